@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/hpower2/url-shortener/database"
+	"github.com/hpower2/url-shortener/internal/backup"
+	"github.com/hpower2/url-shortener/internal/config"
+	"github.com/hpower2/url-shortener/internal/repository"
+	"github.com/hpower2/url-shortener/redis"
+)
+
+const backupUsage = `Usage:
+  main backup export -o FILE [--include-events]
+  main backup import -i FILE [--skip-cache]`
+
+// runBackup runs the backup subcommands against the home region's database
+func runBackup(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, backupUsage)
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	switch args[0] {
+	case "export":
+		runBackupExport(cfg, args[1:])
+	case "import":
+		runBackupImport(cfg, args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, backupUsage)
+		os.Exit(2)
+	}
+}
+
+// runBackupExport writes an archive of the dataset to a file, or stdout for "-"
+func runBackupExport(cfg *config.Config, args []string) {
+	flags := flag.NewFlagSet("backup export", flag.ExitOnError)
+	output := flags.String("o", "", "archive file to write, or - for stdout")
+	includeEvents := flags.Bool("include-events", false, "include raw click events, not just aggregates")
+	flags.Parse(args)
+	if *output == "" {
+		fmt.Fprintln(os.Stderr, backupUsage)
+		os.Exit(2)
+	}
+
+	db, err := database.NewDatabase(convertDatabaseConfig(&cfg.Database))
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	var w io.Writer = os.Stdout
+	var file *os.File
+	if *output != "-" {
+		// Written under a temporary name so a failed export never leaves a partial archive
+		file, err = os.Create(*output + ".partial")
+		if err != nil {
+			log.Fatalf("Failed to create archive: %v", err)
+		}
+		w = file
+	}
+
+	summary, err := backup.Export(context.Background(), db, w, backup.Options{IncludeClickEvents: *includeEvents})
+	if file != nil {
+		if err == nil {
+			err = file.Close()
+		} else {
+			file.Close()
+		}
+		if err == nil {
+			err = os.Rename(file.Name(), *output)
+		} else {
+			os.Remove(file.Name())
+		}
+	}
+	if err != nil {
+		log.Fatalf("Backup export failed: %v", err)
+	}
+
+	for _, table := range summary.Tables {
+		log.Printf("Exported %d %s rows", summary.Counts[table], table)
+	}
+}
+
+// runBackupImport restores an archive into an empty database and rebuilds the
+// link cache from it
+func runBackupImport(cfg *config.Config, args []string) {
+	flags := flag.NewFlagSet("backup import", flag.ExitOnError)
+	input := flags.String("i", "", "archive file to read, or - for stdin")
+	skipCache := flags.Bool("skip-cache", false, "don't rebuild the Redis link cache")
+	flags.Parse(args)
+	if *input == "" {
+		fmt.Fprintln(os.Stderr, backupUsage)
+		os.Exit(2)
+	}
+
+	var r io.Reader = os.Stdin
+	if *input != "-" {
+		file, err := os.Open(*input)
+		if err != nil {
+			log.Fatalf("Failed to open archive: %v", err)
+		}
+		defer file.Close()
+		r = file
+	}
+
+	db, err := database.NewDatabase(convertDatabaseConfig(&cfg.Database))
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	summary, err := backup.Restore(ctx, db, r)
+	if err != nil {
+		log.Fatalf("Backup import failed: %v", err)
+	}
+	for _, table := range summary.Tables {
+		log.Printf("Restored %d %s rows", summary.Counts[table], table)
+	}
+
+	if *skipCache {
+		return
+	}
+
+	redisClient, err := redis.NewRedisClient(convertRedisConfig(&cfg.Redis))
+	if err != nil {
+		log.Fatalf("Restore committed, but failed to connect to Redis to rebuild the cache: %v", err)
+	}
+	defer redisClient.Close()
+
+	cacheRepo := repository.NewCacheRepository(repository.NewRegionRouter(cfg.Database.Region, db, redisClient))
+	rebuilt, err := backup.RebuildCache(ctx, db, cacheRepo)
+	if err != nil {
+		log.Fatalf("Restore committed, but rebuilding the cache failed: %v", err)
+	}
+	log.Printf("Rebuilt the cache of %d links", rebuilt)
+}
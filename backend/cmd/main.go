@@ -3,17 +3,27 @@ package main
 import (
 	"context"
 	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	oldconfig "github.com/hpower2/url-shortener/config"
 	"github.com/hpower2/url-shortener/database"
 	"github.com/hpower2/url-shortener/handlers"
+	"github.com/hpower2/url-shortener/internal/chaos"
 	"github.com/hpower2/url-shortener/internal/config"
+	"github.com/hpower2/url-shortener/internal/fetcher"
+	applogger "github.com/hpower2/url-shortener/internal/logger"
 	"github.com/hpower2/url-shortener/internal/middleware"
+	"github.com/hpower2/url-shortener/internal/models"
 	"github.com/hpower2/url-shortener/internal/repository"
 	"github.com/hpower2/url-shortener/internal/services"
+	"github.com/hpower2/url-shortener/internal/tracking"
 	"github.com/hpower2/url-shortener/redis"
-	"github.com/sirupsen/logrus"
 )
 
 // convertDatabaseConfig converts new config to old config format
@@ -39,6 +49,12 @@ func convertRedisConfig(newCfg *config.RedisConfig) *oldconfig.RedisConfig {
 }
 
 func main() {
+	// Operator subcommands run instead of the server
+	if len(os.Args) > 1 && os.Args[1] == "backup" {
+		runBackup(os.Args[2:])
+		return
+	}
+
 	// Load configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
@@ -46,8 +62,17 @@ func main() {
 	}
 
 	// Initialize logger
-	logger := logrus.New()
-	logger.SetFormatter(&logrus.JSONFormatter{})
+	logger, closeLogger, err := applogger.New(&cfg.Logging)
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer closeLogger()
+
+	// Initialize error tracking (no-op without SENTRY_DSN)
+	if err := tracking.Init(&cfg.Sentry); err != nil {
+		logger.WithError(err).Warn("Error tracking disabled")
+	}
+	defer tracking.Flush(2 * time.Second)
 
 	// Initialize database
 	db, err := database.NewDatabase(convertDatabaseConfig(&cfg.Database))
@@ -63,54 +88,255 @@ func main() {
 	}
 	defer redisClient.Close()
 
+	// Connect the pools of additional data regions, which share the home
+	// region's port, credentials and database name
+	regionRouter := repository.NewRegionRouter(cfg.Database.Region, db, redisClient)
+	for region, host := range cfg.Database.RegionHosts {
+		regionDBConfig := cfg.Database
+		regionDBConfig.Host = host
+		regionDB, err := database.NewDatabase(convertDatabaseConfig(&regionDBConfig))
+		if err != nil {
+			log.Fatalf("Failed to connect to database in region %s: %v", region, err)
+		}
+		defer regionDB.Close()
+
+		regionRedisConfig := cfg.Redis
+		regionRedisConfig.Host = cfg.Redis.RegionHosts[region]
+		regionRedis, err := redis.NewRedisClient(convertRedisConfig(&regionRedisConfig))
+		if err != nil {
+			log.Fatalf("Failed to connect to Redis in region %s: %v", region, err)
+		}
+		defer regionRedis.Close()
+
+		regionRouter.AddRegion(region, regionDB, regionRedis)
+	}
+
 	// Initialize repositories
-	urlRepo := repository.NewURLRepository(db)
-	cacheRepo := repository.NewCacheRepository(redisClient)
+	urlRepo := repository.NewURLRepository(regionRouter)
+	cacheRepo := repository.NewCacheRepository(regionRouter)
 	userRepo := repository.NewUserRepository(db)
+	userEmailRepo := repository.NewUserEmailRepository(db)
 	otpRepo := repository.NewOTPRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	webhookRepo := repository.NewWebhookRepository(regionRouter)
+	urlEventRepo := repository.NewURLEventRepository(regionRouter)
+	preferencesRepo := repository.NewPreferencesRepository(db)
+	domainRepo := repository.NewDomainRepository(db)
+	verifiedDomainRepo := repository.NewVerifiedDomainRepository(db)
+	organizationRepo := repository.NewOrganizationRepository(db)
+	qrBatchRepo := repository.NewQRBatchRepository(db)
+	qrPayloadRepo := repository.NewQRPayloadRepository(regionRouter)
+	reservedCodeRepo := repository.NewReservedCodeRepository(db)
+	honeytokenRepo := repository.NewHoneytokenRepository(db)
+	accountDeletionRepo := repository.NewAccountDeletionRepository(regionRouter)
+	usageReportRepo := repository.NewUsageReportRepository(regionRouter)
+	// Deployments moving old click events to object storage wrap urlRepo here with
+	// repository.NewArchivedClickRepository, so click events and analytics reach
+	// back past what Postgres still holds
+
+	// Every request the server makes on its own goes out under one policy
+	outboundFetcher, err := fetcher.New(&cfg.Fetch)
+	if err != nil {
+		log.Fatalf("Failed to create outbound fetcher: %v", err)
+	}
 
 	// Initialize services
 	baseURL := cfg.App.BaseURL
-	urlService := services.NewURLService(urlRepo, userRepo, cacheRepo, baseURL)
-	authService := services.NewAuthService(userRepo, cfg.Security.JWTSecret)
-	emailService := services.NewEmailService(&cfg.SMTP)
-	otpService := services.NewOTPService(otpRepo, userRepo)
+	webhookService := services.NewWebhookService(webhookRepo, urlRepo, cacheRepo, outboundFetcher)
 	rabbitMQService := services.NewRabbitMQService(&cfg.RabbitMQ)
-	emailQueueConsumer := services.NewEmailQueueConsumer(rabbitMQService, emailService, otpService, cfg)
+	urlEventService := services.NewURLEventService(urlEventRepo, cacheRepo, rabbitMQService, regionRouter, cfg.App.URLEventRelayInterval)
+	domainService := services.NewDomainService(domainRepo, urlRepo, preferencesRepo, regionRouter)
+	preferencesService := services.NewPreferencesService(preferencesRepo, domainService)
+	verifiedDomainService := services.NewVerifiedDomainService(verifiedDomainRepo)
+	jwtKeys, err := services.LoadJWTKeys(&cfg.Security)
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+	authService := services.NewAuthService(userRepo, refreshTokenRepo, cacheRepo, jwtKeys, cfg)
+	emailService := services.NewEmailService(&cfg.SMTP, userRepo)
+	organizationService := services.NewOrganizationService(organizationRepo, userRepo, urlRepo, regionRouter, &cfg.SMTP)
+	reservedRouteService := services.NewReservedRouteService(urlRepo, userRepo, cacheRepo, reservedCodeRepo, emailService, organizationService, webhookService, regionRouter, baseURL, services.DefaultReservedPrefixes, cfg.App.ReservedCodes)
+	suspectList := services.NewSuspectList(honeytokenRepo, cacheRepo, regionRouter, cfg.Security.HoneytokenSuspectTTL, cfg.Security.HoneytokenASNThreshold, cfg.Security.HoneytokenHitRetention)
+	variantClickCounter := services.NewVariantClickCounter(urlRepo)
+	urlService := services.NewURLService(urlRepo, userRepo, cacheRepo, preferencesRepo, verifiedDomainRepo, webhookService, urlEventService, reservedRouteService, organizationService, domainService, suspectList, regionRouter, services.NewURLScanner(cfg, outboundFetcher), services.NewLinkMetadataFetcher(outboundFetcher), variantClickCounter, cfg)
+	usageReportService := services.NewUsageReportService(usageReportRepo, organizationRepo, userRepo, cacheRepo, emailService, cfg.App.UsageReportEmails)
+	otpService := services.NewOTPService(otpRepo, userRepo)
+	userEmailService := services.NewUserEmailService(userEmailRepo, userRepo, otpService)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, userRepo, cacheRepo, cfg)
+	// Deployment-specific redirect hooks (SSO gates, legal interstitials, ...) run in this order
+	redirectHooks := []services.RedirectHook{}
+	for _, hook := range redirectHooks {
+		urlService.RegisterHook(hook)
+	}
+	// Deployment-specific click enrichers (internal traffic, partner networks, ...) run
+	// in this order, after the built-in user agent, location and bot enrichers
+	clickEnrichers := []services.Enricher{}
+	for _, enricher := range clickEnrichers {
+		urlService.RegisterEnricher(enricher)
+	}
+
+	qrBatchService := services.NewQRBatchService(qrBatchRepo, urlRepo, baseURL)
+	qrPayloadService := services.NewQRPayloadService(qrPayloadRepo, cacheRepo, urlService, regionRouter, baseURL)
+	emailQueueConsumer := services.NewEmailQueueConsumer(rabbitMQService, emailService, otpService, organizationService, cfg)
+	accountDeletionService := services.NewAccountDeletionService(accountDeletionRepo, userRepo, cacheRepo, regionRouter, rabbitMQService)
+
+	// Dependencies probed for the public status page. The email queue only
+	// delays emails, so it doesn't take the service down.
+	statusChecks := []services.DependencyCheck{
+		{Name: "database", Critical: true, Check: db.PingContext},
+		{Name: "redis", Critical: true, Check: func(ctx context.Context) error { return redisClient.Ping(ctx).Err() }},
+		{Name: "email_queue", Check: func(ctx context.Context) error { return rabbitMQService.Ping() }},
+	}
+	for _, region := range regionRouter.Regions()[1:] {
+		regionCtx := repository.WithRegion(context.Background(), region)
+		regionDB, regionRedis := regionRouter.DB(regionCtx), regionRouter.Cache(regionCtx)
+		statusChecks = append(statusChecks,
+			services.DependencyCheck{Name: "database_" + region, Check: regionDB.PingContext},
+			services.DependencyCheck{Name: "redis_" + region, Check: func(ctx context.Context) error { return regionRedis.Ping(ctx).Err() }},
+		)
+	}
+	statusService := services.NewStatusService(statusChecks, cacheRepo, &cfg.App)
 
 	// Initialize handlers
-	handler := handlers.NewHandler(urlService, baseURL, cfg.App.FrontendURL)
+	confirmations := handlers.NewConfirmations(cacheRepo, cfg.Security.ConfirmationTTL)
+	handler := handlers.NewHandler(urlService, domainService, preferencesService, services.NewQRCodeService(outboundFetcher), confirmations, baseURL, cfg.App.FrontendURL, cfg.App.ComingSoonURL)
 	authHandler := handlers.NewAuthHandler(authService)
 	otpHandler := handlers.NewOTPHandler(otpService, emailQueueConsumer, userRepo)
+	userEmailHandler := handlers.NewUserEmailHandler(userEmailService, emailQueueConsumer)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	preferencesHandler := handlers.NewPreferencesHandler(preferencesService)
+	domainHandler := handlers.NewDomainHandler(domainService, confirmations)
+	verifiedDomainHandler := handlers.NewVerifiedDomainHandler(verifiedDomainService)
+	emailFeedbackHandler := handlers.NewEmailFeedbackHandler(services.NewEmailFeedbackService(userRepo, otpRepo, &cfg.SMTP, outboundFetcher))
+	adminHandler := handlers.NewAdminHandler(otpService, urlService, reservedRouteService, suspectList)
+	accountDeletionHandler := handlers.NewAccountDeletionHandler(accountDeletionService, confirmations)
+	organizationHandler := handlers.NewOrganizationHandler(organizationService, usageReportService)
+	qrBatchHandler := handlers.NewQRBatchHandler(qrBatchService)
+	qrPayloadHandler := handlers.NewQRPayloadHandler(qrPayloadService)
+	statusHandler := handlers.NewStatusHandler(statusService)
+	changelogHandler := handlers.NewChangelogHandler(models.APIChangelog, models.APIDeprecations)
+
+	// Background work runs until SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Start email queue consumer
-	ctx := context.Background()
 	if err := emailQueueConsumer.Start(ctx); err != nil {
 		log.Printf("Failed to start email queue consumer: %v", err)
 	}
 
+	// Purge deleted accounts in the background over the same connection
+	accountDeletionService.Start(ctx)
+
+	// Record link expirations and publish link events that missed the message bus
+	urlEventService.Start(ctx)
+
+	// Write split test variant clicks in batches
+	variantClickCounter.Start(ctx)
+
+	// Render QR batches left pending by stopped instances, and expire old archives
+	qrBatchService.Start(ctx)
+
+	// Pick the generated short code length before the first link is created;
+	// the scheduler keeps it up to date
+	if _, err := urlService.RefreshKeyspace(ctx); err != nil {
+		log.Printf("Failed to check short code keyspace: %v", err)
+	}
+
+	// Start scheduled jobs (link expiration, click retention, monthly usage reports, token cleanup)
+	scheduler := services.NewScheduler(urlService, usageReportService, authService, otpService, organizationService, userEmailService, domainService, suspectList, cfg.App.CleanupInterval)
+	scheduler.Start(ctx)
+
+	// Probe dependencies for the status page
+	statusService.Start(ctx)
+
+	// Health-check canary rollouts, promoting or rolling them back
+	canaryMonitor := services.NewCanaryMonitor(urlRepo, cacheRepo, webhookService, urlEventService, regionRouter, outboundFetcher, &cfg.App)
+	canaryMonitor.Start(ctx)
+
+	// Keep the short codes reserved through the admin API in sync across instances
+	reservedRouteService.Start(ctx)
+
+	// Refuse links killed on any instance without waiting for the cache
+	urlService.ListenForKills(ctx)
+
 	// Initialize Gin router
 	router := gin.New()
 
-	// Add middleware
-	router.Use(middleware.Logger(logger))
+	// Middleware shared by every route, including the redirect fast path
 	router.Use(middleware.Recovery(logger))
-	router.Use(middleware.CORS([]string{"*"}))
-	router.Use(middleware.RateLimiter(100, 10)) // 100 requests per second, burst of 10
+
+	// Block obviously malicious requests before they reach handlers
+	requestFilter := middleware.NewRequestFilter(&cfg.Security, logger)
+	if cfg.Security.WAFEnabled {
+		router.Use(requestFilter.Handler())
+	}
+
 	router.Use(middleware.RequestID())
-	router.Use(middleware.Security())
+	router.Use(middleware.ErrorReporter())
+	router.Use(middleware.GeoLocation(cfg.App.GeoCountryHeader, cfg.App.GeoCityHeader, cfg.App.GeoASNHeader))
+
+	// Inject faults into the database and Redis calls of chosen routes (staging only)
+	if cfg.Chaos.Rules != "" {
+		if !chaos.Enabled {
+			logger.Warn("CHAOS_RULES is ignored: this binary was built without -tags chaos")
+		} else {
+			chaosMiddleware, err := chaos.Middleware(cfg.Chaos.Rules)
+			if err != nil {
+				log.Fatalf("Invalid CHAOS_RULES: %v", err)
+			}
+			router.Use(chaosMiddleware)
+			logger.Warn("Injecting faults into database and Redis calls per CHAOS_RULES")
+		}
+	}
+
+	// Full middleware chain for the API and health endpoints
+	app := router.Group("/")
+	app.Use(middleware.Logger(logger))
+	app.Use(middleware.CORS([]string{"*"}))
+	app.Use(middleware.RateLimiter(100, 10)) // 100 requests per second, burst of 10
+	if cfg.Security.IPRateLimit > 0 {
+		app.Use(middleware.IPRateLimiter(cacheRepo, cfg.Security.IPRateLimit, cfg.Security.IPRateWindow))
+	}
+	if cfg.Security.SuspectRateLimit > 0 {
+		app.Use(middleware.SuspectRateLimiter(cacheRepo, suspectList, cfg.Security.SuspectRateLimit, cfg.Security.IPRateWindow))
+	}
+	app.Use(middleware.Security())
+	app.Use(middleware.BodySizeLimits(cfg.Security.MaxRequestSize, map[string]int64{
+		"POST /api/v1/auth/register": cfg.Security.MaxAuthRequestSize,
+		"POST /api/v1/auth/login":    cfg.Security.MaxAuthRequestSize,
+		"POST /api/v1/auth/refresh":  cfg.Security.MaxAuthRequestSize,
+		"POST /api/v1/auth/logout":   cfg.Security.MaxAuthRequestSize,
+		"POST /api/v1/otp/generate":  cfg.Security.MaxAuthRequestSize,
+		"POST /api/v1/otp/verify":    cfg.Security.MaxAuthRequestSize,
+		"POST /api/v1/urls/qr-batch": cfg.Security.MaxBulkRequestSize,
+	}))
 
 	// Health check endpoint
-	router.GET("/health", handler.HealthCheck)
+	app.GET("/health", handler.HealthCheck)
+	app.GET("/status", statusHandler.GetStatus)
+
+	// Public keys for services verifying access tokens
+	app.GET("/.well-known/jwks.json", authHandler.GetJWKS)
 
 	// API routes
-	api := router.Group("/api/v1")
+	api := app.Group("/api/v1")
+	api.Use(middleware.Deprecation(models.APIDeprecations, "/api/v1/changelog"))
 	{
+		// API changelog and deprecations (public)
+		api.GET("/changelog", changelogHandler.GetChangelog)
+
+		// Webhook event catalog (public)
+		api.GET("/webhooks/events", webhookHandler.GetEvents)
+
 		// Authentication routes (public)
 		auth := api.Group("/auth")
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
+			auth.POST("/refresh", authHandler.RefreshToken)
 			auth.POST("/logout", authHandler.Logout)
 		}
 
@@ -121,38 +347,283 @@ func main() {
 			otp.POST("/verify", otpHandler.VerifyOTP)
 		}
 
+		// Email provider bounce and complaint notifications (token authenticated)
+		api.POST("/email/feedback/ses", emailFeedbackHandler.HandleSES)
+		api.POST("/email/feedback/sendgrid", emailFeedbackHandler.HandleSendGrid)
+
+		// Operator endpoints (admin token authenticated)
+		admin := api.Group("/admin")
+		admin.Use(middleware.AdminAuth(cfg.Security.AdminToken))
+		{
+			admin.GET("/otp-deliveries", adminHandler.GetOTPDeliveries)
+			admin.GET("/short-codes", adminHandler.GetShortCodeKeyspace)
+			admin.GET("/request-filter", requestFilter.StatsHandler())
+			admin.GET("/reserved-codes", adminHandler.GetReservedCodes)
+			admin.POST("/reserved-codes", adminHandler.ReserveCode)
+			admin.DELETE("/reserved-codes/:code", adminHandler.ReleaseCode)
+			admin.GET("/honeytoken-hits", adminHandler.GetHoneytokenHits)
+			admin.DELETE("/suspects", adminHandler.PardonSuspect)
+			admin.GET("/account-deletions", accountDeletionHandler.GetDeletions)
+			admin.POST("/account-deletions", accountDeletionHandler.CreateDeletion)
+			admin.GET("/account-deletions/:id", accountDeletionHandler.GetDeletion)
+			admin.POST("/account-deletions/:id/resume", accountDeletionHandler.ResumeDeletion)
+			admin.PUT("/urls/:shortCode/suspicious", middleware.LinkRegion(regionRouter), adminHandler.SetURLSuspicious)
+		}
+
+		// Landing page of contact card, Wi-Fi and calendar event QR codes (public)
+		api.GET("/qr/:token", qrPayloadHandler.ServeQRLanding)
+
+		// Password-protected link unlock (public)
+		api.POST("/urls/:shortCode/unlock", middleware.LinkRegion(regionRouter), handler.UnlockURL)
+
+		// Destination edits by collaborators without an account (public, token authenticated)
+		api.GET("/guest-edit/:token", handler.GetGuestEdit)
+		api.PUT("/guest-edit/:token", handler.GuestEdit)
+
+		// Link expansion without a redirect, public unless configured otherwise
+		resolveChain := []gin.HandlerFunc{}
+		if cfg.Security.ResolveRequireAuth {
+			resolveChain = append(resolveChain, middleware.AuthMiddleware(authService, apiKeyService))
+		}
+		if cfg.Security.ResolveRateLimit > 0 {
+			resolveChain = append(resolveChain, middleware.RouteRateLimiter(cacheRepo, "resolve", cfg.Security.ResolveRateLimit, cfg.Security.IPRateWindow))
+		}
+		resolveChain = append(resolveChain, middleware.LinkRegion(regionRouter), handler.ResolveURL)
+		api.GET("/resolve/:shortCode", resolveChain...)
+
 		// Protected routes (require authentication)
 		protected := api.Group("/")
-		protected.Use(middleware.AuthMiddleware(authService))
+		protected.Use(middleware.AuthMiddleware(authService, apiKeyService))
+		protected.Use(middleware.APIUsage(usageReportService))
+		protected.Use(middleware.Sandbox("POST /api/v1/urls", "PUT /api/v1/urls/:shortCode", "DELETE /api/v1/urls/:shortCode"))
+		protected.Use(middleware.DataRegion(organizationService))
 		{
 			// User profile routes
 			protected.GET("/profile", authHandler.GetProfile)
 			protected.PUT("/profile", authHandler.UpdateProfile)
 			protected.POST("/profile/change-password", authHandler.ChangePassword)
-			protected.POST("/auth/refresh", authHandler.RefreshToken)
+			protected.POST("/profile/delete-preview", accountDeletionHandler.PreviewDeleteAccount)
+			protected.DELETE("/profile", accountDeletionHandler.DeleteAccount)
+
+			// Secondary login emails
+			protected.GET("/profile/emails", userEmailHandler.GetEmails)
+			protected.POST("/profile/emails", userEmailHandler.AddEmail)
+			protected.POST("/profile/emails/:id/verify", userEmailHandler.VerifyEmail)
+			protected.POST("/profile/emails/:id/resend", userEmailHandler.ResendVerification)
+			protected.POST("/profile/emails/:id/primary", userEmailHandler.MakePrimary)
+			protected.DELETE("/profile/emails/:id", userEmailHandler.DeleteEmail)
+
+			// Link creation defaults
+			protected.GET("/profile/utm-defaults", preferencesHandler.GetUTMDefaults)
+			protected.PUT("/profile/utm-defaults", preferencesHandler.UpdateUTMDefaults)
+			protected.GET("/profile/preferences", preferencesHandler.GetPreferences)
+			protected.PUT("/profile/preferences", preferencesHandler.UpdatePreferences)
+
+			// API keys for signed server-to-server requests
+			protected.POST("/api-keys", apiKeyHandler.CreateAPIKey)
+			protected.GET("/api-keys", apiKeyHandler.GetAPIKeys)
+			protected.DELETE("/api-keys/:id", apiKeyHandler.RevokeAPIKey)
+
+			// Organizations and their white-label email branding
+			protected.POST("/organization", organizationHandler.CreateOrganization)
+			protected.GET("/organization", organizationHandler.GetOrganization)
+			protected.GET("/organization/members", organizationHandler.GetMembers)
+			protected.POST("/organization/members", organizationHandler.InviteMember)
+			protected.GET("/organization/invitations", organizationHandler.GetInvitations)
+			protected.POST("/organization/invitations/:id/accept", organizationHandler.AcceptInvitation)
+			protected.DELETE("/organization/invitations/:id", organizationHandler.DeclineInvitation)
+			protected.DELETE("/organization/members/:userId", organizationHandler.RemoveMember)
+			protected.GET("/organization/email-branding", organizationHandler.GetEmailBranding)
+			protected.PUT("/organization/email-branding", organizationHandler.UpdateEmailBranding)
+			protected.PUT("/organization/data-region", organizationHandler.SetDataRegion)
+			protected.PUT("/organization/code-prefix", organizationHandler.SetCodePrefix)
+			protected.GET("/organization/links", organizationHandler.GetNamespaceLinks)
+			protected.GET("/organization/links/analytics", organizationHandler.GetNamespaceRollup)
+			protected.POST("/organization/email-branding/verify", organizationHandler.VerifyEmailDomain)
+			protected.GET("/orgs/:id/reports", organizationHandler.GetUsageReports)
+
+			// Custom domains and their branded error pages
+			protected.POST("/domains", domainHandler.CreateDomain)
+			protected.GET("/domains", domainHandler.GetDomains)
+			protected.POST("/domains/:id/delete-preview", domainHandler.PreviewDeleteDomain)
+			protected.DELETE("/domains/:id", domainHandler.DeleteDomain)
+			protected.POST("/domains/:id/verify", domainHandler.VerifyDomain)
+			protected.PUT("/domains/:id/error-pages", domainHandler.UpdateErrorPages)
+
+			// Ownership claims on link destination domains
+			protected.POST("/verified-domains", verifiedDomainHandler.CreateDomain)
+			protected.GET("/verified-domains", verifiedDomainHandler.GetDomains)
+			protected.DELETE("/verified-domains/:id", verifiedDomainHandler.DeleteDomain)
+			protected.POST("/verified-domains/:id/verify", verifiedDomainHandler.VerifyDomain)
+
+			// Account-wide link and click aggregates
+			protected.GET("/dashboard", handler.GetDashboard)
 
 			// URL management (protected)
-			protected.POST("/urls", handler.CreateURL)
+			protected.POST("/urls", middleware.APIKeyRateLimiter(cfg.Security.APIKeyCreateRPS, cfg.Security.APIKeyCreateBurst, cfg.Security.APIKeyCreateMaxWait), handler.CreateURL)
 			protected.GET("/urls", handler.GetAllURLs)
+			protected.GET("/urls/recent-activity", handler.GetRecentActivity)
+			protected.GET("/urls/search", handler.SearchURLs)
+			protected.GET("/urls/campaigns", handler.GetCampaignStats)
+			protected.GET("/urls/export", handler.ExportURLs)
+			protected.POST("/urls/delete-by-label/preview", handler.PreviewDeleteLabeledLinks)
+			protected.POST("/urls/delete-by-label", handler.DeleteLabeledLinks)
 			protected.GET("/urls/:shortCode", handler.GetURLStats)
 			protected.PUT("/urls/:shortCode", handler.UpdateURL)
 			protected.DELETE("/urls/:shortCode", handler.DeleteURL)
+			protected.POST("/urls/:shortCode/kill", handler.KillURL)
+			protected.POST("/urls/:shortCode/extend", handler.ExtendExpiration)
+			protected.POST("/urls/:shortCode/refresh-metadata", handler.RefreshMetadata)
+			protected.GET("/urls/:shortCode/extensions", handler.GetExpirationExtensions)
+			protected.GET("/urls/:shortCode/destination-changes", handler.GetDestinationChanges)
+			protected.GET("/urls/:shortCode/events", handler.GetURLEvents)
+			protected.POST("/urls/:shortCode/password/rotate", handler.RotateLinkPassword)
+			protected.POST("/urls/:shortCode/share-tokens", handler.CreateShareToken)
+			protected.GET("/urls/:shortCode/share-tokens", handler.GetShareTokens)
+			protected.DELETE("/urls/:shortCode/share-tokens/:id", handler.RevokeShareToken)
+			protected.POST("/urls/:shortCode/guest-edit-links", handler.CreateGuestEditLink)
+			protected.GET("/urls/:shortCode/guest-edit-links", handler.GetGuestEditLinks)
+			protected.DELETE("/urls/:shortCode/guest-edit-links/:id", handler.RevokeGuestEditLink)
 
 			// Analytics (protected)
 			protected.GET("/urls/:shortCode/analytics", handler.GetAnalytics)
+			protected.GET("/urls/:shortCode/analytics/timeseries", handler.GetClickTimeSeries)
+			protected.POST("/urls/:shortCode/variants/:name/conversions", handler.RecordConversion)
+			protected.GET("/urls/:shortCode/clicks", handler.GetClicks)
+			protected.GET("/urls/:shortCode/clicks/stream", handler.StreamClicks)
+			protected.GET("/urls/:shortCode/clicks/export", handler.ExportClicks)
 
 			// QR Code generation (protected)
 			protected.GET("/urls/:shortCode/qr", handler.GenerateQRCode)
+			protected.POST("/urls/qr-batch", qrBatchHandler.CreateQRBatch)
+			protected.GET("/urls/qr-batch/:id", qrBatchHandler.GetQRBatch)
+			protected.GET("/urls/qr-batch/:id/download", qrBatchHandler.DownloadQRBatch)
+
+			// Contact card, Wi-Fi and calendar event QR codes (protected)
+			protected.POST("/qr-payloads", qrPayloadHandler.CreateQRPayload)
+			protected.GET("/qr-payloads", qrPayloadHandler.GetQRPayloads)
+			protected.GET("/qr-payloads/:id", qrPayloadHandler.GetQRPayload)
+			protected.PUT("/qr-payloads/:id", qrPayloadHandler.UpdateQRPayload)
+			protected.DELETE("/qr-payloads/:id", qrPayloadHandler.DeleteQRPayload)
+			protected.GET("/qr-payloads/:id/qr", qrPayloadHandler.GetQRPayloadCode)
+
+			// Per-link webhook subscriptions (protected)
+			protected.POST("/urls/:shortCode/webhooks", webhookHandler.CreateWebhook)
+			protected.GET("/urls/:shortCode/webhooks", webhookHandler.GetWebhooks)
+			protected.PUT("/urls/:shortCode/webhooks/:id", webhookHandler.UpdateWebhook)
+			protected.DELETE("/urls/:shortCode/webhooks/:id", webhookHandler.DeleteWebhook)
+			protected.GET("/urls/:shortCode/webhooks/:id/deliveries", webhookHandler.GetDeliveries)
+			protected.POST("/urls/:shortCode/webhooks/:id/test", webhookHandler.TestWebhook)
+			protected.POST("/urls/:shortCode/click-triggers", webhookHandler.CreateClickTrigger)
+			protected.GET("/urls/:shortCode/click-triggers", webhookHandler.GetClickTriggers)
+			protected.DELETE("/urls/:shortCode/click-triggers/:id", webhookHandler.DeleteClickTrigger)
 		}
 	}
 
-	// Direct redirect routes (must be last to avoid conflicts and remain public)
-	router.GET("/:shortCode", handler.RedirectURL)
+	// Direct redirect routes (must be last to avoid conflicts and remain public).
+	// The hottest route skips the API chain: no CORS/CSP or rate limiting, sampled
+	// access logs. Only clients caught probing honeytokens are rate limited.
+	redirectChain := []gin.HandlerFunc{middleware.SampledLogger(logger, cfg.Logging.RedirectSampleRate), middleware.RedirectMetrics(statusService)}
+	if cfg.Security.SuspectRateLimit > 0 {
+		redirectChain = append(redirectChain, middleware.SuspectRateLimiter(cacheRepo, suspectList, cfg.Security.SuspectRateLimit, cfg.Security.IPRateWindow))
+	}
+	redirectChain = append(redirectChain, middleware.LinkRegion(regionRouter), handler.RedirectURL)
+	router.GET("/:shortCode", redirectChain...)
+
+	// Every top-level route is reserved so it can't be claimed as a short code, and
+	// links created before a route existed are moved out of its way, once per region
+	for _, route := range router.Routes() {
+		reservedRouteService.Reserve(route.Path)
+	}
+	go func() {
+		migrated, err := reservedRouteService.MigrateConflictingCodes(ctx)
+		if err != nil {
+			log.Printf("Failed to migrate reserved short codes: %v", err)
+		} else if migrated > 0 {
+			log.Printf("Migrated %d short codes that conflict with reserved routes", migrated)
+		}
+	}()
 
 	// Start server
 	log.Printf("🚀 URL Shortener v2.0 starting on port %s", cfg.Server.Port)
 	log.Printf("📊 Features enabled: Custom codes, Analytics, QR codes, Rate limiting, User Authentication")
-	if err := router.Run(":" + cfg.Server.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	server := &http.Server{
+		Addr:           net.JoinHostPort(cfg.Server.Host, cfg.Server.Port),
+		Handler:        router,
+		ReadTimeout:    cfg.Server.ReadTimeout,
+		WriteTimeout:   cfg.Server.WriteTimeout,
+		IdleTimeout:    cfg.Server.IdleTimeout,
+		MaxHeaderBytes: cfg.Server.MaxHeaderBytes,
+	}
+
+	// Certificates come from the configured files, or from Let's Encrypt when
+	// autocert domains are listed
+	var redirectHandler http.Handler = httpsRedirect(cfg.Server.Port)
+	certFile, keyFile := cfg.Security.CertFile, cfg.Security.KeyFile
+	if cfg.Security.EnableHTTPS && len(cfg.Security.AutocertDomains) > 0 {
+		certManager := newCertManager(&cfg.Security, domainService)
+		server.TLSConfig = certManager.TLSConfig()
+		redirectHandler = certManager.HTTPHandler(redirectHandler)
+		certFile, keyFile = "", ""
+	}
+
+	go func() {
+		var err error
+		if cfg.Security.EnableHTTPS {
+			log.Printf("🔒 Serving HTTPS on port %s", cfg.Server.Port)
+			err = server.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Plain HTTP only redirects to HTTPS, and answers ACME challenges in autocert mode
+	var redirectServer *http.Server
+	if cfg.Security.EnableHTTPS && cfg.Security.HTTPRedirectPort != "" {
+		redirectServer = &http.Server{
+			Addr:         net.JoinHostPort(cfg.Server.Host, cfg.Security.HTTPRedirectPort),
+			Handler:      redirectHandler,
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+			IdleTimeout:  cfg.Server.IdleTimeout,
+		}
+		go func() {
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Failed to start HTTP redirect server: %v", err)
+			}
+		}()
+	}
+
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down...")
+
+	// Everything below shares one deadline; the database and Redis pools are
+	// closed by their deferred calls once main returns
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting connections and let in-flight requests finish
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Timed out draining HTTP requests: %v", err)
+	}
+	if redirectServer != nil {
+		if err := redirectServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to stop HTTP redirect server: %v", err)
+		}
+	}
+
+	// Record the clicks of drained redirects, then write their variant clicks
+	handler.WaitForClicks(shutdownCtx)
+	variantClickCounter.Stop(shutdownCtx)
+
+	// Finish or requeue in-flight emails and purge jobs before closing RabbitMQ
+	accountDeletionService.Stop(shutdownCtx)
+	if err := emailQueueConsumer.Stop(shutdownCtx); err != nil {
+		log.Printf("Failed to stop email queue consumer: %v", err)
 	}
+	log.Println("Shutdown complete")
 }
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/hpower2/url-shortener/internal/config"
+	"github.com/hpower2/url-shortener/internal/services"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// newCertManager obtains and renews Let's Encrypt certificates for the
+// configured domains and for verified custom domains
+func newCertManager(cfg *config.SecurityConfig, domainService services.DomainService) *autocert.Manager {
+	allowed := make(map[string]bool, len(cfg.AutocertDomains))
+	for _, domain := range cfg.AutocertDomains {
+		allowed[domain] = true
+	}
+
+	return &autocert.Manager{
+		Prompt: autocert.AcceptTOS,
+		Cache:  autocert.DirCache(cfg.AutocertCacheDir),
+		Email:  cfg.AutocertEmail,
+		HostPolicy: func(ctx context.Context, host string) error {
+			if allowed[host] {
+				return nil
+			}
+			if _, err := domainService.GetVerifiedDomain(ctx, host); err != nil {
+				return fmt.Errorf("host %q is not configured for certificates", host)
+			}
+			return nil
+		},
+	}
+}
+
+// httpsRedirect permanently redirects plain HTTP requests to the same URL over HTTPS
+func httpsRedirect(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
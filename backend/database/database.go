@@ -7,6 +7,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	"github.com/hpower2/url-shortener/config"
+	"github.com/hpower2/url-shortener/internal/chaos"
 )
 
 type DB struct {
@@ -14,12 +15,15 @@ type DB struct {
 }
 
 func NewDatabase(cfg *config.DatabaseConfig) (*DB, error) {
+	// Sessions run in UTC so timestamps and date arithmetic don't depend on server settings
 	dsn := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s timezone=UTC",
 		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode,
 	)
 
-	db, err := sqlx.Connect("postgres", dsn)
+	// Builds with fault injection wrap the driver; it only disturbs calls of
+	// requests to routes with chaos rules
+	db, err := sqlx.Connect(chaos.DriverName("postgres"), dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
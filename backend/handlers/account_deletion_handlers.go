@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/services"
+)
+
+type AccountDeletionHandler struct {
+	deletionService services.AccountDeletionService
+	confirmations   *Confirmations
+}
+
+func NewAccountDeletionHandler(deletionService services.AccountDeletionService, confirmations *Confirmations) *AccountDeletionHandler {
+	return &AccountDeletionHandler{
+		deletionService: deletionService,
+		confirmations:   confirmations,
+	}
+}
+
+// PreviewDeleteAccount describes what deleting the user's own account destroys,
+// with the token confirming it
+func (h *AccountDeletionHandler) PreviewDeleteAccount(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	impact, err := h.deletionService.PreviewDeletion(c.Request.Context(), userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	h.confirmations.Preview(c, userID.(int), models.ConfirmActionDeleteAccount, "", impact)
+}
+
+// DeleteAccount deactivates the user's own account and queues its data to be
+// purged, once confirmed with the token of its preview
+func (h *AccountDeletionHandler) DeleteAccount(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.confirmations.Confirm(c, userID.(int), models.ConfirmActionDeleteAccount, "") {
+		return
+	}
+
+	if _, err := h.deletionService.RequestDeletion(c.Request.Context(), userID.(int), &req); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Account scheduled for deletion"})
+}
+
+// CreateDeletion deletes a user's account on an admin's behalf
+func (h *AccountDeletionHandler) CreateDeletion(c *gin.Context) {
+	var req models.AdminDeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deletion, err := h.deletionService.DeleteAccount(c.Request.Context(), req.UserID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/api/v1/admin/account-deletions/%d", deletion.ID))
+	c.JSON(http.StatusAccepted, deletion)
+}
+
+// GetDeletions lists account deletions and how far their purge has got
+func (h *AccountDeletionHandler) GetDeletions(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+		return
+	}
+
+	filter := &models.AccountDeletionFilter{
+		Status: c.Query("status"),
+		Limit:  limit,
+	}
+	deletions, err := h.deletionService.GetDeletions(c.Request.Context(), filter)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deletions": deletions,
+		"limit":     filter.Limit,
+	})
+}
+
+// GetDeletion returns the progress of an account deletion
+func (h *AccountDeletionHandler) GetDeletion(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account deletion ID"})
+		return
+	}
+
+	deletion, err := h.deletionService.GetDeletion(c.Request.Context(), id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deletion)
+}
+
+// ResumeDeletion queues a failed account deletion again
+func (h *AccountDeletionHandler) ResumeDeletion(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid account deletion ID"})
+		return
+	}
+
+	deletion, err := h.deletionService.ResumeDeletion(c.Request.Context(), id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, deletion)
+}
+
+// handleError handles different types of errors appropriately
+func (h *AccountDeletionHandler) handleError(c *gin.Context, err error) {
+	handler := &Handler{}
+	handler.handleError(c, err)
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/services"
+)
+
+type AdminHandler struct {
+	otpService    services.OTPService
+	urlService    services.URLService
+	routesService services.ReservedRouteService
+	suspects      services.SuspectList
+}
+
+func NewAdminHandler(otpService services.OTPService, urlService services.URLService, routesService services.ReservedRouteService, suspects services.SuspectList) *AdminHandler {
+	return &AdminHandler{
+		otpService:    otpService,
+		urlService:    urlService,
+		routesService: routesService,
+		suspects:      suspects,
+	}
+}
+
+// GetOTPDeliveries lists OTP emails and how their delivery went, for
+// diagnosing reports of codes that never arrived
+func (h *AdminHandler) GetOTPDeliveries(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+		return
+	}
+
+	filter := &models.OTPDeliveryFilter{
+		Email:  c.Query("email"),
+		Status: c.Query("status"),
+		Limit:  limit,
+	}
+	deliveries, err := h.otpService.GetDeliveries(c.Request.Context(), filter)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"limit":      filter.Limit,
+	})
+}
+
+// GetShortCodeKeyspace reports the generated short code length and how much of
+// the keyspace at that length is used
+func (h *AdminHandler) GetShortCodeKeyspace(c *gin.Context) {
+	c.JSON(http.StatusOK, h.urlService.GetKeyspace())
+}
+
+// SetURLSuspicious flags a link as suspicious, so visitors see a warning page
+// before being redirected, or clears the flag
+func (h *AdminHandler) SetURLSuspicious(c *gin.Context) {
+	var req models.SetSuspiciousRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	url, err := h.urlService.SetSuspicious(c.Request.Context(), c.Param("shortCode"), *req.Suspicious)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, url)
+}
+
+// GetReservedCodes lists the short codes no link can take
+func (h *AdminHandler) GetReservedCodes(c *gin.Context) {
+	codes, err := h.routesService.GetReservedCodes(c.Request.Context())
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"codes": codes})
+}
+
+// ReserveCode stops new links from taking a short code
+func (h *AdminHandler) ReserveCode(c *gin.Context) {
+	var req models.ReserveCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	code, err := h.routesService.ReserveCode(c.Request.Context(), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, code)
+}
+
+// ReleaseCode makes a short code reserved through the admin API available again
+func (h *AdminHandler) ReleaseCode(c *gin.Context) {
+	if err := h.routesService.ReleaseCode(c.Request.Context(), c.Param("code")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Reserved code released successfully"})
+}
+
+// GetHoneytokenHits lists visits to honeytoken short codes, newest first
+func (h *AdminHandler) GetHoneytokenHits(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+		return
+	}
+
+	filter := &models.HoneytokenHitFilter{
+		Code:  c.Query("code"),
+		IP:    c.Query("ip"),
+		Limit: limit,
+	}
+	hits, err := h.suspects.GetHits(c.Request.Context(), filter)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hits":  hits,
+		"limit": filter.Limit,
+	})
+}
+
+// PardonSuspect takes a client IP or ASN off the suspicion list
+func (h *AdminHandler) PardonSuspect(c *gin.Context) {
+	if err := h.suspects.Pardon(c.Request.Context(), c.Query("ip"), c.Query("asn")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Suspect pardoned successfully"})
+}
+
+// handleError handles different types of errors appropriately
+func (h *AdminHandler) handleError(c *gin.Context, err error) {
+	handler := &Handler{}
+	handler.handleError(c, err)
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/hpower2/url-shortener/internal/models"
@@ -26,7 +27,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	response, err := h.authService.Register(c.Request.Context(), &req)
+	response, err := h.authService.Register(c.Request.Context(), &req, c.ClientIP())
 	if err != nil {
 		h.handleError(c, err)
 		return
@@ -115,30 +116,55 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
 }
 
-// RefreshToken generates a new JWT token
+// RefreshToken exchanges a refresh token for a new access and refresh token
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	token, err := h.authService.RefreshToken(c.Request.Context(), userID.(int))
+	tokens, err := h.authService.RefreshToken(c.Request.Context(), req.RefreshToken)
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, tokens)
 }
 
-// Logout handles user logout (client-side token invalidation)
+// Logout revokes the bearer access token and the session of the given refresh token
 func (h *AuthHandler) Logout(c *gin.Context) {
-	// Since we're using JWT, logout is handled client-side by removing the token
-	// In a production system, you might want to maintain a blacklist of tokens
+	var req models.LogoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	accessToken := ""
+	if authHeader := c.GetHeader("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+		accessToken = strings.TrimPrefix(authHeader, "Bearer ")
+	}
+
+	if err := h.authService.Logout(c.Request.Context(), accessToken, req.RefreshToken); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
+// jwksCacheControl lets verifiers cache the key set between rotations
+const jwksCacheControl = "public, max-age=300"
+
+// GetJWKS publishes the public keys access tokens are signed with, so other
+// services can verify them. The set is empty when tokens are signed with HS256.
+func (h *AuthHandler) GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", jwksCacheControl)
+	c.JSON(http.StatusOK, h.authService.JWKS())
+}
+
 // handleError handles different types of errors appropriately
 func (h *AuthHandler) handleError(c *gin.Context, err error) {
 	// Use the same error handling as the main handler
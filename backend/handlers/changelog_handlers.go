@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// changelogCacheControl lets clients reuse the changelog, which only changes with a deploy
+const changelogCacheControl = "public, max-age=3600"
+
+type ChangelogHandler struct {
+	changes      []models.APIChange
+	deprecations []models.Deprecation
+}
+
+func NewChangelogHandler(changes []models.APIChange, deprecations []models.Deprecation) *ChangelogHandler {
+	return &ChangelogHandler{
+		changes:      changes,
+		deprecations: deprecations,
+	}
+}
+
+// GetChangelog lists user-facing API changes and the endpoints deprecated ahead of removal
+func (h *ChangelogHandler) GetChangelog(c *gin.Context) {
+	c.Header("Cache-Control", changelogCacheControl)
+	c.JSON(http.StatusOK, gin.H{
+		"changes":      h.changes,
+		"deprecations": h.deprecations,
+	})
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// Confirmations guards destructive calls with a two-step flow: a preview call
+// answers with the impact of the action and a token, which the destructive
+// call must echo in the X-Confirmation-Token header before the token expires.
+// Tokens are kept in Redis, so any instance can confirm them, and are bound to
+// the user, the action and its subject. Each can be used once, and is used up
+// by the first call that echoes it, even one it doesn't confirm.
+type Confirmations struct {
+	cache repository.CacheRepository
+	ttl   time.Duration
+}
+
+// NewConfirmations creates confirmations whose tokens are valid for ttl
+func NewConfirmations(cache repository.CacheRepository, ttl time.Duration) *Confirmations {
+	return &Confirmations{cache: cache, ttl: ttl}
+}
+
+// Preview answers with the impact of the user's action on subject and a token confirming it
+func (cf *Confirmations) Preview(c *gin.Context, userID int, action, subject string, impact interface{}) {
+	cf.PreviewWithState(c, userID, action, subject, "", impact)
+}
+
+// PreviewWithState is Preview keeping state with the token, such as what the
+// preview counted, for ConfirmWithState to return
+func (cf *Confirmations) PreviewWithState(c *gin.Context, userID int, action, subject, state string, impact interface{}) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		cf.handleError(c, errors.NewInternalError("Failed to generate confirmation token", err))
+		return
+	}
+	token := hex.EncodeToString(raw)
+
+	expiresAt := time.Now().Add(cf.ttl)
+	if err := cf.cache.Set(c.Request.Context(), confirmationKey(token), confirmationBinding(userID, action, subject)+"\n"+state, cf.ttl); err != nil {
+		cf.handleError(c, errors.NewInternalError("Failed to store confirmation token", err))
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, models.ConfirmationPreview{
+		Action:            action,
+		Impact:            impact,
+		ConfirmationToken: token,
+		ExpiresAt:         expiresAt,
+	})
+}
+
+// Confirm reports whether the request echoes a token previewing the user's
+// action on subject, using the token up. Otherwise it answers the request:
+// 428 without a token, 412 for an unknown, expired, used or mismatched one.
+func (cf *Confirmations) Confirm(c *gin.Context, userID int, action, subject string) bool {
+	_, confirmed := cf.ConfirmWithState(c, userID, action, subject)
+	return confirmed
+}
+
+// ConfirmWithState is Confirm also returning the state kept by PreviewWithState.
+// The token is taken from Redis atomically, so concurrent calls echoing the
+// same token can't both be confirmed.
+func (cf *Confirmations) ConfirmWithState(c *gin.Context, userID int, action, subject string) (string, bool) {
+	token := c.GetHeader(models.ConfirmationHeader)
+	if token == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{
+			"error": fmt.Sprintf("Preview this action first and send its confirmation_token in the %s header", models.ConfirmationHeader),
+		})
+		return "", false
+	}
+
+	value, err := cf.cache.Take(c.Request.Context(), confirmationKey(token))
+	if err != nil && !repository.IsCacheMiss(err) {
+		cf.handleError(c, errors.NewInternalError("Failed to use confirmation token", err))
+		return "", false
+	}
+	binding, state, _ := strings.Cut(value, "\n")
+	if err != nil || binding != confirmationBinding(userID, action, subject) {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Confirmation token is invalid or has expired, preview the action again"})
+		return "", false
+	}
+	return state, true
+}
+
+// confirmationKey is where a token's binding is kept; only its hash is stored
+func confirmationKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "confirmation:" + hex.EncodeToString(sum[:])
+}
+
+// confirmationBinding identifies what a token confirms
+func confirmationBinding(userID int, action, subject string) string {
+	return fmt.Sprintf("%d|%s|%s", userID, action, subject)
+}
+
+// handleError handles different types of errors appropriately
+func (cf *Confirmations) handleError(c *gin.Context, err error) {
+	handler := &Handler{}
+	handler.handleError(c, err)
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/services"
+)
+
+type DomainHandler struct {
+	domainService services.DomainService
+	confirmations *Confirmations
+}
+
+func NewDomainHandler(domainService services.DomainService, confirmations *Confirmations) *DomainHandler {
+	return &DomainHandler{
+		domainService: domainService,
+		confirmations: confirmations,
+	}
+}
+
+// CreateDomain registers a custom domain for the current user
+func (h *DomainHandler) CreateDomain(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.CreateCustomDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	domain, err := h.domainService.AddDomain(c.Request.Context(), userID.(int), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"domain":            domain,
+		"verification_name": domain.VerificationRecord(),
+	})
+}
+
+// GetDomains lists the current user's custom domains
+func (h *DomainHandler) GetDomains(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	domains, err := h.domainService.GetDomains(c.Request.Context(), userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"domains": domains})
+}
+
+// VerifyDomain checks DNS ownership of a custom domain
+func (h *DomainHandler) VerifyDomain(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain ID"})
+		return
+	}
+
+	domain, err := h.domainService.VerifyDomain(c.Request.Context(), id, userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, domain)
+}
+
+// UpdateErrorPages configures a custom domain's branded error pages
+func (h *DomainHandler) UpdateErrorPages(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain ID"})
+		return
+	}
+
+	var req models.UpdateErrorPagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	domain, err := h.domainService.UpdateErrorPages(c.Request.Context(), id, userID.(int), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, domain)
+}
+
+// PreviewDeleteDomain describes what removing a custom domain changes, with
+// the token confirming it
+func (h *DomainHandler) PreviewDeleteDomain(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain ID"})
+		return
+	}
+
+	impact, err := h.domainService.PreviewDeleteDomain(c.Request.Context(), id, userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	h.confirmations.Preview(c, userID.(int), models.ConfirmActionDeleteDomain, strconv.Itoa(id), impact)
+}
+
+// DeleteDomain removes a custom domain, once confirmed with the token of its preview
+func (h *DomainHandler) DeleteDomain(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain ID"})
+		return
+	}
+
+	if !h.confirmations.Confirm(c, userID.(int), models.ConfirmActionDeleteDomain, strconv.Itoa(id)) {
+		return
+	}
+
+	if err := h.domainService.DeleteDomain(c.Request.Context(), id, userID.(int)); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Domain deleted successfully"})
+}
+
+// handleError handles different types of errors appropriately
+func (h *DomainHandler) handleError(c *gin.Context, err error) {
+	handler := &Handler{}
+	handler.handleError(c, err)
+}
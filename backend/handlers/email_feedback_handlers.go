@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/services"
+)
+
+// maxEmailFeedbackBody bounds a provider notification or event batch
+const maxEmailFeedbackBody = 1 << 20
+
+type EmailFeedbackHandler struct {
+	feedbackService services.EmailFeedbackService
+}
+
+func NewEmailFeedbackHandler(feedbackService services.EmailFeedbackService) *EmailFeedbackHandler {
+	return &EmailFeedbackHandler{
+		feedbackService: feedbackService,
+	}
+}
+
+// HandleSES receives Amazon SES bounce and complaint notifications from SNS
+func (h *EmailFeedbackHandler) HandleSES(c *gin.Context) {
+	h.handle(c, h.feedbackService.HandleSES)
+}
+
+// HandleSendGrid receives SendGrid Event Webhook batches
+func (h *EmailFeedbackHandler) HandleSendGrid(c *gin.Context) {
+	h.handle(c, h.feedbackService.HandleSendGrid)
+}
+
+// handle authenticates a provider request by its token query parameter and processes its body
+func (h *EmailFeedbackHandler) handle(c *gin.Context, process func(ctx context.Context, body []byte) (*models.EmailFeedbackResult, error)) {
+	if err := h.feedbackService.Authorize(c.Query("token")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxEmailFeedbackBody))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
+	}
+
+	result, err := process(c.Request.Context(), body)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// handleError handles different types of errors appropriately
+func (h *EmailFeedbackHandler) handleError(c *gin.Context, err error) {
+	handler := &Handler{}
+	handler.handleError(c, err)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// exportWriter streams export rows to the response as CSV or as a JSON array.
+// Nothing is sent until the first row or Close, so an error raised before then
+// can still be answered with a regular error response.
+type exportWriter struct {
+	c        *gin.Context
+	format   string
+	filename string
+	columns  []string
+
+	csv     *csv.Writer
+	started bool
+	rows    int
+}
+
+// newExportWriter creates a writer sending filename.<format> with the given CSV header row
+func newExportWriter(c *gin.Context, format, filename string, columns []string) *exportWriter {
+	return &exportWriter{c: c, format: format, filename: filename, columns: columns}
+}
+
+// start sends the headers and the opening of the document
+func (w *exportWriter) start() error {
+	w.started = true
+	w.c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", w.filename, w.format))
+	w.c.Header("Cache-Control", "no-store")
+
+	if w.format == models.ExportFormatJSON {
+		w.c.Header("Content-Type", "application/json; charset=utf-8")
+		w.c.Status(http.StatusOK)
+		_, err := w.c.Writer.WriteString("[")
+		return err
+	}
+
+	w.c.Header("Content-Type", "text/csv; charset=utf-8")
+	w.c.Status(http.StatusOK)
+	w.csv = csv.NewWriter(w.c.Writer)
+	return w.csv.Write(w.columns)
+}
+
+// Write sends one row: value as a JSON array element, or record as a CSV line
+func (w *exportWriter) Write(value interface{}, record []string) error {
+	if !w.started {
+		if err := w.start(); err != nil {
+			return err
+		}
+	}
+	w.rows++
+
+	if w.format == models.ExportFormatJSON {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if w.rows > 1 {
+			data = append([]byte(",\n"), data...)
+		}
+		_, err = w.c.Writer.Write(data)
+		return err
+	}
+	return w.csv.Write(record)
+}
+
+// Close ends the document, sending an empty one when there were no rows
+func (w *exportWriter) Close() error {
+	if !w.started {
+		if err := w.start(); err != nil {
+			return err
+		}
+	}
+
+	if w.format == models.ExportFormatJSON {
+		_, err := w.c.Writer.WriteString("]\n")
+		return err
+	}
+	w.csv.Flush()
+	return w.csv.Error()
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// CreateGuestEditLink mints a link that lets someone without an account change
+// a link's destination once
+func (h *Handler) CreateGuestEditLink(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.CreateGuestEditLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.urlService.CreateGuestEditLink(c.Request.Context(), shortCode, &req, userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetGuestEditLinks lists a link's guest edit links
+func (h *Handler) GetGuestEditLinks(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	links, err := h.urlService.GetGuestEditLinks(c.Request.Context(), shortCode, userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"guest_edit_links": links})
+}
+
+// RevokeGuestEditLink revokes one of a link's unused guest edit links
+func (h *Handler) RevokeGuestEditLink(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid guest edit link ID"})
+		return
+	}
+
+	if err := h.urlService.RevokeGuestEditLink(c.Request.Context(), shortCode, id, userID.(int)); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Guest edit link revoked successfully"})
+}
+
+// GetGuestEdit shows the holder of a guest edit link the link it edits
+func (h *Handler) GetGuestEdit(c *gin.Context) {
+	view, err := h.urlService.GetGuestEdit(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, view)
+}
+
+// GuestEdit changes a link's destination through a guest edit link
+func (h *Handler) GuestEdit(c *gin.Context) {
+	var req models.GuestEditRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	view, err := h.urlService.GuestEdit(c.Request.Context(), c.Param("token"), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, view)
+}
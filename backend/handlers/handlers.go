@@ -1,30 +1,50 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	neturl "net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/hpower2/url-shortener/internal/errors"
 	"github.com/hpower2/url-shortener/internal/models"
 	"github.com/hpower2/url-shortener/internal/services"
-	"github.com/skip2/go-qrcode"
 )
 
+// clickRecordTimeout bounds the background work done for each redirect
+const clickRecordTimeout = 5 * time.Second
+
 type Handler struct {
-	urlService  services.URLService
-	baseURL     string
-	frontendURL string
+	urlService         services.URLService
+	domainService      services.DomainService
+	preferencesService services.PreferencesService
+	qrCodeService      services.QRCodeService
+	confirmations      *Confirmations
+	baseURL            string
+	frontendURL        string
+	comingSoonURL      string
+
+	// Clicks still being recorded after their redirect was served
+	clicks sync.WaitGroup
 }
 
-func NewHandler(urlService services.URLService, baseURL, frontendURL string) *Handler {
+func NewHandler(urlService services.URLService, domainService services.DomainService, preferencesService services.PreferencesService, qrCodeService services.QRCodeService, confirmations *Confirmations, baseURL, frontendURL, comingSoonURL string) *Handler {
 	return &Handler{
-		urlService:  urlService,
-		baseURL:     baseURL,
-		frontendURL: frontendURL,
+		urlService:         urlService,
+		domainService:      domainService,
+		preferencesService: preferencesService,
+		qrCodeService:      qrCodeService,
+		confirmations:      confirmations,
+		baseURL:            baseURL,
+		frontendURL:        frontendURL,
+		comingSoonURL:      comingSoonURL,
 	}
 }
 
@@ -43,127 +63,714 @@ func (h *Handler) CreateURL(c *gin.Context) {
 		return
 	}
 
-	// Get client info
-	clientIP := c.ClientIP()
-	userAgent := c.GetHeader("User-Agent")
-
-	// Create URL using service
-	response, err := h.urlService.CreateURL(c.Request.Context(), &req, userID.(int), clientIP, userAgent)
+	// Get client info
+	clientIP := c.ClientIP()
+	userAgent := c.GetHeader("User-Agent")
+
+	// Create URL using service
+	response, err := h.urlService.CreateURL(c.Request.Context(), &req, userID.(int), clientIP, userAgent)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// RedirectURL redirects to original URL and records analytics
+func (h *Handler) RedirectURL(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+	clientIP := c.ClientIP()
+	userAgent := c.GetHeader("User-Agent")
+	referer := c.GetHeader("Referer")
+	channel := models.ClickChannelFromSource(c.Query(models.ClickSourceParam))
+
+	// Honeytokens look like any unknown code to their visitors
+	if h.urlService.TripHoneytoken(c.Request.Context(), shortCode, clientIP, userAgent, referer) {
+		h.ErrorPageHandler(c, errors.NewNotFoundError("URL not found", nil))
+		return
+	}
+
+	// Get URL (cache first)
+	url, err := h.urlService.GetURLForRedirect(c.Request.Context(), shortCode)
+	if err != nil {
+		h.ErrorPageHandler(c, err)
+		return
+	}
+
+	// Enforce referrer rules
+	if err := h.urlService.CheckReferrer(c.Request.Context(), url, clientIP, userAgent, referer); err != nil {
+		if url.ReferrerFallbackURL != "" {
+			c.Redirect(http.StatusFound, url.ReferrerFallbackURL)
+			return
+		}
+		h.ErrorPageHandler(c, err)
+		return
+	}
+
+	// Password-protected links are unlocked through the frontend prompt, unless
+	// the visitor carries one of the link's share tokens
+	if url.IsPasswordProtected() && !h.urlService.OpenWithShareToken(c.Request.Context(), url, c.Query("share")) {
+		unlockURL := fmt.Sprintf("%s/unlock?code=%s", h.frontendURL, shortCode)
+		if channel == models.ClickChannelScan {
+			unlockURL = models.QRScanURL(unlockURL)
+		}
+		c.Redirect(http.StatusFound, unlockURL)
+		return
+	}
+
+	// Suspicious links warn the visitor first, and only redirect once they choose to continue
+	if c.Query(proceedParam) != "1" && h.urlService.ShouldWarn(c.Request.Context(), url) {
+		h.serveInterstitial(c, url)
+		return
+	}
+
+	// Link previews and prefetches aren't visits, so they don't count toward
+	// click limits or frequency caps. They never learn the destination.
+	preview := isLinkPreview(c.Request)
+
+	// Protect the destination from traffic spikes
+	if !preview {
+		if err := h.urlService.CheckClickRate(c.Request.Context(), url, clientIP, userAgent, referer); err != nil {
+			h.ErrorPageHandler(c, err)
+			return
+		}
+	}
+
+	// Visitors past the link's daily frequency cap go to its alternate URL
+	if !preview {
+		if alternateURL, capped := h.urlService.ResolveFrequencyCap(c.Request.Context(), url, clientIP, userAgent, referer); capped {
+			c.Redirect(http.StatusFound, alternateURL)
+			return
+		}
+	}
+
+	// Deployment-specific hooks (SSO gates, interstitials, ...)
+	redirectTo, err := h.urlService.RunBeforeRedirectHooks(c.Request.Context(), &services.RedirectVisit{
+		URL:       url,
+		ClientIP:  clientIP,
+		UserAgent: userAgent,
+		Referer:   referer,
+		Request:   c.Request,
+	})
+	if err != nil {
+		h.ErrorPageHandler(c, err)
+		return
+	}
+	if redirectTo != "" {
+		c.Redirect(http.StatusFound, redirectTo)
+		return
+	}
+
+	// Previews get the link's metadata, without the destination or a click
+	if preview {
+		h.servePreview(c, url)
+		return
+	}
+
+	// Click-limited links stop once their redirects are used up
+	if err := h.urlService.ClaimRedirect(c.Request.Context(), url); err != nil {
+		h.ErrorPageHandler(c, err)
+		return
+	}
+
+	h.recordClickAsync(c.Request.Context(), shortCode, clientIP, userAgent, referer, channel)
+
+	destination := h.urlService.RotateDestination(c.Request.Context(), url, clientIP, userAgent)
+	if url.HasDeviceRules() {
+		// Shared caches must not hand one device's destination to another
+		c.Header("Vary", "User-Agent")
+	}
+	if url.RedirectType == models.RedirectTypeMeta {
+		h.serveMetaRedirect(c, destination)
+		return
+	}
+
+	// Links with access rules must be re-evaluated on every visit, so browsers must not cache them
+	mustRevalidate := !url.Cacheable() || h.urlService.HasRedirectHooks()
+	c.Redirect(url.RedirectStatus(mustRevalidate), destination)
+}
+
+// UnlockURL checks the password of a protected link and returns its destination
+func (h *Handler) UnlockURL(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	var req models.UnlockURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	clientIP := c.ClientIP()
+	userAgent := c.GetHeader("User-Agent")
+
+	referer := c.GetHeader("Referer")
+
+	url, err := h.urlService.UnlockURL(c.Request.Context(), shortCode, &req, clientIP, userAgent)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	// The password only replaces the prompt; the link's other rules still
+	// apply before the destination is revealed, as they do on redirect
+	if err := h.urlService.CheckReferrer(c.Request.Context(), url, clientIP, userAgent, referer); err != nil {
+		if url.ReferrerFallbackURL != "" {
+			c.JSON(http.StatusOK, models.UnlockURLResponse{ShortCode: url.ShortCode, OriginalURL: url.ReferrerFallbackURL})
+			return
+		}
+		h.handleError(c, err)
+		return
+	}
+	if err := h.urlService.CheckClickRate(c.Request.Context(), url, clientIP, userAgent, referer); err != nil {
+		h.handleError(c, err)
+		return
+	}
+	if alternateURL, capped := h.urlService.ResolveFrequencyCap(c.Request.Context(), url, clientIP, userAgent, referer); capped {
+		c.JSON(http.StatusOK, models.UnlockURLResponse{ShortCode: url.ShortCode, OriginalURL: alternateURL})
+		return
+	}
+	redirectTo, err := h.urlService.RunBeforeRedirectHooks(c.Request.Context(), &services.RedirectVisit{
+		URL:       url,
+		ClientIP:  clientIP,
+		UserAgent: userAgent,
+		Referer:   referer,
+		Request:   c.Request,
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	if redirectTo != "" {
+		c.JSON(http.StatusOK, models.UnlockURLResponse{ShortCode: url.ShortCode, OriginalURL: redirectTo})
+		return
+	}
+
+	if err := h.urlService.ClaimRedirect(c.Request.Context(), url); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	// The unlock page passes on the QR marker of the link it was opened from
+	channel := models.ClickChannelFromSource(c.Query(models.ClickSourceParam))
+	h.recordClickAsync(c.Request.Context(), shortCode, clientIP, userAgent, referer, channel)
+
+	c.JSON(http.StatusOK, models.UnlockURLResponse{
+		ShortCode:   url.ShortCode,
+		OriginalURL: h.urlService.RotateDestination(c.Request.Context(), url, clientIP, userAgent),
+	})
+}
+
+// recordClickAsync records a click with analytics off the request path.
+// The click outlives the request but keeps its values, such as the link's data region.
+func (h *Handler) recordClickAsync(reqCtx context.Context, shortCode, clientIP, userAgent, referer, channel string) {
+	h.clicks.Add(1)
+	go func() {
+		defer h.clicks.Done()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(reqCtx), clickRecordTimeout)
+		defer cancel()
+
+		if err := h.urlService.RecordClick(ctx, shortCode, clientIP, userAgent, referer, channel); err != nil {
+			// Log error but don't fail redirect
+			log.Printf("Failed to record click for %s: %v", shortCode, err)
+		}
+	}()
+}
+
+// WaitForClicks waits until the clicks of served redirects are recorded or ctx
+// is done, so call it once requests have drained
+func (h *Handler) WaitForClicks(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		h.clicks.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("Timed out recording clicks: %v", ctx.Err())
+	}
+}
+
+// KillURL disables a link on every instance within seconds and reports how far that propagated
+func (h *Handler) KillURL(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	result, err := h.urlService.KillURL(c.Request.Context(), c.Param("shortCode"), userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetURLStats returns detailed URL statistics
+func (h *Handler) GetURLStats(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	stats, err := h.urlService.GetURLStats(c.Request.Context(), shortCode, userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetAllURLs returns paginated list of URLs
+func (h *Handler) GetAllURLs(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Parse pagination parameters
+	page, ok := parsePageRequest(c, 10)
+	if !ok {
+		return
+	}
+
+	order := strings.ToLower(c.DefaultQuery("order", "desc"))
+	if order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order parameter"})
+		return
+	}
+
+	opts := &models.URLListOptions{
+		Limit:     page.Limit,
+		Offset:    page.Offset,
+		SortBy:    c.Query("sort"),
+		Ascending: order == "asc",
+		Status:    c.Query("status"),
+	}
+
+	if opts.ClickedSince, ok = timeQuery(c, "clicked_since"); !ok {
+		return
+	}
+	if opts.CreatedAfter, ok = timeQuery(c, "created_after"); !ok {
+		return
+	}
+	if opts.CreatedBefore, ok = timeQuery(c, "created_before"); !ok {
+		return
+	}
+
+	// A cursor, even an empty one for the first page, pages by cursor instead of offset
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		h.getURLPage(c, userID.(int), opts, cursor)
+		return
+	}
+
+	urls, total, err := h.urlService.GetAllURLs(c.Request.Context(), userID.(int), opts)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"urls":       urls,
+		"total":      total,
+		"limit":      opts.Limit,
+		"offset":     opts.Offset,
+		"pagination": paginate(c, page, total),
+	})
+}
+
+// timeQuery parses an optional RFC 3339 time query parameter, responding
+// with 400 and returning false when it is malformed
+func timeQuery(c *gin.Context, param string) (*time.Time, bool) {
+	value := c.Query(param)
+	if value == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s parameter", param)})
+		return nil, false
+	}
+	return &t, true
+}
+
+// getURLPage responds with the page of the user's URLs following cursor
+func (h *Handler) getURLPage(c *gin.Context, userID int, opts *models.URLListOptions, cursor string) {
+	if _, ok := c.GetQuery("page"); ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cursor can't be combined with page or offset"})
+		return
+	}
+	if _, ok := c.GetQuery("offset"); ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cursor can't be combined with page or offset"})
+		return
+	}
+
+	opts.Cursor = cursor
+	page, err := h.urlService.GetURLPage(c.Request.Context(), userID, opts)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	if page.HasMore {
+		setNextCursorLink(c, page.NextCursor)
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// SearchURLs returns the user's links matching the q query parameter
+func (h *Handler) SearchURLs(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	page, ok := parsePageRequest(c, 10)
+	if !ok {
+		return
+	}
+
+	opts := &models.URLSearchOptions{
+		Query:  c.Query("q"),
+		Limit:  page.Limit,
+		Offset: page.Offset,
+	}
+
+	urls, total, err := h.urlService.SearchURLs(c.Request.Context(), userID.(int), opts)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"urls":       urls,
+		"query":      opts.Query,
+		"total":      total,
+		"limit":      opts.Limit,
+		"offset":     opts.Offset,
+		"pagination": paginate(c, page, total),
+	})
+}
+
+// GetCampaignStats returns the user's link and click counts by UTM campaign
+func (h *Handler) GetCampaignStats(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	campaigns, err := h.urlService.GetCampaignStats(c.Request.Context(), userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"campaigns": campaigns})
+}
+
+// GetDashboard returns account-wide link and click aggregates
+func (h *Handler) GetDashboard(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	dashboard, err := h.urlService.GetDashboard(c.Request.Context(), userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dashboard)
+}
+
+// GetRecentActivity returns the links that have been clicked recently
+func (h *Handler) GetRecentActivity(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	within, err := time.ParseDuration(c.DefaultQuery("within", "24h"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid within parameter"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+		return
+	}
+
+	urls, err := h.urlService.GetRecentActivity(c.Request.Context(), userID.(int), within, limit)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"urls":   urls,
+		"within": within.String(),
+	})
+}
+
+// UpdateURL updates an existing URL
+func (h *Handler) UpdateURL(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.UpdateURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	url, err := h.urlService.UpdateURL(c.Request.Context(), shortCode, &req, userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, url)
+}
+
+// ExtendExpiration pushes a URL's expiration date forward
+func (h *Handler) ExtendExpiration(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.ExtendExpirationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	url, extension, err := h.urlService.ExtendExpiration(c.Request.Context(), shortCode, &req, userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"url":       url,
+		"extension": extension,
+	})
+}
+
+// RefreshMetadata fetches the title, description and favicon of a URL's destination now
+func (h *Handler) RefreshMetadata(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	metadata, err := h.urlService.RefreshMetadata(c.Request.Context(), shortCode, userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, metadata)
+}
+
+// GetExpirationExtensions returns the expiration extension history of a URL
+func (h *Handler) GetExpirationExtensions(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	extensions, err := h.urlService.GetExpirationExtensions(c.Request.Context(), shortCode, userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"extensions": extensions})
+}
+
+// GetDestinationChanges returns the destination change history of a URL
+func (h *Handler) GetDestinationChanges(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	changes, err := h.urlService.GetDestinationChanges(c.Request.Context(), shortCode, userID.(int))
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusCreated, response)
+	c.JSON(http.StatusOK, gin.H{"destination_changes": changes})
 }
 
-// RedirectURL redirects to original URL and records analytics
-func (h *Handler) RedirectURL(c *gin.Context) {
+// GetURLEvents returns a page of a URL's lifecycle events, newest first.
+// Older pages are requested with before set to the last event ID seen.
+func (h *Handler) GetURLEvents(c *gin.Context) {
 	shortCode := c.Param("shortCode")
 
-	// Get URL
-	url, err := h.urlService.GetURL(c.Request.Context(), shortCode)
-	if err != nil {
-		h.ErrorPageHandler(c, err)
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// Record click with analytics
-	clientIP := c.ClientIP()
-	userAgent := c.GetHeader("User-Agent")
-	referer := c.GetHeader("Referer")
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(models.DefaultPerPage)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+		return
+	}
+	before, err := strconv.ParseInt(c.DefaultQuery("before", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid before parameter"})
+		return
+	}
 
-	if err := h.urlService.RecordClick(c.Request.Context(), shortCode, clientIP, userAgent, referer); err != nil {
-		// Log error but don't fail redirect
-		// TODO: Add proper logging
+	events, err := h.urlService.GetURLEvents(c.Request.Context(), shortCode, userID.(int), &models.URLEventListOptions{
+		Limit:    limit,
+		BeforeID: before,
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
 	}
 
-	c.Redirect(http.StatusMovedPermanently, url.OriginalURL)
+	c.JSON(http.StatusOK, gin.H{"events": events})
 }
 
-// GetURLStats returns detailed URL statistics
-func (h *Handler) GetURLStats(c *gin.Context) {
+// RotateLinkPassword replaces the password of a protected link
+func (h *Handler) RotateLinkPassword(c *gin.Context) {
 	shortCode := c.Param("shortCode")
 
-	// Get user ID from context
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	stats, err := h.urlService.GetURLStats(c.Request.Context(), shortCode, userID.(int))
+	// The body is optional: without one a password is generated
+	var req models.RotateLinkPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.urlService.RotatePassword(c.Request.Context(), shortCode, &req, userID.(int))
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, stats)
+	c.JSON(http.StatusOK, response)
 }
 
-// GetAllURLs returns paginated list of URLs
-func (h *Handler) GetAllURLs(c *gin.Context) {
-	// Get user ID from context
+// CreateShareToken mints a share token that opens a protected link without its password
+func (h *Handler) CreateShareToken(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	// Parse pagination parameters
-	limitStr := c.DefaultQuery("limit", "10")
-	offsetStr := c.DefaultQuery("offset", "0")
+	var req models.CreateShareTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	limit, err := strconv.Atoi(limitStr)
+	response, err := h.urlService.CreateShareToken(c.Request.Context(), shortCode, &req, userID.(int))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+		h.handleError(c, err)
 		return
 	}
 
-	offset, err := strconv.Atoi(offsetStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset parameter"})
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetShareTokens lists a link's share tokens with their access counts
+func (h *Handler) GetShareTokens(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	urls, total, err := h.urlService.GetAllURLs(c.Request.Context(), userID.(int), limit, offset)
+	tokens, err := h.urlService.GetShareTokens(c.Request.Context(), shortCode, userID.(int))
 	if err != nil {
 		h.handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"urls":   urls,
-		"total":  total,
-		"limit":  limit,
-		"offset": offset,
-	})
+	c.JSON(http.StatusOK, gin.H{"share_tokens": tokens})
 }
 
-// UpdateURL updates an existing URL
-func (h *Handler) UpdateURL(c *gin.Context) {
+// RevokeShareToken revokes one of a link's share tokens
+func (h *Handler) RevokeShareToken(c *gin.Context) {
 	shortCode := c.Param("shortCode")
 
-	// Get user ID from context
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
-	var req models.UpdateURLRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid share token ID"})
 		return
 	}
 
-	url, err := h.urlService.UpdateURL(c.Request.Context(), shortCode, &req, userID.(int))
-	if err != nil {
+	if err := h.urlService.RevokeShareToken(c.Request.Context(), shortCode, id, userID.(int)); err != nil {
 		h.handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, url)
+	c.JSON(http.StatusOK, gin.H{"message": "Share token revoked successfully"})
 }
 
 // DeleteURL deletes a URL
@@ -185,6 +792,69 @@ func (h *Handler) DeleteURL(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "URL deleted successfully"})
 }
 
+// PreviewDeleteLabeledLinks describes which links deleting by a label value
+// would delete, with the token confirming it
+func (h *Handler) PreviewDeleteLabeledLinks(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.DeleteLabeledLinksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	impact, err := h.urlService.PreviewDeleteLabeledLinks(c.Request.Context(), userID.(int), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	// The deletion is only confirmed for as many links as previewed
+	h.confirmations.PreviewWithState(c, userID.(int), models.ConfirmActionDeleteLabeledLinks, req.Subject(), strconv.Itoa(impact.Links), impact)
+}
+
+// DeleteLabeledLinks deletes all of the user's links carrying a label value,
+// once confirmed with the token of its preview
+func (h *Handler) DeleteLabeledLinks(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.DeleteLabeledLinksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	state, confirmed := h.confirmations.ConfirmWithState(c, userID.(int), models.ConfirmActionDeleteLabeledLinks, req.Subject())
+	if !confirmed {
+		return
+	}
+	expected, err := strconv.Atoi(state)
+	if err != nil {
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Confirmation token is invalid or has expired, preview the action again"})
+		return
+	}
+
+	deleted, err := h.urlService.DeleteLabeledLinks(c.Request.Context(), userID.(int), &req, expected)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Links deleted successfully", "deleted": deleted})
+}
+
 // GetAnalytics returns detailed analytics for a URL
 func (h *Handler) GetAnalytics(c *gin.Context) {
 	shortCode := c.Param("shortCode")
@@ -204,7 +874,7 @@ func (h *Handler) GetAnalytics(c *gin.Context) {
 		return
 	}
 
-	analytics, err := h.urlService.GetAnalytics(c.Request.Context(), shortCode, userID.(int), days)
+	analytics, err := h.urlService.GetAnalytics(c.Request.Context(), shortCode, userID.(int), days, c.Query("tz"))
 	if err != nil {
 		h.handleError(c, err)
 		return
@@ -213,6 +883,145 @@ func (h *Handler) GetAnalytics(c *gin.Context) {
 	c.JSON(http.StatusOK, analytics)
 }
 
+// GetClickTimeSeries returns a link's clicks bucketed by hour or day
+func (h *Handler) GetClickTimeSeries(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid days parameter"})
+		return
+	}
+
+	opts := &models.ClickTimeSeriesOptions{Interval: c.Query("interval"), Days: days, Timezone: c.Query("tz")}
+	series, err := h.urlService.GetClickTimeSeries(c.Request.Context(), c.Param("shortCode"), userID.(int), opts)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+// StreamClicks returns a link's click events after an opaque cursor, for
+// integrations syncing them incrementally
+func (h *Handler) StreamClicks(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+		return
+	}
+
+	opts := &models.ClickStreamOptions{Cursor: c.Query("cursor"), Limit: limit}
+	page, err := h.urlService.StreamClicks(c.Request.Context(), c.Param("shortCode"), userID.(int), opts)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	if page.HasMore {
+		setNextCursorLink(c, page.NextCursor)
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// GetClicks returns a page of a link's click events, newest first, resuming
+// from the cursor query parameter
+func (h *Handler) GetClicks(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(models.DefaultPerPage)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit parameter"})
+		return
+	}
+
+	opts := &models.ClickListOptions{Cursor: c.Query("cursor"), Limit: limit}
+	page, err := h.urlService.GetClicks(c.Request.Context(), c.Param("shortCode"), userID.(int), opts)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	if page.HasMore {
+		setNextCursorLink(c, page.NextCursor)
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// ExportURLs streams all of the user's links as CSV or JSON
+func (h *Handler) ExportURLs(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	format, err := models.ParseExportFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	w := newExportWriter(c, format, "urls", models.URLExportColumns)
+	err = h.urlService.ExportURLs(c.Request.Context(), userID.(int), func(url *models.URL) error {
+		return w.Write(url, url.ExportRecord())
+	})
+	h.finishExport(c, w, err)
+}
+
+// ExportClicks streams a link's click events as CSV or JSON
+func (h *Handler) ExportClicks(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	format, err := models.ParseExportFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	shortCode := c.Param("shortCode")
+	w := newExportWriter(c, format, shortCode+"-clicks", models.ClickEventExportColumns)
+	err = h.urlService.ExportClicks(c.Request.Context(), shortCode, userID.(int), func(event *models.ClickEvent) error {
+		return w.Write(event, event.ExportRecord())
+	})
+	h.finishExport(c, w, err)
+}
+
+// finishExport completes a streamed export. Once rows have been sent the
+// status can no longer change, so a later failure cuts the download short.
+func (h *Handler) finishExport(c *gin.Context, w *exportWriter, err error) {
+	if err != nil && !w.started {
+		h.handleError(c, err)
+		return
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		log.Printf("Export of %s failed after %d rows: %v", w.filename, w.rows, err)
+		c.Abort()
+	}
+}
+
 // GenerateQRCode generates QR code for a URL
 func (h *Handler) GenerateQRCode(c *gin.Context) {
 	shortCode := c.Param("shortCode")
@@ -238,22 +1047,62 @@ func (h *Handler) GenerateQRCode(c *gin.Context) {
 	}
 
 	// Generate QR code for the short URL (not original URL)
-	shortURL := fmt.Sprintf("%s/%s", h.baseURL, shortCode)
+	shortURL := url.ShortURL(h.baseURL)
 
-	// Generate QR code using the library
-	qrCode, err := qrcode.Encode(shortURL, qrcode.Medium, 256)
+	// Draw it in the owner's preferred style, with any overrides from the query
+	preferences, err := h.preferencesService.GetPreferences(c.Request.Context(), userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+	overrides, ok := qrStyleQuery(c)
+	if !ok {
+		return
+	}
+	format := strings.ToLower(c.DefaultQuery("format", models.QRFormatPNG))
+	qrCode, err := h.qrCodeService.Render(c.Request.Context(), models.QRScanURL(shortURL), preferences.QRStyle.Override(overrides), format)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate QR code"})
+		h.handleError(c, err)
 		return
 	}
 
-	// Set appropriate headers for PNG image
-	c.Header("Content-Type", "image/png")
+	contentType := "image/png"
+	if format == models.QRFormatSVG {
+		contentType = "image/svg+xml"
+	}
 	c.Header("Cache-Control", "public, max-age=3600") // Cache for 1 hour
-	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s-qr.png\"", shortCode))
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s-qr.%s\"", shortCode, format))
 
 	// Return the QR code image directly
-	c.Data(http.StatusOK, "image/png", qrCode)
+	c.Data(http.StatusOK, contentType, qrCode)
+}
+
+// qrStyleQuery reads QR code style overrides from the query, responding with
+// 400 and returning false when a number is malformed
+func qrStyleQuery(c *gin.Context) (models.QRStyle, bool) {
+	style := models.QRStyle{
+		ErrorCorrection: c.Query("error_correction"),
+		Foreground:      c.Query("foreground"),
+		Background:      c.Query("background"),
+		Logo:            c.Query("logo"),
+	}
+	if size := c.Query("size"); size != "" {
+		value, err := strconv.Atoi(size)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid size parameter"})
+			return style, false
+		}
+		style.Size = value
+	}
+	if margin := c.Query("margin"); margin != "" {
+		value, err := strconv.Atoi(margin)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid margin parameter"})
+			return style, false
+		}
+		style.Margin = &value
+	}
+	return style, true
 }
 
 // HealthCheck returns service health status
@@ -267,6 +1116,8 @@ func (h *Handler) HealthCheck(c *gin.Context) {
 
 // handleError handles different types of errors appropriately
 func (h *Handler) handleError(c *gin.Context, err error) {
+	recordInternalError(c, err)
+
 	if appErr := errors.GetAppError(err); appErr != nil {
 		c.JSON(appErr.StatusCode, appErr.ToErrorResponse())
 		return
@@ -276,6 +1127,15 @@ func (h *Handler) handleError(c *gin.Context, err error) {
 	c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 }
 
+// recordInternalError attaches server-side failures to the request so they are
+// logged and reported to the error tracker; client errors are left out
+func recordInternalError(c *gin.Context, err error) {
+	if appErr := errors.GetAppError(err); appErr != nil && appErr.StatusCode < http.StatusInternalServerError {
+		return
+	}
+	c.Error(err)
+}
+
 // ErrorPageHandler handles errors for short URL redirects by redirecting to frontend
 func (h *Handler) ErrorPageHandler(c *gin.Context, err error) {
 	// Check if this is a short URL redirect request (not API)
@@ -291,6 +1151,12 @@ func (h *Handler) ErrorPageHandler(c *gin.Context, err error) {
 	// Extract short code from path
 	shortCode := strings.TrimPrefix(path, "/")
 	
+	// Errors on a verified custom domain use that domain's branded pages
+	if h.serveBrandedErrorPage(c, err) {
+		recordInternalError(c, err)
+		return
+	}
+
 	// For short URL requests, redirect to frontend error pages
 	if appErr := errors.GetAppError(err); appErr != nil {
 		switch appErr.Code {
@@ -300,15 +1166,86 @@ func (h *Handler) ErrorPageHandler(c *gin.Context, err error) {
 		case errors.ErrCodeExpired:
 			redirectURL := fmt.Sprintf("%s/error/expired?code=%s", h.frontendURL, shortCode)
 			c.Redirect(http.StatusFound, redirectURL)
-		case errors.ErrCodeNotFound:
+		case errors.ErrCodeNotYetActive:
+			c.Redirect(http.StatusFound, h.comingSoonRedirect(shortCode, appErr.Details))
+		case errors.ErrCodeNotFound, errors.ErrCodeReferrerBlocked, errors.ErrCodeForbidden:
 			redirectURL := fmt.Sprintf("%s/error/not-found?code=%s", h.frontendURL, shortCode)
 			c.Redirect(http.StatusFound, redirectURL)
+		case errors.ErrCodeLinkThrottled:
+			c.Header("Retry-After", "60")
+			redirectURL := fmt.Sprintf("%s/error/try-again?code=%s", h.frontendURL, shortCode)
+			c.Redirect(http.StatusFound, redirectURL)
 		default:
+			recordInternalError(c, err)
 			redirectURL := fmt.Sprintf("%s/error/server-error?code=%s", h.frontendURL, shortCode)
 			c.Redirect(http.StatusFound, redirectURL)
 		}
 	} else {
+		recordInternalError(c, err)
 		redirectURL := fmt.Sprintf("%s/error/server-error?code=%s", h.frontendURL, shortCode)
 		c.Redirect(http.StatusFound, redirectURL)
 	}
 }
+
+// comingSoonRedirect returns where visitors of a link that isn't active yet are
+// sent: COMING_SOON_URL if set, or the frontend's coming soon page, with the
+// link's code and activation time
+func (h *Handler) comingSoonRedirect(shortCode, activatesAt string) string {
+	target := h.frontendURL + "/error/coming-soon"
+	if h.comingSoonURL != "" {
+		target = h.comingSoonURL
+	}
+	parsed, err := neturl.Parse(target)
+	if err != nil {
+		return target
+	}
+	query := parsed.Query()
+	query.Set("code", shortCode)
+	if activatesAt != "" {
+		query.Set("activates_at", activatesAt)
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// serveBrandedErrorPage renders the error page configured for the request's custom
+// domain. It returns false when the host has no branded page for this error.
+func (h *Handler) serveBrandedErrorPage(c *gin.Context, err error) bool {
+	if h.domainService == nil {
+		return false
+	}
+
+	domain, lookupErr := h.domainService.GetVerifiedDomain(c.Request.Context(), c.Request.Host)
+	if lookupErr != nil {
+		return false
+	}
+
+	status := http.StatusInternalServerError
+	redirectURL := ""
+	if appErr := errors.GetAppError(err); appErr != nil {
+		switch appErr.Code {
+		case errors.ErrCodeInactive, errors.ErrCodeExpired:
+			status = http.StatusGone
+			redirectURL = domain.ExpiredURL
+		case errors.ErrCodeNotFound, errors.ErrCodeReferrerBlocked, errors.ErrCodeForbidden:
+			status = http.StatusNotFound
+			redirectURL = domain.NotFoundURL
+		case errors.ErrCodeNotYetActive:
+			// The coming soon page tells visitors when to come back
+			return false
+		case errors.ErrCodeLinkThrottled:
+			c.Header("Retry-After", "60")
+			status = http.StatusTooManyRequests
+		}
+	}
+
+	if redirectURL != "" {
+		c.Redirect(http.StatusFound, redirectURL)
+		return true
+	}
+	if domain.ErrorHTML != "" {
+		c.Data(status, "text/html; charset=utf-8", []byte(domain.ErrorHTML))
+		return true
+	}
+	return false
+}
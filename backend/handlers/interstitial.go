@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// proceedParam is the query parameter a visitor follows past the warning page
+const proceedParam = "proceed"
+
+// interstitialTemplate is the warning page shown in front of suspicious links
+var interstitialTemplate = template.Must(template.New("interstitial").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Warning: suspicious link</title>
+</head>
+<body>
+<h1>This link may be unsafe</h1>
+<p>The short link <strong>{{.ShortCode}}</strong> leads to <strong>{{.Host}}</strong>, which has been flagged as suspicious{{if .ThreatType}} ({{.ThreatType}}){{end}}.</p>
+<p>It may try to trick you into revealing personal information or installing unwanted software.</p>
+<p><a href="{{.ContinueURL}}" rel="nofollow noreferrer">Continue anyway</a></p>
+</body>
+</html>
+`))
+
+// interstitialPage holds the values rendered into the warning page
+type interstitialPage struct {
+	ShortCode   string
+	Host        string
+	ThreatType  string
+	ContinueURL string
+}
+
+// serveInterstitial answers a visit to a suspicious link with a warning page
+// instead of redirecting. Continuing re-requests the link with proceedParam set,
+// keeping the rest of the query (such as a share token).
+func (h *Handler) serveInterstitial(c *gin.Context, url *models.URL) {
+	host := url.OriginalURL
+	if parsed, err := neturl.Parse(url.OriginalURL); err == nil && parsed.Host != "" {
+		host = parsed.Host
+	}
+
+	continueURL := *c.Request.URL
+	query := continueURL.Query()
+	query.Set(proceedParam, "1")
+	continueURL.RawQuery = query.Encode()
+
+	var page bytes.Buffer
+	err := interstitialTemplate.Execute(&page, interstitialPage{
+		ShortCode:   url.ShortCode,
+		Host:        host,
+		ThreatType:  strings.ToLower(strings.ReplaceAll(url.ThreatType, "_", " ")),
+		ContinueURL: continueURL.RequestURI(),
+	})
+	if err != nil {
+		log.Printf("Failed to render warning page for %s: %v", url.ShortCode, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Robots-Tag", "noindex")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// previewUserAgents are (lowercased) user agent fragments of link-preview
+// fetchers used by chat apps and social networks to unfurl pasted links
+var previewUserAgents = []string{
+	"slackbot",
+	"slack-imgproxy",
+	"whatsapp",
+	"twitterbot",
+	"facebookexternalhit",
+	"facebookcatalog",
+	"linkedinbot",
+	"discordbot",
+	"telegrambot",
+	"skypeuripreview",
+	"microsoftpreview",
+	"pinterestbot",
+	"redditbot",
+	"iframely",
+	"embedly",
+	"vkshare",
+	"viber",
+	"bitlybot",
+}
+
+// isLinkPreview reports whether a request comes from a link-preview bot or is a
+// browser prefetch (e.g. Safari's) rather than a person following the link
+func isLinkPreview(r *http.Request) bool {
+	for _, header := range []string{"Purpose", "Sec-Purpose", "X-Purpose", "X-Moz"} {
+		value := strings.ToLower(r.Header.Get(header))
+		if strings.Contains(value, "prefetch") || strings.Contains(value, "preview") {
+			return true
+		}
+	}
+
+	userAgent := strings.ToLower(r.UserAgent())
+	for _, fragment := range previewUserAgents {
+		if strings.Contains(userAgent, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// servePreview answers a link preview with Open Graph tags describing the link
+// instead of redirecting, so the preview is not counted as a click. Previews
+// skip click limits, rate limits and frequency caps, and anyone can send the
+// headers that trigger them, so the page never reveals the destination: it
+// carries the title and description fetched from it and the short URL. The
+// page isn't stored, so a prefetched copy is never reused for the real visit.
+func (h *Handler) servePreview(c *gin.Context, url *models.URL) {
+	shortURL := html.EscapeString(url.ShortURL(h.baseURL))
+	title := url.PageTitle
+	if title == "" {
+		title = url.ShortCode
+	}
+	title = html.EscapeString(title)
+	description := html.EscapeString(url.PageDescription)
+
+	page := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%[1]s</title>
+<meta property="og:type" content="website">
+<meta property="og:title" content="%[1]s">
+<meta property="og:url" content="%[2]s">
+<meta property="og:description" content="%[3]s">
+<meta name="twitter:card" content="summary">
+</head>
+<body></body>
+</html>
+`, title, shortURL, description)
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Robots-Tag", "noindex")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+}
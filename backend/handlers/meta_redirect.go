@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metaRedirectTemplate redirects with a meta refresh, a script and a link as a
+// last resort. Unlike an HTTP redirect, the destination's analytics see the
+// short link as the referrer, and clients that don't render pages stay put.
+var metaRedirectTemplate = template.Must(template.New("meta-redirect").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex">
+<meta http-equiv="refresh" content="0; url={{.}}">
+<title>Redirecting…</title>
+<script>window.location.replace({{.}});</script>
+</head>
+<body><p>Redirecting to <a href="{{.}}">{{.}}</a>…</p></body>
+</html>
+`))
+
+// serveMetaRedirect answers a visit with a page that sends the browser on to destination
+func (h *Handler) serveMetaRedirect(c *gin.Context, destination string) {
+	var page bytes.Buffer
+	if err := metaRedirectTemplate.Execute(&page, destination); err != nil {
+		log.Printf("Failed to render redirect page: %v", err)
+		c.Redirect(http.StatusFound, destination)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Robots-Tag", "noindex")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
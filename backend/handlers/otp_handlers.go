@@ -53,9 +53,10 @@ func (h *OTPHandler) GenerateOTP(c *gin.Context) {
 	}
 
 	// Send OTP email via queue
-	if err := h.emailQueueConsumer.PublishOTPEmail(req.Email, "", req.Purpose); err != nil {
+	if err := h.emailQueueConsumer.PublishOTPEmail(c.Request.Context(), otpResponse.DeliveryID, req.Email, req.Purpose); err != nil {
 		// Log error but don't fail the request
 		// The OTP is already generated and stored
+		recordInternalError(c, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send OTP email"})
 		return
 	}
@@ -95,6 +96,8 @@ func (h *OTPHandler) VerifyOTP(c *gin.Context) {
 
 // handleError handles different types of errors
 func (h *OTPHandler) handleError(c *gin.Context, err error) {
+	recordInternalError(c, err)
+
 	if appErr, ok := err.(*errors.AppError); ok {
 		c.JSON(appErr.StatusCode, gin.H{"error": appErr.Message})
 		return
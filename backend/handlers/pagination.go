@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// pageRequest is the window of a list a request asked for, either with
+// page/per_page or with the older limit/offset parameters
+type pageRequest struct {
+	Limit  int
+	Offset int
+
+	// Links keep the style of the request
+	offsetStyle bool
+}
+
+// parsePageRequest reads the requested page, capping its size at
+// models.MaxPerPage. Invalid parameters are answered with 400 and false.
+func parsePageRequest(c *gin.Context, defaultPerPage int) (*pageRequest, bool) {
+	req := &pageRequest{Limit: defaultPerPage}
+
+	perPageParam := "per_page"
+	if _, ok := c.GetQuery("per_page"); !ok {
+		if _, ok := c.GetQuery("limit"); ok {
+			perPageParam = "limit"
+		}
+	}
+	if value, ok := c.GetQuery(perPageParam); ok {
+		perPage, err := strconv.Atoi(value)
+		if err != nil || perPage < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s parameter", perPageParam)})
+			return nil, false
+		}
+		req.Limit = perPage
+	}
+	if req.Limit > models.MaxPerPage {
+		req.Limit = models.MaxPerPage
+	}
+
+	if value, ok := c.GetQuery("page"); ok {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page parameter"})
+			return nil, false
+		}
+		req.Offset = (page - 1) * req.Limit
+	} else if value, ok := c.GetQuery("offset"); ok {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid offset parameter"})
+			return nil, false
+		}
+		req.Offset = offset
+		req.offsetStyle = true
+	}
+
+	return req, true
+}
+
+// paginate describes the page served for req out of total items, and sets the
+// RFC 5988 Link header (first, prev, next, last) and X-Total-Count
+func paginate(c *gin.Context, req *pageRequest, total int) *models.Pagination {
+	pagination := models.NewPagination(req.Limit, req.Offset, total)
+
+	links := []string{fmt.Sprintf(`<%s>; rel="first"`, req.pageURL(c, 0))}
+	if req.Offset > 0 {
+		prev := req.Offset - req.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		pagination.Prev = req.pageURL(c, prev)
+		links = append(links, fmt.Sprintf(`<%s>; rel="prev"`, pagination.Prev))
+	}
+	if req.Offset+req.Limit < total {
+		pagination.Next = req.pageURL(c, req.Offset+req.Limit)
+		links = append(links, fmt.Sprintf(`<%s>; rel="next"`, pagination.Next))
+	}
+	if pagination.TotalPages > 0 {
+		links = append(links, fmt.Sprintf(`<%s>; rel="last"`, req.pageURL(c, (pagination.TotalPages-1)*req.Limit)))
+	}
+
+	c.Header("Link", strings.Join(links, ", "))
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	return pagination
+}
+
+// pageURL returns the request's path and query, moved to the page starting at offset
+func (req *pageRequest) pageURL(c *gin.Context, offset int) string {
+	query := c.Request.URL.Query()
+	if query.Has("limit") {
+		query.Set("limit", strconv.Itoa(req.Limit))
+	} else {
+		query.Set("per_page", strconv.Itoa(req.Limit))
+	}
+	if req.offsetStyle {
+		query.Set("offset", strconv.Itoa(offset))
+	} else {
+		query.Del("offset")
+		query.Set("page", strconv.Itoa(offset/req.Limit+1))
+	}
+	return c.Request.URL.Path + "?" + query.Encode()
+}
+
+// setNextCursorLink sets the RFC 5988 Link header to the request resumed from cursor
+func setNextCursorLink(c *gin.Context, cursor string) {
+	query := c.Request.URL.Query()
+	query.Set("cursor", cursor)
+	c.Header("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, c.Request.URL.Path, query.Encode()))
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/services"
+)
+
+type QRBatchHandler struct {
+	qrBatchService services.QRBatchService
+}
+
+func NewQRBatchHandler(qrBatchService services.QRBatchService) *QRBatchHandler {
+	return &QRBatchHandler{
+		qrBatchService: qrBatchService,
+	}
+}
+
+// CreateQRBatch queues a ZIP of QR codes for many links
+func (h *QRBatchHandler) CreateQRBatch(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.CreateQRBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	batch, err := h.qrBatchService.CreateBatch(c.Request.Context(), userID.(int), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/api/v1/urls/qr-batch/%d", batch.ID))
+	c.JSON(http.StatusAccepted, batch)
+}
+
+// GetQRBatch returns the status of a QR batch
+func (h *QRBatchHandler) GetQRBatch(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid QR batch ID"})
+		return
+	}
+
+	batch, err := h.qrBatchService.GetBatch(c.Request.Context(), id, userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, batch)
+}
+
+// DownloadQRBatch returns the ZIP archive of a completed QR batch
+func (h *QRBatchHandler) DownloadQRBatch(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid QR batch ID"})
+		return
+	}
+
+	archive, err := h.qrBatchService.GetArchive(c.Request.Context(), id, userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"qr-batch-%d.zip\"", id))
+	c.Data(http.StatusOK, "application/zip", archive)
+}
+
+// handleError handles different types of errors appropriately
+func (h *QRBatchHandler) handleError(c *gin.Context, err error) {
+	handler := &Handler{}
+	handler.handleError(c, err)
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/services"
+)
+
+type QRPayloadHandler struct {
+	qrPayloadService services.QRPayloadService
+}
+
+func NewQRPayloadHandler(qrPayloadService services.QRPayloadService) *QRPayloadHandler {
+	return &QRPayloadHandler{
+		qrPayloadService: qrPayloadService,
+	}
+}
+
+// CreateQRPayload creates a contact card, Wi-Fi or calendar event QR code
+func (h *QRPayloadHandler) CreateQRPayload(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.CreateQRPayloadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payload, err := h.qrPayloadService.CreatePayload(c.Request.Context(), userID.(int), &req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, payload)
+}
+
+// GetQRPayloads lists the user's QR payloads
+func (h *QRPayloadHandler) GetQRPayloads(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	payloads, err := h.qrPayloadService.GetPayloads(c.Request.Context(), userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"qr_payloads": payloads})
+}
+
+// GetQRPayload returns a QR payload with its scan count
+func (h *QRPayloadHandler) GetQRPayload(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid QR payload ID"})
+		return
+	}
+
+	payload, err := h.qrPayloadService.GetPayload(c.Request.Context(), id, userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, payload)
+}
+
+// UpdateQRPayload replaces the content of a QR payload
+func (h *QRPayloadHandler) UpdateQRPayload(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid QR payload ID"})
+		return
+	}
+
+	var req models.UpdateQRPayloadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payload, err := h.qrPayloadService.UpdatePayload(c.Request.Context(), id, userID.(int), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, payload)
+}
+
+// DeleteQRPayload deletes a QR payload and its short link
+func (h *QRPayloadHandler) DeleteQRPayload(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid QR payload ID"})
+		return
+	}
+
+	if err := h.qrPayloadService.DeletePayload(c.Request.Context(), id, userID.(int)); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "QR payload deleted successfully"})
+}
+
+// GetQRPayloadCode renders a QR payload's QR code (?mode=landing|direct&size=)
+func (h *QRPayloadHandler) GetQRPayloadCode(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid QR payload ID"})
+		return
+	}
+
+	size, err := strconv.Atoi(c.DefaultQuery("size", strconv.Itoa(services.DefaultQRPayloadSize)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid size parameter"})
+		return
+	}
+	mode := c.DefaultQuery("mode", models.QRModeLanding)
+
+	png, err := h.qrPayloadService.RenderQRCode(c.Request.Context(), id, userID.(int), mode, size)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"qr-payload-%d-%s.png\"", id, mode))
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// ServeQRLanding serves the payload a scanned QR code's short link redirects
+// to: a contact card or calendar file, or a page with Wi-Fi credentials.
+// The scan was already counted by the redirect.
+func (h *QRPayloadHandler) ServeQRLanding(c *gin.Context) {
+	payload, err := h.qrPayloadService.GetLanding(c.Request.Context(), c.Param("token"), c.Query(models.QRLandingGrantParam))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Robots-Tag", "noindex")
+	switch payload.Type {
+	case models.QRPayloadVCard:
+		c.Header("Content-Disposition", `attachment; filename="contact.vcf"`)
+		c.Data(http.StatusOK, "text/vcard; charset=utf-8", []byte(payload.Encode()))
+	case models.QRPayloadEvent:
+		c.Header("Content-Disposition", `attachment; filename="event.ics"`)
+		c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(payload.Encode()))
+	default:
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(wifiLandingPage(payload.Data.WiFi)))
+	}
+}
+
+// wifiLandingPage renders the credentials of a Wi-Fi network. Browsers can't
+// join networks, so the visitor is shown what to enter.
+func wifiLandingPage(network *models.WiFiNetwork) string {
+	password := "None"
+	if network.Password != "" {
+		password = html.EscapeString(network.Password)
+	}
+
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Wi-Fi: %[1]s</title>
+</head>
+<body>
+<h1>Wi-Fi network</h1>
+<p>Network: <strong>%[1]s</strong></p>
+<p>Password: <code>%[2]s</code></p>
+<p>Security: %[3]s</p>
+</body>
+</html>
+`, html.EscapeString(network.SSID), password, html.EscapeString(network.Security))
+}
+
+// handleError handles different types of errors appropriately
+func (h *QRPayloadHandler) handleError(c *gin.Context, err error) {
+	handler := &Handler{}
+	handler.handleError(c, err)
+}
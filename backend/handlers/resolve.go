@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/errors"
+)
+
+// ResolveURL returns a short link's destination, status and safety verdict as
+// JSON instead of redirecting, so chat platforms and mail filters can expand
+// links without counting a click
+func (h *Handler) ResolveURL(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	// Honeytokens resolve like any unknown code, and flag whoever probes them
+	if h.urlService.TripHoneytoken(c.Request.Context(), shortCode, c.ClientIP(), c.GetHeader("User-Agent"), c.GetHeader("Referer")) {
+		h.handleError(c, errors.NewNotFoundError("URL not found", nil))
+		return
+	}
+
+	resolved, err := h.urlService.ResolveURL(c.Request.Context(), shortCode, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	// Verdicts and statuses change, so caches may only keep them briefly.
+	// Destinations picked for this client are for its eyes only.
+	if _, authenticated := c.Get("user_id"); authenticated || resolved.PerVisitor {
+		c.Header("Cache-Control", "private, max-age=60")
+	} else {
+		c.Header("Cache-Control", "public, max-age=60")
+	}
+	c.JSON(http.StatusOK, resolved)
+}
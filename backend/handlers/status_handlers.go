@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/services"
+)
+
+// statusCacheControl lets status page embeds and CDNs reuse a response briefly
+const statusCacheControl = "public, max-age=15"
+
+type StatusHandler struct {
+	statusService services.StatusService
+}
+
+func NewStatusHandler(statusService services.StatusService) *StatusHandler {
+	return &StatusHandler{
+		statusService: statusService,
+	}
+}
+
+// GetStatus returns uptime, redirect latency and dependency health for public status pages
+func (h *StatusHandler) GetStatus(c *gin.Context) {
+	c.Header("Cache-Control", statusCacheControl)
+	c.JSON(http.StatusOK, h.statusService.GetStatus())
+}
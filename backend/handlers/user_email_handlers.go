@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/services"
+)
+
+type UserEmailHandler struct {
+	userEmailService   services.UserEmailService
+	emailQueueConsumer *services.EmailQueueConsumer
+}
+
+func NewUserEmailHandler(userEmailService services.UserEmailService, emailQueueConsumer *services.EmailQueueConsumer) *UserEmailHandler {
+	return &UserEmailHandler{
+		userEmailService:   userEmailService,
+		emailQueueConsumer: emailQueueConsumer,
+	}
+}
+
+// GetEmails lists the current user's primary and secondary emails
+func (h *UserEmailHandler) GetEmails(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	emails, err := h.userEmailService.GetEmails(c.Request.Context(), userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, emails)
+}
+
+// AddEmail adds a secondary email and sends it a verification code
+func (h *UserEmailHandler) AddEmail(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.AddUserEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	email, otpResponse, err := h.userEmailService.AddEmail(c.Request.Context(), userID.(int), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	if !h.sendVerification(c, email, otpResponse) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, email)
+}
+
+// ResendVerification sends a new verification code to an unverified secondary email
+func (h *UserEmailHandler) ResendVerification(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email ID"})
+		return
+	}
+
+	email, otpResponse, err := h.userEmailService.ResendVerification(c.Request.Context(), userID.(int), id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	if !h.sendVerification(c, email, otpResponse) {
+		return
+	}
+
+	c.JSON(http.StatusOK, otpResponse)
+}
+
+// VerifyEmail verifies a secondary email with the code sent to it
+func (h *UserEmailHandler) VerifyEmail(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email ID"})
+		return
+	}
+
+	var req models.VerifyUserEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	email, err := h.userEmailService.VerifyEmail(c.Request.Context(), userID.(int), id, &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, email)
+}
+
+// MakePrimary makes a verified secondary email the current user's primary email
+func (h *UserEmailHandler) MakePrimary(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email ID"})
+		return
+	}
+
+	emails, err := h.userEmailService.MakePrimary(c.Request.Context(), userID.(int), id)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, emails)
+}
+
+// DeleteEmail removes a secondary email
+func (h *UserEmailHandler) DeleteEmail(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email ID"})
+		return
+	}
+
+	if err := h.userEmailService.DeleteEmail(c.Request.Context(), userID.(int), id); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email deleted successfully"})
+}
+
+// sendVerification queues the email delivering a verification code, writing
+// the error response and returning false if it can't be queued
+func (h *UserEmailHandler) sendVerification(c *gin.Context, email *models.UserEmail, otpResponse *models.OTPResponse) bool {
+	if err := h.emailQueueConsumer.PublishOTPEmail(c.Request.Context(), otpResponse.DeliveryID, email.Email, models.OTPPurposeEmailAlias); err != nil {
+		recordInternalError(c, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		return false
+	}
+	return true
+}
+
+// handleError handles different types of errors appropriately
+func (h *UserEmailHandler) handleError(c *gin.Context, err error) {
+	handler := &Handler{}
+	handler.handleError(c, err)
+}
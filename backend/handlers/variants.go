@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecordConversion counts a conversion of a split test variant. The owner's
+// site calls it, e.g. with an API key, for visitors that arrived with the
+// variant's name and then converted.
+func (h *Handler) RecordConversion(c *gin.Context) {
+	shortCode := c.Param("shortCode")
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	variant, err := h.urlService.RecordConversion(c.Request.Context(), shortCode, c.Param("name"), userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, variant)
+}
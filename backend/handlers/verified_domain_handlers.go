@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/services"
+)
+
+type VerifiedDomainHandler struct {
+	verifiedDomainService services.VerifiedDomainService
+}
+
+func NewVerifiedDomainHandler(verifiedDomainService services.VerifiedDomainService) *VerifiedDomainHandler {
+	return &VerifiedDomainHandler{
+		verifiedDomainService: verifiedDomainService,
+	}
+}
+
+// CreateDomain claims a destination domain for the current user
+func (h *VerifiedDomainHandler) CreateDomain(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req models.CreateVerifiedDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	domain, err := h.verifiedDomainService.AddDomain(c.Request.Context(), userID.(int), &req)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"domain":            domain,
+		"verification_name": domain.VerificationRecord(),
+	})
+}
+
+// GetDomains lists the current user's destination domains
+func (h *VerifiedDomainHandler) GetDomains(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	domains, err := h.verifiedDomainService.GetDomains(c.Request.Context(), userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"domains": domains})
+}
+
+// VerifyDomain checks DNS ownership of a destination domain
+func (h *VerifiedDomainHandler) VerifyDomain(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain ID"})
+		return
+	}
+
+	domain, err := h.verifiedDomainService.VerifyDomain(c.Request.Context(), id, userID.(int))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, domain)
+}
+
+// DeleteDomain drops a destination domain claim
+func (h *VerifiedDomainHandler) DeleteDomain(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain ID"})
+		return
+	}
+
+	if err := h.verifiedDomainService.DeleteDomain(c.Request.Context(), id, userID.(int)); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Domain deleted successfully"})
+}
+
+// handleError handles different types of errors appropriately
+func (h *VerifiedDomainHandler) handleError(c *gin.Context, err error) {
+	handler := &Handler{}
+	handler.handleError(c, err)
+}
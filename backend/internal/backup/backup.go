@@ -0,0 +1,239 @@
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hpower2/url-shortener/database"
+	"github.com/lib/pq"
+)
+
+// Archive format identifiers. Bump FormatVersion when the layout changes in a
+// way older versions can't read.
+const (
+	FormatName    = "url-shortener-backup"
+	FormatVersion = 1
+)
+
+// table describes a table included in backups
+type table struct {
+	name    string
+	orderBy string
+	serial  bool // Has an id sequence to reset after a restore
+
+	// Column restored after the rest of the dataset, breaking a foreign key cycle
+	deferred string
+
+	// Only included when requested
+	optional bool
+}
+
+// tables lists the backed up tables, parents before children
+var tables = []table{
+	{name: "users", orderBy: "id", serial: true, deferred: "organization_id"},
+	{name: "organizations", orderBy: "id", serial: true},
+	{name: "user_emails", orderBy: "id", serial: true},
+	{name: "urls", orderBy: "id", serial: true},
+	{name: "qr_payloads", orderBy: "id", serial: true},
+	{name: "click_aggregates", orderBy: "url_id, bucket"},
+	{name: "click_dimension_aggregates", orderBy: "url_id, day, dimension, value"},
+	{name: "click_events", orderBy: "id", serial: true, optional: true},
+}
+
+// lookupTable returns the spec of a backed up table
+func lookupTable(name string) (table, bool) {
+	for _, t := range tables {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return table{}, false
+}
+
+// Header is the first line of an archive
+type Header struct {
+	Format    string        `json:"format"`
+	Version   int           `json:"version"`
+	CreatedAt time.Time     `json:"created_at"`
+	Tables    []TableHeader `json:"tables"`
+}
+
+// TableHeader lists the columns archived for a table
+type TableHeader struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+}
+
+// Trailer is the last line of an archive. Its checksum covers every row line.
+type Trailer struct {
+	Counts map[string]int `json:"counts"`
+	SHA256 string         `json:"sha256"`
+}
+
+// line is a row or trailer line of an archive
+type line struct {
+	Table   string          `json:"table,omitempty"`
+	Row     json.RawMessage `json:"row,omitempty"`
+	Trailer *Trailer        `json:"trailer,omitempty"`
+}
+
+// Options selects what an export includes
+type Options struct {
+	// Raw click events; aggregates are always included
+	IncludeClickEvents bool
+}
+
+// Summary reports the rows exported or restored per table
+type Summary struct {
+	Tables []string // In restore order
+	Counts map[string]int
+}
+
+// Export writes the dataset to w as a gzipped JSON Lines archive: a header, one
+// line per row and a trailer with row counts and a checksum. Rows are read in
+// one repeatable-read transaction so the archive is a consistent snapshot.
+func Export(ctx context.Context, db *database.DB, w io.Writer, opts Options) (*Summary, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin export: %w", err)
+	}
+	defer tx.Rollback()
+
+	header := Header{Format: FormatName, Version: FormatVersion, CreatedAt: time.Now().UTC()}
+	var included []table
+	for _, t := range tables {
+		if t.optional && !opts.IncludeClickEvents {
+			continue
+		}
+		columns, err := tableColumns(ctx, tx, t.name)
+		if err != nil {
+			return nil, err
+		}
+		header.Tables = append(header.Tables, TableHeader{Name: t.name, Columns: columns})
+		included = append(included, t)
+	}
+
+	gz := gzip.NewWriter(w)
+	out := bufio.NewWriter(gz)
+	if err := writeLine(out, nil, header); err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{Counts: map[string]int{}}
+	checksum := sha256.New()
+	for i, t := range included {
+		count, err := exportTable(ctx, tx, out, checksum, t, header.Tables[i].Columns)
+		if err != nil {
+			return nil, err
+		}
+		summary.Tables = append(summary.Tables, t.name)
+		summary.Counts[t.name] = count
+	}
+
+	trailer := &Trailer{Counts: summary.Counts, SHA256: hex.EncodeToString(checksum.Sum(nil))}
+	if err := writeLine(out, nil, line{Trailer: trailer}); err != nil {
+		return nil, err
+	}
+	if err := out.Flush(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write archive: %w", err)
+	}
+	return summary, nil
+}
+
+// exportTable writes one line per row of a table, returning the row count
+func exportTable(ctx context.Context, tx *sql.Tx, out *bufio.Writer, checksum hash.Hash, t table, columns []string) (int, error) {
+	query := fmt.Sprintf(`SELECT row_to_json(r) FROM (SELECT %s FROM %s ORDER BY %s) r`,
+		quoteColumns(columns), pq.QuoteIdentifier(t.name), t.orderBy)
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", t.name, err)
+	}
+	defer rows.Close()
+
+	count := 0
+	for rows.Next() {
+		var row json.RawMessage
+		if err := rows.Scan(&row); err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", t.name, err)
+		}
+		if err := writeLine(out, checksum, line{Table: t.name, Row: row}); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", t.name, err)
+	}
+	return count, nil
+}
+
+// writeLine writes a JSON line, adding it to the checksum when one is given
+func writeLine(out *bufio.Writer, checksum hash.Hash, value interface{}) error {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode archive line: %w", err)
+	}
+	encoded = append(encoded, '\n')
+	if checksum != nil {
+		checksum.Write(encoded)
+	}
+	if _, err := out.Write(encoded); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// queryer is satisfied by both databases and transactions
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// tableColumns returns the stored (non-generated) columns of a table in order
+func tableColumns(ctx context.Context, q queryer, name string) ([]string, error) {
+	query := `
+		SELECT column_name
+		FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1 AND is_generated = 'NEVER'
+		ORDER BY ordinal_position`
+
+	rows, err := q.QueryContext(ctx, query, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns of %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, fmt.Errorf("failed to get columns of %s: %w", name, err)
+		}
+		columns = append(columns, column)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s does not exist; run the migrations first", name)
+	}
+	return columns, rows.Err()
+}
+
+// quoteColumns returns a comma-separated list of quoted column names
+func quoteColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = pq.QuoteIdentifier(column)
+	}
+	return strings.Join(quoted, ", ")
+}
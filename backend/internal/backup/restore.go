@@ -0,0 +1,322 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hpower2/url-shortener/database"
+	"github.com/lib/pq"
+)
+
+// restoreBatchSize is the number of rows inserted per statement
+const restoreBatchSize = 500
+
+// CacheRebuilder resets the cached state of restored links
+type CacheRebuilder interface {
+	DeleteURL(ctx context.Context, shortCode string) error
+	SetClickCount(ctx context.Context, shortCode string, count int64) error
+}
+
+// deferredValue is a column value restored after the rest of the dataset
+type deferredValue struct {
+	ID    int
+	Value *int
+}
+
+// restorer holds the state of a restore in progress
+type restorer struct {
+	tx       *sql.Tx
+	columns  map[string][]string // Archived columns by table
+	checksum []byte
+	counts   map[string]int
+	deferred map[string][]deferredValue // By table
+}
+
+// Restore loads an archive written by Export into an empty database in one
+// transaction. The archive's format version, columns, row counts and checksum
+// are checked, and foreign keys are enforced, before anything is committed.
+func Restore(ctx context.Context, db *database.DB, r io.Reader) (*Summary, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer gz.Close()
+	in := bufio.NewReader(gz)
+
+	headerLine, err := in.ReadBytes('\n')
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive header: %w", err)
+	}
+	var header Header
+	if err := json.Unmarshal(headerLine, &header); err != nil || header.Format != FormatName {
+		return nil, fmt.Errorf("not a %s archive", FormatName)
+	}
+	if header.Version > FormatVersion {
+		return nil, fmt.Errorf("archive format version %d is newer than this release supports (%d)", header.Version, FormatVersion)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin restore: %w", err)
+	}
+	defer tx.Rollback()
+
+	rs := &restorer{tx: tx, columns: map[string][]string{}, counts: map[string]int{}, deferred: map[string][]deferredValue{}}
+	if err := rs.checkTarget(ctx, header.Tables); err != nil {
+		return nil, err
+	}
+
+	trailer, err := rs.load(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	if err := rs.verify(ctx, trailer); err != nil {
+		return nil, err
+	}
+	if err := rs.finish(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit restore: %w", err)
+	}
+	summary := &Summary{Counts: rs.counts}
+	for _, th := range header.Tables {
+		summary.Tables = append(summary.Tables, th.Name)
+	}
+	return summary, nil
+}
+
+// checkTarget checks every archived table exists, is empty and has the
+// archived columns. Columns missing from the archive get their defaults.
+func (rs *restorer) checkTarget(ctx context.Context, archived []TableHeader) error {
+	for _, th := range archived {
+		if _, ok := lookupTable(th.Name); !ok {
+			return fmt.Errorf("archive contains unknown table %s", th.Name)
+		}
+
+		columns, err := tableColumns(ctx, rs.tx, th.Name)
+		if err != nil {
+			return err
+		}
+		existing := make(map[string]bool, len(columns))
+		for _, column := range columns {
+			existing[column] = true
+		}
+		for _, column := range th.Columns {
+			if !existing[column] {
+				return fmt.Errorf("column %s.%s is missing; run the migrations of the release that made the backup first", th.Name, column)
+			}
+		}
+
+		var hasRows bool
+		query := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s)`, pq.QuoteIdentifier(th.Name))
+		if err := rs.tx.QueryRowContext(ctx, query).Scan(&hasRows); err != nil {
+			return fmt.Errorf("failed to check %s: %w", th.Name, err)
+		}
+		if hasRows {
+			return fmt.Errorf("table %s is not empty; restore into a freshly migrated database", th.Name)
+		}
+
+		rs.columns[th.Name] = th.Columns
+	}
+	return nil
+}
+
+// load inserts the archived rows, returning the trailer
+func (rs *restorer) load(ctx context.Context, in *bufio.Reader) (*Trailer, error) {
+	checksum := sha256.New()
+	var batch []json.RawMessage
+	current := ""
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := rs.insert(ctx, current, batch)
+		batch = batch[:0]
+		return err
+	}
+
+	for {
+		raw, err := in.ReadBytes('\n')
+		if err == io.EOF && len(raw) == 0 {
+			return nil, fmt.Errorf("archive is truncated: no trailer")
+		}
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+
+		var l line
+		if err := json.Unmarshal(raw, &l); err != nil {
+			return nil, fmt.Errorf("archive is corrupt: %w", err)
+		}
+		if l.Trailer != nil {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			rs.checksum = checksum.Sum(nil)
+			return l.Trailer, nil
+		}
+
+		if _, ok := rs.columns[l.Table]; !ok {
+			return nil, fmt.Errorf("archive has a row for undeclared table %q", l.Table)
+		}
+		checksum.Write(raw)
+		if l.Table != current {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			current = l.Table
+		}
+		batch = append(batch, l.Row)
+		rs.counts[l.Table]++
+		if len(batch) >= restoreBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// insert inserts a batch of rows of a table. Deferred columns are left at
+// their defaults and remembered.
+func (rs *restorer) insert(ctx context.Context, name string, rows []json.RawMessage) error {
+	t, _ := lookupTable(name)
+	columns := make([]string, 0, len(rs.columns[name]))
+	for _, column := range rs.columns[name] {
+		if column != t.deferred {
+			columns = append(columns, column)
+		}
+	}
+
+	if t.deferred != "" {
+		for _, row := range rows {
+			var values map[string]json.RawMessage
+			if err := json.Unmarshal(row, &values); err != nil {
+				return fmt.Errorf("archive is corrupt: %w", err)
+			}
+			var value deferredValue
+			if err := json.Unmarshal(values["id"], &value.ID); err != nil {
+				return fmt.Errorf("archive is corrupt: %s row without id", name)
+			}
+			if raw, ok := values[t.deferred]; ok {
+				if err := json.Unmarshal(raw, &value.Value); err != nil {
+					return fmt.Errorf("archive is corrupt: %w", err)
+				}
+			}
+			if value.Value != nil {
+				rs.deferred[name] = append(rs.deferred[name], value)
+			}
+		}
+	}
+
+	var encoded bytes.Buffer
+	encoded.WriteByte('[')
+	for i, row := range rows {
+		if i > 0 {
+			encoded.WriteByte(',')
+		}
+		encoded.Write(row)
+	}
+	encoded.WriteByte(']')
+
+	quoted := quoteColumns(columns)
+	query := fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s FROM json_populate_recordset(NULL::%s, $1::json)`,
+		pq.QuoteIdentifier(name), quoted, quoted, pq.QuoteIdentifier(name))
+	if _, err := rs.tx.ExecContext(ctx, query, encoded.String()); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", name, err)
+	}
+	return nil
+}
+
+// verify compares the restored rows with the trailer's counts and checksum
+func (rs *restorer) verify(ctx context.Context, trailer *Trailer) error {
+	if hex.EncodeToString(rs.checksum) != trailer.SHA256 {
+		return fmt.Errorf("archive checksum mismatch; the file is corrupt or was modified")
+	}
+
+	for name := range rs.columns {
+		if rs.counts[name] != trailer.Counts[name] {
+			return fmt.Errorf("archive is truncated: %s has %d of %d rows", name, rs.counts[name], trailer.Counts[name])
+		}
+
+		var stored int
+		query := fmt.Sprintf(`SELECT COUNT(*) FROM %s`, pq.QuoteIdentifier(name))
+		if err := rs.tx.QueryRowContext(ctx, query).Scan(&stored); err != nil {
+			return fmt.Errorf("failed to count %s: %w", name, err)
+		}
+		if stored != trailer.Counts[name] {
+			return fmt.Errorf("restored %d of %d %s rows", stored, trailer.Counts[name], name)
+		}
+	}
+	return nil
+}
+
+// finish restores deferred columns and moves id sequences past the restored rows
+func (rs *restorer) finish(ctx context.Context) error {
+	for _, t := range tables {
+		if _, ok := rs.columns[t.name]; !ok {
+			continue
+		}
+
+		if values := rs.deferred[t.name]; len(values) > 0 {
+			ids := make([]int64, len(values))
+			refs := make([]int64, len(values))
+			for i, value := range values {
+				ids[i], refs[i] = int64(value.ID), int64(*value.Value)
+			}
+			query := fmt.Sprintf(`
+				UPDATE %[1]s SET %[2]s = v.ref
+				FROM unnest($1::bigint[], $2::bigint[]) AS v(id, ref)
+				WHERE %[1]s.id = v.id`, pq.QuoteIdentifier(t.name), pq.QuoteIdentifier(t.deferred))
+			if _, err := rs.tx.ExecContext(ctx, query, pq.Array(ids), pq.Array(refs)); err != nil {
+				return fmt.Errorf("failed to restore %s.%s: %w", t.name, t.deferred, err)
+			}
+		}
+
+		if t.serial {
+			query := fmt.Sprintf(`SELECT setval(pg_get_serial_sequence($1, 'id'), COALESCE(MAX(id), 1), MAX(id) IS NOT NULL) FROM %s`,
+				pq.QuoteIdentifier(t.name))
+			if _, err := rs.tx.ExecContext(ctx, query, t.name); err != nil {
+				return fmt.Errorf("failed to reset the %s id sequence: %w", t.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// RebuildCache drops the cached redirects of every link and reloads their
+// click counters from the database, so nothing cached before a restore is served
+func RebuildCache(ctx context.Context, db *database.DB, cache CacheRebuilder) (int, error) {
+	rows, err := db.QueryContext(ctx, `SELECT short_code, click_count FROM urls ORDER BY id`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list links: %w", err)
+	}
+	defer rows.Close()
+
+	rebuilt := 0
+	for rows.Next() {
+		var shortCode string
+		var clicks int64
+		if err := rows.Scan(&shortCode, &clicks); err != nil {
+			return rebuilt, fmt.Errorf("failed to list links: %w", err)
+		}
+		if err := cache.DeleteURL(ctx, shortCode); err != nil {
+			return rebuilt, fmt.Errorf("failed to clear cached link %s: %w", shortCode, err)
+		}
+		if err := cache.SetClickCount(ctx, shortCode, clicks); err != nil {
+			return rebuilt, fmt.Errorf("failed to reset click count of %s: %w", shortCode, err)
+		}
+		rebuilt++
+	}
+	return rebuilt, rows.Err()
+}
@@ -0,0 +1,178 @@
+//go:build chaos
+
+// Package chaos injects latency and errors into database and Redis calls made
+// while serving chosen routes, to exercise timeouts, fallbacks and error
+// handling on staging before a real outage does. It is only compiled into
+// binaries built with -tags chaos; other builds get no-op stand-ins.
+//
+// Rules are separated by semicolons. Each names a method and gin route path
+// ("*" matches any), the calls to disturb (db, redis or all) and its faults:
+//
+//	GET /:shortCode redis error=0.5; * * db latency=200ms@0.25 error=0.01
+//
+// latency=200ms@0.25 delays a quarter of the calls by 200ms (the rate defaults
+// to 1) and error=0.01 fails one call in a hundred with ErrInjected.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Enabled reports whether fault injection is compiled in
+const Enabled = true
+
+// ErrInjected is the error of calls failed on purpose
+var ErrInjected = errors.New("chaos: injected fault")
+
+// Calls a rule disturbs
+const (
+	TargetDB    = "db"
+	TargetRedis = "redis"
+	TargetAll   = "all"
+)
+
+// Rule injects faults into the calls made while serving matching requests
+type Rule struct {
+	Method      string // "*" matches any method
+	Path        string // Gin route path, e.g. "/:shortCode"; "*" matches any route
+	Target      string
+	Latency     time.Duration
+	LatencyRate float64
+	ErrorRate   float64
+}
+
+// matches reports whether the rule applies to a request to the route
+func (r *Rule) matches(method, path string) bool {
+	return (r.Method == "*" || r.Method == method) && (r.Path == "*" || r.Path == path)
+}
+
+// ParseRules parses semicolon-separated rules
+func ParseRules(value string) ([]Rule, error) {
+	var rules []Rule
+	for _, spec := range strings.Split(value, ";") {
+		fields := strings.Fields(spec)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("rule %q needs a method, path, target and at least one fault", strings.TrimSpace(spec))
+		}
+
+		rule := Rule{Method: strings.ToUpper(fields[0]), Path: fields[1], Target: fields[2]}
+		switch rule.Target {
+		case TargetDB, TargetRedis, TargetAll:
+		default:
+			return nil, fmt.Errorf("rule %q: target must be db, redis or all", strings.TrimSpace(spec))
+		}
+		for _, fault := range fields[3:] {
+			if err := rule.parseFault(fault); err != nil {
+				return nil, fmt.Errorf("rule %q: %w", strings.TrimSpace(spec), err)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// parseFault sets a latency=<duration>[@<rate>] or error=<rate> fault
+func (r *Rule) parseFault(fault string) error {
+	name, value, ok := strings.Cut(fault, "=")
+	if !ok {
+		return fmt.Errorf("fault %q must be latency=<duration>[@<rate>] or error=<rate>", fault)
+	}
+
+	switch name {
+	case "latency":
+		duration, rate, hasRate := strings.Cut(value, "@")
+		latency, err := time.ParseDuration(duration)
+		if err != nil || latency <= 0 {
+			return fmt.Errorf("latency %q must be a positive duration", duration)
+		}
+		r.Latency = latency
+		r.LatencyRate = 1
+		if hasRate {
+			if r.LatencyRate, err = parseRate(rate); err != nil {
+				return err
+			}
+		}
+	case "error":
+		var err error
+		if r.ErrorRate, err = parseRate(value); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown fault %q", name)
+	}
+	return nil
+}
+
+// parseRate parses the share of calls a fault hits
+func parseRate(value string) (float64, error) {
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return 0, fmt.Errorf("rate %q must be between 0 and 1", value)
+	}
+	return rate, nil
+}
+
+// rulesContextKey is the context key holding the rules of a request
+type rulesContextKey struct{}
+
+// Middleware attaches the rules matching each request's route to its context,
+// so the database and Redis calls made while serving it are disturbed
+func Middleware(value string) (gin.HandlerFunc, error) {
+	rules, err := ParseRules(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(c *gin.Context) {
+		var matched []Rule
+		for i := range rules {
+			if rules[i].matches(c.Request.Method, c.FullPath()) {
+				matched = append(matched, rules[i])
+			}
+		}
+		if len(matched) > 0 {
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), rulesContextKey{}, matched))
+		}
+		c.Next()
+	}, nil
+}
+
+// inject applies the faults of the context's rules to a call to target. It
+// returns ErrInjected for calls it fails, or the context's error if it ends
+// while the call is delayed.
+func inject(ctx context.Context, target string) error {
+	rules, _ := ctx.Value(rulesContextKey{}).([]Rule)
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Target != target && rule.Target != TargetAll {
+			continue
+		}
+
+		if rule.Latency > 0 && rand.Float64() < rule.LatencyRate {
+			timer := time.NewTimer(rule.Latency)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		if rand.Float64() < rule.ErrorRate {
+			log.Printf("chaos: failing %s call of %s %s", target, rule.Method, rule.Path)
+			return ErrInjected
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+//go:build !chaos
+
+// Package chaos injects faults into database and Redis calls on staging. This
+// build leaves it out: build with -tags chaos to compile it in.
+package chaos
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// Enabled reports whether fault injection is compiled in
+const Enabled = false
+
+// Middleware always fails in builds without fault injection
+func Middleware(value string) (gin.HandlerFunc, error) {
+	return nil, fmt.Errorf("fault injection needs a binary built with -tags chaos")
+}
+
+// DriverName returns the database/sql driver name unchanged
+func DriverName(name string) string {
+	return name
+}
+
+// InstrumentRedis leaves the client unchanged
+func InstrumentRedis(client *goredis.Client) {}
@@ -0,0 +1,37 @@
+//go:build chaos
+
+package chaos
+
+import (
+	"context"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// InstrumentRedis makes the client inject faults into commands and pipelines
+func InstrumentRedis(client *goredis.Client) {
+	client.AddHook(redisHook{})
+}
+
+// redisHook injects faults before each command or pipeline is sent
+type redisHook struct{}
+
+// BeforeProcess implements goredis.Hook
+func (redisHook) BeforeProcess(ctx context.Context, cmd goredis.Cmder) (context.Context, error) {
+	return ctx, inject(ctx, TargetRedis)
+}
+
+// AfterProcess implements goredis.Hook
+func (redisHook) AfterProcess(ctx context.Context, cmd goredis.Cmder) error {
+	return nil
+}
+
+// BeforeProcessPipeline implements goredis.Hook
+func (redisHook) BeforeProcessPipeline(ctx context.Context, cmds []goredis.Cmder) (context.Context, error) {
+	return ctx, inject(ctx, TargetRedis)
+}
+
+// AfterProcessPipeline implements goredis.Hook
+func (redisHook) AfterProcessPipeline(ctx context.Context, cmds []goredis.Cmder) error {
+	return nil
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"log"
+	"net"
+	"net/url"
 	"os"
 	"strconv"
 	"strings"
@@ -21,6 +23,10 @@ type Config struct {
 	App      AppConfig      `json:"app"`
 	SMTP     SMTPConfig     `json:"smtp"`
 	RabbitMQ RabbitMQConfig `json:"rabbitmq"`
+	Abuse    AbuseConfig    `json:"abuse"`
+	Sentry   SentryConfig   `json:"sentry"`
+	Fetch    FetchConfig    `json:"fetch"`
+	Chaos    ChaosConfig    `json:"chaos"`
 }
 
 // ServerConfig represents server configuration
@@ -46,6 +52,10 @@ type DatabaseConfig struct {
 	MaxIdleConns    int           `json:"max_idle_conns"`
 	ConnMaxLifetime time.Duration `json:"conn_max_lifetime"`
 	ConnMaxIdleTime time.Duration `json:"conn_max_idle_time"`
+	// Region names the data region served by Host
+	Region string `json:"region"`
+	// RegionHosts maps additional data regions to their database host
+	RegionHosts map[string]string `json:"region_hosts"`
 }
 
 // RedisConfig represents Redis configuration
@@ -59,6 +69,10 @@ type RedisConfig struct {
 	DialTimeout  time.Duration `json:"dial_timeout"`
 	ReadTimeout  time.Duration `json:"read_timeout"`
 	WriteTimeout time.Duration `json:"write_timeout"`
+	// RegionHosts maps additional data regions to their Redis host
+	RegionHosts map[string]string `json:"region_hosts"`
+	// URLCacheTTL bounds how long a redirect destination is served from the cache
+	URLCacheTTL time.Duration `json:"url_cache_ttl"`
 }
 
 // SecurityConfig represents security configuration
@@ -67,12 +81,83 @@ type SecurityConfig struct {
 	JWTExpiration  time.Duration `json:"jwt_expiration"`
 	RateLimitRPS   float64       `json:"rate_limit_rps"`
 	RateLimitBurst int           `json:"rate_limit_burst"`
+	IPRateLimit    int           `json:"ip_rate_limit"` // Requests per client IP per window; 0 disables
+	IPRateWindow   time.Duration `json:"ip_rate_window"`
 	MaxRequestSize int64         `json:"max_request_size"`
 	AllowedOrigins []string      `json:"allowed_origins"`
 	TrustedProxies []string      `json:"trusted_proxies"`
 	EnableHTTPS    bool          `json:"enable_https"`
 	CertFile       string        `json:"cert_file"`
 	KeyFile        string        `json:"key_file"`
+	WAFEnabled     bool          `json:"waf_enabled"`
+	WAFAllowlist   []string      `json:"waf_allowlist"`
+	WAFMaxHeader   int           `json:"waf_max_header_bytes"`
+	WAFBadAgents   []string      `json:"waf_bad_user_agents"`
+	SignatureSkew  time.Duration `json:"signature_max_skew"`
+
+	// With HTTPS enabled and domains listed, certificates are obtained from
+	// Let's Encrypt instead of CertFile and KeyFile. HTTPRedirectPort serves
+	// redirects to HTTPS (and ACME HTTP challenges); empty disables it.
+	AutocertDomains  []string `json:"autocert_domains"`
+	AutocertCacheDir string   `json:"autocert_cache_dir"`
+	AutocertEmail    string   `json:"autocert_email"`
+	HTTPRedirectPort string   `json:"http_redirect_port"`
+
+	// Lifetime of refresh tokens; JWTExpiration is the lifetime of access tokens
+	RefreshTokenExpiration time.Duration `json:"refresh_token_expiration"`
+
+	// Access token signing: HS256 signs with JWTSecret, RS256 and EdDSA with the PEM
+	// private key file. The previous secret or key (a private or public key file)
+	// still verifies tokens during a rotation.
+	JWTAlgorithm       string `json:"jwt_algorithm"`
+	JWTPrivateKeyFile  string `json:"jwt_private_key_file"`
+	JWTPreviousKeyFile string `json:"jwt_previous_key_file"`
+	JWTPreviousSecret  string `json:"-"`
+
+	// Claims access tokens are issued with and must carry; an empty audience isn't checked
+	JWTIssuer   string `json:"jwt_issuer"`
+	JWTAudience string `json:"jwt_audience"`
+
+	// Access tokens used within this long of expiring are renewed in the response
+	// (sliding sessions); 0 disables renewal
+	JWTSlidingWindow time.Duration `json:"jwt_sliding_window"`
+
+	// Body size limits for authentication and OTP endpoints, and for bulk endpoints;
+	// every other endpoint uses MaxRequestSize
+	MaxAuthRequestSize int64 `json:"max_auth_request_size"`
+	MaxBulkRequestSize int64 `json:"max_bulk_request_size"`
+
+	// AdminToken authenticates operator-only endpoints, which are disabled while it's empty
+	AdminToken string `json:"-"`
+
+	// Per API key limit on link creation; requests over the burst wait up to the max wait
+	APIKeyCreateRPS     float64       `json:"api_key_create_rps"`
+	APIKeyCreateBurst   int           `json:"api_key_create_burst"`
+	APIKeyCreateMaxWait time.Duration `json:"api_key_create_max_wait"`
+
+	// Brute-force protection for password-protected links (per link and client IP)
+	LinkPasswordMaxAttempts int           `json:"link_password_max_attempts"`
+	LinkPasswordWindow      time.Duration `json:"link_password_window"`
+	LinkPasswordLockout     time.Duration `json:"link_password_lockout"`
+
+	// Clients that visit a honeytoken code stay suspected for HoneytokenSuspectTTL,
+	// and so does their ASN once visits come from this many distinct IPs in it
+	// (0, the default, never suspects an ASN). Suspects are limited to
+	// SuspectRateLimit requests per IP rate window, redirects included (0
+	// disables). Recorded visits are deleted after HoneytokenHitRetention (0
+	// keeps them forever).
+	HoneytokenSuspectTTL   time.Duration `json:"honeytoken_suspect_ttl"`
+	HoneytokenASNThreshold int           `json:"honeytoken_asn_threshold"`
+	HoneytokenHitRetention time.Duration `json:"honeytoken_hit_retention"`
+	SuspectRateLimit       int           `json:"suspect_rate_limit"`
+
+	// GET /api/v1/resolve allows ResolveRateLimit requests per client IP per
+	// IP rate window (0 disables), and is public unless ResolveRequireAuth
+	ResolveRateLimit   int  `json:"resolve_rate_limit"`
+	ResolveRequireAuth bool `json:"resolve_require_auth"`
+
+	// Destructive calls must echo a token from their preview within ConfirmationTTL
+	ConfirmationTTL time.Duration `json:"confirmation_ttl"`
 }
 
 // LoggingConfig represents logging configuration
@@ -80,10 +165,27 @@ type LoggingConfig struct {
 	Level      string `json:"level"`
 	Format     string `json:"format"`
 	Output     string `json:"output"`
+	FilePath   string `json:"file_path"`
 	MaxSize    int    `json:"max_size"`
 	MaxBackups int    `json:"max_backups"`
 	MaxAge     int    `json:"max_age"`
 	Compress   bool   `json:"compress"`
+
+	// Fraction of successful redirects written to the access log
+	RedirectSampleRate float64 `json:"redirect_sample_rate"`
+
+	// Optional log shipping sinks (syslog, loki, http)
+	Sinks             []string          `json:"sinks"`
+	SyslogNetwork     string            `json:"syslog_network"`
+	SyslogAddress     string            `json:"syslog_address"`
+	SyslogTag         string            `json:"syslog_tag"`
+	LokiURL           string            `json:"loki_url"`
+	LokiLabels        map[string]string `json:"loki_labels"`
+	HTTPSinkURL       string            `json:"http_sink_url"`
+	ShipAuthorization string            `json:"-"`
+	ShipBatchSize     int               `json:"ship_batch_size"`
+	ShipBufferSize    int               `json:"ship_buffer_size"`
+	ShipFlushInterval time.Duration     `json:"ship_flush_interval"`
 }
 
 // AppConfig represents application-specific configuration
@@ -99,6 +201,68 @@ type AppConfig struct {
 	EnableAnalytics     bool          `json:"enable_analytics"`
 	EnableQRCode        bool          `json:"enable_qr_code"`
 	CleanupInterval     time.Duration `json:"cleanup_interval"`
+	AnalyticsMode       string        `json:"analytics_mode"`
+	UsageReportEmails   bool          `json:"usage_report_emails"`
+
+	// Per-plan analytics access: the longest days window, and the plans that
+	// see premium breakdowns (geo)
+	AnalyticsMaxDays        int            `json:"analytics_max_days"`
+	AnalyticsPlanMaxDays    map[string]int `json:"analytics_plan_max_days"`
+	AnalyticsBreakdownPlans []string       `json:"analytics_breakdown_plans"`
+
+	// Public status page: how often dependencies are probed, and the windows
+	// uptime and redirect latency are reported over
+	StatusCheckInterval time.Duration `json:"status_check_interval"`
+	StatusUptimeWindow  time.Duration `json:"status_uptime_window"`
+	StatusLatencyWindow time.Duration `json:"status_latency_window"`
+
+	// Identical link creations by a user within this window return the first
+	// result instead of creating duplicates; 0 disables coalescing
+	CreateCoalesceWindow time.Duration `json:"create_coalesce_window"`
+
+	// Canary rollouts: how often new destinations are health-checked, and how
+	// many consecutive failed checks roll a rollout back
+	CanaryCheckInterval time.Duration `json:"canary_check_interval"`
+	CanaryMaxFailures   int           `json:"canary_max_failures"`
+
+	// Titles, descriptions and favicons of link destinations are fetched again
+	// once older than this; 0 disables fetching them in the background
+	MetadataRefreshInterval time.Duration `json:"metadata_refresh_interval"`
+
+	// Visitors of links that aren't active yet are sent here, with the link's
+	// code and activation time; empty uses the frontend's coming soon page
+	ComingSoonURL string `json:"coming_soon_url"`
+
+	// How often link expirations are recorded in the link event log and events
+	// that didn't reach the message bus are published again; 0 disables both
+	URLEventRelayInterval time.Duration `json:"url_event_relay_interval"`
+
+	// Raw click events older than this are deleted every cleanup interval; 0
+	// keeps them forever
+	ClickEventRetention time.Duration `json:"click_event_retention"`
+
+	// Generated short codes get a character longer once this share of the
+	// codes at their length is taken (starting from ShortCodeLength)
+	ShortCodeMaxUtilization float64 `json:"short_code_max_utilization"`
+
+	// Key of the permutation turning sequence numbers into generated short
+	// codes. Changing it makes new codes collide with existing ones.
+	ShortCodeSecret string `json:"-"`
+
+	// Links clicked more than this many times in a minute store only a sample
+	// of their further click events that minute (0 disables sampling)
+	AnalyticsSamplingThreshold int     `json:"analytics_sampling_threshold"`
+	AnalyticsSampleRate        float64 `json:"analytics_sample_rate"`
+
+	// How precisely click events locate visitors, and the request headers the
+	// CDN or load balancer reports the visitor's country, city and ASN in
+	GeoPrecision     string `json:"geo_precision"`
+	GeoCountryHeader string `json:"geo_country_header"`
+	GeoCityHeader    string `json:"geo_city_header"`
+	GeoASNHeader     string `json:"geo_asn_header"`
+
+	// Short codes no link can take, in addition to the application's routes
+	ReservedCodes []string `json:"reserved_codes"`
 }
 
 // SMTPConfig represents SMTP configuration
@@ -108,6 +272,13 @@ type SMTPConfig struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 	From     string `json:"from"`
+
+	// DNS requirements for organizations sending from their own domain
+	SPFInclude   string `json:"spf_include"`
+	DKIMSelector string `json:"dkim_selector"`
+
+	// FeedbackSecret authenticates provider bounce and complaint webhooks
+	FeedbackSecret string `json:"-"`
 }
 
 // RabbitMQConfig represents RabbitMQ configuration
@@ -117,8 +288,111 @@ type RabbitMQConfig struct {
 	Port     string `json:"port"`
 	Username string `json:"username"`
 	Password string `json:"password"`
+
+	// Prefetch is how many unacknowledged messages the broker hands the consumer at once
+	Prefetch int `json:"prefetch"`
+	// Workers is how many messages the consumer processes concurrently
+	Workers int `json:"workers"`
+}
+
+// ChaosConfig represents fault injection into database and Redis calls, for
+// staging. Faults are only injected by binaries built with the chaos tag.
+type ChaosConfig struct {
+	// Semicolon-separated rules (see the chaos package); empty injects nothing
+	Rules string `json:"rules"`
+}
+
+// SentryConfig represents error tracking configuration (Sentry or a compatible service)
+type SentryConfig struct {
+	DSN         string  `json:"-"`
+	Environment string  `json:"environment"`
+	Release     string  `json:"release"`
+	SampleRate  float64 `json:"sample_rate"`
+	Debug       bool    `json:"debug"`
+}
+
+// Enabled returns true if error tracking is configured
+func (c *SentryConfig) Enabled() bool {
+	return c.DSN != ""
+}
+
+// FetchConfig represents the policy for requests the server makes on its own:
+// health checks, threat scans, webhook deliveries and the like
+type FetchConfig struct {
+	UserAgent string `json:"user_agent"`
+	ProxyURL  string `json:"-"` // May carry proxy credentials
+	SourceIP  string `json:"source_ip"`
+
+	// Private, loopback and link-local destinations are refused unless allowed
+	AllowPrivateNetworks bool `json:"allow_private_networks"`
+
+	// Requests per second allowed to each destination host, and the burst above it
+	HostRPS   float64 `json:"host_rps"`
+	HostBurst int     `json:"host_burst"`
 }
 
+// AbuseConfig represents anti-abuse configuration
+type AbuseConfig struct {
+	DomainThrottleEnabled       bool           `json:"domain_throttle_enabled"`
+	DomainThrottleLimit         int            `json:"domain_throttle_limit"`
+	DomainThrottlePlanLimits    map[string]int `json:"domain_throttle_plan_limits"`
+	DomainThrottleVerifiedLimit int            `json:"domain_throttle_verified_limit"`
+	DomainThrottleAction        string         `json:"domain_throttle_action"`
+	BlockedDestinations         []string       `json:"blocked_destinations"`
+	DisposableEmailAction       string         `json:"disposable_email_action"`
+	DisposableEmailDomains      []string       `json:"disposable_email_domains"`
+	SignupVelocityAction        string         `json:"signup_velocity_action"`
+	SignupVelocityLimit         int            `json:"signup_velocity_limit"`
+	SignupVelocityWindow        time.Duration  `json:"signup_velocity_window"`
+	EmailMXCheckAction          string         `json:"email_mx_check_action"`
+
+	// Destination screening against a threat list: off or safebrowsing (Google
+	// Safe Browsing), and how often active links are checked again
+	URLScanner         string        `json:"url_scanner"`
+	SafeBrowsingAPIKey string        `json:"-"`
+	URLRescanInterval  time.Duration `json:"url_rescan_interval"`
+}
+
+// Enforcement levels for anti-abuse checks
+const (
+	AbuseActionOff   = "off"
+	AbuseActionFlag  = "flag"
+	AbuseActionBlock = "block"
+)
+
+// Destination scanners
+const (
+	URLScannerOff          = "off"
+	URLScannerSafeBrowsing = "safebrowsing"
+)
+
+// Analytics storage modes
+const (
+	AnalyticsModeFull      = "full"      // Store every click event
+	AnalyticsModeAggregate = "aggregate" // Store only per-link counters, never raw clicks
+)
+
+// Geolocation precision of click events
+const (
+	GeoPrecisionNone    = "none"    // Store no location
+	GeoPrecisionCountry = "country" // Store the country only
+	GeoPrecisionCity    = "city"    // Store the country and city
+)
+
+// Log output destinations
+const (
+	LogOutputStdout = "stdout"
+	LogOutputFile   = "file"
+	LogOutputBoth   = "both"
+)
+
+// Log shipping sinks
+const (
+	LogSinkSyslog = "syslog"
+	LogSinkLoki   = "loki"
+	LogSinkHTTP   = "http"
+)
+
 // LoadConfig loads configuration from environment variables and .env file
 func LoadConfig() (*Config, error) {
 	// Load .env file if it exists
@@ -147,6 +421,8 @@ func LoadConfig() (*Config, error) {
 			MaxIdleConns:    getIntEnv("DB_MAX_IDLE_CONNS", 25),
 			ConnMaxLifetime: getDurationEnv("DB_CONN_MAX_LIFETIME", 5*time.Minute),
 			ConnMaxIdleTime: getDurationEnv("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+			Region:          getEnv("DATA_REGION", "default"),
+			RegionHosts:     getStringMapEnv("DATA_REGION_DATABASE_HOSTS", map[string]string{}),
 		},
 		Redis: RedisConfig{
 			Host:         getEnv("REDIS_HOST", "localhost"),
@@ -158,27 +434,86 @@ func LoadConfig() (*Config, error) {
 			DialTimeout:  getDurationEnv("REDIS_DIAL_TIMEOUT", 5*time.Second),
 			ReadTimeout:  getDurationEnv("REDIS_READ_TIMEOUT", 3*time.Second),
 			WriteTimeout: getDurationEnv("REDIS_WRITE_TIMEOUT", 3*time.Second),
+			RegionHosts:  getStringMapEnv("DATA_REGION_REDIS_HOSTS", map[string]string{}),
+			URLCacheTTL:  getDurationEnv("REDIRECT_CACHE_TTL", 10*time.Minute),
 		},
 		Security: SecurityConfig{
 			JWTSecret:      getEnv("JWT_SECRET", "your-secret-key"),
-			JWTExpiration:  getDurationEnv("JWT_EXPIRATION", 24*time.Hour),
+			JWTExpiration:  getDurationEnv("JWT_EXPIRATION", 15*time.Minute),
 			RateLimitRPS:   getFloat64Env("RATE_LIMIT_RPS", 10.0),
 			RateLimitBurst: getIntEnv("RATE_LIMIT_BURST", 20),
+			IPRateLimit:    getIntEnv("IP_RATE_LIMIT", 600),
+			IPRateWindow:   getDurationEnv("IP_RATE_WINDOW", time.Minute),
 			MaxRequestSize: getInt64Env("MAX_REQUEST_SIZE", 1<<20), // 1MB
 			AllowedOrigins: getSliceEnv("ALLOWED_ORIGINS", []string{"*"}),
 			TrustedProxies: getSliceEnv("TRUSTED_PROXIES", []string{}),
 			EnableHTTPS:    getBoolEnv("ENABLE_HTTPS", false),
 			CertFile:       getEnv("CERT_FILE", ""),
 			KeyFile:        getEnv("KEY_FILE", ""),
+			WAFEnabled:     getBoolEnv("WAF_ENABLED", true),
+			WAFAllowlist:   getSliceEnv("WAF_ALLOWLIST", []string{}),
+			WAFMaxHeader:   getIntEnv("WAF_MAX_HEADER_BYTES", 16<<10), // 16KB
+			WAFBadAgents:   getSliceEnv("WAF_BAD_USER_AGENTS", []string{}),
+			SignatureSkew:  getDurationEnv("SIGNATURE_MAX_SKEW", 5*time.Minute),
+
+			RefreshTokenExpiration: getDurationEnv("REFRESH_TOKEN_EXPIRATION", 30*24*time.Hour),
+			AdminToken:             getEnv("ADMIN_TOKEN", ""),
+			AutocertDomains:        getSliceEnv("TLS_AUTOCERT_DOMAINS", []string{}),
+			AutocertCacheDir:       getEnv("TLS_AUTOCERT_CACHE_DIR", "certs"),
+			AutocertEmail:          getEnv("TLS_AUTOCERT_EMAIL", ""),
+			HTTPRedirectPort:       getEnv("HTTP_REDIRECT_PORT", ""),
+			JWTAlgorithm:           getEnv("JWT_ALGORITHM", "HS256"),
+			JWTPrivateKeyFile:      getEnv("JWT_PRIVATE_KEY_FILE", ""),
+			JWTPreviousKeyFile:     getEnv("JWT_PREVIOUS_KEY_FILE", ""),
+			JWTPreviousSecret:      getEnv("JWT_PREVIOUS_SECRET", ""),
+			JWTIssuer:              getEnv("JWT_ISSUER", "url-shortener"),
+			JWTAudience:            getEnv("JWT_AUDIENCE", ""),
+			JWTSlidingWindow:       getDurationEnv("JWT_SLIDING_WINDOW", 0),
+
+			MaxAuthRequestSize: getInt64Env("MAX_AUTH_REQUEST_SIZE", 16<<10), // 16KB
+			MaxBulkRequestSize: getInt64Env("MAX_BULK_REQUEST_SIZE", 10<<20), // 10MB
+
+			APIKeyCreateRPS:     getFloat64Env("API_KEY_CREATE_RPS", 5.0),
+			APIKeyCreateBurst:   getIntEnv("API_KEY_CREATE_BURST", 10),
+			APIKeyCreateMaxWait: getDurationEnv("API_KEY_CREATE_MAX_WAIT", 0), // 0 rejects instead of queueing
+
+			LinkPasswordMaxAttempts: getIntEnv("LINK_PASSWORD_MAX_ATTEMPTS", 5),
+			LinkPasswordWindow:      getDurationEnv("LINK_PASSWORD_WINDOW", 15*time.Minute),
+			LinkPasswordLockout:     getDurationEnv("LINK_PASSWORD_LOCKOUT", 15*time.Minute),
+
+			HoneytokenSuspectTTL:   getDurationEnv("HONEYTOKEN_SUSPECT_TTL", 24*time.Hour),
+			HoneytokenASNThreshold: getIntEnv("HONEYTOKEN_ASN_THRESHOLD", 0),
+			HoneytokenHitRetention: getDurationEnv("HONEYTOKEN_HIT_RETENTION", 30*24*time.Hour),
+			SuspectRateLimit:       getIntEnv("SUSPECT_RATE_LIMIT", 30),
+
+			ResolveRateLimit:   getIntEnv("RESOLVE_RATE_LIMIT", 30),
+			ResolveRequireAuth: getBoolEnv("RESOLVE_REQUIRE_AUTH", false),
+
+			ConfirmationTTL: getDurationEnv("CONFIRMATION_TTL", 5*time.Minute),
 		},
 		Logging: LoggingConfig{
 			Level:      getEnv("LOG_LEVEL", "info"),
 			Format:     getEnv("LOG_FORMAT", "json"),
-			Output:     getEnv("LOG_OUTPUT", "stdout"),
+			Output:     getEnv("LOG_OUTPUT", LogOutputStdout),
+			FilePath:   getEnv("LOG_FILE_PATH", "logs/url-shortener.log"),
 			MaxSize:    getIntEnv("LOG_MAX_SIZE", 100),
 			MaxBackups: getIntEnv("LOG_MAX_BACKUPS", 3),
 			MaxAge:     getIntEnv("LOG_MAX_AGE", 28),
 			Compress:   getBoolEnv("LOG_COMPRESS", true),
+
+			RedirectSampleRate: getFloat64Env("LOG_REDIRECT_SAMPLE_RATE", 0.01),
+
+			Sinks:             getSliceEnv("LOG_SINKS", []string{}),
+			SyslogNetwork:     getEnv("LOG_SYSLOG_NETWORK", ""), // empty uses the local syslog daemon
+			SyslogAddress:     getEnv("LOG_SYSLOG_ADDRESS", ""),
+			SyslogTag:         getEnv("LOG_SYSLOG_TAG", "url-shortener"),
+			LokiURL:           getEnv("LOG_LOKI_URL", ""),
+			LokiLabels:        getStringMapEnv("LOG_LOKI_LABELS", map[string]string{"app": "url-shortener"}),
+			HTTPSinkURL:       getEnv("LOG_HTTP_SINK_URL", ""),
+			ShipAuthorization: getEnv("LOG_SHIP_AUTHORIZATION", ""),
+			ShipBatchSize:     getIntEnv("LOG_SHIP_BATCH_SIZE", 100),
+			ShipBufferSize:    getIntEnv("LOG_SHIP_BUFFER_SIZE", 10000),
+			ShipFlushInterval: getDurationEnv("LOG_SHIP_FLUSH_INTERVAL", 5*time.Second),
 		},
 		App: AppConfig{
 			Name:                getEnv("APP_NAME", "URL Shortener"),
@@ -192,6 +527,42 @@ func LoadConfig() (*Config, error) {
 			EnableAnalytics:     getBoolEnv("ENABLE_ANALYTICS", true),
 			EnableQRCode:        getBoolEnv("ENABLE_QR_CODE", true),
 			CleanupInterval:     getDurationEnv("CLEANUP_INTERVAL", 24*time.Hour),
+			AnalyticsMode:       getEnv("ANALYTICS_MODE", AnalyticsModeFull),
+			UsageReportEmails:   getBoolEnv("USAGE_REPORT_EMAILS", false),
+
+			AnalyticsMaxDays:        getIntEnv("ANALYTICS_MAX_DAYS", 365),
+			AnalyticsPlanMaxDays:    getIntMapEnv("ANALYTICS_PLAN_MAX_DAYS", map[string]int{"free": 30}),
+			AnalyticsBreakdownPlans: getSliceEnv("ANALYTICS_BREAKDOWN_PLANS", []string{"pro"}),
+
+			StatusCheckInterval: getDurationEnv("STATUS_CHECK_INTERVAL", 30*time.Second),
+			StatusUptimeWindow:  getDurationEnv("STATUS_UPTIME_WINDOW", 24*time.Hour),
+			StatusLatencyWindow: getDurationEnv("STATUS_LATENCY_WINDOW", 15*time.Minute),
+
+			CreateCoalesceWindow: getDurationEnv("CREATE_COALESCE_WINDOW", 5*time.Second),
+
+			CanaryCheckInterval: getDurationEnv("CANARY_CHECK_INTERVAL", time.Minute),
+			CanaryMaxFailures:   getIntEnv("CANARY_MAX_FAILURES", 3),
+
+			MetadataRefreshInterval: getDurationEnv("METADATA_REFRESH_INTERVAL", 7*24*time.Hour),
+
+			ComingSoonURL: getEnv("COMING_SOON_URL", ""),
+
+			URLEventRelayInterval: getDurationEnv("URL_EVENT_RELAY_INTERVAL", time.Minute),
+
+			ClickEventRetention: getDurationEnv("CLICK_EVENT_RETENTION", 0),
+
+			ShortCodeMaxUtilization: getFloat64Env("SHORT_CODE_MAX_UTILIZATION", 0.1),
+			ShortCodeSecret:         getEnv("SHORT_CODE_SECRET", ""),
+
+			AnalyticsSamplingThreshold: getIntEnv("ANALYTICS_SAMPLING_THRESHOLD", 0),
+			AnalyticsSampleRate:        getFloat64Env("ANALYTICS_SAMPLE_RATE", 0.1),
+
+			GeoPrecision:     getEnv("GEO_PRECISION", GeoPrecisionCountry),
+			GeoCountryHeader: getEnv("GEO_COUNTRY_HEADER", ""),
+			GeoCityHeader:    getEnv("GEO_CITY_HEADER", ""),
+			GeoASNHeader:     getEnv("GEO_ASN_HEADER", ""),
+
+			ReservedCodes: getSliceEnv("RESERVED_CODES", []string{}),
 		},
 		SMTP: SMTPConfig{
 			Host:     getEnv("SMTP_HOST", "smtp.hostinger.com"),
@@ -199,6 +570,11 @@ func LoadConfig() (*Config, error) {
 			Username: getEnv("SMTP_USERNAME", "me@irvineafri.com"),
 			Password: getEnv("SMTP_PASSWORD", ""),
 			From:     getEnv("SMTP_FROM", "noreply@irvineafri.com"),
+
+			SPFInclude:   getEnv("SMTP_SPF_INCLUDE", ""),
+			DKIMSelector: getEnv("SMTP_DKIM_SELECTOR", ""),
+
+			FeedbackSecret: getEnv("EMAIL_FEEDBACK_SECRET", ""),
 		},
 		RabbitMQ: RabbitMQConfig{
 			URL:      getEnv("RABBITMQ_URL", ""),
@@ -206,6 +582,44 @@ func LoadConfig() (*Config, error) {
 			Port:     getEnv("RABBITMQ_PORT", "5672"),
 			Username: getEnv("RABBITMQ_USERNAME", "guest"),
 			Password: getEnv("RABBITMQ_PASSWORD", "guest"),
+			Prefetch: getIntEnv("RABBITMQ_PREFETCH", 10),
+			Workers:  getIntEnv("RABBITMQ_CONSUMER_WORKERS", 4),
+		},
+		Abuse: AbuseConfig{
+			DomainThrottleEnabled:       getBoolEnv("DOMAIN_THROTTLE_ENABLED", true),
+			DomainThrottleLimit:         getIntEnv("DOMAIN_THROTTLE_LIMIT", 100), // links per destination domain per hour
+			DomainThrottlePlanLimits:    getIntMapEnv("DOMAIN_THROTTLE_PLAN_LIMITS", map[string]int{}),
+			DomainThrottleVerifiedLimit: getIntEnv("DOMAIN_THROTTLE_VERIFIED_LIMIT", 1000), // for owners of the destination domain
+			DomainThrottleAction:        getEnv("DOMAIN_THROTTLE_ACTION", "block"),
+			BlockedDestinations:         getSliceEnv("BLOCKED_DESTINATION_DOMAINS", []string{}),
+			DisposableEmailAction:       getEnv("DISPOSABLE_EMAIL_ACTION", AbuseActionBlock),
+			DisposableEmailDomains:      getSliceEnv("DISPOSABLE_EMAIL_DOMAINS", []string{}),
+			SignupVelocityAction:        getEnv("SIGNUP_VELOCITY_ACTION", AbuseActionBlock),
+			SignupVelocityLimit:         getIntEnv("SIGNUP_VELOCITY_LIMIT", 5), // signups per IP per window
+			SignupVelocityWindow:        getDurationEnv("SIGNUP_VELOCITY_WINDOW", time.Hour),
+			EmailMXCheckAction:          getEnv("EMAIL_MX_CHECK_ACTION", AbuseActionOff),
+
+			URLScanner:         getEnv("URL_SCANNER", URLScannerOff),
+			SafeBrowsingAPIKey: getEnv("SAFE_BROWSING_API_KEY", ""),
+			URLRescanInterval:  getDurationEnv("URL_RESCAN_INTERVAL", 7*24*time.Hour),
+		},
+		Sentry: SentryConfig{
+			DSN:         getEnv("SENTRY_DSN", ""), // empty disables error tracking
+			Environment: getEnv("SENTRY_ENVIRONMENT", getEnv("APP_ENV", "development")),
+			Release:     getEnv("SENTRY_RELEASE", getEnv("APP_VERSION", "1.0.0")),
+			SampleRate:  getFloat64Env("SENTRY_SAMPLE_RATE", 1.0),
+			Debug:       getBoolEnv("SENTRY_DEBUG", false),
+		},
+		Fetch: FetchConfig{
+			UserAgent:            getEnv("FETCH_USER_AGENT", "url-shortener/1.0"),
+			ProxyURL:             getEnv("FETCH_PROXY_URL", ""),
+			SourceIP:             getEnv("FETCH_SOURCE_IP", ""),
+			AllowPrivateNetworks: getBoolEnv("FETCH_ALLOW_PRIVATE_NETWORKS", false),
+			HostRPS:              getFloat64Env("FETCH_HOST_RPS", 5),
+			HostBurst:            getIntEnv("FETCH_HOST_BURST", 10),
+		},
+		Chaos: ChaosConfig{
+			Rules: getEnv("CHAOS_RULES", ""),
 		},
 	}
 
@@ -234,15 +648,80 @@ func (c *Config) Validate() error {
 	if c.Database.DBName == "" {
 		return fmt.Errorf("database name is required")
 	}
+	if c.Database.Region == "" {
+		return fmt.Errorf("data region is required")
+	}
+	if _, ok := c.Database.RegionHosts[c.Database.Region]; ok {
+		return fmt.Errorf("data region %q is the home region and can't have its own hosts", c.Database.Region)
+	}
+	if len(c.Database.RegionHosts) != len(c.Redis.RegionHosts) {
+		return fmt.Errorf("every data region needs both a database and a Redis host")
+	}
+	for region := range c.Database.RegionHosts {
+		if _, ok := c.Redis.RegionHosts[region]; !ok {
+			return fmt.Errorf("data region %q has no Redis host", region)
+		}
+	}
 
 	// Validate Redis config
 	if c.Redis.Host == "" {
 		return fmt.Errorf("redis host is required")
 	}
+	if c.Redis.URLCacheTTL < time.Second {
+		return fmt.Errorf("redirect cache TTL must be at least 1s")
+	}
 
 	// Validate security config
-	if c.Security.JWTSecret == "" || c.Security.JWTSecret == "your-secret-key" {
-		return fmt.Errorf("JWT secret must be set and not be default value")
+	switch c.Security.JWTAlgorithm {
+	case "HS256":
+		if c.Security.JWTSecret == "" || c.Security.JWTSecret == "your-secret-key" {
+			return fmt.Errorf("JWT secret must be set and not be default value")
+		}
+	case "RS256", "EdDSA":
+		if c.Security.JWTPrivateKeyFile == "" {
+			return fmt.Errorf("JWT private key file is required for %s", c.Security.JWTAlgorithm)
+		}
+	default:
+		return fmt.Errorf("JWT algorithm must be HS256, RS256 or EdDSA")
+	}
+	if c.Security.JWTExpiration < time.Minute {
+		return fmt.Errorf("JWT expiration must be at least 1m")
+	}
+	if c.Security.RefreshTokenExpiration <= c.Security.JWTExpiration {
+		return fmt.Errorf("refresh token expiration must be longer than the JWT expiration")
+	}
+	if c.Security.JWTIssuer == "" {
+		return fmt.Errorf("JWT issuer is required")
+	}
+	if c.Security.JWTSlidingWindow < 0 || c.Security.JWTSlidingWindow >= c.Security.JWTExpiration {
+		return fmt.Errorf("JWT sliding window must be between 0 and the JWT expiration")
+	}
+	if c.Security.EnableHTTPS && len(c.Security.AutocertDomains) == 0 && (c.Security.CertFile == "" || c.Security.KeyFile == "") {
+		return fmt.Errorf("HTTPS requires a certificate and key file or autocert domains")
+	}
+	if c.Security.HTTPRedirectPort != "" && !c.Security.EnableHTTPS {
+		return fmt.Errorf("HTTP redirect port requires HTTPS to be enabled")
+	}
+	if c.Security.IPRateLimit < 0 || c.Security.IPRateWindow < time.Second {
+		return fmt.Errorf("IP rate limit must not be negative and its window must be at least 1s")
+	}
+	if c.Security.HoneytokenSuspectTTL < time.Minute || c.Security.HoneytokenASNThreshold < 0 || c.Security.HoneytokenHitRetention < 0 || c.Security.SuspectRateLimit < 0 {
+		return fmt.Errorf("honeytoken suspect TTL must be at least 1m, and the ASN threshold, hit retention and suspect rate limit must not be negative")
+	}
+	if c.Security.ResolveRateLimit < 0 {
+		return fmt.Errorf("resolve rate limit must not be negative")
+	}
+	if c.Security.ConfirmationTTL < 10*time.Second || c.Security.ConfirmationTTL > time.Hour {
+		return fmt.Errorf("confirmation TTL must be between 10s and 1h")
+	}
+	if c.Security.MaxRequestSize <= 0 || c.Security.MaxAuthRequestSize <= 0 || c.Security.MaxBulkRequestSize <= 0 {
+		return fmt.Errorf("request size limits must be positive")
+	}
+	if c.Security.APIKeyCreateRPS <= 0 || c.Security.APIKeyCreateBurst <= 0 {
+		return fmt.Errorf("API key create rate and burst must be positive")
+	}
+	if c.Security.APIKeyCreateMaxWait < 0 || c.Security.APIKeyCreateMaxWait > 30*time.Second {
+		return fmt.Errorf("API key create max wait must be between 0 and 30s")
 	}
 
 	// Validate app config
@@ -252,6 +731,151 @@ func (c *Config) Validate() error {
 	if c.App.ShortCodeLength < 4 || c.App.ShortCodeLength > 20 {
 		return fmt.Errorf("short code length must be between 4 and 20")
 	}
+	if c.App.ShortCodeMaxUtilization <= 0 || c.App.ShortCodeMaxUtilization >= 1 {
+		return fmt.Errorf("short code max utilization must be between 0 and 1")
+	}
+	if c.App.ShortCodeSecret == "" && c.IsProduction() {
+		return fmt.Errorf("short code secret is required in production")
+	}
+	if c.App.CleanupInterval <= 0 {
+		return fmt.Errorf("cleanup interval must be positive")
+	}
+	if c.App.MetadataRefreshInterval < 0 {
+		return fmt.Errorf("metadata refresh interval cannot be negative")
+	}
+	if c.App.ClickEventRetention < 0 {
+		return fmt.Errorf("click event retention cannot be negative")
+	}
+	if c.App.ClickEventRetention > 0 && c.App.ClickEventRetention < time.Duration(c.App.AnalyticsMaxDays)*24*time.Hour {
+		return fmt.Errorf("click event retention must cover analytics max days")
+	}
+	if c.App.URLEventRelayInterval < 0 {
+		return fmt.Errorf("URL event relay interval cannot be negative")
+	}
+	if c.App.ComingSoonURL != "" {
+		if parsed, err := url.Parse(c.App.ComingSoonURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("coming soon URL must be an http(s) URL")
+		}
+	}
+	if c.App.AnalyticsMode != AnalyticsModeFull && c.App.AnalyticsMode != AnalyticsModeAggregate {
+		return fmt.Errorf("analytics mode must be either %s or %s", AnalyticsModeFull, AnalyticsModeAggregate)
+	}
+	if c.App.AnalyticsMaxDays <= 0 {
+		return fmt.Errorf("analytics max days must be positive")
+	}
+	for plan, days := range c.App.AnalyticsPlanMaxDays {
+		if days <= 0 {
+			return fmt.Errorf("analytics max days for plan %s must be positive", plan)
+		}
+	}
+	if c.App.AnalyticsSamplingThreshold < 0 {
+		return fmt.Errorf("analytics sampling threshold cannot be negative")
+	}
+	if c.App.AnalyticsSampleRate <= 0 || c.App.AnalyticsSampleRate > 1 {
+		return fmt.Errorf("analytics sample rate must be greater than 0 and at most 1")
+	}
+	switch c.App.GeoPrecision {
+	case GeoPrecisionNone, GeoPrecisionCountry, GeoPrecisionCity:
+	default:
+		return fmt.Errorf("geo precision must be %s, %s or %s", GeoPrecisionNone, GeoPrecisionCountry, GeoPrecisionCity)
+	}
+	if c.App.StatusCheckInterval < time.Second {
+		return fmt.Errorf("status check interval must be at least 1s")
+	}
+	if c.App.StatusUptimeWindow < c.App.StatusCheckInterval || c.App.StatusLatencyWindow <= 0 {
+		return fmt.Errorf("status uptime window must cover a check interval and latency window must be positive")
+	}
+	if c.App.CreateCoalesceWindow < 0 {
+		return fmt.Errorf("create coalesce window cannot be negative")
+	}
+	if c.App.CanaryCheckInterval <= 0 || c.App.CanaryMaxFailures <= 0 {
+		return fmt.Errorf("canary check interval and max failures must be positive")
+	}
+
+	// Validate RabbitMQ config
+	if c.RabbitMQ.Workers < 1 {
+		return fmt.Errorf("RabbitMQ consumer workers must be at least 1")
+	}
+	if c.RabbitMQ.Prefetch < c.RabbitMQ.Workers {
+		return fmt.Errorf("RabbitMQ prefetch must be at least the number of consumer workers")
+	}
+
+	// Validate abuse config
+	if c.Abuse.DomainThrottleAction != "block" && c.Abuse.DomainThrottleAction != "review" {
+		return fmt.Errorf("domain throttle action must be either block or review")
+	}
+	for name, action := range map[string]string{
+		"disposable email": c.Abuse.DisposableEmailAction,
+		"signup velocity":  c.Abuse.SignupVelocityAction,
+		"email MX check":   c.Abuse.EmailMXCheckAction,
+	} {
+		if action != AbuseActionOff && action != AbuseActionFlag && action != AbuseActionBlock {
+			return fmt.Errorf("%s action must be one of off, flag or block", name)
+		}
+	}
+	switch c.Abuse.URLScanner {
+	case URLScannerOff:
+	case URLScannerSafeBrowsing:
+		if c.Abuse.SafeBrowsingAPIKey == "" {
+			return fmt.Errorf("SAFE_BROWSING_API_KEY is required for the safebrowsing URL scanner")
+		}
+	default:
+		return fmt.Errorf("URL scanner must be either off or safebrowsing")
+	}
+	if c.Abuse.URLRescanInterval < 0 {
+		return fmt.Errorf("URL rescan interval cannot be negative")
+	}
+
+	// Validate fetch config
+	if c.Fetch.UserAgent == "" {
+		return fmt.Errorf("fetch user agent is required")
+	}
+	if c.Fetch.ProxyURL != "" {
+		proxy, err := url.Parse(c.Fetch.ProxyURL)
+		if err != nil || proxy.Host == "" || (proxy.Scheme != "http" && proxy.Scheme != "https" && proxy.Scheme != "socks5") {
+			return fmt.Errorf("fetch proxy URL must be an http, https or socks5 URL")
+		}
+	}
+	if c.Fetch.SourceIP != "" && net.ParseIP(c.Fetch.SourceIP) == nil {
+		return fmt.Errorf("fetch source IP must be an IP address")
+	}
+	if c.Fetch.HostRPS <= 0 || c.Fetch.HostBurst < 1 {
+		return fmt.Errorf("fetch host rate and burst must be positive")
+	}
+
+	// Validate logging config
+	switch c.Logging.Output {
+	case LogOutputStdout:
+	case LogOutputFile, LogOutputBoth:
+		if c.Logging.FilePath == "" {
+			return fmt.Errorf("log file path is required when logging to a file")
+		}
+	default:
+		return fmt.Errorf("log output must be one of stdout, file or both")
+	}
+	for _, sink := range c.Logging.Sinks {
+		switch sink {
+		case LogSinkSyslog:
+		case LogSinkLoki:
+			if c.Logging.LokiURL == "" {
+				return fmt.Errorf("Loki URL is required for the loki log sink")
+			}
+		case LogSinkHTTP:
+			if c.Logging.HTTPSinkURL == "" {
+				return fmt.Errorf("HTTP sink URL is required for the http log sink")
+			}
+		default:
+			return fmt.Errorf("unknown log sink %q", sink)
+		}
+	}
+	if len(c.Logging.Sinks) > 0 && (c.Logging.ShipBatchSize <= 0 || c.Logging.ShipBufferSize <= 0 || c.Logging.ShipFlushInterval <= 0) {
+		return fmt.Errorf("log shipping batch size, buffer size and flush interval must be positive")
+	}
+
+	// Validate chaos config
+	if c.Chaos.Rules != "" && c.IsProduction() {
+		return fmt.Errorf("chaos rules cannot be used in production")
+	}
 
 	return nil
 }
@@ -348,3 +972,41 @@ func getSliceEnv(key string, defaultValue []string) []string {
 	}
 	return defaultValue
 }
+
+// getIntMapEnv parses comma-separated key:value pairs, e.g. "free:100,pro:1000"
+func getIntMapEnv(key string, defaultValue map[string]int) map[string]int {
+	if value := os.Getenv(key); value != "" {
+		result := make(map[string]int)
+		for _, item := range strings.Split(value, ",") {
+			parts := strings.SplitN(strings.TrimSpace(item), ":", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			if intValue, err := strconv.Atoi(strings.TrimSpace(parts[1])); err == nil {
+				result[strings.TrimSpace(parts[0])] = intValue
+			}
+		}
+		if len(result) > 0 {
+			return result
+		}
+	}
+	return defaultValue
+}
+
+// getStringMapEnv parses comma-separated key:value pairs, e.g. "app:url-shortener,env:prod"
+func getStringMapEnv(key string, defaultValue map[string]string) map[string]string {
+	if value := os.Getenv(key); value != "" {
+		result := make(map[string]string)
+		for _, item := range strings.Split(value, ",") {
+			parts := strings.SplitN(strings.TrimSpace(item), ":", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			result[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+		if len(result) > 0 {
+			return result
+		}
+	}
+	return defaultValue
+}
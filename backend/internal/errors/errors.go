@@ -10,15 +10,20 @@ type ErrorCode string
 
 const (
 	// Client errors
-	ErrCodeValidation    ErrorCode = "VALIDATION_ERROR"
-	ErrCodeNotFound      ErrorCode = "NOT_FOUND"
-	ErrCodeInactive      ErrorCode = "URL_INACTIVE"
-	ErrCodeExpired       ErrorCode = "URL_EXPIRED"
-	ErrCodeAlreadyExists ErrorCode = "ALREADY_EXISTS"
-	ErrCodeUnauthorized  ErrorCode = "UNAUTHORIZED"
-	ErrCodeForbidden     ErrorCode = "FORBIDDEN"
-	ErrCodeRateLimit     ErrorCode = "RATE_LIMIT_EXCEEDED"
-	ErrCodeBadRequest    ErrorCode = "BAD_REQUEST"
+	ErrCodeValidation      ErrorCode = "VALIDATION_ERROR"
+	ErrCodeNotFound        ErrorCode = "NOT_FOUND"
+	ErrCodeInactive        ErrorCode = "URL_INACTIVE"
+	ErrCodeExpired         ErrorCode = "URL_EXPIRED"
+	ErrCodeNotYetActive    ErrorCode = "URL_NOT_YET_ACTIVE"
+	ErrCodeAlreadyExists   ErrorCode = "ALREADY_EXISTS"
+	ErrCodeUnauthorized    ErrorCode = "UNAUTHORIZED"
+	ErrCodeForbidden       ErrorCode = "FORBIDDEN"
+	ErrCodeRateLimit       ErrorCode = "RATE_LIMIT_EXCEEDED"
+	ErrCodeBadRequest      ErrorCode = "BAD_REQUEST"
+	ErrCodeReferrerBlocked ErrorCode = "REFERRER_BLOCKED"
+	ErrCodeLinkThrottled   ErrorCode = "LINK_THROTTLED"
+	ErrCodeConflict        ErrorCode = "CONFLICT"
+	ErrCodeTooLarge        ErrorCode = "PAYLOAD_TOO_LARGE"
 	
 	// Server errors
 	ErrCodeInternal      ErrorCode = "INTERNAL_ERROR"
@@ -83,6 +88,10 @@ func NewExpiredError(message string, err error) *AppError {
 	return NewAppError(ErrCodeExpired, message, http.StatusGone, err)
 }
 
+func NewNotYetActiveError(message string, err error) *AppError {
+	return NewAppError(ErrCodeNotYetActive, message, http.StatusNotFound, err)
+}
+
 func NewAlreadyExistsError(message string, err error) *AppError {
 	return NewAppError(ErrCodeAlreadyExists, message, http.StatusConflict, err)
 }
@@ -103,6 +112,22 @@ func NewBadRequestError(message string, err error) *AppError {
 	return NewAppError(ErrCodeBadRequest, message, http.StatusBadRequest, err)
 }
 
+func NewReferrerBlockedError(message string, err error) *AppError {
+	return NewAppError(ErrCodeReferrerBlocked, message, http.StatusForbidden, err)
+}
+
+func NewLinkThrottledError(message string, err error) *AppError {
+	return NewAppError(ErrCodeLinkThrottled, message, http.StatusTooManyRequests, err)
+}
+
+func NewConflictError(message string, err error) *AppError {
+	return NewAppError(ErrCodeConflict, message, http.StatusConflict, err)
+}
+
+func NewPayloadTooLargeError(message string, err error) *AppError {
+	return NewAppError(ErrCodeTooLarge, message, http.StatusRequestEntityTooLarge, err)
+}
+
 func NewInternalError(message string, err error) *AppError {
 	return NewAppError(ErrCodeInternal, message, http.StatusInternalServerError, err)
 }
@@ -0,0 +1,221 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/config"
+	"golang.org/x/time/rate"
+)
+
+// ErrBlockedAddress is returned for destinations on private, loopback or other
+// internal networks, unless the policy allows them
+var ErrBlockedAddress = errors.New("destination address is not allowed")
+
+// maxRedirects bounds the redirects followed for a single request
+const maxRedirects = 5
+
+// maxTrackedHosts bounds the per-host rate limiters kept between requests
+const maxTrackedHosts = 10000
+
+// blockedPrefixes are the non-public ranges not covered by the netip.Addr
+// predicates checked in allowed
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "This" network
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // Benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // Reserved, including broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64, which can reach private IPv4 addresses
+}
+
+// Fetcher is the HTTP client for every request the server makes on its own
+// initiative, such as destination health checks, threat scans and webhook
+// deliveries. It applies one outbound policy to all of them: requests carry
+// the configured user agent, leave through the configured proxy or source
+// address, are rate limited per destination host and, unless allowed, never
+// reach private networks. Callers bound requests with their context.
+type Fetcher struct {
+	client       *http.Client
+	userAgent    string
+	proxied      bool
+	allowPrivate bool
+	hostRate     rate.Limit
+	hostBurst    int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+// New creates a fetcher enforcing the configured policy
+func New(cfg *config.FetchConfig) (*Fetcher, error) {
+	f := &Fetcher{
+		userAgent:    cfg.UserAgent,
+		allowPrivate: cfg.AllowPrivateNetworks,
+		hostRate:     rate.Limit(cfg.HostRPS),
+		hostBurst:    cfg.HostBurst,
+		limiters:     make(map[string]*rate.Limiter),
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	if cfg.SourceIP != "" {
+		ip := net.ParseIP(cfg.SourceIP)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid fetch source IP %q", cfg.SourceIP)
+		}
+		dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+	if cfg.ProxyURL != "" {
+		proxy, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fetch proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+		f.proxied = true
+	} else {
+		// Check the address actually dialed, so a hostname can't resolve to a
+		// public address when checked and a private one when connected to
+		dialer.Control = f.controlDial
+	}
+
+	f.client = &http.Client{Transport: transport, CheckRedirect: f.checkRedirect}
+	return f, nil
+}
+
+// Do sends a request under the outbound policy, waiting for the destination
+// host's rate limit first. Like http.Client.Do, it follows redirects and
+// returns a response whatever its status.
+func (f *Fetcher) Do(req *http.Request) (*http.Response, error) {
+	if err := f.checkURL(req.Context(), req.URL); err != nil {
+		return nil, err
+	}
+	if err := f.wait(req.Context(), req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", f.userAgent)
+	return f.client.Do(req)
+}
+
+// checkRedirect applies the policy to each redirect, which counts against the
+// rate limit of the host it leads to
+func (f *Fetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if err := f.checkURL(req.Context(), req.URL); err != nil {
+		return err
+	}
+	return f.wait(req.Context(), req.URL.Hostname())
+}
+
+// checkURL refuses schemes other than HTTP(S) and, when requests go through a
+// proxy, hosts resolving to a blocked address. Direct connections are checked
+// as they are dialed instead.
+func (f *Fetcher) checkURL(ctx context.Context, u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	if !f.proxied || f.allowPrivate {
+		return nil
+	}
+
+	host := u.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return f.checkAddr(addr)
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if err := f.checkAddr(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// controlDial refuses connections to blocked addresses
+func (f *Fetcher) controlDial(network, address string, _ syscall.RawConn) error {
+	if f.allowPrivate {
+		return nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid dial address %q: %w", address, err)
+	}
+	return f.checkAddr(addrPort.Addr())
+}
+
+// checkAddr returns ErrBlockedAddress for addresses outside the public internet
+func (f *Fetcher) checkAddr(addr netip.Addr) error {
+	if !allowed(addr) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addr)
+	}
+	return nil
+}
+
+// allowed reports whether addr is a public unicast address
+func allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// wait blocks until the host's rate limit allows another request, or fails
+// when that would take longer than ctx allows
+func (f *Fetcher) wait(ctx context.Context, host string) error {
+	if err := f.limiter(host).Wait(ctx); err != nil {
+		return fmt.Errorf("rate limit for %s: %w", host, err)
+	}
+	return nil
+}
+
+// limiter returns the rate limiter of a host. Once many hosts are tracked,
+// limiters that have refilled are dropped, as a new one behaves the same.
+func (f *Fetcher) limiter(host string) *rate.Limiter {
+	host = strings.ToLower(host)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if limiter, ok := f.limiters[host]; ok {
+		return limiter
+	}
+	if len(f.limiters) >= maxTrackedHosts {
+		for tracked, limiter := range f.limiters {
+			if limiter.Tokens() >= float64(f.hostBurst) {
+				delete(f.limiters, tracked)
+			}
+		}
+	}
+
+	limiter := rate.NewLimiter(f.hostRate, f.hostBurst)
+	f.limiters[host] = limiter
+	return limiter
+}
@@ -0,0 +1,114 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/hpower2/url-shortener/internal/config"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// New creates the application logger from the logging configuration.
+// The returned close function releases any open log files.
+func New(cfg *config.LoggingConfig) (*logrus.Logger, func() error, error) {
+	logger := logrus.New()
+
+	level, err := logrus.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid log level %q: %w", cfg.Level, err)
+	}
+	logger.SetLevel(level)
+
+	if cfg.Format == "text" {
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	} else {
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	}
+
+	var closers []io.Closer
+	closeFn := func() error {
+		var firstErr error
+		for _, closer := range closers {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+
+	switch cfg.Output {
+	case config.LogOutputFile, config.LogOutputBoth:
+		fileWriter, err := newRotatingFile(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		closers = append(closers, fileWriter)
+
+		if cfg.Output == config.LogOutputBoth {
+			logger.SetOutput(io.MultiWriter(os.Stdout, fileWriter))
+		} else {
+			logger.SetOutput(fileWriter)
+		}
+	default:
+		logger.SetOutput(os.Stdout)
+	}
+
+	// Ship logs to external sinks
+	for _, sink := range cfg.Sinks {
+		hook, err := newSink(sink, cfg)
+		if err != nil {
+			closeFn()
+			return nil, nil, err
+		}
+		logger.AddHook(hook)
+		// Sinks are closed before the file so buffered entries are flushed first
+		closers = append([]io.Closer{hook}, closers...)
+	}
+
+	return logger, closeFn, nil
+}
+
+// sinkHook is a logrus hook that must be closed to flush buffered entries
+type sinkHook interface {
+	logrus.Hook
+	io.Closer
+}
+
+// newSink creates the hook for a configured log sink
+func newSink(name string, cfg *config.LoggingConfig) (sinkHook, error) {
+	opts := shipperOptions{
+		authorization: cfg.ShipAuthorization,
+		batchSize:     cfg.ShipBatchSize,
+		bufferSize:    cfg.ShipBufferSize,
+		flushInterval: cfg.ShipFlushInterval,
+	}
+
+	switch name {
+	case config.LogSinkSyslog:
+		return newSyslogHook(cfg.SyslogNetwork, cfg.SyslogAddress, cfg.SyslogTag)
+	case config.LogSinkLoki:
+		return newShipper(name, cfg.LokiURL, "application/json", newLokiEncoder(cfg.LokiLabels), opts), nil
+	case config.LogSinkHTTP:
+		return newShipper(name, cfg.HTTPSinkURL, "application/x-ndjson", encodeNDJSON, opts), nil
+	default:
+		return nil, fmt.Errorf("unknown log sink %q", name)
+	}
+}
+
+// newRotatingFile opens the log file, rotating it by size and age
+func newRotatingFile(cfg *config.LoggingConfig) (*lumberjack.Logger, error) {
+	if err := os.MkdirAll(filepath.Dir(cfg.FilePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	return &lumberjack.Logger{
+		Filename:   cfg.FilePath,
+		MaxSize:    cfg.MaxSize, // megabytes
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     cfg.MaxAge, // days
+		Compress:   cfg.Compress,
+	}, nil
+}
@@ -0,0 +1,230 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// encodeBatch turns a batch of formatted entries into an HTTP request body
+type encodeBatch func(entries []shippedEntry) ([]byte, error)
+
+// shippedEntry is a formatted log line captured for shipping
+type shippedEntry struct {
+	time  time.Time
+	level logrus.Level
+	line  []byte
+}
+
+// shipper is a logrus hook that batches entries and POSTs them to an HTTP endpoint.
+// Entries are dropped rather than blocking the caller when the buffer is full.
+type shipper struct {
+	name          string
+	url           string
+	contentType   string
+	authorization string
+	encode        encodeBatch
+	formatter     logrus.Formatter
+	client        *http.Client
+	batchSize     int
+	flushInterval time.Duration
+
+	entries chan shippedEntry
+	done    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// newShipper creates a shipper and starts its background flush loop
+func newShipper(name, url, contentType string, encode encodeBatch, opts shipperOptions) *shipper {
+	s := &shipper{
+		name:          name,
+		url:           url,
+		contentType:   contentType,
+		authorization: opts.authorization,
+		encode:        encode,
+		formatter:     &logrus.JSONFormatter{},
+		client:        &http.Client{Timeout: 10 * time.Second},
+		batchSize:     opts.batchSize,
+		flushInterval: opts.flushInterval,
+		entries:       make(chan shippedEntry, opts.bufferSize),
+		done:          make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s
+}
+
+// shipperOptions holds the batching settings shared by all HTTP sinks
+type shipperOptions struct {
+	authorization string
+	batchSize     int
+	bufferSize    int
+	flushInterval time.Duration
+}
+
+// Levels implements logrus.Hook
+func (s *shipper) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook
+func (s *shipper) Fire(entry *logrus.Entry) error {
+	line, err := s.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case s.entries <- shippedEntry{time: entry.Time, level: entry.Level, line: bytes.TrimRight(line, "\n")}:
+	default:
+		// Buffer full: drop the entry rather than stall request handling
+	}
+	return nil
+}
+
+// Close flushes buffered entries and stops the flush loop
+func (s *shipper) Close() error {
+	s.once.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+	})
+	return nil
+}
+
+// run collects entries and flushes them by size or interval
+func (s *shipper) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]shippedEntry, 0, s.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.send(batch); err != nil {
+			// The logger itself may be shipping, so report on stderr
+			fmt.Fprintf(os.Stderr, "Failed to ship %d log entries to %s: %v\n", len(batch), s.name, err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case entry := <-s.entries:
+			batch = append(batch, entry)
+			if len(batch) >= s.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.done:
+			// Drain whatever is still buffered
+			for {
+				select {
+				case entry := <-s.entries:
+					batch = append(batch, entry)
+					if len(batch) >= s.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send POSTs one batch to the sink
+func (s *shipper) send(batch []shippedEntry) error {
+	body, err := s.encode(batch)
+	if err != nil {
+		return fmt.Errorf("failed to encode batch: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", s.contentType)
+	if s.authorization != "" {
+		req.Header.Set("Authorization", s.authorization)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send batch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("sink responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// encodeNDJSON encodes a batch as newline-delimited JSON
+func encodeNDJSON(entries []shippedEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		buf.Write(entry.line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// lokiPushRequest is the body of a Loki push API request
+type lokiPushRequest struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+// lokiStream is a set of log lines sharing the same labels
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// newLokiEncoder returns an encoder that groups entries into one stream per level
+func newLokiEncoder(labels map[string]string) encodeBatch {
+	return func(entries []shippedEntry) ([]byte, error) {
+		streams := make(map[logrus.Level]*lokiStream)
+		var order []logrus.Level
+
+		for _, entry := range entries {
+			stream, ok := streams[entry.level]
+			if !ok {
+				streamLabels := make(map[string]string, len(labels)+1)
+				for key, value := range labels {
+					streamLabels[key] = value
+				}
+				streamLabels["level"] = entry.level.String()
+
+				stream = &lokiStream{Stream: streamLabels}
+				streams[entry.level] = stream
+				order = append(order, entry.level)
+			}
+			stream.Values = append(stream.Values, [2]string{
+				strconv.FormatInt(entry.time.UnixNano(), 10),
+				string(entry.line),
+			})
+		}
+
+		push := lokiPushRequest{}
+		for _, level := range order {
+			push.Streams = append(push.Streams, *streams[level])
+		}
+		return json.Marshal(push)
+	}
+}
@@ -0,0 +1,59 @@
+//go:build !windows && !plan9
+
+package logger
+
+import (
+	"fmt"
+	"log/syslog"
+
+	"github.com/sirupsen/logrus"
+)
+
+// syslogHook forwards entries to a local or remote syslog daemon
+type syslogHook struct {
+	writer    *syslog.Writer
+	formatter logrus.Formatter
+}
+
+// newSyslogHook dials syslog; an empty network uses the local daemon
+func newSyslogHook(network, address, tag string) (*syslogHook, error) {
+	writer, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return &syslogHook{writer: writer, formatter: &logrus.JSONFormatter{}}, nil
+}
+
+// Levels implements logrus.Hook
+func (h *syslogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook
+func (h *syslogHook) Fire(entry *logrus.Entry) error {
+	line, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	message := string(line)
+
+	switch entry.Level {
+	case logrus.PanicLevel:
+		return h.writer.Emerg(message)
+	case logrus.FatalLevel:
+		return h.writer.Crit(message)
+	case logrus.ErrorLevel:
+		return h.writer.Err(message)
+	case logrus.WarnLevel:
+		return h.writer.Warning(message)
+	case logrus.InfoLevel:
+		return h.writer.Info(message)
+	default:
+		return h.writer.Debug(message)
+	}
+}
+
+// Close closes the syslog connection
+func (h *syslogHook) Close() error {
+	return h.writer.Close()
+}
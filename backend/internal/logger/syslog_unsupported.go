@@ -0,0 +1,24 @@
+//go:build windows || plan9
+
+package logger
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// syslogHook is unavailable on platforms without log/syslog
+type syslogHook struct {
+	logrus.Hook
+}
+
+// newSyslogHook always fails on platforms without log/syslog
+func newSyslogHook(network, address, tag string) (*syslogHook, error) {
+	return nil, fmt.Errorf("syslog is not supported on this platform")
+}
+
+// Close implements io.Closer
+func (h *syslogHook) Close() error {
+	return nil
+}
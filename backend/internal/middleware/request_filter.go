@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/config"
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Rule names reported in block logs and stats
+const (
+	RulePathTraversal   = "path_traversal"
+	RuleSQLInjection    = "sql_injection"
+	RuleOversizedHeader = "oversized_headers"
+	RuleBadUserAgent    = "bad_user_agent"
+)
+
+var (
+	pathTraversalPatterns = []string{"../", "..\\", "%2e%2e", "%252e", "/etc/passwd", "%00", "\x00"}
+
+	sqlInjectionPattern = regexp.MustCompile(`(?i)(\bunion\b[\s\S]*\bselect\b)|(\bor\b\s+\d+\s*=\s*\d+)|('\s*or\s+')|(;\s*(drop|delete|insert|update)\s)|(\b(sleep|benchmark|pg_sleep)\s*\()|(\binformation_schema\b)|(/\*[\s\S]*\*/)|('\s*--)`)
+
+	defaultBadUserAgents = []string{
+		"sqlmap", "nikto", "nmap", "masscan", "acunetix", "nessus", "dirbuster",
+		"gobuster", "wpscan", "zgrab", "nuclei", "havij", "w3af", "fimap",
+	}
+)
+
+// RequestFilter blocks obviously malicious requests before they reach handlers
+type RequestFilter struct {
+	logger         *logrus.Logger
+	maxHeaderBytes int
+	badUserAgents  []string
+	allowedIPs     map[string]bool
+	allowedNets    []*net.IPNet
+
+	mu    sync.Mutex
+	stats map[string]int64
+}
+
+// NewRequestFilter creates a request filter from the security configuration
+func NewRequestFilter(cfg *config.SecurityConfig, logger *logrus.Logger) *RequestFilter {
+	f := &RequestFilter{
+		logger:         logger,
+		maxHeaderBytes: cfg.WAFMaxHeader,
+		allowedIPs:     make(map[string]bool),
+		stats:          make(map[string]int64),
+	}
+
+	for _, agent := range append(defaultBadUserAgents, cfg.WAFBadAgents...) {
+		f.badUserAgents = append(f.badUserAgents, strings.ToLower(agent))
+	}
+
+	for _, entry := range cfg.WAFAllowlist {
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			f.allowedNets = append(f.allowedNets, ipNet)
+		} else {
+			f.allowedIPs[entry] = true
+		}
+	}
+
+	return f
+}
+
+// Handler returns the filtering middleware
+func (f *RequestFilter) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if f.isAllowlisted(c.ClientIP()) {
+			c.Next()
+			return
+		}
+
+		if rule := f.match(c.Request); rule != "" {
+			f.record(rule)
+			f.logger.WithFields(logrus.Fields{
+				"rule":       rule,
+				"client_ip":  c.ClientIP(),
+				"method":     c.Request.Method,
+				"path":       c.Request.URL.Path,
+				"user_agent": c.Request.UserAgent(),
+			}).Warn("Suspicious request blocked")
+
+			appErr := errors.NewForbiddenError("Request blocked", nil)
+			c.JSON(appErr.StatusCode, appErr.ToErrorResponse())
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// StatsHandler returns per-rule block counts
+func (f *RequestFilter) StatsHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"blocked": f.Stats()})
+	}
+}
+
+// Stats returns a snapshot of per-rule block counts
+func (f *RequestFilter) Stats() map[string]int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	snapshot := make(map[string]int64, len(f.stats))
+	for rule, count := range f.stats {
+		snapshot[rule] = count
+	}
+	return snapshot
+}
+
+// match returns the name of the first rule the request violates
+func (f *RequestFilter) match(r *http.Request) string {
+	rawPath := strings.ToLower(r.URL.EscapedPath())
+	rawQuery := strings.ToLower(r.URL.RawQuery)
+	for _, pattern := range pathTraversalPatterns {
+		if strings.Contains(rawPath, pattern) || strings.Contains(rawQuery, pattern) {
+			return RulePathTraversal
+		}
+	}
+
+	if query, err := url.QueryUnescape(r.URL.RawQuery); err == nil && sqlInjectionPattern.MatchString(query) {
+		return RuleSQLInjection
+	}
+
+	if f.maxHeaderBytes > 0 && headerSize(r.Header) > f.maxHeaderBytes {
+		return RuleOversizedHeader
+	}
+
+	userAgent := strings.ToLower(r.UserAgent())
+	for _, agent := range f.badUserAgents {
+		if agent != "" && strings.Contains(userAgent, agent) {
+			return RuleBadUserAgent
+		}
+	}
+
+	return ""
+}
+
+// isAllowlisted checks the client IP against the allowlist
+func (f *RequestFilter) isAllowlisted(clientIP string) bool {
+	if f.allowedIPs[clientIP] {
+		return true
+	}
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range f.allowedNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// record increments the block count for a rule
+func (f *RequestFilter) record(rule string) {
+	f.mu.Lock()
+	f.stats[rule]++
+	f.mu.Unlock()
+}
+
+// headerSize returns the total size of all header names and values
+func headerSize(header http.Header) int {
+	size := 0
+	for name, values := range header {
+		for _, value := range values {
+			size += len(name) + len(value)
+		}
+	}
+	return size
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Account deletion statuses
+const (
+	AccountDeletionPending   = "pending"
+	AccountDeletionRunning   = "running"
+	AccountDeletionCompleted = "completed"
+	AccountDeletionFailed    = "failed"
+)
+
+// Stages of an account deletion, in the order they run. The link stages run
+// once per data region, then the account itself is deleted.
+const (
+	PurgeStageCaches     = "caches"     // Deactivate links and drop their cache entries
+	PurgeStageEvents     = "events"     // Delete raw click events
+	PurgeStageAggregates = "aggregates" // Delete click rollups
+	PurgeStageURLs       = "urls"       // Delete the links themselves
+	PurgeStageAccount    = "account"    // Delete the user, once every region is purged
+)
+
+// PurgeLinkStages are the stages run in every data region, in order
+var PurgeLinkStages = []string{PurgeStageCaches, PurgeStageEvents, PurgeStageAggregates, PurgeStageURLs}
+
+// Chunk sizes of a purge. Each queued job deletes at most one chunk, so no
+// statement holds locks for long however large the account is. Links are
+// deleted in smaller chunks as each one cascades to its remaining rows.
+const (
+	PurgeRowChunkSize = 5000
+	PurgeURLChunkSize = 500
+)
+
+// Who asked for an account to be deleted
+const (
+	DeletionRequestedByUser  = "user"
+	DeletionRequestedByAdmin = "admin"
+)
+
+// AccountDeletion tracks the background purge of a deleted account. It
+// outlives the user so admins can follow and audit it.
+type AccountDeletion struct {
+	ID          int    `db:"id" json:"id"`
+	UserID      int    `db:"user_id" json:"user_id"`
+	Email       string `db:"email" json:"email"`
+	RequestedBy string `db:"requested_by" json:"requested_by"`
+	Status      string `db:"status" json:"status"`
+	Stage       string `db:"stage" json:"stage"`
+	Region      string `db:"region" json:"region"`
+	Chunks      int    `db:"chunks" json:"chunks"` // Chunks purged so far
+	PurgeProgress
+	Attempts    int        `db:"attempts" json:"attempts"` // Failed attempts at the current chunk
+	Error       string     `db:"error" json:"error,omitempty"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updated_at"`
+	CompletedAt *time.Time `db:"completed_at" json:"completed_at,omitempty"`
+}
+
+// PurgeProgress counts what an account deletion has purged
+type PurgeProgress struct {
+	CachesPurged       int64 `db:"caches_purged" json:"caches_purged"`
+	ClickEventsDeleted int64 `db:"click_events_deleted" json:"click_events_deleted"`
+	AggregatesDeleted  int64 `db:"aggregates_deleted" json:"aggregates_deleted"`
+	URLsDeleted        int64 `db:"urls_deleted" json:"urls_deleted"`
+}
+
+// Finished reports whether the deletion has stopped, successfully or not
+func (d *AccountDeletion) Finished() bool {
+	return d.Status == AccountDeletionCompleted || d.Status == AccountDeletionFailed
+}
+
+// NextStage returns the stage and region following the current ones, given
+// the data regions in order. The account stage is last.
+func (d *AccountDeletion) NextStage(regions []string) (string, string) {
+	for i, stage := range PurgeLinkStages {
+		if stage != d.Stage {
+			continue
+		}
+		if i+1 < len(PurgeLinkStages) {
+			return PurgeLinkStages[i+1], d.Region
+		}
+		for j, region := range regions {
+			if region == d.Region && j+1 < len(regions) {
+				return PurgeLinkStages[0], regions[j+1]
+			}
+		}
+	}
+	return PurgeStageAccount, ""
+}
+
+// DeleteAccountRequest confirms a user's request to delete their own account
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// AdminDeleteAccountRequest asks for a user's account to be deleted by an admin
+type AdminDeleteAccountRequest struct {
+	UserID int `json:"user_id" binding:"required"`
+}
+
+// AccountDeletionFilter selects account deletions for the admin view
+type AccountDeletionFilter struct {
+	Status string
+	Limit  int
+}
+
+// Validate validates and applies defaults to the filter
+func (f *AccountDeletionFilter) Validate() error {
+	f.Status = strings.ToLower(strings.TrimSpace(f.Status))
+	switch f.Status {
+	case "", AccountDeletionPending, AccountDeletionRunning, AccountDeletionCompleted, AccountDeletionFailed:
+	default:
+		return fmt.Errorf("status must be one of %s, %s, %s, %s",
+			AccountDeletionPending, AccountDeletionRunning, AccountDeletionCompleted, AccountDeletionFailed)
+	}
+	if f.Limit <= 0 || f.Limit > 200 {
+		f.Limit = 50
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// APIKey represents a key used by machine clients to sign requests
+type APIKey struct {
+	ID         int        `db:"id" json:"id"`
+	UserID     int        `db:"user_id" json:"user_id"`
+	Name       string     `db:"name" json:"name"`
+	KeyID      string     `db:"key_id" json:"key_id"`
+	Secret     string     `db:"secret" json:"-"` // Only returned once, on creation
+	IsActive   bool       `db:"is_active" json:"is_active"`
+	Sandbox    bool       `db:"sandbox" json:"sandbox"` // Writes are validated but never persisted
+	LastUsedAt *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+	RevokedAt  *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+}
+
+// CreateAPIKeyRequest represents a request to create an API key
+type CreateAPIKeyRequest struct {
+	Name    string `json:"name" binding:"required" validate:"required,max=100"`
+	Sandbox bool   `json:"sandbox"`
+}
+
+// CreateAPIKeyResponse represents a newly created API key including its secret
+type CreateAPIKeyResponse struct {
+	APIKey
+	Secret string `json:"secret"`
+}
+
+// Validate validates the create API key request
+func (req *CreateAPIKeyRequest) Validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(req.Name) > 100 {
+		return fmt.Errorf("name must be at most 100 characters long")
+	}
+	return nil
+}
+
+// SignedRequest holds the parts of an HTTP request covered by an HMAC signature
+type SignedRequest struct {
+	KeyID     string
+	Timestamp string
+	Nonce     string
+	Signature string
+	Method    string
+	Path      string // Request path including the raw query string
+	Body      []byte
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Canary rollout states
+const (
+	CanaryStatusRollingOut = "rolling_out"
+	CanaryStatusPromoted   = "promoted"    // The new destination replaced the old one
+	CanaryStatusRolledBack = "rolled_back" // The new destination failed its health checks
+)
+
+// Canary rollout limits
+const (
+	DefaultCanaryDuration = 24 * time.Hour
+	MinCanaryDuration     = 10 * time.Minute
+	MaxCanaryDuration     = 30 * 24 * time.Hour
+	MaxCanaryStartPercent = 99
+)
+
+// CanaryRollout gradually moves a link's clicks from its destination to a new
+// one: the new destination's share rises linearly from StartPercent to 100%
+// over Duration, after which it becomes the link's destination
+type CanaryRollout struct {
+	URL          string `json:"url"`                     // Empty cancels a rollout in progress
+	Duration     string `json:"duration,omitempty"`      // How long the shift takes, e.g. "6h" (default 24h)
+	StartPercent int    `json:"start_percent,omitempty"` // Share of clicks sent to the new destination at the start
+
+	duration time.Duration
+}
+
+// Validate validates and normalizes the rollout. An empty URL cancels it.
+func (r *CanaryRollout) Validate() error {
+	r.URL = strings.TrimSpace(r.URL)
+	if r.URL == "" {
+		return nil
+	}
+	if !strings.HasPrefix(r.URL, "http://") && !strings.HasPrefix(r.URL, "https://") {
+		r.URL = "https://" + r.URL
+	}
+
+	parsed, err := url.Parse(r.URL)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("canary URL must be a valid URL")
+	}
+
+	r.duration = DefaultCanaryDuration
+	if r.Duration != "" {
+		r.duration, err = time.ParseDuration(r.Duration)
+		if err != nil {
+			return fmt.Errorf("canary duration must be a duration such as 6h")
+		}
+	}
+	if r.duration < MinCanaryDuration || r.duration > MaxCanaryDuration {
+		return fmt.Errorf("canary duration must be between %s and %s", MinCanaryDuration, MaxCanaryDuration)
+	}
+
+	if r.StartPercent < 0 || r.StartPercent > MaxCanaryStartPercent {
+		return fmt.Errorf("canary start percent must be between 0 and %d", MaxCanaryStartPercent)
+	}
+
+	return nil
+}
+
+// Apply copies the rollout onto a URL, starting it now. An empty URL cancels
+// the rollout and leaves the link on its current destination.
+func (r *CanaryRollout) Apply(u *URL, now time.Time) {
+	if r.URL == "" {
+		u.CanaryURL = ""
+		u.CanaryStatus = ""
+		u.CanaryStartPercent = 0
+		u.CanaryStartedAt = nil
+		u.CanaryEndsAt = nil
+		u.CanaryFailures = 0
+		return
+	}
+
+	endsAt := now.Add(r.duration)
+	u.CanaryURL = r.URL
+	u.CanaryStatus = CanaryStatusRollingOut
+	u.CanaryStartPercent = r.StartPercent
+	u.CanaryStartedAt = &now
+	u.CanaryEndsAt = &endsAt
+	u.CanaryFailures = 0
+}
+
+// IsCanaryRollingOut returns true if the link is shifting clicks to a new destination
+func (u *URL) IsCanaryRollingOut() bool {
+	return u.CanaryStatus == CanaryStatusRollingOut && u.CanaryStartedAt != nil && u.CanaryEndsAt != nil
+}
+
+// CanaryPercent returns the share of clicks the new destination receives at now
+func (u *URL) CanaryPercent(now time.Time) int {
+	if !u.IsCanaryRollingOut() {
+		return 0
+	}
+	if !now.Before(*u.CanaryEndsAt) {
+		return 100
+	}
+
+	total := u.CanaryEndsAt.Sub(*u.CanaryStartedAt)
+	elapsed := now.Sub(*u.CanaryStartedAt)
+	if elapsed <= 0 || total <= 0 {
+		return u.CanaryStartPercent
+	}
+	return u.CanaryStartPercent + int(int64(100-u.CanaryStartPercent)*int64(elapsed)/int64(total))
+}
+
+// PickCanary reports whether a visit at now goes to the new destination
+func (u *URL) PickCanary(now time.Time) bool {
+	return rand.IntN(100) < u.CanaryPercent(now)
+}
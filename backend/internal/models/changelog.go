@@ -0,0 +1,122 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Kinds of API change
+const (
+	APIChangeAdded      = "added"
+	APIChangeChanged    = "changed"
+	APIChangeDeprecated = "deprecated"
+	APIChangeRemoved    = "removed"
+)
+
+// APIChange is one user-facing change to the API's behavior
+type APIChange struct {
+	Version     string `json:"version"`
+	Date        string `json:"date"`
+	Kind        string `json:"kind"`
+	Endpoint    string `json:"endpoint,omitempty"`
+	Description string `json:"description"`
+}
+
+// Deprecation marks an endpoint that will be removed. Responses from it carry
+// Deprecation and Sunset headers so API consumers can migrate ahead of time.
+type Deprecation struct {
+	Method      string     `json:"method"`
+	Path        string     `json:"path"`
+	Since       time.Time  `json:"since"`
+	Sunset      *time.Time `json:"sunset,omitempty"`
+	Replacement string     `json:"replacement,omitempty"` // Path of the endpoint to use instead
+	Description string     `json:"description"`
+}
+
+// Route returns the method and route path the deprecation applies to
+func (d Deprecation) Route() string {
+	return d.Method + " " + d.Path
+}
+
+// DeprecationHeader returns the Deprecation header value (RFC 9745)
+func (d Deprecation) DeprecationHeader() string {
+	return fmt.Sprintf("@%d", d.Since.Unix())
+}
+
+// SunsetHeader returns the Sunset header value (RFC 8594), or "" without a sunset date
+func (d Deprecation) SunsetHeader() string {
+	if d.Sunset == nil {
+		return ""
+	}
+	return d.Sunset.UTC().Format(http.TimeFormat)
+}
+
+// APIChangelog lists user-facing API changes, newest first. Add an entry with
+// every change in behavior that API consumers may need to act on.
+var APIChangelog = []APIChange{
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeChanged, Endpoint: "GET /api/v1/resolve/:shortCode", Description: "Click-limited links no longer disclose their destination and are reported with click_limited true. Links with device rules, split tests or canary rollouts resolve to the destination the caller would be sent to, cacheable only privately."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeChanged, Endpoint: "PUT /api/v1/urls/:shortCode", Description: "Raising max_clicks above redirect_count, or setting it to 0, reactivates a link deactivated by its click limit unless the same request sets is_active."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeChanged, Endpoint: "GET /:shortCode", Description: "The page served to link preview bots and prefetches no longer contains the destination: it carries the fetched page title and description and the short URL, without a meta refresh or link."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeChanged, Endpoint: "POST /api/v1/organization/members", Description: "Invites the email instead of adding the user, answering 201 with the invitation. The invitee lists it with GET /api/v1/organization/invitations and joins with POST /api/v1/organization/invitations/:id/accept, or declines with DELETE /api/v1/organization/invitations/:id. Invitations expire after 7 days."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeChanged, Endpoint: "GET /api/v1/urls", Description: "Expired links are deactivated (is_active false) by the background cleanup job. Extending them, or setting a future expires_at without is_active, reactivates them; links deactivated for other reasons stay inactive."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/urls/:shortCode/events", Description: "Lists a link's lifecycle events (created, activated, deactivated, expired, destination_changed, deleted), newest first. The same events are published to the url_events RabbitMQ exchange with routing key link.<type>."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/urls", Description: "Links accept activates_at to only start redirecting at that time; before it, visitors see a coming soon page and the API answers 404 URL_NOT_YET_ACTIVE. Listed links carry a status, and GET /api/v1/urls accepts status=scheduled."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/urls", Description: "Links accept domain, one of your verified custom domains to share them on, and PUT /api/v1/profile/preferences accepts default_domain for new links. short_url, share URLs and QR codes use the link's domain. Removing a domain moves its links back to the default domain."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/urls/:shortCode/refresh-metadata", Description: "Fetches the title, description and favicon of a link's destination now. Links gain page_title, page_description, favicon_url and metadata_fetched_at, refreshed in the background and cleared when the destination changes."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/urls", Description: "Links accept device_rules sending visitors on ios, android, mobile, tablet or desktop devices to another URL or an app deep link, tried in order; other visitors follow the link as usual. PUT /api/v1/urls/:shortCode replaces them, [] removes them."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeChanged, Endpoint: "DELETE /api/v1/profile", Description: "Deleting the account, DELETE /api/v1/domains/:id and the new POST /api/v1/urls/delete-by-label need the confirmation_token of their preview (POST /api/v1/profile/delete-preview, /domains/:id/delete-preview, /urls/delete-by-label/preview) in the X-Confirmation-Token header. Calls without it get 428, with an expired or used one 412."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "PUT /api/v1/organization/code-prefix", Description: "Reserves a custom code prefix such as acme- for an organization's members, optionally required for their custom codes. Other users get 400 for custom codes using it. Members list and roll up the prefix's links with GET /api/v1/organization/links and /organization/links/analytics; organizations gain code_prefix and require_code_prefix."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/urls/:shortCode/variants/:name/conversions", Description: "Counts a conversion of a split test variant. Links accept split_test to send visits to weighted destination variants, tagged with split_variant; their analytics gain variants with clicks, conversions, conversion_rate and lift."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/urls/:shortCode/guest-edit-links", Description: "Mints an expiring, single-use link letting someone without an account change the destination through GET and PUT /api/v1/guest-edit/:token. Destination changes made this way carry guest_edit_link_id."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/urls/:shortCode/analytics", Description: "QR codes encode the short link with ?src=qr so scans are counted apart from clicks. Analytics gain clicks_by_channel, click events gain channel (click or scan) and CSV click exports gain a trailing channel column."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/resolve/:shortCode", Description: "Returns a short link's destination, status and safety verdict as JSON without redirecting or counting a click. Public by default and rate limited per client IP."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/urls/:shortCode/qr", Description: "Accepts size, error_correction, foreground, background, margin and logo query parameters overriding the qr_style preference, and format=svg for SVG output. qr_style gains margin and logo. Invalid overrides are rejected with 400."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/urls", Description: "Accepts sort=expires_at (links without expiration last) and sort=clicks for click_count, and filters by status=active|expired|inactive, created_after and created_before. An order other than asc or desc is now rejected with 400 instead of sorting descending."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/urls/:shortCode/clicks", Description: "Click events carry the attributes set by the deployment's click enrichers, omitted when there are none. CSV click exports gain a trailing attributes column."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/urls", Description: "Pages by cursor when called with ?cursor= (empty for the first page), returning next_cursor and has_more instead of totals. GET /api/v1/urls/:shortCode/clicks lists a link's click events newest first, paged by cursor the same way. Links with equal sort values are now ordered by ID in the direction of the sort."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeChanged, Endpoint: "GET /api/v1/urls/:shortCode/analytics", Description: "Clicks from clients caught visiting honeytoken short codes are reported with the Bot browser and device, whatever user agent they send."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/urls", Description: "Accepts an optional title (one line, up to 200 characters), also settable with PUT /api/v1/urls/:shortCode. Titles are returned in list and search responses, matched by search and exported as the last CSV column."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/urls/:shortCode/webhooks/:id/test", Description: "Sends a signed sample payload to a webhook and returns the delivery. GET /api/v1/webhooks/events lists every event with its payload JSON schema and an example. Test payloads carry test: true."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "DELETE /api/v1/profile", Description: "Deletes the account after confirming the password. The account is deactivated at once and its links, clicks and analytics are purged in the background."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/urls/:shortCode/kill", Description: "Disables a link on every instance within seconds and reports the propagation: disabled, cache_cleared, instances_notified and propagated."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeChanged, Endpoint: "POST /api/v1/urls", Description: "Refuses custom codes reserved by the operator or containing a profanity with 400."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "PUT /api/v1/profile/preferences", Description: "Sets presets for new links: default_expiry_days, default_redirect_type, default_labels and qr_style alongside utm_defaults. Links created without expires_at now get the preset or server default expiration; pass skip_defaults: true to opt out."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/api-keys", Description: "Accepts sandbox: true. Requests signed with a sandbox key validate link creates, updates and deletes without saving them, and are excluded from usage reports and throttles."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeChanged, Endpoint: "POST /api/v1/urls/:shortCode/webhooks", Description: "Webhook deliveries and shadow traffic are sent with the server's configured user agent (url-shortener/1.0 by default) instead of url-shortener-webhooks/1.0, and targets on private networks fail."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/urls/:shortCode/analytics", Description: "Adds sampled and sample_rate. When a hot link's click events are sampled, counts are estimated from weighted events; click events carry sample_weight."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/urls/export", Description: "Downloads all of the user's links as CSV or JSON."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/urls/:shortCode/clicks/export", Description: "Downloads a link's click events within the plan's analytics window as CSV or JSON."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/dashboard", Description: "Returns account-wide link and click totals, clicks over the last 7 and 30 days, the top 5 links and recently clicked links. GET /api/v1/urls also accepts sort=click_count."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/urls/:shortCode/analytics/timeseries", Description: "Returns a link's clicks bucketed by hour or day for charts; the stats response now fills clicks_by_date."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/verified-domains", Description: "Claims a destination domain, verified with a DNS TXT record. Links to verified domains get a higher creation throttle and skip the suspicious-link warning."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/urls/:shortCode/analytics", Description: "Adds top_browsers, top_devices and top_os breakdowns parsed from click user agents, also in the stats response; click events carry browser, device and os."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/profile/emails", Description: "Adds up to 5 secondary emails that can log in once verified with a code sent to them. A verified email can be made primary; notifications only go to the primary email."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/urls", Description: "List endpoints accept page and per_page, and return a pagination object with Link and X-Total-Count headers. Page sizes are capped at 100."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/urls", Description: "Accepts redirect_type (301, 302, 307 or meta) to choose how visitors are redirected; also settable with PUT /api/v1/urls/:shortCode."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "PUT /api/v1/urls/:shortCode", Description: "Accepts canary to shift a link's clicks to a new destination over time, promoting it when done or rolling back if it fails health checks."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeChanged, Endpoint: "GET /:shortCode", Description: "Links marked suspicious serve a warning page; the visitor is redirected after following its continue link (?proceed=1). Links report the flag in suspicious."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeChanged, Endpoint: "POST /api/v1/urls", Description: "When URL scanning is enabled, destinations flagged as phishing or malware are rejected with 403. Links flagged later are deactivated and report the match in threat_type."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/qr-payloads", Description: "Creates contact card, Wi-Fi and calendar event QR codes served through a short link, so scans are counted like clicks."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/urls/:shortCode/clicks/stream", Description: "Streams a link's click events after an opaque cursor, oldest first, for incremental syncing."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /.well-known/jwks.json", Description: "Publishes the public keys access tokens are signed with when they use RS256 or EdDSA."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Description: "With sliding sessions enabled, authenticated responses may carry a renewed access token in X-Renewed-Token (expiry in X-Renewed-Token-Expires-At) to use for later requests."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeChanged, Description: "Request bodies over the endpoint's size limit (1MB by default, 16KB for authentication and OTP, 10MB for QR batches) are rejected with 413 PAYLOAD_TOO_LARGE."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/otp/generate", Description: "Returns delivery_id and delivery_status so the delivery of the code's email can be traced."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeChanged, Endpoint: "POST /api/v1/auth/refresh", Description: "Takes a refresh_token in the body instead of an access token, and returns a new access and refresh token. Each refresh token works once."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeChanged, Endpoint: "POST /api/v1/auth/login", Description: "Access tokens expire after 15 minutes by default. Login and registration also return a refresh_token."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeChanged, Endpoint: "POST /api/v1/auth/logout", Description: "Revokes the bearer access token immediately and the session of the refresh_token in the body."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "PUT /api/v1/urls/:shortCode", Description: "notes and labels keep internal bookkeeping with a link. They are only returned to the owner and are searchable."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/changelog", Description: "Lists API changes and deprecated endpoints. Deprecated endpoints respond with Deprecation and Sunset headers."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/urls/search", Description: "Searches your links by short code or destination."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "GET /api/v1/urls/campaigns", Description: "Groups your links and their clicks by UTM campaign."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/urls", Description: "utm_source, utm_medium and utm_campaign are stored with the link and added to the destination on redirect."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/urls", Description: "max_clicks deactivates a link after a number of redirects."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/urls", Description: "rotation cycles visits through several destinations."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeAdded, Endpoint: "POST /api/v1/urls", Description: "shadow_traffic mirrors click metadata to a test endpoint."},
+	{Version: "2.1", Date: "2026-10-16", Kind: APIChangeChanged, Endpoint: "GET /:shortCode", Description: "Link preview bots and prefetches receive the link's metadata instead of a redirect and are no longer counted as clicks."},
+}
+
+// APIDeprecations lists the endpoints that are deprecated but still served.
+// Record each one in APIChangelog too.
+var APIDeprecations = []Deprecation{}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Dimensions counted by aggregate-only analytics
+const (
+	AggregateDimensionCountry  = "country"
+	AggregateDimensionReferrer = "referrer"
+	AggregateDimensionBrowser  = "browser"
+	AggregateDimensionDevice   = "device"
+	AggregateDimensionOS       = "os"
+	AggregateDimensionChannel  = "channel"
+)
+
+// MaxAggregateValuesPerDay bounds the distinct values counted per link, dimension and
+// day; later values are counted under AggregateOtherValue
+const MaxAggregateValuesPerDay = 100
+
+// AggregateOtherValue collects values past MaxAggregateValuesPerDay
+const AggregateOtherValue = "other"
+
+// ClickAggregate is a click counted without storing the raw event
+type ClickAggregate struct {
+	URLID     int
+	ClickedAt time.Time
+	Country   string
+	Referrer  string // Referring domain, or "direct"
+	Client    ClientInfo
+	Channel   string
+}
+
+// NewClickAggregate reduces a click to the fields kept by aggregate-only
+// analytics. Clicks from no known country count as UnknownClient.
+func NewClickAggregate(urlID int, clickedAt time.Time, country, referer string, client ClientInfo, channel string) *ClickAggregate {
+	if country == "" {
+		country = UnknownClient
+	}
+	return &ClickAggregate{
+		URLID:     urlID,
+		ClickedAt: clickedAt,
+		Country:   country,
+		Referrer:  ReferrerDomain(referer),
+		Client:    client,
+		Channel:   channel,
+	}
+}
+
+// ReferrerDomain reduces a Referer header to its host, or "direct" when there is none
+func ReferrerDomain(referer string) string {
+	parsed, err := url.Parse(referer)
+	if err != nil || parsed.Hostname() == "" {
+		return "direct"
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
+
+// AddDimensionStats appends the clicks counted for one value of a dimension to
+// the matching breakdown
+func (a *URLAnalytics) AddDimensionStats(dimension, value string, clicks int) {
+	switch dimension {
+	case AggregateDimensionCountry:
+		a.TopCountries = append(a.TopCountries, CountryStats{Country: value, Clicks: clicks})
+	case AggregateDimensionReferrer:
+		a.TopReferrers = append(a.TopReferrers, ReferrerStats{Referrer: value, Clicks: clicks})
+	case AggregateDimensionBrowser:
+		a.TopBrowsers = append(a.TopBrowsers, BrowserStats{Browser: value, Clicks: clicks})
+	case AggregateDimensionDevice:
+		a.TopDevices = append(a.TopDevices, DeviceStats{Device: value, Clicks: clicks})
+	case AggregateDimensionOS:
+		a.TopOS = append(a.TopOS, OSStats{OS: value, Clicks: clicks})
+	case AggregateDimensionChannel:
+		a.ClicksByChannel = append(a.ClicksByChannel, ChannelStats{Channel: value, Clicks: clicks})
+	}
+}
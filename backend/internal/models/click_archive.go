@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// ArchivedClickQuery selects a URL's archived click events: those clicked
+// within [Since, Until) with IDs between AfterID and BeforeID (0 for no bound),
+// up to Limit of them in ID order, newest first when Descending
+type ArchivedClickQuery struct {
+	Since      time.Time
+	Until      time.Time
+	AfterID    int
+	BeforeID   int
+	Limit      int
+	Descending bool
+}
+
+// ClickSummary counts a URL's archived clicks, weighting sampled events by the
+// clicks each stands for
+type ClickSummary struct {
+	Clicks          int
+	UniqueVisitors  int // Distinct IP addresses
+	MaxSampleWeight int
+
+	// Clicks by value of the country, referrer, browser, device, os and channel dimensions
+	Dimensions map[string]map[string]int
+
+	// Clicks per UTC hour, oldest first
+	Hourly []ClickCount
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// ClickAttributes are the extra facts click enrichers record about a click,
+// such as whether it came from an employee. Keys are chosen by the enrichers
+// a deployment registers.
+type ClickAttributes map[string]string
+
+// Set records an attribute, creating the map as needed
+func (a *ClickAttributes) Set(key, value string) {
+	if *a == nil {
+		*a = ClickAttributes{}
+	}
+	(*a)[key] = value
+}
+
+// Value implements driver.Valuer for storing attributes as JSONB
+func (a ClickAttributes) Value() (driver.Value, error) {
+	if a == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(a)
+}
+
+// Scan implements sql.Scanner for reading attributes from JSONB. Empty
+// attributes are read as nil, so they are left out of JSON responses.
+func (a *ClickAttributes) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		raw = v
+	case string:
+		raw = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ClickAttributes", value)
+	}
+
+	var attributes ClickAttributes
+	if err := json.Unmarshal(raw, &attributes); err != nil {
+		return err
+	}
+	if len(attributes) == 0 {
+		attributes = nil
+	}
+	*a = attributes
+	return nil
+}
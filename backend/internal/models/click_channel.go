@@ -0,0 +1,37 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Channels a visit to a short link arrives through
+const (
+	ClickChannelClick = "click" // Link followed, typed or pasted
+	ClickChannelScan  = "scan"  // QR code scanned
+)
+
+// QR codes encode the short link with this query marker, so their scans can
+// be told apart from clicks
+const (
+	ClickSourceParam = "src"
+	ClickSourceQR    = "qr"
+)
+
+// QRScanURL marks a short link as reached by scanning a QR code
+func QRScanURL(shortURL string) string {
+	separator := "?"
+	if strings.Contains(shortURL, "?") {
+		separator = "&"
+	}
+	return shortURL + separator + url.Values{ClickSourceParam: {ClickSourceQR}}.Encode()
+}
+
+// ClickChannelFromSource returns the channel of a visit carrying the given
+// source marker
+func ClickChannelFromSource(source string) string {
+	if strings.EqualFold(source, ClickSourceQR) {
+		return ClickChannelScan
+	}
+	return ClickChannelClick
+}
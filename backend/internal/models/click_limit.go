@@ -0,0 +1,27 @@
+package models
+
+import "fmt"
+
+// MaxClickLimit bounds how many redirects a click-limited link can allow
+const MaxClickLimit = 1000000000
+
+// HasClickLimit returns true if the link deactivates itself after a number of redirects
+func (u *URL) HasClickLimit() bool {
+	return u.MaxClicks > 0
+}
+
+// RemainingClicks returns how many redirects a click-limited link has left
+func (u *URL) RemainingClicks() int {
+	if remaining := u.MaxClicks - u.RedirectCount; remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// validateMaxClicks checks a link's click limit
+func validateMaxClicks(limit int) error {
+	if limit < 0 || limit > MaxClickLimit {
+		return fmt.Errorf("max clicks must be between 0 and %d", MaxClickLimit)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package models
+
+import "math"
+
+// ClickSampleWeight decides whether to store the event of a link's clicks-th
+// click in the current minute. Up to threshold clicks every event is stored;
+// past it only one in every 1/rate is, standing for that many clicks. It
+// returns the clicks the stored event stands for, or 0 when it is left out.
+func ClickSampleWeight(clicks, threshold int64, rate float64) int {
+	if clicks <= threshold {
+		return 1
+	}
+	weight := int64(math.Round(1 / rate))
+	if weight <= 1 {
+		return 1
+	}
+	if (clicks-threshold)%weight != 0 {
+		return 0
+	}
+	return int(weight)
+}
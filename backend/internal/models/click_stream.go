@@ -0,0 +1,101 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// clickCursorPrefix versions the click stream cursor format
+const clickCursorPrefix = "clk1:"
+
+// ClickStreamOptions selects the next page of a link's click stream
+type ClickStreamOptions struct {
+	Cursor string
+	Limit  int
+
+	// Decoded from Cursor by Validate
+	AfterID int
+}
+
+// Validate decodes the cursor and applies defaults to the options
+func (o *ClickStreamOptions) Validate() error {
+	if o.Limit <= 0 || o.Limit > 1000 {
+		o.Limit = 100
+	}
+	if o.Cursor == "" {
+		o.AfterID = 0
+		return nil
+	}
+
+	afterID, err := DecodeClickCursor(o.Cursor)
+	if err != nil {
+		return err
+	}
+	o.AfterID = afterID
+	return nil
+}
+
+// ClickStreamPage is a page of click events in the order they were recorded.
+// NextCursor resumes after the last event, or where the request left off when
+// there were no new events, so it can always be used for the next poll.
+type ClickStreamPage struct {
+	Events     []ClickEvent `json:"events"`
+	NextCursor string       `json:"next_cursor"`
+	HasMore    bool         `json:"has_more"`
+}
+
+// EncodeClickCursor returns the opaque cursor resuming a click stream after an event
+func EncodeClickCursor(eventID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(clickCursorPrefix + strconv.Itoa(eventID)))
+}
+
+// DecodeClickCursor returns the event ID a click stream cursor resumes after
+func DecodeClickCursor(cursor string) (int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(decoded), clickCursorPrefix) {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	eventID, err := strconv.Atoi(strings.TrimPrefix(string(decoded), clickCursorPrefix))
+	if err != nil || eventID < 0 {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	return eventID, nil
+}
+
+// ClickListOptions selects a page of a link's click events, newest first
+type ClickListOptions struct {
+	Cursor string
+	Limit  int
+
+	// Decoded from Cursor by Validate; 0 starts from the newest event
+	BeforeID int
+}
+
+// Validate decodes the cursor and applies defaults to the options
+func (o *ClickListOptions) Validate() error {
+	if o.Limit <= 0 || o.Limit > MaxPerPage {
+		o.Limit = DefaultPerPage
+	}
+	if o.Cursor == "" {
+		o.BeforeID = 0
+		return nil
+	}
+
+	beforeID, err := DecodeClickCursor(o.Cursor)
+	if err != nil {
+		return err
+	}
+	o.BeforeID = beforeID
+	return nil
+}
+
+// ClickPage is a page of click events, newest first. NextCursor continues with
+// older events, and is omitted on the last page.
+type ClickPage struct {
+	Events     []ClickEvent `json:"events"`
+	Limit      int          `json:"limit"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	HasMore    bool         `json:"has_more"`
+}
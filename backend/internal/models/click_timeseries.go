@@ -0,0 +1,115 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Bucket sizes of a click time series
+const (
+	TimeSeriesHour = "hour"
+	TimeSeriesDay  = "day"
+)
+
+// MaxHourlyTimeSeriesDays bounds hourly series to a month of buckets
+const MaxHourlyTimeSeriesDays = 31
+
+// ClickTimeSeriesOptions selects the buckets of a click time series
+type ClickTimeSeriesOptions struct {
+	Interval string
+	Days     int
+	Timezone string
+}
+
+// Validate validates and applies defaults to the options
+func (o *ClickTimeSeriesOptions) Validate() error {
+	switch o.Interval {
+	case "":
+		o.Interval = TimeSeriesDay
+	case TimeSeriesHour, TimeSeriesDay:
+	default:
+		return fmt.Errorf("interval must be %s or %s", TimeSeriesHour, TimeSeriesDay)
+	}
+
+	if o.Days == 0 {
+		o.Days = 30
+	}
+	if o.Days < 1 {
+		return fmt.Errorf("days must be positive")
+	}
+	if o.Interval == TimeSeriesHour && o.Days > MaxHourlyTimeSeriesDays {
+		return fmt.Errorf("hourly series cover at most %d days", MaxHourlyTimeSeriesDays)
+	}
+	return nil
+}
+
+// ClickCount is the number of clicks in the bucket starting at Time
+type ClickCount struct {
+	Time   time.Time `json:"time"`
+	Clicks int       `json:"clicks"`
+}
+
+// ClickTimeSeries is a link's clicks bucketed by hour or day in a time zone,
+// oldest first, with a point for every bucket of the window
+type ClickTimeSeries struct {
+	Interval string       `json:"interval"`
+	Days     int          `json:"days"`
+	Timezone string       `json:"timezone"`
+	Points   []ClickCount `json:"points"`
+
+	// Set when the caller's plan shortened the window
+	UpgradeRequired *UpgradeHint `json:"upgrade_required,omitempty"`
+}
+
+// TimeSeriesStart returns where a series covering the last days days in loc
+// begins: midnight days-1 days before today, so today is the last day
+func TimeSeriesStart(now time.Time, days int, loc *time.Location) time.Time {
+	local := now.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day()-(days-1), 0, 0, 0, 0, loc)
+}
+
+// NewClickTimeSeries buckets hourly UTC click counts into the series' intervals
+// in loc, from the start of the window until now. In time zones whose offset
+// isn't a whole number of hours, each hour's clicks land in the bucket where
+// the hour starts.
+func NewClickTimeSeries(interval string, days int, loc *time.Location, now time.Time, hourly []ClickCount) *ClickTimeSeries {
+	series := &ClickTimeSeries{
+		Interval: interval,
+		Days:     days,
+		Timezone: loc.String(),
+		Points:   []ClickCount{},
+	}
+
+	index := make(map[int64]int)
+	for bucket := TimeSeriesStart(now, days, loc); !bucket.After(now); bucket = nextBucket(bucket, interval, loc) {
+		index[bucket.Unix()] = len(series.Points)
+		series.Points = append(series.Points, ClickCount{Time: bucket})
+	}
+
+	for _, count := range hourly {
+		if i, ok := index[bucketStart(count.Time, interval, loc).Unix()]; ok {
+			series.Points[i].Clicks += count.Clicks
+		}
+	}
+
+	return series
+}
+
+// bucketStart returns the start of the bucket holding t
+func bucketStart(t time.Time, interval string, loc *time.Location) time.Time {
+	local := t.In(loc)
+	if interval == TimeSeriesDay {
+		return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	}
+	// Step back within the local hour rather than rebuilding the wall clock
+	// time, which is ambiguous when clocks fall back
+	return local.Add(-time.Duration(local.Minute())*time.Minute - time.Duration(local.Second())*time.Second - time.Duration(local.Nanosecond()))
+}
+
+// nextBucket returns the start of the bucket after the one starting at bucket
+func nextBucket(bucket time.Time, interval string, loc *time.Location) time.Time {
+	if interval == TimeSeriesDay {
+		return time.Date(bucket.Year(), bucket.Month(), bucket.Day()+1, 0, 0, 0, 0, loc)
+	}
+	return bucket.Add(time.Hour)
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Click trigger kinds
+const (
+	ClickTriggerReach = "reach" // Fires once when the link reaches the threshold
+	ClickTriggerEvery = "every" // Fires each time the click count is a multiple of the threshold
+)
+
+// Bounds for click triggers
+const (
+	MaxClickTriggersPerURL = 20
+	MaxClickTriggerValue   = 1000000000
+)
+
+// ClickTrigger notifies a link's webhooks when its click count crosses a threshold
+type ClickTrigger struct {
+	ID          int        `db:"id" json:"id"`
+	UserID      int        `db:"user_id" json:"user_id"`
+	URLID       int        `db:"url_id" json:"url_id"`
+	Kind        string     `db:"kind" json:"kind"`
+	Threshold   int64      `db:"threshold" json:"threshold"`
+	LastFiredAt *time.Time `db:"last_fired_at" json:"last_fired_at,omitempty"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+}
+
+// Matches returns true if the trigger should fire at the given click count
+func (t *ClickTrigger) Matches(clicks int64) bool {
+	switch t.Kind {
+	case ClickTriggerReach:
+		return t.LastFiredAt == nil && clicks >= t.Threshold
+	case ClickTriggerEvery:
+		return clicks > 0 && clicks%t.Threshold == 0
+	}
+	return false
+}
+
+// ClickThresholdEvent is the data of a link.click_threshold webhook event
+type ClickThresholdEvent struct {
+	TriggerID int    `json:"trigger_id"`
+	Kind      string `json:"kind"`
+	Threshold int64  `json:"threshold"`
+	Clicks    int64  `json:"clicks"`
+}
+
+// CreateClickTriggerRequest represents a request to add a click trigger to a link
+type CreateClickTriggerRequest struct {
+	Kind      string `json:"kind" binding:"required"`
+	Threshold int64  `json:"threshold" binding:"required"`
+}
+
+// Validate validates the create click trigger request
+func (req *CreateClickTriggerRequest) Validate() error {
+	if req.Kind != ClickTriggerReach && req.Kind != ClickTriggerEvery {
+		return fmt.Errorf("kind must be %q or %q", ClickTriggerReach, ClickTriggerEvery)
+	}
+	if req.Threshold < 1 || req.Threshold > MaxClickTriggerValue {
+		return fmt.Errorf("threshold must be between 1 and %d", MaxClickTriggerValue)
+	}
+	return nil
+}
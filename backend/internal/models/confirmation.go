@@ -0,0 +1,70 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConfirmationHeader carries the token a destructive call's preview returned
+const ConfirmationHeader = "X-Confirmation-Token"
+
+// Destructive actions that must be previewed and confirmed
+const (
+	ConfirmActionDeleteAccount      = "delete_account"
+	ConfirmActionDeleteDomain       = "delete_domain"
+	ConfirmActionDeleteLabeledLinks = "delete_labeled_links"
+)
+
+// ConfirmationPreview describes what a destructive call would do, with the
+// token that confirms it
+type ConfirmationPreview struct {
+	Action            string      `json:"action"`
+	Impact            interface{} `json:"impact"`
+	ConfirmationToken string      `json:"confirmation_token"`
+	ExpiresAt         time.Time   `json:"expires_at"`
+}
+
+// AccountDeletionImpact is what deleting an account destroys
+type AccountDeletionImpact struct {
+	Email string `json:"email"`
+	Links int    `json:"links"` // Stop redirecting; their click history is purged too
+}
+
+// DomainDeletionImpact is what deleting a custom domain changes
+type DomainDeletionImpact struct {
+	Hostname         string `json:"hostname"`
+	Verified         bool   `json:"verified"`           // Links stop resolving on the hostname
+	CustomErrorPages bool   `json:"custom_error_pages"` // Are deleted along with the domain
+	Links            int    `json:"links"`              // Move back to the default domain
+}
+
+// DeleteLabeledLinksRequest selects the user's links carrying a label value
+type DeleteLabeledLinksRequest struct {
+	Label string `json:"label" binding:"required"`
+	Value string `json:"value"`
+}
+
+// LabeledLinksImpact is what deleting the links carrying a label value destroys
+type LabeledLinksImpact struct {
+	Label      string   `json:"label"`
+	Value      string   `json:"value"`
+	Links      int      `json:"links"`
+	Clicks     int64    `json:"clicks"`
+	ShortCodes []string `json:"short_codes"` // A sample of the links, most clicked first
+}
+
+// Validate normalizes the label key as labels are stored
+func (req *DeleteLabeledLinksRequest) Validate() error {
+	req.Label = strings.ToLower(strings.TrimSpace(req.Label))
+	if !labelKeyPattern.MatchString(req.Label) {
+		return fmt.Errorf("label key %q must be 1-63 lowercase letters, digits, '_', '.' or '-'", req.Label)
+	}
+	req.Value = strings.TrimSpace(req.Value)
+	return nil
+}
+
+// Subject identifies the links the request selects within a confirmation
+func (req *DeleteLabeledLinksRequest) Subject() string {
+	return req.Label + "=" + req.Value
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DomainVerificationPrefix is the DNS label holding a custom domain's TXT verification record
+const DomainVerificationPrefix = "_urlshortener-challenge"
+
+// UnverifiedCustomDomainTTL is how long a custom domain stays on an account
+// without being verified, allowing for slow DNS propagation
+const UnverifiedCustomDomainTTL = 72 * time.Hour
+
+// MaxErrorHTMLSize caps the size of an uploaded error page snippet
+const MaxErrorHTMLSize = 64 << 10 // 64KB
+
+var hostnamePattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// CustomDomain represents a branded domain serving a user's short links
+type CustomDomain struct {
+	ID                int        `db:"id" json:"id"`
+	UserID            int        `db:"user_id" json:"user_id"`
+	Hostname          string     `db:"hostname" json:"hostname"`
+	VerificationToken string     `db:"verification_token" json:"verification_token"`
+	VerifiedAt        *time.Time `db:"verified_at" json:"verified_at,omitempty"`
+	NotFoundURL       string     `db:"not_found_url" json:"not_found_url,omitempty"`
+	ExpiredURL        string     `db:"expired_url" json:"expired_url,omitempty"`
+	ErrorHTML         string     `db:"error_html" json:"error_html,omitempty"`
+	CreatedAt         time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt         time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// IsVerified returns true once DNS ownership of the domain has been proven
+func (d *CustomDomain) IsVerified() bool {
+	return d.VerifiedAt != nil
+}
+
+// VerificationRecord returns the DNS name that must hold the verification token
+func (d *CustomDomain) VerificationRecord() string {
+	return DomainVerificationPrefix + "." + d.Hostname
+}
+
+// CreateCustomDomainRequest represents a request to add a custom domain
+type CreateCustomDomainRequest struct {
+	Hostname string `json:"hostname" binding:"required"`
+}
+
+// UpdateErrorPagesRequest represents a request to configure a domain's error pages.
+// Nil fields are left unchanged; empty strings fall back to the default pages.
+type UpdateErrorPagesRequest struct {
+	NotFoundURL *string `json:"not_found_url,omitempty"`
+	ExpiredURL  *string `json:"expired_url,omitempty"`
+	ErrorHTML   *string `json:"error_html,omitempty"`
+}
+
+// Validate validates and normalizes the create custom domain request
+func (req *CreateCustomDomainRequest) Validate() error {
+	req.Hostname = NormalizeHostname(req.Hostname)
+	if !hostnamePattern.MatchString(req.Hostname) {
+		return fmt.Errorf("hostname must be a valid domain name")
+	}
+	return nil
+}
+
+// Validate validates the update error pages request
+func (req *UpdateErrorPagesRequest) Validate() error {
+	for name, value := range map[string]*string{"not found URL": req.NotFoundURL, "expired URL": req.ExpiredURL} {
+		if value == nil || *value == "" {
+			continue
+		}
+		*value = strings.TrimSpace(*value)
+		parsed, err := url.Parse(*value)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return fmt.Errorf("%s must be a valid http or https URL", name)
+		}
+	}
+	if req.ErrorHTML != nil && len(*req.ErrorHTML) > MaxErrorHTMLSize {
+		return fmt.Errorf("error HTML must be at most %d bytes", MaxErrorHTMLSize)
+	}
+	return nil
+}
+
+// NormalizeHostname lowercases a host and strips any port and trailing dot
+func NormalizeHostname(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if i := strings.LastIndex(host, ":"); i != -1 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	return strings.TrimSuffix(host, ".")
+}
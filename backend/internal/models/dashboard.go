@@ -0,0 +1,22 @@
+package models
+
+// DashboardTopLinks is how many of the most clicked links the dashboard lists
+const DashboardTopLinks = 5
+
+// DashboardRecentLinks is how many recently clicked links the dashboard lists
+const DashboardRecentLinks = 10
+
+// Dashboard summarizes a user's links and clicks across the whole account
+type Dashboard struct {
+	TotalLinks       int `json:"total_links"`
+	ActiveLinks      int `json:"active_links"`
+	TotalClicks      int `json:"total_clicks"`
+	ClicksLast7Days  int `json:"clicks_last_7_days"`
+	ClicksLast30Days int `json:"clicks_last_30_days"`
+
+	// Most clicked links of all time
+	TopLinks []URL `json:"top_links"`
+
+	// Links clicked in the last 7 days, most recently clicked first
+	RecentActivity []URL `json:"recent_activity"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// DestinationChange records one change of a link's destination URL
+type DestinationChange struct {
+	ID              int       `db:"id" json:"id"`
+	URLID           int       `db:"url_id" json:"url_id"`
+	UserID          *int      `db:"user_id" json:"user_id,omitempty"`                       // Cleared if the user is deleted
+	GuestEditLinkID *int      `db:"guest_edit_link_id" json:"guest_edit_link_id,omitempty"` // Set when made through a guest edit link
+	PreviousURL     string    `db:"previous_url" json:"previous_url"`
+	NewURL          string    `db:"new_url" json:"new_url"`
+	HeldForReview   bool      `db:"held_for_review" json:"held_for_review"`
+	CreatedAt       time.Time `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,174 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Devices a link's device rules can target
+const (
+	DeviceTargetIOS     = "ios"     // iPhones, iPads and iPods
+	DeviceTargetAndroid = "android" // Android phones and tablets
+	DeviceTargetMobile  = "mobile"  // Phones of any system
+	DeviceTargetTablet  = "tablet"  // Tablets of any system
+	DeviceTargetDesktop = "desktop" // Desktop and laptop browsers
+)
+
+// MaxDeviceRules bounds the device rules of a link
+const MaxDeviceRules = 10
+
+// blockedDeviceRuleSchemes can run code or read local data in the visitor's browser
+var blockedDeviceRuleSchemes = []string{"javascript", "data", "vbscript", "file", "blob"}
+
+// DeviceRule sends visits from a device to another destination, such as an
+// app store listing or an app's deep link
+type DeviceRule struct {
+	Device string `json:"device"`
+	URL    string `json:"url"`
+}
+
+// DeviceRules route visits by the visitor's device, the first matching rule
+// winning. Visits matching no rule, including bots, go to the link's destination.
+type DeviceRules []DeviceRule
+
+// Validate normalizes and checks the rules. Web URLs without a scheme get https://,
+// other schemes (e.g. myapp:// or itms-apps://) are kept as deep links.
+func (r DeviceRules) Validate() error {
+	if len(r) > MaxDeviceRules {
+		return fmt.Errorf("a link can have at most %d device rules", MaxDeviceRules)
+	}
+
+	devices := make(map[string]bool, len(r))
+	for i := range r {
+		rule := &r[i]
+
+		rule.Device = strings.ToLower(strings.TrimSpace(rule.Device))
+		switch rule.Device {
+		case DeviceTargetIOS, DeviceTargetAndroid, DeviceTargetMobile, DeviceTargetTablet, DeviceTargetDesktop:
+		default:
+			return fmt.Errorf("device must be %s, %s, %s, %s or %s", DeviceTargetIOS, DeviceTargetAndroid, DeviceTargetMobile, DeviceTargetTablet, DeviceTargetDesktop)
+		}
+		if devices[rule.Device] {
+			return fmt.Errorf("device %s has more than one rule", rule.Device)
+		}
+		devices[rule.Device] = true
+
+		destination := strings.TrimSpace(rule.URL)
+		if scheme, _, found := strings.Cut(destination, ":"); !found || strings.Contains(scheme, ".") {
+			// A host such as example.com or example.com:8080
+			destination = "https://" + destination
+		}
+		parsed, err := url.Parse(destination)
+		if err != nil || parsed.Scheme == "" {
+			return fmt.Errorf("device rule for %s must have a valid URL", rule.Device)
+		}
+		scheme := strings.ToLower(parsed.Scheme)
+		for _, blocked := range blockedDeviceRuleSchemes {
+			if scheme == blocked {
+				return fmt.Errorf("device rule URLs can't use the %s scheme", scheme)
+			}
+		}
+		if (scheme == "http" || scheme == "https") && parsed.Host == "" {
+			return fmt.Errorf("device rule for %s must have a valid URL", rule.Device)
+		}
+		if parsed.Host == "" && parsed.Opaque == "" && parsed.Path == "" {
+			return fmt.Errorf("device rule for %s must have a valid URL", rule.Device)
+		}
+		rule.URL = destination
+	}
+
+	return nil
+}
+
+// WebDestinations returns the http and https URLs of the rules, which are
+// screened like the link's destination. Deep links only open apps.
+func (r DeviceRules) WebDestinations() []string {
+	var destinations []string
+	for _, rule := range r {
+		if isWebURL(rule.URL) {
+			destinations = append(destinations, rule.URL)
+		}
+	}
+	return destinations
+}
+
+// Match returns the first rule for the client's device, or nil
+func (r DeviceRules) Match(client ClientInfo) *DeviceRule {
+	for i := range r {
+		if r[i].Matches(client) {
+			return &r[i]
+		}
+	}
+	return nil
+}
+
+// Matches reports whether a client is the rule's device. Bots match no rule.
+func (rule *DeviceRule) Matches(client ClientInfo) bool {
+	if client.Device == DeviceBot || client.Device == UnknownClient {
+		return false
+	}
+	switch rule.Device {
+	case DeviceTargetIOS:
+		return client.OS == "iOS"
+	case DeviceTargetAndroid:
+		return client.OS == "Android"
+	case DeviceTargetMobile:
+		return client.Device == DeviceMobile
+	case DeviceTargetTablet:
+		return client.Device == DeviceTablet
+	case DeviceTargetDesktop:
+		return client.Device == DeviceDesktop
+	}
+	return false
+}
+
+// HasDeviceRules returns true if the link routes visits by device
+func (u *URL) HasDeviceRules() bool {
+	return len(u.DeviceRules) > 0
+}
+
+// DeviceDestination returns the destination of the link's first device rule
+// matching the client. Web destinations get the link's UTM parameters; deep
+// links are left as they are, as apps may reject unknown parameters.
+func (u *URL) DeviceDestination(client ClientInfo) (string, bool) {
+	rule := u.DeviceRules.Match(client)
+	if rule == nil {
+		return "", false
+	}
+	if !isWebURL(rule.URL) {
+		return rule.URL, true
+	}
+	return u.UTM.Tag(rule.URL), true
+}
+
+// Value implements driver.Valuer for storing device rules as JSONB
+func (r DeviceRules) Value() (driver.Value, error) {
+	if r == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(r)
+}
+
+// Scan implements sql.Scanner for reading device rules from JSONB
+func (r *DeviceRules) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	default:
+		return fmt.Errorf("cannot scan %T into DeviceRules", value)
+	}
+}
+
+// isWebURL reports whether a URL is opened by the browser rather than an app
+func isWebURL(rawURL string) bool {
+	lower := strings.ToLower(rawURL)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
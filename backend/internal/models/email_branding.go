@@ -0,0 +1,105 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Default colors used by emails without organization branding
+const (
+	DefaultEmailPrimaryColor = "#007bff"
+	DefaultEmailAccentColor  = "#f8f9fa"
+	DefaultEmailFromName     = "URL Shortener"
+)
+
+// EmailBranding holds an organization's white-label email settings
+type EmailBranding struct {
+	OrganizationID       int        `db:"organization_id" json:"organization_id"`
+	FromName             string     `db:"from_name" json:"from_name"`
+	FromAddress          string     `db:"from_address" json:"from_address"`
+	FromDomainVerifiedAt *time.Time `db:"from_domain_verified_at" json:"from_domain_verified_at,omitempty"`
+	LogoURL              string     `db:"logo_url" json:"logo_url"`
+	PrimaryColor         string     `db:"primary_color" json:"primary_color"`
+	AccentColor          string     `db:"accent_color" json:"accent_color"`
+	CreatedAt            time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt            time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// FromDomain returns the domain of the custom from-address
+func (b *EmailBranding) FromDomain() string {
+	if i := strings.LastIndex(b.FromAddress, "@"); i != -1 {
+		return strings.ToLower(b.FromAddress[i+1:])
+	}
+	return ""
+}
+
+// FromVerified returns true if the custom from-domain passed its DNS checks
+func (b *EmailBranding) FromVerified() bool {
+	return b.FromAddress != "" && b.FromDomainVerifiedAt != nil
+}
+
+// UpdateEmailBrandingRequest represents a request to change an organization's email branding.
+// Nil fields are left unchanged; empty strings restore the default.
+type UpdateEmailBrandingRequest struct {
+	FromName     *string `json:"from_name,omitempty"`
+	FromAddress  *string `json:"from_address,omitempty"`
+	LogoURL      *string `json:"logo_url,omitempty"`
+	PrimaryColor *string `json:"primary_color,omitempty"`
+	AccentColor  *string `json:"accent_color,omitempty"`
+}
+
+// Validate validates the update email branding request
+func (req *UpdateEmailBrandingRequest) Validate() error {
+	if req.FromName != nil {
+		*req.FromName = strings.TrimSpace(*req.FromName)
+		if len(*req.FromName) > 255 || strings.ContainsAny(*req.FromName, "\r\n") {
+			return fmt.Errorf("from name must be a single line of at most 255 characters")
+		}
+	}
+
+	if req.FromAddress != nil && *req.FromAddress != "" {
+		*req.FromAddress = strings.ToLower(strings.TrimSpace(*req.FromAddress))
+		addr, err := mail.ParseAddress(*req.FromAddress)
+		if err != nil || addr.Address != *req.FromAddress {
+			return fmt.Errorf("from address must be a plain email address")
+		}
+	}
+
+	if req.LogoURL != nil && *req.LogoURL != "" {
+		*req.LogoURL = strings.TrimSpace(*req.LogoURL)
+		parsed, err := url.Parse(*req.LogoURL)
+		if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+			return fmt.Errorf("logo URL must be a valid https URL")
+		}
+	}
+
+	for name, color := range map[string]*string{"primary color": req.PrimaryColor, "accent color": req.AccentColor} {
+		if color != nil && *color != "" && !hexColorPattern.MatchString(*color) {
+			return fmt.Errorf("%s must be a hex color like #1a2b3c", name)
+		}
+	}
+
+	return nil
+}
+
+// EmailDNSCheck reports one DNS record checked for a custom from-domain
+type EmailDNSCheck struct {
+	Name     string   `json:"name"`
+	Passed   bool     `json:"passed"`
+	Found    []string `json:"found,omitempty"`
+	Guidance string   `json:"guidance,omitempty"`
+}
+
+// EmailDomainVerification is the result of checking a from-domain's SPF and DKIM records
+type EmailDomainVerification struct {
+	Domain   string        `json:"domain"`
+	Verified bool          `json:"verified"`
+	SPF      EmailDNSCheck `json:"spf"`
+	DKIM     EmailDNSCheck `json:"dkim"`
+}
@@ -0,0 +1,64 @@
+package models
+
+// Email feedback providers
+const (
+	EmailProviderSES      = "ses"
+	EmailProviderSendGrid = "sendgrid"
+)
+
+// EmailFeedback is a bounce or complaint reported for a recipient
+type EmailFeedback struct {
+	Email  string `json:"email"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// EmailFeedbackResult summarizes a processed provider notification
+type EmailFeedbackResult struct {
+	Received int `json:"received"`
+	Updated  int `json:"updated"`
+}
+
+// SNSMessage is the envelope Amazon SNS posts to HTTP subscribers
+type SNSMessage struct {
+	Type         string `json:"Type"`
+	MessageID    string `json:"MessageId"`
+	TopicArn     string `json:"TopicArn"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+}
+
+// SNS message types
+const (
+	SNSTypeSubscriptionConfirmation = "SubscriptionConfirmation"
+	SNSTypeNotification             = "Notification"
+)
+
+// SESNotification is an Amazon SES bounce or complaint notification.
+// Notifications use notificationType; configuration set events use eventType.
+type SESNotification struct {
+	NotificationType string `json:"notificationType"`
+	EventType        string `json:"eventType"`
+	Bounce           *struct {
+		BounceType        string `json:"bounceType"`
+		BounceSubType     string `json:"bounceSubType"`
+		BouncedRecipients []struct {
+			EmailAddress   string `json:"emailAddress"`
+			DiagnosticCode string `json:"diagnosticCode"`
+		} `json:"bouncedRecipients"`
+	} `json:"bounce"`
+	Complaint *struct {
+		ComplaintFeedbackType string `json:"complaintFeedbackType"`
+		ComplainedRecipients  []struct {
+			EmailAddress string `json:"emailAddress"`
+		} `json:"complainedRecipients"`
+	} `json:"complaint"`
+}
+
+// SendGridEvent is one event from a SendGrid Event Webhook batch
+type SendGridEvent struct {
+	Email  string `json:"email"`
+	Event  string `json:"event"`
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bounds for a single expiration extension
+const (
+	MinExpirationExtension = time.Hour
+	MaxExpirationExtension = 365 * 24 * time.Hour
+)
+
+// ExpirationExtension records one push of a link's expiration date
+type ExpirationExtension struct {
+	ID                int       `db:"id" json:"id"`
+	URLID             int       `db:"url_id" json:"url_id"`
+	UserID            *int      `db:"user_id" json:"user_id,omitempty"` // Cleared if the user is deleted
+	PreviousExpiresAt time.Time `db:"previous_expires_at" json:"previous_expires_at"`
+	NewExpiresAt      time.Time `db:"new_expires_at" json:"new_expires_at"`
+	DurationSeconds   int64     `db:"duration_seconds" json:"duration_seconds"`
+	CreatedAt         time.Time `db:"created_at" json:"created_at"`
+}
+
+// ExtendExpirationRequest represents a request to push a link's expiration forward
+type ExtendExpirationRequest struct {
+	Duration string `json:"duration" binding:"required"` // e.g. "72h" or "30d"
+}
+
+// ParseDuration validates the requested extension and returns it
+func (req *ExtendExpirationRequest) ParseDuration() (time.Duration, error) {
+	duration, err := parseDayDuration(req.Duration)
+	if err != nil {
+		return 0, err
+	}
+
+	if duration < MinExpirationExtension || duration > MaxExpirationExtension {
+		return 0, fmt.Errorf("duration must be between 1h and 365d")
+	}
+	return duration, nil
+}
+
+// parseDayDuration parses a Go duration such as "72h", or a number of days such as "30d"
+func parseDayDuration(value string) (time.Duration, error) {
+	trimmed := strings.TrimSpace(value)
+	if days := strings.TrimSuffix(trimmed, "d"); days != trimmed {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	duration, err := time.ParseDuration(trimmed)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	return duration, nil
+}
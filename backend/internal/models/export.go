@@ -0,0 +1,107 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats of a data export
+const (
+	ExportFormatCSV  = "csv"
+	ExportFormatJSON = "json"
+)
+
+// ExportBatchSize is how many rows an export reads from the database at a time
+const ExportBatchSize = 500
+
+// ParseExportFormat validates an export format, defaulting to CSV
+func ParseExportFormat(format string) (string, error) {
+	switch format {
+	case "":
+		return ExportFormatCSV, nil
+	case ExportFormatCSV, ExportFormatJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("format must be %s or %s", ExportFormatCSV, ExportFormatJSON)
+	}
+}
+
+// URLExportColumns is the header row of a CSV link export, in ExportRecord order
+var URLExportColumns = []string{
+	"id", "short_code", "original_url", "created_at", "updated_at", "click_count",
+	"is_active", "expires_at", "last_clicked_at", "redirect_type", "notes", "labels", "title",
+}
+
+// ExportRecord returns the link as a CSV export row
+func (u *URL) ExportRecord() []string {
+	return []string{
+		strconv.Itoa(u.ID),
+		u.ShortCode,
+		csvSafe(u.OriginalURL),
+		u.CreatedAt.UTC().Format(time.RFC3339),
+		u.UpdatedAt.UTC().Format(time.RFC3339),
+		strconv.Itoa(u.ClickCount),
+		strconv.FormatBool(u.IsActive),
+		exportTime(u.ExpiresAt),
+		exportTime(u.LastClickedAt),
+		u.RedirectType,
+		csvSafe(u.Notes),
+		csvSafe(exportLabels(u.Labels)),
+		csvSafe(u.Title),
+	}
+}
+
+// ClickEventExportColumns is the header row of a CSV click export, in ExportRecord order
+var ClickEventExportColumns = []string{
+	"id", "clicked_at", "ip_address", "country", "city", "referer", "user_agent", "browser", "device", "os", "sample_weight", "attributes", "channel",
+}
+
+// ExportRecord returns the click event as a CSV export row
+func (e *ClickEvent) ExportRecord() []string {
+	return []string{
+		strconv.Itoa(e.ID),
+		e.ClickedAt.UTC().Format(time.RFC3339),
+		e.IPAddress,
+		e.Country,
+		csvSafe(e.City),
+		csvSafe(e.Referer),
+		csvSafe(e.UserAgent),
+		e.Browser,
+		e.Device,
+		e.OS,
+		strconv.Itoa(e.SampleWeight),
+		csvSafe(exportLabels(e.Attributes)),
+		e.Channel,
+	}
+}
+
+// exportTime formats an optional time for a CSV export, empty when unset
+func exportTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// exportLabels formats labels or attributes as key=value pairs in key order, separated by semicolons
+func exportLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
+
+// csvSafe neutralizes values that spreadsheets would evaluate as formulas.
+// Referers and user agents are chosen by visitors, so exports must not let
+// them run when the file is opened.
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
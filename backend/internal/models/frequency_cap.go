@@ -0,0 +1,49 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// BlockReasonFrequencyCapped is recorded for visitors sent to the alternate URL after reaching a link's frequency cap
+const BlockReasonFrequencyCapped = "frequency_capped"
+
+// MaxFrequencyCap bounds how many daily visits a frequency cap can allow
+const MaxFrequencyCap = 1000
+
+// FrequencyCap limits how many times a day the same visitor is sent to a link's destination
+type FrequencyCap struct {
+	MaxVisits   int    `json:"max_visits"`   // 0 removes the cap
+	FallbackURL string `json:"fallback_url"` // Where visitors go once they reach the cap
+}
+
+// Validate validates and normalizes the frequency cap. Zero visits clears it.
+func (f *FrequencyCap) Validate() error {
+	if f.MaxVisits == 0 {
+		f.FallbackURL = ""
+		return nil
+	}
+	if f.MaxVisits < 0 || f.MaxVisits > MaxFrequencyCap {
+		return fmt.Errorf("frequency cap must be between 0 and %d visits", MaxFrequencyCap)
+	}
+
+	f.FallbackURL = strings.TrimSpace(f.FallbackURL)
+	parsed, err := url.Parse(f.FallbackURL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Errorf("frequency cap fallback URL must be a valid http or https URL")
+	}
+
+	return nil
+}
+
+// Apply copies the cap onto a URL
+func (f *FrequencyCap) Apply(u *URL) {
+	u.FrequencyCap = f.MaxVisits
+	u.FrequencyCapURL = f.FallbackURL
+}
+
+// IsFrequencyCapped returns true if the link limits repeat visits
+func (u *URL) IsFrequencyCapped() bool {
+	return u.FrequencyCap > 0
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxCityLength is the longest city name stored with a click
+const maxCityLength = 100
+
+// maxASNDigits is the most digits of a 32-bit autonomous system number
+const maxASNDigits = 10
+
+// GeoLocation is where a visitor is, as reported by the CDN or load balancer in front of the server
+type GeoLocation struct {
+	Country string // ISO 3166-1 alpha-2 code
+	City    string
+	ASN     string // Autonomous system number of the visitor's network, without the AS prefix
+}
+
+// NewGeoLocation normalizes a reported location, dropping values that aren't
+// a usable country code, city name or ASN
+func NewGeoLocation(country, city, asn string) GeoLocation {
+	location := GeoLocation{}
+
+	country = strings.ToUpper(strings.TrimSpace(country))
+	// CDNs report XX for unknown locations and T1 for Tor exit nodes
+	if len(country) == 2 && country != "XX" && country != "T1" &&
+		country[0] >= 'A' && country[0] <= 'Z' && country[1] >= 'A' && country[1] <= 'Z' {
+		location.Country = country
+	}
+
+	city = strings.TrimSpace(city)
+	if utf8.ValidString(city) && utf8.RuneCountInString(city) <= maxCityLength {
+		location.City = city
+	}
+
+	// Accept both 13335 and AS13335
+	asn = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(asn)), "AS")
+	if asn != "" && len(asn) <= maxASNDigits && strings.Trim(asn, "0123456789") == "" {
+		location.ASN = asn
+	}
+	return location
+}
+
+// geoLocationContextKey carries the visitor's location in a request context
+type geoLocationContextKey struct{}
+
+// WithGeoLocation attaches the visitor's location to ctx
+func WithGeoLocation(ctx context.Context, location GeoLocation) context.Context {
+	return context.WithValue(ctx, geoLocationContextKey{}, location)
+}
+
+// GeoLocationFrom returns the visitor's location attached to ctx, empty when unknown
+func GeoLocationFrom(ctx context.Context) GeoLocation {
+	location, _ := ctx.Value(geoLocationContextKey{}).(GeoLocation)
+	return location
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Lifetime of a guest edit link
+const (
+	DefaultGuestEditLinkTTL = 72 * time.Hour
+	MaxGuestEditLinkTTL     = 30 * 24 * time.Hour
+)
+
+// GuestEditLink lets someone without an account change a link's destination
+// once, before it expires. Like share tokens, only a hash of its token is stored.
+type GuestEditLink struct {
+	ID          int        `db:"id" json:"id"`
+	URLID       int        `db:"url_id" json:"url_id"`
+	Name        string     `db:"name" json:"name"` // Who the owner sent it to
+	TokenHash   string     `db:"token_hash" json:"-"`
+	TokenPrefix string     `db:"token_prefix" json:"token_prefix"` // Identifies the token without revealing it
+	ExpiresAt   time.Time  `db:"expires_at" json:"expires_at"`
+	UsedAt      *time.Time `db:"used_at" json:"used_at,omitempty"`
+	RevokedAt   *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+}
+
+// CreateGuestEditLinkRequest represents a request to mint a guest edit link
+type CreateGuestEditLinkRequest struct {
+	Name      string `json:"name" binding:"required"`
+	ExpiresIn string `json:"expires_in,omitempty"` // e.g. "48h" or "7d"; 72h by default
+}
+
+// CreateGuestEditLinkResponse returns a new guest edit link, whose token is only shown once
+type CreateGuestEditLinkResponse struct {
+	GuestEditLink
+	Token   string `json:"token"`
+	EditURL string `json:"edit_url"`
+}
+
+// GuestEditView is what a guest edit link shows its holder about the link
+type GuestEditView struct {
+	ShortCode   string    `json:"short_code"`
+	ShortURL    string    `json:"short_url"`
+	OriginalURL string    `json:"original_url"`
+	Name        string    `json:"name"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// GuestEditRequest represents a guest's new destination for a link
+type GuestEditRequest struct {
+	OriginalURL string `json:"original_url" binding:"required"`
+}
+
+// IsUsable returns true if the link can still make its one edit
+func (l *GuestEditLink) IsUsable(now time.Time) bool {
+	return l.UsedAt == nil && l.RevokedAt == nil && now.Before(l.ExpiresAt)
+}
+
+// Validate validates the create guest edit link request and returns how long the link lasts
+func (req *CreateGuestEditLinkRequest) Validate() (time.Duration, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return 0, fmt.Errorf("name is required")
+	}
+	if len(req.Name) > 100 {
+		return 0, fmt.Errorf("name must be at most 100 characters long")
+	}
+
+	if strings.TrimSpace(req.ExpiresIn) == "" {
+		return DefaultGuestEditLinkTTL, nil
+	}
+	ttl, err := parseDayDuration(req.ExpiresIn)
+	if err != nil {
+		return 0, err
+	}
+	if ttl < time.Hour || ttl > MaxGuestEditLinkTTL {
+		return 0, fmt.Errorf("expires_in must be between 1h and 30d")
+	}
+	return ttl, nil
+}
+
+// Validate validates and normalizes the guest's new destination like a link update's
+func (req *GuestEditRequest) Validate() error {
+	if strings.TrimSpace(req.OriginalURL) == "" {
+		return fmt.Errorf("original_url is required")
+	}
+	update := UpdateURLRequest{OriginalURL: req.OriginalURL}
+	if err := update.Validate(); err != nil {
+		return err
+	}
+	req.OriginalURL = update.OriginalURL
+	return nil
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// HoneytokenHit is a visit to a honeytoken short code. Honeytokens are never
+// published, so whoever visits one is probing the keyspace.
+type HoneytokenHit struct {
+	ID        int       `db:"id" json:"id"`
+	Code      string    `db:"code" json:"code"`
+	IPAddress string    `db:"ip_address" json:"ip_address"`
+	ASN       string    `db:"asn" json:"asn,omitempty"`
+	UserAgent string    `db:"user_agent" json:"user_agent,omitempty"`
+	Referer   string    `db:"referer" json:"referer,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// HoneytokenHitFilter selects honeytoken hits for the admin view
+type HoneytokenHitFilter struct {
+	Code  string
+	IP    string
+	Limit int
+}
+
+// Validate validates and applies defaults to the filter
+func (f *HoneytokenHitFilter) Validate() error {
+	f.Code = strings.ToLower(strings.TrimSpace(f.Code))
+	f.IP = strings.TrimSpace(f.IP)
+	if f.Limit <= 0 || f.Limit > 200 {
+		f.Limit = 50
+	}
+	return nil
+}
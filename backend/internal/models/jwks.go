@@ -0,0 +1,23 @@
+package models
+
+// JWKS is a JSON Web Key Set (RFC 7517) publishing the keys access tokens
+// are verified with
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is the public half of an access token signing key
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// Ed25519 keys
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
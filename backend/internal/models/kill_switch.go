@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// KillSwitchTombstone is cached in place of a killed link's destination. It is
+// never a valid destination, and refills of the cache don't overwrite it.
+const KillSwitchTombstone = "!killed"
+
+// KillSwitchTombstoneTTL is how long the tombstone, and each instance's record
+// of the kill, outlive the kill. It covers cache refills by redirects that read
+// the link just before it was disabled.
+const KillSwitchTombstoneTTL = 5 * time.Minute
+
+// KillSwitchChannel is the Redis pub/sub channel kills are broadcast on
+const KillSwitchChannel = "link_kills"
+
+// KillSwitchResult reports how far disabling a link has propagated
+type KillSwitchResult struct {
+	ShortCode          string    `json:"short_code"`
+	Disabled           bool      `json:"disabled"`             // Saved as inactive in the database
+	CacheCleared       bool      `json:"cache_cleared"`        // Cached destination replaced by the tombstone
+	InstancesNotified  int64     `json:"instances_notified"`   // Instances that received the broadcast, including this one
+	Propagated         bool      `json:"propagated"`           // The cache was cleared and the broadcast delivered
+	TombstoneExpiresAt time.Time `json:"tombstone_expires_at"` // Re-enabling the link takes full effect after this
+	ElapsedMS          int64     `json:"elapsed_ms"`
+	Errors             []string  `json:"errors,omitempty"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Limits on the page metadata stored for a link's destination
+const (
+	MaxPageTitleLength       = 300
+	MaxPageDescriptionLength = 1000
+	MaxFaviconURLLength      = 2048
+)
+
+// LinkMetadata is what the destination page says about itself: its title,
+// description and favicon, as last fetched. It's cleared whenever the
+// destination changes, so it never describes a previous destination.
+type LinkMetadata struct {
+	PageTitle         string     `db:"page_title" json:"page_title,omitempty"`
+	PageDescription   string     `db:"page_description" json:"page_description,omitempty"`
+	FaviconURL        string     `db:"favicon_url" json:"favicon_url,omitempty"`
+	MetadataFetchedAt *time.Time `db:"metadata_fetched_at" json:"metadata_fetched_at,omitempty"`
+}
+
+// MetadataTarget is an active link whose destination's metadata is due for a refresh
+type MetadataTarget struct {
+	ID          int
+	ShortCode   string
+	OriginalURL string
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits on a link's title, internal notes and labels
+const (
+	MaxLinkTitleLength      = 200
+	MaxLinkNotesLength      = 5000
+	MaxLinkLabels           = 20
+	MaxLinkLabelValueLength = 200
+)
+
+// labelKeyPattern matches label keys such as "team" or "cost-center.id"
+var labelKeyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,62}$`)
+
+// LinkLabels are internal key/value labels on a link, e.g. {"team": "growth"}.
+// Like notes they are only shown to the link's owner, never on preview pages
+// or other public surfaces.
+type LinkLabels map[string]string
+
+// Validate normalizes label keys to lower case and checks the labels
+func (l *LinkLabels) Validate() error {
+	if len(*l) > MaxLinkLabels {
+		return fmt.Errorf("a link can have at most %d labels", MaxLinkLabels)
+	}
+
+	normalized := make(LinkLabels, len(*l))
+	for key, value := range *l {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("label key %q must be 1-63 lowercase letters, digits, '_', '.' or '-'", key)
+		}
+		value = strings.TrimSpace(value)
+		if utf8.RuneCountInString(value) > MaxLinkLabelValueLength {
+			return fmt.Errorf("label %q must be at most %d characters", key, MaxLinkLabelValueLength)
+		}
+		if _, exists := normalized[key]; exists {
+			return fmt.Errorf("label %q is set more than once", key)
+		}
+		normalized[key] = value
+	}
+	*l = normalized
+	return nil
+}
+
+// Value implements driver.Valuer for storing labels as JSONB
+func (l LinkLabels) Value() (driver.Value, error) {
+	if l == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(l)
+}
+
+// Scan implements sql.Scanner for reading labels from JSONB
+func (l *LinkLabels) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	default:
+		return fmt.Errorf("cannot scan %T into LinkLabels", value)
+	}
+}
+
+// normalizeLinkTitle trims a link's title and checks it is a single line of limited length
+func normalizeLinkTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if utf8.RuneCountInString(title) > MaxLinkTitleLength {
+		return "", fmt.Errorf("title must be at most %d characters", MaxLinkTitleLength)
+	}
+	if strings.ContainsAny(title, "\r\n") {
+		return "", fmt.Errorf("title must be a single line")
+	}
+	return title, nil
+}
+
+// normalizeLinkNotes trims a link's internal notes and checks their length
+func normalizeLinkNotes(notes string) (string, error) {
+	notes = strings.TrimSpace(notes)
+	if utf8.RuneCountInString(notes) > MaxLinkNotesLength {
+		return "", fmt.Errorf("notes must be at most %d characters", MaxLinkNotesLength)
+	}
+	return notes, nil
+}
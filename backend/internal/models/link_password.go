@@ -0,0 +1,77 @@
+package models
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Reasons recorded for rejected unlock attempts on password-protected links
+const (
+	BlockReasonPassword       = "password"        // Wrong password
+	BlockReasonPasswordLocked = "password_locked" // Attempt made while the client was locked out
+	BlockReasonShareToken     = "share_token"     // Unknown or revoked share token
+)
+
+// MinLinkPasswordLength is the shortest password accepted for a link
+const MinLinkPasswordLength = 4
+
+// UnlockURLRequest represents a request to unlock a password-protected link
+// with either its password or one of its share tokens
+type UnlockURLRequest struct {
+	Password   string `json:"password,omitempty"`
+	ShareToken string `json:"share_token,omitempty"`
+}
+
+// Validate validates the unlock request
+func (req *UnlockURLRequest) Validate() error {
+	if req.Password == "" && req.ShareToken == "" {
+		return fmt.Errorf("password or share_token is required")
+	}
+	return nil
+}
+
+// UnlockURLResponse returns the destination of an unlocked link
+type UnlockURLResponse struct {
+	ShortCode   string `json:"short_code"`
+	OriginalURL string `json:"original_url"`
+}
+
+// IsPasswordProtected returns true if the link requires a password
+func (u *URL) IsPasswordProtected() bool {
+	return u.PasswordHash != ""
+}
+
+// SetPassword hashes and stores the link password; an empty password removes protection
+func (u *URL) SetPassword(password string) error {
+	if password == "" {
+		u.PasswordHash = ""
+		u.PasswordProtected = false
+		return nil
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash link password: %w", err)
+	}
+	u.PasswordHash = string(hashedPassword)
+	u.PasswordProtected = true
+	return nil
+}
+
+// CheckPassword checks if the provided password unlocks the link
+func (u *URL) CheckPassword(password string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
+	return err == nil
+}
+
+// validateLinkPassword checks the length of a new link password
+func validateLinkPassword(password string) error {
+	if len(password) < MinLinkPasswordLength {
+		return fmt.Errorf("link password must be at least %d characters long", MinLinkPasswordLength)
+	}
+	if len(password) > 72 {
+		return fmt.Errorf("link password must be at most 72 characters long")
+	}
+	return nil
+}
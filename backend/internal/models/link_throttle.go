@@ -0,0 +1,22 @@
+package models
+
+import "fmt"
+
+// BlockReasonThrottled is recorded for redirects rejected by a link's click rate limit
+const BlockReasonThrottled = "throttled"
+
+// MaxClicksPerMinuteLimit bounds the per-link click rate limit
+const MaxClicksPerMinuteLimit = 1000000
+
+// IsThrottled returns true if the link caps its redirect rate
+func (u *URL) IsThrottled() bool {
+	return u.MaxClicksPerMinute > 0
+}
+
+// validateMaxClicksPerMinute checks a per-link click rate limit
+func validateMaxClicksPerMinute(limit int) error {
+	if limit < 0 || limit > MaxClicksPerMinuteLimit {
+		return fmt.Errorf("max clicks per minute must be between 0 and %d", MaxClicksPerMinuteLimit)
+	}
+	return nil
+}
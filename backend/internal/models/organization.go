@@ -0,0 +1,168 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Organization groups users under shared settings such as email branding
+type Organization struct {
+	ID         int       `db:"id" json:"id"`
+	Name       string    `db:"name" json:"name"`
+	OwnerID    int       `db:"owner_id" json:"owner_id"`
+	DataRegion string    `db:"data_region" json:"data_region"` // Empty for the home region
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at" json:"updated_at"`
+
+	// Custom codes starting with the prefix and a hyphen are only for members,
+	// who may be required to use it
+	CodePrefix        string `db:"code_prefix" json:"code_prefix,omitempty"`
+	RequireCodePrefix bool   `db:"require_code_prefix" json:"require_code_prefix"`
+}
+
+// Bounds on an organization's short code prefix
+const (
+	MinCodePrefixLength = 2
+	MaxCodePrefixLength = 12
+)
+
+// CodePrefixSeparator joins a code prefix to the rest of a custom code
+const CodePrefixSeparator = "-"
+
+// IsOwner returns true if the user owns the organization
+func (o *Organization) IsOwner(userID int) bool {
+	return o.OwnerID == userID
+}
+
+// CodeNamespace returns how the organization's custom codes start, e.g. "acme-",
+// or "" without a code prefix
+func (o *Organization) CodeNamespace() string {
+	if o.CodePrefix == "" {
+		return ""
+	}
+	return o.CodePrefix + CodePrefixSeparator
+}
+
+// CodePrefixOf returns the lower-cased part of a short code before its first
+// hyphen, or "" for codes without one
+func CodePrefixOf(code string) string {
+	prefix, _, found := strings.Cut(code, CodePrefixSeparator)
+	if !found {
+		return ""
+	}
+	return strings.ToLower(prefix)
+}
+
+// CreateOrganizationRequest represents a request to create an organization
+type CreateOrganizationRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// OrganizationInvitationTTL is how long an invitation to join an organization can be accepted
+const OrganizationInvitationTTL = 7 * 24 * time.Hour
+
+// OrganizationInvitation invites the account with an email to join an organization.
+// Users only become members once they accept.
+type OrganizationInvitation struct {
+	ID               int       `json:"id"`
+	OrganizationID   int       `json:"organization_id"`
+	OrganizationName string    `json:"organization_name"`
+	Email            string    `json:"email"`
+	InvitedBy        *int      `json:"invited_by,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// AddOrganizationMemberRequest represents a request to invite a user to an organization
+type AddOrganizationMemberRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// SetDataRegionRequest represents a request to choose where an organization's data is stored
+type SetDataRegionRequest struct {
+	Region string `json:"region" binding:"required"`
+}
+
+// SetCodePrefixRequest represents a request to reserve a short code prefix for an organization
+type SetCodePrefixRequest struct {
+	Prefix   string `json:"prefix"`   // e.g. "acme" or "acme-*"; empty releases the prefix
+	Required bool   `json:"required"` // Members' custom codes must use the prefix
+}
+
+// CodePrefixRollup sums up the links in an organization's code namespace
+type CodePrefixRollup struct {
+	Namespace     string              `json:"namespace"`
+	Links         int                 `json:"links"`
+	ActiveLinks   int                 `json:"active_links"`
+	Clicks        int64               `json:"clicks"`
+	LastClickedAt *time.Time          `json:"last_clicked_at,omitempty"`
+	TopLinks      []PrefixLinkStats   `json:"top_links"`
+	Members       []PrefixMemberStats `json:"members"`
+}
+
+// PrefixLinkStats is one of the most clicked links of a code namespace
+type PrefixLinkStats struct {
+	ShortCode   string `json:"short_code"`
+	OriginalURL string `json:"original_url"`
+	UserID      int    `json:"user_id"`
+	Clicks      int64  `json:"clicks"`
+}
+
+// PrefixMemberStats sums up a member's links in a code namespace
+type PrefixMemberStats struct {
+	UserID int   `json:"user_id"`
+	Links  int   `json:"links"`
+	Clicks int64 `json:"clicks"`
+}
+
+// OrganizationMember is a user as listed within their organization
+type OrganizationMember struct {
+	UserID    int    `json:"user_id"`
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	IsOwner   bool   `json:"is_owner"`
+}
+
+// Validate validates the create organization request
+func (req *CreateOrganizationRequest) Validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	if len(req.Name) < 2 || len(req.Name) > 255 {
+		return fmt.Errorf("organization name must be between 2 and 255 characters")
+	}
+	return nil
+}
+
+// Validate validates the add member request
+func (req *AddOrganizationMemberRequest) Validate() error {
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if req.Email == "" {
+		return fmt.Errorf("email is required")
+	}
+	return nil
+}
+
+// Validate validates and normalizes the code prefix request
+func (req *SetCodePrefixRequest) Validate() error {
+	prefix := strings.ToLower(strings.TrimSpace(req.Prefix))
+	prefix = strings.TrimSuffix(strings.TrimSuffix(prefix, "*"), CodePrefixSeparator)
+	req.Prefix = prefix
+	if prefix == "" {
+		req.Required = false
+		return nil
+	}
+
+	if len(prefix) < MinCodePrefixLength || len(prefix) > MaxCodePrefixLength {
+		return fmt.Errorf("code prefix must be between %d and %d characters", MinCodePrefixLength, MaxCodePrefixLength)
+	}
+	for _, char := range prefix {
+		if !((char >= 'a' && char <= 'z') || (char >= '0' && char <= '9')) {
+			return fmt.Errorf("code prefix must contain only alphanumeric characters")
+		}
+	}
+	if ContainsBlockedWord(prefix) {
+		return fmt.Errorf("code prefix contains a blocked word")
+	}
+	return nil
+}
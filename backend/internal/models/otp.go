@@ -2,20 +2,35 @@ package models
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
+// OTP email delivery statuses
+const (
+	OTPDeliveryQueued  = "queued"
+	OTPDeliverySent    = "sent"
+	OTPDeliveryFailed  = "failed"
+	OTPDeliveryBounced = "bounced"
+)
+
 // OTPVerification represents an OTP verification record
 type OTPVerification struct {
 	ID         int        `db:"id" json:"id"`
 	UserID     int        `db:"user_id" json:"user_id"`
 	Email      string     `db:"email" json:"email"`
-	OTPCode    string     `db:"otp_code" json:"otp_code"`
+	OTPCode    string     `db:"otp_code" json:"-"`
 	Purpose    string     `db:"purpose" json:"purpose"`
 	IsVerified bool       `db:"is_verified" json:"is_verified"`
 	ExpiresAt  time.Time  `db:"expires_at" json:"expires_at"`
 	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
 	VerifiedAt *time.Time `db:"verified_at" json:"verified_at,omitempty"`
+
+	// Delivery of the email carrying the code
+	DeliveryStatus    string     `db:"delivery_status" json:"delivery_status"`
+	DeliveryError     string     `db:"delivery_error" json:"delivery_error,omitempty"`
+	DeliveryAttempts  int        `db:"delivery_attempts" json:"delivery_attempts"`
+	DeliveryUpdatedAt *time.Time `db:"delivery_updated_at" json:"delivery_updated_at,omitempty"`
 }
 
 // OTPRequest represents a request to generate OTP
@@ -33,8 +48,31 @@ type OTPVerifyRequest struct {
 
 // OTPResponse represents the response after OTP generation
 type OTPResponse struct {
-	Message   string    `json:"message"`
-	ExpiresAt time.Time `json:"expires_at"`
+	Message        string    `json:"message"`
+	ExpiresAt      time.Time `json:"expires_at"`
+	DeliveryID     int       `json:"delivery_id"`
+	DeliveryStatus string    `json:"delivery_status"`
+}
+
+// OTPDeliveryFilter selects OTP records for the delivery admin view
+type OTPDeliveryFilter struct {
+	Email  string
+	Status string
+	Limit  int
+}
+
+// Validate validates and applies defaults to the filter
+func (f *OTPDeliveryFilter) Validate() error {
+	f.Email = strings.ToLower(strings.TrimSpace(f.Email))
+	switch f.Status {
+	case "", OTPDeliveryQueued, OTPDeliverySent, OTPDeliveryFailed, OTPDeliveryBounced:
+	default:
+		return fmt.Errorf("status must be one of %s, %s, %s, %s", OTPDeliveryQueued, OTPDeliverySent, OTPDeliveryFailed, OTPDeliveryBounced)
+	}
+	if f.Limit <= 0 || f.Limit > 200 {
+		f.Limit = 50
+	}
+	return nil
 }
 
 // OTPVerifyResponse represents the response after OTP verification
@@ -0,0 +1,32 @@
+package models
+
+// Per-page bounds shared by offset-paginated list endpoints
+const (
+	DefaultPerPage = 20
+	MaxPerPage     = 100
+)
+
+// Pagination describes the page of a list response and links to its neighbors.
+// Next and Prev are omitted on the last and first page.
+type Pagination struct {
+	Page       int    `json:"page"`
+	PerPage    int    `json:"per_page"`
+	Total      int    `json:"total"`
+	TotalPages int    `json:"total_pages"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+}
+
+// NewPagination describes the window of limit items starting at offset in a
+// list of total items. Links are left for the caller to fill in.
+func NewPagination(limit, offset, total int) *Pagination {
+	pagination := &Pagination{
+		Page:    offset/limit + 1,
+		PerPage: limit,
+		Total:   total,
+	}
+	if total > 0 {
+		pagination.TotalPages = (total + limit - 1) / limit
+	}
+	return pagination
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxUTMValueLength caps the length of each UTM value
+const maxUTMValueLength = 255
+
+// UTMParams holds UTM tracking parameters
+type UTMParams struct {
+	Source   string `json:"utm_source,omitempty"`
+	Medium   string `json:"utm_medium,omitempty"`
+	Campaign string `json:"utm_campaign,omitempty"`
+	Term     string `json:"utm_term,omitempty"`
+	Content  string `json:"utm_content,omitempty"`
+}
+
+// CampaignStats summarizes a user's links tagged with one UTM campaign, source and medium
+type CampaignStats struct {
+	Campaign string `db:"campaign" json:"utm_campaign"`
+	Source   string `db:"source" json:"utm_source,omitempty"`
+	Medium   string `db:"medium" json:"utm_medium,omitempty"`
+	Links    int    `db:"links" json:"links"`
+	Clicks   int64  `db:"clicks" json:"clicks"`
+}
+
+// UserPreferences holds a user's personal settings for new links
+type UserPreferences struct {
+	UserID      int       `db:"user_id" json:"-"`
+	UTMDefaults UTMParams `db:"utm_defaults" json:"utm_defaults"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+
+	// Presets for new links that don't set their own value
+	DefaultExpiryDays   int        `db:"default_expiry_days" json:"default_expiry_days,omitempty"` // Expire links this many days after creation (0 uses the server default)
+	DefaultRedirectType string     `db:"default_redirect_type" json:"default_redirect_type,omitempty"`
+	DefaultLabels       LinkLabels `db:"default_labels" json:"default_labels,omitempty"`
+	QRStyle             QRStyle    `db:"qr_style" json:"qr_style"`
+	DefaultDomain       string     `db:"default_domain" json:"default_domain,omitempty"` // Verified custom domain of new links (empty uses the default domain)
+}
+
+// UpdatePreferencesRequest replaces a user's presets for new links
+type UpdatePreferencesRequest struct {
+	UTMDefaults         UTMParams  `json:"utm_defaults"`
+	DefaultExpiryDays   int        `json:"default_expiry_days,omitempty"`
+	DefaultRedirectType string     `json:"default_redirect_type,omitempty"`
+	DefaultLabels       LinkLabels `json:"default_labels,omitempty"`
+	QRStyle             QRStyle    `json:"qr_style"`
+	DefaultDomain       string     `json:"default_domain,omitempty"`
+}
+
+// MaxDefaultExpiryDays bounds the default expiration of a user's new links
+const MaxDefaultExpiryDays = 3650
+
+// Validate validates and normalizes the update preferences request
+func (req *UpdatePreferencesRequest) Validate() error {
+	if err := req.UTMDefaults.Validate(); err != nil {
+		return err
+	}
+	if req.DefaultExpiryDays < 0 || req.DefaultExpiryDays > MaxDefaultExpiryDays {
+		return fmt.Errorf("default expiry days must be between 0 and %d", MaxDefaultExpiryDays)
+	}
+	if err := validateRedirectType(req.DefaultRedirectType); err != nil {
+		return err
+	}
+	if err := req.DefaultLabels.Validate(); err != nil {
+		return err
+	}
+	req.DefaultDomain = NormalizeHostname(req.DefaultDomain)
+	return req.QRStyle.Validate()
+}
+
+// Apply copies the requested presets onto the preferences
+func (req *UpdatePreferencesRequest) Apply(preferences *UserPreferences) {
+	preferences.UTMDefaults = req.UTMDefaults
+	preferences.DefaultExpiryDays = req.DefaultExpiryDays
+	preferences.DefaultRedirectType = req.DefaultRedirectType
+	preferences.DefaultLabels = req.DefaultLabels
+	preferences.QRStyle = req.QRStyle
+	preferences.DefaultDomain = req.DefaultDomain
+}
+
+// ApplyTo fills in the parts of a new link the request leaves unset. Labels
+// are merged, with the request's value winning for a key set in both. The
+// default expiration of a scheduled link counts from its activation.
+func (p *UserPreferences) ApplyTo(req *CreateURLRequest, now time.Time) error {
+	if req.ExpiresAt.Time == nil && p.DefaultExpiryDays > 0 {
+		expiresAt := req.LiveFrom(now).AddDate(0, 0, p.DefaultExpiryDays)
+		req.ExpiresAt.Time = &expiresAt
+	}
+	if req.RedirectType == RedirectTypeDefault {
+		req.RedirectType = p.DefaultRedirectType
+	}
+	if req.Domain == "" {
+		req.Domain = p.DefaultDomain
+	}
+
+	if len(p.DefaultLabels) > 0 {
+		labels := make(LinkLabels, len(p.DefaultLabels)+len(req.Labels))
+		for key, value := range p.DefaultLabels {
+			labels[key] = value
+		}
+		for key, value := range req.Labels {
+			labels[key] = value
+		}
+		if len(labels) > MaxLinkLabels {
+			return fmt.Errorf("a link can have at most %d labels, including your default labels", MaxLinkLabels)
+		}
+		req.Labels = labels
+	}
+	return nil
+}
+
+// IsEmpty returns true if no UTM parameter is set
+func (p UTMParams) IsEmpty() bool {
+	return p.Source == "" && p.Medium == "" && p.Campaign == "" && p.Term == "" && p.Content == ""
+}
+
+// Validate trims and validates the UTM values
+func (p *UTMParams) Validate() error {
+	for name, value := range p.fields() {
+		*value = strings.TrimSpace(*value)
+		if len(*value) > maxUTMValueLength {
+			return fmt.Errorf("%s must be at most %d characters long", name, maxUTMValueLength)
+		}
+	}
+	return nil
+}
+
+// ApplyTo adds the UTM parameters to a URL. Parameters already present on the URL are kept.
+func (p UTMParams) ApplyTo(rawURL string) (string, error) {
+	if p.IsEmpty() {
+		return rawURL, nil
+	}
+
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL format: %w", err)
+	}
+
+	// Append only the missing parameters so the existing query string is left untouched
+	existing := parsedURL.Query()
+	additions := url.Values{}
+	for name, value := range p.fields() {
+		if *value != "" && existing.Get(name) == "" {
+			additions.Set(name, *value)
+		}
+	}
+	if len(additions) == 0 {
+		return rawURL, nil
+	}
+
+	if parsedURL.RawQuery == "" {
+		parsedURL.RawQuery = additions.Encode()
+	} else {
+		parsedURL.RawQuery += "&" + additions.Encode()
+	}
+	return parsedURL.String(), nil
+}
+
+// Tag adds the UTM parameters to a destination, leaving it unchanged if it can't be parsed
+func (p UTMParams) Tag(rawURL string) string {
+	tagged, err := p.ApplyTo(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return tagged
+}
+
+// fields maps query parameter names to the UTM values
+func (p *UTMParams) fields() map[string]*string {
+	return map[string]*string{
+		"utm_source":   &p.Source,
+		"utm_medium":   &p.Medium,
+		"utm_campaign": &p.Campaign,
+		"utm_term":     &p.Term,
+		"utm_content":  &p.Content,
+	}
+}
+
+// Value implements driver.Valuer for storing UTM parameters as JSONB
+func (p UTMParams) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+// Scan implements sql.Scanner for reading UTM parameters from JSONB
+func (p *UTMParams) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*p = UTMParams{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return fmt.Errorf("cannot scan %T into UTMParams", value)
+	}
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QR batch statuses
+const (
+	QRBatchPending   = "pending"
+	QRBatchCompleted = "completed"
+	QRBatchFailed    = "failed"
+	QRBatchExpired   = "expired" // Completed, with the archive deleted
+)
+
+// Bounds for a bulk QR code request
+const (
+	MaxQRBatchCodes    = 500
+	DefaultQRBatchSize = 512
+	MinQRBatchSize     = 64
+	MaxQRBatchSize     = 2048
+)
+
+// MaxPendingQRBatches is how many batches a user can have waiting to be rendered
+const MaxPendingQRBatches = 3
+
+// QRBatchArchiveTTL is how long a completed batch's archive is kept
+const QRBatchArchiveTTL = 7 * 24 * time.Hour
+
+// QRBatch is a set of QR codes rendered in the background into a ZIP archive
+type QRBatch struct {
+	ID          int        `db:"id" json:"id"`
+	UserID      int        `db:"user_id" json:"user_id"`
+	ShortCodes  []string   `db:"short_codes" json:"short_codes"`
+	Size        int        `db:"size" json:"size"`
+	Status      string     `db:"status" json:"status"`
+	Error       *string    `db:"error" json:"error,omitempty"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	CompletedAt *time.Time `db:"completed_at" json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `db:"-" json:"expires_at,omitempty"` // When a completed batch's archive is deleted
+}
+
+// CreateQRBatchRequest selects the links to render, either by short code or by UTM campaign
+type CreateQRBatchRequest struct {
+	ShortCodes []string `json:"short_codes,omitempty"`
+	Campaign   string   `json:"campaign,omitempty"` // Matches the utm_campaign of the links' destinations
+	Size       int      `json:"size,omitempty"`     // Image width in pixels
+}
+
+// Validate validates and normalizes the create QR batch request
+func (req *CreateQRBatchRequest) Validate() error {
+	req.Campaign = strings.TrimSpace(req.Campaign)
+	if len(req.ShortCodes) == 0 && req.Campaign == "" {
+		return fmt.Errorf("short_codes or campaign is required")
+	}
+	if len(req.ShortCodes) > 0 && req.Campaign != "" {
+		return fmt.Errorf("short_codes and campaign cannot be combined")
+	}
+
+	seen := make(map[string]bool, len(req.ShortCodes))
+	shortCodes := make([]string, 0, len(req.ShortCodes))
+	for _, shortCode := range req.ShortCodes {
+		shortCode = strings.TrimSpace(shortCode)
+		if shortCode == "" || seen[shortCode] {
+			continue
+		}
+		seen[shortCode] = true
+		shortCodes = append(shortCodes, shortCode)
+	}
+	if len(shortCodes) > MaxQRBatchCodes {
+		return fmt.Errorf("a batch can contain at most %d short codes", MaxQRBatchCodes)
+	}
+	req.ShortCodes = shortCodes
+
+	if req.Size == 0 {
+		req.Size = DefaultQRBatchSize
+	}
+	if req.Size < MinQRBatchSize || req.Size > MaxQRBatchSize {
+		return fmt.Errorf("size must be between %d and %d pixels", MinQRBatchSize, MaxQRBatchSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,332 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// QR payload types
+const (
+	QRPayloadVCard = "vcard" // Contact card
+	QRPayloadWiFi  = "wifi"  // Wi-Fi network credentials
+	QRPayloadEvent = "event" // Calendar event
+)
+
+// QR code modes of a payload
+const (
+	QRModeLanding = "landing" // Encodes the payload's short link, so scans are counted
+	QRModeDirect  = "direct"  // Encodes the payload itself; scans can't be counted
+)
+
+// QRLandingPath is where a payload's landing page is served, followed by its token
+const QRLandingPath = "/api/v1/qr/"
+
+// QRLandingGrantParam carries the grant letting a visit of a password-protected
+// or click-limited link into its landing page, valid once for QRLandingGrantTTL
+const (
+	QRLandingGrantParam = "grant"
+	QRLandingGrantTTL   = 5 * time.Minute
+)
+
+// Wi-Fi security types, as used in WIFI: QR codes
+const (
+	WiFiSecurityWPA  = "WPA"
+	WiFiSecurityWEP  = "WEP"
+	WiFiSecurityNone = "nopass"
+)
+
+// maxQRPayloadField bounds the length of each text field of a payload
+const maxQRPayloadField = 500
+
+// QRPayload is a non-URL QR code (contact card, Wi-Fi network or calendar
+// event) served through a short link, so scans are tracked like clicks
+type QRPayload struct {
+	ID        int           `db:"id" json:"id"`
+	UserID    int           `db:"user_id" json:"user_id"`
+	URLID     int           `db:"url_id" json:"url_id"`
+	Token     string        `db:"token" json:"-"`
+	Type      string        `db:"type" json:"type"`
+	Data      QRPayloadData `db:"data" json:"data"`
+	CreatedAt time.Time     `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time     `db:"updated_at" json:"updated_at"`
+
+	// From the payload's short link
+	ShortCode string `db:"short_code" json:"short_code"`
+	Scans     int64  `db:"click_count" json:"scans"`
+	Domain    string `db:"domain" json:"-"`
+	ShortURL  string `db:"-" json:"short_url"`
+	QRCodeURL string `db:"-" json:"qr_code_url"`
+}
+
+// QRPayloadData holds the content of a payload. Only the section of its type is set.
+type QRPayloadData struct {
+	VCard *VCard         `json:"vcard,omitempty"`
+	WiFi  *WiFiNetwork   `json:"wifi,omitempty"`
+	Event *CalendarEvent `json:"event,omitempty"`
+}
+
+// VCard is a contact card
+type VCard struct {
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name,omitempty"`
+	Organization string `json:"organization,omitempty"`
+	Title        string `json:"title,omitempty"`
+	Email        string `json:"email,omitempty"`
+	Phone        string `json:"phone,omitempty"`
+	Website      string `json:"website,omitempty"`
+	Address      string `json:"address,omitempty"`
+	Note         string `json:"note,omitempty"`
+}
+
+// WiFiNetwork holds the credentials of a Wi-Fi network
+type WiFiNetwork struct {
+	SSID     string `json:"ssid"`
+	Password string `json:"password,omitempty"`
+	Security string `json:"security"` // WPA, WEP or nopass
+	Hidden   bool   `json:"hidden,omitempty"`
+}
+
+// CalendarEvent is a calendar entry
+type CalendarEvent struct {
+	Title       string    `json:"title"`
+	Location    string    `json:"location,omitempty"`
+	Description string    `json:"description,omitempty"`
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+}
+
+// CreateQRPayloadRequest creates a payload with the section matching its type
+type CreateQRPayloadRequest struct {
+	Type       string `json:"type" binding:"required"`
+	CustomCode string `json:"custom_code,omitempty"` // Of the payload's short link
+	QRPayloadData
+}
+
+// UpdateQRPayloadRequest replaces a payload's content. The type can't change.
+type UpdateQRPayloadRequest struct {
+	QRPayloadData
+}
+
+// Validate validates and normalizes the create QR payload request
+func (req *CreateQRPayloadRequest) Validate() error {
+	req.CustomCode = strings.TrimSpace(req.CustomCode)
+	return req.QRPayloadData.Validate(req.Type)
+}
+
+// Validate checks the data holds exactly the section of a payload type, and normalizes it
+func (d *QRPayloadData) Validate(payloadType string) error {
+	sections := 0
+	for _, set := range []bool{d.VCard != nil, d.WiFi != nil, d.Event != nil} {
+		if set {
+			sections++
+		}
+	}
+	if sections > 1 {
+		return fmt.Errorf("only the %s section can be set", payloadType)
+	}
+
+	switch payloadType {
+	case QRPayloadVCard:
+		if d.VCard == nil {
+			return fmt.Errorf("vcard is required")
+		}
+		return d.VCard.Validate()
+	case QRPayloadWiFi:
+		if d.WiFi == nil {
+			return fmt.Errorf("wifi is required")
+		}
+		return d.WiFi.Validate()
+	case QRPayloadEvent:
+		if d.Event == nil {
+			return fmt.Errorf("event is required")
+		}
+		return d.Event.Validate()
+	default:
+		return fmt.Errorf("type must be %s, %s or %s", QRPayloadVCard, QRPayloadWiFi, QRPayloadEvent)
+	}
+}
+
+// Validate validates and normalizes a contact card
+func (v *VCard) Validate() error {
+	fields := []*string{&v.FirstName, &v.LastName, &v.Organization, &v.Title, &v.Email, &v.Phone, &v.Website, &v.Address, &v.Note}
+	if err := trimPayloadFields(fields); err != nil {
+		return err
+	}
+	if v.FirstName == "" && v.Organization == "" {
+		return fmt.Errorf("vcard needs a first_name or organization")
+	}
+	if v.Email != "" {
+		if _, err := mail.ParseAddress(v.Email); err != nil {
+			return fmt.Errorf("vcard email is invalid")
+		}
+	}
+	return nil
+}
+
+// Validate validates and normalizes Wi-Fi credentials
+func (w *WiFiNetwork) Validate() error {
+	if err := trimPayloadFields([]*string{&w.SSID}); err != nil {
+		return err
+	}
+	if w.SSID == "" {
+		return fmt.Errorf("wifi ssid is required")
+	}
+	if len(w.Password) > maxQRPayloadField {
+		return fmt.Errorf("wifi password must be at most %d characters", maxQRPayloadField)
+	}
+
+	switch strings.ToUpper(w.Security) {
+	case "", WiFiSecurityWPA:
+		w.Security = WiFiSecurityWPA
+	case WiFiSecurityWEP:
+		w.Security = WiFiSecurityWEP
+	case "NOPASS", "NONE":
+		w.Security = WiFiSecurityNone
+	default:
+		return fmt.Errorf("wifi security must be %s, %s or %s", WiFiSecurityWPA, WiFiSecurityWEP, WiFiSecurityNone)
+	}
+
+	if w.Security == WiFiSecurityNone {
+		w.Password = ""
+	} else if w.Password == "" {
+		return fmt.Errorf("wifi password is required for %s networks", w.Security)
+	}
+	return nil
+}
+
+// Validate validates and normalizes a calendar event
+func (e *CalendarEvent) Validate() error {
+	if err := trimPayloadFields([]*string{&e.Title, &e.Location, &e.Description}); err != nil {
+		return err
+	}
+	if e.Title == "" {
+		return fmt.Errorf("event title is required")
+	}
+	if e.StartsAt.IsZero() || e.EndsAt.IsZero() {
+		return fmt.Errorf("event starts_at and ends_at are required")
+	}
+	if !e.EndsAt.After(e.StartsAt) {
+		return fmt.Errorf("event ends_at must be after starts_at")
+	}
+	return nil
+}
+
+// trimPayloadFields trims text fields and checks their length
+func trimPayloadFields(fields []*string) error {
+	for _, field := range fields {
+		*field = strings.TrimSpace(*field)
+		if utf8.RuneCountInString(*field) > maxQRPayloadField {
+			return fmt.Errorf("payload fields must be at most %d characters", maxQRPayloadField)
+		}
+	}
+	return nil
+}
+
+// Value implements driver.Valuer for storing payload data as JSONB
+func (d QRPayloadData) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+// Scan implements sql.Scanner for reading payload data from JSONB
+func (d *QRPayloadData) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, d)
+	case string:
+		return json.Unmarshal([]byte(v), d)
+	default:
+		return fmt.Errorf("cannot scan %T into QRPayloadData", value)
+	}
+}
+
+// Encode returns the standard text encoding of the payload: vCard 3.0, the
+// WIFI: format or iCalendar
+func (p *QRPayload) Encode() string {
+	switch {
+	case p.Data.VCard != nil:
+		return p.Data.VCard.Encode()
+	case p.Data.WiFi != nil:
+		return p.Data.WiFi.Encode()
+	case p.Data.Event != nil:
+		return p.Data.Event.Encode(fmt.Sprintf("qr-payload-%d", p.ID))
+	}
+	return ""
+}
+
+// Encode returns the contact card in vCard 3.0 format
+func (v *VCard) Encode() string {
+	var b strings.Builder
+	line := func(name, value string) {
+		if value != "" {
+			b.WriteString(name + ":" + value + "\r\n")
+		}
+	}
+
+	fullName := strings.TrimSpace(v.FirstName + " " + v.LastName)
+	if fullName == "" {
+		fullName = v.Organization
+	}
+
+	b.WriteString("BEGIN:VCARD\r\nVERSION:3.0\r\n")
+	b.WriteString("N:" + escapeVText(v.LastName) + ";" + escapeVText(v.FirstName) + ";;;\r\n")
+	line("FN", escapeVText(fullName))
+	line("ORG", escapeVText(v.Organization))
+	line("TITLE", escapeVText(v.Title))
+	line("EMAIL;TYPE=INTERNET", escapeVText(v.Email))
+	line("TEL;TYPE=CELL", escapeVText(v.Phone))
+	line("URL", escapeVText(v.Website))
+	if v.Address != "" {
+		line("ADR", ";;"+escapeVText(v.Address)+";;;;")
+	}
+	line("NOTE", escapeVText(v.Note))
+	b.WriteString("END:VCARD\r\n")
+	return b.String()
+}
+
+// Encode returns the network in the WIFI: format phone cameras join directly
+func (w *WiFiNetwork) Encode() string {
+	escape := strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, `:`, `\:`, `"`, `\"`)
+
+	var b strings.Builder
+	b.WriteString("WIFI:T:" + w.Security + ";S:" + escape.Replace(w.SSID) + ";")
+	if w.Password != "" {
+		b.WriteString("P:" + escape.Replace(w.Password) + ";")
+	}
+	if w.Hidden {
+		b.WriteString("H:true;")
+	}
+	b.WriteString(";")
+	return b.String()
+}
+
+// Encode returns the event as an iCalendar file with the given unique ID
+func (e *CalendarEvent) Encode(uid string) string {
+	const layout = "20060102T150405Z"
+
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//url-shortener//QR event//EN\r\n")
+	b.WriteString("BEGIN:VEVENT\r\n")
+	b.WriteString("UID:" + uid + "\r\n")
+	b.WriteString("DTSTAMP:" + time.Now().UTC().Format(layout) + "\r\n")
+	b.WriteString("DTSTART:" + e.StartsAt.UTC().Format(layout) + "\r\n")
+	b.WriteString("DTEND:" + e.EndsAt.UTC().Format(layout) + "\r\n")
+	b.WriteString("SUMMARY:" + escapeVText(e.Title) + "\r\n")
+	if e.Location != "" {
+		b.WriteString("LOCATION:" + escapeVText(e.Location) + "\r\n")
+	}
+	if e.Description != "" {
+		b.WriteString("DESCRIPTION:" + escapeVText(e.Description) + "\r\n")
+	}
+	b.WriteString("END:VEVENT\r\nEND:VCALENDAR\r\n")
+	return b.String()
+}
+
+// escapeVText escapes a text value for vCard and iCalendar
+func escapeVText(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
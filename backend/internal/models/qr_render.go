@@ -0,0 +1,161 @@
+package models
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+)
+
+// qrLogoScale is the share of the code's width a logo may cover. The logo
+// hides about 4% of the modules, well within what high error correction recovers.
+const qrLogoScale = 0.2
+
+// EncodePNG renders content as a PNG QR code in this style, with the logo, if
+// any, drawn in the center
+func (s QRStyle) EncodePNG(content string, logo image.Image) ([]byte, error) {
+	modules, err := s.modules(content)
+	if err != nil {
+		return nil, err
+	}
+	foreground, background, err := s.colors()
+	if err != nil {
+		return nil, err
+	}
+
+	// Codes too dense for the size get one pixel per module
+	size := s.size()
+	if size < len(modules) {
+		size = len(modules)
+	}
+
+	// Map each pixel to the nearest module, like the encoder's own images
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	modulesPerPixel := float64(len(modules)) / float64(size)
+	for y := 0; y < size; y++ {
+		row := modules[int(float64(y)*modulesPerPixel)]
+		for x := 0; x < size; x++ {
+			if row[int(float64(x)*modulesPerPixel)] {
+				img.Set(x, y, foreground)
+			}
+		}
+	}
+
+	if logo != nil {
+		box := logoBox(logo.Bounds(), size)
+		pad := int(1 / modulesPerPixel) // One module of background around the logo
+		draw.Draw(img, box.Inset(-pad), image.NewUniform(background), image.Point{}, draw.Src)
+		draw.Draw(img, box, scaleImage(logo, box.Dx(), box.Dy()), image.Point{}, draw.Over)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeSVG renders content as an SVG QR code in this style, with the logo, if
+// any, embedded as a PNG in the center. The image is drawn in modules and
+// scaled to the style's size, so it stays sharp at any size.
+func (s QRStyle) EncodeSVG(content string, logo image.Image) ([]byte, error) {
+	modules, err := s.modules(content)
+	if err != nil {
+		return nil, err
+	}
+	foreground, background, err := s.colors()
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(modules)
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n", s.size(), s.size(), n, n)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"/>`+"\n", n, n, hexColor(background))
+
+	// One path of horizontal runs of dark modules
+	buf.WriteString(`<path fill="` + hexColor(foreground) + `" d="`)
+	for y, row := range modules {
+		for x := 0; x < n; x++ {
+			if !row[x] {
+				continue
+			}
+			run := 1
+			for x+run < n && row[x+run] {
+				run++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", x, y, run, run)
+			x += run
+		}
+	}
+	buf.WriteString("\"/>\n")
+
+	if logo != nil {
+		// Lay the logo out on a grid of 1000 units per module, then scale it back down
+		const unit = 1000
+		box := logoBox(logo.Bounds(), n*unit)
+		var encoded bytes.Buffer
+		if err := png.Encode(&encoded, logo); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n",
+			svgUnits(box.Min.X-unit, unit), svgUnits(box.Min.Y-unit, unit),
+			svgUnits(box.Dx()+2*unit, unit), svgUnits(box.Dy()+2*unit, unit), hexColor(background))
+		fmt.Fprintf(&buf, `<image x="%s" y="%s" width="%s" height="%s" href="data:image/png;base64,%s"/>`+"\n",
+			svgUnits(box.Min.X, unit), svgUnits(box.Min.Y, unit),
+			svgUnits(box.Dx(), unit), svgUnits(box.Dy(), unit), base64.StdEncoding.EncodeToString(encoded.Bytes()))
+	}
+
+	buf.WriteString("</svg>\n")
+	return buf.Bytes(), nil
+}
+
+// logoBox returns where a logo with the given bounds goes in a square code
+// size units wide: centered, keeping its aspect ratio
+func logoBox(bounds image.Rectangle, size int) image.Rectangle {
+	maxSide := int(float64(size) * qrLogoScale)
+	width, height := maxSide, maxSide
+	if bounds.Dx() > bounds.Dy() {
+		height = maxSide * bounds.Dy() / bounds.Dx()
+	} else if bounds.Dy() > bounds.Dx() {
+		width = maxSide * bounds.Dx() / bounds.Dy()
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	corner := image.Pt((size-width)/2, (size-height)/2)
+	return image.Rectangle{Min: corner, Max: corner.Add(image.Pt(width, height))}
+}
+
+// scaleImage resizes an image by nearest-neighbor sampling
+func scaleImage(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy := bounds.Min.Y + y*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			dst.Set(x, y, src.At(bounds.Min.X+x*bounds.Dx()/width, sy))
+		}
+	}
+	return dst
+}
+
+// hexColor formats a color as #rrggbb
+func hexColor(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
+
+// svgUnits formats a length measured in 1/unit modules as modules
+func svgUnits(value, unit int) string {
+	return fmt.Sprintf("%g", float64(value)/float64(unit))
+}
@@ -0,0 +1,212 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// Bounds and defaults of a QR code style
+const (
+	DefaultQRCodeSize   = 256
+	MinQRCodeSize       = 64
+	MaxQRCodeSize       = 2048
+	DefaultQRCodeMargin = 4 // Modules of quiet zone the QR standard asks for
+	MaxQRCodeMargin     = 16
+	MaxQRLogoURLLength  = 2048
+)
+
+// QR code image formats
+const (
+	QRFormatPNG = "png"
+	QRFormatSVG = "svg"
+)
+
+// QR code error correction levels, from the smallest code to the most damage tolerant
+const (
+	QRErrorCorrectionLow     = "low"
+	QRErrorCorrectionMedium  = "medium"
+	QRErrorCorrectionHigh    = "high"
+	QRErrorCorrectionHighest = "highest"
+)
+
+// qrRecoveryLevels maps error correction levels to the encoder's recovery levels
+var qrRecoveryLevels = map[string]qrcode.RecoveryLevel{
+	QRErrorCorrectionLow:     qrcode.Low,
+	QRErrorCorrectionMedium:  qrcode.Medium,
+	QRErrorCorrectionHigh:    qrcode.High,
+	QRErrorCorrectionHighest: qrcode.Highest,
+}
+
+// QRStyle is how a link's QR code is drawn. Unset fields keep the defaults:
+// 256 pixels, medium error correction (high with a logo), black on white,
+// with a 4 module margin and no logo.
+type QRStyle struct {
+	Size            int    `json:"size,omitempty"`             // Image width in pixels
+	ErrorCorrection string `json:"error_correction,omitempty"` // low, medium, high or highest
+	Foreground      string `json:"foreground,omitempty"`       // Hex color of the modules, e.g. #1a73e8
+	Background      string `json:"background,omitempty"`       // Hex color of the background
+	Margin          *int   `json:"margin,omitempty"`           // Quiet zone around the code, in modules
+	Logo            string `json:"logo,omitempty"`             // URL of a PNG, JPEG or GIF image drawn in the center
+}
+
+// Override returns the style with the fields set in overrides replacing its own
+func (s QRStyle) Override(overrides QRStyle) QRStyle {
+	if overrides.Size != 0 {
+		s.Size = overrides.Size
+	}
+	if overrides.ErrorCorrection != "" {
+		s.ErrorCorrection = overrides.ErrorCorrection
+	}
+	if overrides.Foreground != "" {
+		s.Foreground = overrides.Foreground
+	}
+	if overrides.Background != "" {
+		s.Background = overrides.Background
+	}
+	if overrides.Margin != nil {
+		s.Margin = overrides.Margin
+	}
+	if overrides.Logo != "" {
+		s.Logo = overrides.Logo
+	}
+	return s
+}
+
+// Validate normalizes and checks the style
+func (s *QRStyle) Validate() error {
+	if s.Size != 0 && (s.Size < MinQRCodeSize || s.Size > MaxQRCodeSize) {
+		return fmt.Errorf("QR code size must be between %d and %d pixels", MinQRCodeSize, MaxQRCodeSize)
+	}
+
+	s.ErrorCorrection = strings.ToLower(strings.TrimSpace(s.ErrorCorrection))
+	if _, ok := qrRecoveryLevels[s.ErrorCorrection]; s.ErrorCorrection != "" && !ok {
+		return fmt.Errorf("QR error correction must be %s, %s, %s or %s",
+			QRErrorCorrectionLow, QRErrorCorrectionMedium, QRErrorCorrectionHigh, QRErrorCorrectionHighest)
+	}
+
+	for name, value := range map[string]*string{"foreground": &s.Foreground, "background": &s.Background} {
+		*value = strings.ToLower(strings.TrimSpace(*value))
+		if len(*value) == 6 {
+			// Query parameters are easier to write without the #
+			*value = "#" + *value
+		}
+		if _, err := parseHexColor(*value); *value != "" && err != nil {
+			return fmt.Errorf("QR %s color must be a hex color such as #1a73e8", name)
+		}
+	}
+
+	if s.Margin != nil && (*s.Margin < 0 || *s.Margin > MaxQRCodeMargin) {
+		return fmt.Errorf("QR code margin must be between 0 and %d modules", MaxQRCodeMargin)
+	}
+
+	s.Logo = strings.TrimSpace(s.Logo)
+	if s.Logo != "" {
+		if len(s.Logo) > MaxQRLogoURLLength {
+			return fmt.Errorf("QR logo URL must be at most %d characters", MaxQRLogoURLLength)
+		}
+		parsed, err := url.Parse(s.Logo)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("QR logo must be an http or https URL")
+		}
+		// The logo hides part of the code, which only high error correction recovers
+		if s.ErrorCorrection == QRErrorCorrectionLow || s.ErrorCorrection == QRErrorCorrectionMedium {
+			return fmt.Errorf("QR codes with a logo need %s or %s error correction", QRErrorCorrectionHigh, QRErrorCorrectionHighest)
+		}
+	}
+	return nil
+}
+
+// modules encodes content in this style, returning the code's modules
+// (true where dark) surrounded by the style's margin
+func (s QRStyle) modules(content string) ([][]bool, error) {
+	level, ok := qrRecoveryLevels[s.ErrorCorrection]
+	if !ok {
+		level = qrcode.Medium
+		if s.Logo != "" {
+			level = qrcode.High
+		}
+	}
+	code, err := qrcode.New(content, level)
+	if err != nil {
+		return nil, err
+	}
+	code.DisableBorder = true
+	symbol := code.Bitmap()
+
+	margin := DefaultQRCodeMargin
+	if s.Margin != nil {
+		margin = *s.Margin
+	}
+	modules := make([][]bool, len(symbol)+2*margin)
+	for y := range modules {
+		modules[y] = make([]bool, len(symbol)+2*margin)
+		if y >= margin && y < margin+len(symbol) {
+			copy(modules[y][margin:], symbol[y-margin])
+		}
+	}
+	return modules, nil
+}
+
+// colors returns the style's foreground and background colors
+func (s QRStyle) colors() (color.Color, color.Color, error) {
+	foreground, background := color.Color(color.Black), color.Color(color.White)
+	var err error
+	if s.Foreground != "" {
+		if foreground, err = parseHexColor(s.Foreground); err != nil {
+			return nil, nil, err
+		}
+	}
+	if s.Background != "" {
+		if background, err = parseHexColor(s.Background); err != nil {
+			return nil, nil, err
+		}
+	}
+	return foreground, background, nil
+}
+
+// size returns the style's image width in pixels
+func (s QRStyle) size() int {
+	if s.Size == 0 {
+		return DefaultQRCodeSize
+	}
+	return s.Size
+}
+
+// parseHexColor parses a #rrggbb color
+func parseHexColor(value string) (color.Color, error) {
+	if len(value) != 7 || value[0] != '#' {
+		return nil, fmt.Errorf("invalid color %q", value)
+	}
+	rgb, err := strconv.ParseUint(value[1:], 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid color %q", value)
+	}
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 0xff}, nil
+}
+
+// Value implements driver.Valuer for storing the style as JSONB
+func (s QRStyle) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan implements sql.Scanner for reading the style from JSONB
+func (s *QRStyle) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*s = QRStyle{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	default:
+		return fmt.Errorf("cannot scan %T into QRStyle", value)
+	}
+}
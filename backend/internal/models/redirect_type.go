@@ -0,0 +1,47 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Redirect types of a link. The default redirects permanently unless the link
+// must be re-evaluated on every visit; browsers cache permanent redirects, so
+// links whose destination may change should use a temporary type.
+const (
+	RedirectTypeDefault = ""
+	RedirectType301     = "301"
+	RedirectType302     = "302"
+	RedirectType307     = "307"
+	RedirectTypeMeta    = "meta" // An HTML page redirecting with meta refresh and JavaScript
+)
+
+// validateRedirectType checks a link's redirect type
+func validateRedirectType(redirectType string) error {
+	switch redirectType {
+	case RedirectTypeDefault, RedirectType301, RedirectType302, RedirectType307, RedirectTypeMeta:
+		return nil
+	}
+	return fmt.Errorf("redirect type must be %s, %s, %s or %s", RedirectType301, RedirectType302, RedirectType307, RedirectTypeMeta)
+}
+
+// HasTemporaryRedirect returns true if the link is configured to redirect
+// with something other than a permanent redirect
+func (u *URL) HasTemporaryRedirect() bool {
+	return u.RedirectType != RedirectTypeDefault && u.RedirectType != RedirectType301
+}
+
+// RedirectStatus returns the HTTP status of a redirect to the link. Links that
+// must be re-evaluated on every visit never redirect permanently.
+func (u *URL) RedirectStatus(mustRevalidate bool) int {
+	switch u.RedirectType {
+	case RedirectType302:
+		return http.StatusFound
+	case RedirectType307:
+		return http.StatusTemporaryRedirect
+	}
+	if mustRevalidate {
+		return http.StatusFound
+	}
+	return http.StatusMovedPermanently
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Referrer rule modes
+const (
+	ReferrerModeAllow = "allow" // Only redirect when the referrer matches a listed domain
+	ReferrerModeDeny  = "deny"  // Never redirect when the referrer matches a listed domain
+)
+
+// Reasons recorded for blocked redirect attempts
+const (
+	BlockReasonReferrer = "referrer"
+)
+
+// ReferrerRules restricts which referrers a link redirects for
+type ReferrerRules struct {
+	Mode        string   `json:"mode"`
+	Domains     []string `json:"domains"`
+	FallbackURL string   `json:"fallback_url,omitempty"` // Where rejected visitors go; empty shows the not-found page
+}
+
+// BlockedClick records a redirect attempt rejected by a link's access rules
+type BlockedClick struct {
+	ID        int       `db:"id" json:"id"`
+	URLId     int       `db:"url_id" json:"url_id"`
+	Reason    string    `db:"reason" json:"reason"`
+	IPAddress string    `db:"ip_address" json:"ip_address"`
+	UserAgent string    `db:"user_agent" json:"user_agent"`
+	Referer   string    `db:"referer" json:"referer"`
+	BlockedAt time.Time `db:"blocked_at" json:"blocked_at"`
+}
+
+// Validate validates and normalizes referrer rules. An empty mode clears the rules.
+func (r *ReferrerRules) Validate() error {
+	r.Mode = strings.ToLower(strings.TrimSpace(r.Mode))
+	if r.Mode == "" {
+		r.Domains = nil
+		r.FallbackURL = ""
+		return nil
+	}
+	if r.Mode != ReferrerModeAllow && r.Mode != ReferrerModeDeny {
+		return fmt.Errorf("referrer mode must be either allow or deny")
+	}
+
+	domains := make([]string, 0, len(r.Domains))
+	for _, domain := range r.Domains {
+		if normalized := normalizeReferrerDomain(domain); normalized != "" {
+			domains = append(domains, normalized)
+		}
+	}
+	if len(domains) == 0 {
+		return fmt.Errorf("at least one referrer domain is required")
+	}
+	r.Domains = domains
+
+	r.FallbackURL = strings.TrimSpace(r.FallbackURL)
+	if r.FallbackURL != "" {
+		parsed, err := url.Parse(r.FallbackURL)
+		if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return fmt.Errorf("referrer fallback URL must be a valid http or https URL")
+		}
+	}
+
+	return nil
+}
+
+// Apply copies the rules onto a URL
+func (r *ReferrerRules) Apply(u *URL) {
+	u.ReferrerMode = r.Mode
+	u.ReferrerDomains = r.Domains
+	u.ReferrerFallbackURL = r.FallbackURL
+}
+
+// ReferrerAllowed reports whether a redirect with the given Referer header passes the link's rules
+func (u *URL) ReferrerAllowed(referer string) bool {
+	if u.ReferrerMode == "" {
+		return true
+	}
+
+	matched := false
+	if host := refererHost(referer); host != "" {
+		for _, domain := range u.ReferrerDomains {
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				matched = true
+				break
+			}
+		}
+	}
+
+	if u.ReferrerMode == ReferrerModeAllow {
+		return matched
+	}
+	return !matched
+}
+
+// normalizeReferrerDomain reduces a domain or URL to a lowercase host without "www."
+func normalizeReferrerDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if strings.Contains(domain, "://") {
+		if parsed, err := url.Parse(domain); err == nil {
+			domain = parsed.Hostname()
+		}
+	}
+	domain = strings.TrimSuffix(strings.SplitN(domain, "/", 2)[0], ".")
+	return strings.TrimPrefix(domain, "www.")
+}
+
+// refererHost extracts the normalized host from a Referer header
+func refererHost(referer string) string {
+	if referer == "" {
+		return ""
+	}
+	parsed, err := url.Parse(referer)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Where a reserved short code comes from
+const (
+	ReservedCodeSourceRoute  = "route"  // A top-level path of the application
+	ReservedCodeSourceConfig = "config" // The RESERVED_CODES setting
+	ReservedCodeSourceAdmin  = "admin"  // Added through the admin API
+)
+
+// MaxReservedCodeReasonLength caps the note explaining a reservation
+const MaxReservedCodeReasonLength = 200
+
+// ReservedCode is a short code no link can take. Codes are reserved ignoring case.
+// A honeytoken is a reserved code that is never published, so that visits to
+// it give away clients enumerating short codes.
+type ReservedCode struct {
+	Code       string     `db:"code" json:"code"`
+	Source     string     `db:"-" json:"source"`
+	Reason     string     `db:"reason" json:"reason,omitempty"`
+	Honeytoken bool       `db:"honeytoken" json:"honeytoken,omitempty"`
+	CreatedAt  *time.Time `db:"created_at" json:"created_at,omitempty"` // Only set for codes added through the admin API
+}
+
+// ReserveCodeRequest represents an operator's request to reserve a short code
+type ReserveCodeRequest struct {
+	Code       string `json:"code" binding:"required"`
+	Reason     string `json:"reason,omitempty"`
+	Honeytoken bool   `json:"honeytoken,omitempty"`
+}
+
+// Validate validates and normalizes the reserve code request
+func (req *ReserveCodeRequest) Validate() error {
+	code, err := NormalizeReservedCode(req.Code)
+	if err != nil {
+		return err
+	}
+	req.Code = code
+
+	req.Reason = strings.TrimSpace(req.Reason)
+	if len(req.Reason) > MaxReservedCodeReasonLength {
+		return fmt.Errorf("reason must be at most %d characters", MaxReservedCodeReasonLength)
+	}
+	return nil
+}
+
+// NormalizeReservedCode lower-cases a code to reserve and checks it could be a short code
+func NormalizeReservedCode(code string) (string, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" || len(code) > MaxShortCodeLength {
+		return "", fmt.Errorf("code must be between 1 and %d characters", MaxShortCodeLength)
+	}
+	for _, char := range code {
+		if !((char >= 'a' && char <= 'z') || (char >= '0' && char <= '9') || char == '-') {
+			return "", fmt.Errorf("code must contain only alphanumeric characters and hyphens")
+		}
+	}
+	return code, nil
+}
+
+// blockedCodeWords are profanities no short code may contain. They match
+// anywhere in a code, so only words that are rarely part of harmless ones are listed.
+var blockedCodeWords = []string{
+	"asshole", "bastard", "bitch", "bollock", "cunt", "fuck", "shit", "slut", "twat", "wank", "whore",
+}
+
+// leetReplacer undoes the digit substitutions used to slip words past a filter
+var leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "-", "")
+
+// ContainsBlockedWord reports whether a short code spells out a profanity,
+// ignoring case, hyphens and digits standing in for letters
+func ContainsBlockedWord(code string) bool {
+	normalized := leetReplacer.Replace(strings.ToLower(code))
+	for _, word := range blockedCodeWords {
+		if strings.Contains(normalized, word) {
+			return true
+		}
+	}
+	return false
+}
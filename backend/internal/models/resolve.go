@@ -0,0 +1,35 @@
+package models
+
+// Statuses of a resolved link
+const (
+	ResolveStatusActive    = "active"
+	ResolveStatusExpired   = "expired"
+	ResolveStatusInactive  = "inactive"
+	ResolveStatusScheduled = "scheduled"
+)
+
+// Safety verdicts of a resolved link
+const (
+	SafetyClean         = "clean"          // Not flagged by threat scans or admins
+	SafetySuspicious    = "suspicious"     // Visitors see a warning page before the destination
+	SafetyMalicious     = "malicious"      // Taken down because its destination was flagged
+	SafetyPendingReview = "pending_review" // Held for review before it can redirect
+)
+
+// ResolvedLink describes where a short link goes without following it, for
+// chat platforms and mail filters expanding links. The destination is only
+// disclosed while the link redirects and isn't password protected or click limited.
+type ResolvedLink struct {
+	ShortCode         string `json:"short_code"`
+	ShortURL          string `json:"short_url"`
+	Status            string `json:"status"`
+	Destination       string `json:"destination,omitempty"`
+	PasswordProtected bool   `json:"password_protected,omitempty"`
+	ClickLimited      bool   `json:"click_limited,omitempty"`
+	Safety            string `json:"safety"`
+	ThreatType        string `json:"threat_type,omitempty"`
+
+	// Whether the destination was picked for the requesting client, by its
+	// device or split test variant, so shared caches mustn't keep it
+	PerVisitor bool `json:"-"`
+}
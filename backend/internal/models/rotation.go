@@ -0,0 +1,97 @@
+package models
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Rotation modes of rotator links
+const (
+	RotationModeSequential = "sequential" // Round robin in list order
+	RotationModeRandom     = "random"
+)
+
+// Bounds on a rotator link's destinations
+const (
+	MinRotationDestinations = 2
+	MaxRotationDestinations = 50
+)
+
+// Rotation turns a link into a rotator that sends each visit to the next (or a
+// random) destination of a list, e.g. to spread leads across sales reps
+type Rotation struct {
+	Mode         string   `json:"mode"` // Empty turns rotation off
+	Destinations []string `json:"destinations"`
+}
+
+// LinkDestination is one destination of a rotator link with its click count
+type LinkDestination struct {
+	ID            int        `db:"id" json:"id"`
+	URLID         int        `db:"url_id" json:"url_id"`
+	Position      int        `db:"position" json:"position"`
+	URL           string     `db:"destination_url" json:"url"`
+	ClickCount    int64      `db:"click_count" json:"click_count"`
+	LastClickedAt *time.Time `db:"last_clicked_at" json:"last_clicked_at,omitempty"`
+	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
+}
+
+// Validate validates and normalizes the rotation. An empty mode clears it.
+func (r *Rotation) Validate() error {
+	switch r.Mode {
+	case "":
+		r.Destinations = nil
+		return nil
+	case RotationModeSequential, RotationModeRandom:
+	default:
+		return fmt.Errorf("rotation mode must be %s or %s", RotationModeSequential, RotationModeRandom)
+	}
+
+	if len(r.Destinations) < MinRotationDestinations || len(r.Destinations) > MaxRotationDestinations {
+		return fmt.Errorf("rotation needs between %d and %d destinations", MinRotationDestinations, MaxRotationDestinations)
+	}
+
+	seen := make(map[string]bool, len(r.Destinations))
+	for i, destination := range r.Destinations {
+		destination = strings.TrimSpace(destination)
+		if !strings.HasPrefix(destination, "http://") && !strings.HasPrefix(destination, "https://") {
+			destination = "https://" + destination
+		}
+
+		parsed, err := url.Parse(destination)
+		if err != nil || parsed.Host == "" {
+			return fmt.Errorf("rotation destination %d must be a valid URL", i+1)
+		}
+		if seen[destination] {
+			return fmt.Errorf("rotation destination %s is listed more than once", destination)
+		}
+		seen[destination] = true
+		r.Destinations[i] = destination
+	}
+
+	return nil
+}
+
+// Apply copies the rotation mode onto a URL. Destinations are stored separately.
+func (r *Rotation) Apply(u *URL) {
+	u.RotationMode = r.Mode
+}
+
+// IsRotator returns true if the link cycles through several destinations
+func (u *URL) IsRotator() bool {
+	return u.RotationMode != ""
+}
+
+// PickDestination chooses the destination of a visit. Sequential rotation uses
+// the link's visit sequence number (starting at 1); random rotation ignores it.
+func PickDestination(destinations []LinkDestination, mode string, sequence int64) *LinkDestination {
+	if len(destinations) == 0 {
+		return nil
+	}
+	if mode == RotationModeSequential && sequence > 0 {
+		return &destinations[(sequence-1)%int64(len(destinations))]
+	}
+	return &destinations[rand.IntN(len(destinations))]
+}
@@ -0,0 +1,18 @@
+package models
+
+import "context"
+
+// sandboxContextKey marks the context of a request made with a sandbox API key
+type sandboxContextKey struct{}
+
+// WithSandbox marks ctx as serving a sandbox request: writes are validated and
+// answered as usual but never persisted, and count toward no quota
+func WithSandbox(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sandboxContextKey{}, true)
+}
+
+// IsSandbox reports whether ctx serves a sandbox request
+func IsSandbox(ctx context.Context) bool {
+	sandbox, _ := ctx.Value(sandboxContextKey{}).(bool)
+	return sandbox
+}
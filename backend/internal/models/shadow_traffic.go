@@ -0,0 +1,95 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Shadow traffic limits. Mirroring is a debugging aid, so it always switches itself off.
+const (
+	DefaultShadowTrafficDuration = 24 * time.Hour
+	MaxShadowTrafficDuration     = 7 * 24 * time.Hour
+)
+
+// ShadowEventClick is the event name of mirrored clicks
+const ShadowEventClick = "shadow.click"
+
+// ShadowTraffic mirrors a copy of each click's metadata to an endpoint, for
+// testing downstream analytics systems against real traffic
+type ShadowTraffic struct {
+	URL      string `json:"url"`                // Empty turns mirroring off
+	Duration string `json:"duration,omitempty"` // How long to mirror, e.g. "2h" (default 24h)
+
+	duration time.Duration
+}
+
+// ShadowClick is the payload mirrored for a click. It holds what the link's
+// analytics store, so aggregate-only installs send no visitor details.
+type ShadowClick struct {
+	Event     string    `json:"event"`
+	ShortCode string    `json:"short_code"`
+	ClickedAt time.Time `json:"clicked_at"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Referer   string    `json:"referer,omitempty"`
+	Country   string    `json:"country,omitempty"`
+}
+
+// Validate validates and normalizes the shadow traffic settings. An empty URL clears them.
+func (s *ShadowTraffic) Validate() error {
+	s.URL = strings.TrimSpace(s.URL)
+	if s.URL == "" {
+		return nil
+	}
+
+	parsed, err := url.Parse(s.URL)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Errorf("shadow traffic URL must be a valid http or https URL")
+	}
+
+	s.duration = DefaultShadowTrafficDuration
+	if s.Duration != "" {
+		s.duration, err = time.ParseDuration(s.Duration)
+		if err != nil {
+			return fmt.Errorf("shadow traffic duration must be a duration such as 2h")
+		}
+	}
+	if s.duration <= 0 || s.duration > MaxShadowTrafficDuration {
+		return fmt.Errorf("shadow traffic duration must be positive and at most %s", MaxShadowTrafficDuration)
+	}
+
+	return nil
+}
+
+// Apply copies the settings onto a URL, mirroring from now for the validated duration
+func (s *ShadowTraffic) Apply(u *URL, now time.Time) {
+	if s.URL == "" {
+		u.ShadowURL = ""
+		u.ShadowUntil = nil
+		return
+	}
+
+	until := now.Add(s.duration)
+	u.ShadowURL = s.URL
+	u.ShadowUntil = &until
+}
+
+// IsMirroring returns true if the link's clicks are currently mirrored
+func (u *URL) IsMirroring(now time.Time) bool {
+	return u.ShadowURL != "" && u.ShadowUntil != nil && now.Before(*u.ShadowUntil)
+}
+
+// NewShadowClick builds the mirrored payload of a recorded click
+func NewShadowClick(shortCode string, click *ClickEvent) *ShadowClick {
+	return &ShadowClick{
+		Event:     ShadowEventClick,
+		ShortCode: shortCode,
+		ClickedAt: click.ClickedAt,
+		IPAddress: click.IPAddress,
+		UserAgent: click.UserAgent,
+		Referer:   click.Referer,
+		Country:   click.Country,
+	}
+}
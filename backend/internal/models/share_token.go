@@ -0,0 +1,72 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ShareToken is a named, revocable code that opens a password-protected link
+// without its password
+type ShareToken struct {
+	ID          int        `db:"id" json:"id"`
+	URLID       int        `db:"url_id" json:"url_id"`
+	Name        string     `db:"name" json:"name"`
+	TokenHash   string     `db:"token_hash" json:"-"`
+	TokenPrefix string     `db:"token_prefix" json:"token_prefix"` // Identifies the token without revealing it
+	AccessCount int64      `db:"access_count" json:"access_count"`
+	LastUsedAt  *time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+	RevokedAt   *time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+}
+
+// CreateShareTokenRequest represents a request to mint a share token
+type CreateShareTokenRequest struct {
+	Name string `json:"name" binding:"required" validate:"required,max=100"`
+}
+
+// CreateShareTokenResponse returns a new share token, which is only shown once
+type CreateShareTokenResponse struct {
+	ShareToken
+	Token    string `json:"token"`
+	ShareURL string `json:"share_url"`
+}
+
+// RotateLinkPasswordRequest represents a request to replace a link's password.
+// An empty password generates one.
+type RotateLinkPasswordRequest struct {
+	Password          string `json:"password,omitempty"`
+	RevokeShareTokens bool   `json:"revoke_share_tokens,omitempty"`
+}
+
+// RotateLinkPasswordResponse returns the link's new password
+type RotateLinkPasswordResponse struct {
+	ShortCode          string `json:"short_code"`
+	Password           string `json:"password"`
+	RevokedShareTokens int    `json:"revoked_share_tokens"`
+}
+
+// IsRevoked returns true if the token no longer opens the link
+func (t *ShareToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+// Validate validates the create share token request
+func (req *CreateShareTokenRequest) Validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(req.Name) > 100 {
+		return fmt.Errorf("name must be at most 100 characters long")
+	}
+	return nil
+}
+
+// Validate validates the rotate link password request
+func (req *RotateLinkPasswordRequest) Validate() error {
+	if req.Password == "" {
+		return nil
+	}
+	return validateLinkPassword(req.Password)
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// ShortCodeAlphabet is the set of characters generated short codes are drawn from
+const ShortCodeAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// MaxShortCodeLength is the longest generated short code
+const MaxShortCodeLength = 20
+
+// ShortCodeKeyspace reports how full the space of generated short codes is at
+// the current length. Once Utilization reaches MaxUtilization, new codes get
+// one character more. Generated codes never collide with each other, so this
+// only keeps collisions with custom codes, and with codes generated at random
+// by earlier versions, rare.
+type ShortCodeKeyspace struct {
+	Length         int       `json:"length"`
+	MinLength      int       `json:"min_length"`
+	Codes          int64     `json:"codes"`    // Existing codes of Length characters from the alphabet
+	Keyspace       float64   `json:"keyspace"` // Possible codes of Length characters
+	Utilization    float64   `json:"utilization"`
+	MaxUtilization float64   `json:"max_utilization"`
+	CheckedAt      time.Time `json:"checked_at,omitempty"`
+}
+
+// ShortCodeKeyspaceSize returns how many codes of the given length the alphabet allows
+func ShortCodeKeyspaceSize(length int) float64 {
+	return math.Pow(float64(len(ShortCodeAlphabet)), float64(length))
+}
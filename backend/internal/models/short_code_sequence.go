@@ -0,0 +1,70 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"math/big"
+)
+
+// shortCodeFeistelRounds is the number of rounds of the permutation shuffling sequence numbers
+const shortCodeFeistelRounds = 4
+
+// ShortCodeFromSequence turns a number from the short code sequence into a
+// code of the given length. A permutation keyed by secret shuffles the numbers
+// over every code of that length, so consecutive numbers give unrelated codes
+// that don't reveal how many links exist, while distinct numbers below the
+// keyspace size still always give distinct codes.
+func ShortCodeFromSequence(seq int64, length int, secret []byte) string {
+	base := big.NewInt(int64(len(ShortCodeAlphabet)))
+	size := new(big.Int).Exp(base, big.NewInt(int64(length)), nil)
+
+	n := new(big.Int).Mod(big.NewInt(seq), size)
+	n = permuteShortCode(n, size, length, secret)
+
+	code := make([]byte, length)
+	digit := new(big.Int)
+	for i := length - 1; i >= 0; i-- {
+		n.DivMod(n, base, digit)
+		code[i] = ShortCodeAlphabet[digit.Int64()]
+	}
+	return string(code)
+}
+
+// permuteShortCode maps n to another number below size, one-to-one. A Feistel
+// network permutes the smallest even number of bits holding size, and results
+// outside the keyspace are permuted again until they fall inside it ("cycle
+// walking"), which keeps the mapping one-to-one on the keyspace.
+func permuteShortCode(n, size *big.Int, length int, secret []byte) *big.Int {
+	bits := size.BitLen()
+	if bits%2 == 1 {
+		bits++
+	}
+	half := uint(bits / 2)
+	mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), half), big.NewInt(1))
+
+	for {
+		n = feistel(n, half, mask, length, secret)
+		if n.Cmp(size) < 0 {
+			return n
+		}
+	}
+}
+
+// feistel runs one pass of the Feistel network over a number of 2*half bits,
+// with HMAC-SHA256 of the right half as the round function
+func feistel(n *big.Int, half uint, mask *big.Int, length int, secret []byte) *big.Int {
+	left := new(big.Int).Rsh(n, half)
+	right := new(big.Int).And(n, mask)
+
+	for round := 0; round < shortCodeFeistelRounds; round++ {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte{byte(length), byte(round)})
+		mac.Write(right.Bytes())
+
+		f := new(big.Int).SetBytes(mac.Sum(nil))
+		f.And(f, mask)
+		left, right = right, f.Xor(f, left)
+	}
+
+	return new(big.Int).Or(new(big.Int).Lsh(left, half), right)
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// Overall service statuses reported on the status page
+const (
+	ServiceStatusOperational = "operational"
+	ServiceStatusDegraded    = "degraded"
+	ServiceStatusOutage      = "major_outage"
+)
+
+// Dependency statuses
+const (
+	DependencyStatusUp      = "up"
+	DependencyStatusDown    = "down"
+	DependencyStatusUnknown = "unknown"
+)
+
+// ServiceStatus is the public status page summary. It carries no error details.
+type ServiceStatus struct {
+	Status          string             `json:"status"`
+	StartedAt       time.Time          `json:"started_at"`
+	Uptime          UptimeStats        `json:"uptime"`
+	RedirectLatency LatencyStats       `json:"redirect_latency"`
+	RedirectCache   CacheStats         `json:"redirect_cache"`
+	Dependencies    []DependencyStatus `json:"dependencies"`
+	GeneratedAt     time.Time          `json:"generated_at"`
+}
+
+// UptimeStats is the share of dependency checks in the window that found
+// every critical dependency up
+type UptimeStats struct {
+	Window  string  `json:"window"`
+	Percent float64 `json:"percent"`
+	Checks  int     `json:"checks"`
+}
+
+// LatencyStats summarizes redirect latencies in the window
+type LatencyStats struct {
+	Window    string  `json:"window"`
+	Samples   int     `json:"samples"`
+	P50Ms     float64 `json:"p50_ms"`
+	P95Ms     float64 `json:"p95_ms"`
+	P99Ms     float64 `json:"p99_ms"`
+	ErrorRate float64 `json:"error_rate"` // Share of redirects answered with a 5xx
+}
+
+// CacheStats counts the outcomes of redirect cache lookups since startup.
+// Errors are lookups that failed (rather than missed) and fell back to the database.
+type CacheStats struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	Errors  int64   `json:"errors"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// DependencyStatus is the latest health check of one dependency
+type DependencyStatus struct {
+	Name      string     `json:"name"`
+	Status    string     `json:"status"`
+	Critical  bool       `json:"critical"`
+	LatencyMs float64    `json:"latency_ms"`
+	CheckedAt *time.Time `json:"checked_at,omitempty"`
+}
@@ -53,8 +53,111 @@ type URL struct {
 	ClickCount  int        `db:"click_count" json:"click_count"`
 	IsActive    bool       `db:"is_active" json:"is_active"`
 	ExpiresAt   *time.Time `db:"expires_at" json:"expires_at,omitempty"`
+	ActivatesAt *time.Time `db:"activates_at" json:"activates_at,omitempty"`
 	UserAgent   string     `db:"user_agent" json:"user_agent,omitempty"`
 	IPAddress   string     `db:"ip_address" json:"ip_address,omitempty"`
+	NeedsReview bool       `db:"needs_review" json:"needs_review"`
+
+	// Threat list category the destination was found on, which deactivated the
+	// link or, for lower-severity threats, marked it suspicious
+	ThreatType string `db:"threat_type" json:"threat_type,omitempty"`
+
+	// Suspicious links show a warning page before redirecting
+	Suspicious bool `db:"suspicious" json:"suspicious"`
+
+	// Referrer-based access rules (empty mode means no restriction)
+	ReferrerMode        string   `db:"referrer_mode" json:"referrer_mode,omitempty"`
+	ReferrerDomains     []string `db:"referrer_domains" json:"referrer_domains,omitempty"`
+	ReferrerFallbackURL string   `db:"referrer_fallback_url" json:"referrer_fallback_url,omitempty"`
+
+	// Password protection (the hash is never serialized)
+	PasswordHash      string `db:"password_hash" json:"-"`
+	PasswordProtected bool   `db:"-" json:"password_protected"`
+
+	// Inactivity expiration (0 disables it)
+	LastClickedAt        *time.Time `db:"last_clicked_at" json:"last_clicked_at,omitempty"`
+	InactivityExpiryDays int        `db:"inactivity_expiry_days" json:"inactivity_expiry_days,omitempty"`
+
+	// Set when the cleanup job deactivated the link because it expired, so a
+	// new expiration reactivates it
+	ExpiryDeactivated bool `db:"expiry_deactivated" json:"-"`
+
+	// Redirect rate cap protecting the destination (0 means unlimited)
+	MaxClicksPerMinute int `db:"max_clicks_per_minute" json:"max_clicks_per_minute,omitempty"`
+
+	// Redirects allowed before the link deactivates (0 means unlimited) and how many were served
+	MaxClicks     int `db:"max_clicks" json:"max_clicks,omitempty"`
+	RedirectCount int `db:"redirect_count" json:"redirect_count,omitempty"`
+
+	// Set when the link deactivated on taking its last allowed redirect, so
+	// raising or removing the limit reactivates it
+	ClickLimitDeactivated bool `db:"click_limit_deactivated" json:"-"`
+
+	// Per-visitor daily visit cap and where capped visitors go (0 means uncapped)
+	FrequencyCap    int    `db:"frequency_cap" json:"frequency_cap,omitempty"`
+	FrequencyCapURL string `db:"frequency_cap_url" json:"frequency_cap_url,omitempty"`
+
+	// Endpoint receiving a copy of each click's metadata, until ShadowUntil
+	ShadowURL   string     `db:"shadow_url" json:"shadow_url,omitempty"`
+	ShadowUntil *time.Time `db:"shadow_until" json:"shadow_until,omitempty"`
+
+	// Rotator links send each visit to one of their destinations (empty for regular links)
+	RotationMode string `db:"rotation_mode" json:"rotation_mode,omitempty"`
+
+	// A/B tested links split visits between weighted variants (empty for regular links)
+	SplitMode string `db:"split_mode" json:"split_mode,omitempty"`
+
+	// Destinations for visitors on particular devices, e.g. app store links for phones
+	DeviceRules DeviceRules `db:"device_rules" json:"device_rules,omitempty"`
+
+	// Title, description and favicon fetched from the destination
+	LinkMetadata
+
+	// Verified custom domain the link is shared on (empty for the default domain)
+	Domain string `db:"domain" json:"domain,omitempty"`
+
+	// Whether the link redirects now, set on lists of the owner's links (see Status)
+	Status string `db:"-" json:"status,omitempty"`
+
+	// How visitors are redirected: 301, 302, 307 or meta (empty for the default)
+	RedirectType string `db:"redirect_type" json:"redirect_type,omitempty"`
+
+	// Canary rollout shifting clicks from OriginalURL to CanaryURL between the start and end times
+	CanaryURL          string     `db:"canary_url" json:"canary_url,omitempty"`
+	CanaryStatus       string     `db:"canary_status" json:"canary_status,omitempty"`
+	CanaryStartPercent int        `db:"canary_start_percent" json:"canary_start_percent,omitempty"`
+	CanaryStartedAt    *time.Time `db:"canary_started_at" json:"canary_started_at,omitempty"`
+	CanaryEndsAt       *time.Time `db:"canary_ends_at" json:"canary_ends_at,omitempty"`
+	CanaryFailures     int        `db:"canary_failures" json:"canary_failures,omitempty"` // Consecutive failed health checks
+
+	// UTM parameters added to the destination on redirect
+	UTM UTMParams `db:"utm_params" json:"utm"`
+
+	// Internal bookkeeping for the owner's team, never shown publicly
+	Title  string     `db:"title" json:"title,omitempty"`
+	Notes  string     `db:"notes" json:"notes,omitempty"`
+	Labels LinkLabels `db:"labels" json:"labels,omitempty"`
+}
+
+// LinkScanTarget is an active link whose destinations are due for a threat scan
+type LinkScanTarget struct {
+	ID           int
+	ShortCode    string
+	Destinations []string // The destination, any rotation, split test or device rule web destinations and a canary in progress
+}
+
+// MaxInactivityExpiryDays bounds the inactivity expiration policy
+const MaxInactivityExpiryDays = 3650
+
+// Cacheable returns true if the redirect can be served from the cache, which
+// only holds the destination and so skips per-request access rules
+func (u *URL) Cacheable() bool {
+	return !u.NeedsReview && !u.Suspicious && u.ReferrerMode == "" && !u.IsPasswordProtected() && !u.IsThrottled() && !u.IsFrequencyCapped() && !u.IsRotator() && !u.IsSplitTest() && !u.HasDeviceRules() && !u.IsCanaryRollingOut() && !u.HasClickLimit() && !u.HasTemporaryRedirect() && !u.IsScheduled()
+}
+
+// SetSuspiciousRequest sets or clears a link's suspicious flag
+type SetSuspiciousRequest struct {
+	Suspicious *bool `json:"suspicious" binding:"required"`
 }
 
 // CreateURLRequest represents the request to create a new short URL
@@ -62,6 +165,58 @@ type CreateURLRequest struct {
 	URL        string       `json:"url" binding:"required" validate:"required,url"`
 	CustomCode string       `json:"custom_code,omitempty" validate:"omitempty,min=3,max=20,alphanum"`
 	ExpiresAt  OptionalTime `json:"expires_at,omitempty"`
+
+	// Only start redirecting at this time, e.g. when a campaign launches
+	ActivatesAt OptionalTime `json:"activates_at,omitempty"`
+
+	ReferrerRules *ReferrerRules `json:"referrer_rules,omitempty"`
+	Password      string         `json:"password,omitempty"`
+
+	// Opt out of the user's UTM auto-tagging defaults for this link
+	SkipUTMDefaults bool `json:"skip_utm_defaults,omitempty"`
+
+	// Opt out of all of the user's link presets and the server's default expiration
+	SkipDefaults bool `json:"skip_defaults,omitempty"`
+
+	// UTM parameters stored with the link and added to its destination on redirect
+	UTMSource   string `json:"utm_source,omitempty"`
+	UTMMedium   string `json:"utm_medium,omitempty"`
+	UTMCampaign string `json:"utm_campaign,omitempty"`
+
+	// Expire the link after this many days without clicks (0 disables it)
+	InactivityExpiryDays int `json:"inactivity_expiry_days,omitempty"`
+
+	// Cap redirects per minute (0 means unlimited)
+	MaxClicksPerMinute int `json:"max_clicks_per_minute,omitempty"`
+
+	// Deactivate the link after this many redirects, e.g. 1 for a one-time link (0 means unlimited)
+	MaxClicks int `json:"max_clicks,omitempty"`
+
+	// Send repeat visitors to an alternate URL after this many daily visits
+	FrequencyCap *FrequencyCap `json:"frequency_cap,omitempty"`
+
+	// Mirror click metadata to a debugging endpoint for a while
+	ShadowTraffic *ShadowTraffic `json:"shadow_traffic,omitempty"`
+
+	// Cycle visits through several destinations
+	Rotation *Rotation `json:"rotation,omitempty"`
+
+	// Split visits between weighted destination variants to compare their conversions
+	SplitTest *SplitTest `json:"split_test,omitempty"`
+
+	// Send visitors on some devices elsewhere, e.g. phones to the app store
+	DeviceRules DeviceRules `json:"device_rules,omitempty"`
+
+	// Redirect with 301, 302, 307 or a meta refresh page (default: 301, or 302 for links with access rules)
+	RedirectType string `json:"redirect_type,omitempty"`
+
+	// Title, internal notes and key/value labels, only shown to the owner
+	Title  string     `json:"title,omitempty"`
+	Notes  string     `json:"notes,omitempty"`
+	Labels LinkLabels `json:"labels,omitempty"`
+
+	// One of the user's verified custom domains to share the link on (default: the user's default domain)
+	Domain string `json:"domain,omitempty"`
 }
 
 // CreateURLResponse represents the response when creating a short URL
@@ -73,7 +228,9 @@ type CreateURLResponse struct {
 	IsActive    bool       `json:"is_active"`
 	CreatedAt   time.Time  `json:"created_at"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	ActivatesAt *time.Time `json:"activates_at,omitempty"`
 	QRCode      string     `json:"qr_code_url,omitempty"`
+	NeedsReview bool       `json:"needs_review,omitempty"`
 }
 
 // URLStatsResponse represents URL statistics
@@ -95,7 +252,20 @@ type ClickEvent struct {
 	Referer   string    `db:"referer" json:"referer"`
 	Country   string    `db:"country" json:"country"`
 	City      string    `db:"city" json:"city"`
+	Browser   string    `db:"browser" json:"browser"`
+	Device    string    `db:"device" json:"device"`
+	OS        string    `db:"os" json:"os"`
 	ClickedAt time.Time `db:"clicked_at" json:"clicked_at"`
+
+	// Clicks this event stands for: more than 1 for the events stored while
+	// a hot link's clicks were sampled
+	SampleWeight int `db:"sample_weight" json:"sample_weight"`
+
+	// How the visitor reached the link: ClickChannelClick or ClickChannelScan
+	Channel string `db:"channel" json:"channel"`
+
+	// Set by the deployment's custom click enrichers
+	Attributes ClickAttributes `db:"attributes" json:"attributes,omitempty"`
 }
 
 // URLAnalytics represents analytics data
@@ -106,6 +276,46 @@ type URLAnalytics struct {
 	ClicksThisWeek int             `json:"clicks_this_week"`
 	TopCountries   []CountryStats  `json:"top_countries"`
 	TopReferrers   []ReferrerStats `json:"top_referrers"`
+	TopBrowsers    []BrowserStats  `json:"top_browsers"`
+	TopDevices     []DeviceStats   `json:"top_devices"`
+	TopOS          []OSStats       `json:"top_os"`
+
+	// Clicks by how visitors reached the link, e.g. how many scanned its QR code
+	ClicksByChannel []ChannelStats `json:"clicks_by_channel"`
+
+	// IANA time zone used for the today/this-week buckets
+	Timezone string `json:"timezone"`
+
+	// Set when some of the link's click events were sampled: counts are then
+	// estimated from the stored events, at the lowest share of clicks kept.
+	// The link's click_count stays exact.
+	Sampled    bool    `json:"sampled"`
+	SampleRate float64 `json:"sample_rate,omitempty"`
+
+	// Redirect attempts rejected by access rules, by reason
+	BlockedClicks     map[string]int  `json:"blocked_clicks,omitempty"`
+	RejectedReferrers []ReferrerStats `json:"rejected_referrers,omitempty"`
+
+	// Visits through each share token of a password-protected link
+	ShareTokens []ShareToken `json:"share_tokens,omitempty"`
+
+	// Clicks sent to each destination of a rotator link
+	Destinations []LinkDestination `json:"destinations,omitempty"`
+
+	// Clicks and conversions of each variant of a split test link, compared with the first
+	Variants []VariantStats `json:"variants,omitempty"`
+
+	// Set when the caller's plan limited the response
+	UpgradeRequired *UpgradeHint `json:"upgrade_required,omitempty"`
+}
+
+// UpgradeHint explains what a response left out because of the caller's plan
+type UpgradeHint struct {
+	Plan          string   `json:"plan"`
+	RequestedDays int      `json:"requested_days,omitempty"`
+	MaxDays       int      `json:"max_days,omitempty"`
+	HiddenFields  []string `json:"hidden_fields,omitempty"`
+	Message       string   `json:"message"`
 }
 
 // CountryStats represents click statistics by country
@@ -120,11 +330,194 @@ type ReferrerStats struct {
 	Clicks   int    `json:"clicks"`
 }
 
+// BrowserStats represents click statistics by browser
+type BrowserStats struct {
+	Browser string `json:"browser"`
+	Clicks  int    `json:"clicks"`
+}
+
+// DeviceStats represents click statistics by device type
+type DeviceStats struct {
+	Device string `json:"device"`
+	Clicks int    `json:"clicks"`
+}
+
+// OSStats represents click statistics by operating system
+type OSStats struct {
+	OS     string `json:"os"`
+	Clicks int    `json:"clicks"`
+}
+
+// ChannelStats represents click statistics by channel
+type ChannelStats struct {
+	Channel string `json:"channel"`
+	Clicks  int    `json:"clicks"`
+}
+
+// Sort fields accepted when listing URLs
+const (
+	URLSortCreatedAt     = "created_at"
+	URLSortLastClickedAt = "last_clicked_at"
+	URLSortClickCount    = "click_count"
+	URLSortExpiresAt     = "expires_at"
+
+	// Accepted for click_count
+	urlSortClicks = "clicks"
+)
+
+// Link statuses a list of URLs can be filtered by. Expired links are only
+// expired, whether or not they are also deactivated.
+const (
+	URLStatusActive    = "active"    // Active and not expired
+	URLStatusExpired   = "expired"   // Past their expiration
+	URLStatusInactive  = "inactive"  // Deactivated and not expired
+	URLStatusScheduled = "scheduled" // Active, but not redirecting before their activation time
+)
+
+// URLSearchOptions controls a search of a user's URLs
+type URLSearchOptions struct {
+	Query  string
+	Limit  int
+	Offset int
+}
+
+// Validate validates and applies defaults to the search options
+func (o *URLSearchOptions) Validate() error {
+	o.Query = strings.TrimSpace(o.Query)
+	if o.Query == "" {
+		return fmt.Errorf("search query is required")
+	}
+	if len(o.Query) > 200 {
+		return fmt.Errorf("search query must be at most 200 characters")
+	}
+	if o.Limit <= 0 || o.Limit > 100 {
+		o.Limit = 10
+	}
+	if o.Offset < 0 {
+		o.Offset = 0
+	}
+	return nil
+}
+
+// URLListOptions controls pagination, sorting and filtering when listing a user's URLs
+type URLListOptions struct {
+	Limit     int
+	Offset    int
+	SortBy    string
+	Ascending bool
+
+	// Only include links clicked at or after this time
+	ClickedSince *time.Time
+
+	// Only include links with this status, or created within [CreatedAfter, CreatedBefore)
+	Status        string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+
+	// Cursor resumes a list paginated by cursor instead of offset; empty starts it
+	Cursor string
+
+	// Decoded from Cursor by Validate
+	After *URLCursor
+}
+
+// Validate validates and applies defaults to the list options
+func (o *URLListOptions) Validate() error {
+	if o.Limit <= 0 {
+		o.Limit = 10
+	}
+	if o.Offset < 0 {
+		o.Offset = 0
+	}
+	switch o.SortBy {
+	case "":
+		o.SortBy = URLSortCreatedAt
+	case urlSortClicks:
+		o.SortBy = URLSortClickCount
+	case URLSortCreatedAt, URLSortLastClickedAt, URLSortClickCount, URLSortExpiresAt:
+	default:
+		return fmt.Errorf("sort must be one of %s, %s, %s, %s", URLSortCreatedAt, URLSortLastClickedAt, URLSortClickCount, URLSortExpiresAt)
+	}
+
+	o.Status = strings.ToLower(strings.TrimSpace(o.Status))
+	switch o.Status {
+	case "", URLStatusActive, URLStatusExpired, URLStatusInactive, URLStatusScheduled:
+	default:
+		return fmt.Errorf("status must be one of %s, %s, %s, %s", URLStatusActive, URLStatusExpired, URLStatusInactive, URLStatusScheduled)
+	}
+	if o.CreatedAfter != nil && o.CreatedBefore != nil && !o.CreatedAfter.Before(*o.CreatedBefore) {
+		return fmt.Errorf("created_after must be before created_before")
+	}
+
+	o.After = nil
+	if o.Cursor == "" {
+		return nil
+	}
+	after, err := DecodeURLCursor(o.Cursor)
+	if err != nil {
+		return err
+	}
+	if after.SortBy != o.SortBy || after.Ascending != o.Ascending {
+		return fmt.Errorf("cursor belongs to a list with a different sort or order")
+	}
+	o.After = after
+	return nil
+}
+
 // UpdateURLRequest represents the request to update a URL
 type UpdateURLRequest struct {
 	OriginalURL string       `json:"original_url,omitempty"`
 	IsActive    *bool        `json:"is_active,omitempty"`
 	ExpiresAt   OptionalTime `json:"expires_at,omitempty"`
+
+	// Set to reschedule the link's activation; a time in the past activates it right away
+	ActivatesAt OptionalTime `json:"activates_at,omitempty"`
+
+	// Set to replace the link's referrer rules; a rule with an empty mode removes them
+	ReferrerRules *ReferrerRules `json:"referrer_rules,omitempty"`
+
+	// Set to change the link password; an empty string removes protection
+	Password *string `json:"password,omitempty"`
+
+	// Set to change the inactivity expiration policy; 0 disables it
+	InactivityExpiryDays *int `json:"inactivity_expiry_days,omitempty"`
+
+	// Set to change the redirect rate cap; 0 removes it
+	MaxClicksPerMinute *int `json:"max_clicks_per_minute,omitempty"`
+
+	// Set to change the click limit; 0 removes it. Redirects already served still count.
+	MaxClicks *int `json:"max_clicks,omitempty"`
+
+	// Set to replace the per-visitor frequency cap; zero visits removes it
+	FrequencyCap *FrequencyCap `json:"frequency_cap,omitempty"`
+
+	// Set to start (or restart) mirroring clicks; an empty URL stops it
+	ShadowTraffic *ShadowTraffic `json:"shadow_traffic,omitempty"`
+
+	// Set to replace the rotation destinations; an empty mode turns rotation off
+	Rotation *Rotation `json:"rotation,omitempty"`
+
+	// Set to replace the split test variants; an empty mode ends the test
+	SplitTest *SplitTest `json:"split_test,omitempty"`
+
+	// Set to replace the device rules; an empty list removes them
+	DeviceRules *DeviceRules `json:"device_rules,omitempty"`
+
+	// Set to change the redirect type; an empty string restores the default
+	RedirectType *string `json:"redirect_type,omitempty"`
+
+	// Set to start (or restart) a canary rollout to a new destination; an empty URL cancels it
+	Canary *CanaryRollout `json:"canary,omitempty"`
+
+	// Set to replace the title or internal notes; an empty string clears them
+	Title *string `json:"title,omitempty"`
+	Notes *string `json:"notes,omitempty"`
+
+	// Set to replace the internal labels; an empty object clears them
+	Labels *LinkLabels `json:"labels,omitempty"`
+
+	// Set to move the link to another of the user's verified custom domains; an empty string moves it to the default domain
+	Domain *string `json:"domain,omitempty"`
 }
 
 // Validate validates the update URL request
@@ -152,6 +545,117 @@ func (req *UpdateURLRequest) Validate() error {
 		return fmt.Errorf("expiration date cannot be in the past")
 	}
 
+	// Validate referrer rules
+	if req.ReferrerRules != nil {
+		if err := req.ReferrerRules.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate link password
+	if req.Password != nil && *req.Password != "" {
+		if err := validateLinkPassword(*req.Password); err != nil {
+			return err
+		}
+	}
+
+	// Validate inactivity expiration
+	if req.InactivityExpiryDays != nil {
+		if err := validateInactivityExpiryDays(*req.InactivityExpiryDays); err != nil {
+			return err
+		}
+	}
+
+	// Validate click rate limit
+	if req.MaxClicksPerMinute != nil {
+		if err := validateMaxClicksPerMinute(*req.MaxClicksPerMinute); err != nil {
+			return err
+		}
+	}
+
+	// Validate click limit
+	if req.MaxClicks != nil {
+		if err := validateMaxClicks(*req.MaxClicks); err != nil {
+			return err
+		}
+	}
+
+	// Validate redirect type
+	if req.RedirectType != nil {
+		if err := validateRedirectType(*req.RedirectType); err != nil {
+			return err
+		}
+	}
+
+	// Validate frequency cap
+	if req.FrequencyCap != nil {
+		if err := req.FrequencyCap.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate shadow traffic
+	if req.ShadowTraffic != nil {
+		if err := req.ShadowTraffic.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate rotation
+	if req.Rotation != nil {
+		if err := req.Rotation.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate split test
+	if req.SplitTest != nil {
+		if err := req.SplitTest.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate device rules
+	if req.DeviceRules != nil {
+		if err := req.DeviceRules.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate canary rollout
+	if req.Canary != nil {
+		if err := req.Canary.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate title, notes and labels
+	if req.Title != nil {
+		title, err := normalizeLinkTitle(*req.Title)
+		if err != nil {
+			return err
+		}
+		req.Title = &title
+	}
+	if req.Notes != nil {
+		notes, err := normalizeLinkNotes(*req.Notes)
+		if err != nil {
+			return err
+		}
+		req.Notes = &notes
+	}
+	if req.Labels != nil {
+		if err := req.Labels.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Normalize the custom domain
+	if req.Domain != nil {
+		domain := NormalizeHostname(*req.Domain)
+		req.Domain = &domain
+	}
+
 	return nil
 }
 
@@ -190,6 +694,39 @@ func (u *URL) IsExpired() bool {
 	return time.Now().After(*u.ExpiresAt)
 }
 
+// IsScheduled checks if the URL is waiting for its activation time
+func (u *URL) IsScheduled() bool {
+	return u.ActivatesAt != nil && time.Now().Before(*u.ActivatesAt)
+}
+
+// LinkStatus returns the status the link is listed with: expired, inactive,
+// scheduled or active, in that order of precedence
+func (u *URL) LinkStatus() string {
+	switch {
+	case u.IsExpired():
+		return URLStatusExpired
+	case !u.IsActive:
+		return URLStatusInactive
+	case u.IsScheduled():
+		return URLStatusScheduled
+	default:
+		return URLStatusActive
+	}
+}
+
+// ValidateActivationWindow checks that the link activates before it expires
+func (u *URL) ValidateActivationWindow() error {
+	return validateActivationWindow(u.ActivatesAt, u.ExpiresAt)
+}
+
+// validateActivationWindow checks that a link activates before it expires
+func validateActivationWindow(activatesAt, expiresAt *time.Time) error {
+	if activatesAt != nil && expiresAt != nil && !activatesAt.Before(*expiresAt) {
+		return fmt.Errorf("activation time must be before the expiration date")
+	}
+	return nil
+}
+
 // NormalizeURL normalizes the original URL
 func (u *URL) NormalizeURL() {
 	u.OriginalURL = strings.TrimSpace(u.OriginalURL)
@@ -232,12 +769,156 @@ func (req *CreateURLRequest) Validate() error {
 				return fmt.Errorf("custom code must contain only alphanumeric characters")
 			}
 		}
+
+		if ContainsBlockedWord(req.CustomCode) {
+			return fmt.Errorf("custom code contains a blocked word")
+		}
 	}
 
 	// Validate expiration date
 	if req.ExpiresAt.Time != nil && req.ExpiresAt.Time.Before(time.Now()) {
 		return fmt.Errorf("expiration date cannot be in the past")
 	}
+	if err := validateActivationWindow(req.ActivatesAt.Time, req.ExpiresAt.Time); err != nil {
+		return err
+	}
 
+	// Validate referrer rules
+	if req.ReferrerRules != nil {
+		if err := req.ReferrerRules.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate link password
+	if req.Password != "" {
+		if err := validateLinkPassword(req.Password); err != nil {
+			return err
+		}
+	}
+
+	// Validate inactivity expiration
+	if err := validateInactivityExpiryDays(req.InactivityExpiryDays); err != nil {
+		return err
+	}
+
+	// Validate click rate limit
+	if err := validateMaxClicksPerMinute(req.MaxClicksPerMinute); err != nil {
+		return err
+	}
+
+	// Validate click limit
+	if err := validateMaxClicks(req.MaxClicks); err != nil {
+		return err
+	}
+
+	// Validate redirect type
+	if err := validateRedirectType(req.RedirectType); err != nil {
+		return err
+	}
+
+	// Validate UTM parameters
+	utm := req.UTM()
+	if err := utm.Validate(); err != nil {
+		return err
+	}
+	req.UTMSource, req.UTMMedium, req.UTMCampaign = utm.Source, utm.Medium, utm.Campaign
+
+	// Validate frequency cap
+	if req.FrequencyCap != nil {
+		if err := req.FrequencyCap.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate shadow traffic
+	if req.ShadowTraffic != nil {
+		if err := req.ShadowTraffic.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate rotation
+	if req.Rotation != nil {
+		if err := req.Rotation.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate split test
+	if req.SplitTest != nil {
+		if err := req.SplitTest.Validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate device rules
+	if err := req.DeviceRules.Validate(); err != nil {
+		return err
+	}
+
+	// Validate title, notes and labels
+	title, err := normalizeLinkTitle(req.Title)
+	if err != nil {
+		return err
+	}
+	req.Title = title
+	notes, err := normalizeLinkNotes(req.Notes)
+	if err != nil {
+		return err
+	}
+	req.Notes = notes
+	if err := req.Labels.Validate(); err != nil {
+		return err
+	}
+
+	// Normalize the custom domain
+	req.Domain = NormalizeHostname(req.Domain)
+
+	return nil
+}
+
+// LiveFrom returns when the requested link starts redirecting: its activation
+// time if it's scheduled, or otherwise now
+func (req *CreateURLRequest) LiveFrom(now time.Time) time.Time {
+	if req.ActivatesAt.Time != nil && req.ActivatesAt.Time.After(now) {
+		return *req.ActivatesAt.Time
+	}
+	return now
+}
+
+// UTM returns the link's UTM parameters from the request
+func (req *CreateURLRequest) UTM() UTMParams {
+	return UTMParams{Source: req.UTMSource, Medium: req.UTMMedium, Campaign: req.UTMCampaign}
+}
+
+// ShortURL returns the link's short URL: on its custom domain, which is served
+// over HTTPS, or otherwise on the server's base URL
+func (u *URL) ShortURL(baseURL string) string {
+	if u.Domain != "" {
+		return fmt.Sprintf("https://%s/%s", u.Domain, u.ShortCode)
+	}
+	return fmt.Sprintf("%s/%s", baseURL, u.ShortCode)
+}
+
+// Deactivate turns the link off for a reason other than its expiration or
+// click limit, so neither a new expiration nor a higher limit brings it back
+func (u *URL) Deactivate() {
+	u.IsActive = false
+	u.ExpiryDeactivated = false
+	u.ClickLimitDeactivated = false
+}
+
+// TaggedURL returns the link's destination with its UTM parameters added.
+// Parameters the destination already sets are kept.
+func (u *URL) TaggedURL() string {
+	return u.UTM.Tag(u.OriginalURL)
+}
+
+// validateInactivityExpiryDays checks an inactivity expiration policy
+func validateInactivityExpiryDays(days int) error {
+	if days < 0 || days > MaxInactivityExpiryDays {
+		return fmt.Errorf("inactivity expiry days must be between 0 and %d", MaxInactivityExpiryDays)
+	}
 	return nil
 }
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Transitions recorded in a link's lifecycle event log
+const (
+	URLEventCreated            = "created"
+	URLEventActivated          = "activated"
+	URLEventDeactivated        = "deactivated"
+	URLEventExpired            = "expired"
+	URLEventDestinationChanged = "destination_changed"
+	URLEventDeleted            = "deleted"
+)
+
+// Why a link was activated or deactivated
+const (
+	URLEventReasonOwner      = "owner"       // The owner changed is_active
+	URLEventReasonReview     = "review"      // A new destination was held for review
+	URLEventReasonExtended   = "extended"    // The owner extended an expired link
+	URLEventReasonKillSwitch = "kill_switch" // The owner killed the link
+	URLEventReasonThreat     = "threat"      // A rescan found its destination on a threat list
+	URLEventReasonClickLimit = "click_limit" // The link used up its max_clicks
+	URLEventReasonExpired    = "expired"     // The cleanup job deactivated the expired link
+)
+
+// URLEventsExchange is the message bus exchange link events are published to,
+// routed by "link.<type>" so consumers can bind to the transitions they need
+const URLEventsExchange = "url_events"
+
+// URLEvent is one transition in a link's lifecycle. Events are only ever
+// appended, and outlive the link, so a link's log tells its whole history.
+type URLEvent struct {
+	ID          int64           `db:"id" json:"id"`
+	URLID       int             `db:"url_id" json:"url_id"`
+	ShortCode   string          `db:"short_code" json:"short_code"`
+	UserID      int             `db:"user_id" json:"user_id"`
+	Type        string          `db:"type" json:"type"`
+	Data        json.RawMessage `db:"data" json:"data,omitempty"`
+	CreatedAt   time.Time       `db:"created_at" json:"created_at"`
+	PublishedAt *time.Time      `db:"published_at" json:"-"`
+}
+
+// URLEventStatusChange is the data of activated and deactivated events
+type URLEventStatusChange struct {
+	Reason string `json:"reason"`
+}
+
+// URLEventCreation is the data of created events
+type URLEventCreation struct {
+	OriginalURL string     `json:"original_url"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	ActivatesAt *time.Time `json:"activates_at,omitempty"`
+	NeedsReview bool       `json:"needs_review,omitempty"`
+}
+
+// NewURLEvent creates an event of a link, with data encoded as JSON
+func NewURLEvent(url *URL, eventType string, data interface{}) (*URLEvent, error) {
+	event := &URLEvent{
+		URLID:     url.ID,
+		ShortCode: url.ShortCode,
+		UserID:    url.UserID,
+		Type:      eventType,
+		CreatedAt: time.Now(),
+	}
+	if data != nil {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s event data: %w", eventType, err)
+		}
+		event.Data = encoded
+	}
+	return event, nil
+}
+
+// RoutingKey returns the key the event is published with
+func (e *URLEvent) RoutingKey() string {
+	return "link." + e.Type
+}
+
+// URLEventListOptions pages through a link's events, newest first
+type URLEventListOptions struct {
+	Limit int
+
+	// Only events with lower IDs; 0 starts from the newest event
+	BeforeID int64
+}
+
+// Validate applies defaults to the options
+func (o *URLEventListOptions) Validate() error {
+	if o.Limit <= 0 || o.Limit > MaxPerPage {
+		o.Limit = DefaultPerPage
+	}
+	if o.BeforeID < 0 {
+		return fmt.Errorf("before must be a positive event ID")
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// urlCursorPrefix versions the link list cursor format
+const urlCursorPrefix = "url1:"
+
+// URLCursor is the position in a sorted list of links after which the next
+// page starts: the list's sort, and the sort value and ID of the last link served
+type URLCursor struct {
+	SortBy    string     `json:"s"`
+	Ascending bool       `json:"a,omitempty"`
+	Time      *time.Time `json:"t,omitempty"` // created_at, last_clicked_at or expires_at; nil for a link never clicked or without expiration
+	Clicks    int        `json:"c,omitempty"` // click_count
+	ID        int        `json:"i"`
+}
+
+// NewURLCursor returns the cursor resuming a list sorted by sortBy after url
+func NewURLCursor(url *URL, sortBy string, ascending bool) *URLCursor {
+	cursor := &URLCursor{SortBy: sortBy, Ascending: ascending, ID: url.ID}
+	switch sortBy {
+	case URLSortClickCount:
+		cursor.Clicks = url.ClickCount
+	case URLSortLastClickedAt:
+		cursor.Time = url.LastClickedAt
+	case URLSortExpiresAt:
+		cursor.Time = url.ExpiresAt
+	default:
+		createdAt := url.CreatedAt
+		cursor.Time = &createdAt
+	}
+	return cursor
+}
+
+// SortValue returns the sort column's value at the cursor, nil for a link
+// never clicked or without expiration
+func (c *URLCursor) SortValue() interface{} {
+	if c.SortBy == URLSortClickCount {
+		return c.Clicks
+	}
+	if c.Time == nil {
+		return nil
+	}
+	return *c.Time
+}
+
+// Encode returns the cursor as an opaque string
+func (c *URLCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(append([]byte(urlCursorPrefix), data...))
+}
+
+// DecodeURLCursor parses a cursor returned by URLCursor.Encode
+func DecodeURLCursor(cursor string) (*URLCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(decoded), urlCursorPrefix) {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var c URLCursor
+	if err := json.Unmarshal(decoded[len(urlCursorPrefix):], &c); err != nil || c.ID <= 0 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	switch c.SortBy {
+	case URLSortCreatedAt:
+		if c.Time == nil {
+			return nil, fmt.Errorf("invalid cursor")
+		}
+	case URLSortLastClickedAt, URLSortClickCount, URLSortExpiresAt:
+	default:
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &c, nil
+}
+
+// URLPage is a page of a user's links listed by cursor. NextCursor resumes
+// after the last link, and is omitted on the last page.
+type URLPage struct {
+	URLs       []URL  `json:"urls"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Split modes of A/B tested links
+const (
+	SplitModeWeighted = "weighted" // Each visit draws a variant by weight
+	SplitModeSticky   = "sticky"   // Each visitor IP keeps drawing the same variant
+)
+
+// Bounds on a split test's variants
+const (
+	MinSplitVariants     = 2
+	MaxSplitVariants     = 10
+	MaxVariantWeight     = 100
+	MaxVariantNameLength = 50
+)
+
+// SplitVariantParam is added to a variant's destination with the variant's
+// name, so the site can report conversions for it
+const SplitVariantParam = "split_variant"
+
+// SplitTest turns a link into an A/B test that sends each visit to one of
+// several destinations in proportion to their weights
+type SplitTest struct {
+	Mode     string         `json:"mode"` // Empty ends the test
+	Variants []SplitVariant `json:"variants"`
+}
+
+// SplitVariant is one destination of a split test
+type SplitVariant struct {
+	Name   string `json:"name"` // Defaults to A, B, C, ... by position
+	URL    string `json:"url"`
+	Weight int    `json:"weight"` // 1-100, default 1
+}
+
+// URLVariant is a stored variant of a split test with its clicks and conversions
+type URLVariant struct {
+	ID              int        `db:"id" json:"id"`
+	URLID           int        `db:"url_id" json:"url_id"`
+	Position        int        `db:"position" json:"position"`
+	Name            string     `db:"name" json:"name"`
+	URL             string     `db:"destination_url" json:"url"`
+	Weight          int        `db:"weight" json:"weight"`
+	ClickCount      int64      `db:"click_count" json:"click_count"`
+	ConversionCount int64      `db:"conversion_count" json:"conversion_count"`
+	LastClickedAt   *time.Time `db:"last_clicked_at" json:"last_clicked_at,omitempty"`
+	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
+}
+
+// VariantStats compares a variant's conversion rate with the control, the first variant
+type VariantStats struct {
+	URLVariant
+	ConversionRate float64 `json:"conversion_rate"` // Conversions per click
+
+	// Relative change of the conversion rate over the control's, e.g. 0.25 for
+	// 25% better. Left out for the control and while the control has no conversions.
+	Lift *float64 `json:"lift,omitempty"`
+}
+
+// Validate validates and normalizes the split test. An empty mode clears it.
+func (t *SplitTest) Validate() error {
+	switch t.Mode {
+	case "":
+		t.Variants = nil
+		return nil
+	case SplitModeWeighted, SplitModeSticky:
+	default:
+		return fmt.Errorf("split test mode must be %s or %s", SplitModeWeighted, SplitModeSticky)
+	}
+
+	if len(t.Variants) < MinSplitVariants || len(t.Variants) > MaxSplitVariants {
+		return fmt.Errorf("split test needs between %d and %d variants", MinSplitVariants, MaxSplitVariants)
+	}
+
+	names := make(map[string]bool, len(t.Variants))
+	for i := range t.Variants {
+		variant := &t.Variants[i]
+
+		variant.Name = strings.TrimSpace(variant.Name)
+		if variant.Name == "" {
+			variant.Name = string(rune('A' + i))
+		}
+		if len(variant.Name) > MaxVariantNameLength {
+			return fmt.Errorf("variant name must be at most %d characters long", MaxVariantNameLength)
+		}
+		if names[strings.ToLower(variant.Name)] {
+			return fmt.Errorf("variant name %s is used more than once", variant.Name)
+		}
+		names[strings.ToLower(variant.Name)] = true
+
+		destination := strings.TrimSpace(variant.URL)
+		if !strings.HasPrefix(destination, "http://") && !strings.HasPrefix(destination, "https://") {
+			destination = "https://" + destination
+		}
+		parsed, err := url.Parse(destination)
+		if err != nil || parsed.Host == "" {
+			return fmt.Errorf("variant %s must have a valid URL", variant.Name)
+		}
+		variant.URL = destination
+
+		if variant.Weight == 0 {
+			variant.Weight = 1
+		}
+		if variant.Weight < 1 || variant.Weight > MaxVariantWeight {
+			return fmt.Errorf("variant weight must be between 1 and %d", MaxVariantWeight)
+		}
+	}
+
+	return nil
+}
+
+// Destinations returns the URLs of the split test's variants
+func (t *SplitTest) Destinations() []string {
+	destinations := make([]string, len(t.Variants))
+	for i, variant := range t.Variants {
+		destinations[i] = variant.URL
+	}
+	return destinations
+}
+
+// Apply copies the split mode onto a URL. Variants are stored separately.
+func (t *SplitTest) Apply(u *URL) {
+	u.SplitMode = t.Mode
+}
+
+// IsSplitTest returns true if the link splits its visits between variants
+func (u *URL) IsSplitTest() bool {
+	return u.SplitMode != ""
+}
+
+// PickVariant chooses the variant of a visit by weight. Sticky tests hash the
+// visitor's IP with the short code, so a visitor keeps its variant while the
+// variants and weights stay the same.
+func PickVariant(variants []URLVariant, mode, shortCode, clientIP string) *URLVariant {
+	total := 0
+	for _, variant := range variants {
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+
+	var draw int
+	if mode == SplitModeSticky && clientIP != "" {
+		hash := fnv.New32a()
+		hash.Write([]byte(shortCode + "|" + clientIP))
+		draw = int(hash.Sum32() % uint32(total))
+	} else {
+		draw = rand.IntN(total)
+	}
+
+	for i := range variants {
+		if draw < variants[i].Weight {
+			return &variants[i]
+		}
+		draw -= variants[i].Weight
+	}
+	return &variants[len(variants)-1]
+}
+
+// TagVariant adds the variant's name to its destination's query
+func TagVariant(destination, name string) string {
+	parsed, err := url.Parse(destination)
+	if err != nil {
+		return destination
+	}
+	query := parsed.Query()
+	query.Set(SplitVariantParam, name)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// CompareVariants computes each variant's conversion rate and its lift over
+// the control
+func CompareVariants(variants []URLVariant) []VariantStats {
+	stats := make([]VariantStats, len(variants))
+	for i, variant := range variants {
+		stats[i].URLVariant = variant
+		if variant.ClickCount > 0 {
+			stats[i].ConversionRate = float64(variant.ConversionCount) / float64(variant.ClickCount)
+		}
+	}
+
+	if len(stats) == 0 || stats[0].ConversionRate == 0 {
+		return stats
+	}
+	control := stats[0].ConversionRate
+	for i := 1; i < len(stats); i++ {
+		lift := (stats[i].ConversionRate - control) / control
+		stats[i].Lift = &lift
+	}
+	return stats
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// UsageReport summarizes an organization's usage over one calendar month (UTC)
+type UsageReport struct {
+	ID             int        `db:"id" json:"id"`
+	OrganizationID int        `db:"organization_id" json:"organization_id"`
+	PeriodStart    time.Time  `db:"period_start" json:"period_start"`
+	LinksCreated   int64      `db:"links_created" json:"links_created"`
+	ClicksServed   int64      `db:"clicks_served" json:"clicks_served"`
+	APICalls       int64      `db:"api_calls" json:"api_calls"`
+	StorageBytes   int64      `db:"storage_bytes" json:"storage_bytes"` // Links, click history and QR archives at generation time
+	GeneratedAt    time.Time  `db:"generated_at" json:"generated_at"`
+	EmailedAt      *time.Time `db:"emailed_at" json:"emailed_at,omitempty"`
+}
+
+// Period returns the report's month, e.g. "2024-05"
+func (r *UsageReport) Period() string {
+	return r.PeriodStart.Format("2006-01")
+}
+
+// UsagePeriod returns the start and end of the calendar month (UTC) containing t
+func UsagePeriod(t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
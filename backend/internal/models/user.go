@@ -21,9 +21,32 @@ type User struct {
 	EmailVerifiedAt *time.Time `db:"email_verified_at" json:"email_verified_at,omitempty"`
 	LinkCount       int        `db:"link_count" json:"link_count"`
 	LinkLimit       int        `db:"link_limit" json:"link_limit"`
-	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
-	UpdatedAt       time.Time  `db:"updated_at" json:"updated_at"`
-}
+	Plan            string     `db:"plan" json:"plan"`
+	NeedsReview     bool       `db:"needs_review" json:"needs_review"`
+	ReviewReason    string     `db:"review_reason" json:"review_reason,omitempty"`
+	SignupIP        *string    `db:"signup_ip" json:"-"`
+	Timezone        string     `db:"timezone" json:"timezone"`
+	OrganizationID  *int       `db:"organization_id" json:"organization_id,omitempty"`
+	// EmailStatus is set from provider bounce and complaint notifications
+	EmailStatus       string     `db:"email_status" json:"email_status"`
+	EmailStatusReason string     `db:"email_status_reason" json:"email_status_reason,omitempty"`
+	EmailStatusAt     *time.Time `db:"email_status_at" json:"email_status_at,omitempty"`
+	CreatedAt         time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt         time.Time  `db:"updated_at" json:"updated_at"`
+}
+
+// Plan names
+const (
+	PlanFree = "free"
+	PlanPro  = "pro"
+)
+
+// Email statuses. Emails are only sent to deliverable addresses.
+const (
+	EmailStatusDeliverable = "deliverable"
+	EmailStatusBounced     = "bounced"
+	EmailStatusComplained  = "complained"
+)
 
 // RegisterRequest represents a user registration request
 type RegisterRequest struct {
@@ -41,8 +64,8 @@ type LoginRequest struct {
 
 // LoginResponse represents a successful login response
 type LoginResponse struct {
-	User  UserResponse `json:"user"`
-	Token string       `json:"token"`
+	User UserResponse `json:"user"`
+	TokenPair
 }
 
 // UserResponse represents user data in responses (without sensitive info)
@@ -56,7 +79,13 @@ type UserResponse struct {
 	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
 	LinkCount       int        `json:"link_count"`
 	LinkLimit       int        `json:"link_limit"`
-	CreatedAt       time.Time  `json:"created_at"`
+	Plan            string     `json:"plan"`
+	Timezone        string     `json:"timezone"`
+	// EmailStatus tells the user when we stopped emailing their address, and why
+	EmailStatus       string     `json:"email_status"`
+	EmailStatusReason string     `json:"email_status_reason,omitempty"`
+	EmailStatusAt     *time.Time `json:"email_status_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
 }
 
 // UpdateUserRequest represents a user update request
@@ -64,6 +93,7 @@ type UpdateUserRequest struct {
 	FirstName string `json:"first_name,omitempty" validate:"omitempty,min=2"`
 	LastName  string `json:"last_name,omitempty" validate:"omitempty,min=2"`
 	Email     string `json:"email,omitempty" validate:"omitempty,email"`
+	Timezone  string `json:"timezone,omitempty"`
 }
 
 // ChangePasswordRequest represents a password change request
@@ -142,6 +172,13 @@ func (req *UpdateUserRequest) Validate() error {
 		}
 	}
 
+	if req.Timezone != "" {
+		req.Timezone = strings.TrimSpace(req.Timezone)
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			return fmt.Errorf("timezone must be a valid IANA time zone name")
+		}
+	}
+
 	return nil
 }
 
@@ -169,8 +206,31 @@ func (u *User) ToResponse() UserResponse {
 		FirstName: u.FirstName,
 		LastName:  u.LastName,
 		IsActive:  u.IsActive,
-		CreatedAt: u.CreatedAt,
+		Plan:      u.Plan,
+		Timezone:  u.Timezone,
+
+		EmailStatus:       u.EmailStatus,
+		EmailStatusReason: u.EmailStatusReason,
+		EmailStatusAt:     u.EmailStatusAt,
+		CreatedAt:         u.CreatedAt,
+	}
+}
+
+// EmailDeliverable reports whether emails may be sent to the user's address
+func (u *User) EmailDeliverable() bool {
+	return u.EmailStatus == "" || u.EmailStatus == EmailStatusDeliverable
+}
+
+// Location returns the user's profile time zone, defaulting to UTC
+func (u *User) Location() *time.Location {
+	if u.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		return time.UTC
 	}
+	return loc
 }
 
 // FullName returns the user's full name
@@ -0,0 +1,103 @@
+package models
+
+import "strings"
+
+// Device types reported by click analytics
+const (
+	DeviceDesktop = "Desktop"
+	DeviceMobile  = "Mobile"
+	DeviceTablet  = "Tablet"
+	DeviceBot     = "Bot"
+)
+
+// UnknownClient is reported for clients that can't be identified, including
+// clicks recorded before user agents were parsed
+const UnknownClient = "Unknown"
+
+// ClientInfo is the browser, device type and operating system a click came from
+type ClientInfo struct {
+	Browser string
+	Device  string
+	OS      string
+}
+
+// userAgentRule maps a product token found in a user agent to a name. Rules are
+// tried in order, so tokens other products imitate (Safari, Chrome) come last.
+type userAgentRule struct {
+	tokens []string
+	name   string
+}
+
+var botTokens = []string{"bot", "crawler", "spider", "slurp", "facebookexternalhit", "preview", "curl/", "wget/", "python-requests", "go-http-client"}
+
+var browserRules = []userAgentRule{
+	{[]string{"edg/", "edge/", "edga/", "edgios/"}, "Edge"},
+	{[]string{"opr/", "opera", "opt/"}, "Opera"},
+	{[]string{"samsungbrowser/"}, "Samsung Internet"},
+	{[]string{"yabrowser/"}, "Yandex"},
+	{[]string{"ucbrowser/"}, "UC Browser"},
+	{[]string{"fban/", "fbav/"}, "Facebook"},
+	{[]string{"instagram"}, "Instagram"},
+	{[]string{"firefox/", "fxios/"}, "Firefox"},
+	{[]string{"chrome/", "crios/", "chromium/"}, "Chrome"},
+	{[]string{"msie ", "trident/"}, "Internet Explorer"},
+	{[]string{"safari/"}, "Safari"},
+}
+
+var osRules = []userAgentRule{
+	{[]string{"iphone", "ipad", "ipod"}, "iOS"},
+	{[]string{"android"}, "Android"},
+	{[]string{"windows"}, "Windows"},
+	{[]string{"cros "}, "ChromeOS"},
+	{[]string{"mac os x", "macintosh"}, "macOS"},
+	{[]string{"linux", "x11"}, "Linux"},
+}
+
+// ParseUserAgent identifies the client behind a User-Agent header. Unrecognized
+// browsers and operating systems are reported as "Other".
+func ParseUserAgent(userAgent string) ClientInfo {
+	ua := strings.ToLower(strings.TrimSpace(userAgent))
+	if ua == "" {
+		return ClientInfo{Browser: UnknownClient, Device: UnknownClient, OS: UnknownClient}
+	}
+
+	info := ClientInfo{
+		Browser: matchUserAgent(ua, browserRules),
+		OS:      matchUserAgent(ua, osRules),
+	}
+
+	switch {
+	case containsAny(ua, botTokens):
+		info.Device = DeviceBot
+		info.Browser = DeviceBot
+	case containsAny(ua, []string{"ipad", "tablet", "kindle", "silk/"}),
+		strings.Contains(ua, "android") && !strings.Contains(ua, "mobile"):
+		info.Device = DeviceTablet
+	case containsAny(ua, []string{"mobi", "iphone", "ipod", "android", "windows phone"}):
+		info.Device = DeviceMobile
+	default:
+		info.Device = DeviceDesktop
+	}
+
+	return info
+}
+
+// matchUserAgent returns the name of the first rule with a token in ua
+func matchUserAgent(ua string, rules []userAgentRule) string {
+	for _, rule := range rules {
+		if containsAny(ua, rule.tokens) {
+			return rule.name
+		}
+	}
+	return "Other"
+}
+
+// containsAny reports whether s contains any of the substrings
+func containsAny(s string, substrings []string) bool {
+	for _, substring := range substrings {
+		if strings.Contains(s, substring) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// OTPPurposeEmailAlias is the purpose of codes verifying a secondary email
+const OTPPurposeEmailAlias = "email_alias_verification"
+
+// MaxUserEmails bounds how many secondary emails an account can have
+const MaxUserEmails = 5
+
+// UnverifiedUserEmailTTL is how long a secondary email stays on an account
+// without being verified
+const UnverifiedUserEmailTTL = 24 * time.Hour
+
+// UserEmail is a secondary email address of an account. Verified addresses
+// can be used to log in; notifications go to the primary address.
+type UserEmail struct {
+	ID         int        `db:"id" json:"id"`
+	UserID     int        `db:"user_id" json:"-"`
+	Email      string     `db:"email" json:"email"`
+	VerifiedAt *time.Time `db:"verified_at" json:"verified_at,omitempty"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+}
+
+// IsVerified returns true if the owner proved they receive mail at the address
+func (e *UserEmail) IsVerified() bool {
+	return e.VerifiedAt != nil
+}
+
+// UserEmailsResponse lists an account's addresses
+type UserEmailsResponse struct {
+	Primary string      `json:"primary"`
+	Emails  []UserEmail `json:"emails"`
+}
+
+// AddUserEmailRequest adds a secondary email to the caller's account
+type AddUserEmailRequest struct {
+	Email string `json:"email" binding:"required"`
+}
+
+// Validate validates and normalizes the request
+func (req *AddUserEmailRequest) Validate() error {
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if _, err := mail.ParseAddress(req.Email); err != nil {
+		return fmt.Errorf("invalid email format")
+	}
+	return nil
+}
+
+// VerifyUserEmailRequest verifies a secondary email with the code sent to it
+type VerifyUserEmailRequest struct {
+	OTPCode string `json:"otp_code" binding:"required"`
+}
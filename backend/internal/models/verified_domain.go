@@ -0,0 +1,58 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DestinationVerificationPrefix is the DNS label holding a destination domain's TXT verification record
+const DestinationVerificationPrefix = "_urlshortener-owner"
+
+// VerifiedDomain is a destination domain a user claims to own. Once its TXT
+// record proves it, links the user creates to the domain and its subdomains get
+// a higher creation limit and skip the suspicious-link warning.
+type VerifiedDomain struct {
+	ID                int        `db:"id" json:"id"`
+	UserID            int        `db:"user_id" json:"user_id"`
+	Domain            string     `db:"domain" json:"domain"`
+	VerificationToken string     `db:"verification_token" json:"verification_token"`
+	VerifiedAt        *time.Time `db:"verified_at" json:"verified_at,omitempty"`
+	CreatedAt         time.Time  `db:"created_at" json:"created_at"`
+}
+
+// IsVerified returns true once DNS ownership of the domain has been proven
+func (d *VerifiedDomain) IsVerified() bool {
+	return d.VerifiedAt != nil
+}
+
+// VerificationRecord returns the DNS name that must hold the verification token
+func (d *VerifiedDomain) VerificationRecord() string {
+	return DestinationVerificationPrefix + "." + d.Domain
+}
+
+// CreateVerifiedDomainRequest represents a request to claim a destination domain
+type CreateVerifiedDomainRequest struct {
+	Domain string `json:"domain" binding:"required"`
+}
+
+// Validate validates and normalizes the claim request
+func (req *CreateVerifiedDomainRequest) Validate() error {
+	req.Domain = strings.TrimPrefix(NormalizeHostname(req.Domain), "www.")
+	if !hostnamePattern.MatchString(req.Domain) {
+		return fmt.Errorf("domain must be a valid domain name")
+	}
+	return nil
+}
+
+// VerifiedDomainCandidates returns the domains whose verification covers a
+// host: the host itself and each parent domain (www.shop.example.com is
+// covered by shop.example.com and example.com)
+func VerifiedDomainCandidates(host string) []string {
+	candidates := []string{}
+	for host != "" && strings.Contains(host, ".") {
+		candidates = append(candidates, host)
+		_, host, _ = strings.Cut(host, ".")
+	}
+	return candidates
+}
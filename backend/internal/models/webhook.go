@@ -0,0 +1,147 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Webhook events a link subscription can filter on
+const (
+	WebhookEventLinkClicked            = "link.clicked"
+	WebhookEventLinkUpdated            = "link.updated"
+	WebhookEventLinkExtended           = "link.extended"
+	WebhookEventLinkClickThreshold     = "link.click_threshold"
+	WebhookEventLinkDestinationChanged = "link.destination_changed"
+)
+
+// WebhookEvents lists every supported webhook event
+var WebhookEvents = []string{
+	WebhookEventLinkClicked,
+	WebhookEventLinkUpdated,
+	WebhookEventLinkExtended,
+	WebhookEventLinkClickThreshold,
+	WebhookEventLinkDestinationChanged,
+}
+
+// Webhook represents a webhook subscribed to a single link
+type Webhook struct {
+	ID        int       `db:"id" json:"id"`
+	UserID    int       `db:"user_id" json:"user_id"`
+	URLID     int       `db:"url_id" json:"url_id"`
+	TargetURL string    `db:"target_url" json:"target_url"`
+	Secret    string    `db:"secret" json:"-"` // Only returned once, on creation
+	Events    []string  `db:"events" json:"events"`
+	IsActive  bool      `db:"is_active" json:"is_active"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Subscribes returns true if the webhook should receive the event
+func (w *Webhook) Subscribes(event string) bool {
+	for _, subscribed := range w.Events {
+		if subscribed == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery records one attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	ID         int       `db:"id" json:"id"`
+	WebhookID  int       `db:"webhook_id" json:"webhook_id"`
+	Event      string    `db:"event" json:"event"`
+	Payload    string    `db:"payload" json:"payload"`
+	StatusCode *int      `db:"status_code" json:"status_code,omitempty"`
+	Error      *string   `db:"error" json:"error,omitempty"`
+	Success    bool      `db:"success" json:"success"`
+	DurationMs int       `db:"duration_ms" json:"duration_ms"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// WebhookPayload is the JSON body POSTed to webhook endpoints
+type WebhookPayload struct {
+	Event     string      `json:"event"`
+	ShortCode string      `json:"short_code"`
+	Timestamp time.Time   `json:"timestamp"`
+	Test      bool        `json:"test,omitempty"` // Sent with sample data on request, not by a real event
+	Data      interface{} `json:"data"`
+}
+
+// CreateWebhookRequest represents a request to subscribe a webhook to a link
+type CreateWebhookRequest struct {
+	TargetURL string   `json:"target_url" binding:"required" validate:"required,url"`
+	Events    []string `json:"events" binding:"required" validate:"required,min=1"`
+}
+
+// CreateWebhookResponse represents a newly created webhook including its signing secret
+type CreateWebhookResponse struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+// UpdateWebhookRequest represents a request to update a webhook
+type UpdateWebhookRequest struct {
+	TargetURL string   `json:"target_url,omitempty" validate:"omitempty,url"`
+	Events    []string `json:"events,omitempty"`
+	IsActive  *bool    `json:"is_active,omitempty"`
+}
+
+// Validate validates the create webhook request
+func (req *CreateWebhookRequest) Validate() error {
+	if err := validateWebhookTarget(req.TargetURL); err != nil {
+		return err
+	}
+	if len(req.Events) == 0 {
+		return fmt.Errorf("at least one event is required")
+	}
+	return validateWebhookEvents(req.Events)
+}
+
+// Validate validates the update webhook request
+func (req *UpdateWebhookRequest) Validate() error {
+	if req.TargetURL != "" {
+		if err := validateWebhookTarget(req.TargetURL); err != nil {
+			return err
+		}
+	}
+	if req.Events != nil {
+		if len(req.Events) == 0 {
+			return fmt.Errorf("at least one event is required")
+		}
+		if err := validateWebhookEvents(req.Events); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateWebhookTarget checks that a webhook endpoint is an absolute HTTP(S) URL
+func validateWebhookTarget(target string) error {
+	parsed, err := url.ParseRequestURI(target)
+	if err != nil || parsed.Host == "" {
+		return fmt.Errorf("target URL must be a valid URL")
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("target URL must use http or https")
+	}
+	return nil
+}
+
+// validateWebhookEvents checks that every event is supported
+func validateWebhookEvents(events []string) error {
+	for _, event := range events {
+		supported := false
+		for _, known := range WebhookEvents {
+			if event == known {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return fmt.Errorf("unsupported webhook event: %s", event)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// WebhookEventType describes a webhook event for integrators: what triggers
+// it, the JSON schema of its payload and an example payload
+type WebhookEventType struct {
+	Event       string                 `json:"event"`
+	Description string                 `json:"description"`
+	Schema      map[string]interface{} `json:"schema"`
+	Example     WebhookPayload         `json:"example"`
+}
+
+// TestWebhookRequest selects the event a test delivery samples. It defaults
+// to the first event the webhook subscribes to.
+type TestWebhookRequest struct {
+	Event string `json:"event,omitempty"`
+}
+
+// webhookEventDescriptions explains when each webhook event is sent
+var webhookEventDescriptions = map[string]string{
+	WebhookEventLinkClicked:            "A visitor was redirected. The data is the click.",
+	WebhookEventLinkUpdated:            "The link was edited, deactivated or otherwise changed. The data is the link after the change.",
+	WebhookEventLinkExtended:           "The link's expiration was pushed back. The data is the extension.",
+	WebhookEventLinkClickThreshold:     "The link reached a click trigger's threshold. The data is the trigger and the click count.",
+	WebhookEventLinkDestinationChanged: "The link's destination changed, by an edit or a canary rollout. The data is the change.",
+}
+
+// SampleWebhookData returns realistic data for an event about the link, for
+// test deliveries and the event catalog. Only the link itself is real.
+func SampleWebhookData(event string, url *URL, now time.Time) (interface{}, error) {
+	userID := url.UserID
+	switch event {
+	case WebhookEventLinkClicked:
+		return &ClickEvent{
+			ID:           1,
+			URLId:        url.ID,
+			IPAddress:    "203.0.113.7",
+			UserAgent:    "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+			Referer:      "https://news.example.com/",
+			Country:      "US",
+			City:         "Seattle",
+			Browser:      "Safari",
+			Device:       "Mobile",
+			OS:           "iOS",
+			ClickedAt:    now,
+			SampleWeight: 1,
+		}, nil
+	case WebhookEventLinkUpdated:
+		return url, nil
+	case WebhookEventLinkExtended:
+		previous := now.Add(24 * time.Hour)
+		if url.ExpiresAt != nil {
+			previous = *url.ExpiresAt
+		}
+		return &ExpirationExtension{
+			ID:                1,
+			URLID:             url.ID,
+			UserID:            &userID,
+			PreviousExpiresAt: previous,
+			NewExpiresAt:      previous.Add(7 * 24 * time.Hour),
+			DurationSeconds:   int64((7 * 24 * time.Hour).Seconds()),
+			CreatedAt:         now,
+		}, nil
+	case WebhookEventLinkClickThreshold:
+		return &ClickThresholdEvent{
+			TriggerID: 1,
+			Kind:      ClickTriggerReach,
+			Threshold: 1000,
+			Clicks:    1000,
+		}, nil
+	case WebhookEventLinkDestinationChanged:
+		return &DestinationChange{
+			ID:          1,
+			URLID:       url.ID,
+			UserID:      &userID,
+			PreviousURL: url.OriginalURL,
+			NewURL:      "https://example.com/new-landing-page",
+			CreatedAt:   now,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported webhook event: %s", event)
+	}
+}
+
+// WebhookEventCatalog describes every supported webhook event, with examples
+// about a placeholder link
+func WebhookEventCatalog(now time.Time) []WebhookEventType {
+	now = now.UTC().Truncate(time.Second)
+	link := &URL{
+		ID:          42,
+		ShortCode:   "abc123",
+		OriginalURL: "https://example.com/landing-page",
+		UserID:      7,
+		CreatedAt:   now.Add(-30 * 24 * time.Hour),
+		UpdatedAt:   now,
+		ClickCount:  1000,
+		IsActive:    true,
+	}
+
+	catalog := make([]WebhookEventType, 0, len(WebhookEvents))
+	for _, event := range WebhookEvents {
+		data, err := SampleWebhookData(event, link, now)
+		if err != nil {
+			continue
+		}
+		catalog = append(catalog, WebhookEventType{
+			Event:       event,
+			Description: webhookEventDescriptions[event],
+			Schema:      webhookPayloadSchema(event, reflect.TypeOf(data)),
+			Example: WebhookPayload{
+				Event:     event,
+				ShortCode: link.ShortCode,
+				Timestamp: now,
+				Data:      data,
+			},
+		})
+	}
+	return catalog
+}
+
+// webhookPayloadSchema returns the JSON schema of an event's payload whose data is of type data
+func webhookPayloadSchema(event string, data reflect.Type) map[string]interface{} {
+	schema := jsonSchema(reflect.TypeOf(WebhookPayload{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = event
+
+	properties := schema["properties"].(map[string]interface{})
+	properties["event"] = map[string]interface{}{"type": "string", "const": event}
+	properties["data"] = jsonSchema(data)
+	return schema
+}
+
+// jsonSchema describes how encoding/json serializes values of type t
+func jsonSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchema(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []string{}
+		addStructFields(t, properties, &required)
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	default:
+		// interface{} and anything else encoding/json decides at run time
+		return map[string]interface{}{}
+	}
+}
+
+// addStructFields adds the JSON fields of a struct, including those of
+// embedded structs, to a schema. Fields without omitempty are required.
+func addStructFields(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				addStructFields(embedded, properties, required)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = jsonSchema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/lib/pq"
+)
+
+// AccountDeletionRepository interface defines the contract for account deletion
+// tracking, kept in the home region, and for purging a user's link data from
+// the context's region one chunk at a time
+type AccountDeletionRepository interface {
+	Create(ctx context.Context, deletion *models.AccountDeletion) (*models.AccountDeletion, error)
+	GetByID(ctx context.Context, id int) (*models.AccountDeletion, error)
+	GetAll(ctx context.Context, filter *models.AccountDeletionFilter) ([]models.AccountDeletion, error)
+	GetStalled(ctx context.Context, before time.Time) ([]models.AccountDeletion, error)
+	Advance(ctx context.Context, deletion *models.AccountDeletion, chunk models.PurgeProgress, stage, region string) (bool, error)
+	Complete(ctx context.Context, id int) error
+	RecordFailure(ctx context.Context, id int, message string, failed bool) error
+	Resume(ctx context.Context, id int) (bool, error)
+
+	GetActiveShortCodes(ctx context.Context, userID, limit int) ([]string, error)
+	DeactivateURLs(ctx context.Context, userID int, shortCodes []string) error
+	DeleteClickEvents(ctx context.Context, userID, limit int) (int64, error)
+	DeleteClickAggregates(ctx context.Context, userID, limit int) (int64, error)
+	DeleteURLs(ctx context.Context, userID, limit int) ([]string, error)
+	DeleteUser(ctx context.Context, userID int) error
+}
+
+// accountDeletionColumns lists the columns selected for an account deletion, in scanAccountDeletion order
+const accountDeletionColumns = `id, user_id, email, requested_by, status, stage, region, chunks,
+			   caches_purged, click_events_deleted, aggregates_deleted, urls_deleted,
+			   attempts, error, created_at, updated_at, completed_at`
+
+// scanAccountDeletion scans a row selected with accountDeletionColumns into an account deletion
+func scanAccountDeletion(row rowScanner, deletion *models.AccountDeletion) error {
+	return row.Scan(
+		&deletion.ID, &deletion.UserID, &deletion.Email, &deletion.RequestedBy,
+		&deletion.Status, &deletion.Stage, &deletion.Region, &deletion.Chunks,
+		&deletion.CachesPurged, &deletion.ClickEventsDeleted, &deletion.AggregatesDeleted, &deletion.URLsDeleted,
+		&deletion.Attempts, &deletion.Error, &deletion.CreatedAt, &deletion.UpdatedAt, &deletion.CompletedAt,
+	)
+}
+
+// accountDeletionRepository implements AccountDeletionRepository interface
+type accountDeletionRepository struct {
+	regions *RegionRouter
+}
+
+// NewAccountDeletionRepository creates a new account deletion repository
+func NewAccountDeletionRepository(regions *RegionRouter) AccountDeletionRepository {
+	return &accountDeletionRepository{regions: regions}
+}
+
+// Create records a pending account deletion, returning ErrDuplicate if one is
+// already in progress for the user
+func (r *accountDeletionRepository) Create(ctx context.Context, deletion *models.AccountDeletion) (*models.AccountDeletion, error) {
+	query := `
+		INSERT INTO account_deletions (user_id, email, requested_by, status, stage, region)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + accountDeletionColumns
+
+	err := scanAccountDeletion(r.regions.HomeDB().QueryRowContext(ctx, query,
+		deletion.UserID, deletion.Email, deletion.RequestedBy, deletion.Status, deletion.Stage, deletion.Region,
+	), deletion)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("account deletion %w", ErrDuplicate)
+		}
+		return nil, fmt.Errorf("failed to create account deletion: %w", err)
+	}
+
+	return deletion, nil
+}
+
+// GetByID retrieves an account deletion by ID
+func (r *accountDeletionRepository) GetByID(ctx context.Context, id int) (*models.AccountDeletion, error) {
+	query := `SELECT ` + accountDeletionColumns + ` FROM account_deletions WHERE id = $1`
+
+	deletion := &models.AccountDeletion{}
+	if err := scanAccountDeletion(r.regions.HomeDB().QueryRowContext(ctx, query, id), deletion); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("account deletion %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get account deletion: %w", err)
+	}
+
+	return deletion, nil
+}
+
+// GetAll retrieves account deletions matching the filter, newest first
+func (r *accountDeletionRepository) GetAll(ctx context.Context, filter *models.AccountDeletionFilter) ([]models.AccountDeletion, error) {
+	query := `
+		SELECT ` + accountDeletionColumns + `
+		FROM account_deletions
+		WHERE ($1 = '' OR status = $1)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2`
+
+	rows, err := r.regions.HomeDB().QueryContext(ctx, query, filter.Status, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account deletions: %w", err)
+	}
+	return scanAccountDeletions(rows)
+}
+
+// GetStalled retrieves the deletions in progress that haven't changed since before
+func (r *accountDeletionRepository) GetStalled(ctx context.Context, before time.Time) ([]models.AccountDeletion, error) {
+	query := `
+		SELECT ` + accountDeletionColumns + `
+		FROM account_deletions
+		WHERE status IN ('pending', 'running') AND updated_at < $1
+		ORDER BY id`
+
+	rows, err := r.regions.HomeDB().QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stalled account deletions: %w", err)
+	}
+	return scanAccountDeletions(rows)
+}
+
+// scanAccountDeletions scans and closes rows selected with accountDeletionColumns
+func scanAccountDeletions(rows *sql.Rows) ([]models.AccountDeletion, error) {
+	defer rows.Close()
+
+	var deletions []models.AccountDeletion
+	for rows.Next() {
+		var deletion models.AccountDeletion
+		if err := scanAccountDeletion(rows, &deletion); err != nil {
+			return nil, fmt.Errorf("failed to scan account deletion: %w", err)
+		}
+		deletions = append(deletions, deletion)
+	}
+
+	return deletions, rows.Err()
+}
+
+// Advance adds a purged chunk to the deletion's progress and moves it to the
+// given stage and region. It returns false, changing nothing, if the deletion
+// has moved on since it was read, so a redelivered job doesn't start a second
+// chain of jobs.
+func (r *accountDeletionRepository) Advance(ctx context.Context, deletion *models.AccountDeletion, chunk models.PurgeProgress, stage, region string) (bool, error) {
+	query := `
+		UPDATE account_deletions
+		SET status = $5, stage = $6, region = $7, chunks = chunks + 1,
+		    caches_purged = caches_purged + $8, click_events_deleted = click_events_deleted + $9,
+		    aggregates_deleted = aggregates_deleted + $10, urls_deleted = urls_deleted + $11,
+		    attempts = 0, error = '', updated_at = $12
+		WHERE id = $1 AND stage = $2 AND region = $3 AND chunks = $4 AND status IN ('pending', 'running')`
+
+	result, err := r.regions.HomeDB().ExecContext(ctx, query,
+		deletion.ID, deletion.Stage, deletion.Region, deletion.Chunks,
+		models.AccountDeletionRunning, stage, region,
+		chunk.CachesPurged, chunk.ClickEventsDeleted, chunk.AggregatesDeleted, chunk.URLsDeleted,
+		time.Now(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to advance account deletion: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// Complete marks the deletion completed
+func (r *accountDeletionRepository) Complete(ctx context.Context, id int) error {
+	query := `
+		UPDATE account_deletions
+		SET status = $2, attempts = 0, error = '', updated_at = $3, completed_at = $3
+		WHERE id = $1`
+
+	if _, err := r.regions.HomeDB().ExecContext(ctx, query, id, models.AccountDeletionCompleted, time.Now()); err != nil {
+		return fmt.Errorf("failed to complete account deletion: %w", err)
+	}
+	return nil
+}
+
+// RecordFailure records a failed attempt at the deletion's current chunk,
+// marking the deletion failed when it won't be retried
+func (r *accountDeletionRepository) RecordFailure(ctx context.Context, id int, message string, failed bool) error {
+	query := `
+		UPDATE account_deletions
+		SET attempts = attempts + 1, error = $2, updated_at = $3,
+		    status = CASE WHEN $4 THEN $5 ELSE status END,
+		    completed_at = CASE WHEN $4 THEN $3 ELSE completed_at END
+		WHERE id = $1`
+
+	_, err := r.regions.HomeDB().ExecContext(ctx, query, id, message, time.Now(), failed, models.AccountDeletionFailed)
+	if err != nil {
+		return fmt.Errorf("failed to record account deletion failure: %w", err)
+	}
+	return nil
+}
+
+// Resume puts a failed deletion back in progress at the chunk it failed on,
+// returning false if it hasn't failed
+func (r *accountDeletionRepository) Resume(ctx context.Context, id int) (bool, error) {
+	query := `
+		UPDATE account_deletions
+		SET status = $2, attempts = 0, updated_at = $3, completed_at = NULL
+		WHERE id = $1 AND status = $4`
+
+	result, err := r.regions.HomeDB().ExecContext(ctx, query, id, models.AccountDeletionRunning, time.Now(), models.AccountDeletionFailed)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return false, fmt.Errorf("account deletion %w", ErrDuplicate)
+		}
+		return false, fmt.Errorf("failed to resume account deletion: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// GetActiveShortCodes returns the short codes of up to limit of the user's
+// active links in the context's region
+func (r *accountDeletionRepository) GetActiveShortCodes(ctx context.Context, userID, limit int) ([]string, error) {
+	query := `SELECT short_code FROM urls WHERE user_id = $1 AND is_active = TRUE ORDER BY id LIMIT $2`
+
+	return r.shortCodes(ctx, "list", query, userID, limit)
+}
+
+// DeactivateURLs deactivates the user's links with the given short codes in
+// the context's region, so they stop redirecting
+func (r *accountDeletionRepository) DeactivateURLs(ctx context.Context, userID int, shortCodes []string) error {
+	query := `UPDATE urls SET is_active = FALSE WHERE user_id = $1 AND short_code = ANY($2)`
+
+	if _, err := r.regions.DB(ctx).ExecContext(ctx, query, userID, pq.Array(shortCodes)); err != nil {
+		return fmt.Errorf("failed to deactivate URLs: %w", err)
+	}
+	return nil
+}
+
+// DeleteClickEvents deletes up to limit of the raw click and blocked click
+// events of the user's links in the context's region, then of the lifecycle
+// events of the user's links, including those deleted before
+func (r *accountDeletionRepository) DeleteClickEvents(ctx context.Context, userID, limit int) (int64, error) {
+	deleted, err := r.deleteChunk(ctx, userID, limit, []string{"click_events", "blocked_clicks"}, "id")
+	if err != nil || deleted >= int64(limit) {
+		return deleted, err
+	}
+
+	query := `DELETE FROM url_events WHERE id IN (SELECT id FROM url_events WHERE user_id = $1 LIMIT $2)`
+
+	result, err := r.regions.DB(ctx).ExecContext(ctx, query, userID, int64(limit)-deleted)
+	if err != nil {
+		return deleted, fmt.Errorf("failed to delete url_events: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return deleted, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return deleted + rowsAffected, nil
+}
+
+// DeleteClickAggregates deletes up to limit of the click rollups of the
+// user's links in the context's region
+func (r *accountDeletionRepository) DeleteClickAggregates(ctx context.Context, userID, limit int) (int64, error) {
+	// The rollup tables are keyed by (url_id, ...), so rows are picked by ctid
+	return r.deleteChunk(ctx, userID, limit, []string{"click_aggregates", "click_dimension_aggregates", "url_analytics"}, "ctid")
+}
+
+// deleteChunk deletes up to limit rows of the user's links from the tables in
+// order, moving to the next table only once the previous one is empty
+func (r *accountDeletionRepository) deleteChunk(ctx context.Context, userID, limit int, tables []string, key string) (int64, error) {
+	var deleted int64
+	for _, table := range tables {
+		if deleted >= int64(limit) {
+			break
+		}
+
+		query := fmt.Sprintf(`
+			DELETE FROM %[1]s
+			WHERE %[2]s IN (
+				SELECT t.%[2]s FROM %[1]s t
+				JOIN urls u ON u.id = t.url_id
+				WHERE u.user_id = $1
+				LIMIT $2
+			)`, table, key)
+
+		result, err := r.regions.DB(ctx).ExecContext(ctx, query, userID, int64(limit)-deleted)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete %s: %w", table, err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		deleted += rowsAffected
+	}
+	return deleted, nil
+}
+
+// DeleteURLs deletes up to limit of the user's links in the context's region,
+// along with whatever rows still reference them and their event logs, and
+// returns their short codes
+func (r *accountDeletionRepository) DeleteURLs(ctx context.Context, userID, limit int) ([]string, error) {
+	// Event logs don't reference their links, so they are deleted explicitly
+	query := `
+		WITH deleted AS (
+			DELETE FROM urls
+			WHERE id IN (SELECT id FROM urls WHERE user_id = $1 LIMIT $2)
+			RETURNING id, short_code
+		), events AS (
+			DELETE FROM url_events WHERE url_id IN (SELECT id FROM deleted)
+		)
+		SELECT short_code FROM deleted`
+
+	return r.shortCodes(ctx, "delete", query, userID, limit)
+}
+
+// shortCodes runs a chunked statement on the user's links, returning the short codes it touched
+func (r *accountDeletionRepository) shortCodes(ctx context.Context, action, query string, userID, limit int) ([]string, error) {
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s URLs: %w", action, err)
+	}
+	defer rows.Close()
+
+	var shortCodes []string
+	for rows.Next() {
+		var shortCode string
+		if err := rows.Scan(&shortCode); err != nil {
+			return nil, fmt.Errorf("failed to scan short code: %w", err)
+		}
+		shortCodes = append(shortCodes, shortCode)
+	}
+
+	return shortCodes, rows.Err()
+}
+
+// DeleteUser deletes the user's row from the context's region: the account
+// itself at home, or the row mirrored for its links elsewhere. Rows that
+// reference the user are deleted with it. Users already gone are ignored.
+func (r *accountDeletionRepository) DeleteUser(ctx context.Context, userID int) error {
+	if _, err := r.regions.DB(ctx).ExecContext(ctx, `DELETE FROM users WHERE id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	return nil
+}
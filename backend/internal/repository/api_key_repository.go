@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hpower2/url-shortener/database"
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// APIKeyRepository interface defines the contract for API key database operations
+type APIKeyRepository interface {
+	Create(ctx context.Context, apiKey *models.APIKey) (*models.APIKey, error)
+	GetByKeyID(ctx context.Context, keyID string) (*models.APIKey, error)
+	GetAllByUser(ctx context.Context, userID int) ([]models.APIKey, error)
+	Revoke(ctx context.Context, id int, userID int) error
+	UpdateLastUsed(ctx context.Context, id int) error
+}
+
+// apiKeyRepository implements APIKeyRepository interface
+type apiKeyRepository struct {
+	db *database.DB
+}
+
+// NewAPIKeyRepository creates a new API key repository
+func NewAPIKeyRepository(db *database.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+// Create creates a new API key record
+func (r *apiKeyRepository) Create(ctx context.Context, apiKey *models.APIKey) (*models.APIKey, error) {
+	query := `
+		INSERT INTO api_keys (user_id, name, key_id, secret, is_active, sandbox, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		apiKey.UserID, apiKey.Name, apiKey.KeyID, apiKey.Secret, apiKey.IsActive, apiKey.Sandbox, apiKey.CreatedAt,
+	).Scan(&apiKey.ID, &apiKey.CreatedAt)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return apiKey, nil
+}
+
+// GetByKeyID retrieves an API key by its public key ID
+func (r *apiKeyRepository) GetByKeyID(ctx context.Context, keyID string) (*models.APIKey, error) {
+	query := `
+		SELECT id, user_id, name, key_id, secret, is_active, sandbox, last_used_at, created_at, revoked_at
+		FROM api_keys
+		WHERE key_id = $1`
+
+	apiKey := &models.APIKey{}
+	err := r.db.QueryRowContext(ctx, query, keyID).Scan(
+		&apiKey.ID, &apiKey.UserID, &apiKey.Name, &apiKey.KeyID, &apiKey.Secret,
+		&apiKey.IsActive, &apiKey.Sandbox, &apiKey.LastUsedAt, &apiKey.CreatedAt, &apiKey.RevokedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("API key %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	return apiKey, nil
+}
+
+// GetAllByUser retrieves all API keys for a user
+func (r *apiKeyRepository) GetAllByUser(ctx context.Context, userID int) ([]models.APIKey, error) {
+	query := `
+		SELECT id, user_id, name, key_id, secret, is_active, sandbox, last_used_at, created_at, revoked_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API keys: %w", err)
+	}
+	defer rows.Close()
+
+	apiKeys := []models.APIKey{}
+	for rows.Next() {
+		var apiKey models.APIKey
+		err := rows.Scan(
+			&apiKey.ID, &apiKey.UserID, &apiKey.Name, &apiKey.KeyID, &apiKey.Secret,
+			&apiKey.IsActive, &apiKey.Sandbox, &apiKey.LastUsedAt, &apiKey.CreatedAt, &apiKey.RevokedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %w", err)
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+
+	return apiKeys, nil
+}
+
+// Revoke deactivates an API key owned by a user
+func (r *apiKeyRepository) Revoke(ctx context.Context, id int, userID int) error {
+	query := `
+		UPDATE api_keys
+		SET is_active = FALSE, revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND is_active = TRUE`
+
+	result, err := r.db.ExecContext(ctx, query, id, userID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("API key %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdateLastUsed records that an API key was used
+func (r *apiKeyRepository) UpdateLastUsed(ctx context.Context, id int) error {
+	query := "UPDATE api_keys SET last_used_at = $2 WHERE id = $1"
+	_, err := r.db.ExecContext(ctx, query, id, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update API key last used: %w", err)
+	}
+	return nil
+}
@@ -2,69 +2,272 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sync/atomic"
 	"time"
 
-	"github.com/hpower2/url-shortener/redis"
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/hpower2/url-shortener/internal/models"
 )
 
 // cacheRepository implements CacheRepository interface
 type cacheRepository struct {
-	redis *redis.Client
+	regions *RegionRouter
+
+	// Outcomes of URL lookups since startup
+	urlHits   atomic.Int64
+	urlMisses atomic.Int64
+	urlErrors atomic.Int64
 }
 
-// NewCacheRepository creates a new cache repository
-func NewCacheRepository(redis *redis.Client) CacheRepository {
-	return &cacheRepository{redis: redis}
+// NewCacheRepository creates a new cache repository. Keys are stored in the
+// Redis pool of the data region set on the context.
+func NewCacheRepository(regions *RegionRouter) CacheRepository {
+	return &cacheRepository{regions: regions}
 }
 
-// SetURL caches a URL mapping
+// SetURL caches a URL mapping, dropping the link's cached resolution
 func (r *cacheRepository) SetURL(ctx context.Context, shortCode, originalURL string, expiration time.Duration) error {
 	key := fmt.Sprintf("url:%s", shortCode)
-	return r.redis.Set(ctx, key, originalURL, expiration).Err()
+	pipe := r.regions.Cache(ctx).TxPipeline()
+	pipe.Set(ctx, key, originalURL, expiration)
+	pipe.Del(ctx, resolvedKey(shortCode))
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// SetURLIfAbsent caches a URL mapping unless one, or a kill switch tombstone,
+// is already cached. Used to refill the cache from the database, whose read
+// may predate a kill.
+func (r *cacheRepository) SetURLIfAbsent(ctx context.Context, shortCode, originalURL string, expiration time.Duration) error {
+	key := fmt.Sprintf("url:%s", shortCode)
+	return r.regions.Cache(ctx).SetNX(ctx, key, originalURL, expiration).Err()
+}
+
+// TombstoneURL replaces a cached URL with the kill switch tombstone
+func (r *cacheRepository) TombstoneURL(ctx context.Context, shortCode string, expiration time.Duration) error {
+	return r.SetURL(ctx, shortCode, models.KillSwitchTombstone, expiration)
 }
 
-// GetURL retrieves a cached URL
+// PublishLinkKill broadcasts a killed short code, returning how many instances received it
+func (r *cacheRepository) PublishLinkKill(ctx context.Context, shortCode string) (int64, error) {
+	return r.regions.Cache(ctx).Publish(ctx, models.KillSwitchChannel, shortCode).Result()
+}
+
+// SubscribeLinkKills calls onKill with each short code killed in any data
+// region, until ctx is cancelled
+func (r *cacheRepository) SubscribeLinkKills(ctx context.Context, onKill func(shortCode string)) {
+	for _, region := range r.regions.Regions() {
+		pubsub := r.regions.Cache(WithRegion(ctx, region)).Subscribe(ctx, models.KillSwitchChannel)
+		go func() {
+			defer pubsub.Close()
+			messages := pubsub.Channel()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case message, ok := <-messages:
+					if !ok {
+						return
+					}
+					onKill(message.Payload)
+				}
+			}
+		}()
+	}
+}
+
+// GetURL retrieves a cached URL, returning ErrCacheMiss when it isn't cached
 func (r *cacheRepository) GetURL(ctx context.Context, shortCode string) (string, error) {
 	key := fmt.Sprintf("url:%s", shortCode)
-	return r.redis.Get(ctx, key).Result()
+	originalURL, err := r.regions.Cache(ctx).Get(ctx, key).Result()
+	err = cacheError(err)
+	switch {
+	case err == nil:
+		r.urlHits.Add(1)
+	case err == ErrCacheMiss:
+		r.urlMisses.Add(1)
+	default:
+		r.urlErrors.Add(1)
+	}
+	return originalURL, err
+}
+
+// URLStats returns the outcomes of URL lookups since startup
+func (r *cacheRepository) URLStats() models.CacheStats {
+	stats := models.CacheStats{
+		Hits:   r.urlHits.Load(),
+		Misses: r.urlMisses.Load(),
+		Errors: r.urlErrors.Load(),
+	}
+	if lookups := stats.Hits + stats.Misses + stats.Errors; lookups > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(lookups)
+	}
+	return stats
 }
 
-// DeleteURL removes a cached URL
+// DeleteURL removes a cached URL and its resolution
 func (r *cacheRepository) DeleteURL(ctx context.Context, shortCode string) error {
 	key := fmt.Sprintf("url:%s", shortCode)
-	return r.redis.Del(ctx, key).Err()
+	return r.regions.Cache(ctx).Del(ctx, key, resolvedKey(shortCode)).Err()
+}
+
+// resolvedKey is the key of a link's cached resolution. It lives and dies
+// with the link's redirect cache entry: every write of that entry drops it.
+func resolvedKey(shortCode string) string {
+	return fmt.Sprintf("resolved:%s", shortCode)
 }
 
-// IncrementClickCount increments the click count in cache
-func (r *cacheRepository) IncrementClickCount(ctx context.Context, shortCode string) error {
+// SetResolvedLink caches how a link resolves
+func (r *cacheRepository) SetResolvedLink(ctx context.Context, resolved *models.ResolvedLink, expiration time.Duration) error {
+	data, err := json.Marshal(resolved)
+	if err != nil {
+		return fmt.Errorf("failed to marshal resolved link: %w", err)
+	}
+	return r.regions.Cache(ctx).Set(ctx, resolvedKey(resolved.ShortCode), data, expiration).Err()
+}
+
+// GetResolvedLink retrieves how a link resolves, returning ErrCacheMiss when it isn't cached
+func (r *cacheRepository) GetResolvedLink(ctx context.Context, shortCode string) (*models.ResolvedLink, error) {
+	data, err := r.regions.Cache(ctx).Get(ctx, resolvedKey(shortCode)).Bytes()
+	if err = cacheError(err); err != nil {
+		return nil, err
+	}
+
+	resolved := &models.ResolvedLink{}
+	if err := json.Unmarshal(data, resolved); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal resolved link: %w", err)
+	}
+	return resolved, nil
+}
+
+// PurgeURLs removes every cache entry of the given links, returning how many were removed
+func (r *cacheRepository) PurgeURLs(ctx context.Context, shortCodes []string) (int64, error) {
+	if len(shortCodes) == 0 {
+		return 0, nil
+	}
+	keys := make([]string, 0, 3*len(shortCodes))
+	for _, shortCode := range shortCodes {
+		keys = append(keys, fmt.Sprintf("url:%s", shortCode), fmt.Sprintf("clicks:%s", shortCode), resolvedKey(shortCode))
+	}
+	return r.regions.Cache(ctx).Del(ctx, keys...).Result()
+}
+
+// IncrementClickCount increments the click count in cache and returns the new count
+func (r *cacheRepository) IncrementClickCount(ctx context.Context, shortCode string) (int64, error) {
 	key := fmt.Sprintf("clicks:%s", shortCode)
-	return r.redis.Incr(ctx, key).Err()
+	return r.regions.Cache(ctx).Incr(ctx, key).Result()
 }
 
-// GetClickCount retrieves the click count from cache
+// SetClickCount overwrites the click count in cache
+func (r *cacheRepository) SetClickCount(ctx context.Context, shortCode string, count int64) error {
+	key := fmt.Sprintf("clicks:%s", shortCode)
+	return r.regions.Cache(ctx).Set(ctx, key, count, 0).Err()
+}
+
+// GetClickCount retrieves the click count from cache, returning ErrCacheMiss when it isn't cached
 func (r *cacheRepository) GetClickCount(ctx context.Context, shortCode string) (int64, error) {
 	key := fmt.Sprintf("clicks:%s", shortCode)
-	return r.redis.Get(ctx, key).Int64()
+	count, err := r.regions.Cache(ctx).Get(ctx, key).Int64()
+	return count, cacheError(err)
 }
 
 // Set stores a generic key-value pair
 func (r *cacheRepository) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
-	return r.redis.Set(ctx, key, value, expiration).Err()
+	return r.regions.Cache(ctx).Set(ctx, key, value, expiration).Err()
 }
 
-// Get retrieves a generic value by key
+// Get retrieves a generic value by key, returning ErrCacheMiss when it doesn't exist
 func (r *cacheRepository) Get(ctx context.Context, key string) (string, error) {
-	return r.redis.Get(ctx, key).Result()
+	value, err := r.regions.Cache(ctx).Get(ctx, key).Result()
+	return value, cacheError(err)
 }
 
 // Delete removes a generic key
 func (r *cacheRepository) Delete(ctx context.Context, key string) error {
-	return r.redis.Del(ctx, key).Err()
+	return r.regions.Cache(ctx).Del(ctx, key).Err()
+}
+
+// Take retrieves a generic value by key and deletes it in one step, so only
+// one caller gets it. It returns ErrCacheMiss when the key doesn't exist.
+func (r *cacheRepository) Take(ctx context.Context, key string) (string, error) {
+	value, err := r.regions.Cache(ctx).GetDel(ctx, key).Result()
+	return value, cacheError(err)
 }
 
 // Exists checks if a key exists
 func (r *cacheRepository) Exists(ctx context.Context, key string) (bool, error) {
-	result, err := r.redis.Exists(ctx, key).Result()
+	result, err := r.regions.Cache(ctx).Exists(ctx, key).Result()
 	return result > 0, err
 }
+
+// ExistsAny checks if any of the keys exists, in a single round trip
+func (r *cacheRepository) ExistsAny(ctx context.Context, keys ...string) (bool, error) {
+	result, err := r.regions.Cache(ctx).Exists(ctx, keys...).Result()
+	return result > 0, err
+}
+
+// IncrementWithExpiry increments a counter, setting its expiration when it is first created
+func (r *cacheRepository) IncrementWithExpiry(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	count, err := r.regions.Cache(ctx).Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		if err := r.regions.Cache(ctx).Expire(ctx, key, expiration).Err(); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// SetIfNotExists stores a key only if it does not already exist
+func (r *cacheRepository) SetIfNotExists(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	return r.regions.Cache(ctx).SetNX(ctx, key, value, expiration).Result()
+}
+
+// AcquireLease takes a named lease for ttl unless another instance holds it,
+// so periodic and one-off jobs run on a single replica. Leases aren't released:
+// they expire, which also frees them when their holder dies.
+func (r *cacheRepository) AcquireLease(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	return r.regions.Cache(ctx).SetNX(ctx, "lease:"+name, time.Now().Unix(), ttl).Result()
+}
+
+// AddUnique adds a member to a HyperLogLog counter
+func (r *cacheRepository) AddUnique(ctx context.Context, key, member string) error {
+	return r.regions.Cache(ctx).PFAdd(ctx, key, member).Err()
+}
+
+// CountUnique returns the estimated number of distinct members of a HyperLogLog counter
+func (r *cacheRepository) CountUnique(ctx context.Context, key string) (int64, error) {
+	return r.regions.Cache(ctx).PFCount(ctx, key).Result()
+}
+
+// AddUniqueWithExpiry adds a member to a HyperLogLog counter and returns its
+// estimated number of distinct members, setting the counter's expiration when
+// it is first created
+func (r *cacheRepository) AddUniqueWithExpiry(ctx context.Context, key, member string, expiration time.Duration) (int64, error) {
+	client := r.regions.Cache(ctx)
+	if err := client.PFAdd(ctx, key, member).Err(); err != nil {
+		return 0, err
+	}
+	count, err := client.PFCount(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		if err := client.Expire(ctx, key, expiration).Err(); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// cacheError translates Redis's missing key error to ErrCacheMiss
+func cacheError(err error) error {
+	if err == goredis.Nil {
+		return ErrCacheMiss
+	}
+	return err
+}
@@ -0,0 +1,356 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// ClickArchive reads click events that were moved out of Postgres into long-term
+// storage, such as old partitions exported to object storage. Deployments that
+// archive clicks plug a reader in through NewArchivedClickRepository.
+type ClickArchive interface {
+	// ArchivedBefore returns the time before which click events have been moved
+	// to the archive and deleted from Postgres, or the zero time when none have
+	ArchivedBefore(ctx context.Context) (time.Time, error)
+
+	// GetClickEvents returns the archived click events of a URL matching the query
+	GetClickEvents(ctx context.Context, urlID int, query *models.ArchivedClickQuery) ([]models.ClickEvent, error)
+
+	// GetClickSummary counts the archived clicks of a URL clicked since the given
+	// time (the zero time for all of them)
+	GetClickSummary(ctx context.Context, urlID int, since time.Time) (*models.ClickSummary, error)
+
+	// GetUserClickSummary counts the archived clicks of a user's URLs clicked
+	// since the given time; only Clicks is needed
+	GetUserClickSummary(ctx context.Context, userID int, since time.Time) (*models.ClickSummary, error)
+}
+
+// archivedClickRepository serves click events and analytics from the hot
+// click_events table, and from the archive whenever the requested range
+// reaches back past what the table still holds
+type archivedClickRepository struct {
+	URLRepository
+	archive ClickArchive
+}
+
+// NewArchivedClickRepository wraps a URL repository so its click event and
+// analytics queries also read the archive, merging both
+func NewArchivedClickRepository(urlRepo URLRepository, archive ClickArchive) URLRepository {
+	return &archivedClickRepository{URLRepository: urlRepo, archive: archive}
+}
+
+// archivedBefore returns the archive boundary, failing the query when it is unknown
+func (r *archivedClickRepository) archivedBefore(ctx context.Context) (time.Time, error) {
+	boundary, err := r.archive.ArchivedBefore(ctx)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get click archive boundary: %w", err)
+	}
+	return boundary, nil
+}
+
+// GetClickEvents retrieves a URL's newest click events, completing them with
+// archived events when the table holds fewer than limit
+func (r *archivedClickRepository) GetClickEvents(ctx context.Context, urlID int, limit int) ([]models.ClickEvent, error) {
+	events, err := r.URLRepository.GetClickEvents(ctx, urlID, limit)
+	if err != nil || len(events) >= limit {
+		return events, err
+	}
+	boundary, err := r.archivedBefore(ctx)
+	if err != nil || boundary.IsZero() {
+		return events, err
+	}
+
+	archived, err := r.archive.GetClickEvents(ctx, urlID, &models.ArchivedClickQuery{
+		Until:      boundary,
+		Limit:      limit - len(events),
+		Descending: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archived click events: %w", err)
+	}
+	return mergeClickEvents(events, archived, true), nil
+}
+
+// GetClickEventsBefore pages through a URL's click events newest first,
+// continuing into the archive once the table's events run out
+func (r *archivedClickRepository) GetClickEventsBefore(ctx context.Context, urlID, beforeID int, since time.Time, limit int) ([]models.ClickEvent, error) {
+	events, err := r.URLRepository.GetClickEventsBefore(ctx, urlID, beforeID, since, limit)
+	if err != nil || len(events) >= limit {
+		return events, err
+	}
+	boundary, err := r.archivedBefore(ctx)
+	if err != nil || !since.Before(boundary) {
+		return events, err
+	}
+
+	if len(events) > 0 {
+		beforeID = events[len(events)-1].ID
+	}
+	archived, err := r.archive.GetClickEvents(ctx, urlID, &models.ArchivedClickQuery{
+		Since:      since,
+		Until:      boundary,
+		BeforeID:   beforeID,
+		Limit:      limit - len(events),
+		Descending: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archived click events: %w", err)
+	}
+	return mergeClickEvents(events, archived, true), nil
+}
+
+// GetClickEventsAfter pages through a URL's click events oldest first,
+// starting in the archive when the range begins before its boundary
+func (r *archivedClickRepository) GetClickEventsAfter(ctx context.Context, urlID, afterID int, since, until time.Time, limit int) ([]models.ClickEvent, error) {
+	boundary, err := r.archivedBefore(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !since.Before(boundary) {
+		return r.URLRepository.GetClickEventsAfter(ctx, urlID, afterID, since, until, limit)
+	}
+
+	archiveUntil := until
+	if boundary.Before(archiveUntil) {
+		archiveUntil = boundary
+	}
+	archived, err := r.archive.GetClickEvents(ctx, urlID, &models.ArchivedClickQuery{
+		Since:   since,
+		Until:   archiveUntil,
+		AfterID: afterID,
+		Limit:   limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archived click events: %w", err)
+	}
+	if len(archived) >= limit {
+		return archived, nil
+	}
+
+	if len(archived) > 0 {
+		afterID = archived[len(archived)-1].ID
+	}
+	events, err := r.URLRepository.GetClickEventsAfter(ctx, urlID, afterID, since, until, limit-len(archived))
+	if err != nil {
+		return nil, err
+	}
+	return mergeClickEvents(archived, events, false), nil
+}
+
+// GetAnalytics retrieves a URL's analytics, adding its archived clicks
+func (r *archivedClickRepository) GetAnalytics(ctx context.Context, urlID int, days int, loc *time.Location) (*models.URLAnalytics, error) {
+	analytics, err := r.URLRepository.GetAnalytics(ctx, urlID, days, loc)
+	if err != nil {
+		return nil, err
+	}
+	return analytics, r.addArchivedAnalytics(ctx, urlID, days, loc, analytics)
+}
+
+// GetAnalyticsByUser retrieves the analytics of a user's URL, adding its archived clicks
+func (r *archivedClickRepository) GetAnalyticsByUser(ctx context.Context, urlID int, userID int, days int, loc *time.Location) (*models.URLAnalytics, error) {
+	analytics, err := r.URLRepository.GetAnalyticsByUser(ctx, urlID, userID, days, loc)
+	if err != nil {
+		return nil, err
+	}
+	return analytics, r.addArchivedAnalytics(ctx, urlID, days, loc, analytics)
+}
+
+// addArchivedAnalytics adds a URL's archived clicks to analytics computed from
+// the table, over the same windows. Visitors seen both before and after the
+// archive boundary are counted once on each side.
+func (r *archivedClickRepository) addArchivedAnalytics(ctx context.Context, urlID int, days int, loc *time.Location, analytics *models.URLAnalytics) error {
+	boundary, err := r.archivedBefore(ctx)
+	if err != nil || boundary.IsZero() {
+		return err
+	}
+
+	all, err := r.archive.GetClickSummary(ctx, urlID, time.Time{})
+	if err != nil {
+		return fmt.Errorf("failed to get archived click summary: %w", err)
+	}
+	analytics.TotalClicks += all.Clicks
+	analytics.UniqueClicks += all.UniqueVisitors
+	if all.MaxSampleWeight > 1 {
+		rate := 1 / float64(all.MaxSampleWeight)
+		if !analytics.Sampled || rate < analytics.SampleRate {
+			analytics.SampleRate = rate
+		}
+		analytics.Sampled = true
+	}
+
+	// Same day boundaries as the table's analytics
+	now := time.Now().In(loc)
+	startOfToday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	startOfWeek := startOfToday.AddDate(0, 0, -7)
+	if startOfWeek.Before(boundary) {
+		week, err := r.archive.GetClickSummary(ctx, urlID, startOfWeek)
+		if err != nil {
+			return fmt.Errorf("failed to get archived click summary: %w", err)
+		}
+		for _, hour := range week.Hourly {
+			analytics.ClicksThisWeek += hour.Clicks
+			if !hour.Time.Before(startOfToday) {
+				analytics.ClicksToday += hour.Clicks
+			}
+		}
+	}
+
+	if days <= 0 {
+		days = 30
+	}
+	since := time.Now().AddDate(0, 0, -days)
+	if since.Before(boundary) {
+		window, err := r.archive.GetClickSummary(ctx, urlID, since)
+		if err != nil {
+			return fmt.Errorf("failed to get archived click summary: %w", err)
+		}
+		mergeDimensionStats(analytics, window.Dimensions)
+	}
+	return nil
+}
+
+// GetDashboardTotals counts a user's links and their clicks, adding the
+// archived clicks of the last 7 and 30 days
+func (r *archivedClickRepository) GetDashboardTotals(ctx context.Context, userID int, now time.Time) (*models.Dashboard, error) {
+	dashboard, err := r.URLRepository.GetDashboardTotals(ctx, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	boundary, err := r.archivedBefore(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Same windows as the table's totals
+	for _, window := range []struct {
+		since  time.Time
+		clicks *int
+	}{
+		{now.AddDate(0, 0, -30), &dashboard.ClicksLast30Days},
+		{now.AddDate(0, 0, -7), &dashboard.ClicksLast7Days},
+	} {
+		if !window.since.Before(boundary) {
+			continue
+		}
+		archived, err := r.archive.GetUserClickSummary(ctx, userID, window.since)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get archived click summary: %w", err)
+		}
+		*window.clicks += archived.Clicks
+	}
+	return dashboard, nil
+}
+
+// GetHourlyClicks counts a URL's clicks per UTC hour since the given time,
+// including archived clicks
+func (r *archivedClickRepository) GetHourlyClicks(ctx context.Context, urlID int, since time.Time) ([]models.ClickCount, error) {
+	counts, err := r.URLRepository.GetHourlyClicks(ctx, urlID, since)
+	if err != nil {
+		return nil, err
+	}
+	boundary, err := r.archivedBefore(ctx)
+	if err != nil || !since.Before(boundary) {
+		return counts, err
+	}
+
+	archived, err := r.archive.GetClickSummary(ctx, urlID, since.Truncate(time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get archived click summary: %w", err)
+	}
+
+	clicks := make(map[time.Time]int, len(counts)+len(archived.Hourly))
+	for _, count := range append(counts, archived.Hourly...) {
+		clicks[count.Time.UTC()] += count.Clicks
+	}
+	merged := make([]models.ClickCount, 0, len(clicks))
+	for hour, total := range clicks {
+		merged = append(merged, models.ClickCount{Time: hour, Clicks: total})
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Time.Before(merged[j].Time) })
+	return merged, nil
+}
+
+// mergeClickEvents appends the events of the second source to the first,
+// skipping any event found in both while it was being archived
+func mergeClickEvents(first, second []models.ClickEvent, descending bool) []models.ClickEvent {
+	if len(first) == 0 {
+		return second
+	}
+	last := first[len(first)-1].ID
+	for _, event := range second {
+		if (descending && event.ID < last) || (!descending && event.ID > last) {
+			first = append(first, event)
+		}
+	}
+	return first
+}
+
+// mergeDimensionStats adds archived clicks by country, referrer, browser,
+// device, OS and channel to the analytics' breakdowns, keeping the 10 most
+// clicked values of each
+func mergeDimensionStats(analytics *models.URLAnalytics, dimensions map[string]map[string]int) {
+	current := map[string]map[string]int{
+		models.AggregateDimensionCountry:  {},
+		models.AggregateDimensionReferrer: {},
+		models.AggregateDimensionBrowser:  {},
+		models.AggregateDimensionDevice:   {},
+		models.AggregateDimensionOS:       {},
+		models.AggregateDimensionChannel:  {},
+	}
+	for _, stats := range analytics.TopCountries {
+		current[models.AggregateDimensionCountry][stats.Country] += stats.Clicks
+	}
+	for _, stats := range analytics.TopReferrers {
+		current[models.AggregateDimensionReferrer][stats.Referrer] += stats.Clicks
+	}
+	for _, stats := range analytics.TopBrowsers {
+		current[models.AggregateDimensionBrowser][stats.Browser] += stats.Clicks
+	}
+	for _, stats := range analytics.TopDevices {
+		current[models.AggregateDimensionDevice][stats.Device] += stats.Clicks
+	}
+	for _, stats := range analytics.TopOS {
+		current[models.AggregateDimensionOS][stats.OS] += stats.Clicks
+	}
+	for _, stats := range analytics.ClicksByChannel {
+		current[models.AggregateDimensionChannel][stats.Channel] += stats.Clicks
+	}
+	analytics.TopCountries = []models.CountryStats{}
+	analytics.TopReferrers = []models.ReferrerStats{}
+	analytics.TopBrowsers = []models.BrowserStats{}
+	analytics.TopDevices = []models.DeviceStats{}
+	analytics.TopOS = []models.OSStats{}
+	analytics.ClicksByChannel = []models.ChannelStats{}
+
+	merged := []string{
+		models.AggregateDimensionCountry, models.AggregateDimensionReferrer,
+		models.AggregateDimensionBrowser, models.AggregateDimensionDevice,
+		models.AggregateDimensionOS, models.AggregateDimensionChannel,
+	}
+	for _, dimension := range merged {
+		for value, clicks := range dimensions[dimension] {
+			current[dimension][value] += clicks
+		}
+
+		values := make([]string, 0, len(current[dimension]))
+		for value := range current[dimension] {
+			values = append(values, value)
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if current[dimension][values[i]] != current[dimension][values[j]] {
+				return current[dimension][values[i]] > current[dimension][values[j]]
+			}
+			return values[i] < values[j]
+		})
+		if len(values) > 10 {
+			values = values[:10]
+		}
+		for _, value := range values {
+			analytics.AddDimensionStats(dimension, value, current[dimension][value])
+		}
+	}
+}
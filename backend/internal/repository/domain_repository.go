@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hpower2/url-shortener/database"
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// DomainRepository interface defines the contract for custom domain database operations
+type DomainRepository interface {
+	Create(ctx context.Context, domain *models.CustomDomain) (*models.CustomDomain, error)
+	GetByID(ctx context.Context, id int, userID int) (*models.CustomDomain, error)
+	GetVerifiedByHostname(ctx context.Context, hostname string) (*models.CustomDomain, error)
+	GetByUserHostname(ctx context.Context, userID int, hostname string) (*models.CustomDomain, error)
+	GetAllByUser(ctx context.Context, userID int) ([]models.CustomDomain, error)
+	Update(ctx context.Context, domain *models.CustomDomain) (*models.CustomDomain, error)
+	Delete(ctx context.Context, id int, userID int) error
+	DeleteUnverifiedBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// domainRepository implements DomainRepository interface
+type domainRepository struct {
+	db *database.DB
+}
+
+// NewDomainRepository creates a new custom domain repository
+func NewDomainRepository(db *database.DB) DomainRepository {
+	return &domainRepository{db: db}
+}
+
+const domainColumns = `id, user_id, hostname, verification_token, verified_at, not_found_url, expired_url, error_html, created_at, updated_at`
+
+// scanDomain scans a row selected with domainColumns
+func scanDomain(row rowScanner, domain *models.CustomDomain) error {
+	return row.Scan(
+		&domain.ID, &domain.UserID, &domain.Hostname, &domain.VerificationToken, &domain.VerifiedAt,
+		&domain.NotFoundURL, &domain.ExpiredURL, &domain.ErrorHTML, &domain.CreatedAt, &domain.UpdatedAt,
+	)
+}
+
+// Create creates a new custom domain
+func (r *domainRepository) Create(ctx context.Context, domain *models.CustomDomain) (*models.CustomDomain, error) {
+	query := `
+		INSERT INTO custom_domains (user_id, hostname, verification_token, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		domain.UserID, domain.Hostname, domain.VerificationToken, domain.CreatedAt, domain.UpdatedAt,
+	).Scan(&domain.ID, &domain.CreatedAt, &domain.UpdatedAt)
+
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("custom domain %w", ErrDuplicate)
+		}
+		return nil, fmt.Errorf("failed to create custom domain: %w", err)
+	}
+
+	return domain, nil
+}
+
+// GetByID retrieves one of a user's custom domains
+func (r *domainRepository) GetByID(ctx context.Context, id int, userID int) (*models.CustomDomain, error) {
+	query := `SELECT ` + domainColumns + ` FROM custom_domains WHERE id = $1 AND user_id = $2`
+
+	domain := &models.CustomDomain{}
+	if err := scanDomain(r.db.QueryRowContext(ctx, query, id, userID), domain); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("custom domain %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get custom domain: %w", err)
+	}
+
+	return domain, nil
+}
+
+// GetVerifiedByHostname retrieves the verified custom domain with a hostname.
+// Only one account can have a hostname verified.
+func (r *domainRepository) GetVerifiedByHostname(ctx context.Context, hostname string) (*models.CustomDomain, error) {
+	query := `SELECT ` + domainColumns + ` FROM custom_domains WHERE hostname = $1 AND verified_at IS NOT NULL`
+
+	return r.getDomain(ctx, query, hostname)
+}
+
+// GetByUserHostname retrieves a user's custom domain by hostname, verified or not
+func (r *domainRepository) GetByUserHostname(ctx context.Context, userID int, hostname string) (*models.CustomDomain, error) {
+	query := `SELECT ` + domainColumns + ` FROM custom_domains WHERE user_id = $1 AND hostname = $2`
+
+	return r.getDomain(ctx, query, userID, hostname)
+}
+
+// getDomain retrieves the custom domain a query selects
+func (r *domainRepository) getDomain(ctx context.Context, query string, args ...interface{}) (*models.CustomDomain, error) {
+	domain := &models.CustomDomain{}
+	if err := scanDomain(r.db.QueryRowContext(ctx, query, args...), domain); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("custom domain %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get custom domain: %w", err)
+	}
+
+	return domain, nil
+}
+
+// GetAllByUser retrieves all custom domains for a user
+func (r *domainRepository) GetAllByUser(ctx context.Context, userID int) ([]models.CustomDomain, error) {
+	query := `SELECT ` + domainColumns + ` FROM custom_domains WHERE user_id = $1 ORDER BY hostname`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get custom domains: %w", err)
+	}
+	defer rows.Close()
+
+	domains := []models.CustomDomain{}
+	for rows.Next() {
+		var domain models.CustomDomain
+		if err := scanDomain(rows, &domain); err != nil {
+			return nil, fmt.Errorf("failed to scan custom domain: %w", err)
+		}
+		domains = append(domains, domain)
+	}
+
+	return domains, nil
+}
+
+// Update updates a custom domain's verification state and error pages. It
+// fails with ErrDuplicate when verifying a hostname another account verified first.
+func (r *domainRepository) Update(ctx context.Context, domain *models.CustomDomain) (*models.CustomDomain, error) {
+	query := `
+		UPDATE custom_domains
+		SET verified_at = $3, not_found_url = $4, expired_url = $5, error_html = $6, updated_at = $7
+		WHERE id = $1 AND user_id = $2
+		RETURNING updated_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		domain.ID, domain.UserID, domain.VerifiedAt, domain.NotFoundURL, domain.ExpiredURL, domain.ErrorHTML, time.Now(),
+	).Scan(&domain.UpdatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("custom domain %w", ErrNotFound)
+		}
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("custom domain %w", ErrDuplicate)
+		}
+		return nil, fmt.Errorf("failed to update custom domain: %w", err)
+	}
+
+	return domain, nil
+}
+
+// Delete removes one of a user's custom domains
+func (r *domainRepository) Delete(ctx context.Context, id int, userID int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM custom_domains WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete custom domain: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("custom domain %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// DeleteUnverifiedBefore removes the custom domains added before a time that
+// were never verified
+func (r *domainRepository) DeleteUnverifiedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM custom_domains WHERE verified_at IS NULL AND created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete unverified custom domains: %w", err)
+	}
+	return result.RowsAffected()
+}
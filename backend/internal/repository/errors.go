@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// Sentinel errors returned by repositories. Errors wrap them with the missing
+// entity (e.g. "URL not found"), so check them with errors.Is.
+var (
+	// ErrNotFound is returned when a row does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrCacheMiss is returned when a cache key does not exist. Any other
+	// cache error is a real failure of the cache.
+	ErrCacheMiss = errors.New("cache miss")
+	// ErrDuplicate is returned when a row would break a unique constraint
+	ErrDuplicate = errors.New("already exists")
+	// ErrChanged is returned when rows no longer match what the caller expected,
+	// and nothing was changed
+	ErrChanged = errors.New("changed")
+)
+
+// IsNotFound reports whether err means the requested row does not exist
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsCacheMiss reports whether err means the requested cache key does not exist
+func IsCacheMiss(err error) bool {
+	return errors.Is(err, ErrCacheMiss)
+}
+
+// IsDuplicate reports whether err means the row breaks a unique constraint
+func IsDuplicate(err error) bool {
+	return errors.Is(err, ErrDuplicate)
+}
+
+// IsChanged reports whether err means the rows changed since the caller read them
+func IsChanged(err error) bool {
+	return errors.Is(err, ErrChanged)
+}
+
+// isUniqueViolation reports whether a database error is a unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
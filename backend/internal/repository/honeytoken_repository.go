@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hpower2/url-shortener/database"
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// HoneytokenRepository interface defines the contract for honeytoken hit database operations
+type HoneytokenRepository interface {
+	RecordHit(ctx context.Context, hit *models.HoneytokenHit) error
+	GetHits(ctx context.Context, filter *models.HoneytokenHitFilter) ([]models.HoneytokenHit, error)
+	DeleteHitsBefore(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// honeytokenRepository implements HoneytokenRepository interface
+type honeytokenRepository struct {
+	db *database.DB
+}
+
+// NewHoneytokenRepository creates a new honeytoken repository
+func NewHoneytokenRepository(db *database.DB) HoneytokenRepository {
+	return &honeytokenRepository{db: db}
+}
+
+// RecordHit stores a visit to a honeytoken
+func (r *honeytokenRepository) RecordHit(ctx context.Context, hit *models.HoneytokenHit) error {
+	query := `
+		INSERT INTO honeytoken_hits (code, ip_address, asn, user_agent, referer, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+
+	err := r.db.QueryRowContext(ctx, query,
+		hit.Code, hit.IPAddress, hit.ASN, hit.UserAgent, hit.Referer, hit.CreatedAt,
+	).Scan(&hit.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record honeytoken hit: %w", err)
+	}
+	return nil
+}
+
+// GetHits retrieves honeytoken hits matching the filter, newest first
+func (r *honeytokenRepository) GetHits(ctx context.Context, filter *models.HoneytokenHitFilter) ([]models.HoneytokenHit, error) {
+	query := `
+		SELECT id, code, ip_address, asn, user_agent, referer, created_at
+		FROM honeytoken_hits
+		WHERE ($1 = '' OR code = $1) AND ($2 = '' OR ip_address = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3`
+
+	rows, err := r.db.QueryContext(ctx, query, filter.Code, filter.IP, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get honeytoken hits: %w", err)
+	}
+	defer rows.Close()
+
+	hits := []models.HoneytokenHit{}
+	for rows.Next() {
+		var hit models.HoneytokenHit
+		if err := rows.Scan(&hit.ID, &hit.Code, &hit.IPAddress, &hit.ASN, &hit.UserAgent, &hit.Referer, &hit.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan honeytoken hit: %w", err)
+		}
+		hits = append(hits, hit)
+	}
+
+	return hits, rows.Err()
+}
+
+// DeleteHitsBefore deletes up to limit honeytoken hits recorded before a time
+func (r *honeytokenRepository) DeleteHitsBefore(ctx context.Context, before time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM honeytoken_hits
+		WHERE id IN (SELECT id FROM honeytoken_hits WHERE created_at < $1 LIMIT $2)`
+
+	result, err := r.db.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete old honeytoken hits: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -13,28 +13,107 @@ type URLRepository interface {
 	GetByShortCode(ctx context.Context, shortCode string) (*models.URL, error)
 	GetByID(ctx context.Context, id int) (*models.URL, error)
 	GetAll(ctx context.Context, limit, offset int) ([]models.URL, int, error)
-	GetAllByUser(ctx context.Context, userID int, limit, offset int) ([]models.URL, int, error)
+	GetAllByUser(ctx context.Context, userID int, opts *models.URLListOptions) ([]models.URL, int, error)
+	GetPageByUser(ctx context.Context, userID int, opts *models.URLListOptions) ([]models.URL, error)
+	GetAllByUserAfter(ctx context.Context, userID, afterID, limit int) ([]models.URL, error)
+	Search(ctx context.Context, userID int, opts *models.URLSearchOptions) ([]models.URL, int, error)
+	GetPageByCodeNamespace(ctx context.Context, namespace string, userIDs []int, limit, offset int) ([]models.URL, int, error)
+	GetCodeNamespaceRollup(ctx context.Context, namespace string, userIDs []int, topLinks int) (*models.CodePrefixRollup, error)
+	CountCodeNamespaceConflicts(ctx context.Context, namespace string, userIDs []int, createdBefore time.Time) (int, error)
 	Update(ctx context.Context, url *models.URL) (*models.URL, error)
 	Delete(ctx context.Context, shortCode string) error
 	DeleteByUser(ctx context.Context, shortCode string, userID int) error
+	GetLabeledLinksImpact(ctx context.Context, userID int, label, value string, sample int) (*models.LabeledLinksImpact, error)
+	DeleteByLabel(ctx context.Context, userID int, label, value string, expected int) ([]models.URL, error)
+	CountByDomain(ctx context.Context, userID int, hostname string) (int, error)
+	ClearDomain(ctx context.Context, userID int, hostname string) (int64, error)
 	ExistsByShortCode(ctx context.Context, shortCode string) (bool, error)
+	CountGeneratedShortCodes(ctx context.Context, length int) (int64, error)
+	NextShortCodeSequence(ctx context.Context) (int64, error)
 	IncrementClickCount(ctx context.Context, shortCode string) error
 	CreateClickEvent(ctx context.Context, clickEvent *models.ClickEvent) error
+	CreateBlockedClick(ctx context.Context, blockedClick *models.BlockedClick) error
 	GetClickEvents(ctx context.Context, urlID int, limit int) ([]models.ClickEvent, error)
-	GetAnalytics(ctx context.Context, urlID int, days int) (*models.URLAnalytics, error)
-	GetAnalyticsByUser(ctx context.Context, urlID int, userID int, days int) (*models.URLAnalytics, error)
+	GetClickEventsBefore(ctx context.Context, urlID, beforeID int, since time.Time, limit int) ([]models.ClickEvent, error)
+	GetClickEventsAfter(ctx context.Context, urlID, afterID int, since, until time.Time, limit int) ([]models.ClickEvent, error)
+	GetAnalytics(ctx context.Context, urlID int, days int, loc *time.Location) (*models.URLAnalytics, error)
+	GetAnalyticsByUser(ctx context.Context, urlID int, userID int, days int, loc *time.Location) (*models.URLAnalytics, error)
+	GetHourlyClicks(ctx context.Context, urlID int, since time.Time) ([]models.ClickCount, error)
+	GetAggregateHourlyClicks(ctx context.Context, urlID int, since time.Time) ([]models.ClickCount, error)
+	RecordClickAggregate(ctx context.Context, aggregate *models.ClickAggregate) error
+	GetAggregateAnalytics(ctx context.Context, urlID int, days int, loc *time.Location) (*models.URLAnalytics, error)
 	CheckOwnership(ctx context.Context, shortCode string, userID int) (bool, error)
+	GetOwnedShortCodes(ctx context.Context, userID int, shortCodes []string) ([]string, error)
+	GetShortCodesByCampaign(ctx context.Context, userID int, campaign string, limit int) ([]string, error)
+	GetCampaignStats(ctx context.Context, userID int) ([]models.CampaignStats, error)
+	GetDashboardTotals(ctx context.Context, userID int, now time.Time) (*models.Dashboard, error)
+	GetAggregateDashboardTotals(ctx context.Context, userID int, now time.Time) (*models.Dashboard, error)
+	ExpireInactive(ctx context.Context, now time.Time) ([]string, error)
+	DeactivateExpired(ctx context.Context, now time.Time, limit int) ([]models.URL, error)
+	DeleteClickEventsBefore(ctx context.Context, before time.Time, limit int) (int64, error)
+	ExtendExpiration(ctx context.Context, extension *models.ExpirationExtension) (*models.ExpirationExtension, error)
+	GetExpirationExtensions(ctx context.Context, urlID int) ([]models.ExpirationExtension, error)
+	CreateDestinationChange(ctx context.Context, change *models.DestinationChange) (*models.DestinationChange, error)
+	GetDestinationChanges(ctx context.Context, urlID int) ([]models.DestinationChange, error)
+	GetByShortCodes(ctx context.Context, shortCodes []string) ([]models.URL, error)
+	UpdateShortCode(ctx context.Context, id int, shortCode string) error
+	CreateShareToken(ctx context.Context, token *models.ShareToken) (*models.ShareToken, error)
+	GetShareTokens(ctx context.Context, urlID int) ([]models.ShareToken, error)
+	RevokeShareToken(ctx context.Context, urlID, id int) error
+	RevokeShareTokens(ctx context.Context, urlID int) (int, error)
+	UseShareToken(ctx context.Context, urlID int, tokenHash string) (bool, error)
+	CreateGuestEditLink(ctx context.Context, link *models.GuestEditLink) (*models.GuestEditLink, error)
+	GetGuestEditLinks(ctx context.Context, urlID int) ([]models.GuestEditLink, error)
+	GetGuestEditLinkByHash(ctx context.Context, tokenHash string) (*models.GuestEditLink, error)
+	RevokeGuestEditLink(ctx context.Context, urlID, id int) error
+	UseGuestEditLink(ctx context.Context, id int, now time.Time) (bool, error)
+	SetDestinations(ctx context.Context, urlID int, destinations []string) error
+	GetDestinations(ctx context.Context, urlID int) ([]models.LinkDestination, error)
+	RecordDestinationClick(ctx context.Context, id int) error
+	SetVariants(ctx context.Context, urlID int, variants []models.SplitVariant) error
+	GetVariants(ctx context.Context, urlID int) ([]models.URLVariant, error)
+	RecordVariantClicks(ctx context.Context, clicks map[int]int64, clickedAt time.Time) error
+	RecordVariantConversion(ctx context.Context, urlID int, name string) (*models.URLVariant, error)
+	ClaimRedirect(ctx context.Context, id int) (bool, bool, error)
+	GetLinksToScan(ctx context.Context, scannedBefore time.Time, limit int) ([]models.LinkScanTarget, error)
+	MarkScanned(ctx context.Context, ids []int, scannedAt time.Time) error
+	GetLinksWithStaleMetadata(ctx context.Context, checkedBefore time.Time, limit int) ([]models.MetadataTarget, error)
+	SetMetadata(ctx context.Context, id int, destination string, metadata *models.LinkMetadata) (bool, error)
+	MarkMetadataChecked(ctx context.Context, ids []int, checkedAt time.Time) error
+	FlagThreat(ctx context.Context, id int, threatType string) (bool, error)
+	WarnThreat(ctx context.Context, id int, threatType string) (bool, error)
+	SetSuspicious(ctx context.Context, id int, suspicious bool) error
+	GetRollingOutCanaries(ctx context.Context) ([]models.URL, error)
+	RecordCanaryCheck(ctx context.Context, id int, healthy bool) (int, error)
+	FinishCanary(ctx context.Context, id int, status string) (bool, error)
 }
 
 // CacheRepository interface defines the contract for cache operations
 type CacheRepository interface {
 	SetURL(ctx context.Context, shortCode, originalURL string, expiration time.Duration) error
+	SetURLIfAbsent(ctx context.Context, shortCode, originalURL string, expiration time.Duration) error
 	GetURL(ctx context.Context, shortCode string) (string, error)
+	TombstoneURL(ctx context.Context, shortCode string, expiration time.Duration) error
+	PublishLinkKill(ctx context.Context, shortCode string) (int64, error)
+	SubscribeLinkKills(ctx context.Context, onKill func(shortCode string))
+	URLStats() models.CacheStats
 	DeleteURL(ctx context.Context, shortCode string) error
-	IncrementClickCount(ctx context.Context, shortCode string) error
+	SetResolvedLink(ctx context.Context, resolved *models.ResolvedLink, expiration time.Duration) error
+	GetResolvedLink(ctx context.Context, shortCode string) (*models.ResolvedLink, error)
+	PurgeURLs(ctx context.Context, shortCodes []string) (int64, error)
+	IncrementClickCount(ctx context.Context, shortCode string) (int64, error)
+	SetClickCount(ctx context.Context, shortCode string, count int64) error
 	GetClickCount(ctx context.Context, shortCode string) (int64, error)
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
 	Get(ctx context.Context, key string) (string, error)
 	Delete(ctx context.Context, key string) error
+	Take(ctx context.Context, key string) (string, error)
 	Exists(ctx context.Context, key string) (bool, error)
+	ExistsAny(ctx context.Context, keys ...string) (bool, error)
+	IncrementWithExpiry(ctx context.Context, key string, expiration time.Duration) (int64, error)
+	SetIfNotExists(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error)
+	AcquireLease(ctx context.Context, name string, ttl time.Duration) (bool, error)
+	AddUnique(ctx context.Context, key, member string) error
+	CountUnique(ctx context.Context, key string) (int64, error)
+	AddUniqueWithExpiry(ctx context.Context, key, member string, expiration time.Duration) (int64, error)
 } 
\ No newline at end of file
@@ -0,0 +1,391 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hpower2/url-shortener/database"
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// OrganizationRepository interface defines the contract for organization database operations
+type OrganizationRepository interface {
+	Create(ctx context.Context, org *models.Organization) (*models.Organization, error)
+	GetByID(ctx context.Context, id int) (*models.Organization, error)
+	GetMembers(ctx context.Context, orgID int) ([]models.OrganizationMember, error)
+	SetMembership(ctx context.Context, userID int, orgID *int) error
+	CreateInvitation(ctx context.Context, invitation *models.OrganizationInvitation) (*models.OrganizationInvitation, error)
+	GetInvitationsByEmail(ctx context.Context, email string, now time.Time) ([]models.OrganizationInvitation, error)
+	GetInvitation(ctx context.Context, id int) (*models.OrganizationInvitation, error)
+	DeleteInvitation(ctx context.Context, id int) error
+	DeleteExpiredInvitations(ctx context.Context, now time.Time) (int64, error)
+	SetDataRegion(ctx context.Context, orgID int, region string) error
+	GetByCodePrefix(ctx context.Context, prefix string) (*models.Organization, error)
+	SetCodePrefix(ctx context.Context, orgID int, prefix string, required bool) error
+	CountMemberLinks(ctx context.Context, orgID int) (int, error)
+	GetEmailBranding(ctx context.Context, orgID int) (*models.EmailBranding, error)
+	GetEmailBrandingByEmail(ctx context.Context, email string) (*models.EmailBranding, error)
+	UpsertEmailBranding(ctx context.Context, branding *models.EmailBranding) (*models.EmailBranding, error)
+}
+
+// organizationRepository implements OrganizationRepository interface
+type organizationRepository struct {
+	db *database.DB
+}
+
+// NewOrganizationRepository creates a new organization repository
+func NewOrganizationRepository(db *database.DB) OrganizationRepository {
+	return &organizationRepository{db: db}
+}
+
+// organizationColumns lists the columns selected for an organization, in scanOrganization order
+const organizationColumns = `id, name, owner_id, data_region, code_prefix, require_code_prefix, created_at, updated_at`
+
+// scanOrganization scans a row selected with organizationColumns
+func scanOrganization(row rowScanner, org *models.Organization) error {
+	return row.Scan(
+		&org.ID, &org.Name, &org.OwnerID, &org.DataRegion, &org.CodePrefix, &org.RequireCodePrefix,
+		&org.CreatedAt, &org.UpdatedAt,
+	)
+}
+
+const brandingColumns = `organization_id, from_name, from_address, from_domain_verified_at, logo_url,
+		       primary_color, accent_color, created_at, updated_at`
+
+// scanBranding scans a row selected with brandingColumns
+func scanBranding(row rowScanner, branding *models.EmailBranding) error {
+	return row.Scan(
+		&branding.OrganizationID, &branding.FromName, &branding.FromAddress, &branding.FromDomainVerifiedAt,
+		&branding.LogoURL, &branding.PrimaryColor, &branding.AccentColor, &branding.CreatedAt, &branding.UpdatedAt,
+	)
+}
+
+// Create creates an organization and makes its owner the first member
+func (r *organizationRepository) Create(ctx context.Context, org *models.Organization) (*models.Organization, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO organizations (name, owner_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at`
+
+	err = tx.QueryRowContext(ctx, query, org.Name, org.OwnerID, org.CreatedAt, org.UpdatedAt).
+		Scan(&org.ID, &org.CreatedAt, &org.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create organization: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET organization_id = $2 WHERE id = $1", org.OwnerID, org.ID); err != nil {
+		return nil, fmt.Errorf("failed to add organization owner: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit organization: %w", err)
+	}
+
+	return org, nil
+}
+
+// GetByID retrieves an organization by ID
+func (r *organizationRepository) GetByID(ctx context.Context, id int) (*models.Organization, error) {
+	query := `SELECT ` + organizationColumns + ` FROM organizations WHERE id = $1`
+
+	org := &models.Organization{}
+	if err := scanOrganization(r.db.QueryRowContext(ctx, query, id), org); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("organization %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return org, nil
+}
+
+// GetMembers lists the users belonging to an organization
+func (r *organizationRepository) GetMembers(ctx context.Context, orgID int) ([]models.OrganizationMember, error) {
+	query := `
+		SELECT u.id, u.email, u.first_name, u.last_name, u.id = o.owner_id
+		FROM users u
+		JOIN organizations o ON o.id = u.organization_id
+		WHERE u.organization_id = $1
+		ORDER BY u.email`
+
+	rows, err := r.db.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization members: %w", err)
+	}
+	defer rows.Close()
+
+	members := []models.OrganizationMember{}
+	for rows.Next() {
+		var member models.OrganizationMember
+		if err := rows.Scan(&member.UserID, &member.Email, &member.FirstName, &member.LastName, &member.IsOwner); err != nil {
+			return nil, fmt.Errorf("failed to scan organization member: %w", err)
+		}
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+// SetMembership moves a user into an organization, or out of one when orgID is nil
+func (r *organizationRepository) SetMembership(ctx context.Context, userID int, orgID *int) error {
+	query := "UPDATE users SET organization_id = $2, updated_at = $3 WHERE id = $1"
+	result, err := r.db.ExecContext(ctx, query, userID, orgID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update organization membership: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("user %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// invitationColumns lists the columns selected for an invitation joined with its
+// organization (as o), in scanInvitation order
+const invitationColumns = `i.id, i.organization_id, o.name, i.email, i.invited_by, i.created_at, i.expires_at`
+
+// scanInvitation scans a row selected with invitationColumns
+func scanInvitation(row rowScanner, invitation *models.OrganizationInvitation) error {
+	return row.Scan(
+		&invitation.ID, &invitation.OrganizationID, &invitation.OrganizationName, &invitation.Email,
+		&invitation.InvitedBy, &invitation.CreatedAt, &invitation.ExpiresAt,
+	)
+}
+
+// CreateInvitation invites an email to an organization, renewing any earlier
+// invitation of the same email
+func (r *organizationRepository) CreateInvitation(ctx context.Context, invitation *models.OrganizationInvitation) (*models.OrganizationInvitation, error) {
+	query := `
+		INSERT INTO organization_invitations (organization_id, email, invited_by, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (organization_id, email)
+		DO UPDATE SET invited_by = EXCLUDED.invited_by, created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		RETURNING id`
+
+	err := r.db.QueryRowContext(ctx, query, invitation.OrganizationID, invitation.Email, invitation.InvitedBy,
+		invitation.CreatedAt, invitation.ExpiresAt).Scan(&invitation.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create organization invitation: %w", err)
+	}
+
+	return invitation, nil
+}
+
+// GetInvitationsByEmail lists the invitations of an email that haven't expired
+func (r *organizationRepository) GetInvitationsByEmail(ctx context.Context, email string, now time.Time) ([]models.OrganizationInvitation, error) {
+	query := `
+		SELECT ` + invitationColumns + `
+		FROM organization_invitations i
+		JOIN organizations o ON o.id = i.organization_id
+		WHERE i.email = $1 AND i.expires_at > $2
+		ORDER BY i.created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, email, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := []models.OrganizationInvitation{}
+	for rows.Next() {
+		var invitation models.OrganizationInvitation
+		if err := scanInvitation(rows, &invitation); err != nil {
+			return nil, fmt.Errorf("failed to scan organization invitation: %w", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+// GetInvitation retrieves an invitation by ID
+func (r *organizationRepository) GetInvitation(ctx context.Context, id int) (*models.OrganizationInvitation, error) {
+	query := `
+		SELECT ` + invitationColumns + `
+		FROM organization_invitations i
+		JOIN organizations o ON o.id = i.organization_id
+		WHERE i.id = $1`
+
+	invitation := &models.OrganizationInvitation{}
+	if err := scanInvitation(r.db.QueryRowContext(ctx, query, id), invitation); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("organization invitation %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get organization invitation: %w", err)
+	}
+
+	return invitation, nil
+}
+
+// DeleteInvitation deletes an invitation once accepted or declined
+func (r *organizationRepository) DeleteInvitation(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM organization_invitations WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete organization invitation: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("organization invitation %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// DeleteExpiredInvitations deletes the invitations that can no longer be accepted
+func (r *organizationRepository) DeleteExpiredInvitations(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM organization_invitations WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired organization invitations: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// SetDataRegion changes the region holding an organization's link data
+func (r *organizationRepository) SetDataRegion(ctx context.Context, orgID int, region string) error {
+	query := "UPDATE organizations SET data_region = $2, updated_at = $3 WHERE id = $1"
+	result, err := r.db.ExecContext(ctx, query, orgID, region, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update organization data region: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("organization %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// GetByCodePrefix retrieves the organization that reserved a short code prefix
+func (r *organizationRepository) GetByCodePrefix(ctx context.Context, prefix string) (*models.Organization, error) {
+	query := `SELECT ` + organizationColumns + ` FROM organizations WHERE code_prefix = $1 AND code_prefix <> ''`
+
+	org := &models.Organization{}
+	if err := scanOrganization(r.db.QueryRowContext(ctx, query, prefix), org); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("organization %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	return org, nil
+}
+
+// SetCodePrefix reserves a short code prefix for an organization, or releases
+// its prefix when empty. Prefixes are unique across organizations.
+func (r *organizationRepository) SetCodePrefix(ctx context.Context, orgID int, prefix string, required bool) error {
+	query := "UPDATE organizations SET code_prefix = $2, require_code_prefix = $3, updated_at = $4 WHERE id = $1"
+	result, err := r.db.ExecContext(ctx, query, orgID, prefix, required, time.Now())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("code prefix %w", ErrDuplicate)
+		}
+		return fmt.Errorf("failed to update organization code prefix: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("organization %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// CountMemberLinks returns how many links the organization's members own
+func (r *organizationRepository) CountMemberLinks(ctx context.Context, orgID int) (int, error) {
+	query := "SELECT COALESCE(SUM(link_count), 0) FROM users WHERE organization_id = $1"
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, orgID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count organization links: %w", err)
+	}
+	return count, nil
+}
+
+// GetEmailBranding retrieves an organization's email branding, or empty branding when none is set
+func (r *organizationRepository) GetEmailBranding(ctx context.Context, orgID int) (*models.EmailBranding, error) {
+	query := `SELECT ` + brandingColumns + ` FROM organization_email_branding WHERE organization_id = $1`
+
+	branding := &models.EmailBranding{}
+	if err := scanBranding(r.db.QueryRowContext(ctx, query, orgID), branding); err != nil {
+		if err == sql.ErrNoRows {
+			return &models.EmailBranding{OrganizationID: orgID}, nil
+		}
+		return nil, fmt.Errorf("failed to get email branding: %w", err)
+	}
+
+	return branding, nil
+}
+
+// GetEmailBrandingByEmail retrieves the email branding of the organization a recipient belongs to
+func (r *organizationRepository) GetEmailBrandingByEmail(ctx context.Context, email string) (*models.EmailBranding, error) {
+	query := `
+		SELECT b.organization_id, b.from_name, b.from_address, b.from_domain_verified_at, b.logo_url,
+		       b.primary_color, b.accent_color, b.created_at, b.updated_at
+		FROM organization_email_branding b
+		JOIN users u ON u.organization_id = b.organization_id
+		WHERE u.email = $1`
+
+	branding := &models.EmailBranding{}
+	if err := scanBranding(r.db.QueryRowContext(ctx, query, email), branding); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("email branding %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get email branding: %w", err)
+	}
+
+	return branding, nil
+}
+
+// UpsertEmailBranding creates or replaces an organization's email branding
+func (r *organizationRepository) UpsertEmailBranding(ctx context.Context, branding *models.EmailBranding) (*models.EmailBranding, error) {
+	query := `
+		INSERT INTO organization_email_branding (organization_id, from_name, from_address, from_domain_verified_at,
+		                                         logo_url, primary_color, accent_color, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+		ON CONFLICT (organization_id) DO UPDATE
+		SET from_name = EXCLUDED.from_name, from_address = EXCLUDED.from_address,
+		    from_domain_verified_at = EXCLUDED.from_domain_verified_at, logo_url = EXCLUDED.logo_url,
+		    primary_color = EXCLUDED.primary_color, accent_color = EXCLUDED.accent_color,
+		    updated_at = EXCLUDED.updated_at
+		RETURNING created_at, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		branding.OrganizationID, branding.FromName, branding.FromAddress, branding.FromDomainVerifiedAt,
+		branding.LogoURL, branding.PrimaryColor, branding.AccentColor, time.Now(),
+	).Scan(&branding.CreatedAt, &branding.UpdatedAt)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to save email branding: %w", err)
+	}
+
+	return branding, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/hpower2/url-shortener/database"
@@ -17,6 +18,23 @@ type OTPRepository interface {
 	Update(ctx context.Context, otp *models.OTPVerification) error
 	DeleteExpired(ctx context.Context) error
 	DeleteByUserAndPurpose(ctx context.Context, userID int, purpose string) error
+	GetByID(ctx context.Context, id int) (*models.OTPVerification, error)
+	SetDeliveryStatus(ctx context.Context, id int, status, detail string, attempted bool) error
+	MarkBounced(ctx context.Context, email, reason string, since time.Time) (int, error)
+	GetDeliveries(ctx context.Context, filter *models.OTPDeliveryFilter) ([]models.OTPVerification, error)
+}
+
+// otpColumns lists the columns selected for an OTP, in scanOTP order
+const otpColumns = `id, user_id, email, otp_code, purpose, is_verified, expires_at, created_at, verified_at,
+			   delivery_status, delivery_error, delivery_attempts, delivery_updated_at`
+
+// scanOTP scans a row selected with otpColumns into an OTP
+func scanOTP(row rowScanner, otp *models.OTPVerification) error {
+	return row.Scan(
+		&otp.ID, &otp.UserID, &otp.Email, &otp.OTPCode, &otp.Purpose,
+		&otp.IsVerified, &otp.ExpiresAt, &otp.CreatedAt, &otp.VerifiedAt,
+		&otp.DeliveryStatus, &otp.DeliveryError, &otp.DeliveryAttempts, &otp.DeliveryUpdatedAt,
+	)
 }
 
 // otpRepository implements OTPRepository interface
@@ -45,11 +63,11 @@ func (r *otpRepository) Create(ctx context.Context, otp *models.OTPVerification)
 	query := `
 		INSERT INTO otp_verifications (user_id, email, otp_code, purpose, expires_at, created_at)
 		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, created_at`
+		RETURNING id, created_at, delivery_status`
 
 	err = r.db.QueryRowContext(ctx, query,
 		otp.UserID, otp.Email, otp.OTPCode, otp.Purpose, otp.ExpiresAt, otp.CreatedAt,
-	).Scan(&otp.ID, &otp.CreatedAt)
+	).Scan(&otp.ID, &otp.CreatedAt, &otp.DeliveryStatus)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OTP: %w", err)
@@ -61,21 +79,18 @@ func (r *otpRepository) Create(ctx context.Context, otp *models.OTPVerification)
 // GetByEmailAndPurpose retrieves the latest unverified OTP for email and purpose
 func (r *otpRepository) GetByEmailAndPurpose(ctx context.Context, email, purpose string) (*models.OTPVerification, error) {
 	query := `
-		SELECT id, user_id, email, otp_code, purpose, is_verified, expires_at, created_at, verified_at
+		SELECT ` + otpColumns + `
 		FROM otp_verifications 
 		WHERE email = $1 AND purpose = $2 AND is_verified = FALSE
 		ORDER BY created_at DESC
 		LIMIT 1`
 
 	otp := &models.OTPVerification{}
-	err := r.db.QueryRowContext(ctx, query, email, purpose).Scan(
-		&otp.ID, &otp.UserID, &otp.Email, &otp.OTPCode, &otp.Purpose, 
-		&otp.IsVerified, &otp.ExpiresAt, &otp.CreatedAt, &otp.VerifiedAt,
-	)
+	err := scanOTP(r.db.QueryRowContext(ctx, query, email, purpose), otp)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("OTP not found")
+			return nil, fmt.Errorf("OTP %w", ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get OTP: %w", err)
 	}
@@ -101,19 +116,22 @@ func (r *otpRepository) Update(ctx context.Context, otp *models.OTPVerification)
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("OTP not found")
+		return fmt.Errorf("OTP %w", ErrNotFound)
 	}
 
 	return nil
 }
 
-// DeleteExpired deletes all expired OTP records
+// otpDeliveryRetention is how long an expired OTP is kept so its delivery can still be looked up
+const otpDeliveryRetention = 24 * time.Hour
+
+// DeleteExpired deletes unverified OTP records that expired more than a day ago
 func (r *otpRepository) DeleteExpired(ctx context.Context) error {
 	query := `
 		DELETE FROM otp_verifications 
 		WHERE expires_at < $1 AND is_verified = FALSE`
 
-	_, err := r.db.ExecContext(ctx, query, time.Now())
+	_, err := r.db.ExecContext(ctx, query, time.Now().Add(-otpDeliveryRetention))
 	if err != nil {
 		return fmt.Errorf("failed to delete expired OTPs: %w", err)
 	}
@@ -133,4 +151,83 @@ func (r *otpRepository) DeleteByUserAndPurpose(ctx context.Context, userID int,
 	}
 
 	return nil
-} 
\ No newline at end of file
+}
+
+// GetByID retrieves an OTP record by ID
+func (r *otpRepository) GetByID(ctx context.Context, id int) (*models.OTPVerification, error) {
+	query := `SELECT ` + otpColumns + ` FROM otp_verifications WHERE id = $1`
+
+	otp := &models.OTPVerification{}
+	if err := scanOTP(r.db.QueryRowContext(ctx, query, id), otp); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("OTP %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get OTP: %w", err)
+	}
+
+	return otp, nil
+}
+
+// SetDeliveryStatus records the delivery status of an OTP email, counting a
+// send attempt when attempted is set
+func (r *otpRepository) SetDeliveryStatus(ctx context.Context, id int, status, detail string, attempted bool) error {
+	query := `
+		UPDATE otp_verifications
+		SET delivery_status = $2, delivery_error = $3,
+		    delivery_attempts = delivery_attempts + CASE WHEN $4 THEN 1 ELSE 0 END,
+		    delivery_updated_at = $5
+		WHERE id = $1`
+
+	if _, err := r.db.ExecContext(ctx, query, id, status, detail, attempted, time.Now()); err != nil {
+		return fmt.Errorf("failed to update OTP delivery status: %w", err)
+	}
+	return nil
+}
+
+// MarkBounced marks OTP emails sent to an address since the given time as bounced
+func (r *otpRepository) MarkBounced(ctx context.Context, email, reason string, since time.Time) (int, error) {
+	query := `
+		UPDATE otp_verifications
+		SET delivery_status = $2, delivery_error = $3, delivery_updated_at = $4
+		WHERE LOWER(email) = $1 AND delivery_status = $5 AND created_at >= $6`
+
+	result, err := r.db.ExecContext(ctx, query,
+		strings.ToLower(email), models.OTPDeliveryBounced, reason, time.Now(), models.OTPDeliverySent, since,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark OTP emails bounced: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return int(rowsAffected), nil
+}
+
+// GetDeliveries retrieves OTP records matching the filter, newest first
+func (r *otpRepository) GetDeliveries(ctx context.Context, filter *models.OTPDeliveryFilter) ([]models.OTPVerification, error) {
+	query := `
+		SELECT ` + otpColumns + `
+		FROM otp_verifications
+		WHERE ($1 = '' OR LOWER(email) = $1) AND ($2 = '' OR delivery_status = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3`
+
+	rows, err := r.db.QueryContext(ctx, query, filter.Email, filter.Status, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get OTP deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	otps := []models.OTPVerification{}
+	for rows.Next() {
+		var otp models.OTPVerification
+		if err := scanOTP(rows, &otp); err != nil {
+			return nil, fmt.Errorf("failed to scan OTP: %w", err)
+		}
+		otps = append(otps, otp)
+	}
+
+	return otps, rows.Err()
+}
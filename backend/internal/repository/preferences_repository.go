@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hpower2/url-shortener/database"
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// PreferencesRepository interface defines the contract for user preference database operations
+type PreferencesRepository interface {
+	Get(ctx context.Context, userID int) (*models.UserPreferences, error)
+	Upsert(ctx context.Context, preferences *models.UserPreferences) (*models.UserPreferences, error)
+	ClearDefaultDomain(ctx context.Context, userID int, hostname string) error
+}
+
+// preferencesRepository implements PreferencesRepository interface
+type preferencesRepository struct {
+	db *database.DB
+}
+
+// NewPreferencesRepository creates a new user preferences repository
+func NewPreferencesRepository(db *database.DB) PreferencesRepository {
+	return &preferencesRepository{db: db}
+}
+
+// Get retrieves a user's preferences, returning empty preferences if none are saved
+func (r *preferencesRepository) Get(ctx context.Context, userID int) (*models.UserPreferences, error) {
+	query := `
+		SELECT user_id, utm_defaults, default_expiry_days, default_redirect_type,
+			default_labels, qr_style, default_domain, created_at, updated_at
+		FROM user_preferences
+		WHERE user_id = $1`
+
+	preferences := &models.UserPreferences{}
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&preferences.UserID, &preferences.UTMDefaults, &preferences.DefaultExpiryDays, &preferences.DefaultRedirectType,
+		&preferences.DefaultLabels, &preferences.QRStyle, &preferences.DefaultDomain, &preferences.CreatedAt, &preferences.UpdatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &models.UserPreferences{UserID: userID}, nil
+		}
+		return nil, fmt.Errorf("failed to get user preferences: %w", err)
+	}
+
+	return preferences, nil
+}
+
+// Upsert creates or replaces a user's preferences
+func (r *preferencesRepository) Upsert(ctx context.Context, preferences *models.UserPreferences) (*models.UserPreferences, error) {
+	query := `
+		INSERT INTO user_preferences (user_id, utm_defaults, default_expiry_days, default_redirect_type,
+			default_labels, qr_style, default_domain, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8)
+		ON CONFLICT (user_id) DO UPDATE
+		SET utm_defaults = EXCLUDED.utm_defaults, default_expiry_days = EXCLUDED.default_expiry_days,
+			default_redirect_type = EXCLUDED.default_redirect_type, default_labels = EXCLUDED.default_labels,
+			qr_style = EXCLUDED.qr_style, default_domain = EXCLUDED.default_domain, updated_at = EXCLUDED.updated_at
+		RETURNING created_at, updated_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		preferences.UserID, preferences.UTMDefaults, preferences.DefaultExpiryDays, preferences.DefaultRedirectType,
+		preferences.DefaultLabels, preferences.QRStyle, preferences.DefaultDomain, time.Now(),
+	).Scan(&preferences.CreatedAt, &preferences.UpdatedAt)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to save user preferences: %w", err)
+	}
+
+	return preferences, nil
+}
+
+// ClearDefaultDomain stops a user's new links from using a custom domain by default
+func (r *preferencesRepository) ClearDefaultDomain(ctx context.Context, userID int, hostname string) error {
+	query := `UPDATE user_preferences SET default_domain = '', updated_at = $3 WHERE user_id = $1 AND default_domain = $2`
+
+	if _, err := r.db.ExecContext(ctx, query, userID, hostname, time.Now()); err != nil {
+		return fmt.Errorf("failed to clear default domain: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hpower2/url-shortener/database"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/lib/pq"
+)
+
+// QRBatchRepository interface defines the contract for bulk QR code batch database operations
+type QRBatchRepository interface {
+	Create(ctx context.Context, batch *models.QRBatch) (*models.QRBatch, error)
+	GetByID(ctx context.Context, id int, userID int) (*models.QRBatch, error)
+	GetArchive(ctx context.Context, id int, userID int) ([]byte, error)
+	Complete(ctx context.Context, id int, archive []byte) error
+	Fail(ctx context.Context, id int, message string) error
+	CountPending(ctx context.Context, userID int) (int, error)
+	ClaimPending(ctx context.Context, staleBefore time.Time, maxAttempts, limit int) ([]models.QRBatch, error)
+	FailAbandoned(ctx context.Context, staleBefore time.Time, maxAttempts int, message string) (int64, error)
+	ExpireArchives(ctx context.Context, completedBefore time.Time) (int64, error)
+}
+
+// qrBatchRepository implements QRBatchRepository interface
+type qrBatchRepository struct {
+	db *database.DB
+}
+
+// NewQRBatchRepository creates a new QR batch repository
+func NewQRBatchRepository(db *database.DB) QRBatchRepository {
+	return &qrBatchRepository{db: db}
+}
+
+// Create creates a new pending QR batch, claimed by the creating instance
+func (r *qrBatchRepository) Create(ctx context.Context, batch *models.QRBatch) (*models.QRBatch, error) {
+	query := `
+		INSERT INTO qr_batches (user_id, short_codes, size, status, created_at, claimed_at, attempts)
+		VALUES ($1, $2, $3, $4, $5, $5, 1)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		batch.UserID, pq.Array(batch.ShortCodes), batch.Size, batch.Status, batch.CreatedAt,
+	).Scan(&batch.ID, &batch.CreatedAt)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create QR batch: %w", err)
+	}
+
+	return batch, nil
+}
+
+// GetByID retrieves a QR batch owned by a user, without its archive
+func (r *qrBatchRepository) GetByID(ctx context.Context, id int, userID int) (*models.QRBatch, error) {
+	query := `
+		SELECT id, user_id, short_codes, size, status, error, created_at, completed_at
+		FROM qr_batches
+		WHERE id = $1 AND user_id = $2`
+
+	batch := &models.QRBatch{}
+	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(
+		&batch.ID, &batch.UserID, pq.Array(&batch.ShortCodes), &batch.Size, &batch.Status,
+		&batch.Error, &batch.CreatedAt, &batch.CompletedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("QR batch %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get QR batch: %w", err)
+	}
+
+	return batch, nil
+}
+
+// GetArchive retrieves the ZIP archive of a completed QR batch owned by a user
+func (r *qrBatchRepository) GetArchive(ctx context.Context, id int, userID int) ([]byte, error) {
+	query := `SELECT archive FROM qr_batches WHERE id = $1 AND user_id = $2 AND status = $3`
+
+	var archive []byte
+	err := r.db.QueryRowContext(ctx, query, id, userID, models.QRBatchCompleted).Scan(&archive)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("QR batch archive %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get QR batch archive: %w", err)
+	}
+
+	return archive, nil
+}
+
+// Complete stores the rendered archive and marks the batch completed
+func (r *qrBatchRepository) Complete(ctx context.Context, id int, archive []byte) error {
+	query := `
+		UPDATE qr_batches
+		SET status = $2, archive = $3, completed_at = $4
+		WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id, models.QRBatchCompleted, archive, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to complete QR batch: %w", err)
+	}
+	return nil
+}
+
+// Fail marks the batch failed with the reason
+func (r *qrBatchRepository) Fail(ctx context.Context, id int, message string) error {
+	query := `
+		UPDATE qr_batches
+		SET status = $2, error = $3, completed_at = $4
+		WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id, models.QRBatchFailed, message, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to mark QR batch failed: %w", err)
+	}
+	return nil
+}
+
+// CountPending counts a user's batches waiting to be rendered
+func (r *qrBatchRepository) CountPending(ctx context.Context, userID int) (int, error) {
+	query := `SELECT COUNT(*) FROM qr_batches WHERE user_id = $1 AND status = $2`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, userID, models.QRBatchPending).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count pending QR batches: %w", err)
+	}
+	return count, nil
+}
+
+// ClaimPending claims up to limit pending batches that no instance has claimed
+// since staleBefore and that were tried fewer than maxAttempts times. Batches
+// claimed by one caller are skipped by concurrent ones.
+func (r *qrBatchRepository) ClaimPending(ctx context.Context, staleBefore time.Time, maxAttempts, limit int) ([]models.QRBatch, error) {
+	query := `
+		UPDATE qr_batches
+		SET claimed_at = $4, attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM qr_batches
+			WHERE status = $1 AND (claimed_at IS NULL OR claimed_at < $2) AND attempts < $3
+			ORDER BY id
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, short_codes, size, status, created_at`
+
+	rows, err := r.db.QueryContext(ctx, query, models.QRBatchPending, staleBefore, maxAttempts, time.Now(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim pending QR batches: %w", err)
+	}
+	defer rows.Close()
+
+	var batches []models.QRBatch
+	for rows.Next() {
+		var batch models.QRBatch
+		if err := rows.Scan(&batch.ID, &batch.UserID, pq.Array(&batch.ShortCodes), &batch.Size, &batch.Status, &batch.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan QR batch: %w", err)
+		}
+		batches = append(batches, batch)
+	}
+	return batches, rows.Err()
+}
+
+// FailAbandoned marks failed the pending batches tried maxAttempts times
+// whose last claim went stale before staleBefore
+func (r *qrBatchRepository) FailAbandoned(ctx context.Context, staleBefore time.Time, maxAttempts int, message string) (int64, error) {
+	query := `
+		UPDATE qr_batches
+		SET status = $4, error = $5, completed_at = $6
+		WHERE status = $1 AND claimed_at < $2 AND attempts >= $3`
+
+	result, err := r.db.ExecContext(ctx, query, models.QRBatchPending, staleBefore, maxAttempts, models.QRBatchFailed, message, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to fail abandoned QR batches: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ExpireArchives deletes the archives of batches completed before a time and
+// marks the batches expired
+func (r *qrBatchRepository) ExpireArchives(ctx context.Context, completedBefore time.Time) (int64, error) {
+	query := `
+		UPDATE qr_batches
+		SET status = $3, archive = NULL
+		WHERE status = $1 AND completed_at < $2`
+
+	result, err := r.db.ExecContext(ctx, query, models.QRBatchCompleted, completedBefore, models.QRBatchExpired)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire QR batch archives: %w", err)
+	}
+	return result.RowsAffected()
+}
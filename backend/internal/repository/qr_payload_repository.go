@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// QRPayloadRepository interface defines the contract for QR payload database operations
+type QRPayloadRepository interface {
+	Create(ctx context.Context, payload *models.QRPayload) (*models.QRPayload, error)
+	GetByID(ctx context.Context, id int, userID int) (*models.QRPayload, error)
+	GetByToken(ctx context.Context, token string) (*models.QRPayload, error)
+	GetAllByUser(ctx context.Context, userID int) ([]models.QRPayload, error)
+	UpdateData(ctx context.Context, id int, data models.QRPayloadData) error
+}
+
+// qrPayloadRepository implements QRPayloadRepository interface
+type qrPayloadRepository struct {
+	regions *RegionRouter
+}
+
+// NewQRPayloadRepository creates a new QR payload repository. Payloads are
+// stored with their short link, in the data region set on the context.
+func NewQRPayloadRepository(regions *RegionRouter) QRPayloadRepository {
+	return &qrPayloadRepository{regions: regions}
+}
+
+const qrPayloadColumns = `p.id, p.user_id, p.url_id, p.token, p.type, p.data, p.created_at, p.updated_at, u.short_code, u.click_count, u.domain`
+
+// qrPayloadFrom joins payloads to their short link
+const qrPayloadFrom = ` FROM qr_payloads p JOIN urls u ON u.id = p.url_id`
+
+// scanQRPayload scans a payload row selected with qrPayloadColumns
+func scanQRPayload(row rowScanner, payload *models.QRPayload) error {
+	return row.Scan(
+		&payload.ID, &payload.UserID, &payload.URLID, &payload.Token, &payload.Type, &payload.Data,
+		&payload.CreatedAt, &payload.UpdatedAt, &payload.ShortCode, &payload.Scans, &payload.Domain,
+	)
+}
+
+// Create creates a new QR payload for an existing short link
+func (r *qrPayloadRepository) Create(ctx context.Context, payload *models.QRPayload) (*models.QRPayload, error) {
+	query := `
+		INSERT INTO qr_payloads (user_id, url_id, token, type, data, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at, updated_at`
+
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query,
+		payload.UserID, payload.URLID, payload.Token, payload.Type, payload.Data, payload.CreatedAt, payload.UpdatedAt,
+	).Scan(&payload.ID, &payload.CreatedAt, &payload.UpdatedAt)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create QR payload: %w", err)
+	}
+
+	return payload, nil
+}
+
+// GetByID retrieves a QR payload owned by a user
+func (r *qrPayloadRepository) GetByID(ctx context.Context, id int, userID int) (*models.QRPayload, error) {
+	query := `SELECT ` + qrPayloadColumns + qrPayloadFrom + ` WHERE p.id = $1 AND p.user_id = $2`
+
+	payload := &models.QRPayload{}
+	if err := scanQRPayload(r.regions.DB(ctx).QueryRowContext(ctx, query, id, userID), payload); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("QR payload %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get QR payload: %w", err)
+	}
+
+	return payload, nil
+}
+
+// GetByToken retrieves the QR payload served at a landing token, if its short
+// link can currently be visited
+func (r *qrPayloadRepository) GetByToken(ctx context.Context, token string) (*models.QRPayload, error) {
+	query := `SELECT ` + qrPayloadColumns + qrPayloadFrom + `
+		WHERE p.token = $1 AND u.is_active = true AND (u.expires_at IS NULL OR u.expires_at > NOW())`
+
+	payload := &models.QRPayload{}
+	if err := scanQRPayload(r.regions.DB(ctx).QueryRowContext(ctx, query, token), payload); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("QR payload %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get QR payload: %w", err)
+	}
+
+	return payload, nil
+}
+
+// GetAllByUser retrieves a user's QR payloads, newest first
+func (r *qrPayloadRepository) GetAllByUser(ctx context.Context, userID int) ([]models.QRPayload, error) {
+	query := `SELECT ` + qrPayloadColumns + qrPayloadFrom + ` WHERE p.user_id = $1 ORDER BY p.created_at DESC, p.id DESC`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get QR payloads: %w", err)
+	}
+	defer rows.Close()
+
+	payloads := []models.QRPayload{}
+	for rows.Next() {
+		var payload models.QRPayload
+		if err := scanQRPayload(rows, &payload); err != nil {
+			return nil, fmt.Errorf("failed to scan QR payload: %w", err)
+		}
+		payloads = append(payloads, payload)
+	}
+
+	return payloads, rows.Err()
+}
+
+// UpdateData replaces the content of a QR payload
+func (r *qrPayloadRepository) UpdateData(ctx context.Context, id int, data models.QRPayloadData) error {
+	query := `UPDATE qr_payloads SET data = $2, updated_at = $3 WHERE id = $1`
+
+	result, err := r.regions.DB(ctx).ExecContext(ctx, query, id, data, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update QR payload: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("QR payload %w", ErrNotFound)
+	}
+	return nil
+}
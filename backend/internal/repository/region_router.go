@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/hpower2/url-shortener/database"
+	"github.com/hpower2/url-shortener/redis"
+)
+
+// linkRegionCacheTTL bounds how long a link's region is remembered in the home cache
+const linkRegionCacheTTL = 24 * time.Hour
+
+// regionContextKey is the context key holding the data region of a request
+type regionContextKey struct{}
+
+// WithRegion returns a context whose link data is read from and written to region
+func WithRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, regionContextKey{}, region)
+}
+
+// RegionFromContext returns the data region set on the context, or "" when unset
+func RegionFromContext(ctx context.Context) string {
+	region, _ := ctx.Value(regionContextKey{}).(string)
+	return region
+}
+
+// RegionRouter routes link data (URLs, clicks, webhooks and their cache entries)
+// to the database and Redis pools of the data region set on the context.
+// Everything else, and requests without a region, use the home region.
+type RegionRouter struct {
+	home      string
+	databases map[string]*database.DB
+	caches    map[string]*redis.Client
+}
+
+// NewRegionRouter creates a router whose home region uses db and cache
+func NewRegionRouter(home string, db *database.DB, cache *redis.Client) *RegionRouter {
+	return &RegionRouter{
+		home:      home,
+		databases: map[string]*database.DB{home: db},
+		caches:    map[string]*redis.Client{home: cache},
+	}
+}
+
+// AddRegion registers the pools of an additional data region
+func (r *RegionRouter) AddRegion(region string, db *database.DB, cache *redis.Client) {
+	r.databases[region] = db
+	r.caches[region] = cache
+}
+
+// Home returns the name of the home region
+func (r *RegionRouter) Home() string {
+	return r.home
+}
+
+// Regions returns every region, home first
+func (r *RegionRouter) Regions() []string {
+	regions := make([]string, 0, len(r.databases))
+	for region := range r.databases {
+		if region != r.home {
+			regions = append(regions, region)
+		}
+	}
+	sort.Strings(regions)
+	return append([]string{r.home}, regions...)
+}
+
+// HasRegion reports whether region is configured
+func (r *RegionRouter) HasRegion(region string) bool {
+	_, ok := r.databases[region]
+	return ok
+}
+
+// MultiRegion reports whether any region besides home is configured
+func (r *RegionRouter) MultiRegion() bool {
+	return len(r.databases) > 1
+}
+
+// IsHome reports whether the context's link data lives in the home region
+func (r *RegionRouter) IsHome(ctx context.Context) bool {
+	region := RegionFromContext(ctx)
+	return region == "" || region == r.home || !r.HasRegion(region)
+}
+
+// DB returns the database pool of the context's region
+func (r *RegionRouter) DB(ctx context.Context) *database.DB {
+	if db, ok := r.databases[RegionFromContext(ctx)]; ok {
+		return db
+	}
+	return r.databases[r.home]
+}
+
+// Cache returns the Redis pool of the context's region
+func (r *RegionRouter) Cache(ctx context.Context) *redis.Client {
+	if cache, ok := r.caches[RegionFromContext(ctx)]; ok {
+		return cache
+	}
+	return r.caches[r.home]
+}
+
+// HomeDB returns the home region's database pool
+func (r *RegionRouter) HomeDB() *database.DB {
+	return r.databases[r.home]
+}
+
+// RegisterLink claims a short code in the home region's directory for a link
+// stored outside it, so unauthenticated visits can be routed and codes stay
+// unique across regions. Home links are not listed.
+func (r *RegionRouter) RegisterLink(ctx context.Context, shortCode string, userID int) error {
+	if r.IsHome(ctx) {
+		return nil
+	}
+
+	query := `INSERT INTO link_regions (short_code, region, user_id) VALUES ($1, $2, $3)`
+	if _, err := r.HomeDB().ExecContext(ctx, query, shortCode, RegionFromContext(ctx), userID); err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("short code %w", ErrDuplicate)
+		}
+		return fmt.Errorf("failed to register link region: %w", err)
+	}
+	// A visit before the link existed may have cached it as a home link
+	r.caches[r.home].Del(ctx, linkRegionKey(shortCode))
+	return nil
+}
+
+// UnregisterLink removes a short code from the home region's directory
+func (r *RegionRouter) UnregisterLink(ctx context.Context, shortCode string) error {
+	if r.IsHome(ctx) {
+		return nil
+	}
+
+	if _, err := r.HomeDB().ExecContext(ctx, `DELETE FROM link_regions WHERE short_code = $1`, shortCode); err != nil {
+		return fmt.Errorf("failed to unregister link region: %w", err)
+	}
+	r.caches[r.home].Del(ctx, linkRegionKey(shortCode))
+	return nil
+}
+
+// LinkRegion returns the region holding a short code, looked up in the home
+// region's directory. Codes missing from the directory live in the home region.
+func (r *RegionRouter) LinkRegion(ctx context.Context, shortCode string) (string, error) {
+	if !r.MultiRegion() {
+		return r.home, nil
+	}
+
+	homeCache := r.caches[r.home]
+	key := linkRegionKey(shortCode)
+	if region, err := homeCache.Get(ctx, key).Result(); err == nil {
+		return region, nil
+	} else if err != goredis.Nil {
+		// Fall through to the directory so a Redis outage doesn't break routing
+		log.Printf("Failed to get link region from cache: %v", err)
+	}
+
+	region := r.home
+	err := r.HomeDB().QueryRowContext(ctx, `SELECT region FROM link_regions WHERE short_code = $1`, shortCode).Scan(&region)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to get link region: %w", err)
+	}
+
+	if err := homeCache.Set(ctx, key, region, linkRegionCacheTTL).Err(); err != nil {
+		log.Printf("Failed to cache link region: %v", err)
+	}
+	return region, nil
+}
+
+// WithLinkRegion returns a context routed to the region holding a short code.
+// Lookup failures fall back to the home region.
+func (r *RegionRouter) WithLinkRegion(ctx context.Context, shortCode string) context.Context {
+	if !r.MultiRegion() || RegionFromContext(ctx) != "" {
+		return ctx
+	}
+
+	region, err := r.LinkRegion(ctx, shortCode)
+	if err != nil {
+		log.Printf("Failed to route link %s to its region: %v", shortCode, err)
+		return ctx
+	}
+	return WithRegion(ctx, region)
+}
+
+// EnsureUser mirrors a minimal row for a home-region user into the context's
+// region, so its link tables can reference them. Credentials never leave home.
+func (r *RegionRouter) EnsureUser(ctx context.Context, userID int) error {
+	if r.IsHome(ctx) {
+		return nil
+	}
+
+	var email, firstName, lastName string
+	query := `SELECT email, first_name, last_name FROM users WHERE id = $1`
+	if err := r.HomeDB().QueryRowContext(ctx, query, userID).Scan(&email, &firstName, &lastName); err != nil {
+		return fmt.Errorf("failed to get user to mirror into region: %w", err)
+	}
+
+	query = `
+		INSERT INTO users (id, email, password, first_name, last_name)
+		VALUES ($1, $2, '', $3, $4)
+		ON CONFLICT (id) DO NOTHING`
+	if _, err := r.DB(ctx).ExecContext(ctx, query, userID, email, firstName, lastName); err != nil {
+		return fmt.Errorf("failed to mirror user into region: %w", err)
+	}
+	return nil
+}
+
+// linkRegionKey returns the home cache key remembering a link's region
+func linkRegionKey(shortCode string) string {
+	return fmt.Sprintf("link_region:%s", shortCode)
+}
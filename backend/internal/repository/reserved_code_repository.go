@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hpower2/url-shortener/database"
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// ReservedCodeRepository interface defines the contract for operator-reserved short code database operations
+type ReservedCodeRepository interface {
+	Create(ctx context.Context, code *models.ReservedCode) (bool, error)
+	GetAll(ctx context.Context) ([]models.ReservedCode, error)
+	Delete(ctx context.Context, code string) error
+}
+
+// reservedCodeRepository implements ReservedCodeRepository interface
+type reservedCodeRepository struct {
+	db *database.DB
+}
+
+// NewReservedCodeRepository creates a new reserved code repository
+func NewReservedCodeRepository(db *database.DB) ReservedCodeRepository {
+	return &reservedCodeRepository{db: db}
+}
+
+// Create reserves a code, returning false if it is already reserved
+func (r *reservedCodeRepository) Create(ctx context.Context, code *models.ReservedCode) (bool, error) {
+	query := `
+		INSERT INTO reserved_codes (code, reason, honeytoken)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (code) DO NOTHING
+		RETURNING created_at`
+
+	rows, err := r.db.QueryContext(ctx, query, code.Code, code.Reason, code.Honeytoken)
+	if err != nil {
+		return false, fmt.Errorf("failed to create reserved code: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return false, rows.Err()
+	}
+	if err := rows.Scan(&code.CreatedAt); err != nil {
+		return false, fmt.Errorf("failed to scan reserved code: %w", err)
+	}
+	return true, nil
+}
+
+// GetAll retrieves every reserved code in alphabetical order
+func (r *reservedCodeRepository) GetAll(ctx context.Context) ([]models.ReservedCode, error) {
+	query := `SELECT code, reason, honeytoken, created_at FROM reserved_codes ORDER BY code`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reserved codes: %w", err)
+	}
+	defer rows.Close()
+
+	codes := []models.ReservedCode{}
+	for rows.Next() {
+		code := models.ReservedCode{Source: models.ReservedCodeSourceAdmin}
+		if err := rows.Scan(&code.Code, &code.Reason, &code.Honeytoken, &code.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan reserved code: %w", err)
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// Delete releases a reserved code
+func (r *reservedCodeRepository) Delete(ctx context.Context, code string) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM reserved_codes WHERE code = $1", code)
+	if err != nil {
+		return fmt.Errorf("failed to delete reserved code: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("reserved code %w", ErrNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/lib/pq"
+)
+
+// URLEventRepository interface defines the contract for the link lifecycle event log
+type URLEventRepository interface {
+	Append(ctx context.Context, event *models.URLEvent) (*models.URLEvent, error)
+	AppendBatch(ctx context.Context, urls []models.URL, eventType string, data []byte, createdAt time.Time) ([]models.URLEvent, error)
+	GetByURL(ctx context.Context, urlID int, opts *models.URLEventListOptions) ([]models.URLEvent, error)
+	GetUnpublished(ctx context.Context, before time.Time, limit int) ([]models.URLEvent, error)
+	MarkPublished(ctx context.Context, ids []int64, publishedAt time.Time) error
+	AppendExpirations(ctx context.Context, since, until time.Time, limit int) ([]models.URLEvent, error)
+}
+
+// urlEventRepository implements URLEventRepository interface
+type urlEventRepository struct {
+	regions *RegionRouter
+}
+
+// NewURLEventRepository creates a new link event repository. Events are
+// stored with their link, in the data region set on the context.
+func NewURLEventRepository(regions *RegionRouter) URLEventRepository {
+	return &urlEventRepository{regions: regions}
+}
+
+const urlEventColumns = `id, url_id, short_code, user_id, type, data, created_at, published_at`
+
+// scanURLEvent scans an event row selected with urlEventColumns
+func scanURLEvent(row rowScanner, event *models.URLEvent) error {
+	var data []byte
+	if err := row.Scan(
+		&event.ID, &event.URLID, &event.ShortCode, &event.UserID, &event.Type, &data,
+		&event.CreatedAt, &event.PublishedAt,
+	); err != nil {
+		return err
+	}
+	event.Data = data
+	return nil
+}
+
+// Append adds an event to the log. Events are never updated, other than
+// recording when they were published.
+func (r *urlEventRepository) Append(ctx context.Context, event *models.URLEvent) (*models.URLEvent, error) {
+	query := `
+		INSERT INTO url_events (url_id, short_code, user_id, type, data, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+
+	// JSONB takes the data as text
+	var data interface{}
+	if len(event.Data) > 0 {
+		data = string(event.Data)
+	}
+
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query,
+		event.URLID, event.ShortCode, event.UserID, event.Type, data, event.CreatedAt,
+	).Scan(&event.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to append link event: %w", err)
+	}
+	return event, nil
+}
+
+// AppendBatch adds an event of the same type and data for each link to the
+// log in a single statement
+func (r *urlEventRepository) AppendBatch(ctx context.Context, urls []models.URL, eventType string, data []byte, createdAt time.Time) ([]models.URLEvent, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]int64, len(urls))
+	shortCodes := make([]string, len(urls))
+	userIDs := make([]int64, len(urls))
+	for i := range urls {
+		ids[i] = int64(urls[i].ID)
+		shortCodes[i] = urls[i].ShortCode
+		userIDs[i] = int64(urls[i].UserID)
+	}
+
+	// JSONB takes the data as text
+	var jsonData interface{}
+	if len(data) > 0 {
+		jsonData = string(data)
+	}
+
+	query := `
+		INSERT INTO url_events (url_id, short_code, user_id, type, data, created_at)
+		SELECT url_id, short_code, user_id, $4, $5, $6
+		FROM unnest($1::int[], $2::text[], $3::int[]) AS t(url_id, short_code, user_id)
+		ON CONFLICT (url_id, type, created_at) DO NOTHING
+		RETURNING ` + urlEventColumns
+
+	return r.query(ctx, "append link events", query,
+		pq.Array(ids), pq.Array(shortCodes), pq.Array(userIDs), eventType, jsonData, createdAt)
+}
+
+// GetByURL retrieves a page of a link's events, newest first
+func (r *urlEventRepository) GetByURL(ctx context.Context, urlID int, opts *models.URLEventListOptions) ([]models.URLEvent, error) {
+	query := `SELECT ` + urlEventColumns + ` FROM url_events WHERE url_id = $1`
+	args := []interface{}{urlID}
+	if opts.BeforeID > 0 {
+		args = append(args, opts.BeforeID)
+		query += fmt.Sprintf(" AND id < $%d", len(args))
+	}
+	args = append(args, opts.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	return r.query(ctx, "get link events", query, args...)
+}
+
+// GetUnpublished retrieves up to limit events appended before a time that
+// haven't reached the message bus, oldest first
+func (r *urlEventRepository) GetUnpublished(ctx context.Context, before time.Time, limit int) ([]models.URLEvent, error) {
+	query := `
+		SELECT ` + urlEventColumns + `
+		FROM url_events
+		WHERE published_at IS NULL AND created_at < $1
+		ORDER BY id
+		LIMIT $2`
+
+	return r.query(ctx, "get unpublished link events", query, before, limit)
+}
+
+// MarkPublished records that events reached the message bus
+func (r *urlEventRepository) MarkPublished(ctx context.Context, ids []int64, publishedAt time.Time) error {
+	query := `UPDATE url_events SET published_at = $2 WHERE id = ANY($1) AND published_at IS NULL`
+
+	if _, err := r.regions.DB(ctx).ExecContext(ctx, query, pq.Array(ids), publishedAt); err != nil {
+		return fmt.Errorf("failed to mark link events published: %w", err)
+	}
+	return nil
+}
+
+// AppendExpirations appends an expired event, dated when the link expired,
+// for up to limit links that expired within [since, until) and have none for
+// their current expiration yet
+func (r *urlEventRepository) AppendExpirations(ctx context.Context, since, until time.Time, limit int) ([]models.URLEvent, error) {
+	query := `
+		INSERT INTO url_events (url_id, short_code, user_id, type, data, created_at)
+		SELECT u.id, u.short_code, u.user_id, $4, jsonb_build_object('expires_at', u.expires_at), u.expires_at
+		FROM urls u
+		WHERE u.expires_at >= $1 AND u.expires_at < $2
+		  AND NOT EXISTS (
+			SELECT 1 FROM url_events e
+			WHERE e.url_id = u.id AND e.type = $4 AND e.created_at >= u.expires_at
+		  )
+		ORDER BY u.expires_at
+		LIMIT $3
+		ON CONFLICT (url_id, type, created_at) DO NOTHING
+		RETURNING ` + urlEventColumns
+
+	return r.query(ctx, "append link expirations", query, since, until, limit, models.URLEventExpired)
+}
+
+// query runs a statement returning events
+func (r *urlEventRepository) query(ctx context.Context, action, query string, args ...interface{}) ([]models.URLEvent, error) {
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s: %w", action, err)
+	}
+	defer rows.Close()
+
+	events := []models.URLEvent{}
+	for rows.Next() {
+		var event models.URLEvent
+		if err := scanURLEvent(rows, &event); err != nil {
+			return nil, fmt.Errorf("failed to scan link event: %w", err)
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
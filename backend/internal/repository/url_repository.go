@@ -4,35 +4,97 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
 	"time"
 
-	"github.com/hpower2/url-shortener/database"
 	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/lib/pq"
 )
 
+// urlColumns lists the columns selected for a URL, in scanURL order
+const urlColumns = `id, short_code, original_url, user_id, created_at, updated_at, click_count,
+			   is_active, expires_at, user_agent, ip_address, needs_review,
+			   referrer_mode, referrer_domains, referrer_fallback_url, password_hash,
+			   last_clicked_at, inactivity_expiry_days, max_clicks_per_minute, frequency_cap, frequency_cap_url,
+			   shadow_url, shadow_until, rotation_mode, max_clicks, redirect_count, utm_params, title, notes, labels,
+			   threat_type, suspicious, canary_url, canary_status, canary_start_percent,
+			   canary_started_at, canary_ends_at, canary_failures, redirect_type, split_mode, device_rules,
+			   page_title, page_description, favicon_url, metadata_fetched_at, domain,
+			   activates_at, expiry_deactivated, click_limit_deactivated`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanURL scans a row selected with urlColumns into a URL
+func scanURL(row rowScanner, url *models.URL) error {
+	err := row.Scan(
+		&url.ID, &url.ShortCode, &url.OriginalURL, &url.UserID, &url.CreatedAt, &url.UpdatedAt,
+		&url.ClickCount, &url.IsActive, &url.ExpiresAt, &url.UserAgent, &url.IPAddress,
+		&url.NeedsReview, &url.ReferrerMode, pq.Array(&url.ReferrerDomains), &url.ReferrerFallbackURL,
+		&url.PasswordHash, &url.LastClickedAt, &url.InactivityExpiryDays,
+		&url.MaxClicksPerMinute, &url.FrequencyCap, &url.FrequencyCapURL,
+		&url.ShadowURL, &url.ShadowUntil, &url.RotationMode, &url.MaxClicks, &url.RedirectCount,
+		&url.UTM, &url.Title, &url.Notes, &url.Labels, &url.ThreatType, &url.Suspicious,
+		&url.CanaryURL, &url.CanaryStatus, &url.CanaryStartPercent,
+		&url.CanaryStartedAt, &url.CanaryEndsAt, &url.CanaryFailures, &url.RedirectType, &url.SplitMode,
+		&url.DeviceRules, &url.PageTitle, &url.PageDescription, &url.FaviconURL, &url.MetadataFetchedAt,
+		&url.Domain, &url.ActivatesAt, &url.ExpiryDeactivated, &url.ClickLimitDeactivated,
+	)
+	url.PasswordProtected = url.IsPasswordProtected()
+	return err
+}
+
 // urlRepository implements URLRepository interface
 type urlRepository struct {
-	db *database.DB
+	regions *RegionRouter
 }
 
-// NewURLRepository creates a new URL repository
-func NewURLRepository(db *database.DB) URLRepository {
-	return &urlRepository{db: db}
+// NewURLRepository creates a new URL repository. Links are stored in the data
+// region set on the context (see RegionRouter).
+func NewURLRepository(regions *RegionRouter) URLRepository {
+	return &urlRepository{regions: regions}
 }
 
 // Create creates a new URL record
 func (r *urlRepository) Create(ctx context.Context, url *models.URL) (*models.URL, error) {
+	if err := r.regions.EnsureUser(ctx, url.UserID); err != nil {
+		return nil, err
+	}
+	if err := r.regions.RegisterLink(ctx, url.ShortCode, url.UserID); err != nil {
+		return nil, err
+	}
+
 	query := `
-		INSERT INTO urls (short_code, original_url, user_id, is_active, expires_at, user_agent, ip_address, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO urls (short_code, original_url, user_id, is_active, expires_at, user_agent, ip_address, needs_review,
+		                  referrer_mode, referrer_domains, referrer_fallback_url, password_hash, inactivity_expiry_days,
+		                  max_clicks_per_minute, frequency_cap, frequency_cap_url, shadow_url, shadow_until, rotation_mode,
+		                  max_clicks, utm_params, notes, labels, created_at, updated_at, redirect_type, title, split_mode,
+		                  device_rules, domain, activates_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31)
 		RETURNING id, created_at, updated_at`
 
-	err := r.db.QueryRowContext(ctx, query,
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query,
 		url.ShortCode, url.OriginalURL, url.UserID, url.IsActive, url.ExpiresAt,
-		url.UserAgent, url.IPAddress, url.CreatedAt, url.UpdatedAt,
+		url.UserAgent, url.IPAddress, url.NeedsReview,
+		url.ReferrerMode, pq.Array(url.ReferrerDomains), url.ReferrerFallbackURL, url.PasswordHash, url.InactivityExpiryDays,
+		url.MaxClicksPerMinute, url.FrequencyCap, url.FrequencyCapURL, url.ShadowURL, url.ShadowUntil, url.RotationMode,
+		url.MaxClicks, url.UTM, url.Notes, url.Labels, url.CreatedAt, url.UpdatedAt, url.RedirectType, url.Title,
+		url.SplitMode, url.DeviceRules, url.Domain, url.ActivatesAt,
 	).Scan(&url.ID, &url.CreatedAt, &url.UpdatedAt)
 
 	if err != nil {
+		// Release the code claimed in the directory
+		if unregisterErr := r.regions.UnregisterLink(ctx, url.ShortCode); unregisterErr != nil {
+			log.Printf("Failed to release link region: %v", unregisterErr)
+		}
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("short code %w", ErrDuplicate)
+		}
 		return nil, fmt.Errorf("failed to create URL: %w", err)
 	}
 
@@ -42,20 +104,16 @@ func (r *urlRepository) Create(ctx context.Context, url *models.URL) (*models.UR
 // GetByShortCode retrieves a URL by short code
 func (r *urlRepository) GetByShortCode(ctx context.Context, shortCode string) (*models.URL, error) {
 	query := `
-		SELECT id, short_code, original_url, user_id, created_at, updated_at, click_count, 
-			   is_active, expires_at, user_agent, ip_address
+		SELECT ` + urlColumns + `
 		FROM urls 
 		WHERE short_code = $1`
 
 	url := &models.URL{}
-	err := r.db.QueryRowContext(ctx, query, shortCode).Scan(
-		&url.ID, &url.ShortCode, &url.OriginalURL, &url.UserID, &url.CreatedAt, &url.UpdatedAt,
-		&url.ClickCount, &url.IsActive, &url.ExpiresAt, &url.UserAgent, &url.IPAddress,
-	)
+	err := scanURL(r.regions.DB(ctx).QueryRowContext(ctx, query, shortCode), url)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("URL not found")
+			return nil, fmt.Errorf("URL %w", ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get URL: %w", err)
 	}
@@ -66,20 +124,16 @@ func (r *urlRepository) GetByShortCode(ctx context.Context, shortCode string) (*
 // GetByID retrieves a URL by ID
 func (r *urlRepository) GetByID(ctx context.Context, id int) (*models.URL, error) {
 	query := `
-		SELECT id, short_code, original_url, user_id, created_at, updated_at, click_count, 
-			   is_active, expires_at, user_agent, ip_address
+		SELECT ` + urlColumns + `
 		FROM urls 
 		WHERE id = $1`
 
 	url := &models.URL{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&url.ID, &url.ShortCode, &url.OriginalURL, &url.UserID, &url.CreatedAt, &url.UpdatedAt,
-		&url.ClickCount, &url.IsActive, &url.ExpiresAt, &url.UserAgent, &url.IPAddress,
-	)
+	err := scanURL(r.regions.DB(ctx).QueryRowContext(ctx, query, id), url)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("URL not found")
+			return nil, fmt.Errorf("URL %w", ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get URL: %w", err)
 	}
@@ -92,20 +146,19 @@ func (r *urlRepository) GetAll(ctx context.Context, limit, offset int) ([]models
 	// Get total count
 	var total int
 	countQuery := "SELECT COUNT(*) FROM urls"
-	err := r.db.QueryRowContext(ctx, countQuery).Scan(&total)
+	err := r.regions.DB(ctx).QueryRowContext(ctx, countQuery).Scan(&total)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to get total count: %w", err)
 	}
 
 	// Get URLs with pagination
 	query := `
-		SELECT id, short_code, original_url, created_at, updated_at, click_count, 
-			   is_active, expires_at, user_agent, ip_address
+		SELECT ` + urlColumns + `
 		FROM urls 
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2`
 
-	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, limit, offset)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to get URLs: %w", err)
 	}
@@ -114,10 +167,7 @@ func (r *urlRepository) GetAll(ctx context.Context, limit, offset int) ([]models
 	var urls []models.URL
 	for rows.Next() {
 		var url models.URL
-		err := rows.Scan(
-			&url.ID, &url.ShortCode, &url.OriginalURL, &url.CreatedAt, &url.UpdatedAt,
-			&url.ClickCount, &url.IsActive, &url.ExpiresAt, &url.UserAgent, &url.IPAddress,
-		)
+		err := scanURL(rows, &url)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan URL: %w", err)
 		}
@@ -128,25 +178,32 @@ func (r *urlRepository) GetAll(ctx context.Context, limit, offset int) ([]models
 }
 
 // GetAllByUser retrieves all URLs for a specific user with pagination
-func (r *urlRepository) GetAllByUser(ctx context.Context, userID int, limit, offset int) ([]models.URL, int, error) {
+func (r *urlRepository) GetAllByUser(ctx context.Context, userID int, opts *models.URLListOptions) ([]models.URL, int, error) {
+	where, args := urlListFilter(userID, opts, time.Now())
+
 	// Get total count for the user
 	var total int
-	countQuery := `SELECT COUNT(*) FROM urls WHERE user_id = $1`
-	err := r.db.QueryRowContext(ctx, countQuery, userID).Scan(&total)
+	countQuery := `SELECT COUNT(*) FROM urls ` + where
+	err := r.regions.DB(ctx).QueryRowContext(ctx, countQuery, args...).Scan(&total)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to get total count: %w", err)
 	}
 
-	// Get URLs for the user
-	query := `
-		SELECT id, short_code, original_url, user_id, created_at, updated_at, click_count, 
-			   is_active, expires_at, user_agent, ip_address
+	// The sort column is validated against a fixed set of names by the caller
+	direction := "DESC"
+	if opts.Ascending {
+		direction = "ASC"
+	}
+
+	// Get URLs for the user, in the same order GetPageByUser pages through
+	query := fmt.Sprintf(`
+		SELECT `+urlColumns+`
 		FROM urls 
-		WHERE user_id = $1
-		ORDER BY created_at DESC 
-		LIMIT $2 OFFSET $3`
+		%s
+		ORDER BY %s %s NULLS LAST, id %s
+		LIMIT $%d OFFSET $%d`, where, opts.SortBy, direction, direction, len(args)+1, len(args)+2)
 
-	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, append(args, opts.Limit, opts.Offset)...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to get URLs: %w", err)
 	}
@@ -155,10 +212,7 @@ func (r *urlRepository) GetAllByUser(ctx context.Context, userID int, limit, off
 	var urls []models.URL
 	for rows.Next() {
 		var url models.URL
-		err := rows.Scan(
-			&url.ID, &url.ShortCode, &url.OriginalURL, &url.UserID, &url.CreatedAt, &url.UpdatedAt,
-			&url.ClickCount, &url.IsActive, &url.ExpiresAt, &url.UserAgent, &url.IPAddress,
-		)
+		err := scanURL(rows, &url)
 		if err != nil {
 			return nil, 0, fmt.Errorf("failed to scan URL: %w", err)
 		}
@@ -168,16 +222,310 @@ func (r *urlRepository) GetAllByUser(ctx context.Context, userID int, limit, off
 	return urls, total, nil
 }
 
+// GetPageByUser retrieves up to opts.Limit of a user's URLs following
+// opts.After in the list's order, seeking past earlier pages through the index
+// instead of counting them off like an offset. Ties are broken by ID, and
+// links never clicked or without expiration come last when sorting by last
+// click or expiration.
+func (r *urlRepository) GetPageByUser(ctx context.Context, userID int, opts *models.URLListOptions) ([]models.URL, error) {
+	where, args := urlListFilter(userID, opts, time.Now())
+
+	// The sort column is validated against a fixed set of names by the caller
+	direction, seek := "DESC", "<"
+	if opts.Ascending {
+		direction, seek = "ASC", ">"
+	}
+
+	if after := opts.After; after != nil {
+		if value := after.SortValue(); value != nil {
+			args = append(args, value, after.ID)
+			where += fmt.Sprintf(" AND ((%s, id) %s ($%d, $%d) OR %s IS NULL)", opts.SortBy, seek, len(args)-1, len(args), opts.SortBy)
+		} else {
+			args = append(args, after.ID)
+			where += fmt.Sprintf(" AND %s IS NULL AND id %s $%d", opts.SortBy, seek, len(args))
+		}
+	}
+
+	query := fmt.Sprintf(`
+		SELECT `+urlColumns+`
+		FROM urls
+		%s
+		ORDER BY %s %s NULLS LAST, id %s
+		LIMIT $%d`, where, opts.SortBy, direction, direction, len(args)+1)
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, append(args, opts.Limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URLs: %w", err)
+	}
+	defer rows.Close()
+
+	urls := []models.URL{}
+	for rows.Next() {
+		var url models.URL
+		if err := scanURL(rows, &url); err != nil {
+			return nil, fmt.Errorf("failed to scan URL: %w", err)
+		}
+		urls = append(urls, url)
+	}
+
+	return urls, rows.Err()
+}
+
+// urlListFilter returns the WHERE clause and its arguments selecting the
+// user's URLs that match the list options' filters at now
+func urlListFilter(userID int, opts *models.URLListOptions, now time.Time) (string, []interface{}) {
+	where := "WHERE user_id = $1"
+	args := []interface{}{userID}
+	if opts.ClickedSince != nil {
+		args = append(args, *opts.ClickedSince)
+		where += fmt.Sprintf(" AND last_clicked_at >= $%d", len(args))
+	}
+	if opts.CreatedAfter != nil {
+		args = append(args, *opts.CreatedAfter)
+		where += fmt.Sprintf(" AND created_at >= $%d", len(args))
+	}
+	if opts.CreatedBefore != nil {
+		args = append(args, *opts.CreatedBefore)
+		where += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+
+	switch opts.Status {
+	case models.URLStatusActive:
+		args = append(args, now)
+		where += fmt.Sprintf(" AND is_active AND (expires_at IS NULL OR expires_at > $%d) AND (activates_at IS NULL OR activates_at <= $%d)", len(args), len(args))
+	case models.URLStatusScheduled:
+		args = append(args, now)
+		where += fmt.Sprintf(" AND is_active AND (expires_at IS NULL OR expires_at > $%d) AND activates_at > $%d", len(args), len(args))
+	case models.URLStatusExpired:
+		args = append(args, now)
+		where += fmt.Sprintf(" AND expires_at <= $%d", len(args))
+	case models.URLStatusInactive:
+		args = append(args, now)
+		where += fmt.Sprintf(" AND NOT is_active AND (expires_at IS NULL OR expires_at > $%d)", len(args))
+	}
+	return where, args
+}
+
+// GetAllByUserAfter retrieves up to limit of a user's URLs with IDs above
+// afterID, in ID order, so callers can walk every link in batches
+func (r *urlRepository) GetAllByUserAfter(ctx context.Context, userID, afterID, limit int) ([]models.URL, error) {
+	query := `
+		SELECT ` + urlColumns + `
+		FROM urls
+		WHERE user_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URLs: %w", err)
+	}
+	defer rows.Close()
+
+	urls := []models.URL{}
+	for rows.Next() {
+		var url models.URL
+		if err := scanURL(rows, &url); err != nil {
+			return nil, fmt.Errorf("failed to scan URL: %w", err)
+		}
+		urls = append(urls, url)
+	}
+
+	return urls, rows.Err()
+}
+
+// Search finds a user's URLs whose short code, destination, title, notes or labels match the query,
+// either as words (ranked by the full-text index) or as a substring (served by
+// the trigram indexes). Word matches rank first, then newest links.
+func (r *urlRepository) Search(ctx context.Context, userID int, opts *models.URLSearchOptions) ([]models.URL, int, error) {
+	where := `
+		WHERE user_id = $1
+		AND (search_vector @@ websearch_to_tsquery('simple', $2)
+			OR original_url ILIKE $3
+			OR short_code ILIKE $3
+			OR title ILIKE $3
+			OR notes ILIKE $3)`
+	pattern := "%" + likeEscaper.Replace(opts.Query) + "%"
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM urls ` + where
+	err := r.regions.DB(ctx).QueryRowContext(ctx, countQuery, userID, opts.Query, pattern).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count search results: %w", err)
+	}
+
+	query := `
+		SELECT ` + urlColumns + `
+		FROM urls ` + where + `
+		ORDER BY ts_rank(search_vector, websearch_to_tsquery('simple', $2)) DESC, created_at DESC, id DESC
+		LIMIT $4 OFFSET $5`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, userID, opts.Query, pattern, opts.Limit, opts.Offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to search URLs: %w", err)
+	}
+	defer rows.Close()
+
+	urls := []models.URL{}
+	for rows.Next() {
+		var url models.URL
+		if err := scanURL(rows, &url); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan URL: %w", err)
+		}
+		urls = append(urls, url)
+	}
+
+	return urls, total, rows.Err()
+}
+
+// GetPageByCodeNamespace lists the links of the given users whose short code
+// starts with namespace (ignoring case), newest first
+func (r *urlRepository) GetPageByCodeNamespace(ctx context.Context, namespace string, userIDs []int, limit, offset int) ([]models.URL, int, error) {
+	where := `WHERE LOWER(short_code) LIKE $1 AND user_id = ANY($2)`
+	pattern := likeEscaper.Replace(strings.ToLower(namespace)) + "%"
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM urls ` + where
+	err := r.regions.DB(ctx).QueryRowContext(ctx, countQuery, pattern, pq.Array(userIDs)).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count namespace URLs: %w", err)
+	}
+
+	query := `
+		SELECT ` + urlColumns + `
+		FROM urls ` + where + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, pattern, pq.Array(userIDs), limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get namespace URLs: %w", err)
+	}
+	defer rows.Close()
+
+	urls := []models.URL{}
+	for rows.Next() {
+		var url models.URL
+		if err := scanURL(rows, &url); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan URL: %w", err)
+		}
+		urls = append(urls, url)
+	}
+
+	return urls, total, rows.Err()
+}
+
+// GetCodeNamespaceRollup sums up the links of the given users whose short code
+// starts with namespace: link and click totals, the most clicked links and each
+// user's share
+func (r *urlRepository) GetCodeNamespaceRollup(ctx context.Context, namespace string, userIDs []int, topLinks int) (*models.CodePrefixRollup, error) {
+	where := `WHERE LOWER(short_code) LIKE $1 AND user_id = ANY($2)`
+	pattern := likeEscaper.Replace(strings.ToLower(namespace)) + "%"
+	db := r.regions.DB(ctx)
+
+	rollup := &models.CodePrefixRollup{
+		Namespace: namespace,
+		TopLinks:  []models.PrefixLinkStats{},
+		Members:   []models.PrefixMemberStats{},
+	}
+	totalsQuery := `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE is_active), COALESCE(SUM(click_count), 0), MAX(last_clicked_at)
+		FROM urls ` + where
+	err := db.QueryRowContext(ctx, totalsQuery, pattern, pq.Array(userIDs)).Scan(
+		&rollup.Links, &rollup.ActiveLinks, &rollup.Clicks, &rollup.LastClickedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum up namespace URLs: %w", err)
+	}
+
+	topQuery := `
+		SELECT short_code, original_url, user_id, click_count
+		FROM urls ` + where + `
+		ORDER BY click_count DESC, id
+		LIMIT $3`
+	rows, err := db.QueryContext(ctx, topQuery, pattern, pq.Array(userIDs), topLinks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top namespace URLs: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var link models.PrefixLinkStats
+		if err := rows.Scan(&link.ShortCode, &link.OriginalURL, &link.UserID, &link.Clicks); err != nil {
+			return nil, fmt.Errorf("failed to scan namespace URL: %w", err)
+		}
+		rollup.TopLinks = append(rollup.TopLinks, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	membersQuery := `
+		SELECT user_id, COUNT(*), COALESCE(SUM(click_count), 0)
+		FROM urls ` + where + `
+		GROUP BY user_id
+		ORDER BY 3 DESC, user_id`
+	memberRows, err := db.QueryContext(ctx, membersQuery, pattern, pq.Array(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum up namespace URLs by member: %w", err)
+	}
+	defer memberRows.Close()
+	for memberRows.Next() {
+		var member models.PrefixMemberStats
+		if err := memberRows.Scan(&member.UserID, &member.Links, &member.Clicks); err != nil {
+			return nil, fmt.Errorf("failed to scan namespace member: %w", err)
+		}
+		rollup.Members = append(rollup.Members, member)
+	}
+
+	return rollup, memberRows.Err()
+}
+
+// CountCodeNamespaceConflicts counts the links of users other than the given
+// ones created before a time whose short code starts with namespace, ignoring case
+func (r *urlRepository) CountCodeNamespaceConflicts(ctx context.Context, namespace string, userIDs []int, createdBefore time.Time) (int, error) {
+	query := `SELECT COUNT(*) FROM urls WHERE LOWER(short_code) LIKE $1 AND NOT (user_id = ANY($2)) AND created_at < $3`
+	pattern := likeEscaper.Replace(strings.ToLower(namespace)) + "%"
+
+	var count int
+	if err := r.regions.DB(ctx).QueryRowContext(ctx, query, pattern, pq.Array(userIDs), createdBefore).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count namespace conflicts: %w", err)
+	}
+	return count, nil
+}
+
+// likeEscaper escapes the LIKE wildcards in user input
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
 // Update updates a URL record
 func (r *urlRepository) Update(ctx context.Context, url *models.URL) (*models.URL, error) {
 	query := `
 		UPDATE urls 
-		SET original_url = $2, is_active = $3, expires_at = $4, updated_at = $5
+		SET original_url = $2, is_active = $3, expires_at = $4, needs_review = $5,
+		    referrer_mode = $6, referrer_domains = $7, referrer_fallback_url = $8, password_hash = $9,
+		    inactivity_expiry_days = $10, max_clicks_per_minute = $11,
+		    frequency_cap = $12, frequency_cap_url = $13, shadow_url = $14, shadow_until = $15,
+		    rotation_mode = $16, max_clicks = $17, notes = $18, labels = $19, updated_at = $20,
+		    canary_url = $21, canary_status = $22, canary_start_percent = $23,
+		    canary_started_at = $24, canary_ends_at = $25, canary_failures = $26, redirect_type = $27,
+		    title = $28, split_mode = $29, device_rules = $30, domain = $31, activates_at = $32,
+		    expiry_deactivated = $33, click_limit_deactivated = $34,
+		    page_title = CASE WHEN original_url = $2 THEN page_title ELSE '' END,
+		    page_description = CASE WHEN original_url = $2 THEN page_description ELSE '' END,
+		    favicon_url = CASE WHEN original_url = $2 THEN favicon_url ELSE '' END,
+		    metadata_fetched_at = CASE WHEN original_url = $2 THEN metadata_fetched_at END,
+		    metadata_checked_at = CASE WHEN original_url = $2 THEN metadata_checked_at END
 		WHERE short_code = $1
 		RETURNING id, created_at, updated_at`
 
-	err := r.db.QueryRowContext(ctx, query,
-		url.ShortCode, url.OriginalURL, url.IsActive, url.ExpiresAt, time.Now(),
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query,
+		url.ShortCode, url.OriginalURL, url.IsActive, url.ExpiresAt, url.NeedsReview,
+		url.ReferrerMode, pq.Array(url.ReferrerDomains), url.ReferrerFallbackURL, url.PasswordHash,
+		url.InactivityExpiryDays, url.MaxClicksPerMinute, url.FrequencyCap, url.FrequencyCapURL,
+		url.ShadowURL, url.ShadowUntil, url.RotationMode, url.MaxClicks, url.Notes, url.Labels, time.Now(),
+		url.CanaryURL, url.CanaryStatus, url.CanaryStartPercent,
+		url.CanaryStartedAt, url.CanaryEndsAt, url.CanaryFailures, url.RedirectType,
+		url.Title, url.SplitMode, url.DeviceRules, url.Domain, url.ActivatesAt,
+		url.ExpiryDeactivated, url.ClickLimitDeactivated,
 	).Scan(&url.ID, &url.CreatedAt, &url.UpdatedAt)
 
 	if err != nil {
@@ -190,7 +538,7 @@ func (r *urlRepository) Update(ctx context.Context, url *models.URL) (*models.UR
 // Delete deletes a URL by short code
 func (r *urlRepository) Delete(ctx context.Context, shortCode string) error {
 	query := "DELETE FROM urls WHERE short_code = $1"
-	result, err := r.db.ExecContext(ctx, query, shortCode)
+	result, err := r.regions.DB(ctx).ExecContext(ctx, query, shortCode)
 	if err != nil {
 		return fmt.Errorf("failed to delete URL: %w", err)
 	}
@@ -201,16 +549,16 @@ func (r *urlRepository) Delete(ctx context.Context, shortCode string) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("URL not found")
+		return fmt.Errorf("URL %w", ErrNotFound)
 	}
 
-	return nil
+	return r.regions.UnregisterLink(ctx, shortCode)
 }
 
 // DeleteByUser deletes a URL by short code for a specific user
 func (r *urlRepository) DeleteByUser(ctx context.Context, shortCode string, userID int) error {
 	query := `DELETE FROM urls WHERE short_code = $1 AND user_id = $2`
-	result, err := r.db.ExecContext(ctx, query, shortCode, userID)
+	result, err := r.regions.DB(ctx).ExecContext(ctx, query, shortCode, userID)
 	if err != nil {
 		return fmt.Errorf("failed to delete URL: %w", err)
 	}
@@ -221,27 +569,148 @@ func (r *urlRepository) DeleteByUser(ctx context.Context, shortCode string, user
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("URL not found or not owned by user")
+		return fmt.Errorf("URL %w", ErrNotFound)
 	}
 
-	return nil
+	return r.regions.UnregisterLink(ctx, shortCode)
+}
+
+// GetLabeledLinksImpact counts a user's links carrying a label value and their
+// clicks, with the short codes of up to sample of them, most clicked first
+func (r *urlRepository) GetLabeledLinksImpact(ctx context.Context, userID int, label, value string, sample int) (*models.LabeledLinksImpact, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(click_count), 0),
+		       COALESCE((ARRAY_AGG(short_code ORDER BY click_count DESC, id))[1:$4], '{}')
+		FROM urls
+		WHERE user_id = $1 AND labels ->> $2 = $3`
+
+	impact := &models.LabeledLinksImpact{Label: label, Value: value}
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query, userID, label, value, sample).Scan(
+		&impact.Links, &impact.Clicks, pq.Array(&impact.ShortCodes),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count labeled URLs: %w", err)
+	}
+	if impact.ShortCodes == nil {
+		impact.ShortCodes = []string{}
+	}
+	return impact, nil
+}
+
+// DeleteByLabel deletes a user's links carrying a label value and returns them,
+// with only their ID, short code and owner set. Unless exactly expected links
+// carry it, nothing is deleted and ErrChanged is returned.
+func (r *urlRepository) DeleteByLabel(ctx context.Context, userID int, label, value string, expected int) ([]models.URL, error) {
+	tx, err := r.regions.DB(ctx).BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM urls WHERE user_id = $1 AND labels ->> $2 = $3 RETURNING id, short_code, user_id`
+
+	rows, err := tx.QueryContext(ctx, query, userID, label, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete labeled URLs: %w", err)
+	}
+	defer rows.Close()
+
+	urls := []models.URL{}
+	for rows.Next() {
+		var url models.URL
+		if err := rows.Scan(&url.ID, &url.ShortCode, &url.UserID); err != nil {
+			return nil, fmt.Errorf("failed to scan deleted URL: %w", err)
+		}
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(urls) != expected {
+		return nil, fmt.Errorf("labeled URLs %w", ErrChanged)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, url := range urls {
+		if err := r.regions.UnregisterLink(ctx, url.ShortCode); err != nil {
+			return urls, err
+		}
+	}
+	return urls, nil
+}
+
+// CountByDomain counts a user's links shared on a custom domain
+func (r *urlRepository) CountByDomain(ctx context.Context, userID int, hostname string) (int, error) {
+	var count int
+	err := r.regions.DB(ctx).QueryRowContext(ctx, `SELECT COUNT(*) FROM urls WHERE user_id = $1 AND domain = $2`, userID, hostname).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count URLs by domain: %w", err)
+	}
+	return count, nil
+}
+
+// ClearDomain moves a user's links on a custom domain back to the default
+// domain and returns how many moved
+func (r *urlRepository) ClearDomain(ctx context.Context, userID int, hostname string) (int64, error) {
+	result, err := r.regions.DB(ctx).ExecContext(ctx,
+		`UPDATE urls SET domain = '', updated_at = $3 WHERE user_id = $1 AND domain = $2`, userID, hostname, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to clear URL domain: %w", err)
+	}
+	return result.RowsAffected()
 }
 
-// ExistsByShortCode checks if a URL exists by short code
+// ExistsByShortCode checks if a URL exists by short code in any region,
+// since short codes are shared by every region
 func (r *urlRepository) ExistsByShortCode(ctx context.Context, shortCode string) (bool, error) {
 	query := "SELECT EXISTS(SELECT 1 FROM urls WHERE short_code = $1)"
 	var exists bool
-	err := r.db.QueryRowContext(ctx, query, shortCode).Scan(&exists)
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query, shortCode).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check URL existence: %w", err)
+	}
+	if exists || !r.regions.MultiRegion() {
+		return exists, nil
+	}
+
+	// Home links are checked directly, links in other regions through the directory
+	query = `
+		SELECT EXISTS(SELECT 1 FROM urls WHERE short_code = $1)
+		    OR EXISTS(SELECT 1 FROM link_regions WHERE short_code = $1)`
+	err = r.regions.HomeDB().QueryRowContext(ctx, query, shortCode).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("failed to check URL existence: %w", err)
 	}
 	return exists, nil
 }
 
-// IncrementClickCount increments the click count for a URL
+// CountGeneratedShortCodes counts the region's short codes that could have been
+// generated at the given length: that long and drawn from the generated alphabet
+func (r *urlRepository) CountGeneratedShortCodes(ctx context.Context, length int) (int64, error) {
+	query := `SELECT COUNT(*) FROM urls WHERE char_length(short_code) = $1 AND short_code ~ '^[A-Za-z0-9]+$'`
+	var count int64
+	if err := r.regions.DB(ctx).QueryRowContext(ctx, query, length).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count short codes: %w", err)
+	}
+	return count, nil
+}
+
+// NextShortCodeSequence draws the next number for a generated short code. The
+// sequence lives in the home region, so numbers are unique across regions.
+func (r *urlRepository) NextShortCodeSequence(ctx context.Context) (int64, error) {
+	var seq int64
+	if err := r.regions.HomeDB().QueryRowContext(ctx, "SELECT nextval('short_code_seq')").Scan(&seq); err != nil {
+		return 0, fmt.Errorf("failed to get next short code number: %w", err)
+	}
+	return seq, nil
+}
+
+// IncrementClickCount increments the click count for a URL and records when it was last clicked
 func (r *urlRepository) IncrementClickCount(ctx context.Context, shortCode string) error {
-	query := "UPDATE urls SET click_count = click_count + 1 WHERE short_code = $1"
-	_, err := r.db.ExecContext(ctx, query, shortCode)
+	query := "UPDATE urls SET click_count = click_count + 1, last_clicked_at = $2 WHERE short_code = $1"
+	_, err := r.regions.DB(ctx).ExecContext(ctx, query, shortCode, time.Now())
 	if err != nil {
 		return fmt.Errorf("failed to increment click count: %w", err)
 	}
@@ -251,12 +720,14 @@ func (r *urlRepository) IncrementClickCount(ctx context.Context, shortCode strin
 // CreateClickEvent creates a new click event record
 func (r *urlRepository) CreateClickEvent(ctx context.Context, clickEvent *models.ClickEvent) error {
 	query := `
-		INSERT INTO click_events (url_id, ip_address, user_agent, referer, country, city, clicked_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+		INSERT INTO click_events (url_id, ip_address, user_agent, referer, country, city, browser, device, os, clicked_at, sample_weight, attributes, channel)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err := r.regions.DB(ctx).ExecContext(ctx, query,
 		clickEvent.URLId, clickEvent.IPAddress, clickEvent.UserAgent,
-		clickEvent.Referer, clickEvent.Country, clickEvent.City, clickEvent.ClickedAt,
+		clickEvent.Referer, clickEvent.Country, clickEvent.City,
+		clickEvent.Browser, clickEvent.Device, clickEvent.OS, clickEvent.ClickedAt, clickEvent.SampleWeight,
+		clickEvent.Attributes, clickEvent.Channel,
 	)
 
 	if err != nil {
@@ -266,16 +737,34 @@ func (r *urlRepository) CreateClickEvent(ctx context.Context, clickEvent *models
 	return nil
 }
 
+// CreateBlockedClick records a redirect attempt rejected by a link's access rules
+func (r *urlRepository) CreateBlockedClick(ctx context.Context, blockedClick *models.BlockedClick) error {
+	query := `
+		INSERT INTO blocked_clicks (url_id, reason, ip_address, user_agent, referer, blocked_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := r.regions.DB(ctx).ExecContext(ctx, query,
+		blockedClick.URLId, blockedClick.Reason, blockedClick.IPAddress,
+		blockedClick.UserAgent, blockedClick.Referer, blockedClick.BlockedAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create blocked click: %w", err)
+	}
+
+	return nil
+}
+
 // GetClickEvents retrieves click events for a URL
 func (r *urlRepository) GetClickEvents(ctx context.Context, urlID int, limit int) ([]models.ClickEvent, error) {
 	query := `
-		SELECT id, url_id, ip_address, user_agent, referer, country, city, clicked_at
+		SELECT id, url_id, ip_address, user_agent, referer, country, city, browser, device, os, clicked_at, sample_weight, attributes, channel
 		FROM click_events 
 		WHERE url_id = $1
 		ORDER BY clicked_at DESC
 		LIMIT $2`
 
-	rows, err := r.db.QueryContext(ctx, query, urlID, limit)
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, urlID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get click events: %w", err)
 	}
@@ -286,7 +775,8 @@ func (r *urlRepository) GetClickEvents(ctx context.Context, urlID int, limit int
 		var event models.ClickEvent
 		err := rows.Scan(
 			&event.ID, &event.URLId, &event.IPAddress, &event.UserAgent,
-			&event.Referer, &event.Country, &event.City, &event.ClickedAt,
+			&event.Referer, &event.Country, &event.City,
+			&event.Browser, &event.Device, &event.OS, &event.ClickedAt, &event.SampleWeight, &event.Attributes, &event.Channel,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan click event: %w", err)
@@ -297,75 +787,1480 @@ func (r *urlRepository) GetClickEvents(ctx context.Context, urlID int, limit int
 	return events, nil
 }
 
+// GetClickEventsBefore retrieves up to limit click events of a URL recorded
+// before the event beforeID (0 for the newest), newest first, limited to
+// those clicked since since
+func (r *urlRepository) GetClickEventsBefore(ctx context.Context, urlID, beforeID int, since time.Time, limit int) ([]models.ClickEvent, error) {
+	query := `
+		SELECT id, url_id, ip_address, user_agent, referer, country, city, browser, device, os, clicked_at, sample_weight, attributes, channel
+		FROM click_events
+		WHERE url_id = $1 AND ($2 = 0 OR id < $2) AND clicked_at >= $3
+		ORDER BY id DESC
+		LIMIT $4`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, urlID, beforeID, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get click events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.ClickEvent{}
+	for rows.Next() {
+		var event models.ClickEvent
+		err := rows.Scan(
+			&event.ID, &event.URLId, &event.IPAddress, &event.UserAgent,
+			&event.Referer, &event.Country, &event.City,
+			&event.Browser, &event.Device, &event.OS, &event.ClickedAt, &event.SampleWeight, &event.Attributes, &event.Channel,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan click event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// GetClickEventsAfter retrieves up to limit click events of a URL recorded
+// after the event afterID, in ID order, limited to those clicked within [since, until)
+func (r *urlRepository) GetClickEventsAfter(ctx context.Context, urlID, afterID int, since, until time.Time, limit int) ([]models.ClickEvent, error) {
+	query := `
+		SELECT id, url_id, ip_address, user_agent, referer, country, city, browser, device, os, clicked_at, sample_weight, attributes, channel
+		FROM click_events
+		WHERE url_id = $1 AND id > $2 AND clicked_at >= $3 AND clicked_at < $4
+		ORDER BY id
+		LIMIT $5`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, urlID, afterID, since, until, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get click events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.ClickEvent{}
+	for rows.Next() {
+		var event models.ClickEvent
+		err := rows.Scan(
+			&event.ID, &event.URLId, &event.IPAddress, &event.UserAgent,
+			&event.Referer, &event.Country, &event.City,
+			&event.Browser, &event.Device, &event.OS, &event.ClickedAt, &event.SampleWeight, &event.Attributes, &event.Channel,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan click event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
 // GetAnalytics retrieves analytics data for a URL
-func (r *urlRepository) GetAnalytics(ctx context.Context, urlID int, days int) (*models.URLAnalytics, error) {
+// Day boundaries ("today", "this week") are computed in loc.
+func (r *urlRepository) GetAnalytics(ctx context.Context, urlID int, days int, loc *time.Location) (*models.URLAnalytics, error) {
 	// For now, return basic analytics - you can enhance this with more complex queries
 	analytics := &models.URLAnalytics{
-		TotalClicks:    0,
-		UniqueClicks:   0,
-		ClicksToday:    0,
-		ClicksThisWeek: 0,
-		TopCountries:   []models.CountryStats{},
-		TopReferrers:   []models.ReferrerStats{},
+		TotalClicks:     0,
+		UniqueClicks:    0,
+		ClicksToday:     0,
+		ClicksThisWeek:  0,
+		TopCountries:    []models.CountryStats{},
+		TopReferrers:    []models.ReferrerStats{},
+		TopBrowsers:     []models.BrowserStats{},
+		TopDevices:      []models.DeviceStats{},
+		TopOS:           []models.OSStats{},
+		ClicksByChannel: []models.ChannelStats{},
+		Timezone:        loc.String(),
 	}
 
-	// Get total clicks
-	query := "SELECT COUNT(*) FROM click_events WHERE url_id = $1"
-	err := r.db.QueryRowContext(ctx, query, urlID).Scan(&analytics.TotalClicks)
+	now := time.Now().In(loc)
+	startOfToday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	startOfWeek := startOfToday.AddDate(0, 0, -7)
+
+	// Get total clicks, weighting sampled events by the clicks each stands for
+	var maxWeight int
+	query := "SELECT COALESCE(SUM(sample_weight), 0), COALESCE(MAX(sample_weight), 1) FROM click_events WHERE url_id = $1"
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query, urlID).Scan(&analytics.TotalClicks, &maxWeight)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get total clicks: %w", err)
 	}
+	if maxWeight > 1 {
+		analytics.Sampled = true
+		analytics.SampleRate = 1 / float64(maxWeight)
+	}
 
 	// Get unique clicks (unique IP addresses)
 	query = "SELECT COUNT(DISTINCT ip_address) FROM click_events WHERE url_id = $1"
-	err = r.db.QueryRowContext(ctx, query, urlID).Scan(&analytics.UniqueClicks)
+	err = r.regions.DB(ctx).QueryRowContext(ctx, query, urlID).Scan(&analytics.UniqueClicks)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get unique clicks: %w", err)
 	}
 
 	// Get clicks today
-	query = "SELECT COUNT(*) FROM click_events WHERE url_id = $1 AND clicked_at >= CURRENT_DATE"
-	err = r.db.QueryRowContext(ctx, query, urlID).Scan(&analytics.ClicksToday)
+	query = "SELECT COALESCE(SUM(sample_weight), 0) FROM click_events WHERE url_id = $1 AND clicked_at >= $2"
+	err = r.regions.DB(ctx).QueryRowContext(ctx, query, urlID, startOfToday).Scan(&analytics.ClicksToday)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get clicks today: %w", err)
 	}
 
 	// Get clicks this week
-	query = "SELECT COUNT(*) FROM click_events WHERE url_id = $1 AND clicked_at >= CURRENT_DATE - INTERVAL '7 days'"
-	err = r.db.QueryRowContext(ctx, query, urlID).Scan(&analytics.ClicksThisWeek)
+	query = "SELECT COALESCE(SUM(sample_weight), 0) FROM click_events WHERE url_id = $1 AND clicked_at >= $2"
+	err = r.regions.DB(ctx).QueryRowContext(ctx, query, urlID, startOfWeek).Scan(&analytics.ClicksThisWeek)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get clicks this week: %w", err)
 	}
 
+	if err := r.addClientStats(ctx, urlID, days, analytics); err != nil {
+		return nil, err
+	}
+
+	if err := r.addBlockedClickStats(ctx, urlID, analytics); err != nil {
+		return nil, err
+	}
+
 	return analytics, nil
 }
 
-// GetAnalyticsByUser retrieves URL analytics for a specific user
-func (r *urlRepository) GetAnalyticsByUser(ctx context.Context, urlID int, userID int, days int) (*models.URLAnalytics, error) {
-	// First check if the URL belongs to the user
-	ownershipQuery := `SELECT COUNT(*) FROM urls WHERE id = $1 AND user_id = $2`
-	var count int
-	err := r.db.QueryRowContext(ctx, ownershipQuery, urlID, userID).Scan(&count)
-	if err != nil {
-		return nil, fmt.Errorf("failed to check ownership: %w", err)
+// addClientStats adds the countries, browsers, device types, operating systems
+// and channels clicks came from over the last days days, as aggregate-only
+// analytics count them. Clicks from no known country, or recorded before user
+// agents were parsed, are counted as unknown.
+func (r *urlRepository) addClientStats(ctx context.Context, urlID int, days int, analytics *models.URLAnalytics) error {
+	if days <= 0 {
+		days = 30
 	}
+	since := time.Now().AddDate(0, 0, -days)
 
-	if count == 0 {
-		return nil, fmt.Errorf("URL not found or not owned by user")
+	columns := []string{
+		models.AggregateDimensionCountry,
+		models.AggregateDimensionBrowser, models.AggregateDimensionDevice,
+		models.AggregateDimensionOS, models.AggregateDimensionChannel,
+	}
+	for _, column := range columns {
+		// column is one of the fixed names above, never user input
+		query := fmt.Sprintf(`
+			SELECT COALESCE(NULLIF(%[1]s, ''), $3), SUM(sample_weight) AS clicks
+			FROM click_events
+			WHERE url_id = $1 AND clicked_at >= $2
+			GROUP BY 1
+			ORDER BY clicks DESC
+			LIMIT 10`, column)
+		rows, err := r.regions.DB(ctx).QueryContext(ctx, query, urlID, since, models.UnknownClient)
+		if err != nil {
+			return fmt.Errorf("failed to get clicks by %s: %w", column, err)
+		}
+		for rows.Next() {
+			var value string
+			var clicks int
+			if err := rows.Scan(&value, &clicks); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan clicks by %s: %w", column, err)
+			}
+			analytics.AddDimensionStats(column, value, clicks)
+		}
+		rows.Close()
 	}
 
-	// Use the existing GetAnalytics method
-	return r.GetAnalytics(ctx, urlID, days)
+	return nil
+}
+
+// addBlockedClickStats adds redirect attempts rejected by access rules to the analytics
+func (r *urlRepository) addBlockedClickStats(ctx context.Context, urlID int, analytics *models.URLAnalytics) error {
+	// Get blocked redirect attempts by reason
+	query := "SELECT reason, COUNT(*) FROM blocked_clicks WHERE url_id = $1 GROUP BY reason"
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, urlID)
+	if err != nil {
+		return fmt.Errorf("failed to get blocked clicks: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var reason string
+		var count int
+		if err := rows.Scan(&reason, &count); err != nil {
+			return fmt.Errorf("failed to scan blocked clicks: %w", err)
+		}
+		if analytics.BlockedClicks == nil {
+			analytics.BlockedClicks = make(map[string]int)
+		}
+		analytics.BlockedClicks[reason] = count
+	}
+
+	// Get the referrers most often rejected by referrer rules
+	query = `
+		SELECT COALESCE(NULLIF(referer, ''), 'direct'), COUNT(*) AS clicks
+		FROM blocked_clicks
+		WHERE url_id = $1 AND reason = $2
+		GROUP BY 1
+		ORDER BY clicks DESC
+		LIMIT 10`
+	referrerRows, err := r.regions.DB(ctx).QueryContext(ctx, query, urlID, models.BlockReasonReferrer)
+	if err != nil {
+		return fmt.Errorf("failed to get rejected referrers: %w", err)
+	}
+	defer referrerRows.Close()
+
+	for referrerRows.Next() {
+		var stats models.ReferrerStats
+		if err := referrerRows.Scan(&stats.Referrer, &stats.Clicks); err != nil {
+			return fmt.Errorf("failed to scan rejected referrers: %w", err)
+		}
+		analytics.RejectedReferrers = append(analytics.RejectedReferrers, stats)
+	}
+
+	return nil
+}
+
+// RecordClickAggregate counts a click in the hourly and per-day dimension aggregates
+func (r *urlRepository) RecordClickAggregate(ctx context.Context, aggregate *models.ClickAggregate) error {
+	tx, err := r.regions.DB(ctx).BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	clickedAt := aggregate.ClickedAt.UTC()
+	query := `
+		INSERT INTO click_aggregates (url_id, bucket, clicks)
+		VALUES ($1, $2, 1)
+		ON CONFLICT (url_id, bucket) DO UPDATE SET clicks = click_aggregates.clicks + 1`
+	if _, err := tx.ExecContext(ctx, query, aggregate.URLID, clickedAt.Truncate(time.Hour)); err != nil {
+		return fmt.Errorf("failed to record click aggregate: %w", err)
+	}
+
+	// Values past the daily cap are counted as "other", bounding each link's rows per day
+	query = `
+		INSERT INTO click_dimension_aggregates (url_id, day, dimension, value, clicks)
+		SELECT $1, $2, $3,
+		       CASE WHEN EXISTS (
+		                SELECT 1 FROM click_dimension_aggregates
+		                WHERE url_id = $1 AND day = $2 AND dimension = $3 AND value = $4
+		            ) OR (
+		                SELECT COUNT(*) FROM click_dimension_aggregates
+		                WHERE url_id = $1 AND day = $2 AND dimension = $3
+		            ) < $5
+		            THEN $4 ELSE $6 END,
+		       1
+		ON CONFLICT (url_id, day, dimension, value) DO UPDATE SET clicks = click_dimension_aggregates.clicks + 1`
+
+	dimensions := map[string]string{
+		models.AggregateDimensionCountry:  aggregate.Country,
+		models.AggregateDimensionReferrer: aggregate.Referrer,
+		models.AggregateDimensionBrowser:  aggregate.Client.Browser,
+		models.AggregateDimensionDevice:   aggregate.Client.Device,
+		models.AggregateDimensionOS:       aggregate.Client.OS,
+		models.AggregateDimensionChannel:  aggregate.Channel,
+	}
+	for dimension, value := range dimensions {
+		_, err := tx.ExecContext(ctx, query,
+			aggregate.URLID, clickedAt.Format("2006-01-02"), dimension, value,
+			models.MaxAggregateValuesPerDay, models.AggregateOtherValue,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record %s aggregate: %w", dimension, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit click aggregate: %w", err)
+	}
+	return nil
+}
+
+// GetAggregateAnalytics builds analytics from the click aggregates. Day boundaries
+// ("today", "this week") are computed in loc; top countries and referrers cover the
+// last days UTC days. Unique clicks are not tracked here.
+func (r *urlRepository) GetAggregateAnalytics(ctx context.Context, urlID int, days int, loc *time.Location) (*models.URLAnalytics, error) {
+	analytics := &models.URLAnalytics{
+		TopCountries:    []models.CountryStats{},
+		TopReferrers:    []models.ReferrerStats{},
+		TopBrowsers:     []models.BrowserStats{},
+		TopDevices:      []models.DeviceStats{},
+		TopOS:           []models.OSStats{},
+		ClicksByChannel: []models.ChannelStats{},
+		Timezone:        loc.String(),
+	}
+
+	now := time.Now().In(loc)
+	startOfToday := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	startOfWeek := startOfToday.AddDate(0, 0, -7)
+
+	query := `
+		SELECT COALESCE(SUM(clicks), 0),
+		       COALESCE(SUM(clicks) FILTER (WHERE bucket >= $2), 0),
+		       COALESCE(SUM(clicks) FILTER (WHERE bucket >= $3), 0)
+		FROM click_aggregates
+		WHERE url_id = $1`
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query, urlID, startOfToday, startOfWeek).Scan(
+		&analytics.TotalClicks, &analytics.ClicksToday, &analytics.ClicksThisWeek,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get click aggregates: %w", err)
+	}
+
+	if days <= 0 {
+		days = 30
+	}
+	since := time.Now().UTC().AddDate(0, 0, -days).Format("2006-01-02")
+	query = `
+		SELECT value, SUM(clicks) AS clicks
+		FROM click_dimension_aggregates
+		WHERE url_id = $1 AND dimension = $2 AND day >= $3
+		GROUP BY value
+		ORDER BY clicks DESC
+		LIMIT 10`
+
+	dimensions := []string{
+		models.AggregateDimensionCountry, models.AggregateDimensionReferrer,
+		models.AggregateDimensionBrowser, models.AggregateDimensionDevice, models.AggregateDimensionOS,
+		models.AggregateDimensionChannel,
+	}
+	for _, dimension := range dimensions {
+		rows, err := r.regions.DB(ctx).QueryContext(ctx, query, urlID, dimension, since)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s aggregates: %w", dimension, err)
+		}
+		for rows.Next() {
+			var value string
+			var clicks int
+			if err := rows.Scan(&value, &clicks); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan %s aggregates: %w", dimension, err)
+			}
+			analytics.AddDimensionStats(dimension, value, clicks)
+		}
+		rows.Close()
+	}
+
+	if err := r.addBlockedClickStats(ctx, urlID, analytics); err != nil {
+		return nil, err
+	}
+
+	return analytics, nil
+}
+
+// GetHourlyClicks counts a URL's click events per UTC hour since the given time
+func (r *urlRepository) GetHourlyClicks(ctx context.Context, urlID int, since time.Time) ([]models.ClickCount, error) {
+	query := `
+		SELECT date_trunc('hour', clicked_at AT TIME ZONE 'UTC') AS hour, SUM(sample_weight)
+		FROM click_events
+		WHERE url_id = $1 AND clicked_at >= $2
+		GROUP BY hour
+		ORDER BY hour`
+
+	return r.queryClickCounts(ctx, query, urlID, since)
+}
+
+// GetAggregateHourlyClicks reads a URL's hourly click aggregates since the given time
+func (r *urlRepository) GetAggregateHourlyClicks(ctx context.Context, urlID int, since time.Time) ([]models.ClickCount, error) {
+	query := `
+		SELECT bucket AT TIME ZONE 'UTC', clicks
+		FROM click_aggregates
+		WHERE url_id = $1 AND bucket >= $2
+		ORDER BY bucket`
+
+	return r.queryClickCounts(ctx, query, urlID, since)
+}
+
+// queryClickCounts runs a query selecting UTC hours and their clicks
+func (r *urlRepository) queryClickCounts(ctx context.Context, query string, urlID int, since time.Time) ([]models.ClickCount, error) {
+	// Include the hour the window starts in, which may begin before since
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, urlID, since.Truncate(time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hourly clicks: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []models.ClickCount{}
+	for rows.Next() {
+		var count models.ClickCount
+		if err := rows.Scan(&count.Time, &count.Clicks); err != nil {
+			return nil, fmt.Errorf("failed to scan hourly clicks: %w", err)
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
+
+// GetAnalyticsByUser retrieves URL analytics for a specific user
+func (r *urlRepository) GetAnalyticsByUser(ctx context.Context, urlID int, userID int, days int, loc *time.Location) (*models.URLAnalytics, error) {
+	// First check if the URL belongs to the user
+	ownershipQuery := `SELECT COUNT(*) FROM urls WHERE id = $1 AND user_id = $2`
+	var count int
+	err := r.regions.DB(ctx).QueryRowContext(ctx, ownershipQuery, urlID, userID).Scan(&count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check ownership: %w", err)
+	}
+
+	if count == 0 {
+		return nil, fmt.Errorf("URL %w", ErrNotFound)
+	}
+
+	// Use the existing GetAnalytics method
+	return r.GetAnalytics(ctx, urlID, days, loc)
 }
 
 // CheckOwnership checks if a URL belongs to a specific user
 func (r *urlRepository) CheckOwnership(ctx context.Context, shortCode string, userID int) (bool, error) {
 	query := `SELECT COUNT(*) FROM urls WHERE short_code = $1 AND user_id = $2`
 	var count int
-	err := r.db.QueryRowContext(ctx, query, shortCode, userID).Scan(&count)
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query, shortCode, userID).Scan(&count)
 	if err != nil {
 		return false, fmt.Errorf("failed to check ownership: %w", err)
 	}
 
 	return count > 0, nil
 }
+
+// GetOwnedShortCodes returns the subset of short codes owned by a user
+func (r *urlRepository) GetOwnedShortCodes(ctx context.Context, userID int, shortCodes []string) ([]string, error) {
+	query := `SELECT short_code FROM urls WHERE user_id = $1 AND short_code = ANY($2)`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, userID, pq.Array(shortCodes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get owned short codes: %w", err)
+	}
+	defer rows.Close()
+
+	return scanShortCodes(rows)
+}
+
+// GetShortCodesByCampaign returns the short codes of a user's links whose destination
+// carries the given utm_campaign, oldest first
+func (r *urlRepository) GetShortCodesByCampaign(ctx context.Context, userID int, campaign string, limit int) ([]string, error) {
+	query := `
+		SELECT short_code FROM urls
+		WHERE user_id = $1 AND (original_url ~ $2 OR utm_params->>'utm_campaign' = $4)
+		ORDER BY created_at, id
+		LIMIT $3`
+
+	pattern := `[?&]utm_campaign=` + regexp.QuoteMeta(url.QueryEscape(campaign)) + `(&|#|$)`
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, userID, pattern, limit, campaign)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaign short codes: %w", err)
+	}
+	defer rows.Close()
+
+	return scanShortCodes(rows)
+}
+
+// GetCampaignStats counts a user's links and their clicks by the UTM campaign,
+// source and medium stored with them, most clicked first
+func (r *urlRepository) GetCampaignStats(ctx context.Context, userID int) ([]models.CampaignStats, error) {
+	query := `
+		SELECT utm_params->>'utm_campaign' AS campaign,
+		       COALESCE(utm_params->>'utm_source', '') AS source,
+		       COALESCE(utm_params->>'utm_medium', '') AS medium,
+		       COUNT(*) AS links, COALESCE(SUM(click_count), 0) AS clicks
+		FROM urls
+		WHERE user_id = $1 AND COALESCE(utm_params->>'utm_campaign', '') <> ''
+		GROUP BY 1, 2, 3
+		ORDER BY clicks DESC, campaign, source, medium`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaign stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := []models.CampaignStats{}
+	for rows.Next() {
+		var campaign models.CampaignStats
+		if err := rows.Scan(&campaign.Campaign, &campaign.Source, &campaign.Medium, &campaign.Links, &campaign.Clicks); err != nil {
+			return nil, fmt.Errorf("failed to scan campaign stats: %w", err)
+		}
+		stats = append(stats, campaign)
+	}
+	return stats, rows.Err()
+}
+
+// GetDashboardTotals counts a user's links and their clicks, overall and from
+// click events over the last 7 and 30 days
+func (r *urlRepository) GetDashboardTotals(ctx context.Context, userID int, now time.Time) (*models.Dashboard, error) {
+	query := `
+		SELECT COALESCE(SUM(e.sample_weight) FILTER (WHERE e.clicked_at >= $2), 0), COALESCE(SUM(e.sample_weight), 0)
+		FROM click_events e
+		JOIN urls u ON u.id = e.url_id
+		WHERE u.user_id = $1 AND e.clicked_at >= $3`
+
+	return r.getDashboardTotals(ctx, query, userID, now)
+}
+
+// GetAggregateDashboardTotals counts a user's links and their clicks, overall
+// and from the hourly click aggregates over the last 7 and 30 days
+func (r *urlRepository) GetAggregateDashboardTotals(ctx context.Context, userID int, now time.Time) (*models.Dashboard, error) {
+	query := `
+		SELECT COALESCE(SUM(a.clicks) FILTER (WHERE a.bucket >= $2), 0), COALESCE(SUM(a.clicks), 0)
+		FROM click_aggregates a
+		JOIN urls u ON u.id = a.url_id
+		WHERE u.user_id = $1 AND a.bucket >= $3`
+
+	return r.getDashboardTotals(ctx, query, userID, now)
+}
+
+// getDashboardTotals counts a user's links, then their recent clicks with a
+// query selecting the last 7 and 30 days' clicks
+func (r *urlRepository) getDashboardTotals(ctx context.Context, clicksQuery string, userID int, now time.Time) (*models.Dashboard, error) {
+	dashboard := &models.Dashboard{TopLinks: []models.URL{}, RecentActivity: []models.URL{}}
+
+	query := `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE is_active), COALESCE(SUM(click_count), 0)
+		FROM urls
+		WHERE user_id = $1`
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query, userID).Scan(
+		&dashboard.TotalLinks, &dashboard.ActiveLinks, &dashboard.TotalClicks,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count links: %w", err)
+	}
+
+	err = r.regions.DB(ctx).QueryRowContext(ctx, clicksQuery, userID, now.AddDate(0, 0, -7), now.AddDate(0, 0, -30)).Scan(
+		&dashboard.ClicksLast7Days, &dashboard.ClicksLast30Days,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count recent clicks: %w", err)
+	}
+
+	return dashboard, nil
+}
+
+// scanShortCodes collects a single short_code column
+func scanShortCodes(rows *sql.Rows) ([]string, error) {
+	shortCodes := []string{}
+	for rows.Next() {
+		var shortCode string
+		if err := rows.Scan(&shortCode); err != nil {
+			return nil, fmt.Errorf("failed to scan short code: %w", err)
+		}
+		shortCodes = append(shortCodes, shortCode)
+	}
+	return shortCodes, rows.Err()
+}
+
+// ExpireInactive expires active links whose inactivity policy has lapsed, measuring
+// inactivity from the last click (or creation when never clicked). It returns the
+// short codes of the expired links.
+func (r *urlRepository) ExpireInactive(ctx context.Context, now time.Time) ([]string, error) {
+	query := `
+		UPDATE urls
+		SET expires_at = $1, updated_at = $1
+		WHERE inactivity_expiry_days > 0
+		  AND is_active = TRUE
+		  AND (expires_at IS NULL OR expires_at > $1)
+		  AND COALESCE(last_clicked_at, created_at) < $1 - make_interval(days => inactivity_expiry_days)
+		RETURNING short_code`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire inactive URLs: %w", err)
+	}
+	defer rows.Close()
+
+	var shortCodes []string
+	for rows.Next() {
+		var shortCode string
+		if err := rows.Scan(&shortCode); err != nil {
+			return nil, fmt.Errorf("failed to scan expired URL: %w", err)
+		}
+		shortCodes = append(shortCodes, shortCode)
+	}
+
+	return shortCodes, nil
+}
+
+// DeactivateExpired deactivates up to limit active links that expired by now
+// and returns them, with only their ID, short code and owner set
+func (r *urlRepository) DeactivateExpired(ctx context.Context, now time.Time, limit int) ([]models.URL, error) {
+	query := `
+		UPDATE urls
+		SET is_active = FALSE, expiry_deactivated = TRUE, click_limit_deactivated = FALSE, updated_at = $1
+		WHERE id IN (
+			SELECT id FROM urls
+			WHERE is_active AND expires_at <= $1
+			ORDER BY expires_at
+			LIMIT $2
+		)
+		RETURNING id, short_code, user_id`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deactivate expired URLs: %w", err)
+	}
+	defer rows.Close()
+
+	urls := []models.URL{}
+	for rows.Next() {
+		var url models.URL
+		if err := rows.Scan(&url.ID, &url.ShortCode, &url.UserID); err != nil {
+			return nil, fmt.Errorf("failed to scan deactivated URL: %w", err)
+		}
+		urls = append(urls, url)
+	}
+	return urls, rows.Err()
+}
+
+// DeleteClickEventsBefore deletes up to limit raw click and blocked click
+// events recorded before a time, across all links, and returns how many it
+// deleted. Click counts and rollups are kept.
+func (r *urlRepository) DeleteClickEventsBefore(ctx context.Context, before time.Time, limit int) (int64, error) {
+	var deleted int64
+	for _, table := range []struct{ name, column string }{
+		{"click_events", "clicked_at"},
+		{"blocked_clicks", "blocked_at"},
+	} {
+		if deleted >= int64(limit) {
+			break
+		}
+
+		query := fmt.Sprintf(`
+			DELETE FROM %[1]s
+			WHERE id IN (SELECT id FROM %[1]s WHERE %[2]s < $1 LIMIT $2)`, table.name, table.column)
+
+		result, err := r.regions.DB(ctx).ExecContext(ctx, query, before, int64(limit)-deleted)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete old %s: %w", table.name, err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return deleted, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		deleted += rowsAffected
+	}
+	return deleted, nil
+}
+
+// ExtendExpiration moves a link's expiration date and records the extension. The update
+// only applies if the expiration has not changed since it was read.
+func (r *urlRepository) ExtendExpiration(ctx context.Context, extension *models.ExpirationExtension) (*models.ExpirationExtension, error) {
+	tx, err := r.regions.DB(ctx).BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE urls SET expires_at = $2, updated_at = $3, is_active = is_active OR expiry_deactivated, expiry_deactivated = FALSE
+		 WHERE id = $1 AND expires_at = $4`,
+		extension.URLID, extension.NewExpiresAt, extension.CreatedAt, extension.PreviousExpiresAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extend URL expiration: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, fmt.Errorf("URL expiration changed concurrently")
+	}
+
+	query := `
+		INSERT INTO url_expiration_extensions (url_id, user_id, previous_expires_at, new_expires_at, duration_seconds, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+
+	err = tx.QueryRowContext(ctx, query,
+		extension.URLID, extension.UserID, extension.PreviousExpiresAt, extension.NewExpiresAt,
+		extension.DurationSeconds, extension.CreatedAt,
+	).Scan(&extension.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record expiration extension: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit expiration extension: %w", err)
+	}
+
+	return extension, nil
+}
+
+// GetExpirationExtensions retrieves a link's expiration extensions, most recent first
+func (r *urlRepository) GetExpirationExtensions(ctx context.Context, urlID int) ([]models.ExpirationExtension, error) {
+	query := `
+		SELECT id, url_id, user_id, previous_expires_at, new_expires_at, duration_seconds, created_at
+		FROM url_expiration_extensions
+		WHERE url_id = $1
+		ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, urlID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expiration extensions: %w", err)
+	}
+	defer rows.Close()
+
+	extensions := []models.ExpirationExtension{}
+	for rows.Next() {
+		var extension models.ExpirationExtension
+		err := rows.Scan(
+			&extension.ID, &extension.URLID, &extension.UserID, &extension.PreviousExpiresAt,
+			&extension.NewExpiresAt, &extension.DurationSeconds, &extension.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan expiration extension: %w", err)
+		}
+		extensions = append(extensions, extension)
+	}
+
+	return extensions, nil
+}
+
+// CreateDestinationChange records a change of a link's destination
+func (r *urlRepository) CreateDestinationChange(ctx context.Context, change *models.DestinationChange) (*models.DestinationChange, error) {
+	query := `
+		INSERT INTO url_destination_changes (url_id, user_id, guest_edit_link_id, previous_url, new_url, held_for_review, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`
+
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query,
+		change.URLID, change.UserID, change.GuestEditLinkID, change.PreviousURL, change.NewURL, change.HeldForReview, change.CreatedAt,
+	).Scan(&change.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create destination change: %w", err)
+	}
+
+	return change, nil
+}
+
+// GetDestinationChanges retrieves a link's destination changes, most recent first
+func (r *urlRepository) GetDestinationChanges(ctx context.Context, urlID int) ([]models.DestinationChange, error) {
+	query := `
+		SELECT id, url_id, user_id, guest_edit_link_id, previous_url, new_url, held_for_review, created_at
+		FROM url_destination_changes
+		WHERE url_id = $1
+		ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, urlID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get destination changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := []models.DestinationChange{}
+	for rows.Next() {
+		var change models.DestinationChange
+		err := rows.Scan(
+			&change.ID, &change.URLID, &change.UserID, &change.GuestEditLinkID, &change.PreviousURL,
+			&change.NewURL, &change.HeldForReview, &change.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan destination change: %w", err)
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// GetByShortCodes retrieves the links with any of the given short codes
+func (r *urlRepository) GetByShortCodes(ctx context.Context, shortCodes []string) ([]models.URL, error) {
+	query := `SELECT ` + urlColumns + ` FROM urls WHERE short_code = ANY($1) ORDER BY id`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, pq.Array(shortCodes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URLs by short code: %w", err)
+	}
+	defer rows.Close()
+
+	urls := []models.URL{}
+	for rows.Next() {
+		var url models.URL
+		if err := scanURL(rows, &url); err != nil {
+			return nil, fmt.Errorf("failed to scan URL: %w", err)
+		}
+		urls = append(urls, url)
+	}
+
+	return urls, rows.Err()
+}
+
+// UpdateShortCode moves a link to a new short code
+func (r *urlRepository) UpdateShortCode(ctx context.Context, id int, shortCode string) error {
+	query := "UPDATE urls SET short_code = $2, updated_at = $3 WHERE id = $1"
+
+	result, err := r.regions.DB(ctx).ExecContext(ctx, query, id, shortCode, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to update short code: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("URL %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// CreateShareToken creates a share token for a link
+func (r *urlRepository) CreateShareToken(ctx context.Context, token *models.ShareToken) (*models.ShareToken, error) {
+	query := `
+		INSERT INTO link_share_tokens (url_id, name, token_hash, token_prefix, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query,
+		token.URLID, token.Name, token.TokenHash, token.TokenPrefix, token.CreatedAt,
+	).Scan(&token.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create share token: %w", err)
+	}
+
+	return token, nil
+}
+
+// GetShareTokens retrieves a link's share tokens, including revoked ones, newest first
+func (r *urlRepository) GetShareTokens(ctx context.Context, urlID int) ([]models.ShareToken, error) {
+	query := `
+		SELECT id, url_id, name, token_hash, token_prefix, access_count, last_used_at, revoked_at, created_at
+		FROM link_share_tokens
+		WHERE url_id = $1
+		ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, urlID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get share tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []models.ShareToken{}
+	for rows.Next() {
+		var token models.ShareToken
+		err := rows.Scan(
+			&token.ID, &token.URLID, &token.Name, &token.TokenHash, &token.TokenPrefix,
+			&token.AccessCount, &token.LastUsedAt, &token.RevokedAt, &token.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan share token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, nil
+}
+
+// RevokeShareToken revokes one of a link's share tokens
+func (r *urlRepository) RevokeShareToken(ctx context.Context, urlID, id int) error {
+	query := `UPDATE link_share_tokens SET revoked_at = $3 WHERE id = $1 AND url_id = $2 AND revoked_at IS NULL`
+
+	result, err := r.regions.DB(ctx).ExecContext(ctx, query, id, urlID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to revoke share token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("share token %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// RevokeShareTokens revokes all of a link's share tokens, returning how many were revoked
+func (r *urlRepository) RevokeShareTokens(ctx context.Context, urlID int) (int, error) {
+	query := `UPDATE link_share_tokens SET revoked_at = $2 WHERE url_id = $1 AND revoked_at IS NULL`
+
+	result, err := r.regions.DB(ctx).ExecContext(ctx, query, urlID, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke share tokens: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return int(rowsAffected), nil
+}
+
+// UseShareToken counts a visit through a share token, reporting whether the
+// token is a live token of the link
+func (r *urlRepository) UseShareToken(ctx context.Context, urlID int, tokenHash string) (bool, error) {
+	query := `
+		UPDATE link_share_tokens
+		SET access_count = access_count + 1, last_used_at = $3
+		WHERE url_id = $1 AND token_hash = $2 AND revoked_at IS NULL`
+
+	result, err := r.regions.DB(ctx).ExecContext(ctx, query, urlID, tokenHash, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to use share token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// CreateGuestEditLink creates a guest edit link for a link
+func (r *urlRepository) CreateGuestEditLink(ctx context.Context, link *models.GuestEditLink) (*models.GuestEditLink, error) {
+	query := `
+		INSERT INTO guest_edit_links (url_id, name, token_hash, token_prefix, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query,
+		link.URLID, link.Name, link.TokenHash, link.TokenPrefix, link.ExpiresAt, link.CreatedAt,
+	).Scan(&link.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create guest edit link: %w", err)
+	}
+
+	return link, nil
+}
+
+// GetGuestEditLinks retrieves a link's guest edit links, including used,
+// revoked and expired ones, newest first
+func (r *urlRepository) GetGuestEditLinks(ctx context.Context, urlID int) ([]models.GuestEditLink, error) {
+	query := `
+		SELECT id, url_id, name, token_hash, token_prefix, expires_at, used_at, revoked_at, created_at
+		FROM guest_edit_links
+		WHERE url_id = $1
+		ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, urlID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get guest edit links: %w", err)
+	}
+	defer rows.Close()
+
+	links := []models.GuestEditLink{}
+	for rows.Next() {
+		var link models.GuestEditLink
+		err := rows.Scan(
+			&link.ID, &link.URLID, &link.Name, &link.TokenHash, &link.TokenPrefix,
+			&link.ExpiresAt, &link.UsedAt, &link.RevokedAt, &link.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan guest edit link: %w", err)
+		}
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
+
+// GetGuestEditLinkByHash retrieves the guest edit link with the given token hash
+func (r *urlRepository) GetGuestEditLinkByHash(ctx context.Context, tokenHash string) (*models.GuestEditLink, error) {
+	query := `
+		SELECT id, url_id, name, token_hash, token_prefix, expires_at, used_at, revoked_at, created_at
+		FROM guest_edit_links
+		WHERE token_hash = $1`
+
+	var link models.GuestEditLink
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query, tokenHash).Scan(
+		&link.ID, &link.URLID, &link.Name, &link.TokenHash, &link.TokenPrefix,
+		&link.ExpiresAt, &link.UsedAt, &link.RevokedAt, &link.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("guest edit link %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get guest edit link: %w", err)
+	}
+
+	return &link, nil
+}
+
+// RevokeGuestEditLink revokes one of a link's unused guest edit links
+func (r *urlRepository) RevokeGuestEditLink(ctx context.Context, urlID, id int) error {
+	query := `UPDATE guest_edit_links SET revoked_at = $3 WHERE id = $1 AND url_id = $2 AND revoked_at IS NULL AND used_at IS NULL`
+
+	result, err := r.regions.DB(ctx).ExecContext(ctx, query, id, urlID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to revoke guest edit link: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("guest edit link %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// UseGuestEditLink marks a guest edit link used, reporting whether it was
+// still usable. Of concurrent edits through one link only one succeeds.
+func (r *urlRepository) UseGuestEditLink(ctx context.Context, id int, now time.Time) (bool, error) {
+	query := `
+		UPDATE guest_edit_links
+		SET used_at = $2
+		WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL AND expires_at > $2`
+
+	result, err := r.regions.DB(ctx).ExecContext(ctx, query, id, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to use guest edit link: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// ClaimRedirect atomically takes one of a click-limited link's remaining redirects,
+// deactivating the link when it takes the last one. The row lock makes concurrent
+// claims queue, so a link never serves more redirects than its limit. It reports
+// whether a redirect was left and whether the link is now deactivated.
+func (r *urlRepository) ClaimRedirect(ctx context.Context, id int) (bool, bool, error) {
+	query := `
+		UPDATE urls
+		SET redirect_count = redirect_count + 1,
+		    is_active = redirect_count + 1 < max_clicks,
+		    click_limit_deactivated = redirect_count + 1 >= max_clicks,
+		    expiry_deactivated = FALSE,
+		    updated_at = $2
+		WHERE id = $1 AND is_active AND max_clicks > 0 AND redirect_count < max_clicks
+		RETURNING is_active`
+
+	var active bool
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query, id, time.Now()).Scan(&active)
+	if err == sql.ErrNoRows {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("failed to claim redirect: %w", err)
+	}
+	return true, !active, nil
+}
+
+// SetDestinations replaces a rotator link's destinations, in rotation order.
+// Destinations kept from the previous list keep their click counts.
+func (r *urlRepository) SetDestinations(ctx context.Context, urlID int, destinations []string) error {
+	tx, err := r.regions.DB(ctx).BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM link_destinations WHERE url_id = $1 AND NOT (destination_url = ANY($2))`,
+		urlID, pq.Array(destinations),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove link destinations: %w", err)
+	}
+
+	query := `
+		INSERT INTO link_destinations (url_id, position, destination_url, created_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (url_id, destination_url) DO UPDATE SET position = EXCLUDED.position`
+	for position, destination := range destinations {
+		if _, err := tx.ExecContext(ctx, query, urlID, position, destination, time.Now()); err != nil {
+			return fmt.Errorf("failed to store link destination: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit link destinations: %w", err)
+	}
+
+	return nil
+}
+
+// GetDestinations retrieves a rotator link's destinations in rotation order
+func (r *urlRepository) GetDestinations(ctx context.Context, urlID int) ([]models.LinkDestination, error) {
+	query := `
+		SELECT id, url_id, position, destination_url, click_count, last_clicked_at, created_at
+		FROM link_destinations
+		WHERE url_id = $1
+		ORDER BY position`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, urlID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get link destinations: %w", err)
+	}
+	defer rows.Close()
+
+	destinations := []models.LinkDestination{}
+	for rows.Next() {
+		var destination models.LinkDestination
+		err := rows.Scan(
+			&destination.ID, &destination.URLID, &destination.Position, &destination.URL,
+			&destination.ClickCount, &destination.LastClickedAt, &destination.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan link destination: %w", err)
+		}
+		destinations = append(destinations, destination)
+	}
+
+	return destinations, nil
+}
+
+// RecordDestinationClick counts a visit sent to one of a rotator link's destinations
+func (r *urlRepository) RecordDestinationClick(ctx context.Context, id int) error {
+	query := `UPDATE link_destinations SET click_count = click_count + 1, last_clicked_at = $2 WHERE id = $1`
+	if _, err := r.regions.DB(ctx).ExecContext(ctx, query, id, time.Now()); err != nil {
+		return fmt.Errorf("failed to record destination click: %w", err)
+	}
+	return nil
+}
+
+// SetVariants replaces a split test link's variants, in listed order. Variants
+// kept by name keep their click and conversion counts.
+func (r *urlRepository) SetVariants(ctx context.Context, urlID int, variants []models.SplitVariant) error {
+	tx, err := r.regions.DB(ctx).BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	names := make([]string, len(variants))
+	for i, variant := range variants {
+		names[i] = variant.Name
+	}
+	_, err = tx.ExecContext(ctx,
+		`DELETE FROM url_variants WHERE url_id = $1 AND NOT (name = ANY($2))`,
+		urlID, pq.Array(names),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove URL variants: %w", err)
+	}
+
+	query := `
+		INSERT INTO url_variants (url_id, position, name, destination_url, weight, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (url_id, name) DO UPDATE
+		SET position = EXCLUDED.position, destination_url = EXCLUDED.destination_url, weight = EXCLUDED.weight`
+	for position, variant := range variants {
+		if _, err := tx.ExecContext(ctx, query, urlID, position, variant.Name, variant.URL, variant.Weight, time.Now()); err != nil {
+			return fmt.Errorf("failed to store URL variant: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit URL variants: %w", err)
+	}
+
+	return nil
+}
+
+// GetVariants retrieves a split test link's variants, the control first
+func (r *urlRepository) GetVariants(ctx context.Context, urlID int) ([]models.URLVariant, error) {
+	query := `
+		SELECT id, url_id, position, name, destination_url, weight, click_count, conversion_count,
+		       last_clicked_at, created_at
+		FROM url_variants
+		WHERE url_id = $1
+		ORDER BY position`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, urlID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URL variants: %w", err)
+	}
+	defer rows.Close()
+
+	variants := []models.URLVariant{}
+	for rows.Next() {
+		var variant models.URLVariant
+		err := rows.Scan(
+			&variant.ID, &variant.URLID, &variant.Position, &variant.Name, &variant.URL, &variant.Weight,
+			&variant.ClickCount, &variant.ConversionCount, &variant.LastClickedAt, &variant.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan URL variant: %w", err)
+		}
+		variants = append(variants, variant)
+	}
+
+	return variants, rows.Err()
+}
+
+// RecordVariantClicks adds visits sent to split test links' variants, by
+// variant ID, to their click counts in a single statement
+func (r *urlRepository) RecordVariantClicks(ctx context.Context, clicks map[int]int64, clickedAt time.Time) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+	ids := make([]int64, 0, len(clicks))
+	counts := make([]int64, 0, len(clicks))
+	for id, n := range clicks {
+		ids = append(ids, int64(id))
+		counts = append(counts, n)
+	}
+
+	query := `
+		UPDATE url_variants v
+		SET click_count = v.click_count + c.clicks, last_clicked_at = $3
+		FROM unnest($1::int[], $2::bigint[]) AS c(id, clicks)
+		WHERE v.id = c.id`
+	if _, err := r.regions.DB(ctx).ExecContext(ctx, query, pq.Array(ids), pq.Array(counts), clickedAt); err != nil {
+		return fmt.Errorf("failed to record variant clicks: %w", err)
+	}
+	return nil
+}
+
+// RecordVariantConversion counts a conversion of a split test link's variant,
+// matching its name case-insensitively, and returns the updated variant
+func (r *urlRepository) RecordVariantConversion(ctx context.Context, urlID int, name string) (*models.URLVariant, error) {
+	query := `
+		UPDATE url_variants SET conversion_count = conversion_count + 1
+		WHERE url_id = $1 AND LOWER(name) = LOWER($2)
+		RETURNING id, url_id, position, name, destination_url, weight, click_count, conversion_count,
+		          last_clicked_at, created_at`
+
+	var variant models.URLVariant
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query, urlID, name).Scan(
+		&variant.ID, &variant.URLID, &variant.Position, &variant.Name, &variant.URL, &variant.Weight,
+		&variant.ClickCount, &variant.ConversionCount, &variant.LastClickedAt, &variant.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("URL variant %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record variant conversion: %w", err)
+	}
+	return &variant, nil
+}
+
+// GetLinksToScan returns active links not scanned since scannedBefore, least
+// recently scanned first, with their rotation destinations and split test variants
+func (r *urlRepository) GetLinksToScan(ctx context.Context, scannedBefore time.Time, limit int) ([]models.LinkScanTarget, error) {
+	query := `
+		SELECT u.id, u.short_code, u.original_url,
+		       COALESCE(array_agg(d.destination_url) FILTER (WHERE d.id IS NOT NULL), '{}'),
+		       CASE WHEN u.canary_status = $3 THEN u.canary_url ELSE '' END,
+		       ARRAY(SELECT v.destination_url FROM url_variants v WHERE v.url_id = u.id ORDER BY v.position),
+		       u.device_rules
+		FROM urls u
+		LEFT JOIN link_destinations d ON d.url_id = u.id
+		WHERE u.is_active = TRUE AND (u.scanned_at IS NULL OR u.scanned_at < $1)
+		GROUP BY u.id
+		ORDER BY u.scanned_at NULLS FIRST, u.id
+		LIMIT $2`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, scannedBefore, limit, models.CanaryStatusRollingOut)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get links to scan: %w", err)
+	}
+	defer rows.Close()
+
+	var targets []models.LinkScanTarget
+	for rows.Next() {
+		var target models.LinkScanTarget
+		var destination, canary string
+		var rotation, variants []string
+		var deviceRules models.DeviceRules
+		if err := rows.Scan(&target.ID, &target.ShortCode, &destination, pq.Array(&rotation), &canary, pq.Array(&variants), &deviceRules); err != nil {
+			return nil, fmt.Errorf("failed to scan link to scan: %w", err)
+		}
+		target.Destinations = append([]string{destination}, rotation...)
+		if canary != "" {
+			target.Destinations = append(target.Destinations, canary)
+		}
+		target.Destinations = append(target.Destinations, variants...)
+		target.Destinations = append(target.Destinations, deviceRules.WebDestinations()...)
+		targets = append(targets, target)
+	}
+
+	return targets, rows.Err()
+}
+
+// MarkScanned records when links' destinations were last scanned
+func (r *urlRepository) MarkScanned(ctx context.Context, ids []int, scannedAt time.Time) error {
+	_, err := r.regions.DB(ctx).ExecContext(ctx, `UPDATE urls SET scanned_at = $2 WHERE id = ANY($1)`, pq.Array(ids), scannedAt)
+	if err != nil {
+		return fmt.Errorf("failed to mark links scanned: %w", err)
+	}
+	return nil
+}
+
+// GetLinksWithStaleMetadata returns active links whose destination's metadata
+// was never fetched or last checked before checkedBefore, least recently checked first
+func (r *urlRepository) GetLinksWithStaleMetadata(ctx context.Context, checkedBefore time.Time, limit int) ([]models.MetadataTarget, error) {
+	query := `
+		SELECT id, short_code, original_url
+		FROM urls
+		WHERE is_active = TRUE AND (metadata_checked_at IS NULL OR metadata_checked_at < $1)
+		ORDER BY metadata_checked_at NULLS FIRST, id
+		LIMIT $2`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, checkedBefore, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get links with stale metadata: %w", err)
+	}
+	defer rows.Close()
+
+	var targets []models.MetadataTarget
+	for rows.Next() {
+		var target models.MetadataTarget
+		if err := rows.Scan(&target.ID, &target.ShortCode, &target.OriginalURL); err != nil {
+			return nil, fmt.Errorf("failed to scan link with stale metadata: %w", err)
+		}
+		targets = append(targets, target)
+	}
+
+	return targets, rows.Err()
+}
+
+// SetMetadata stores the metadata fetched from a link's destination, unless
+// the destination changed in the meantime. It reports whether it was stored.
+func (r *urlRepository) SetMetadata(ctx context.Context, id int, destination string, metadata *models.LinkMetadata) (bool, error) {
+	query := `
+		UPDATE urls
+		SET page_title = $3, page_description = $4, favicon_url = $5,
+		    metadata_fetched_at = $6, metadata_checked_at = $6
+		WHERE id = $1 AND original_url = $2`
+
+	result, err := r.regions.DB(ctx).ExecContext(ctx, query, id, destination,
+		metadata.PageTitle, metadata.PageDescription, metadata.FaviconURL, metadata.MetadataFetchedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to set link metadata: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// MarkMetadataChecked records a failed metadata fetch, keeping the metadata
+// fetched before
+func (r *urlRepository) MarkMetadataChecked(ctx context.Context, ids []int, checkedAt time.Time) error {
+	_, err := r.regions.DB(ctx).ExecContext(ctx, `UPDATE urls SET metadata_checked_at = $2 WHERE id = ANY($1)`, pq.Array(ids), checkedAt)
+	if err != nil {
+		return fmt.Errorf("failed to mark link metadata checked: %w", err)
+	}
+	return nil
+}
+
+// FlagThreat deactivates an active link whose destination is on a threat list
+// and holds it for review. It reports whether the link was active.
+func (r *urlRepository) FlagThreat(ctx context.Context, id int, threatType string) (bool, error) {
+	query := `
+		UPDATE urls
+		SET is_active = FALSE, needs_review = TRUE, threat_type = $2, updated_at = $3,
+		    expiry_deactivated = FALSE, click_limit_deactivated = FALSE
+		WHERE id = $1 AND is_active = TRUE`
+
+	result, err := r.regions.DB(ctx).ExecContext(ctx, query, id, threatType, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to flag link: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// WarnThreat marks an active link suspicious because its destination is on a
+// lower-severity threat list. Links already flagged for this threat are left
+// alone, so an operator clearing the flag isn't overridden by the next rescan.
+// It reports whether the link was flagged.
+func (r *urlRepository) WarnThreat(ctx context.Context, id int, threatType string) (bool, error) {
+	query := `
+		UPDATE urls
+		SET suspicious = TRUE, threat_type = $2, updated_at = $3
+		WHERE id = $1 AND is_active = TRUE AND threat_type <> $2`
+
+	result, err := r.regions.DB(ctx).ExecContext(ctx, query, id, threatType, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to flag link: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
+
+// SetSuspicious sets or clears a link's suspicious flag
+func (r *urlRepository) SetSuspicious(ctx context.Context, id int, suspicious bool) error {
+	query := `UPDATE urls SET suspicious = $2, updated_at = $3 WHERE id = $1`
+
+	result, err := r.regions.DB(ctx).ExecContext(ctx, query, id, suspicious, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to set link suspicious flag: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("URL %w", ErrNotFound)
+	}
+	return nil
+}
+
+// GetRollingOutCanaries returns the active links with a canary rollout in progress
+func (r *urlRepository) GetRollingOutCanaries(ctx context.Context) ([]models.URL, error) {
+	query := `SELECT ` + urlColumns + `
+		FROM urls
+		WHERE canary_status = $1 AND is_active = TRUE
+		ORDER BY canary_ends_at`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, models.CanaryStatusRollingOut)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get canary rollouts: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []models.URL
+	for rows.Next() {
+		var url models.URL
+		if err := scanURL(rows, &url); err != nil {
+			return nil, fmt.Errorf("failed to scan URL: %w", err)
+		}
+		urls = append(urls, url)
+	}
+	return urls, rows.Err()
+}
+
+// RecordCanaryCheck records a health check of a rollout in progress: a failed
+// one adds to its consecutive failures, in place, and a healthy one resets
+// them. It returns the consecutive failures, or ErrNotFound once the rollout
+// is no longer in progress.
+func (r *urlRepository) RecordCanaryCheck(ctx context.Context, id int, healthy bool) (int, error) {
+	query := `UPDATE urls SET canary_failures = canary_failures + 1 WHERE id = $1 AND canary_status = $2 RETURNING canary_failures`
+	if healthy {
+		query = `UPDATE urls SET canary_failures = 0 WHERE id = $1 AND canary_status = $2 RETURNING canary_failures`
+	}
+
+	var failures int
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query, id, models.CanaryStatusRollingOut).Scan(&failures)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("canary rollout %w", ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to record canary health check: %w", err)
+	}
+	return failures, nil
+}
+
+// FinishCanary ends a rollout in progress. Promoting makes the canary URL the
+// link's destination; rolling back leaves the destination as it was. It
+// reports whether the rollout was still in progress.
+func (r *urlRepository) FinishCanary(ctx context.Context, id int, status string) (bool, error) {
+	query := `
+		UPDATE urls
+		SET canary_status = $2,
+		    original_url = CASE WHEN $2 = $3 THEN canary_url ELSE original_url END,
+		    page_title = CASE WHEN $2 = $3 THEN '' ELSE page_title END,
+		    page_description = CASE WHEN $2 = $3 THEN '' ELSE page_description END,
+		    favicon_url = CASE WHEN $2 = $3 THEN '' ELSE favicon_url END,
+		    metadata_fetched_at = CASE WHEN $2 = $3 THEN NULL ELSE metadata_fetched_at END,
+		    metadata_checked_at = CASE WHEN $2 = $3 THEN NULL ELSE metadata_checked_at END,
+		    updated_at = $5
+		WHERE id = $1 AND canary_status = $4`
+
+	result, err := r.regions.DB(ctx).ExecContext(ctx, query, id, status, models.CanaryStatusPromoted, models.CanaryStatusRollingOut, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to finish canary rollout: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hpower2/url-shortener/database"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/lib/pq"
+)
+
+// UsageReportRepository interface defines the contract for organization usage report database operations
+type UsageReportRepository interface {
+	GetOrganizationsWithoutReport(ctx context.Context, periodStart time.Time) ([]int, error)
+	ComputeUsage(ctx context.Context, orgID int, from, to time.Time, memberIDs []int) (*models.UsageReport, error)
+	Create(ctx context.Context, report *models.UsageReport) (*models.UsageReport, error)
+	GetByOrganization(ctx context.Context, orgID int) ([]models.UsageReport, error)
+	MarkEmailed(ctx context.Context, id int) error
+}
+
+// usageReportRepository implements UsageReportRepository interface
+type usageReportRepository struct {
+	db      *database.DB
+	regions *RegionRouter
+}
+
+// NewUsageReportRepository creates a new usage report repository. Reports live in
+// the home region; link and click usage is read from the context's data region.
+func NewUsageReportRepository(regions *RegionRouter) UsageReportRepository {
+	return &usageReportRepository{db: regions.HomeDB(), regions: regions}
+}
+
+// GetOrganizationsWithoutReport returns the organizations created before the end of
+// the period that have no report for it yet
+func (r *usageReportRepository) GetOrganizationsWithoutReport(ctx context.Context, periodStart time.Time) ([]int, error) {
+	query := `
+		SELECT o.id FROM organizations o
+		WHERE o.created_at < $2
+		  AND NOT EXISTS (
+		      SELECT 1 FROM organization_usage_reports r
+		      WHERE r.organization_id = o.id AND r.period_start = $1
+		  )
+		ORDER BY o.id`
+
+	rows, err := r.db.QueryContext(ctx, query, periodStart, periodStart.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organizations without report: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan organization ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// ComputeUsage counts the links created and clicks served by the organization's members
+// in [from, to), and the storage their data currently uses
+func (r *usageReportRepository) ComputeUsage(ctx context.Context, orgID int, from, to time.Time, memberIDs []int) (*models.UsageReport, error) {
+	report := &models.UsageReport{
+		OrganizationID: orgID,
+		PeriodStart:    from,
+	}
+	members := pq.Array(memberIDs)
+
+	query := `SELECT COUNT(*) FROM urls WHERE user_id = ANY($1) AND created_at >= $2 AND created_at < $3`
+	if err := r.regions.DB(ctx).QueryRowContext(ctx, query, members, from, to).Scan(&report.LinksCreated); err != nil {
+		return nil, fmt.Errorf("failed to count links created: %w", err)
+	}
+
+	// Clicks are either stored as events or, in aggregate-only mode, as hourly counters
+	query = `
+		SELECT
+		    (SELECT COALESCE(SUM(e.sample_weight), 0) FROM click_events e JOIN urls u ON u.id = e.url_id
+		     WHERE u.user_id = ANY($1) AND e.clicked_at >= $2 AND e.clicked_at < $3)
+		  + (SELECT COALESCE(SUM(a.clicks), 0) FROM click_aggregates a JOIN urls u ON u.id = a.url_id
+		     WHERE u.user_id = ANY($1) AND a.bucket >= $2 AND a.bucket < $3)`
+	if err := r.regions.DB(ctx).QueryRowContext(ctx, query, members, from, to).Scan(&report.ClicksServed); err != nil {
+		return nil, fmt.Errorf("failed to count clicks served: %w", err)
+	}
+
+	query = `
+		SELECT
+		    (SELECT COALESCE(SUM(pg_column_size(u.*)), 0) FROM urls u WHERE u.user_id = ANY($1))
+		  + (SELECT COALESCE(SUM(pg_column_size(e.*)), 0) FROM click_events e JOIN urls u ON u.id = e.url_id
+		     WHERE u.user_id = ANY($1))`
+	if err := r.regions.DB(ctx).QueryRowContext(ctx, query, members).Scan(&report.StorageBytes); err != nil {
+		return nil, fmt.Errorf("failed to measure storage: %w", err)
+	}
+
+	// QR archives are always kept in the home region
+	var qrBytes int64
+	query = `SELECT COALESCE(SUM(pg_column_size(q.*)), 0) FROM qr_batches q WHERE q.user_id = ANY($1)`
+	if err := r.db.QueryRowContext(ctx, query, members).Scan(&qrBytes); err != nil {
+		return nil, fmt.Errorf("failed to measure storage: %w", err)
+	}
+	report.StorageBytes += qrBytes
+
+	return report, nil
+}
+
+// Create stores a usage report; an existing report for the same period is kept
+func (r *usageReportRepository) Create(ctx context.Context, report *models.UsageReport) (*models.UsageReport, error) {
+	query := `
+		INSERT INTO organization_usage_reports
+		    (organization_id, period_start, links_created, clicks_served, api_calls, storage_bytes, generated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (organization_id, period_start) DO NOTHING
+		RETURNING id`
+
+	err := r.db.QueryRowContext(ctx, query,
+		report.OrganizationID, report.PeriodStart, report.LinksCreated, report.ClicksServed,
+		report.APICalls, report.StorageBytes, report.GeneratedAt,
+	).Scan(&report.ID)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("usage report already exists")
+		}
+		return nil, fmt.Errorf("failed to create usage report: %w", err)
+	}
+
+	return report, nil
+}
+
+// GetByOrganization retrieves an organization's usage reports, most recent first
+func (r *usageReportRepository) GetByOrganization(ctx context.Context, orgID int) ([]models.UsageReport, error) {
+	query := `
+		SELECT id, organization_id, period_start, links_created, clicks_served, api_calls,
+		       storage_bytes, generated_at, emailed_at
+		FROM organization_usage_reports
+		WHERE organization_id = $1
+		ORDER BY period_start DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, orgID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage reports: %w", err)
+	}
+	defer rows.Close()
+
+	reports := []models.UsageReport{}
+	for rows.Next() {
+		var report models.UsageReport
+		err := rows.Scan(
+			&report.ID, &report.OrganizationID, &report.PeriodStart, &report.LinksCreated,
+			&report.ClicksServed, &report.APICalls, &report.StorageBytes, &report.GeneratedAt,
+			&report.EmailedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan usage report: %w", err)
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// MarkEmailed records that a report was emailed to the organization owner
+func (r *usageReportRepository) MarkEmailed(ctx context.Context, id int) error {
+	query := "UPDATE organization_usage_reports SET emailed_at = $2 WHERE id = $1"
+	_, err := r.db.ExecContext(ctx, query, id, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to mark usage report emailed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hpower2/url-shortener/database"
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// UserEmailRepository interface defines the contract for secondary email database operations
+type UserEmailRepository interface {
+	Create(ctx context.Context, email *models.UserEmail) (*models.UserEmail, error)
+	GetByID(ctx context.Context, id, userID int) (*models.UserEmail, error)
+	GetAllByUser(ctx context.Context, userID int) ([]models.UserEmail, error)
+	CountByUser(ctx context.Context, userID int) (int, error)
+	MarkVerified(ctx context.Context, id int, verifiedAt time.Time) error
+	DeleteUnverifiedBefore(ctx context.Context, before time.Time) (int64, error)
+	MakePrimary(ctx context.Context, id, userID int) error
+	Delete(ctx context.Context, id, userID int) error
+}
+
+// userEmailRepository implements UserEmailRepository interface
+type userEmailRepository struct {
+	db *database.DB
+}
+
+// NewUserEmailRepository creates a new secondary email repository
+func NewUserEmailRepository(db *database.DB) UserEmailRepository {
+	return &userEmailRepository{db: db}
+}
+
+// Create creates a new unverified secondary email
+func (r *userEmailRepository) Create(ctx context.Context, email *models.UserEmail) (*models.UserEmail, error) {
+	query := `
+		INSERT INTO user_emails (user_id, email, created_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query, email.UserID, email.Email, email.CreatedAt).Scan(&email.ID, &email.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("user email %w", ErrDuplicate)
+		}
+		return nil, fmt.Errorf("failed to create user email: %w", err)
+	}
+
+	return email, nil
+}
+
+// GetByID retrieves one of a user's secondary emails
+func (r *userEmailRepository) GetByID(ctx context.Context, id, userID int) (*models.UserEmail, error) {
+	query := `
+		SELECT id, user_id, email, verified_at, created_at
+		FROM user_emails
+		WHERE id = $1 AND user_id = $2`
+
+	email := &models.UserEmail{}
+	err := r.db.QueryRowContext(ctx, query, id, userID).Scan(
+		&email.ID, &email.UserID, &email.Email, &email.VerifiedAt, &email.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user email %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get user email: %w", err)
+	}
+
+	return email, nil
+}
+
+// GetAllByUser lists a user's secondary emails, oldest first
+func (r *userEmailRepository) GetAllByUser(ctx context.Context, userID int) ([]models.UserEmail, error) {
+	query := `
+		SELECT id, user_id, email, verified_at, created_at
+		FROM user_emails
+		WHERE user_id = $1
+		ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user emails: %w", err)
+	}
+	defer rows.Close()
+
+	emails := []models.UserEmail{}
+	for rows.Next() {
+		var email models.UserEmail
+		if err := rows.Scan(&email.ID, &email.UserID, &email.Email, &email.VerifiedAt, &email.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user email: %w", err)
+		}
+		emails = append(emails, email)
+	}
+
+	return emails, rows.Err()
+}
+
+// CountByUser counts a user's secondary emails
+func (r *userEmailRepository) CountByUser(ctx context.Context, userID int) (int, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM user_emails WHERE user_id = $1`, userID).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count user emails: %w", err)
+	}
+	return count, nil
+}
+
+// MarkVerified records that a secondary email was verified. Only one account
+// can have an address verified, so it fails with ErrDuplicate when another
+// one verified it first.
+func (r *userEmailRepository) MarkVerified(ctx context.Context, id int, verifiedAt time.Time) error {
+	if _, err := r.db.ExecContext(ctx, `UPDATE user_emails SET verified_at = $2 WHERE id = $1`, id, verifiedAt); err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("user email %w", ErrDuplicate)
+		}
+		return fmt.Errorf("failed to verify user email: %w", err)
+	}
+	return nil
+}
+
+// DeleteUnverifiedBefore removes the secondary emails added before a time that
+// were never verified
+func (r *userEmailRepository) DeleteUnverifiedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM user_emails WHERE verified_at IS NULL AND created_at < $1`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete unverified user emails: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// MakePrimary swaps a verified secondary email with the user's primary email.
+// The old primary address stays on the account as a secondary email, and the
+// bounce status of the old address is reset for the new one.
+func (r *userEmailRepository) MakePrimary(ctx context.Context, id, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var secondary string
+	var verifiedAt *time.Time
+	query := `SELECT email, verified_at FROM user_emails WHERE id = $1 AND user_id = $2 FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, id, userID).Scan(&secondary, &verifiedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("user email %w", ErrNotFound)
+		}
+		return fmt.Errorf("failed to get user email: %w", err)
+	}
+
+	var primary string
+	var primaryVerifiedAt *time.Time
+	query = `SELECT email, email_verified_at FROM users WHERE id = $1 FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, userID).Scan(&primary, &primaryVerifiedAt); err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	// Both addresses are unique, so park the secondary one while they swap
+	if _, err := tx.ExecContext(ctx, `UPDATE user_emails SET email = $2 WHERE id = $1`, id, fmt.Sprintf("swapping:%d", id)); err != nil {
+		return fmt.Errorf("failed to update user email: %w", err)
+	}
+	query = `
+		UPDATE users
+		SET email = $2, email_verified = TRUE, email_verified_at = $3,
+		    email_status = $4, email_status_reason = '', email_status_at = NULL, updated_at = $5
+		WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, userID, secondary, verifiedAt, models.EmailStatusDeliverable, time.Now()); err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	query = `UPDATE user_emails SET email = $2, verified_at = $3 WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, id, primary, primaryVerifiedAt); err != nil {
+		return fmt.Errorf("failed to update user email: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Delete removes one of a user's secondary emails
+func (r *userEmailRepository) Delete(ctx context.Context, id, userID int) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM user_emails WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete user email: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("user email %w", ErrNotFound)
+	}
+
+	return nil
+}
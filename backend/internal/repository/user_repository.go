@@ -14,11 +14,29 @@ import (
 type UserRepository interface {
 	Create(ctx context.Context, user *models.User) (*models.User, error)
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByLoginEmail(ctx context.Context, email string) (*models.User, error)
 	GetByID(ctx context.Context, id int) (*models.User, error)
 	Update(ctx context.Context, user *models.User) (*models.User, error)
 	Delete(ctx context.Context, id int) error
 	ExistsByEmail(ctx context.Context, email string) (bool, error)
 	GetAll(ctx context.Context, limit, offset int) ([]models.User, int, error)
+	SetEmailStatus(ctx context.Context, email, status, reason string) (bool, error)
+}
+
+// userColumns lists the columns selected for a user, in scanUser order
+const userColumns = `id, email, password, first_name, last_name, is_active, email_verified, email_verified_at, link_count, link_limit, plan,
+		       needs_review, review_reason, timezone, organization_id, email_status, email_status_reason, email_status_at,
+		       created_at, updated_at`
+
+// scanUser scans a row selected with userColumns into a user
+func scanUser(row rowScanner, user *models.User) error {
+	return row.Scan(
+		&user.ID, &user.Email, &user.Password, &user.FirstName, &user.LastName,
+		&user.IsActive, &user.EmailVerified, &user.EmailVerifiedAt, &user.LinkCount, &user.LinkLimit,
+		&user.Plan, &user.NeedsReview, &user.ReviewReason, &user.Timezone, &user.OrganizationID,
+		&user.EmailStatus, &user.EmailStatusReason, &user.EmailStatusAt,
+		&user.CreatedAt, &user.UpdatedAt,
+	)
 }
 
 // userRepository implements UserRepository interface
@@ -34,15 +52,17 @@ func NewUserRepository(db *database.DB) UserRepository {
 // Create creates a new user record
 func (r *userRepository) Create(ctx context.Context, user *models.User) (*models.User, error) {
 	query := `
-		INSERT INTO users (email, password, first_name, last_name, is_active, email_verified, link_count, link_limit, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
-		RETURNING id, created_at, updated_at`
+		INSERT INTO users (email, password, first_name, last_name, is_active, email_verified, link_count, link_limit, plan,
+		                   needs_review, review_reason, signup_ip, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		RETURNING id, timezone, email_status, created_at, updated_at`
 
 	err := r.db.QueryRowContext(ctx, query,
 		user.Email, user.Password, user.FirstName, user.LastName,
 		user.IsActive, user.EmailVerified, user.LinkCount, user.LinkLimit,
+		user.Plan, user.NeedsReview, user.ReviewReason, user.SignupIP,
 		user.CreatedAt, user.UpdatedAt,
-	).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&user.ID, &user.Timezone, &user.EmailStatus, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to create user: %w", err)
@@ -54,20 +74,37 @@ func (r *userRepository) Create(ctx context.Context, user *models.User) (*models
 // GetByEmail retrieves a user by email
 func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	query := `
-		SELECT id, email, password, first_name, last_name, is_active, email_verified, email_verified_at, link_count, link_limit, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users 
 		WHERE email = $1`
 
 	user := &models.User{}
-	err := r.db.QueryRowContext(ctx, query, email).Scan(
-		&user.ID, &user.Email, &user.Password, &user.FirstName, &user.LastName,
-		&user.IsActive, &user.EmailVerified, &user.EmailVerifiedAt, &user.LinkCount, &user.LinkLimit,
-		&user.CreatedAt, &user.UpdatedAt,
-	)
+	err := scanUser(r.db.QueryRowContext(ctx, query, email), user)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return user, nil
+}
+
+// GetByLoginEmail retrieves a user by their primary email or a verified secondary email
+func (r *userRepository) GetByLoginEmail(ctx context.Context, email string) (*models.User, error) {
+	query := `
+		SELECT ` + userColumns + `
+		FROM users
+		WHERE email = $1
+		   OR id = (SELECT user_id FROM user_emails WHERE email = $1 AND verified_at IS NOT NULL)`
+
+	user := &models.User{}
+	err := scanUser(r.db.QueryRowContext(ctx, query, email), user)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, fmt.Errorf("user %w", ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
@@ -78,20 +115,16 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.
 // GetByID retrieves a user by ID
 func (r *userRepository) GetByID(ctx context.Context, id int) (*models.User, error) {
 	query := `
-		SELECT id, email, password, first_name, last_name, is_active, email_verified, email_verified_at, link_count, link_limit, created_at, updated_at
+		SELECT ` + userColumns + `
 		FROM users 
 		WHERE id = $1`
 
 	user := &models.User{}
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&user.ID, &user.Email, &user.Password, &user.FirstName, &user.LastName,
-		&user.IsActive, &user.EmailVerified, &user.EmailVerifiedAt, &user.LinkCount, &user.LinkLimit,
-		&user.CreatedAt, &user.UpdatedAt,
-	)
+	err := scanUser(r.db.QueryRowContext(ctx, query, id), user)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("user not found")
+			return nil, fmt.Errorf("user %w", ErrNotFound)
 		}
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
@@ -104,15 +137,20 @@ func (r *userRepository) Update(ctx context.Context, user *models.User) (*models
 	query := `
 		UPDATE users 
 		SET email = $2, first_name = $3, last_name = $4, is_active = $5, 
-		    email_verified = $6, email_verified_at = $7, link_count = $8, link_limit = $9, updated_at = $10
+		    email_verified = $6, email_verified_at = $7, link_count = $8, link_limit = $9,
+		    timezone = $10, updated_at = $11,
+		    -- A new address hasn't bounced yet
+		    email_status = CASE WHEN email = $2 THEN email_status ELSE 'deliverable' END,
+		    email_status_reason = CASE WHEN email = $2 THEN email_status_reason ELSE '' END,
+		    email_status_at = CASE WHEN email = $2 THEN email_status_at ELSE NULL END
 		WHERE id = $1
-		RETURNING created_at, updated_at`
+		RETURNING email_status, email_status_reason, email_status_at, created_at, updated_at`
 
 	err := r.db.QueryRowContext(ctx, query,
 		user.ID, user.Email, user.FirstName, user.LastName,
 		user.IsActive, user.EmailVerified, user.EmailVerifiedAt, user.LinkCount, user.LinkLimit,
-		time.Now(),
-	).Scan(&user.CreatedAt, &user.UpdatedAt)
+		user.Timezone, time.Now(),
+	).Scan(&user.EmailStatus, &user.EmailStatusReason, &user.EmailStatusAt, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to update user: %w", err)
@@ -135,7 +173,7 @@ func (r *userRepository) Delete(ctx context.Context, id int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("user not found")
+		return fmt.Errorf("user %w", ErrNotFound)
 	}
 
 	return nil
@@ -143,7 +181,11 @@ func (r *userRepository) Delete(ctx context.Context, id int) error {
 
 // ExistsByEmail checks if a user exists by email
 func (r *userRepository) ExistsByEmail(ctx context.Context, email string) (bool, error) {
-	query := "SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)"
+	// Verified secondary emails count too, so an address belongs to one account
+	// only. Unverified ones don't, or anyone could squat an address.
+	query := `
+		SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)
+		    OR EXISTS(SELECT 1 FROM user_emails WHERE email = $1 AND verified_at IS NOT NULL)`
 	var exists bool
 	err := r.db.QueryRowContext(ctx, query, email).Scan(&exists)
 	if err != nil {
@@ -190,3 +232,23 @@ func (r *userRepository) GetAll(ctx context.Context, limit, offset int) ([]model
 
 	return users, total, nil
 }
+
+// SetEmailStatus records the deliverability of an email address, reporting
+// whether a user has that address
+func (r *userRepository) SetEmailStatus(ctx context.Context, email, status, reason string) (bool, error) {
+	query := `
+		UPDATE users
+		SET email_status = $2, email_status_reason = $3, email_status_at = $4, updated_at = $4
+		WHERE email = $1`
+
+	result, err := r.db.ExecContext(ctx, query, email, status, reason, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("failed to set email status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	return rowsAffected > 0, nil
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/hpower2/url-shortener/database"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/lib/pq"
+)
+
+// VerifiedDomainRepository interface defines the contract for verified destination domain database operations
+type VerifiedDomainRepository interface {
+	Create(ctx context.Context, domain *models.VerifiedDomain) (*models.VerifiedDomain, error)
+	GetByID(ctx context.Context, id int, userID int) (*models.VerifiedDomain, error)
+	GetAllByUser(ctx context.Context, userID int) ([]models.VerifiedDomain, error)
+	MarkVerified(ctx context.Context, domain *models.VerifiedDomain) error
+	Delete(ctx context.Context, id int, userID int) error
+	IsVerifiedForUser(ctx context.Context, userID int, host string) (bool, error)
+}
+
+// verifiedDomainRepository implements VerifiedDomainRepository interface
+type verifiedDomainRepository struct {
+	db *database.DB
+}
+
+// NewVerifiedDomainRepository creates a new verified domain repository
+func NewVerifiedDomainRepository(db *database.DB) VerifiedDomainRepository {
+	return &verifiedDomainRepository{db: db}
+}
+
+const verifiedDomainColumns = `id, user_id, domain, verification_token, verified_at, created_at`
+
+// scanVerifiedDomain scans a row selected with verifiedDomainColumns
+func scanVerifiedDomain(row rowScanner, domain *models.VerifiedDomain) error {
+	return row.Scan(
+		&domain.ID, &domain.UserID, &domain.Domain, &domain.VerificationToken, &domain.VerifiedAt, &domain.CreatedAt,
+	)
+}
+
+// Create creates a new claim on a destination domain
+func (r *verifiedDomainRepository) Create(ctx context.Context, domain *models.VerifiedDomain) (*models.VerifiedDomain, error) {
+	query := `
+		INSERT INTO verified_domains (user_id, domain, verification_token, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		domain.UserID, domain.Domain, domain.VerificationToken, domain.CreatedAt,
+	).Scan(&domain.ID, &domain.CreatedAt)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create verified domain: %w", err)
+	}
+
+	return domain, nil
+}
+
+// GetByID retrieves one of a user's destination domains
+func (r *verifiedDomainRepository) GetByID(ctx context.Context, id int, userID int) (*models.VerifiedDomain, error) {
+	query := `SELECT ` + verifiedDomainColumns + ` FROM verified_domains WHERE id = $1 AND user_id = $2`
+
+	domain := &models.VerifiedDomain{}
+	if err := scanVerifiedDomain(r.db.QueryRowContext(ctx, query, id, userID), domain); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("verified domain %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get verified domain: %w", err)
+	}
+
+	return domain, nil
+}
+
+// GetAllByUser retrieves all destination domains claimed by a user
+func (r *verifiedDomainRepository) GetAllByUser(ctx context.Context, userID int) ([]models.VerifiedDomain, error) {
+	query := `SELECT ` + verifiedDomainColumns + ` FROM verified_domains WHERE user_id = $1 ORDER BY domain`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get verified domains: %w", err)
+	}
+	defer rows.Close()
+
+	domains := []models.VerifiedDomain{}
+	for rows.Next() {
+		var domain models.VerifiedDomain
+		if err := scanVerifiedDomain(rows, &domain); err != nil {
+			return nil, fmt.Errorf("failed to scan verified domain: %w", err)
+		}
+		domains = append(domains, domain)
+	}
+
+	return domains, nil
+}
+
+// MarkVerified records when the domain's ownership was proven
+func (r *verifiedDomainRepository) MarkVerified(ctx context.Context, domain *models.VerifiedDomain) error {
+	query := `UPDATE verified_domains SET verified_at = $3 WHERE id = $1 AND user_id = $2`
+
+	result, err := r.db.ExecContext(ctx, query, domain.ID, domain.UserID, domain.VerifiedAt)
+	if err != nil {
+		return fmt.Errorf("failed to verify domain: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("verified domain %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// Delete removes one of a user's destination domains
+func (r *verifiedDomainRepository) Delete(ctx context.Context, id int, userID int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM verified_domains WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete verified domain: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("verified domain %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// IsVerifiedForUser reports whether the user has verified the host or one of its parent domains
+func (r *verifiedDomainRepository) IsVerifiedForUser(ctx context.Context, userID int, host string) (bool, error) {
+	candidates := models.VerifiedDomainCandidates(host)
+	if len(candidates) == 0 {
+		return false, nil
+	}
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM verified_domains
+			WHERE user_id = $1 AND domain = ANY($2) AND verified_at IS NOT NULL
+		)`
+
+	var verified bool
+	if err := r.db.QueryRowContext(ctx, query, userID, pq.Array(candidates)).Scan(&verified); err != nil {
+		return false, fmt.Errorf("failed to check verified domain: %w", err)
+	}
+
+	return verified, nil
+}
@@ -0,0 +1,299 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/lib/pq"
+)
+
+// WebhookRepository interface defines the contract for webhook database operations
+type WebhookRepository interface {
+	Create(ctx context.Context, webhook *models.Webhook) (*models.Webhook, error)
+	GetByID(ctx context.Context, id int, urlID int) (*models.Webhook, error)
+	GetAllByURL(ctx context.Context, urlID int) ([]models.Webhook, error)
+	GetActiveByURLAndEvent(ctx context.Context, urlID int, event string) ([]models.Webhook, error)
+	Update(ctx context.Context, webhook *models.Webhook) (*models.Webhook, error)
+	Delete(ctx context.Context, id int, urlID int) error
+	CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	GetDeliveries(ctx context.Context, webhookID int, limit, offset int) ([]models.WebhookDelivery, int, error)
+	CreateTrigger(ctx context.Context, trigger *models.ClickTrigger) (*models.ClickTrigger, error)
+	GetTriggersByURL(ctx context.Context, urlID int) ([]models.ClickTrigger, error)
+	DeleteTrigger(ctx context.Context, id int, urlID int) error
+	MarkTriggerFired(ctx context.Context, trigger *models.ClickTrigger, firedAt time.Time) (bool, error)
+}
+
+// webhookRepository implements WebhookRepository interface
+type webhookRepository struct {
+	regions *RegionRouter
+}
+
+// NewWebhookRepository creates a new webhook repository. Webhooks are stored
+// with their link, in the data region set on the context.
+func NewWebhookRepository(regions *RegionRouter) WebhookRepository {
+	return &webhookRepository{regions: regions}
+}
+
+const webhookColumns = `id, user_id, url_id, target_url, secret, events, is_active, created_at, updated_at`
+
+// scanWebhook scans a webhook row selected with webhookColumns
+func scanWebhook(row rowScanner, webhook *models.Webhook) error {
+	return row.Scan(
+		&webhook.ID, &webhook.UserID, &webhook.URLID, &webhook.TargetURL, &webhook.Secret,
+		pq.Array(&webhook.Events), &webhook.IsActive, &webhook.CreatedAt, &webhook.UpdatedAt,
+	)
+}
+
+// Create creates a new webhook subscription
+func (r *webhookRepository) Create(ctx context.Context, webhook *models.Webhook) (*models.Webhook, error) {
+	query := `
+		INSERT INTO webhooks (user_id, url_id, target_url, secret, events, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at`
+
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query,
+		webhook.UserID, webhook.URLID, webhook.TargetURL, webhook.Secret, pq.Array(webhook.Events),
+		webhook.IsActive, webhook.CreatedAt, webhook.UpdatedAt,
+	).Scan(&webhook.ID, &webhook.CreatedAt, &webhook.UpdatedAt)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// GetByID retrieves a webhook subscribed to a link
+func (r *webhookRepository) GetByID(ctx context.Context, id int, urlID int) (*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1 AND url_id = $2`
+
+	webhook := &models.Webhook{}
+	if err := scanWebhook(r.regions.DB(ctx).QueryRowContext(ctx, query, id, urlID), webhook); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("webhook %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// GetAllByURL retrieves all webhooks subscribed to a link
+func (r *webhookRepository) GetAllByURL(ctx context.Context, urlID int) ([]models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE url_id = $1 ORDER BY created_at DESC`
+	return r.queryWebhooks(ctx, query, urlID)
+}
+
+// GetActiveByURLAndEvent retrieves the active webhooks of a link that subscribe to an event
+func (r *webhookRepository) GetActiveByURLAndEvent(ctx context.Context, urlID int, event string) ([]models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE url_id = $1 AND is_active = TRUE AND $2 = ANY(events)`
+	return r.queryWebhooks(ctx, query, urlID, event)
+}
+
+// queryWebhooks runs a webhook select and scans every row
+func (r *webhookRepository) queryWebhooks(ctx context.Context, query string, args ...interface{}) ([]models.Webhook, error) {
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		var webhook models.Webhook
+		if err := scanWebhook(rows, &webhook); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
+// Update updates a webhook subscription
+func (r *webhookRepository) Update(ctx context.Context, webhook *models.Webhook) (*models.Webhook, error) {
+	query := `
+		UPDATE webhooks
+		SET target_url = $3, events = $4, is_active = $5, updated_at = $6
+		WHERE id = $1 AND url_id = $2
+		RETURNING updated_at`
+
+	webhook.UpdatedAt = time.Now()
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query,
+		webhook.ID, webhook.URLID, webhook.TargetURL, pq.Array(webhook.Events), webhook.IsActive, webhook.UpdatedAt,
+	).Scan(&webhook.UpdatedAt)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("webhook %w", ErrNotFound)
+		}
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// Delete removes a webhook subscription and its delivery history
+func (r *webhookRepository) Delete(ctx context.Context, id int, urlID int) error {
+	result, err := r.regions.DB(ctx).ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1 AND url_id = $2", id, urlID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("webhook %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// CreateDelivery records a delivery attempt
+func (r *webhookRepository) CreateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, status_code, error, success, duration_ms, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`
+
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query,
+		delivery.WebhookID, delivery.Event, delivery.Payload, delivery.StatusCode, delivery.Error,
+		delivery.Success, delivery.DurationMs, delivery.CreatedAt,
+	).Scan(&delivery.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to create webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+// GetDeliveries retrieves a webhook's delivery history, newest first
+func (r *webhookRepository) GetDeliveries(ctx context.Context, webhookID int, limit, offset int) ([]models.WebhookDelivery, int, error) {
+	var total int
+	if err := r.regions.DB(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = $1", webhookID).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count webhook deliveries: %w", err)
+	}
+
+	query := `
+		SELECT id, webhook_id, event, payload, status_code, error, success, duration_ms, created_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, webhookID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		err := rows.Scan(
+			&delivery.ID, &delivery.WebhookID, &delivery.Event, &delivery.Payload, &delivery.StatusCode,
+			&delivery.Error, &delivery.Success, &delivery.DurationMs, &delivery.CreatedAt,
+		)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, total, nil
+}
+
+// CreateTrigger creates a click threshold trigger on a link
+func (r *webhookRepository) CreateTrigger(ctx context.Context, trigger *models.ClickTrigger) (*models.ClickTrigger, error) {
+	query := `
+		INSERT INTO click_triggers (user_id, url_id, kind, threshold, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	err := r.regions.DB(ctx).QueryRowContext(ctx, query,
+		trigger.UserID, trigger.URLID, trigger.Kind, trigger.Threshold, trigger.CreatedAt,
+	).Scan(&trigger.ID, &trigger.CreatedAt)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create click trigger: %w", err)
+	}
+
+	return trigger, nil
+}
+
+// GetTriggersByURL retrieves a link's click triggers
+func (r *webhookRepository) GetTriggersByURL(ctx context.Context, urlID int) ([]models.ClickTrigger, error) {
+	query := `
+		SELECT id, user_id, url_id, kind, threshold, last_fired_at, created_at
+		FROM click_triggers
+		WHERE url_id = $1
+		ORDER BY threshold, id`
+
+	rows, err := r.regions.DB(ctx).QueryContext(ctx, query, urlID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get click triggers: %w", err)
+	}
+	defer rows.Close()
+
+	triggers := []models.ClickTrigger{}
+	for rows.Next() {
+		var trigger models.ClickTrigger
+		err := rows.Scan(
+			&trigger.ID, &trigger.UserID, &trigger.URLID, &trigger.Kind, &trigger.Threshold,
+			&trigger.LastFiredAt, &trigger.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan click trigger: %w", err)
+		}
+		triggers = append(triggers, trigger)
+	}
+
+	return triggers, nil
+}
+
+// DeleteTrigger removes a link's click trigger
+func (r *webhookRepository) DeleteTrigger(ctx context.Context, id int, urlID int) error {
+	result, err := r.regions.DB(ctx).ExecContext(ctx, "DELETE FROM click_triggers WHERE id = $1 AND url_id = $2", id, urlID)
+	if err != nil {
+		return fmt.Errorf("failed to delete click trigger: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return fmt.Errorf("click trigger %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// MarkTriggerFired records that a trigger fired. One-off triggers are only marked
+// once, so it returns false if another click already fired them.
+func (r *webhookRepository) MarkTriggerFired(ctx context.Context, trigger *models.ClickTrigger, firedAt time.Time) (bool, error) {
+	query := `UPDATE click_triggers SET last_fired_at = $2 WHERE id = $1`
+	if trigger.Kind == models.ClickTriggerReach {
+		query += ` AND last_fired_at IS NULL`
+	}
+
+	result, err := r.regions.DB(ctx).ExecContext(ctx, query, trigger.ID, firedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark click trigger fired: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
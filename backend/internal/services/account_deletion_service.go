@@ -0,0 +1,380 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// Timing of the account purge pipeline
+const (
+	purgeChunkTimeout   = 2 * time.Minute  // Bounds purging a single chunk
+	purgeRetryDelay     = 30 * time.Second // Multiplied by the attempt number
+	maxPurgeAttempts    = 5                // Failed attempts at a chunk before the deletion fails
+	purgeStallThreshold = 15 * time.Minute // Deletions without progress for this long are requeued
+	purgeStallInterval  = 5 * time.Minute
+)
+
+// AccountDeletionService interface defines the contract for deleting accounts.
+// Accounts are deactivated at once and their data purged in the background,
+// one chunk per queued job, as large accounts have too much to delete in a request.
+type AccountDeletionService interface {
+	PreviewDeletion(ctx context.Context, userID int) (*models.AccountDeletionImpact, error)
+	RequestDeletion(ctx context.Context, userID int, req *models.DeleteAccountRequest) (*models.AccountDeletion, error)
+	DeleteAccount(ctx context.Context, userID int) (*models.AccountDeletion, error)
+	GetDeletions(ctx context.Context, filter *models.AccountDeletionFilter) ([]models.AccountDeletion, error)
+	GetDeletion(ctx context.Context, id int) (*models.AccountDeletion, error)
+	ResumeDeletion(ctx context.Context, id int) (*models.AccountDeletion, error)
+	Start(ctx context.Context)
+	Stop(ctx context.Context)
+}
+
+// accountDeletionService implements AccountDeletionService interface
+type accountDeletionService struct {
+	deletionRepo    repository.AccountDeletionRepository
+	userRepo        repository.UserRepository
+	cacheRepo       repository.CacheRepository
+	regions         *repository.RegionRouter
+	rabbitMQService RabbitMQService
+
+	cancel context.CancelFunc // Stops consuming
+	done   chan struct{}      // Closed once the consume loop has returned
+}
+
+// NewAccountDeletionService creates a new account deletion service
+func NewAccountDeletionService(
+	deletionRepo repository.AccountDeletionRepository,
+	userRepo repository.UserRepository,
+	cacheRepo repository.CacheRepository,
+	regions *repository.RegionRouter,
+	rabbitMQService RabbitMQService,
+) AccountDeletionService {
+	return &accountDeletionService{
+		deletionRepo:    deletionRepo,
+		userRepo:        userRepo,
+		cacheRepo:       cacheRepo,
+		regions:         regions,
+		rabbitMQService: rabbitMQService,
+	}
+}
+
+// PreviewDeletion describes what deleting the user's own account destroys
+func (s *accountDeletionService) PreviewDeletion(ctx context.Context, userID int) (*models.AccountDeletionImpact, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AccountDeletionImpact{Email: user.Email, Links: user.LinkCount}, nil
+}
+
+// RequestDeletion deletes the user's own account once they confirm their password
+func (s *accountDeletionService) RequestDeletion(ctx context.Context, userID int, req *models.DeleteAccountRequest) (*models.AccountDeletion, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.CheckPassword(req.Password) {
+		return nil, errors.NewUnauthorizedError("Password is incorrect", nil)
+	}
+
+	return s.startDeletion(ctx, user, models.DeletionRequestedByUser)
+}
+
+// DeleteAccount deletes a user's account on an admin's behalf
+func (s *accountDeletionService) DeleteAccount(ctx context.Context, userID int) (*models.AccountDeletion, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.startDeletion(ctx, user, models.DeletionRequestedByAdmin)
+}
+
+// getUser retrieves the user whose account is being deleted
+func (s *accountDeletionService) getUser(ctx context.Context, userID int) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("User not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get user", err)
+	}
+	return user, nil
+}
+
+// startDeletion records the deletion, deactivates the account so it can't
+// sign in or create links while it is purged, and queues the first chunk.
+// The deletion is recorded first so a deactivated account is always purged.
+func (s *accountDeletionService) startDeletion(ctx context.Context, user *models.User, requestedBy string) (*models.AccountDeletion, error) {
+	deletion, err := s.deletionRepo.Create(ctx, &models.AccountDeletion{
+		UserID:      user.ID,
+		Email:       user.Email,
+		RequestedBy: requestedBy,
+		Status:      models.AccountDeletionPending,
+		Stage:       models.PurgeLinkStages[0],
+		Region:      s.regions.Home(),
+	})
+	if err != nil {
+		if repository.IsDuplicate(err) {
+			return nil, errors.NewConflictError("Account deletion is already in progress", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to create account deletion", err)
+	}
+
+	if user.IsActive {
+		user.IsActive = false
+		if _, err := s.userRepo.Update(ctx, user); err != nil {
+			return nil, errors.NewDatabaseError("Failed to deactivate user", err)
+		}
+	}
+
+	s.enqueue(deletion.ID, 0)
+	return deletion, nil
+}
+
+// GetDeletions lists account deletions and their progress, newest first
+func (s *accountDeletionService) GetDeletions(ctx context.Context, filter *models.AccountDeletionFilter) ([]models.AccountDeletion, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid account deletion filter", err)
+	}
+
+	deletions, err := s.deletionRepo.GetAll(ctx, filter)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get account deletions", err)
+	}
+	return deletions, nil
+}
+
+// GetDeletion returns an account deletion and its progress
+func (s *accountDeletionService) GetDeletion(ctx context.Context, id int) (*models.AccountDeletion, error) {
+	deletion, err := s.deletionRepo.GetByID(ctx, id)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("Account deletion not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get account deletion", err)
+	}
+	return deletion, nil
+}
+
+// ResumeDeletion queues a failed deletion again from the chunk it failed on
+func (s *accountDeletionService) ResumeDeletion(ctx context.Context, id int) (*models.AccountDeletion, error) {
+	resumed, err := s.deletionRepo.Resume(ctx, id)
+	if err != nil {
+		if repository.IsDuplicate(err) {
+			return nil, errors.NewConflictError("Another deletion of the account is in progress", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to resume account deletion", err)
+	}
+
+	deletion, err := s.GetDeletion(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !resumed {
+		return nil, errors.NewConflictError(fmt.Sprintf("Account deletion is %s", deletion.Status), nil)
+	}
+
+	s.enqueue(deletion.ID, 0)
+	return deletion, nil
+}
+
+// Start consumes the purge queue in the background and requeues deletions
+// that stopped making progress, until ctx is cancelled or Stop is called.
+// RabbitMQ must already be connected.
+func (s *accountDeletionService) Start(ctx context.Context) {
+	consumeCtx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		for consumeCtx.Err() == nil {
+			if err := s.rabbitMQService.ConsumePurges(consumeCtx, s.handlePurge); err != nil {
+				log.Printf("Error consuming purge queue: %v", err)
+				select {
+				case <-consumeCtx.Done():
+				case <-time.After(5 * time.Second): // Wait before retrying
+				}
+			}
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(purgeStallInterval)
+		defer ticker.Stop()
+
+		for {
+			s.requeueStalled(consumeCtx)
+			select {
+			case <-consumeCtx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops pulling purge jobs and waits for the chunks being purged to
+// finish, or for ctx to expire
+func (s *accountDeletionService) Stop(ctx context.Context) {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		log.Println("Timed out waiting for in-flight purge jobs; they will be requeued")
+	}
+}
+
+// requeueStalled queues deletions again whose job was lost, e.g. because
+// RabbitMQ was down when it was published. A deletion that is still queued
+// is harmless to queue twice: only one job can record each chunk.
+func (s *accountDeletionService) requeueStalled(ctx context.Context) {
+	deletions, err := s.deletionRepo.GetStalled(ctx, time.Now().Add(-purgeStallThreshold))
+	if err != nil {
+		log.Printf("Failed to find stalled account deletions: %v", err)
+		return
+	}
+	for _, deletion := range deletions {
+		log.Printf("Requeueing stalled deletion %d of user %d at %s/%s", deletion.ID, deletion.UserID, deletion.Region, deletion.Stage)
+		s.enqueue(deletion.ID, 0)
+	}
+}
+
+// enqueue queues the next chunk of a deletion. Failures are only logged, as
+// the stall check queues it again later.
+func (s *accountDeletionService) enqueue(id int, delay time.Duration) {
+	if err := s.rabbitMQService.PublishPurge(&PurgeMessage{DeletionID: id}, delay); err != nil {
+		log.Printf("Failed to queue account deletion %d: %v", id, err)
+	}
+}
+
+// handlePurge purges the next chunk of a deletion and queues the one after,
+// retrying failed chunks with a growing delay until the attempts run out
+func (s *accountDeletionService) handlePurge(message *PurgeMessage) {
+	ctx, cancel := context.WithTimeout(context.Background(), purgeChunkTimeout)
+	defer cancel()
+
+	deletion, err := s.deletionRepo.GetByID(ctx, message.DeletionID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			log.Printf("Dropping purge job of unknown account deletion %d", message.DeletionID)
+			return
+		}
+		log.Printf("Failed to get account deletion %d: %v", message.DeletionID, err)
+		s.enqueue(message.DeletionID, purgeRetryDelay)
+		return
+	}
+	if deletion.Finished() {
+		return
+	}
+
+	chunk, done, err := s.purgeChunk(ctx, deletion)
+	if err != nil {
+		attempts := deletion.Attempts + 1
+		failed := attempts >= maxPurgeAttempts
+		log.Printf("Failed to purge %s of user %d in region %q (attempt %d/%d): %v",
+			deletion.Stage, deletion.UserID, deletion.Region, attempts, maxPurgeAttempts, err)
+		if err := s.deletionRepo.RecordFailure(ctx, deletion.ID, err.Error(), failed); err != nil {
+			log.Printf("Failed to record failure of account deletion %d: %v", deletion.ID, err)
+		}
+		if !failed {
+			s.enqueue(deletion.ID, time.Duration(attempts)*purgeRetryDelay)
+		}
+		return
+	}
+
+	if done && deletion.Stage == models.PurgeStageAccount {
+		if err := s.deletionRepo.Complete(ctx, deletion.ID); err != nil {
+			log.Printf("Failed to complete account deletion %d: %v", deletion.ID, err)
+			s.enqueue(deletion.ID, purgeRetryDelay)
+			return
+		}
+		log.Printf("Deleted account of user %d", deletion.UserID)
+		return
+	}
+
+	stage, region := deletion.Stage, deletion.Region
+	if done {
+		stage, region = deletion.NextStage(s.regions.Regions())
+	}
+	advanced, err := s.deletionRepo.Advance(ctx, deletion, chunk, stage, region)
+	if err != nil {
+		log.Printf("Failed to record progress of account deletion %d: %v", deletion.ID, err)
+		s.enqueue(deletion.ID, purgeRetryDelay)
+		return
+	}
+	if !advanced {
+		// Another job recorded this chunk first and queued the next one
+		return
+	}
+
+	s.enqueue(deletion.ID, 0)
+}
+
+// purgeChunk purges one chunk of the deletion's current stage from its region,
+// reporting whether the stage is done
+func (s *accountDeletionService) purgeChunk(ctx context.Context, deletion *models.AccountDeletion) (models.PurgeProgress, bool, error) {
+	var chunk models.PurgeProgress
+	regionCtx := repository.WithRegion(ctx, deletion.Region)
+
+	switch deletion.Stage {
+	case models.PurgeStageCaches:
+		// Purge the links' caches before deactivating them, so a failed purge
+		// leaves them active and the retried chunk picks them up again
+		shortCodes, err := s.deletionRepo.GetActiveShortCodes(regionCtx, deletion.UserID, models.PurgeURLChunkSize)
+		if err != nil {
+			return chunk, false, err
+		}
+		if chunk.CachesPurged, err = s.cacheRepo.PurgeURLs(regionCtx, shortCodes); err != nil {
+			return chunk, false, fmt.Errorf("failed to purge cached URLs: %w", err)
+		}
+		if err := s.deletionRepo.DeactivateURLs(regionCtx, deletion.UserID, shortCodes); err != nil {
+			return chunk, false, err
+		}
+		return chunk, len(shortCodes) < models.PurgeURLChunkSize, nil
+
+	case models.PurgeStageEvents:
+		deleted, err := s.deletionRepo.DeleteClickEvents(regionCtx, deletion.UserID, models.PurgeRowChunkSize)
+		chunk.ClickEventsDeleted = deleted
+		return chunk, deleted < models.PurgeRowChunkSize, err
+
+	case models.PurgeStageAggregates:
+		deleted, err := s.deletionRepo.DeleteClickAggregates(regionCtx, deletion.UserID, models.PurgeRowChunkSize)
+		chunk.AggregatesDeleted = deleted
+		return chunk, deleted < models.PurgeRowChunkSize, err
+
+	case models.PurgeStageURLs:
+		shortCodes, err := s.deletionRepo.DeleteURLs(regionCtx, deletion.UserID, models.PurgeURLChunkSize)
+		if err != nil {
+			return chunk, false, err
+		}
+		chunk.URLsDeleted = int64(len(shortCodes))
+		// Visits since the links were deactivated may have cached them again
+		if chunk.CachesPurged, err = s.cacheRepo.PurgeURLs(regionCtx, shortCodes); err != nil {
+			return chunk, false, fmt.Errorf("failed to purge cached URLs: %w", err)
+		}
+		return chunk, len(shortCodes) < models.PurgeURLChunkSize, nil
+
+	case models.PurgeStageAccount:
+		// Delete the rows mirrored into other regions before the account itself
+		regions := s.regions.Regions()
+		for i := len(regions) - 1; i >= 0; i-- {
+			if err := s.deletionRepo.DeleteUser(repository.WithRegion(ctx, regions[i]), deletion.UserID); err != nil {
+				return chunk, false, err
+			}
+		}
+		return chunk, true, nil
+
+	default:
+		return chunk, false, fmt.Errorf("unknown purge stage %q", deletion.Stage)
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/config"
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// APIKeyService interface defines the contract for API key operations
+type APIKeyService interface {
+	CreateAPIKey(ctx context.Context, userID int, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error)
+	GetAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int, userID int) error
+	VerifySignedRequest(ctx context.Context, req *models.SignedRequest) (*models.User, *models.APIKey, error)
+}
+
+// apiKeyService implements APIKeyService interface
+type apiKeyService struct {
+	apiKeyRepo repository.APIKeyRepository
+	userRepo   repository.UserRepository
+	cacheRepo  repository.CacheRepository
+	config     *config.Config
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepository, userRepo repository.UserRepository, cacheRepo repository.CacheRepository, config *config.Config) APIKeyService {
+	return &apiKeyService{
+		apiKeyRepo: apiKeyRepo,
+		userRepo:   userRepo,
+		cacheRepo:  cacheRepo,
+		config:     config,
+	}
+}
+
+// CreateAPIKey creates a new API key and returns its secret once
+func (s *apiKeyService) CreateAPIKey(ctx context.Context, userID int, req *models.CreateAPIKeyRequest) (*models.CreateAPIKeyResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid API key request", err)
+	}
+
+	keyID, err := randomHex(12)
+	if err != nil {
+		return nil, errors.NewInternalError("Failed to generate API key", err)
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, errors.NewInternalError("Failed to generate API key secret", err)
+	}
+
+	apiKey := &models.APIKey{
+		UserID:    userID,
+		Name:      req.Name,
+		KeyID:     "ak_" + keyID,
+		Secret:    secret,
+		IsActive:  true,
+		Sandbox:   req.Sandbox,
+		CreatedAt: time.Now(),
+	}
+
+	createdKey, err := s.apiKeyRepo.Create(ctx, apiKey)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to create API key", err)
+	}
+
+	return &models.CreateAPIKeyResponse{
+		APIKey: *createdKey,
+		Secret: createdKey.Secret,
+	}, nil
+}
+
+// GetAPIKeys lists a user's API keys
+func (s *apiKeyService) GetAPIKeys(ctx context.Context, userID int) ([]models.APIKey, error) {
+	apiKeys, err := s.apiKeyRepo.GetAllByUser(ctx, userID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get API keys", err)
+	}
+	return apiKeys, nil
+}
+
+// RevokeAPIKey revokes one of a user's API keys
+func (s *apiKeyService) RevokeAPIKey(ctx context.Context, id int, userID int) error {
+	if err := s.apiKeyRepo.Revoke(ctx, id, userID); err != nil {
+		if repository.IsNotFound(err) {
+			return errors.NewNotFoundError("API key not found", err)
+		}
+		return errors.NewDatabaseError("Failed to revoke API key", err)
+	}
+	return nil
+}
+
+// VerifySignedRequest authenticates an HMAC-signed request.
+// The signature is hex(HMAC-SHA256(secret, METHOD\nPATH\nTIMESTAMP\nNONCE\nhex(SHA256(body)))).
+func (s *apiKeyService) VerifySignedRequest(ctx context.Context, req *models.SignedRequest) (*models.User, *models.APIKey, error) {
+	if req.KeyID == "" || req.Timestamp == "" || req.Nonce == "" || req.Signature == "" {
+		return nil, nil, errors.NewUnauthorizedError("Incomplete request signature headers", nil)
+	}
+
+	// Reject stale or future-dated requests
+	unixTime, err := strconv.ParseInt(req.Timestamp, 10, 64)
+	if err != nil {
+		return nil, nil, errors.NewUnauthorizedError("Invalid signature timestamp", err)
+	}
+	skew := time.Since(time.Unix(unixTime, 0))
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > s.config.Security.SignatureSkew {
+		return nil, nil, errors.NewUnauthorizedError("Signature timestamp outside allowed window", nil)
+	}
+
+	apiKey, err := s.apiKeyRepo.GetByKeyID(ctx, req.KeyID)
+	if err != nil {
+		return nil, nil, errors.NewUnauthorizedError("Invalid API key", err)
+	}
+	if !apiKey.IsActive {
+		return nil, nil, errors.NewUnauthorizedError("API key has been revoked", nil)
+	}
+
+	expected, err := hex.DecodeString(signRequest(apiKey.Secret, req))
+	if err != nil {
+		return nil, nil, errors.NewInternalError("Failed to compute signature", err)
+	}
+	provided, err := hex.DecodeString(req.Signature)
+	if err != nil || !hmac.Equal(expected, provided) {
+		return nil, nil, errors.NewUnauthorizedError("Invalid request signature", nil)
+	}
+
+	// Replay protection: each nonce may only be used once within the allowed window
+	nonceKey := fmt.Sprintf("signature_nonce:%s:%s", apiKey.KeyID, req.Nonce)
+	fresh, err := s.cacheRepo.SetIfNotExists(ctx, nonceKey, 1, 2*s.config.Security.SignatureSkew)
+	if err != nil {
+		return nil, nil, errors.NewRedisError("Failed to verify request nonce", err)
+	}
+	if !fresh {
+		return nil, nil, errors.NewUnauthorizedError("Request nonce has already been used", nil)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, apiKey.UserID)
+	if err != nil {
+		return nil, nil, errors.NewUnauthorizedError("User not found", err)
+	}
+	if !user.IsValidForLogin() {
+		return nil, nil, errors.NewUnauthorizedError("Account is deactivated", nil)
+	}
+
+	if err := s.apiKeyRepo.UpdateLastUsed(ctx, apiKey.ID); err != nil {
+		log.Printf("Failed to update API key last used: %v", err)
+	}
+
+	return user, apiKey, nil
+}
+
+// signRequest computes the hex-encoded signature of a request
+func signRequest(secret string, req *models.SignedRequest) string {
+	bodyHash := sha256.Sum256(req.Body)
+	payload := strings.Join([]string{
+		strings.ToUpper(req.Method),
+		req.Path,
+		req.Timestamp,
+		req.Nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) (string, error) {
+	bytes := make([]byte, n)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
@@ -2,11 +2,16 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log"
+	"net"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/hpower2/url-shortener/internal/config"
 	"github.com/hpower2/url-shortener/internal/errors"
 	"github.com/hpower2/url-shortener/internal/models"
 	"github.com/hpower2/url-shortener/internal/repository"
@@ -14,10 +19,14 @@ import (
 
 // AuthService interface defines the contract for authentication operations
 type AuthService interface {
-	Register(ctx context.Context, req *models.RegisterRequest) (*models.LoginResponse, error)
+	Register(ctx context.Context, req *models.RegisterRequest, clientIP string) (*models.LoginResponse, error)
 	Login(ctx context.Context, req *models.LoginRequest) (*models.LoginResponse, error)
 	ValidateToken(tokenString string) (*models.User, error)
-	RefreshToken(ctx context.Context, userID int) (string, error)
+	SlideToken(tokenString string, user *models.User) (string, time.Time, error)
+	RefreshToken(ctx context.Context, refreshToken string) (*models.TokenPair, error)
+	Logout(ctx context.Context, accessToken, refreshToken string) error
+	CleanupExpiredRefreshTokens(ctx context.Context) (int, error)
+	JWKS() *models.JWKS
 	GetUserByID(ctx context.Context, userID int) (*models.User, error)
 	UpdateUser(ctx context.Context, userID int, req *models.UpdateUserRequest) (*models.User, error)
 	ChangePassword(ctx context.Context, userID int, req *models.ChangePasswordRequest) error
@@ -25,27 +34,40 @@ type AuthService interface {
 
 // authService implements AuthService interface
 type authService struct {
-	userRepo  repository.UserRepository
-	jwtSecret []byte
+	userRepo         repository.UserRepository
+	refreshTokenRepo repository.RefreshTokenRepository
+	cacheRepo        repository.CacheRepository
+	config           *config.Config
+	keys             *JWTKeys
 }
 
 // JWTClaims represents JWT token claims
 type JWTClaims struct {
 	UserID int    `json:"user_id"`
 	Email  string `json:"email"`
+
+	// When the user logged in; sliding renewals keep it so sessions can't outlive a refresh token
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+
+	// The refresh token family of the login, so sliding renewals stop once it is revoked
+	SessionID string `json:"sid,omitempty"`
+
 	jwt.RegisteredClaims
 }
 
 // NewAuthService creates a new authentication service
-func NewAuthService(userRepo repository.UserRepository, jwtSecret string) AuthService {
+func NewAuthService(userRepo repository.UserRepository, refreshTokenRepo repository.RefreshTokenRepository, cacheRepo repository.CacheRepository, keys *JWTKeys, config *config.Config) AuthService {
 	return &authService{
-		userRepo:  userRepo,
-		jwtSecret: []byte(jwtSecret),
+		userRepo:         userRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		cacheRepo:        cacheRepo,
+		config:           config,
+		keys:             keys,
 	}
 }
 
 // Register registers a new user
-func (s *authService) Register(ctx context.Context, req *models.RegisterRequest) (*models.LoginResponse, error) {
+func (s *authService) Register(ctx context.Context, req *models.RegisterRequest, clientIP string) (*models.LoginResponse, error) {
 	// Validate request
 	if err := req.Validate(); err != nil {
 		log.Println("Invalid registration data", err)
@@ -63,6 +85,13 @@ func (s *authService) Register(ctx context.Context, req *models.RegisterRequest)
 		return nil, errors.NewAlreadyExistsError("User with this email already exists", nil)
 	}
 
+	// Run anti-abuse checks
+	reviewReasons, err := s.checkSignupAbuse(ctx, req.Email, clientIP)
+	if err != nil {
+		log.Println("Registration rejected by anti-abuse checks", err)
+		return nil, err
+	}
+
 	// Create user
 	user := &models.User{
 		Email:         req.Email,
@@ -73,9 +102,15 @@ func (s *authService) Register(ctx context.Context, req *models.RegisterRequest)
 		EmailVerified: false, // User needs to verify email first
 		LinkCount:     0,
 		LinkLimit:     50,
+		Plan:          models.PlanFree,
+		NeedsReview:   len(reviewReasons) > 0,
+		ReviewReason:  strings.Join(reviewReasons, "; "),
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 	}
+	if clientIP != "" {
+		user.SignupIP = &clientIP
+	}
 
 	// Hash password
 	if err := user.HashPassword(); err != nil {
@@ -88,15 +123,15 @@ func (s *authService) Register(ctx context.Context, req *models.RegisterRequest)
 		return nil, errors.NewDatabaseError("Failed to create user", err)
 	}
 
-	// Generate JWT token
-	token, err := s.generateToken(createdUser)
+	// Start a session
+	tokens, err := s.issueTokens(ctx, createdUser)
 	if err != nil {
-		return nil, errors.NewInternalError("Failed to generate token", err)
+		return nil, err
 	}
 
 	return &models.LoginResponse{
-		User:  createdUser.ToResponse(),
-		Token: token,
+		User:      createdUser.ToResponse(),
+		TokenPair: *tokens,
 	}, nil
 }
 
@@ -107,10 +142,13 @@ func (s *authService) Login(ctx context.Context, req *models.LoginRequest) (*mod
 		return nil, errors.NewValidationError("Invalid login data", err)
 	}
 
-	// Get user by email
-	user, err := s.userRepo.GetByEmail(ctx, req.Email)
+	// Get user by their primary or a verified secondary email
+	user, err := s.userRepo.GetByLoginEmail(ctx, req.Email)
 	if err != nil {
-		return nil, errors.NewNotFoundError("Invalid email or password", nil)
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("Invalid email or password", nil)
+		}
+		return nil, errors.NewDatabaseError("Failed to get user", err)
 	}
 
 	// Check if user is active
@@ -123,37 +161,34 @@ func (s *authService) Login(ctx context.Context, req *models.LoginRequest) (*mod
 		return nil, errors.NewUnauthorizedError("Invalid email or password", nil)
 	}
 
-	// Generate JWT token
-	token, err := s.generateToken(user)
+	// Start a session
+	tokens, err := s.issueTokens(ctx, user)
 	if err != nil {
-		return nil, errors.NewInternalError("Failed to generate token", err)
+		return nil, err
 	}
 
 	return &models.LoginResponse{
-		User:  user.ToResponse(),
-		Token: token,
+		User:      user.ToResponse(),
+		TokenPair: *tokens,
 	}, nil
 }
 
 // ValidateToken validates a JWT token and returns the user
 func (s *authService) ValidateToken(tokenString string) (*models.User, error) {
-	// Parse token
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return s.jwtSecret, nil
-	})
-
+	claims, err := s.parseToken(tokenString)
 	if err != nil {
-		return nil, errors.NewUnauthorizedError("Invalid token", err)
+		return nil, err
 	}
 
-	// Extract claims
-	claims, ok := token.Claims.(*JWTClaims)
-	if !ok || !token.Valid {
-		return nil, errors.NewUnauthorizedError("Invalid token claims", nil)
+	// Reject tokens revoked by logout
+	if claims.ID != "" {
+		revoked, err := s.cacheRepo.Exists(context.Background(), revokedTokenKey(claims.ID))
+		if err != nil {
+			// Fail open so a Redis outage doesn't sign everyone out
+			log.Printf("Failed to check token revocation: %v", err)
+		} else if revoked {
+			return nil, errors.NewUnauthorizedError("Token has been revoked", nil)
+		}
 	}
 
 	// Get user from database
@@ -170,18 +205,211 @@ func (s *authService) ValidateToken(tokenString string) (*models.User, error) {
 	return user, nil
 }
 
-// RefreshToken generates a new JWT token for a user
-func (s *authService) RefreshToken(ctx context.Context, userID int) (string, error) {
-	user, err := s.userRepo.GetByID(ctx, userID)
+// SlideToken renews a validated access token that is within the sliding window
+// of expiring, returning the new token and its expiry. It returns an empty
+// token when sliding sessions are off, the token isn't due, renewing it would
+// keep the session past the refresh token lifetime, or the session was ended
+// by logout or a password change.
+func (s *authService) SlideToken(tokenString string, user *models.User) (string, time.Time, error) {
+	window := s.config.Security.JWTSlidingWindow
+	if window <= 0 {
+		return "", time.Time{}, nil
+	}
+
+	claims, err := s.parseToken(tokenString)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if time.Until(claims.ExpiresAt.Time) > window {
+		return "", time.Time{}, nil
+	}
+
+	authTime := claims.IssuedAt.Time
+	if claims.AuthTime != nil {
+		authTime = claims.AuthTime.Time
+	}
+	if time.Since(authTime)+s.config.Security.JWTExpiration > s.config.Security.RefreshTokenExpiration {
+		return "", time.Time{}, nil
+	}
+
+	// Tokens from before sessions were recorded can't be checked, so they
+	// aren't renewed and the client refreshes instead
+	if claims.SessionID == "" {
+		return "", time.Time{}, nil
+	}
+	active, err := s.refreshTokenRepo.IsFamilyActive(context.Background(), claims.SessionID)
+	if err != nil {
+		return "", time.Time{}, errors.NewDatabaseError("Failed to check session", err)
+	}
+	if !active {
+		return "", time.Time{}, nil
+	}
+
+	token, expiresAt, err := s.signToken(user, claims.SessionID, authTime)
+	if err != nil {
+		return "", time.Time{}, errors.NewInternalError("Failed to renew token", err)
+	}
+	return token, expiresAt, nil
+}
+
+// JWKS returns the public keys access tokens can be verified with
+func (s *authService) JWKS() *models.JWKS {
+	return s.keys.JWKS()
+}
+
+// parseToken verifies an access token's signature, lifetime, issuer and audience
+func (s *authService) parseToken(tokenString string) (*JWTClaims, error) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(s.keys.Algorithms()),
+		jwt.WithIssuer(s.config.Security.JWTIssuer),
+	}
+	if s.config.Security.JWTAudience != "" {
+		options = append(options, jwt.WithAudience(s.config.Security.JWTAudience))
+	}
+
+	claims := &JWTClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.keys.Keyfunc, options...)
+	if err != nil {
+		return nil, errors.NewUnauthorizedError("Invalid token", err)
+	}
+	if !token.Valid {
+		return nil, errors.NewUnauthorizedError("Invalid token claims", nil)
+	}
+	return claims, nil
+}
+
+// RefreshToken exchanges a refresh token for a new access and refresh token.
+// The token used is revoked; presenting it again revokes the whole session,
+// since only a stolen copy would still be in use after a rotation.
+func (s *authService) RefreshToken(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	token, err := s.refreshTokenRepo.GetByHash(ctx, hashRefreshToken(refreshToken))
 	if err != nil {
-		return "", errors.NewNotFoundError("User not found", err)
+		if repository.IsNotFound(err) {
+			return nil, errors.NewUnauthorizedError("Invalid refresh token", nil)
+		}
+		return nil, errors.NewDatabaseError("Failed to get refresh token", err)
 	}
 
+	if token.IsRevoked() {
+		s.revokeReusedFamily(ctx, token)
+		return nil, errors.NewUnauthorizedError("Refresh token has been revoked", nil)
+	}
+	if token.IsExpired() {
+		return nil, errors.NewUnauthorizedError("Refresh token has expired", nil)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, token.UserID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewUnauthorizedError("User not found", nil)
+		}
+		return nil, errors.NewDatabaseError("Failed to get user", err)
+	}
 	if !user.IsValidForLogin() {
-		return "", errors.NewUnauthorizedError("Account is deactivated", nil)
+		return nil, errors.NewUnauthorizedError("Account is deactivated", nil)
+	}
+
+	value, replacement, err := s.newRefreshToken(user.ID, token.FamilyID)
+	if err != nil {
+		return nil, errors.NewInternalError("Failed to generate refresh token", err)
+	}
+	rotated, err := s.refreshTokenRepo.Rotate(ctx, token.ID, replacement)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to rotate refresh token", err)
+	}
+	if !rotated {
+		// Another request used the token between the read and the rotation
+		s.revokeReusedFamily(ctx, token)
+		return nil, errors.NewUnauthorizedError("Refresh token has been revoked", nil)
+	}
+
+	accessToken, expiresAt, err := s.generateToken(user, token.FamilyID)
+	if err != nil {
+		return nil, errors.NewInternalError("Failed to generate token", err)
 	}
 
-	return s.generateToken(user)
+	return &models.TokenPair{
+		Token:            accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     value,
+		RefreshExpiresAt: replacement.ExpiresAt,
+	}, nil
+}
+
+// Logout revokes an access token until it expires and the session of a
+// refresh token; either may be empty. Unknown refresh tokens are ignored so
+// logging out twice succeeds.
+func (s *authService) Logout(ctx context.Context, accessToken, refreshToken string) error {
+	if accessToken == "" && refreshToken == "" {
+		return errors.NewValidationError("An access token or refresh token is required", nil)
+	}
+
+	if accessToken != "" {
+		if err := s.revokeAccessToken(ctx, accessToken); err != nil {
+			return err
+		}
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+	token, err := s.refreshTokenRepo.GetByHash(ctx, hashRefreshToken(refreshToken))
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil
+		}
+		return errors.NewDatabaseError("Failed to get refresh token", err)
+	}
+
+	if _, err := s.refreshTokenRepo.RevokeFamily(ctx, token.FamilyID); err != nil {
+		return errors.NewDatabaseError("Failed to revoke refresh token", err)
+	}
+	return nil
+}
+
+// revokeAccessToken blacklists an access token's ID in Redis until the token
+// expires. Tokens that are already invalid need no revocation.
+func (s *authService) revokeAccessToken(ctx context.Context, accessToken string) error {
+	claims, err := s.parseToken(accessToken)
+	if err != nil || claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+
+	ttl := time.Until(claims.ExpiresAt.Time)
+	if ttl <= 0 {
+		return nil
+	}
+	if err := s.cacheRepo.Set(ctx, revokedTokenKey(claims.ID), "1", ttl); err != nil {
+		return errors.NewRedisError("Failed to revoke access token", err)
+	}
+	return nil
+}
+
+// revokedTokenKey returns the cache key blacklisting an access token ID
+func revokedTokenKey(jti string) string {
+	return fmt.Sprintf("jwt_revoked:%s", jti)
+}
+
+// CleanupExpiredRefreshTokens deletes refresh tokens past their expiration
+func (s *authService) CleanupExpiredRefreshTokens(ctx context.Context) (int, error) {
+	deleted, err := s.refreshTokenRepo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return 0, errors.NewDatabaseError("Failed to delete expired refresh tokens", err)
+	}
+	return deleted, nil
+}
+
+// revokeReusedFamily revokes the session of a refresh token that was presented
+// after it had already been used
+func (s *authService) revokeReusedFamily(ctx context.Context, token *models.RefreshToken) {
+	revoked, err := s.refreshTokenRepo.RevokeFamily(ctx, token.FamilyID)
+	if err != nil {
+		log.Printf("Failed to revoke reused refresh token family for user %d: %v", token.UserID, err)
+		return
+	}
+	if revoked > 0 {
+		log.Printf("Refresh token reuse detected for user %d, revoked %d tokens", token.UserID, revoked)
+	}
 }
 
 // GetUserByID retrieves a user by ID
@@ -207,10 +435,14 @@ func (s *authService) UpdateUser(ctx context.Context, userID int, req *models.Up
 	}
 
 	// Update fields
-	if req.Email != "" {
-		// Check if email is already taken by another user
-		existingUser, err := s.userRepo.GetByEmail(ctx, req.Email)
-		if err == nil && existingUser.ID != userID {
+	if req.Email != "" && req.Email != user.Email {
+		// Check if email is already taken by another user or as a secondary
+		// email (the user's own secondary emails are made primary instead)
+		exists, err := s.userRepo.ExistsByEmail(ctx, req.Email)
+		if err != nil {
+			return nil, errors.NewDatabaseError("Failed to check email", err)
+		}
+		if exists {
 			return nil, errors.NewAlreadyExistsError("Email already in use", nil)
 		}
 		user.Email = req.Email
@@ -221,6 +453,9 @@ func (s *authService) UpdateUser(ctx context.Context, userID int, req *models.Up
 	if req.LastName != "" {
 		user.LastName = req.LastName
 	}
+	if req.Timezone != "" {
+		user.Timezone = req.Timezone
+	}
 	user.UpdatedAt = time.Now()
 
 	// Update user
@@ -262,32 +497,185 @@ func (s *authService) ChangePassword(ctx context.Context, userID int, req *model
 		return errors.NewDatabaseError("Failed to update password", err)
 	}
 
+	// End every session started with the old password
+	if _, err := s.refreshTokenRepo.RevokeAllByUser(ctx, userID); err != nil {
+		return errors.NewDatabaseError("Failed to revoke refresh tokens", err)
+	}
+
 	return nil
 }
 
-// generateToken generates a JWT token for a user
-func (s *authService) generateToken(user *models.User) (string, error) {
+// checkSignupAbuse runs the registration anti-abuse checks. Checks configured
+// to block reject the signup; checks configured to flag return a reason so the
+// account is still created but held for review.
+func (s *authService) checkSignupAbuse(ctx context.Context, email, clientIP string) ([]string, error) {
+	abuse := s.config.Abuse
+	domain := email[strings.LastIndex(email, "@")+1:]
+	var reasons []string
+
+	// Disposable email providers
+	if abuse.DisposableEmailAction != config.AbuseActionOff && s.isDisposableDomain(domain) {
+		if abuse.DisposableEmailAction == config.AbuseActionBlock {
+			return nil, errors.NewValidationError("Disposable email addresses are not allowed", nil)
+		}
+		reasons = append(reasons, "disposable email domain")
+	}
+
+	// Email domain must be able to receive mail
+	if abuse.EmailMXCheckAction != config.AbuseActionOff && !s.hasMXRecords(ctx, domain) {
+		if abuse.EmailMXCheckAction == config.AbuseActionBlock {
+			return nil, errors.NewValidationError("Email domain cannot receive mail", nil)
+		}
+		reasons = append(reasons, "email domain has no MX records")
+	}
+
+	// Per-IP signup velocity
+	if abuse.SignupVelocityAction != config.AbuseActionOff && abuse.SignupVelocityLimit > 0 && clientIP != "" {
+		key := fmt.Sprintf("signup_velocity:%s", clientIP)
+		count, err := s.cacheRepo.IncrementWithExpiry(ctx, key, abuse.SignupVelocityWindow)
+		if err != nil {
+			// Fail open so a Redis outage doesn't block registrations
+			log.Println("Failed to check signup velocity", err)
+		} else if count > int64(abuse.SignupVelocityLimit) {
+			if abuse.SignupVelocityAction == config.AbuseActionBlock {
+				return nil, errors.NewRateLimitError("Too many registrations from this IP address, please try again later", nil)
+			}
+			reasons = append(reasons, "signup velocity exceeded")
+		}
+	}
+
+	return reasons, nil
+}
+
+// isDisposableDomain checks the domain and its parent domains against the
+// disposable list, ignoring case and a trailing dot
+func (s *authService) isDisposableDomain(domain string) bool {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	for candidate := domain; strings.Contains(candidate, "."); candidate = candidate[strings.Index(candidate, ".")+1:] {
+		if disposableEmailDomains[candidate] {
+			return true
+		}
+		for _, extra := range s.config.Abuse.DisposableEmailDomains {
+			if strings.EqualFold(extra, candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasMXRecords checks whether the domain publishes MX records.
+// Lookup failures other than "not found" are treated as passing.
+func (s *authService) hasMXRecords(ctx context.Context, domain string) bool {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	records, err := net.DefaultResolver.LookupMX(ctx, domain)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return false
+		}
+		log.Println("Failed to look up MX records", err)
+		return true
+	}
+	return len(records) > 0
+}
+
+// issueTokens starts a session for a user with a new refresh token family
+func (s *authService) issueTokens(ctx context.Context, user *models.User) (*models.TokenPair, error) {
+	value, refreshToken, err := s.newRefreshToken(user.ID, "")
+	if err != nil {
+		return nil, errors.NewInternalError("Failed to generate refresh token", err)
+	}
+	if _, err := s.refreshTokenRepo.Create(ctx, refreshToken); err != nil {
+		return nil, errors.NewDatabaseError("Failed to store refresh token", err)
+	}
+
+	accessToken, expiresAt, err := s.generateToken(user, refreshToken.FamilyID)
+	if err != nil {
+		return nil, errors.NewInternalError("Failed to generate token", err)
+	}
+
+	return &models.TokenPair{
+		Token:            accessToken,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     value,
+		RefreshExpiresAt: refreshToken.ExpiresAt,
+	}, nil
+}
+
+// newRefreshToken generates a refresh token, returning its value and the record
+// to store. An empty family ID starts a new family.
+func (s *authService) newRefreshToken(userID int, familyID string) (string, *models.RefreshToken, error) {
+	if familyID == "" {
+		var err error
+		if familyID, err = randomHex(16); err != nil {
+			return "", nil, err
+		}
+	}
+
+	value, err := randomHex(32)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	return value, &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashRefreshToken(value),
+		ExpiresAt: now.Add(s.config.Security.RefreshTokenExpiration),
+		CreatedAt: now,
+	}, nil
+}
+
+// hashRefreshToken returns the stored form of a refresh token
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateToken generates a short-lived JWT access token for a user who just
+// authenticated, in the session of the given refresh token family
+func (s *authService) generateToken(user *models.User, sessionID string) (string, time.Time, error) {
+	return s.signToken(user, sessionID, time.Now())
+}
+
+// signToken signs an access token for a session that started at authTime
+func (s *authService) signToken(user *models.User, sessionID string, authTime time.Time) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(s.config.Security.JWTExpiration)
+
+	// A unique ID lets logout revoke this token
+	jti, err := randomHex(16)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate token ID: %w", err)
+	}
+
 	// Create claims
 	claims := &JWTClaims{
-		UserID: user.ID,
-		Email:  user.Email,
+		UserID:    user.ID,
+		Email:     user.Email,
+		AuthTime:  jwt.NewNumericDate(authTime),
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 24 hours
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-			Issuer:    "url-shortener",
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Issuer:    s.config.Security.JWTIssuer,
 			Subject:   fmt.Sprintf("user-%d", user.ID),
+			ID:        jti,
 		},
 	}
+	if s.config.Security.JWTAudience != "" {
+		claims.Audience = jwt.ClaimStrings{s.config.Security.JWTAudience}
+	}
 
-	// Create token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Sign token
-	tokenString, err := token.SignedString(s.jwtSecret)
+	// Sign token with the current key
+	tokenString, err := s.keys.Sign(claims)
 	if err != nil {
-		return "", fmt.Errorf("failed to sign token: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
 	}
 
-	return tokenString, nil
+	return tokenString, expiresAt, nil
 }
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/config"
+	"github.com/hpower2/url-shortener/internal/fetcher"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// canaryCheckTimeout bounds a single health check of a canary destination
+const canaryCheckTimeout = 10 * time.Second
+
+// CanaryMonitor drives canary rollouts: it health-checks each new destination,
+// rolls a rollout back once its checks keep failing, and promotes the new
+// destination when the rollout completes
+type CanaryMonitor struct {
+	urlRepo     repository.URLRepository
+	cacheRepo   repository.CacheRepository
+	webhooks    WebhookService
+	events      URLEventService
+	regions     *repository.RegionRouter
+	fetcher     *fetcher.Fetcher
+	interval    time.Duration
+	maxFailures int
+}
+
+// NewCanaryMonitor creates a monitor that checks rollouts every configured interval
+func NewCanaryMonitor(urlRepo repository.URLRepository, cacheRepo repository.CacheRepository, webhooks WebhookService, events URLEventService, regions *repository.RegionRouter, fetcher *fetcher.Fetcher, cfg *config.AppConfig) *CanaryMonitor {
+	return &CanaryMonitor{
+		urlRepo:     urlRepo,
+		cacheRepo:   cacheRepo,
+		webhooks:    webhooks,
+		events:      events,
+		regions:     regions,
+		fetcher:     fetcher,
+		interval:    cfg.CanaryCheckInterval,
+		maxFailures: cfg.CanaryMaxFailures,
+	}
+}
+
+// Start checks rollouts in the background until ctx is cancelled
+func (m *CanaryMonitor) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.runOnce(ctx)
+			}
+		}
+	}()
+}
+
+// runOnce checks every rollout in progress a single time. Each region's
+// rollouts are checked by one instance per interval, under a lease, so
+// replicas don't add up failures of the same check.
+func (m *CanaryMonitor) runOnce(ctx context.Context) {
+	for _, region := range m.regions.Regions() {
+		regionCtx := repository.WithRegion(ctx, region)
+
+		acquired, err := m.cacheRepo.AcquireLease(regionCtx, "canary-monitor", m.interval)
+		if err != nil {
+			log.Printf("Error acquiring the canary monitor lease in %s: %v", region, err)
+			continue
+		}
+		if !acquired {
+			continue
+		}
+
+		urls, err := m.urlRepo.GetRollingOutCanaries(regionCtx)
+		if err != nil {
+			log.Printf("Error getting canary rollouts: %v", err)
+			continue
+		}
+
+		for i := range urls {
+			m.checkRollout(regionCtx, &urls[i])
+		}
+	}
+}
+
+// checkRollout health-checks one rollout's new destination, then rolls the
+// rollout back or promotes it when due
+func (m *CanaryMonitor) checkRollout(ctx context.Context, url *models.URL) {
+	healthErr := m.checkHealth(ctx, url.CanaryURL)
+	failures, err := m.urlRepo.RecordCanaryCheck(ctx, url.ID, healthErr == nil)
+	if err != nil {
+		if !repository.IsNotFound(err) {
+			log.Printf("Failed to record canary health check of %s: %v", url.ShortCode, err)
+		}
+		// The owner changed or cancelled the rollout meanwhile, or its
+		// failures are unknown; check it again next time
+		return
+	}
+	if healthErr != nil {
+		log.Printf("Canary destination of %s failed its health check (%d/%d): %v", url.ShortCode, failures, m.maxFailures, healthErr)
+	}
+
+	switch {
+	case failures >= m.maxFailures:
+		m.finish(ctx, url, models.CanaryStatusRolledBack)
+	case failures == 0 && !time.Now().Before(*url.CanaryEndsAt):
+		m.finish(ctx, url, models.CanaryStatusPromoted)
+	}
+}
+
+// checkHealth requests a destination, treating errors and 5xx responses as failures
+func (m *CanaryMonitor) checkHealth(ctx context.Context, destination string) error {
+	ctx, cancel := context.WithTimeout(ctx, canaryCheckTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, destination, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := m.fetcher.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// finish ends a rollout, recording a promotion as a destination change
+func (m *CanaryMonitor) finish(ctx context.Context, url *models.URL, status string) {
+	finished, err := m.urlRepo.FinishCanary(ctx, url.ID, status)
+	if err != nil {
+		log.Printf("Failed to finish canary rollout of %s: %v", url.ShortCode, err)
+		return
+	}
+	if !finished {
+		// The owner changed or cancelled the rollout meanwhile
+		return
+	}
+
+	if err := m.cacheRepo.DeleteURL(ctx, url.ShortCode); err != nil {
+		// Log error but keep going
+		log.Printf("Failed to delete URL from cache: %v", err)
+	}
+
+	var destinationChange *models.DestinationChange
+	if status == models.CanaryStatusPromoted {
+		log.Printf("Promoted canary destination of %s", url.ShortCode)
+		destinationChange = &models.DestinationChange{
+			URLID:       url.ID,
+			PreviousURL: url.OriginalURL,
+			NewURL:      url.CanaryURL,
+			CreatedAt:   time.Now(),
+		}
+		if _, err := m.urlRepo.CreateDestinationChange(ctx, destinationChange); err != nil {
+			// Log error but keep going
+			log.Printf("Failed to record destination change: %v", err)
+		}
+		m.events.Record(ctx, url, models.URLEventDestinationChanged, destinationChange)
+	} else {
+		log.Printf("Rolled back canary rollout of %s after %d failed health checks", url.ShortCode, m.maxFailures)
+	}
+
+	updatedURL, err := m.urlRepo.GetByShortCode(ctx, url.ShortCode)
+	if err != nil {
+		log.Printf("Failed to get URL %s: %v", url.ShortCode, err)
+		return
+	}
+	m.webhooks.Dispatch(ctx, updatedURL, models.WebhookEventLinkUpdated, updatedURL)
+	if destinationChange != nil {
+		m.webhooks.Dispatch(ctx, updatedURL, models.WebhookEventLinkDestinationChanged, destinationChange)
+	}
+}
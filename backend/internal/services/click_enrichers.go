@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"github.com/hpower2/url-shortener/internal/config"
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// Enricher adds facts to a click before it is recorded, for example marking
+// clicks from the office network as internal. The built-in enrichers parse the
+// user agent, locate the visitor and score bots; deployments register their
+// own in main, which run after the built-ins in registration order.
+type Enricher interface {
+	// Enrich fills in fields of the click, usually Attributes. The click's IP
+	// address, user agent and referer are the visitor's; the built-ins have
+	// already set its client and location. Errors are logged and the click is
+	// recorded with whatever the enricher set.
+	Enrich(ctx context.Context, url *models.URL, click *models.ClickEvent) error
+}
+
+// EnricherFunc adapts a plain function to Enricher
+type EnricherFunc func(ctx context.Context, url *models.URL, click *models.ClickEvent) error
+
+// Enrich implements Enricher
+func (f EnricherFunc) Enrich(ctx context.Context, url *models.URL, click *models.ClickEvent) error {
+	return f(ctx, url, click)
+}
+
+// userAgentEnricher identifies the visitor's browser, device and operating system
+type userAgentEnricher struct{}
+
+// Enrich implements Enricher
+func (userAgentEnricher) Enrich(ctx context.Context, url *models.URL, click *models.ClickEvent) error {
+	client := models.ParseUserAgent(click.UserAgent)
+	click.Browser, click.Device, click.OS = client.Browser, client.Device, client.OS
+	return nil
+}
+
+// geoEnricher adds the visitor's location, no more precisely than the
+// deployment's geo precision allows
+type geoEnricher struct {
+	precision string
+}
+
+// Enrich implements Enricher
+func (e geoEnricher) Enrich(ctx context.Context, url *models.URL, click *models.ClickEvent) error {
+	location := models.GeoLocationFrom(ctx)
+	switch e.precision {
+	case config.GeoPrecisionCity:
+		click.Country = location.Country
+		click.City = location.City
+	case config.GeoPrecisionCountry:
+		click.Country = location.Country
+	}
+	return nil
+}
+
+// botEnricher counts clients caught probing honeytokens as bots, whatever
+// user agent they claim
+type botEnricher struct {
+	suspects SuspectList
+}
+
+// Enrich implements Enricher
+func (e botEnricher) Enrich(ctx context.Context, url *models.URL, click *models.ClickEvent) error {
+	if click.Device != models.DeviceBot && e.suspects.IsSuspect(ctx, click.IPAddress, models.GeoLocationFrom(ctx).ASN) {
+		click.Browser, click.Device = models.DeviceBot, models.DeviceBot
+	}
+	return nil
+}
+
+// RegisterEnricher appends an enricher to the click pipeline. Enrichers must
+// be registered before the server starts handling requests.
+func (s *urlService) RegisterEnricher(enricher Enricher) {
+	s.enrichers = append(s.enrichers, enricher)
+}
+
+// enrichClick runs the built-in enrichers, then the registered ones, on a click
+func (s *urlService) enrichClick(ctx context.Context, url *models.URL, click *models.ClickEvent) {
+	builtins := []Enricher{
+		userAgentEnricher{},
+		geoEnricher{precision: s.config.App.GeoPrecision},
+		botEnricher{suspects: s.suspects},
+	}
+	for _, enricher := range append(builtins, s.enrichers...) {
+		if err := enricher.Enrich(ctx, url, click); err != nil {
+			log.Printf("Click enricher failed on %s: %v", url.ShortCode, err)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// codePrefixTopLinks is how many of the most clicked links a namespace rollup lists
+const codePrefixTopLinks = 10
+
+// SetCodePrefix reserves a short code prefix for the owner's organization, or
+// releases it. A prefix can't be taken while other users have links using it.
+// The prefix is claimed first, its organization row being unique per prefix,
+// so other users' custom codes are refused from then on; only links created
+// before the claim can conflict, and they release it again.
+func (s *organizationService) SetCodePrefix(ctx context.Context, userID int, req *models.SetCodePrefixRequest) (*models.Organization, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid code prefix", err)
+	}
+
+	org, err := s.getOwnedOrganization(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	claimedAt := time.Now()
+	if err := s.orgRepo.SetCodePrefix(ctx, org.ID, req.Prefix, req.Required); err != nil {
+		if repository.IsDuplicate(err) {
+			return nil, errors.NewAlreadyExistsError("Code prefix is taken by another organization", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to update code prefix", err)
+	}
+
+	if req.Prefix != "" && req.Prefix != org.CodePrefix {
+		if err := s.checkCodeNamespace(ctx, org.ID, req.Prefix+models.CodePrefixSeparator, claimedAt); err != nil {
+			if err := s.orgRepo.SetCodePrefix(ctx, org.ID, org.CodePrefix, org.RequireCodePrefix); err != nil {
+				log.Printf("Failed to release code prefix %s of organization %d: %v", req.Prefix, org.ID, err)
+			}
+			return nil, err
+		}
+	}
+	org.CodePrefix = req.Prefix
+	org.RequireCodePrefix = req.Required
+
+	return org, nil
+}
+
+// checkCodeNamespace checks no users outside an organization had links in a
+// code namespace when it was claimed
+func (s *organizationService) checkCodeNamespace(ctx context.Context, orgID int, namespace string, claimedAt time.Time) error {
+	memberIDs, err := s.memberIDs(ctx, orgID)
+	if err != nil {
+		return err
+	}
+
+	// Links of other users may be stored in any region
+	for _, region := range s.regions.Regions() {
+		conflicts, err := s.urlRepo.CountCodeNamespaceConflicts(repository.WithRegion(ctx, region), namespace, memberIDs, claimedAt)
+		if err != nil {
+			return errors.NewDatabaseError("Failed to check code prefix", err)
+		}
+		if conflicts > 0 {
+			return errors.NewConflictError(fmt.Sprintf("Links of other users already start with %s", namespace), nil)
+		}
+	}
+	return nil
+}
+
+// CheckCustomCode checks a user may take a custom short code: codes in an
+// organization's namespace are only for its members, and members of an
+// organization requiring its prefix must use it
+func (s *organizationService) CheckCustomCode(ctx context.Context, user *models.User, code string) error {
+	if prefix := models.CodePrefixOf(code); prefix != "" {
+		org, err := s.orgRepo.GetByCodePrefix(ctx, prefix)
+		if err != nil && !repository.IsNotFound(err) {
+			return errors.NewDatabaseError("Failed to check code prefix", err)
+		}
+		if err == nil && (user.OrganizationID == nil || *user.OrganizationID != org.ID) {
+			return errors.NewValidationError(fmt.Sprintf("Custom short codes starting with %s are reserved by another organization", org.CodeNamespace()), nil)
+		}
+	}
+
+	if user.OrganizationID == nil {
+		return nil
+	}
+	org, err := s.orgRepo.GetByID(ctx, *user.OrganizationID)
+	if err != nil {
+		return errors.NewDatabaseError("Failed to get organization", err)
+	}
+	if org.RequireCodePrefix && org.CodePrefix != "" && models.CodePrefixOf(code) != org.CodePrefix {
+		return errors.NewValidationError(fmt.Sprintf("Custom short codes of your organization must start with %s", org.CodeNamespace()), nil)
+	}
+	return nil
+}
+
+// GetNamespaceLinks lists the members' links in the user's organization's code namespace
+func (s *organizationService) GetNamespaceLinks(ctx context.Context, userID int, limit, offset int) ([]models.URL, int, error) {
+	org, memberIDs, err := s.getNamespace(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	urls, total, err := s.urlRepo.GetPageByCodeNamespace(ctx, org.CodeNamespace(), memberIDs, limit, offset)
+	if err != nil {
+		return nil, 0, errors.NewDatabaseError("Failed to get namespace links", err)
+	}
+	return urls, total, nil
+}
+
+// GetNamespaceRollup sums up the members' links in the user's organization's code namespace
+func (s *organizationService) GetNamespaceRollup(ctx context.Context, userID int) (*models.CodePrefixRollup, error) {
+	org, memberIDs, err := s.getNamespace(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rollup, err := s.urlRepo.GetCodeNamespaceRollup(ctx, org.CodeNamespace(), memberIDs, codePrefixTopLinks)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to sum up namespace links", err)
+	}
+	return rollup, nil
+}
+
+// getNamespace loads the user's organization, ensuring it has a code prefix,
+// and its members' IDs
+func (s *organizationService) getNamespace(ctx context.Context, userID int) (*models.Organization, []int, error) {
+	org, err := s.GetOrganization(ctx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if org.CodePrefix == "" {
+		return nil, nil, errors.NewNotFoundError("Organization has no code prefix", nil)
+	}
+
+	memberIDs, err := s.memberIDs(ctx, org.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return org, memberIDs, nil
+}
+
+// memberIDs returns the IDs of an organization's members
+func (s *organizationService) memberIDs(ctx context.Context, orgID int) ([]int, error) {
+	members, err := s.orgRepo.GetMembers(ctx, orgID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get organization members", err)
+	}
+
+	ids := make([]int, len(members))
+	for i, member := range members {
+		ids[i] = member.UserID
+	}
+	return ids, nil
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// createPending marks a coalescing key whose link is still being created
+const createPending = "pending"
+
+// createPollInterval is how often a coalesced request checks for the first result
+const createPollInterval = 50 * time.Millisecond
+
+// createMaxBackoff caps the pause before a coalesced request retries taking a
+// key released by a failed first request
+const createMaxBackoff = 500 * time.Millisecond
+
+// coalesceCreate runs create unless the user submitted an identical request
+// within the coalesce window, in which case the first request's link is
+// returned. A Redis key keyed by user and the validated (normalized) request
+// holds "pending" while the first request creates the link, then its response.
+// If Redis is unavailable the link is created without coalescing.
+func (s *urlService) coalesceCreate(ctx context.Context, userID int, req *models.CreateURLRequest, create func() (*models.CreateURLResponse, error)) (*models.CreateURLResponse, error) {
+	window := s.config.App.CreateCoalesceWindow
+	if window <= 0 || models.IsSandbox(ctx) {
+		// Sandbox links are never saved, so they mustn't answer a real request
+		return create()
+	}
+
+	key, err := createCoalesceKey(userID, req)
+	if err != nil {
+		return create()
+	}
+
+	deadline := time.Now().Add(window)
+	backoff := createPollInterval
+	for {
+		acquired, err := s.cacheRepo.SetIfNotExists(ctx, key, createPending, window)
+		if err != nil {
+			log.Printf("Failed to coalesce link creation: %v", err)
+			return create()
+		}
+		if acquired {
+			return s.createAndShare(ctx, key, window, create)
+		}
+
+		value, err := s.cacheRepo.Get(ctx, key)
+		switch {
+		case repository.IsCacheMiss(err):
+			// The first request failed or its result expired; take the key
+			// ourselves after a short, growing pause, so requests racing for
+			// it don't spin on Redis
+			select {
+			case <-ctx.Done():
+				return nil, errors.NewTimeoutError("Link creation cancelled", ctx.Err())
+			case <-time.After(backoff/2 + rand.N(backoff/2)):
+			}
+			backoff = min(2*backoff, createMaxBackoff)
+			continue
+		case err != nil:
+			log.Printf("Failed to coalesce link creation: %v", err)
+			return create()
+		case value != createPending:
+			var response models.CreateURLResponse
+			if err := json.Unmarshal([]byte(value), &response); err != nil {
+				return nil, errors.NewInternalError("Failed to read coalesced link", err)
+			}
+			return &response, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, errors.NewConflictError("An identical link is still being created", nil)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, errors.NewTimeoutError("Link creation cancelled", ctx.Err())
+		case <-time.After(createPollInterval):
+		}
+	}
+}
+
+// createAndShare creates the link and stores the response for identical
+// requests. A failure releases the key so a retry isn't coalesced into it.
+func (s *urlService) createAndShare(ctx context.Context, key string, window time.Duration, create func() (*models.CreateURLResponse, error)) (*models.CreateURLResponse, error) {
+	response, err := create()
+	if err != nil {
+		if delErr := s.cacheRepo.Delete(ctx, key); delErr != nil {
+			log.Printf("Failed to release link creation key: %v", delErr)
+		}
+		return nil, err
+	}
+
+	encoded, err := json.Marshal(response)
+	if err == nil {
+		err = s.cacheRepo.Set(ctx, key, encoded, window)
+	}
+	if err != nil {
+		log.Printf("Failed to share created link: %v", err)
+		s.cacheRepo.Delete(ctx, key)
+	}
+	return response, nil
+}
+
+// createCoalesceKey returns the cache key identifying a user's validated create request
+func createCoalesceKey(userID int, req *models.CreateURLRequest) (string, error) {
+	encoded, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(encoded)
+	return fmt.Sprintf("create_coalesce:%d:%s", userID, hex.EncodeToString(digest[:])), nil
+}
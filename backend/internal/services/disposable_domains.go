@@ -0,0 +1,43 @@
+package services
+
+// disposableEmailDomains is the built-in list of throwaway email providers
+// rejected or flagged at registration. Extend it at deploy time with the
+// DISPOSABLE_EMAIL_DOMAINS environment variable.
+var disposableEmailDomains = map[string]bool{
+	"10minutemail.com":       true,
+	"20minutemail.com":       true,
+	"33mail.com":             true,
+	"burnermail.io":          true,
+	"discard.email":          true,
+	"dispostable.com":        true,
+	"emailondeck.com":        true,
+	"fakeinbox.com":          true,
+	"getairmail.com":         true,
+	"getnada.com":            true,
+	"guerrillamail.com":      true,
+	"guerrillamail.info":     true,
+	"guerrillamail.net":      true,
+	"guerrillamailblock.com": true,
+	"harakirimail.com":       true,
+	"incognitomail.org":      true,
+	"mailcatch.com":          true,
+	"maildrop.cc":            true,
+	"mailinator.com":         true,
+	"mailnesia.com":          true,
+	"mintemail.com":          true,
+	"mohmal.com":             true,
+	"mytemp.email":           true,
+	"sharklasers.com":        true,
+	"spamgourmet.com":        true,
+	"temp-mail.org":          true,
+	"tempail.com":            true,
+	"tempmail.com":           true,
+	"tempmail.net":           true,
+	"tempmailo.com":          true,
+	"tempr.email":            true,
+	"throwawaymail.com":      true,
+	"trashmail.com":          true,
+	"trashmail.de":           true,
+	"yopmail.com":            true,
+	"yopmail.fr":             true,
+}
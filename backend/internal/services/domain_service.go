@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// DomainService interface defines the contract for custom domain operations
+type DomainService interface {
+	AddDomain(ctx context.Context, userID int, req *models.CreateCustomDomainRequest) (*models.CustomDomain, error)
+	GetDomains(ctx context.Context, userID int) ([]models.CustomDomain, error)
+	VerifyDomain(ctx context.Context, id int, userID int) (*models.CustomDomain, error)
+	UpdateErrorPages(ctx context.Context, id int, userID int, req *models.UpdateErrorPagesRequest) (*models.CustomDomain, error)
+	PreviewDeleteDomain(ctx context.Context, id int, userID int) (*models.DomainDeletionImpact, error)
+	DeleteDomain(ctx context.Context, id int, userID int) error
+	GetVerifiedDomain(ctx context.Context, host string) (*models.CustomDomain, error)
+	CheckLinkDomain(ctx context.Context, userID int, hostname string) error
+	DeleteUnverifiedDomains(ctx context.Context) (int64, error)
+}
+
+// domainService implements DomainService interface
+type domainService struct {
+	domainRepo repository.DomainRepository
+	urlRepo    repository.URLRepository
+	prefsRepo  repository.PreferencesRepository
+	regions    *repository.RegionRouter
+	resolver   *net.Resolver
+}
+
+// NewDomainService creates a new custom domain service
+func NewDomainService(domainRepo repository.DomainRepository, urlRepo repository.URLRepository, prefsRepo repository.PreferencesRepository, regions *repository.RegionRouter) DomainService {
+	return &domainService{
+		domainRepo: domainRepo,
+		urlRepo:    urlRepo,
+		prefsRepo:  prefsRepo,
+		regions:    regions,
+		resolver:   net.DefaultResolver,
+	}
+}
+
+// AddDomain registers a custom domain pending DNS verification. Unverified
+// domains don't claim the hostname: other accounts can add it too, and the
+// first to verify it keeps it.
+func (s *domainService) AddDomain(ctx context.Context, userID int, req *models.CreateCustomDomainRequest) (*models.CustomDomain, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid domain request", err)
+	}
+
+	if _, err := s.domainRepo.GetVerifiedByHostname(ctx, req.Hostname); err == nil {
+		return nil, errors.NewAlreadyExistsError("Domain is already registered", nil)
+	} else if !repository.IsNotFound(err) {
+		return nil, errors.NewDatabaseError("Failed to check domain", err)
+	}
+
+	token, err := randomHex(16)
+	if err != nil {
+		return nil, errors.NewInternalError("Failed to generate verification token", err)
+	}
+
+	domain := &models.CustomDomain{
+		UserID:            userID,
+		Hostname:          req.Hostname,
+		VerificationToken: token,
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+	}
+
+	createdDomain, err := s.domainRepo.Create(ctx, domain)
+	if err != nil {
+		if repository.IsDuplicate(err) {
+			return nil, errors.NewAlreadyExistsError("Domain already added", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to create domain", err)
+	}
+
+	return createdDomain, nil
+}
+
+// GetDomains lists the user's custom domains
+func (s *domainService) GetDomains(ctx context.Context, userID int) ([]models.CustomDomain, error) {
+	domains, err := s.domainRepo.GetAllByUser(ctx, userID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get domains", err)
+	}
+	return domains, nil
+}
+
+// VerifyDomain checks the domain's TXT record for its verification token
+func (s *domainService) VerifyDomain(ctx context.Context, id int, userID int) (*models.CustomDomain, error) {
+	domain, err := s.getOwnedDomain(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if domain.IsVerified() {
+		return domain, nil
+	}
+
+	records, err := s.resolver.LookupTXT(ctx, domain.VerificationRecord())
+	if err != nil {
+		return nil, errors.NewValidationError(fmt.Sprintf("No TXT record found at %s", domain.VerificationRecord()), err)
+	}
+
+	verified := false
+	for _, record := range records {
+		if strings.TrimSpace(record) == domain.VerificationToken {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.NewValidationError(fmt.Sprintf("TXT record at %s does not match the verification token", domain.VerificationRecord()), nil)
+	}
+
+	now := time.Now()
+	domain.VerifiedAt = &now
+
+	updatedDomain, err := s.domainRepo.Update(ctx, domain)
+	if err != nil {
+		if repository.IsDuplicate(err) {
+			// Another account proved ownership of the hostname first
+			return nil, errors.NewAlreadyExistsError("Domain is already registered", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to verify domain", err)
+	}
+
+	return updatedDomain, nil
+}
+
+// UpdateErrorPages configures the pages shown for failed redirects on the domain
+func (s *domainService) UpdateErrorPages(ctx context.Context, id int, userID int, req *models.UpdateErrorPagesRequest) (*models.CustomDomain, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid error page settings", err)
+	}
+
+	domain, err := s.getOwnedDomain(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.NotFoundURL != nil {
+		domain.NotFoundURL = *req.NotFoundURL
+	}
+	if req.ExpiredURL != nil {
+		domain.ExpiredURL = *req.ExpiredURL
+	}
+	if req.ErrorHTML != nil {
+		domain.ErrorHTML = *req.ErrorHTML
+	}
+
+	updatedDomain, err := s.domainRepo.Update(ctx, domain)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to update error pages", err)
+	}
+
+	return updatedDomain, nil
+}
+
+// PreviewDeleteDomain describes what deleting one of the user's custom domains changes
+func (s *domainService) PreviewDeleteDomain(ctx context.Context, id int, userID int) (*models.DomainDeletionImpact, error) {
+	domain, err := s.getOwnedDomain(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	impact := &models.DomainDeletionImpact{
+		Hostname:         domain.Hostname,
+		Verified:         domain.IsVerified(),
+		CustomErrorPages: domain.NotFoundURL != "" || domain.ExpiredURL != "" || domain.ErrorHTML != "",
+	}
+	for _, region := range s.regions.Regions() {
+		links, err := s.urlRepo.CountByDomain(repository.WithRegion(ctx, region), userID, domain.Hostname)
+		if err != nil {
+			return nil, errors.NewDatabaseError("Failed to count links on domain", err)
+		}
+		impact.Links += links
+	}
+	return impact, nil
+}
+
+// DeleteDomain removes one of the user's custom domains. Links shared on it,
+// and new links by default, move back to the default domain first.
+func (s *domainService) DeleteDomain(ctx context.Context, id int, userID int) error {
+	domain, err := s.getOwnedDomain(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, region := range s.regions.Regions() {
+		if _, err := s.urlRepo.ClearDomain(repository.WithRegion(ctx, region), userID, domain.Hostname); err != nil {
+			return errors.NewDatabaseError("Failed to move links off domain", err)
+		}
+	}
+	if err := s.prefsRepo.ClearDefaultDomain(ctx, userID, domain.Hostname); err != nil {
+		return errors.NewDatabaseError("Failed to clear default domain", err)
+	}
+
+	if err := s.domainRepo.Delete(ctx, id, userID); err != nil {
+		if repository.IsNotFound(err) {
+			return errors.NewNotFoundError("Domain not found", err)
+		}
+		return errors.NewDatabaseError("Failed to delete domain", err)
+	}
+	return nil
+}
+
+// GetVerifiedDomain returns the verified custom domain serving a request host
+func (s *domainService) GetVerifiedDomain(ctx context.Context, host string) (*models.CustomDomain, error) {
+	domain, err := s.domainRepo.GetVerifiedByHostname(ctx, models.NormalizeHostname(host))
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("Domain not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get domain", err)
+	}
+
+	return domain, nil
+}
+
+// CheckLinkDomain checks that links of the user can be shared on a hostname:
+// it must be one of the user's verified custom domains
+func (s *domainService) CheckLinkDomain(ctx context.Context, userID int, hostname string) error {
+	domain, err := s.domainRepo.GetByUserHostname(ctx, userID, hostname)
+	if err != nil && !repository.IsNotFound(err) {
+		return errors.NewDatabaseError("Failed to get domain", err)
+	}
+	if err != nil {
+		return errors.NewValidationError(fmt.Sprintf("Domain %s is not one of your custom domains", hostname), nil)
+	}
+	if !domain.IsVerified() {
+		return errors.NewValidationError(fmt.Sprintf("Domain %s must be verified before links can use it", hostname), nil)
+	}
+	return nil
+}
+
+// DeleteUnverifiedDomains removes the custom domains that weren't verified in time
+func (s *domainService) DeleteUnverifiedDomains(ctx context.Context) (int64, error) {
+	deleted, err := s.domainRepo.DeleteUnverifiedBefore(ctx, time.Now().Add(-models.UnverifiedCustomDomainTTL))
+	if err != nil {
+		return 0, errors.NewDatabaseError("Failed to delete unverified domains", err)
+	}
+	return deleted, nil
+}
+
+// getOwnedDomain loads a custom domain, ensuring it belongs to the user
+func (s *domainService) getOwnedDomain(ctx context.Context, id int, userID int) (*models.CustomDomain, error) {
+	domain, err := s.domainRepo.GetByID(ctx, id, userID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("Domain not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get domain", err)
+	}
+	return domain, nil
+}
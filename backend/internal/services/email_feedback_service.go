@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/config"
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/fetcher"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// snsConfirmTimeout bounds confirming an SNS subscription
+const snsConfirmTimeout = 10 * time.Second
+
+// otpBounceWindow is how far back a bounce is attributed to OTP emails sent to the address
+const otpBounceWindow = time.Hour
+
+// EmailFeedbackService marks user email addresses undeliverable from the bounce
+// and complaint notifications of email providers
+type EmailFeedbackService interface {
+	Authorize(token string) error
+	HandleSES(ctx context.Context, body []byte) (*models.EmailFeedbackResult, error)
+	HandleSendGrid(ctx context.Context, body []byte) (*models.EmailFeedbackResult, error)
+}
+
+// emailFeedbackService implements EmailFeedbackService interface
+type emailFeedbackService struct {
+	userRepo repository.UserRepository
+	otpRepo  repository.OTPRepository
+	secret   string
+	fetcher  *fetcher.Fetcher
+}
+
+// NewEmailFeedbackService creates a new email feedback service
+func NewEmailFeedbackService(userRepo repository.UserRepository, otpRepo repository.OTPRepository, config *config.SMTPConfig, fetcher *fetcher.Fetcher) EmailFeedbackService {
+	return &emailFeedbackService{
+		userRepo: userRepo,
+		otpRepo:  otpRepo,
+		secret:   config.FeedbackSecret,
+		fetcher:  fetcher,
+	}
+}
+
+// Authorize checks the token providers are configured to send. The endpoints
+// are disabled until a secret is configured.
+func (s *emailFeedbackService) Authorize(token string) error {
+	if s.secret == "" {
+		return errors.NewNotFoundError("Email feedback is not enabled", nil)
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.secret)) != 1 {
+		return errors.NewUnauthorizedError("Invalid email feedback token", nil)
+	}
+	return nil
+}
+
+// HandleSES processes an Amazon SES notification delivered through SNS,
+// confirming the topic subscription when SNS first sends it
+func (s *emailFeedbackService) HandleSES(ctx context.Context, body []byte) (*models.EmailFeedbackResult, error) {
+	var message models.SNSMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, errors.NewBadRequestError("Invalid SNS message", err)
+	}
+
+	notificationBody := body
+	switch message.Type {
+	case models.SNSTypeSubscriptionConfirmation:
+		if err := s.confirmSubscription(ctx, message.SubscribeURL); err != nil {
+			return nil, err
+		}
+		return &models.EmailFeedbackResult{}, nil
+	case models.SNSTypeNotification:
+		notificationBody = []byte(message.Message)
+	case "":
+		// Raw message delivery posts the SES notification itself
+	default:
+		return &models.EmailFeedbackResult{}, nil
+	}
+
+	var notification models.SESNotification
+	if err := json.Unmarshal(notificationBody, &notification); err != nil {
+		return nil, errors.NewBadRequestError("Invalid SES notification", err)
+	}
+
+	return s.apply(ctx, models.EmailProviderSES, sesFeedback(&notification))
+}
+
+// HandleSendGrid processes a batch of SendGrid Event Webhook events
+func (s *emailFeedbackService) HandleSendGrid(ctx context.Context, body []byte) (*models.EmailFeedbackResult, error) {
+	var events []models.SendGridEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, errors.NewBadRequestError("Invalid SendGrid events", err)
+	}
+
+	var feedback []models.EmailFeedback
+	for _, event := range events {
+		switch {
+		// Blocks are usually temporary reputation or content rejections
+		case event.Event == "bounce" && event.Type != "blocked":
+			feedback = append(feedback, models.EmailFeedback{Email: event.Email, Status: models.EmailStatusBounced, Reason: event.Reason})
+		case event.Event == "spamreport":
+			feedback = append(feedback, models.EmailFeedback{Email: event.Email, Status: models.EmailStatusComplained, Reason: "Marked as spam"})
+		}
+	}
+
+	return s.apply(ctx, models.EmailProviderSendGrid, feedback)
+}
+
+// sesFeedback extracts permanent bounces and complaints from an SES notification.
+// Transient bounces (full mailboxes, throttling) don't pause sending.
+func sesFeedback(notification *models.SESNotification) []models.EmailFeedback {
+	kind := notification.NotificationType
+	if kind == "" {
+		kind = notification.EventType
+	}
+
+	var feedback []models.EmailFeedback
+	switch {
+	case kind == "Bounce" && notification.Bounce != nil && notification.Bounce.BounceType == "Permanent":
+		for _, recipient := range notification.Bounce.BouncedRecipients {
+			reason := recipient.DiagnosticCode
+			if reason == "" {
+				reason = "Permanent bounce: " + notification.Bounce.BounceSubType
+			}
+			feedback = append(feedback, models.EmailFeedback{Email: recipient.EmailAddress, Status: models.EmailStatusBounced, Reason: reason})
+		}
+	case kind == "Complaint" && notification.Complaint != nil:
+		reason := "Marked as spam"
+		if notification.Complaint.ComplaintFeedbackType != "" {
+			reason = "Complaint: " + notification.Complaint.ComplaintFeedbackType
+		}
+		for _, recipient := range notification.Complaint.ComplainedRecipients {
+			feedback = append(feedback, models.EmailFeedback{Email: recipient.EmailAddress, Status: models.EmailStatusComplained, Reason: reason})
+		}
+	}
+	return feedback
+}
+
+// apply marks each reported address undeliverable, along with the OTP emails
+// recently sent to a bounced one. Addresses that don't belong to a user are ignored.
+func (s *emailFeedbackService) apply(ctx context.Context, provider string, feedback []models.EmailFeedback) (*models.EmailFeedbackResult, error) {
+	result := &models.EmailFeedbackResult{Received: len(feedback)}
+	for _, item := range feedback {
+		email := strings.ToLower(strings.TrimSpace(item.Email))
+		if email == "" {
+			continue
+		}
+
+		updated, err := s.userRepo.SetEmailStatus(ctx, email, item.Status, item.Reason)
+		if err != nil {
+			return nil, errors.NewDatabaseError("Failed to update email status", err)
+		}
+		if updated {
+			result.Updated++
+			log.Printf("Marked %s as %s from %s feedback: %s", email, item.Status, provider, item.Reason)
+		}
+
+		if item.Status == models.EmailStatusBounced {
+			if _, err := s.otpRepo.MarkBounced(ctx, email, item.Reason, time.Now().Add(-otpBounceWindow)); err != nil {
+				return nil, errors.NewDatabaseError("Failed to update OTP delivery status", err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// confirmSubscription visits an SNS subscription URL. Only AWS hosts are
+// visited so the endpoint can't be used to make arbitrary requests.
+func (s *emailFeedbackService) confirmSubscription(ctx context.Context, subscribeURL string) error {
+	parsed, err := url.Parse(subscribeURL)
+	if err != nil || parsed.Scheme != "https" || !strings.HasSuffix(parsed.Hostname(), ".amazonaws.com") {
+		return errors.NewBadRequestError("Invalid SNS subscribe URL", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, snsConfirmTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return errors.NewInternalError("Failed to confirm SNS subscription", err)
+	}
+	resp, err := s.fetcher.Do(req)
+	if err != nil {
+		return errors.NewExternalServiceError("Failed to confirm SNS subscription", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.NewExternalServiceError("Failed to confirm SNS subscription", fmt.Errorf("SNS responded with status %d", resp.StatusCode))
+	}
+	return nil
+}
@@ -7,14 +7,20 @@ import (
 	"time"
 
 	"github.com/hpower2/url-shortener/internal/config"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
 )
 
 // EmailQueueConsumer handles email queue consumption and processing
 type EmailQueueConsumer struct {
-	rabbitMQService RabbitMQService
-	emailService    EmailService
-	otpService      OTPService
-	config          *config.Config
+	rabbitMQService     RabbitMQService
+	emailService        EmailService
+	otpService          OTPService
+	organizationService OrganizationService
+	config              *config.Config
+
+	cancel context.CancelFunc // Stops consuming
+	done   chan struct{}      // Closed once the consume loop has returned
 }
 
 // NewEmailQueueConsumer creates a new email queue consumer
@@ -22,13 +28,15 @@ func NewEmailQueueConsumer(
 	rabbitMQService RabbitMQService,
 	emailService EmailService,
 	otpService OTPService,
+	organizationService OrganizationService,
 	config *config.Config,
 ) *EmailQueueConsumer {
 	return &EmailQueueConsumer{
-		rabbitMQService: rabbitMQService,
-		emailService:    emailService,
-		otpService:      otpService,
-		config:          config,
+		rabbitMQService:     rabbitMQService,
+		emailService:        emailService,
+		otpService:          otpService,
+		organizationService: organizationService,
+		config:              config,
 	}
 }
 
@@ -42,34 +50,24 @@ func (c *EmailQueueConsumer) Start(ctx context.Context) error {
 	}
 
 	// Start consuming emails
+	consumeCtx, cancel := context.WithCancel(ctx)
+	c.cancel = cancel
+	c.done = make(chan struct{})
 	go func() {
+		defer close(c.done)
 		for {
 			select {
-			case <-ctx.Done():
+			case <-consumeCtx.Done():
 				log.Println("Email queue consumer stopping...")
-				c.rabbitMQService.Close()
 				return
 			default:
-				if err := c.rabbitMQService.ConsumeEmails(c.handleEmailMessage); err != nil {
-					log.Printf("Error consuming emails: %v", err)
-					time.Sleep(5 * time.Second) // Wait before retrying
-				}
 			}
-		}
-	}()
-
-	// Start periodic cleanup of expired OTPs
-	go func() {
-		ticker := time.NewTicker(5 * time.Minute)
-		defer ticker.Stop()
 
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				if err := c.otpService.CleanupExpiredOTPs(context.Background()); err != nil {
-					log.Printf("Error cleaning up expired OTPs: %v", err)
+			if err := c.rabbitMQService.ConsumeEmails(consumeCtx, c.handleEmailMessage); err != nil {
+				log.Printf("Error consuming emails: %v", err)
+				select {
+				case <-consumeCtx.Done():
+				case <-time.After(5 * time.Second): // Wait before retrying
 				}
 			}
 		}
@@ -82,30 +80,86 @@ func (c *EmailQueueConsumer) Start(ctx context.Context) error {
 func (c *EmailQueueConsumer) handleEmailMessage(message *EmailMessage) error {
 	log.Printf("Processing email message: type=%s, to=%s", message.Type, message.To)
 
+	// Members of an organization receive its white-label branding
+	branding := c.organizationService.GetBrandingForRecipient(context.Background(), message.To)
+
 	switch message.Type {
 	case "otp":
-		return c.emailService.SendOTPEmail(message.To, message.OTPCode, message.Purpose)
+		return c.sendOTPEmail(message, branding)
 	case "welcome":
 		// Extract first name from the message or use a default
 		firstName := "User" // You might want to pass this in the message
-		return c.emailService.SendWelcomeEmail(message.To, firstName)
+		return c.emailService.SendWelcomeEmail(message.To, firstName, branding)
 	default:
 		return fmt.Errorf("unknown email type: %s", message.Type)
 	}
 }
 
-// PublishOTPEmail publishes an OTP email to the queue
-func (c *EmailQueueConsumer) PublishOTPEmail(email, otpCode, purpose string) error {
+// sendOTPEmail sends the code of an OTP record and records how its delivery went.
+// Codes that were replaced or verified before the email went out aren't sent.
+func (c *EmailQueueConsumer) sendOTPEmail(message *EmailMessage, branding *models.EmailBranding) error {
+	if message.OTPID == 0 {
+		// Published before delivery tracking, with the code in the message
+		return c.emailService.SendOTPEmail(message.To, message.OTPCode, message.Purpose, branding)
+	}
+
+	ctx := context.Background()
+	otp, err := c.otpService.GetOTP(ctx, message.OTPID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			log.Printf("Skipping OTP email %d to %s: the code was replaced", message.OTPID, message.To)
+			return nil
+		}
+		return err
+	}
+	if otp.IsVerified {
+		return nil
+	}
+
+	if !c.emailService.IsDeliverable(otp.Email) {
+		c.recordOTPDelivery(ctx, otp.ID, models.OTPDeliveryBounced, "Address previously bounced or complained", false)
+		return nil
+	}
+
+	if err := c.emailService.SendOTPEmail(otp.Email, otp.OTPCode, otp.Purpose, branding); err != nil {
+		// The queue gives up once the retries are used up
+		status := models.OTPDeliveryQueued
+		if message.Retry+1 >= message.MaxRetries {
+			status = models.OTPDeliveryFailed
+		}
+		c.recordOTPDelivery(ctx, otp.ID, status, err.Error(), true)
+		return err
+	}
+
+	c.recordOTPDelivery(ctx, otp.ID, models.OTPDeliverySent, "", true)
+	return nil
+}
+
+// recordOTPDelivery records an OTP delivery outcome. Failures are only logged
+// so they never cause an email to be sent twice.
+func (c *EmailQueueConsumer) recordOTPDelivery(ctx context.Context, otpID int, status, detail string, attempted bool) {
+	if err := c.otpService.RecordDelivery(ctx, otpID, status, detail, attempted); err != nil {
+		log.Printf("Failed to record delivery of OTP %d as %s: %v", otpID, status, err)
+	}
+}
+
+// PublishOTPEmail publishes the email delivering an OTP record to the queue.
+// If it can't be queued the record's delivery is marked failed.
+func (c *EmailQueueConsumer) PublishOTPEmail(ctx context.Context, otpID int, email, purpose string) error {
 	message := &EmailMessage{
 		To:         email,
 		Type:       "otp",
-		OTPCode:    otpCode,
+		OTPID:      otpID,
 		Purpose:    purpose,
 		Retry:      0,
 		MaxRetries: 3,
 	}
 
-	return c.rabbitMQService.PublishEmail(message)
+	if err := c.rabbitMQService.PublishEmail(message); err != nil {
+		c.recordOTPDelivery(ctx, otpID, models.OTPDeliveryFailed, "Failed to queue email: "+err.Error(), false)
+		return err
+	}
+	return nil
 }
 
 // PublishWelcomeEmail publishes a welcome email to the queue
@@ -120,7 +174,19 @@ func (c *EmailQueueConsumer) PublishWelcomeEmail(email, firstName string) error
 	return c.rabbitMQService.PublishEmail(message)
 }
 
-// Stop stops the email queue consumer
-func (c *EmailQueueConsumer) Stop() error {
+// Stop stops pulling messages and waits for the ones being handled to finish
+// before closing the RabbitMQ channel and connection. If ctx expires first,
+// the connection is closed anyway and the broker requeues whatever was left
+// unacknowledged.
+func (c *EmailQueueConsumer) Stop(ctx context.Context) error {
+	if c.cancel != nil {
+		c.cancel()
+		select {
+		case <-c.done:
+			log.Println("Email queue consumer stopped")
+		case <-ctx.Done():
+			log.Println("Timed out waiting for in-flight emails; unacknowledged messages will be requeued")
+		}
+	}
 	return c.rabbitMQService.Close()
 }
@@ -1,51 +1,134 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"html"
 	"log"
+	"strings"
 
 	"github.com/hpower2/url-shortener/internal/config"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
 	"gopkg.in/gomail.v2"
 )
 
-// EmailService interface defines the contract for email operations
+// EmailService interface defines the contract for email operations.
+// A nil branding sends the email with the default look and sender.
 type EmailService interface {
-	SendOTPEmail(email, otpCode, purpose string) error
-	SendWelcomeEmail(email, firstName string) error
+	SendOTPEmail(email, otpCode, purpose string, branding *models.EmailBranding) error
+	SendWelcomeEmail(email, firstName string, branding *models.EmailBranding) error
+	SendUsageReportEmail(email, orgName string, report *models.UsageReport, branding *models.EmailBranding) error
+	SendShortCodeChangedEmail(email, oldCode, newShortURL string, branding *models.EmailBranding) error
+	IsDeliverable(email string) bool
 }
 
 // emailService implements EmailService interface
 type emailService struct {
-	config *config.SMTPConfig
+	config   *config.SMTPConfig
+	userRepo repository.UserRepository
 }
 
-// NewEmailService creates a new email service
-func NewEmailService(config *config.SMTPConfig) EmailService {
+// NewEmailService creates a new email service. Emails to users whose address
+// bounced or complained are skipped.
+func NewEmailService(config *config.SMTPConfig, userRepo repository.UserRepository) EmailService {
 	return &emailService{
-		config: config,
+		config:   config,
+		userRepo: userRepo,
 	}
 }
 
 // SendOTPEmail sends an OTP email to the user
-func (s *emailService) SendOTPEmail(email, otpCode, purpose string) error {
+func (s *emailService) SendOTPEmail(email, otpCode, purpose string, branding *models.EmailBranding) error {
+	theme := newEmailTheme(branding)
 	subject := s.getOTPSubject(purpose)
-	body := s.getOTPEmailBody(otpCode, purpose)
+	body := s.getOTPEmailBody(otpCode, purpose, theme)
 
-	return s.sendEmail(email, subject, body)
+	return s.sendEmail(email, subject, body, branding)
 }
 
 // SendWelcomeEmail sends a welcome email to the user
-func (s *emailService) SendWelcomeEmail(email, firstName string) error {
-	subject := "Welcome to URL Shortener!"
-	body := s.getWelcomeEmailBody(firstName)
+func (s *emailService) SendWelcomeEmail(email, firstName string, branding *models.EmailBranding) error {
+	theme := newEmailTheme(branding)
+	subject := fmt.Sprintf("Welcome to %s!", theme.name)
+	body := s.getWelcomeEmailBody(firstName, theme)
 
-	return s.sendEmail(email, subject, body)
+	return s.sendEmail(email, subject, body, branding)
 }
 
-// sendEmail sends an email using SMTP
-func (s *emailService) sendEmail(to, subject, body string) error {
+// SendUsageReportEmail sends an organization's monthly usage report to its owner
+func (s *emailService) SendUsageReportEmail(email, orgName string, report *models.UsageReport, branding *models.EmailBranding) error {
+	theme := newEmailTheme(branding)
+	subject := fmt.Sprintf("%s usage report for %s", orgName, report.Period())
+	body := s.getUsageReportEmailBody(orgName, report, theme)
+
+	return s.sendEmail(email, subject, body, branding)
+}
+
+// SendShortCodeChangedEmail tells a link owner that their short code was moved
+// because the path is now used by the application
+func (s *emailService) SendShortCodeChangedEmail(email, oldCode, newShortURL string, branding *models.EmailBranding) error {
+	theme := newEmailTheme(branding)
+	subject := fmt.Sprintf("Your short link /%s has moved", oldCode)
+	body := s.getShortCodeChangedEmailBody(oldCode, newShortURL, theme)
+
+	return s.sendEmail(email, subject, body, branding)
+}
+
+// emailTheme holds the values substituted into email templates
+type emailTheme struct {
+	name         string
+	header       string
+	primaryColor string
+	accentColor  string
+}
+
+// newEmailTheme builds the template values from an organization's branding
+func newEmailTheme(branding *models.EmailBranding) emailTheme {
+	theme := emailTheme{
+		name:         models.DefaultEmailFromName,
+		primaryColor: models.DefaultEmailPrimaryColor,
+		accentColor:  models.DefaultEmailAccentColor,
+	}
+	if branding != nil {
+		if branding.FromName != "" {
+			theme.name = branding.FromName
+		}
+		if branding.PrimaryColor != "" {
+			theme.primaryColor = branding.PrimaryColor
+		}
+		if branding.AccentColor != "" {
+			theme.accentColor = branding.AccentColor
+		}
+	}
+
+	theme.header = fmt.Sprintf("<h1>%s</h1>", html.EscapeString(theme.name))
+	if branding != nil && branding.LogoURL != "" {
+		theme.header = fmt.Sprintf(`<img src="%s" alt="%s" style="max-height: 60px;">`,
+			html.EscapeString(branding.LogoURL), html.EscapeString(theme.name))
+	}
+
+	return theme
+}
+
+// sendEmail sends an email using SMTP. Organizations send from their own address
+// once its domain is verified; otherwise only their name is used with ours.
+func (s *emailService) sendEmail(to, subject, body string, branding *models.EmailBranding) error {
+	if !s.IsDeliverable(to) {
+		// Not an error, so queued emails aren't retried
+		log.Printf("Skipping email to undeliverable address %s", to)
+		return nil
+	}
+
 	m := gomail.NewMessage()
-	m.SetHeader("From", s.config.From)
+	switch {
+	case branding != nil && branding.FromVerified():
+		m.SetAddressHeader("From", branding.FromAddress, newEmailTheme(branding).name)
+	case branding != nil && branding.FromName != "":
+		m.SetAddressHeader("From", s.config.From, branding.FromName)
+	default:
+		m.SetHeader("From", s.config.From)
+	}
 	m.SetHeader("To", to)
 	m.SetHeader("Subject", subject)
 	m.SetBody("text/html", body)
@@ -62,6 +145,16 @@ func (s *emailService) sendEmail(to, subject, body string) error {
 	return nil
 }
 
+// IsDeliverable reports whether an address may be emailed. Addresses that
+// aren't a user's, or can't be looked up, are.
+func (s *emailService) IsDeliverable(email string) bool {
+	user, err := s.userRepo.GetByEmail(context.Background(), strings.ToLower(email))
+	if err != nil {
+		return true
+	}
+	return user.EmailDeliverable()
+}
+
 // getOTPSubject returns the subject based on purpose
 func (s *emailService) getOTPSubject(purpose string) string {
 	switch purpose {
@@ -69,19 +162,23 @@ func (s *emailService) getOTPSubject(purpose string) string {
 		return "Verify Your Email Address"
 	case "password_reset":
 		return "Reset Your Password"
+	case models.OTPPurposeEmailAlias:
+		return "Verify Your New Email Address"
 	default:
 		return "Verification Code"
 	}
 }
 
 // getOTPEmailBody returns the HTML email body for OTP
-func (s *emailService) getOTPEmailBody(otpCode, purpose string) string {
+func (s *emailService) getOTPEmailBody(otpCode, purpose string, theme emailTheme) string {
 	var message string
 	switch purpose {
 	case "email_verification":
 		message = "Please use the following code to verify your email address:"
 	case "password_reset":
 		message = "Please use the following code to reset your password:"
+	case models.OTPPurposeEmailAlias:
+		message = "Please use the following code to add this email address to your account:"
 	default:
 		message = "Please use the following verification code:"
 	}
@@ -99,11 +196,11 @@ func (s *emailService) getOTPEmailBody(otpCode, purpose string) string {
         .otp-code { 
             font-size: 32px; 
             font-weight: bold; 
-            color: #007bff; 
+            color: %[3]s; 
             text-align: center; 
             padding: 20px; 
-            background-color: #f8f9fa; 
-            border: 2px dashed #007bff; 
+            background-color: %[4]s; 
+            border: 2px dashed %[3]s; 
             margin: 20px 0; 
             letter-spacing: 5px;
         }
@@ -125,14 +222,14 @@ func (s *emailService) getOTPEmailBody(otpCode, purpose string) string {
 <body>
     <div class="container">
         <div class="header">
-            <h1>URL Shortener</h1>
+            %[5]s
         </div>
         
         <p>Hello,</p>
         
-        <p>%s</p>
+        <p>%[1]s</p>
         
-        <div class="otp-code">%s</div>
+        <div class="otp-code">%[2]s</div>
         
         <div class="warning">
             <strong>Important:</strong> This code will expire in 10 minutes. 
@@ -142,30 +239,30 @@ func (s *emailService) getOTPEmailBody(otpCode, purpose string) string {
         <p>If you didn't request this code, please ignore this email.</p>
         
         <div class="footer">
-            <p>This is an automated message from URL Shortener.<br>
+            <p>This is an automated message from %[6]s.<br>
             Please do not reply to this email.</p>
         </div>
     </div>
 </body>
 </html>
-`, message, otpCode)
+`, message, otpCode, theme.primaryColor, theme.accentColor, theme.header, html.EscapeString(theme.name))
 }
 
 // getWelcomeEmailBody returns the HTML email body for welcome message
-func (s *emailService) getWelcomeEmailBody(firstName string) string {
+func (s *emailService) getWelcomeEmailBody(firstName string, theme emailTheme) string {
 	return fmt.Sprintf(`
 <!DOCTYPE html>
 <html>
 <head>
     <meta charset="UTF-8">
-    <title>Welcome to URL Shortener</title>
+    <title>Welcome to %[2]s</title>
     <style>
         body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
         .container { max-width: 600px; margin: 0 auto; padding: 20px; }
         .header { text-align: center; margin-bottom: 30px; }
         .welcome { 
-            background-color: #d4edda; 
-            border: 1px solid #c3e6cb; 
+            background-color: %[4]s; 
+            border: 1px solid %[3]s; 
             padding: 20px; 
             margin: 20px 0; 
             border-radius: 5px; 
@@ -182,11 +279,11 @@ func (s *emailService) getWelcomeEmailBody(firstName string) string {
 <body>
     <div class="container">
         <div class="header">
-            <h1>URL Shortener</h1>
+            %[5]s
         </div>
         
         <div class="welcome">
-            <h2>Welcome, %s!</h2>
+            <h2>Welcome, %[1]s!</h2>
             <p>Your email has been successfully verified and your account is now active.</p>
         </div>
         
@@ -198,14 +295,138 @@ func (s *emailService) getWelcomeEmailBody(firstName string) string {
             <li>Manage your URLs</li>
         </ul>
         
-        <p>Thank you for choosing URL Shortener!</p>
+        <p>Thank you for choosing %[2]s!</p>
+        
+        <div class="footer">
+            <p>This is an automated message from %[2]s.<br>
+            Please do not reply to this email.</p>
+        </div>
+    </div>
+</body>
+</html>
+`, html.EscapeString(firstName), html.EscapeString(theme.name), theme.primaryColor, theme.accentColor, theme.header)
+}
+
+// getUsageReportEmailBody returns the HTML email body for a monthly usage report
+func (s *emailService) getUsageReportEmailBody(orgName string, report *models.UsageReport, theme emailTheme) string {
+	return fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Usage report</title>
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { text-align: center; margin-bottom: 30px; }
+        .report { 
+            background-color: %[4]s; 
+            border: 1px solid %[3]s; 
+            padding: 20px; 
+            margin: 20px 0; 
+            border-radius: 5px; 
+        }
+        .report td { padding: 4px 12px; }
+        .footer { 
+            text-align: center; 
+            margin-top: 30px; 
+            font-size: 12px; 
+            color: #666; 
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            %[5]s
+        </div>
+        
+        <h2>%[1]s usage for %[6]s</h2>
+        
+        <div class="report">
+            <table>
+                <tr><td>Links created</td><td><strong>%[7]d</strong></td></tr>
+                <tr><td>Clicks served</td><td><strong>%[8]d</strong></td></tr>
+                <tr><td>API calls</td><td><strong>%[9]d</strong></td></tr>
+                <tr><td>Storage</td><td><strong>%[10]s</strong></td></tr>
+            </table>
+        </div>
         
         <div class="footer">
-            <p>This is an automated message from URL Shortener.<br>
+            <p>This is an automated message from %[2]s.<br>
             Please do not reply to this email.</p>
         </div>
     </div>
 </body>
 </html>
-`, firstName)
+`, html.EscapeString(orgName), html.EscapeString(theme.name), theme.primaryColor, theme.accentColor, theme.header,
+		report.Period(), report.LinksCreated, report.ClicksServed, report.APICalls, formatBytes(report.StorageBytes))
+}
+
+// getShortCodeChangedEmailBody returns the HTML email body for a migrated short code
+func (s *emailService) getShortCodeChangedEmailBody(oldCode, newShortURL string, theme emailTheme) string {
+	return fmt.Sprintf(`
+<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Your short link has moved</title>
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; color: #333; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { text-align: center; margin-bottom: 30px; }
+        .notice { 
+            background-color: %[4]s; 
+            border: 1px solid %[3]s; 
+            padding: 20px; 
+            margin: 20px 0; 
+            border-radius: 5px; 
+            text-align: center;
+        }
+        .footer { 
+            text-align: center; 
+            margin-top: 30px; 
+            font-size: 12px; 
+            color: #666; 
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header">
+            %[5]s
+        </div>
+        
+        <p>The path <strong>/%[1]s</strong> is now used by %[2]s itself, so your short link could no longer be reached there.</p>
+        
+        <div class="notice">
+            <p>Your link now lives at</p>
+            <h2><a href="%[6]s">%[6]s</a></h2>
+        </div>
+        
+        <p>Its destination, settings and analytics are unchanged. Please update any places where you shared the old link.</p>
+        
+        <div class="footer">
+            <p>This is an automated message from %[2]s.<br>
+            Please do not reply to this email.</p>
+        </div>
+    </div>
+</body>
+</html>
+`, html.EscapeString(oldCode), html.EscapeString(theme.name), theme.primaryColor, theme.accentColor, theme.header,
+		html.EscapeString(newShortURL))
+}
+
+// formatBytes renders a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
 }
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// CreateGuestEditLink mints a guest edit link for a user's link and returns its token once
+func (s *urlService) CreateGuestEditLink(ctx context.Context, shortCode string, req *models.CreateGuestEditLinkRequest, userID int) (*models.CreateGuestEditLinkResponse, error) {
+	ttl, err := req.Validate()
+	if err != nil {
+		return nil, errors.NewValidationError("Invalid guest edit link request", err)
+	}
+
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := randomHex(24)
+	if err != nil {
+		return nil, errors.NewInternalError("Failed to generate guest edit link", err)
+	}
+
+	now := time.Now()
+	link, err := s.urlRepo.CreateGuestEditLink(ctx, &models.GuestEditLink{
+		URLID:       url.ID,
+		Name:        req.Name,
+		TokenHash:   hashShareToken(token),
+		TokenPrefix: token[:8],
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+	})
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to create guest edit link", err)
+	}
+
+	return &models.CreateGuestEditLinkResponse{
+		GuestEditLink: *link,
+		Token:         token,
+		EditURL:       fmt.Sprintf("%s/guest-edit/%s", s.config.App.FrontendURL, token),
+	}, nil
+}
+
+// GetGuestEditLinks lists the guest edit links of a user's link
+func (s *urlService) GetGuestEditLinks(ctx context.Context, shortCode string, userID int) ([]models.GuestEditLink, error) {
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := s.urlRepo.GetGuestEditLinks(ctx, url.ID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get guest edit links", err)
+	}
+	return links, nil
+}
+
+// RevokeGuestEditLink stops an unused guest edit link from editing a user's link
+func (s *urlService) RevokeGuestEditLink(ctx context.Context, shortCode string, id int, userID int) error {
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.urlRepo.RevokeGuestEditLink(ctx, url.ID, id); err != nil {
+		if repository.IsNotFound(err) {
+			return errors.NewNotFoundError("Guest edit link not found or already used", err)
+		}
+		return errors.NewDatabaseError("Failed to revoke guest edit link", err)
+	}
+	return nil
+}
+
+// GetGuestEdit shows the holder of a guest edit link the link it edits
+func (s *urlService) GetGuestEdit(ctx context.Context, token string) (*models.GuestEditView, error) {
+	ctx, link, err := s.openGuestEditLink(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := s.getGuestEditURL(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	return s.guestEditView(url, link), nil
+}
+
+// GuestEdit changes a link's destination through a guest edit link, using it
+// up. The new destination is screened as if the owner had set it, and the
+// change is recorded in the link's destination history.
+func (s *urlService) GuestEdit(ctx context.Context, token string, req *models.GuestEditRequest) (*models.GuestEditView, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid destination", err)
+	}
+
+	ctx, link, err := s.openGuestEditLink(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	url, err := s.getGuestEditURL(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	if req.OriginalURL == url.OriginalURL {
+		// Don't use up the link on a change that changes nothing
+		return nil, errors.NewValidationError("The link already goes to this destination", nil)
+	}
+
+	owner, err := s.userRepo.GetByID(ctx, url.UserID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get user", err)
+	}
+	needsReview, err := s.checkDestination(ctx, owner, req.OriginalURL)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	used, err := s.urlRepo.UseGuestEditLink(ctx, link.ID, now)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to use guest edit link", err)
+	}
+	if !used {
+		return nil, errors.NewConflictError("Guest edit link has already been used", nil)
+	}
+
+	destinationChange := &models.DestinationChange{
+		URLID:           url.ID,
+		GuestEditLinkID: &link.ID,
+		PreviousURL:     url.OriginalURL,
+		NewURL:          req.OriginalURL,
+		HeldForReview:   needsReview,
+		CreatedAt:       now,
+	}
+	wasActive := url.IsActive
+	url.OriginalURL = req.OriginalURL
+	// The previous destination's metadata no longer applies
+	url.LinkMetadata = models.LinkMetadata{}
+	if needsReview {
+		url.Deactivate()
+		url.NeedsReview = true
+	}
+	url.UpdatedAt = now
+
+	updatedURL, err := s.urlRepo.Update(ctx, url)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to update URL", err)
+	}
+
+	// The cached destination is stale now
+	if err := s.cacheRepo.DeleteURL(ctx, updatedURL.ShortCode); err != nil {
+		// Log error but don't fail the request
+		log.Printf("Failed to delete URL from cache: %v", err)
+	}
+
+	if _, err := s.urlRepo.CreateDestinationChange(ctx, destinationChange); err != nil {
+		// Log error but don't fail the request
+		log.Printf("Failed to record destination change: %v", err)
+	}
+	s.webhooks.Dispatch(ctx, updatedURL, models.WebhookEventLinkUpdated, updatedURL)
+	s.webhooks.Dispatch(ctx, updatedURL, models.WebhookEventLinkDestinationChanged, destinationChange)
+	s.events.Record(ctx, updatedURL, models.URLEventDestinationChanged, destinationChange)
+	if wasActive && !updatedURL.IsActive {
+		s.recordStatusChange(ctx, updatedURL, models.URLEventReasonReview)
+	}
+
+	return s.guestEditView(updatedURL, link), nil
+}
+
+// openGuestEditLink finds a usable guest edit link by its token. The token
+// doesn't say which data region holds the link, so each region is checked;
+// the returned context is bound to the one that does.
+func (s *urlService) openGuestEditLink(ctx context.Context, token string) (context.Context, *models.GuestEditLink, error) {
+	if token == "" {
+		return nil, nil, errors.NewNotFoundError("Guest edit link not found", nil)
+	}
+
+	tokenHash := hashShareToken(token)
+	for _, region := range s.regions.Regions() {
+		regionCtx := repository.WithRegion(ctx, region)
+		link, err := s.urlRepo.GetGuestEditLinkByHash(regionCtx, tokenHash)
+		if err != nil {
+			if repository.IsNotFound(err) {
+				continue
+			}
+			return nil, nil, errors.NewDatabaseError("Failed to get guest edit link", err)
+		}
+
+		switch {
+		case link.RevokedAt != nil:
+			return nil, nil, errors.NewNotFoundError("Guest edit link not found", nil)
+		case link.UsedAt != nil:
+			return nil, nil, errors.NewConflictError("Guest edit link has already been used", nil)
+		case !link.IsUsable(time.Now()):
+			return nil, nil, errors.NewExpiredError("Guest edit link has expired", nil)
+		}
+		return regionCtx, link, nil
+	}
+	return nil, nil, errors.NewNotFoundError("Guest edit link not found", nil)
+}
+
+// getGuestEditURL loads the link a guest edit link edits. Links their owner
+// just killed can't be edited, so a guest can't point them somewhere new.
+func (s *urlService) getGuestEditURL(ctx context.Context, link *models.GuestEditLink) (*models.URL, error) {
+	url, err := s.urlRepo.GetByID(ctx, link.URLID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("URL not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get URL", err)
+	}
+	if s.isKilled(url.ShortCode) {
+		return nil, errors.NewInactiveError("URL is not active", nil)
+	}
+	return url, nil
+}
+
+// guestEditView returns what a guest edit link shows about its link
+func (s *urlService) guestEditView(url *models.URL, link *models.GuestEditLink) *models.GuestEditView {
+	return &models.GuestEditView{
+		ShortCode:   url.ShortCode,
+		ShortURL:    url.ShortURL(s.baseURL),
+		OriginalURL: url.OriginalURL,
+		Name:        link.Name,
+		ExpiresAt:   link.ExpiresAt,
+	}
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// TripHoneytoken reports whether a short code is a honeytoken. Honeytokens are
+// never published, so their visitors are put on the suspicion list, which the
+// rate limiter and click classification consult.
+func (s *urlService) TripHoneytoken(ctx context.Context, shortCode, clientIP, userAgent, referer string) bool {
+	if !s.routes.IsHoneytoken(shortCode) {
+		return false
+	}
+
+	hit := &models.HoneytokenHit{
+		Code:      shortCode,
+		IPAddress: clientIP,
+		ASN:       models.GeoLocationFrom(ctx).ASN,
+		UserAgent: userAgent,
+		Referer:   referer,
+		CreatedAt: time.Now(),
+	}
+	log.Printf("Honeytoken %s visited from %s (AS%s)", shortCode, clientIP, hit.ASN)
+	s.suspects.Flag(ctx, hit)
+	return true
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/hpower2/url-shortener/internal/config"
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// JWT signing algorithms
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmEdDSA = "EdDSA"
+)
+
+// jwtKey is a key access tokens are signed or verified with. HMAC keys have
+// no ID and are never published.
+type jwtKey struct {
+	id      string
+	method  jwt.SigningMethod
+	signing interface{} // Private key or HMAC secret; nil for verify-only keys
+	verify  interface{} // Public key or HMAC secret
+}
+
+// JWTKeys holds the key new access tokens are signed with and the previous
+// key, which still verifies tokens issued before a rotation
+type JWTKeys struct {
+	current  *jwtKey
+	previous *jwtKey
+}
+
+// LoadJWTKeys loads the configured signing keys. HS256 uses JWT_SECRET and
+// JWT_PREVIOUS_SECRET; RS256 and EdDSA read PEM files.
+func LoadJWTKeys(cfg *config.SecurityConfig) (*JWTKeys, error) {
+	keys := &JWTKeys{}
+	switch cfg.JWTAlgorithm {
+	case JWTAlgorithmHS256:
+		secret := []byte(cfg.JWTSecret)
+		keys.current = &jwtKey{method: jwt.SigningMethodHS256, signing: secret, verify: secret}
+		if cfg.JWTPreviousSecret != "" {
+			previous := []byte(cfg.JWTPreviousSecret)
+			keys.previous = &jwtKey{method: jwt.SigningMethodHS256, signing: previous, verify: previous}
+		}
+	case JWTAlgorithmRS256, JWTAlgorithmEdDSA:
+		current, err := loadJWTKeyFile(cfg.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load JWT private key: %w", err)
+		}
+		if current.signing == nil {
+			return nil, fmt.Errorf("JWT private key file %s holds a public key", cfg.JWTPrivateKeyFile)
+		}
+		if current.method.Alg() != cfg.JWTAlgorithm {
+			return nil, fmt.Errorf("JWT private key is for %s, not %s", current.method.Alg(), cfg.JWTAlgorithm)
+		}
+		keys.current = current
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", cfg.JWTAlgorithm)
+	}
+
+	// The previous key may use another algorithm, so the algorithm can be changed by rotating
+	if cfg.JWTPreviousKeyFile != "" {
+		previous, err := loadJWTKeyFile(cfg.JWTPreviousKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load previous JWT key: %w", err)
+		}
+		keys.previous = previous
+	}
+	return keys, nil
+}
+
+// Sign signs claims with the current key
+func (k *JWTKeys) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.current.method, claims)
+	if k.current.id != "" {
+		token.Header["kid"] = k.current.id
+	}
+	return token.SignedString(k.current.signing)
+}
+
+// Algorithms returns the algorithms of the keys tokens may be verified with
+func (k *JWTKeys) Algorithms() []string {
+	algorithms := []string{k.current.method.Alg()}
+	if k.previous != nil && k.previous.method.Alg() != algorithms[0] {
+		algorithms = append(algorithms, k.previous.method.Alg())
+	}
+	return algorithms
+}
+
+// Keyfunc returns the key a token is verified with: the key named by its kid
+// header, or otherwise every key of the token's algorithm
+func (k *JWTKeys) Keyfunc(token *jwt.Token) (interface{}, error) {
+	candidates := jwt.VerificationKeySet{}
+	kid, _ := token.Header["kid"].(string)
+	for _, key := range []*jwtKey{k.current, k.previous} {
+		if key == nil || key.method.Alg() != token.Method.Alg() {
+			continue
+		}
+		if kid != "" && key.id == kid {
+			return key.verify, nil
+		}
+		if kid == "" {
+			candidates.Keys = append(candidates.Keys, key.verify)
+		}
+	}
+	if len(candidates.Keys) == 0 {
+		return nil, fmt.Errorf("no key to verify token with")
+	}
+	return candidates, nil
+}
+
+// JWKS returns the public keys of the current and previous asymmetric keys
+func (k *JWTKeys) JWKS() *models.JWKS {
+	jwks := &models.JWKS{Keys: []models.JWK{}}
+	for _, key := range []*jwtKey{k.current, k.previous} {
+		if key == nil || key.id == "" {
+			continue
+		}
+		if jwk, ok := publicJWK(key.verify); ok {
+			jwk.Kid = key.id
+			jwk.Use = "sig"
+			jwk.Alg = key.method.Alg()
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
+}
+
+// loadJWTKeyFile loads an RSA or Ed25519 key from a PEM file. A public key
+// can only verify tokens.
+func loadJWTKeyFile(path string) (*jwtKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+
+	var signing, public interface{}
+	if private, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signing = private
+		public = private.(crypto.Signer).Public()
+	} else if private, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		signing = private
+		public = private.Public()
+	} else if public, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		return nil, fmt.Errorf("unrecognized key in %s", path)
+	}
+
+	key := &jwtKey{signing: signing, verify: public}
+	switch public.(type) {
+	case *rsa.PublicKey:
+		key.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("key in %s is neither RSA nor Ed25519", path)
+	}
+
+	jwk, _ := publicJWK(public)
+	key.id = jwkThumbprint(jwk)
+	return key, nil
+}
+
+// publicJWK returns the JWK members describing a public key
+func publicJWK(public interface{}) (models.JWK, bool) {
+	switch public := public.(type) {
+	case *rsa.PublicKey:
+		return models.JWK{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return models.JWK{Kty: "OKP", Crv: "Ed25519", X: base64.RawURLEncoding.EncodeToString(public)}, true
+	}
+	return models.JWK{}, false
+}
+
+// jwkThumbprint returns the RFC 7638 thumbprint of a key, used as its key ID
+// so it changes whenever the key does
+func jwkThumbprint(jwk models.JWK) string {
+	// The required members, in lexicographic order
+	var members interface{}
+	if jwk.Kty == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+	encoded, _ := json.Marshal(members)
+	sum := sha256.Sum256(encoded)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// KillURL disables a link so that it stops redirecting on every instance
+// within seconds, and reports how far that got. The link is saved as inactive,
+// its cached destination is replaced by a short-lived tombstone that cache
+// refills can't overwrite, and the kill is broadcast so each instance refuses
+// the code before even asking the cache.
+func (s *urlService) KillURL(ctx context.Context, shortCode string, userID int) (*models.KillSwitchResult, error) {
+	started := time.Now()
+
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.KillSwitchResult{
+		ShortCode:          shortCode,
+		TombstoneExpiresAt: started.Add(models.KillSwitchTombstoneTTL),
+	}
+
+	// A link already deactivated on expiry or by its click limit is updated too,
+	// so a new expiration or limit can't bring it back
+	if wasActive := url.IsActive; wasActive || url.ExpiryDeactivated || url.ClickLimitDeactivated {
+		url.Deactivate()
+		url.UpdatedAt = time.Now()
+		if _, err := s.urlRepo.Update(ctx, url); err != nil {
+			return nil, errors.NewDatabaseError("Failed to disable URL", err)
+		}
+		if wasActive {
+			s.webhooks.Dispatch(ctx, url, models.WebhookEventLinkUpdated, url)
+			s.recordStatusChange(ctx, url, models.URLEventReasonKillSwitch)
+		}
+	}
+	result.Disabled = true
+
+	if err := s.cacheRepo.TombstoneURL(ctx, shortCode, models.KillSwitchTombstoneTTL); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("cache: %v", err))
+	} else {
+		result.CacheCleared = true
+	}
+
+	s.markKilled(shortCode)
+	notified, err := s.cacheRepo.PublishLinkKill(ctx, shortCode)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("broadcast: %v", err))
+	}
+	result.InstancesNotified = notified
+
+	result.Propagated = result.CacheCleared && notified > 0
+	result.ElapsedMS = time.Since(started).Milliseconds()
+	if !result.Propagated {
+		log.Printf("Kill switch for %s did not fully propagate: %v", shortCode, result.Errors)
+	}
+	return result, nil
+}
+
+// ListenForKills records the links killed on any instance until ctx is cancelled
+func (s *urlService) ListenForKills(ctx context.Context) {
+	s.cacheRepo.SubscribeLinkKills(ctx, s.markKilled)
+}
+
+// markKilled makes this instance refuse a short code until the kill's tombstone expires
+func (s *urlService) markKilled(shortCode string) {
+	now := time.Now()
+
+	s.killedMu.Lock()
+	defer s.killedMu.Unlock()
+
+	for code, expiresAt := range s.killed {
+		if now.After(expiresAt) {
+			delete(s.killed, code)
+		}
+	}
+	s.killed[shortCode] = now.Add(models.KillSwitchTombstoneTTL)
+}
+
+// isKilled reports whether a short code was killed within the tombstone TTL
+func (s *urlService) isKilled(shortCode string) bool {
+	s.killedMu.Lock()
+	defer s.killedMu.Unlock()
+
+	expiresAt, ok := s.killed[shortCode]
+	return ok && time.Now().Before(expiresAt)
+}
@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	neturl "net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/fetcher"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+)
+
+// metadataFetchTimeout bounds fetching a single destination's metadata
+const metadataFetchTimeout = 10 * time.Second
+
+// metadataMaxBytes is how much of a page is read looking for its metadata,
+// which belongs in the head
+const metadataMaxBytes = 512 << 10
+
+// Metadata refreshes go through stale links in batches, up to a bound and a
+// time budget per run, fetching a few destinations at a time
+const (
+	metadataRefreshBatchSize   = 100
+	metadataRefreshMaxBatches  = 10
+	metadataRefreshBudget      = 10 * time.Minute
+	metadataRefreshConcurrency = 8
+)
+
+// LinkMetadataFetcher reads the title, description and favicon of link
+// destinations through the outbound fetcher
+type LinkMetadataFetcher struct {
+	fetcher *fetcher.Fetcher
+}
+
+// NewLinkMetadataFetcher creates a metadata fetcher
+func NewLinkMetadataFetcher(fetcher *fetcher.Fetcher) *LinkMetadataFetcher {
+	return &LinkMetadataFetcher{fetcher: fetcher}
+}
+
+// Fetch requests a destination and reads its metadata. Pages that aren't HTML
+// have none, but count as fetched.
+func (f *LinkMetadataFetcher) Fetch(ctx context.Context, destination string) (*models.LinkMetadata, error) {
+	ctx, cancel := context.WithTimeout(ctx, metadataFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, destination, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+
+	resp, err := f.fetcher.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("responded with status %d", resp.StatusCode)
+	}
+
+	fetchedAt := time.Now()
+	metadata := &models.LinkMetadata{MetadataFetchedAt: &fetchedAt}
+
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return metadata, nil
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, metadataMaxBytes), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode page: %w", err)
+	}
+	parseLinkMetadata(body, resp.Request.URL, metadata)
+	return metadata, nil
+}
+
+// parseLinkMetadata reads the title, description and favicon from the head of
+// a page. The title and description prefer the page's own over Open Graph
+// ones; a page declaring no icon gets its host's /favicon.ico.
+func parseLinkMetadata(body io.Reader, base *neturl.URL, metadata *models.LinkMetadata) {
+	var title, ogTitle, description, ogDescription, icon string
+
+	tokenizer := html.NewTokenizer(body)
+	inTitle := false
+scan:
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			break
+		}
+
+		token := tokenizer.Token()
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			switch token.Data {
+			case "title":
+				inTitle = title == ""
+			case "meta":
+				content := htmlAttr(token, "content")
+				switch strings.ToLower(htmlAttr(token, "name") + htmlAttr(token, "property")) {
+				case "description":
+					description = content
+				case "og:title":
+					ogTitle = content
+				case "og:description":
+					ogDescription = content
+				}
+			case "link":
+				if icon == "" && slices.Contains(strings.Fields(strings.ToLower(htmlAttr(token, "rel"))), "icon") {
+					icon = htmlAttr(token, "href")
+				}
+			case "body":
+				// Metadata belongs in the head
+				break scan
+			}
+		case html.TextToken:
+			if inTitle {
+				title += token.Data
+			}
+		case html.EndTagToken:
+			switch token.Data {
+			case "title":
+				inTitle = false
+			case "head":
+				break scan
+			}
+		}
+	}
+
+	if title = strings.TrimSpace(title); title == "" {
+		title = ogTitle
+	}
+	if description == "" {
+		description = ogDescription
+	}
+	metadata.PageTitle = truncateMetadata(title, models.MaxPageTitleLength)
+	metadata.PageDescription = truncateMetadata(description, models.MaxPageDescriptionLength)
+	metadata.FaviconURL = resolveFavicon(base, icon)
+}
+
+// htmlAttr returns the value of a token's attribute, or an empty string
+func htmlAttr(token html.Token, name string) string {
+	for _, attr := range token.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// truncateMetadata collapses whitespace and shortens text to at most max characters
+func truncateMetadata(text string, max int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	return string([]rune(text)[:max])
+}
+
+// resolveFavicon makes a page's icon reference absolute, defaulting to
+// /favicon.ico. Icons that aren't http(s), such as data URIs, are dropped.
+func resolveFavicon(base *neturl.URL, href string) string {
+	if href = strings.TrimSpace(href); href == "" {
+		href = "/favicon.ico"
+	}
+	icon, err := base.Parse(href)
+	if err != nil || (icon.Scheme != "http" && icon.Scheme != "https") {
+		return ""
+	}
+	if resolved := icon.String(); len(resolved) <= models.MaxFaviconURLLength {
+		return resolved
+	}
+	return ""
+}
+
+// RefreshMetadata fetches the title, description and favicon of a user's link
+// destination now, instead of waiting for the periodic refresh
+func (s *urlService) RefreshMetadata(ctx context.Context, shortCode string, userID int) (*models.LinkMetadata, error) {
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := s.metadata.Fetch(ctx, url.OriginalURL)
+	if err != nil {
+		return nil, errors.NewExternalServiceError("Failed to fetch the destination's metadata", err)
+	}
+
+	// Sandbox requests get the metadata without saving it
+	if models.IsSandbox(ctx) {
+		return metadata, nil
+	}
+
+	stored, err := s.urlRepo.SetMetadata(ctx, url.ID, url.OriginalURL, metadata)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to store link metadata", err)
+	}
+	if !stored {
+		return nil, errors.NewConflictError("The link's destination changed while its metadata was fetched", nil)
+	}
+	return metadata, nil
+}
+
+// RefreshStaleMetadata fetches the metadata of active links in every region
+// that was never fetched or is older than the refresh interval. Destinations
+// that fail are tried again an interval later. Each region is refreshed by one
+// instance at a time, under a lease, and a run stops once its time budget is
+// spent. It returns how many links got fresh metadata.
+func (s *urlService) RefreshStaleMetadata(ctx context.Context) (int, error) {
+	interval := s.config.App.MetadataRefreshInterval
+	if interval == 0 {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, metadataRefreshBudget)
+	defer cancel()
+
+	refreshed := 0
+	for _, region := range s.regions.Regions() {
+		regionCtx := repository.WithRegion(ctx, region)
+
+		acquired, err := s.cacheRepo.AcquireLease(regionCtx, "metadata-refresh", metadataRefreshBudget)
+		if err != nil {
+			return refreshed, errors.NewRedisError("Failed to acquire the metadata refresh lease", err)
+		}
+		if !acquired {
+			continue
+		}
+
+		for batch := 0; batch < metadataRefreshMaxBatches && ctx.Err() == nil; batch++ {
+			now := time.Now()
+			targets, err := s.urlRepo.GetLinksWithStaleMetadata(regionCtx, now.Add(-interval), metadataRefreshBatchSize)
+			if err != nil {
+				return refreshed, errors.NewDatabaseError("Failed to get links with stale metadata", err)
+			}
+			if len(targets) == 0 {
+				break
+			}
+
+			stored, failed, err := s.refreshMetadataBatch(regionCtx, targets)
+			refreshed += stored
+			if err != nil {
+				return refreshed, err
+			}
+
+			// Failures are recorded even once the budget is spent
+			if len(failed) > 0 {
+				if err := s.urlRepo.MarkMetadataChecked(context.WithoutCancel(regionCtx), failed, now); err != nil {
+					return refreshed, errors.NewDatabaseError("Failed to mark link metadata checked", err)
+				}
+			}
+			if len(targets) < metadataRefreshBatchSize {
+				break
+			}
+		}
+	}
+
+	return refreshed, nil
+}
+
+// refreshMetadataBatch fetches and stores the metadata of links, a few at a
+// time. It returns how many were stored and the IDs of the links whose fetch
+// failed. Links left when ctx ends are skipped, and picked up by a later run.
+func (s *urlService) refreshMetadataBatch(ctx context.Context, targets []models.MetadataTarget) (int, []int, error) {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		stored   int
+		failed   []int
+		storeErr error
+	)
+	slots := make(chan struct{}, metadataRefreshConcurrency)
+
+	for _, target := range targets {
+		select {
+		case <-ctx.Done():
+		case slots <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			metadata, err := s.metadata.Fetch(ctx, target.OriginalURL)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("Failed to fetch metadata of %s: %v", target.ShortCode, err)
+				mu.Lock()
+				failed = append(failed, target.ID)
+				mu.Unlock()
+				return
+			}
+
+			ok, err := s.urlRepo.SetMetadata(ctx, target.ID, target.OriginalURL, metadata)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err != nil:
+				storeErr = errors.NewDatabaseError("Failed to store link metadata", err)
+			case ok:
+				stored++
+			}
+		}()
+	}
+
+	wg.Wait()
+	return stored, failed, storeErr
+}
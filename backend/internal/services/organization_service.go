@@ -0,0 +1,518 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/config"
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// OrganizationService interface defines the contract for organization operations
+type OrganizationService interface {
+	CreateOrganization(ctx context.Context, userID int, req *models.CreateOrganizationRequest) (*models.Organization, error)
+	GetOrganization(ctx context.Context, userID int) (*models.Organization, error)
+	GetMembers(ctx context.Context, userID int) ([]models.OrganizationMember, error)
+	InviteMember(ctx context.Context, userID int, req *models.AddOrganizationMemberRequest) (*models.OrganizationInvitation, error)
+	GetInvitations(ctx context.Context, userID int) ([]models.OrganizationInvitation, error)
+	AcceptInvitation(ctx context.Context, userID int, invitationID int) (*models.Organization, error)
+	DeclineInvitation(ctx context.Context, userID int, invitationID int) error
+	DeleteExpiredInvitations(ctx context.Context) (int64, error)
+	RemoveMember(ctx context.Context, userID int, memberID int) error
+	GetEmailBranding(ctx context.Context, userID int) (*models.EmailBranding, error)
+	UpdateEmailBranding(ctx context.Context, userID int, req *models.UpdateEmailBrandingRequest) (*models.EmailBranding, error)
+	VerifyEmailDomain(ctx context.Context, userID int) (*models.EmailDomainVerification, error)
+	GetBrandingForRecipient(ctx context.Context, email string) *models.EmailBranding
+	SetDataRegion(ctx context.Context, userID int, req *models.SetDataRegionRequest) (*models.Organization, error)
+	WithUserRegion(ctx context.Context, user *models.User) context.Context
+	SetCodePrefix(ctx context.Context, userID int, req *models.SetCodePrefixRequest) (*models.Organization, error)
+	CheckCustomCode(ctx context.Context, user *models.User, code string) error
+	GetNamespaceLinks(ctx context.Context, userID int, limit, offset int) ([]models.URL, int, error)
+	GetNamespaceRollup(ctx context.Context, userID int) (*models.CodePrefixRollup, error)
+}
+
+// organizationService implements OrganizationService interface
+type organizationService struct {
+	orgRepo  repository.OrganizationRepository
+	userRepo repository.UserRepository
+	urlRepo  repository.URLRepository
+	regions  *repository.RegionRouter
+	smtp     *config.SMTPConfig
+	resolver *net.Resolver
+}
+
+// NewOrganizationService creates a new organization service
+func NewOrganizationService(orgRepo repository.OrganizationRepository, userRepo repository.UserRepository, urlRepo repository.URLRepository, regions *repository.RegionRouter, smtp *config.SMTPConfig) OrganizationService {
+	return &organizationService{
+		orgRepo:  orgRepo,
+		userRepo: userRepo,
+		urlRepo:  urlRepo,
+		regions:  regions,
+		smtp:     smtp,
+		resolver: net.DefaultResolver,
+	}
+}
+
+// CreateOrganization creates an organization owned by the user
+func (s *organizationService) CreateOrganization(ctx context.Context, userID int, req *models.CreateOrganizationRequest) (*models.Organization, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid organization request", err)
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("User not found", err)
+	}
+	if user.OrganizationID != nil {
+		return nil, errors.NewAlreadyExistsError("User already belongs to an organization", nil)
+	}
+
+	org := &models.Organization{
+		Name:      req.Name,
+		OwnerID:   userID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	createdOrg, err := s.orgRepo.Create(ctx, org)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to create organization", err)
+	}
+
+	return createdOrg, nil
+}
+
+// GetOrganization retrieves the organization the user belongs to
+func (s *organizationService) GetOrganization(ctx context.Context, userID int) (*models.Organization, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("User not found", err)
+	}
+	if user.OrganizationID == nil {
+		return nil, errors.NewNotFoundError("User does not belong to an organization", nil)
+	}
+
+	org, err := s.orgRepo.GetByID(ctx, *user.OrganizationID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("Organization not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get organization", err)
+	}
+
+	return s.withRegionName(org), nil
+}
+
+// GetMembers lists the members of the user's organization
+func (s *organizationService) GetMembers(ctx context.Context, userID int) ([]models.OrganizationMember, error) {
+	org, err := s.GetOrganization(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := s.orgRepo.GetMembers(ctx, org.ID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get organization members", err)
+	}
+
+	return members, nil
+}
+
+// InviteMember invites an email to the owner's organization. Whoever holds the
+// account with that email joins only once they accept, so nobody is added
+// without consent and the response doesn't reveal whether the account exists.
+func (s *organizationService) InviteMember(ctx context.Context, userID int, req *models.AddOrganizationMemberRequest) (*models.OrganizationInvitation, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid member request", err)
+	}
+
+	org, err := s.getOwnedOrganization(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	invitation := &models.OrganizationInvitation{
+		OrganizationID:   org.ID,
+		OrganizationName: org.Name,
+		Email:            req.Email,
+		InvitedBy:        &userID,
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(models.OrganizationInvitationTTL),
+	}
+	createdInvitation, err := s.orgRepo.CreateInvitation(ctx, invitation)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to invite organization member", err)
+	}
+
+	return createdInvitation, nil
+}
+
+// GetInvitations lists the pending invitations of the user's email
+func (s *organizationService) GetInvitations(ctx context.Context, userID int) ([]models.OrganizationInvitation, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("User not found", err)
+	}
+
+	invitations, err := s.orgRepo.GetInvitationsByEmail(ctx, strings.ToLower(user.Email), time.Now())
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get organization invitations", err)
+	}
+
+	return invitations, nil
+}
+
+// AcceptInvitation makes the user a member of the organization that invited their email
+func (s *organizationService) AcceptInvitation(ctx context.Context, userID int, invitationID int) (*models.Organization, error) {
+	user, invitation, err := s.getUserInvitation(ctx, userID, invitationID)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := s.orgRepo.GetByID(ctx, invitation.OrganizationID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("Invitation not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get organization", err)
+	}
+
+	if user.OrganizationID == nil || *user.OrganizationID != org.ID {
+		if user.OrganizationID != nil {
+			return nil, errors.NewAlreadyExistsError("Leave your current organization before joining another", nil)
+		}
+		if org.DataRegion != "" && user.LinkCount > 0 {
+			return nil, errors.NewConflictError("Users with existing links can't join an organization whose data is stored in another region", nil)
+		}
+		if err := s.orgRepo.SetMembership(ctx, user.ID, &org.ID); err != nil {
+			return nil, errors.NewDatabaseError("Failed to add organization member", err)
+		}
+	}
+
+	if err := s.orgRepo.DeleteInvitation(ctx, invitation.ID); err != nil && !repository.IsNotFound(err) {
+		return nil, errors.NewDatabaseError("Failed to delete organization invitation", err)
+	}
+
+	return s.withRegionName(org), nil
+}
+
+// DeclineInvitation deletes an invitation of the user's email
+func (s *organizationService) DeclineInvitation(ctx context.Context, userID int, invitationID int) error {
+	_, invitation, err := s.getUserInvitation(ctx, userID, invitationID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.orgRepo.DeleteInvitation(ctx, invitation.ID); err != nil {
+		if repository.IsNotFound(err) {
+			return errors.NewNotFoundError("Invitation not found", err)
+		}
+		return errors.NewDatabaseError("Failed to delete organization invitation", err)
+	}
+
+	return nil
+}
+
+// DeleteExpiredInvitations deletes the invitations that can no longer be accepted
+func (s *organizationService) DeleteExpiredInvitations(ctx context.Context) (int64, error) {
+	deleted, err := s.orgRepo.DeleteExpiredInvitations(ctx, time.Now())
+	if err != nil {
+		return 0, errors.NewDatabaseError("Failed to delete expired organization invitations", err)
+	}
+	return deleted, nil
+}
+
+// getUserInvitation retrieves a pending invitation addressed to the user's email.
+// Other users' invitations are reported as not found.
+func (s *organizationService) getUserInvitation(ctx context.Context, userID int, invitationID int) (*models.User, *models.OrganizationInvitation, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, nil, errors.NewNotFoundError("User not found", err)
+	}
+
+	invitation, err := s.orgRepo.GetInvitation(ctx, invitationID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, nil, errors.NewNotFoundError("Invitation not found", err)
+		}
+		return nil, nil, errors.NewDatabaseError("Failed to get organization invitation", err)
+	}
+	if !strings.EqualFold(invitation.Email, user.Email) || !time.Now().Before(invitation.ExpiresAt) {
+		return nil, nil, errors.NewNotFoundError("Invitation not found", nil)
+	}
+
+	return user, invitation, nil
+}
+
+// RemoveMember removes a user from the owner's organization
+func (s *organizationService) RemoveMember(ctx context.Context, userID int, memberID int) error {
+	org, err := s.getOwnedOrganization(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if org.IsOwner(memberID) {
+		return errors.NewBadRequestError("The organization owner cannot be removed", nil)
+	}
+
+	member, err := s.userRepo.GetByID(ctx, memberID)
+	if err != nil || member.OrganizationID == nil || *member.OrganizationID != org.ID {
+		return errors.NewNotFoundError("Member not found", err)
+	}
+	if org.DataRegion != "" && member.LinkCount > 0 {
+		return errors.NewConflictError("Members with links stored in the organization's region can't be removed", nil)
+	}
+
+	if err := s.orgRepo.SetMembership(ctx, memberID, nil); err != nil {
+		return errors.NewDatabaseError("Failed to remove organization member", err)
+	}
+
+	return nil
+}
+
+// GetEmailBranding retrieves the email branding of the user's organization
+func (s *organizationService) GetEmailBranding(ctx context.Context, userID int) (*models.EmailBranding, error) {
+	org, err := s.GetOrganization(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	branding, err := s.orgRepo.GetEmailBranding(ctx, org.ID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get email branding", err)
+	}
+
+	return branding, nil
+}
+
+// UpdateEmailBranding changes the owner's organization email branding. A new
+// from-address must be verified again before it is used.
+func (s *organizationService) UpdateEmailBranding(ctx context.Context, userID int, req *models.UpdateEmailBrandingRequest) (*models.EmailBranding, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid email branding", err)
+	}
+
+	org, err := s.getOwnedOrganization(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	branding, err := s.orgRepo.GetEmailBranding(ctx, org.ID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get email branding", err)
+	}
+
+	if req.FromName != nil {
+		branding.FromName = *req.FromName
+	}
+	if req.FromAddress != nil && *req.FromAddress != branding.FromAddress {
+		branding.FromAddress = *req.FromAddress
+		branding.FromDomainVerifiedAt = nil
+	}
+	if req.LogoURL != nil {
+		branding.LogoURL = *req.LogoURL
+	}
+	if req.PrimaryColor != nil {
+		branding.PrimaryColor = *req.PrimaryColor
+	}
+	if req.AccentColor != nil {
+		branding.AccentColor = *req.AccentColor
+	}
+
+	updatedBranding, err := s.orgRepo.UpsertEmailBranding(ctx, branding)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to save email branding", err)
+	}
+
+	return updatedBranding, nil
+}
+
+// VerifyEmailDomain checks the SPF and DKIM records of the custom from-domain and
+// marks it verified when both pass. The result includes guidance for failing checks.
+func (s *organizationService) VerifyEmailDomain(ctx context.Context, userID int) (*models.EmailDomainVerification, error) {
+	org, err := s.getOwnedOrganization(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	branding, err := s.orgRepo.GetEmailBranding(ctx, org.ID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get email branding", err)
+	}
+
+	domain := branding.FromDomain()
+	if domain == "" {
+		return nil, errors.NewBadRequestError("Set a from address before verifying its domain", nil)
+	}
+
+	result := &models.EmailDomainVerification{
+		Domain: domain,
+		SPF:    s.checkSPF(ctx, domain),
+		DKIM:   s.checkDKIM(ctx, domain),
+	}
+	result.Verified = result.SPF.Passed && result.DKIM.Passed
+
+	if result.Verified && branding.FromDomainVerifiedAt == nil {
+		now := time.Now()
+		branding.FromDomainVerifiedAt = &now
+		if _, err := s.orgRepo.UpsertEmailBranding(ctx, branding); err != nil {
+			return nil, errors.NewDatabaseError("Failed to save email branding", err)
+		}
+	}
+
+	return result, nil
+}
+
+// GetBrandingForRecipient returns the email branding for a recipient's organization,
+// or nil when they have none. Lookup failures fall back to the default branding.
+func (s *organizationService) GetBrandingForRecipient(ctx context.Context, email string) *models.EmailBranding {
+	branding, err := s.orgRepo.GetEmailBrandingByEmail(ctx, email)
+	if err != nil {
+		if !repository.IsNotFound(err) {
+			log.Printf("Failed to get email branding: %v", err)
+		}
+		return nil
+	}
+	return branding
+}
+
+// checkSPF verifies the domain publishes an SPF record authorizing our mail
+// servers. Without SMTP_SPF_INCLUDE any record would pass, proving nothing, so
+// the check fails.
+func (s *organizationService) checkSPF(ctx context.Context, domain string) models.EmailDNSCheck {
+	check := models.EmailDNSCheck{Name: domain}
+	if s.smtp.SPFInclude == "" {
+		check.Guidance = "Custom from-domains are not available: SMTP_SPF_INCLUDE is not configured"
+		return check
+	}
+
+	expected := fmt.Sprintf("v=spf1 include:%s ~all", s.smtp.SPFInclude)
+
+	records, _ := s.resolver.LookupTXT(ctx, domain)
+	for _, record := range records {
+		if !strings.HasPrefix(strings.ToLower(record), "v=spf1") {
+			continue
+		}
+		check.Found = append(check.Found, record)
+		if strings.Contains(record, "include:"+s.smtp.SPFInclude) {
+			check.Passed = true
+		}
+	}
+
+	switch {
+	case check.Passed:
+	case len(check.Found) == 0:
+		check.Guidance = fmt.Sprintf("Add a TXT record at %s: %q", domain, expected)
+	default:
+		check.Guidance = fmt.Sprintf("Add include:%s to the existing SPF record at %s", s.smtp.SPFInclude, domain)
+	}
+
+	return check
+}
+
+// checkDKIM verifies the domain publishes the DKIM key for our signing
+// selector. Mail that isn't DKIM-signed can't prove the domain is ours to send
+// from, so the check fails without SMTP_DKIM_SELECTOR.
+func (s *organizationService) checkDKIM(ctx context.Context, domain string) models.EmailDNSCheck {
+	if s.smtp.DKIMSelector == "" {
+		return models.EmailDNSCheck{Name: domain, Guidance: "Custom from-domains are not available: SMTP_DKIM_SELECTOR is not configured"}
+	}
+
+	name := fmt.Sprintf("%s._domainkey.%s", s.smtp.DKIMSelector, domain)
+	check := models.EmailDNSCheck{Name: name}
+
+	records, _ := s.resolver.LookupTXT(ctx, name)
+	for _, record := range records {
+		if strings.Contains(record, "p=") {
+			check.Found = append(check.Found, record)
+			check.Passed = true
+		}
+	}
+
+	if !check.Passed {
+		check.Guidance = fmt.Sprintf("Publish the DKIM public key provided by support as a TXT record at %s", name)
+	}
+
+	return check
+}
+
+// SetDataRegion chooses the region storing the owner's organization's link and click
+// data. Existing data is not moved, so the region can only change before members create links.
+func (s *organizationService) SetDataRegion(ctx context.Context, userID int, req *models.SetDataRegionRequest) (*models.Organization, error) {
+	if !s.regions.HasRegion(req.Region) {
+		return nil, errors.NewValidationError(fmt.Sprintf("Unknown data region, choose one of: %s", strings.Join(s.regions.Regions(), ", ")), nil)
+	}
+
+	org, err := s.getOwnedOrganization(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	region := req.Region
+	if region == s.regions.Home() {
+		region = ""
+	}
+	if region == org.DataRegion {
+		return s.withRegionName(org), nil
+	}
+
+	links, err := s.orgRepo.CountMemberLinks(ctx, org.ID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to count organization links", err)
+	}
+	if links > 0 {
+		return nil, errors.NewConflictError("The data region can only be changed before members create links", nil)
+	}
+
+	if err := s.orgRepo.SetDataRegion(ctx, org.ID, region); err != nil {
+		return nil, errors.NewDatabaseError("Failed to update data region", err)
+	}
+	org.DataRegion = region
+	org.UpdatedAt = time.Now()
+
+	return s.withRegionName(org), nil
+}
+
+// WithUserRegion routes the context to the data region of the user's organization.
+// Lookup failures fall back to the home region.
+func (s *organizationService) WithUserRegion(ctx context.Context, user *models.User) context.Context {
+	if !s.regions.MultiRegion() || user.OrganizationID == nil {
+		return ctx
+	}
+
+	org, err := s.orgRepo.GetByID(ctx, *user.OrganizationID)
+	if err != nil {
+		log.Printf("Failed to get organization data region: %v", err)
+		return ctx
+	}
+	if org.DataRegion == "" {
+		return ctx
+	}
+	return repository.WithRegion(ctx, org.DataRegion)
+}
+
+// withRegionName reports the home region by name rather than as empty
+func (s *organizationService) withRegionName(org *models.Organization) *models.Organization {
+	if org.DataRegion == "" {
+		org.DataRegion = s.regions.Home()
+	}
+	return org
+}
+
+// getOwnedOrganization loads the user's organization, ensuring they own it
+func (s *organizationService) getOwnedOrganization(ctx context.Context, userID int) (*models.Organization, error) {
+	org, err := s.GetOrganization(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !org.IsOwner(userID) {
+		return nil, errors.NewForbiddenError("Only the organization owner can do this", nil)
+	}
+	return org, nil
+}
@@ -17,6 +17,9 @@ type OTPService interface {
 	GenerateOTP(ctx context.Context, userID int, email, purpose string) (*models.OTPResponse, error)
 	VerifyOTP(ctx context.Context, req *models.OTPVerifyRequest) (*models.OTPVerifyResponse, error)
 	CleanupExpiredOTPs(ctx context.Context) error
+	GetOTP(ctx context.Context, id int) (*models.OTPVerification, error)
+	RecordDelivery(ctx context.Context, id int, status, detail string, attempted bool) error
+	GetDeliveries(ctx context.Context, filter *models.OTPDeliveryFilter) ([]models.OTPVerification, error)
 }
 
 // otpService implements OTPService interface
@@ -61,8 +64,10 @@ func (s *otpService) GenerateOTP(ctx context.Context, userID int, email, purpose
 	}
 
 	return &models.OTPResponse{
-		Message:   "OTP sent successfully",
-		ExpiresAt: createdOTP.ExpiresAt,
+		Message:        "OTP sent successfully",
+		ExpiresAt:      createdOTP.ExpiresAt,
+		DeliveryID:     createdOTP.ID,
+		DeliveryStatus: createdOTP.DeliveryStatus,
 	}, nil
 }
 
@@ -146,3 +151,36 @@ func (s *otpService) generateOTPCode() (string, error) {
 
 	return fmt.Sprintf("%06d", n.Add(n, min).Int64()), nil
 }
+
+// GetOTP retrieves an OTP record by ID
+func (s *otpService) GetOTP(ctx context.Context, id int) (*models.OTPVerification, error) {
+	otp, err := s.otpRepo.GetByID(ctx, id)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("OTP not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get OTP", err)
+	}
+	return otp, nil
+}
+
+// RecordDelivery records the delivery status of an OTP email
+func (s *otpService) RecordDelivery(ctx context.Context, id int, status, detail string, attempted bool) error {
+	if err := s.otpRepo.SetDeliveryStatus(ctx, id, status, detail, attempted); err != nil {
+		return errors.NewDatabaseError("Failed to record OTP delivery", err)
+	}
+	return nil
+}
+
+// GetDeliveries lists OTP emails and their delivery status, newest first
+func (s *otpService) GetDeliveries(ctx context.Context, filter *models.OTPDeliveryFilter) ([]models.OTPVerification, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid delivery filter", err)
+	}
+
+	deliveries, err := s.otpRepo.GetDeliveries(ctx, filter)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get OTP deliveries", err)
+	}
+	return deliveries, nil
+}
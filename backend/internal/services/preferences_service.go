@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// PreferencesService interface defines the contract for user preference operations
+type PreferencesService interface {
+	GetPreferences(ctx context.Context, userID int) (*models.UserPreferences, error)
+	UpdateUTMDefaults(ctx context.Context, userID int, utm *models.UTMParams) (*models.UserPreferences, error)
+	UpdatePreferences(ctx context.Context, userID int, req *models.UpdatePreferencesRequest) (*models.UserPreferences, error)
+}
+
+// preferencesService implements PreferencesService interface
+type preferencesService struct {
+	preferencesRepo repository.PreferencesRepository
+	domains         DomainService
+}
+
+// NewPreferencesService creates a new user preferences service
+func NewPreferencesService(preferencesRepo repository.PreferencesRepository, domains DomainService) PreferencesService {
+	return &preferencesService{
+		preferencesRepo: preferencesRepo,
+		domains:         domains,
+	}
+}
+
+// GetPreferences retrieves a user's preferences
+func (s *preferencesService) GetPreferences(ctx context.Context, userID int) (*models.UserPreferences, error) {
+	preferences, err := s.preferencesRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get preferences", err)
+	}
+	return preferences, nil
+}
+
+// UpdateUTMDefaults replaces the UTM values applied to the user's new links
+func (s *preferencesService) UpdateUTMDefaults(ctx context.Context, userID int, utm *models.UTMParams) (*models.UserPreferences, error) {
+	if err := utm.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid UTM defaults", err)
+	}
+
+	preferences, err := s.preferencesRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get preferences", err)
+	}
+	preferences.UTMDefaults = *utm
+
+	updatedPreferences, err := s.preferencesRepo.Upsert(ctx, preferences)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to save preferences", err)
+	}
+	return updatedPreferences, nil
+}
+
+// UpdatePreferences replaces all of the presets applied to the user's new links
+func (s *preferencesService) UpdatePreferences(ctx context.Context, userID int, req *models.UpdatePreferencesRequest) (*models.UserPreferences, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid preferences", err)
+	}
+	if req.DefaultDomain != "" {
+		if err := s.domains.CheckLinkDomain(ctx, userID, req.DefaultDomain); err != nil {
+			return nil, err
+		}
+	}
+
+	preferences, err := s.preferencesRepo.Get(ctx, userID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get preferences", err)
+	}
+	req.Apply(preferences)
+
+	updatedPreferences, err := s.preferencesRepo.Upsert(ctx, preferences)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to save preferences", err)
+	}
+	return updatedPreferences, nil
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+	"github.com/skip2/go-qrcode"
+)
+
+// qrBatchTimeout bounds rendering and storing a single batch
+const qrBatchTimeout = 5 * time.Minute
+
+// qrBatchPollInterval is how often instances look for batches to render and
+// archives to expire
+const qrBatchPollInterval = time.Minute
+
+// qrBatchClaimTTL is how long a claimed batch is left to its instance before
+// another one renders it
+const qrBatchClaimTTL = 2 * qrBatchTimeout
+
+// qrBatchMaxAttempts is how many times a batch is rendered before it's failed,
+// so a batch that keeps killing its instance isn't retried forever
+const qrBatchMaxAttempts = 3
+
+// qrBatchClaimSize is how many batches an instance claims per poll
+const qrBatchClaimSize = 5
+
+// QRBatchService interface defines the contract for bulk QR code generation
+type QRBatchService interface {
+	CreateBatch(ctx context.Context, userID int, req *models.CreateQRBatchRequest) (*models.QRBatch, error)
+	GetBatch(ctx context.Context, id int, userID int) (*models.QRBatch, error)
+	GetArchive(ctx context.Context, id int, userID int) ([]byte, error)
+	Start(ctx context.Context)
+}
+
+// qrBatchService implements QRBatchService interface
+type qrBatchService struct {
+	batchRepo repository.QRBatchRepository
+	urlRepo   repository.URLRepository
+	baseURL   string
+}
+
+// NewQRBatchService creates a new QR batch service
+func NewQRBatchService(batchRepo repository.QRBatchRepository, urlRepo repository.URLRepository, baseURL string) QRBatchService {
+	return &qrBatchService{
+		batchRepo: batchRepo,
+		urlRepo:   urlRepo,
+		baseURL:   baseURL,
+	}
+}
+
+// CreateBatch queues a ZIP of QR codes for the user's selected links and renders it in the background.
+// Batches outlive the instance that queued them: if it stops before finishing, another renders them.
+func (s *qrBatchService) CreateBatch(ctx context.Context, userID int, req *models.CreateQRBatchRequest) (*models.QRBatch, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid QR batch request", err)
+	}
+
+	pending, err := s.batchRepo.CountPending(ctx, userID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to count pending QR batches", err)
+	}
+	if pending >= models.MaxPendingQRBatches {
+		return nil, errors.NewRateLimitError(fmt.Sprintf("At most %d QR batches can be pending at once", models.MaxPendingQRBatches), nil)
+	}
+
+	shortCodes, err := s.resolveShortCodes(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	shortURLs, err := s.shortURLs(ctx, shortCodes)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get URLs", err)
+	}
+
+	batch := &models.QRBatch{
+		UserID:     userID,
+		ShortCodes: shortCodes,
+		Size:       req.Size,
+		Status:     models.QRBatchPending,
+		CreatedAt:  time.Now(),
+	}
+
+	batch, err = s.batchRepo.Create(ctx, batch)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to create QR batch", err)
+	}
+
+	go s.render(*batch, shortURLs)
+
+	return batch, nil
+}
+
+// GetBatch returns the status of a user's QR batch
+func (s *qrBatchService) GetBatch(ctx context.Context, id int, userID int) (*models.QRBatch, error) {
+	batch, err := s.batchRepo.GetByID(ctx, id, userID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("QR batch not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get QR batch", err)
+	}
+	if batch.Status == models.QRBatchCompleted && batch.CompletedAt != nil {
+		expiresAt := batch.CompletedAt.Add(models.QRBatchArchiveTTL)
+		batch.ExpiresAt = &expiresAt
+	}
+	return batch, nil
+}
+
+// GetArchive returns the ZIP archive of a completed QR batch
+func (s *qrBatchService) GetArchive(ctx context.Context, id int, userID int) ([]byte, error) {
+	batch, err := s.GetBatch(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if batch.Status == models.QRBatchExpired {
+		return nil, errors.NewExpiredError("QR batch archive has expired", nil)
+	}
+	if batch.Status != models.QRBatchCompleted {
+		return nil, errors.NewBadRequestError(fmt.Sprintf("QR batch is %s", batch.Status), nil)
+	}
+
+	archive, err := s.batchRepo.GetArchive(ctx, id, userID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("QR batch archive not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get QR batch archive", err)
+	}
+	return archive, nil
+}
+
+// Start renders the batches left pending by stopped instances, and deletes
+// expired archives, in the background until ctx is cancelled
+func (s *qrBatchService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(qrBatchPollInterval)
+		defer ticker.Stop()
+
+		for {
+			s.runOnce(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// runOnce renders the pending batches this instance claims, one at a time,
+// and expires old archives
+func (s *qrBatchService) runOnce(ctx context.Context) {
+	staleBefore := time.Now().Add(-qrBatchClaimTTL)
+
+	failed, err := s.batchRepo.FailAbandoned(ctx, staleBefore, qrBatchMaxAttempts, "QR batch rendering was interrupted")
+	if err != nil {
+		log.Printf("Error failing abandoned QR batches: %v", err)
+	} else if failed > 0 {
+		log.Printf("Failed %d QR batches whose rendering kept being interrupted", failed)
+	}
+
+	batches, err := s.batchRepo.ClaimPending(ctx, staleBefore, qrBatchMaxAttempts, qrBatchClaimSize)
+	if err != nil {
+		log.Printf("Error claiming pending QR batches: %v", err)
+	}
+	for _, batch := range batches {
+		if ctx.Err() != nil {
+			break
+		}
+		shortURLs, err := s.shortURLs(ctx, batch.ShortCodes)
+		if err != nil {
+			log.Printf("Failed to get URLs of QR batch %d: %v", batch.ID, err)
+			continue
+		}
+		s.render(batch, shortURLs)
+	}
+
+	expired, err := s.batchRepo.ExpireArchives(ctx, time.Now().Add(-models.QRBatchArchiveTTL))
+	if err != nil {
+		log.Printf("Error expiring QR batch archives: %v", err)
+	} else if expired > 0 {
+		log.Printf("Deleted the archives of %d expired QR batches", expired)
+	}
+}
+
+// shortURLs maps short codes to the short URLs their QR codes encode: each
+// link's, on its custom domain if it has one
+func (s *qrBatchService) shortURLs(ctx context.Context, shortCodes []string) (map[string]string, error) {
+	urls, err := s.urlRepo.GetByShortCodes(ctx, shortCodes)
+	if err != nil {
+		return nil, err
+	}
+	shortURLs := make(map[string]string, len(urls))
+	for i := range urls {
+		shortURLs[urls[i].ShortCode] = urls[i].ShortURL(s.baseURL)
+	}
+	return shortURLs, nil
+}
+
+// resolveShortCodes returns the requested short codes, all of which must belong to the user,
+// or the user's links tagged with the requested campaign
+func (s *qrBatchService) resolveShortCodes(ctx context.Context, userID int, req *models.CreateQRBatchRequest) ([]string, error) {
+	if req.Campaign != "" {
+		shortCodes, err := s.urlRepo.GetShortCodesByCampaign(ctx, userID, req.Campaign, models.MaxQRBatchCodes+1)
+		if err != nil {
+			return nil, errors.NewDatabaseError("Failed to find campaign links", err)
+		}
+		if len(shortCodes) == 0 {
+			return nil, errors.NewNotFoundError("No links found for campaign", nil)
+		}
+		if len(shortCodes) > models.MaxQRBatchCodes {
+			return nil, errors.NewValidationError(fmt.Sprintf("Campaign has more than %d links", models.MaxQRBatchCodes), nil)
+		}
+		return shortCodes, nil
+	}
+
+	owned, err := s.urlRepo.GetOwnedShortCodes(ctx, userID, req.ShortCodes)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to check URL ownership", err)
+	}
+	if len(owned) != len(req.ShortCodes) {
+		ownedSet := make(map[string]bool, len(owned))
+		for _, shortCode := range owned {
+			ownedSet[shortCode] = true
+		}
+		var missing []string
+		for _, shortCode := range req.ShortCodes {
+			if !ownedSet[shortCode] {
+				missing = append(missing, shortCode)
+			}
+		}
+		return nil, errors.NewNotFoundError("URL not found", nil).WithDetails(strings.Join(missing, ", "))
+	}
+
+	return req.ShortCodes, nil
+}
+
+// render builds the batch's ZIP archive and records the outcome
+func (s *qrBatchService) render(batch models.QRBatch, shortURLs map[string]string) {
+	ctx, cancel := context.WithTimeout(context.Background(), qrBatchTimeout)
+	defer cancel()
+
+	archive, err := s.buildArchive(batch, shortURLs)
+	if err != nil {
+		log.Printf("Failed to render QR batch %d: %v", batch.ID, err)
+		if err := s.batchRepo.Fail(ctx, batch.ID, err.Error()); err != nil {
+			log.Printf("Failed to record QR batch %d failure: %v", batch.ID, err)
+		}
+		return
+	}
+
+	if err := s.batchRepo.Complete(ctx, batch.ID, archive); err != nil {
+		log.Printf("Failed to store QR batch %d: %v", batch.ID, err)
+	}
+}
+
+// buildArchive renders one PNG per short code into a ZIP archive. Codes
+// without a short URL are encoded on the base URL.
+func (s *qrBatchService) buildArchive(batch models.QRBatch, shortURLs map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	for _, shortCode := range batch.ShortCodes {
+		shortURL, ok := shortURLs[shortCode]
+		if !ok {
+			shortURL = fmt.Sprintf("%s/%s", s.baseURL, shortCode)
+		}
+		png, err := qrcode.Encode(models.QRScanURL(shortURL), qrcode.Medium, batch.Size)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode QR code for %s: %w", shortCode, err)
+		}
+
+		// PNGs are already compressed, so store them as-is
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     shortCode + "-qr.png",
+			Method:   zip.Store,
+			Modified: batch.CreatedAt,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to archive: %w", shortCode, err)
+		}
+		if _, err := file.Write(png); err != nil {
+			return nil, fmt.Errorf("failed to write %s to archive: %w", shortCode, err)
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish archive: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"  // Logos may be GIFs
+	_ "image/jpeg" // or JPEGs, besides PNGs
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/fetcher"
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// Limits on the logos drawn in QR codes
+const (
+	qrLogoFetchTimeout = 5 * time.Second
+	qrLogoMaxBytes     = 1 << 20 // 1 MiB
+	qrLogoMaxPixels    = 2048    // Per side, so a small file can't decode to a huge image
+	qrLogoCacheTTL     = 10 * time.Minute
+	qrLogoCacheSize    = 100
+)
+
+// QRCodeService draws QR codes in a style, fetching the style's logo
+type QRCodeService interface {
+	Render(ctx context.Context, content string, style models.QRStyle, format string) ([]byte, error)
+}
+
+// cachedLogo is a decoded logo and when it must be fetched again
+type cachedLogo struct {
+	image     image.Image
+	expiresAt time.Time
+}
+
+// qrCodeService implements QRCodeService interface
+type qrCodeService struct {
+	fetcher *fetcher.Fetcher
+
+	// Logos are usually the same few brand images, so keep them decoded for a while
+	mu    sync.Mutex
+	logos map[string]cachedLogo
+}
+
+// NewQRCodeService creates a QR code service fetching logos through fetcher
+func NewQRCodeService(fetcher *fetcher.Fetcher) QRCodeService {
+	return &qrCodeService{
+		fetcher: fetcher,
+		logos:   make(map[string]cachedLogo),
+	}
+}
+
+// Render validates the style and draws content as a PNG or SVG QR code
+func (s *qrCodeService) Render(ctx context.Context, content string, style models.QRStyle, format string) ([]byte, error) {
+	if err := style.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid QR code style", err)
+	}
+	if format != models.QRFormatPNG && format != models.QRFormatSVG {
+		return nil, errors.NewValidationError("Invalid QR code format", fmt.Errorf("format must be %s or %s", models.QRFormatPNG, models.QRFormatSVG))
+	}
+
+	var logo image.Image
+	if style.Logo != "" {
+		var err error
+		if logo, err = s.getLogo(ctx, style.Logo); err != nil {
+			return nil, err
+		}
+	}
+
+	var code []byte
+	var err error
+	if format == models.QRFormatSVG {
+		code, err = style.EncodeSVG(content, logo)
+	} else {
+		code, err = style.EncodePNG(content, logo)
+	}
+	if err != nil {
+		return nil, errors.NewInternalError("Failed to generate QR code", err)
+	}
+	return code, nil
+}
+
+// getLogo returns the decoded logo at logoURL, fetching it unless cached
+func (s *qrCodeService) getLogo(ctx context.Context, logoURL string) (image.Image, error) {
+	now := time.Now()
+	s.mu.Lock()
+	cached, ok := s.logos[logoURL]
+	s.mu.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.image, nil
+	}
+
+	logo, err := s.fetchLogo(ctx, logoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.logos) >= qrLogoCacheSize {
+		for cachedURL, cached := range s.logos {
+			if !now.Before(cached.expiresAt) {
+				delete(s.logos, cachedURL)
+			}
+		}
+	}
+	if len(s.logos) < qrLogoCacheSize {
+		s.logos[logoURL] = cachedLogo{image: logo, expiresAt: now.Add(qrLogoCacheTTL)}
+	}
+	return logo, nil
+}
+
+// fetchLogo downloads and decodes a logo, refusing oversized images
+func (s *qrCodeService) fetchLogo(ctx context.Context, logoURL string) (image.Image, error) {
+	ctx, cancel := context.WithTimeout(ctx, qrLogoFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, logoURL, nil)
+	if err != nil {
+		return nil, errors.NewValidationError("Invalid QR logo URL", err)
+	}
+	resp, err := s.fetcher.Do(req)
+	if err != nil {
+		return nil, errors.NewBadRequestError("Failed to fetch QR logo", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.NewBadRequestError("Failed to fetch QR logo", fmt.Errorf("logo responded with status %d", resp.StatusCode))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, qrLogoMaxBytes+1))
+	if err != nil {
+		return nil, errors.NewBadRequestError("Failed to fetch QR logo", err)
+	}
+	if len(data) > qrLogoMaxBytes {
+		return nil, errors.NewValidationError("QR logo is too large", fmt.Errorf("logo must be at most %d bytes", qrLogoMaxBytes))
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.NewValidationError("QR logo must be a PNG, JPEG or GIF image", err)
+	}
+	if config.Width > qrLogoMaxPixels || config.Height > qrLogoMaxPixels {
+		return nil, errors.NewValidationError("QR logo is too large", fmt.Errorf("logo must be at most %dx%d pixels", qrLogoMaxPixels, qrLogoMaxPixels))
+	}
+	logo, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.NewValidationError("QR logo must be a PNG, JPEG or GIF image", err)
+	}
+	return logo, nil
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+	"github.com/skip2/go-qrcode"
+)
+
+// Bounds of a rendered QR payload image, in pixels
+const (
+	DefaultQRPayloadSize = 256
+	MinQRPayloadSize     = 64
+	MaxQRPayloadSize     = 2048
+)
+
+// QRPayloadService interface defines the contract for non-URL QR codes
+type QRPayloadService interface {
+	CreatePayload(ctx context.Context, userID int, req *models.CreateQRPayloadRequest, clientIP, userAgent string) (*models.QRPayload, error)
+	GetPayloads(ctx context.Context, userID int) ([]models.QRPayload, error)
+	GetPayload(ctx context.Context, id int, userID int) (*models.QRPayload, error)
+	UpdatePayload(ctx context.Context, id int, userID int, req *models.UpdateQRPayloadRequest) (*models.QRPayload, error)
+	DeletePayload(ctx context.Context, id int, userID int) error
+	RenderQRCode(ctx context.Context, id int, userID int, mode string, size int) ([]byte, error)
+	GetLanding(ctx context.Context, token, grant string) (*models.QRPayload, error)
+}
+
+// qrPayloadService implements QRPayloadService interface
+type qrPayloadService struct {
+	payloadRepo repository.QRPayloadRepository
+	cacheRepo   repository.CacheRepository
+	urlService  URLService
+	regions     *repository.RegionRouter
+	baseURL     string
+}
+
+// NewQRPayloadService creates a new QR payload service
+func NewQRPayloadService(payloadRepo repository.QRPayloadRepository, cacheRepo repository.CacheRepository, urlService URLService, regions *repository.RegionRouter, baseURL string) QRPayloadService {
+	return &qrPayloadService{
+		payloadRepo: payloadRepo,
+		cacheRepo:   cacheRepo,
+		urlService:  urlService,
+		regions:     regions,
+		baseURL:     baseURL,
+	}
+}
+
+// CreatePayload creates a payload and the short link its QR code points to.
+// The link sends visitors to the payload's landing page, so every scan is
+// counted as a click of the link and shows up in its analytics.
+func (s *qrPayloadService) CreatePayload(ctx context.Context, userID int, req *models.CreateQRPayloadRequest, clientIP, userAgent string) (*models.QRPayload, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid QR payload request", err)
+	}
+
+	// The landing page is found by an unguessable token rather than the payload ID
+	token, err := randomHex(16)
+	if err != nil {
+		return nil, errors.NewInternalError("Failed to generate QR payload token", err)
+	}
+
+	link, err := s.urlService.CreateURL(ctx, &models.CreateURLRequest{
+		URL:             s.baseURL + models.QRLandingPath + token,
+		CustomCode:      req.CustomCode,
+		SkipUTMDefaults: true,
+	}, userID, clientIP, userAgent)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := &models.QRPayload{
+		UserID:    userID,
+		URLID:     link.ID,
+		Token:     token,
+		Type:      req.Type,
+		Data:      req.QRPayloadData,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+		ShortCode: link.ShortCode,
+	}
+	payload, err = s.payloadRepo.Create(ctx, payload)
+	if err != nil {
+		if deleteErr := s.urlService.DeleteURL(ctx, link.ShortCode, userID); deleteErr != nil {
+			log.Printf("Failed to remove short link %s of failed QR payload: %v", link.ShortCode, deleteErr)
+		}
+		return nil, errors.NewDatabaseError("Failed to create QR payload", err)
+	}
+
+	// Reload it with its link, which may have been given a custom domain
+	return s.GetPayload(ctx, payload.ID, userID)
+}
+
+// GetPayloads returns the user's QR payloads
+func (s *qrPayloadService) GetPayloads(ctx context.Context, userID int) ([]models.QRPayload, error) {
+	payloads, err := s.payloadRepo.GetAllByUser(ctx, userID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get QR payloads", err)
+	}
+	for i := range payloads {
+		s.decorate(&payloads[i])
+	}
+	return payloads, nil
+}
+
+// GetPayload returns one of the user's QR payloads
+func (s *qrPayloadService) GetPayload(ctx context.Context, id int, userID int) (*models.QRPayload, error) {
+	payload, err := s.payloadRepo.GetByID(ctx, id, userID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("QR payload not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get QR payload", err)
+	}
+	return s.decorate(payload), nil
+}
+
+// UpdatePayload replaces a payload's content. Printed QR codes keep working
+// because they point at the unchanged short link.
+func (s *qrPayloadService) UpdatePayload(ctx context.Context, id int, userID int, req *models.UpdateQRPayloadRequest) (*models.QRPayload, error) {
+	payload, err := s.GetPayload(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.QRPayloadData.Validate(payload.Type); err != nil {
+		return nil, errors.NewValidationError("Invalid QR payload request", err)
+	}
+
+	if err := s.payloadRepo.UpdateData(ctx, id, req.QRPayloadData); err != nil {
+		return nil, errors.NewDatabaseError("Failed to update QR payload", err)
+	}
+	payload.Data = req.QRPayloadData
+	payload.UpdatedAt = time.Now()
+	return payload, nil
+}
+
+// DeletePayload deletes a payload along with its short link
+func (s *qrPayloadService) DeletePayload(ctx context.Context, id int, userID int) error {
+	payload, err := s.GetPayload(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	// The payload row is removed with its link
+	return s.urlService.DeleteURL(ctx, payload.ShortCode, userID)
+}
+
+// RenderQRCode renders a payload's QR code as a PNG. Landing mode encodes the
+// short link, so scans are counted; direct mode encodes the payload itself,
+// which phones handle offline (e.g. joining a Wi-Fi network) but can't be tracked.
+func (s *qrPayloadService) RenderQRCode(ctx context.Context, id int, userID int, mode string, size int) ([]byte, error) {
+	if size < MinQRPayloadSize || size > MaxQRPayloadSize {
+		return nil, errors.NewValidationError(fmt.Sprintf("size must be between %d and %d pixels", MinQRPayloadSize, MaxQRPayloadSize), nil)
+	}
+
+	payload, err := s.GetPayload(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var content string
+	switch mode {
+	case models.QRModeLanding:
+		content = models.QRScanURL(payload.ShortURL)
+	case models.QRModeDirect:
+		content = payload.Encode()
+	default:
+		return nil, errors.NewValidationError(fmt.Sprintf("mode must be %s or %s", models.QRModeLanding, models.QRModeDirect), nil)
+	}
+
+	png, err := qrcode.Encode(content, qrcode.Medium, size)
+	if err != nil {
+		return nil, errors.NewValidationError("Payload is too large for a QR code", err)
+	}
+	return png, nil
+}
+
+// GetLanding returns the payload served at a landing token. The token doesn't
+// say which data region holds the payload, so each region is checked.
+func (s *qrPayloadService) GetLanding(ctx context.Context, token, grant string) (*models.QRPayload, error) {
+	for _, region := range s.regions.Regions() {
+		regionCtx := repository.WithRegion(ctx, region)
+		payload, err := s.payloadRepo.GetByToken(regionCtx, token)
+		if err == nil {
+			if err := s.checkLanding(regionCtx, payload, grant); err != nil {
+				return nil, err
+			}
+			return payload, nil
+		}
+		if !repository.IsNotFound(err) {
+			return nil, errors.NewDatabaseError("Failed to get QR payload", err)
+		}
+	}
+	return nil, errors.NewNotFoundError("QR code not found", nil)
+}
+
+// checkLanding applies the checks of a visit to the payload's short link to a
+// visit of its landing page, which would otherwise get around them: the link
+// must be active and not scheduled, and landing pages of password-protected
+// or click-limited links need the one-time grant of a visit that passed them
+func (s *qrPayloadService) checkLanding(ctx context.Context, payload *models.QRPayload, grant string) error {
+	url, err := s.urlService.GetURLForRedirect(ctx, payload.ShortCode)
+	if err != nil {
+		return err
+	}
+	if !url.IsPasswordProtected() && !url.HasClickLimit() {
+		return nil
+	}
+
+	if grant != "" {
+		granted, err := s.cacheRepo.Take(ctx, qrLandingGrantKey(grant))
+		if err != nil && !repository.IsCacheMiss(err) {
+			return errors.NewRedisError("Failed to check QR landing grant", err)
+		}
+		if err == nil && granted == payload.Token {
+			return nil
+		}
+	}
+	return errors.NewForbiddenError("Open this QR code through its short link", nil)
+}
+
+// qrLandingGrantKey is where a landing grant's token is kept
+func qrLandingGrantKey(grant string) string {
+	return "qr_landing_grant:" + grant
+}
+
+// decorate fills in the URLs of a payload's short link, on its custom domain
+// if it has one, and QR code
+func (s *qrPayloadService) decorate(payload *models.QRPayload) *models.QRPayload {
+	link := models.URL{ShortCode: payload.ShortCode, Domain: payload.Domain}
+	payload.ShortURL = link.ShortURL(s.baseURL)
+	payload.QRCodeURL = fmt.Sprintf("%s/api/v1/qr-payloads/%d/qr", s.baseURL, payload.ID)
+	return payload
+}
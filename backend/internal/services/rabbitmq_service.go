@@ -1,15 +1,25 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"runtime/debug"
+	"sync"
 	"time"
 
 	"github.com/hpower2/url-shortener/internal/config"
+	"github.com/hpower2/url-shortener/internal/models"
 	amqp "github.com/rabbitmq/amqp091-go"
 )
 
+// emailConsumerTag identifies the email queue consumer on its channel so it can be cancelled
+const emailConsumerTag = "email_queue_consumer"
+
+// purgeConsumerTag identifies the purge queue consumer on its channel so it can be cancelled
+const purgeConsumerTag = "purge_queue_consumer"
+
 // EmailMessage represents an email message in the queue
 type EmailMessage struct {
 	To         string `json:"to"`
@@ -17,18 +27,30 @@ type EmailMessage struct {
 	Body       string `json:"body"`
 	Type       string `json:"type"` // "otp" or "welcome"
 	OTPCode    string `json:"otp_code,omitempty"`
+	OTPID      int    `json:"otp_id,omitempty"` // OTP record the email delivers; its code is loaded when sending
 	Purpose    string `json:"purpose,omitempty"`
 	Retry      int    `json:"retry"`
 	MaxRetries int    `json:"max_retries"`
 }
 
+// PurgeMessage asks for the next chunk of an account deletion to be purged.
+// The deletion's progress is kept in the database, so the message only
+// identifies it.
+type PurgeMessage struct {
+	DeletionID int `json:"deletion_id"`
+}
+
 // RabbitMQService interface defines the contract for RabbitMQ operations
 type RabbitMQService interface {
 	Connect() error
 	Close() error
 	PublishEmail(message *EmailMessage) error
-	ConsumeEmails(handler func(*EmailMessage) error) error
+	ConsumeEmails(ctx context.Context, handler func(*EmailMessage) error) error
 	PublishDelayedEmail(message *EmailMessage, delay time.Duration) error
+	PublishPurge(message *PurgeMessage, delay time.Duration) error
+	ConsumePurges(ctx context.Context, handler func(*PurgeMessage)) error
+	PublishURLEvent(event *models.URLEvent) error
+	Ping() error
 }
 
 // rabbitMQService implements RabbitMQService interface
@@ -98,11 +120,55 @@ func (s *rabbitMQService) Connect() error {
 		return fmt.Errorf("failed to declare delayed email queue: %w", err)
 	}
 
+	// Declare account purge queue
+	_, err = s.channel.QueueDeclare(
+		"purge_queue", // name
+		true,          // durable
+		false,         // delete when unused
+		false,         // exclusive
+		false,         // no-wait
+		nil,           // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare purge queue: %w", err)
+	}
+
+	// Declare delayed purge queue; each message sets its own delay
+	_, err = s.channel.QueueDeclare(
+		"purge_delay_queue", // name
+		true,                // durable
+		false,               // delete when unused
+		false,               // exclusive
+		false,               // no-wait
+		amqp.Table{
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": "purge_queue",
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare delayed purge queue: %w", err)
+	}
+
+	// Declare the link event exchange; consumers bind their own queues to it
+	err = s.channel.ExchangeDeclare(
+		models.URLEventsExchange, // name
+		"topic",                  // kind
+		true,                     // durable
+		false,                    // auto-deleted
+		false,                    // internal
+		false,                    // no-wait
+		nil,                      // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare link event exchange: %w", err)
+	}
+
 	log.Println("Connected to RabbitMQ successfully")
 	return nil
 }
 
-// Close closes the RabbitMQ connection
+// Close closes the channel and then the RabbitMQ connection. Messages still
+// unacknowledged at that point are requeued by the broker.
 func (s *rabbitMQService) Close() error {
 	if s.channel != nil {
 		if err := s.channel.Close(); err != nil {
@@ -117,6 +183,14 @@ func (s *rabbitMQService) Close() error {
 	return nil
 }
 
+// Ping reports whether the connection to RabbitMQ is open
+func (s *rabbitMQService) Ping() error {
+	if s.connection == nil || s.connection.IsClosed() {
+		return fmt.Errorf("not connected to RabbitMQ")
+	}
+	return nil
+}
+
 // PublishEmail publishes an email message to the queue
 func (s *rabbitMQService) PublishEmail(message *EmailMessage) error {
 	if s.channel == nil {
@@ -183,76 +257,267 @@ func (s *rabbitMQService) PublishDelayedEmail(message *EmailMessage, delay time.
 	return nil
 }
 
-// ConsumeEmails consumes email messages from the queue
-func (s *rabbitMQService) ConsumeEmails(handler func(*EmailMessage) error) error {
+// PublishPurge publishes a purge message to the queue, or after delay when it is positive
+func (s *rabbitMQService) PublishPurge(message *PurgeMessage, delay time.Duration) error {
+	if s.channel == nil {
+		return fmt.Errorf("RabbitMQ channel not initialized")
+	}
+
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	publishing := amqp.Publishing{
+		ContentType:  "application/json",
+		Body:         body,
+		DeliveryMode: amqp.Persistent,
+	}
+	queue := "purge_queue"
+	if delay > 0 {
+		queue = "purge_delay_queue"
+		publishing.Expiration = fmt.Sprintf("%d", delay.Milliseconds())
+	}
+
+	if err := s.channel.Publish("", queue, false, false, publishing); err != nil {
+		return fmt.Errorf("failed to publish purge message: %w", err)
+	}
+	return nil
+}
+
+// PublishURLEvent publishes a link lifecycle event to the link event exchange,
+// routed by its type
+func (s *rabbitMQService) PublishURLEvent(event *models.URLEvent) error {
+	if s.channel == nil {
+		return fmt.Errorf("RabbitMQ channel not initialized")
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal link event: %w", err)
+	}
+
+	err = s.channel.Publish(
+		models.URLEventsExchange, // exchange
+		event.RoutingKey(),       // routing key
+		false,                    // mandatory
+		false,                    // immediate
+		amqp.Publishing{
+			ContentType:  "application/json",
+			Body:         body,
+			DeliveryMode: amqp.Persistent,
+			MessageId:    fmt.Sprintf("%s/%d", event.ShortCode, event.ID), // IDs are per region; short codes are unique
+			Timestamp:    event.CreatedAt,
+			Type:         event.Type,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to publish link event: %w", err)
+	}
+	return nil
+}
+
+// ConsumePurges consumes purge messages from the queue with a pool of workers,
+// returning once the delivery channel closes and every worker has finished.
+// The handler takes care of failures itself, so every message is acknowledged.
+// Cancelling ctx requeues prefetched messages not yet started.
+func (s *rabbitMQService) ConsumePurges(ctx context.Context, handler func(*PurgeMessage)) error {
 	if s.channel == nil {
 		return fmt.Errorf("RabbitMQ channel not initialized")
 	}
 
-	// Set QoS to process one message at a time
 	err := s.channel.Qos(
-		1,     // prefetch count
-		0,     // prefetch size
-		false, // global
+		s.config.Prefetch, // prefetch count
+		0,                 // prefetch size
+		false,             // global
 	)
 	if err != nil {
 		return fmt.Errorf("failed to set QoS: %w", err)
 	}
 
 	msgs, err := s.channel.Consume(
-		"email_queue", // queue
-		"",            // consumer
-		false,         // auto-ack (we'll manually ack)
-		false,         // exclusive
-		false,         // no-local
-		false,         // no-wait
-		nil,           // args
+		"purge_queue",    // queue
+		purgeConsumerTag, // consumer
+		false,            // auto-ack (we'll manually ack)
+		false,            // exclusive
+		false,            // no-local
+		false,            // no-wait
+		nil,              // args
 	)
 	if err != nil {
-		return fmt.Errorf("failed to register consumer: %w", err)
+		return fmt.Errorf("failed to register purge consumer: %w", err)
 	}
 
-	log.Println("Starting email queue consumer...")
-
-	for msg := range msgs {
-		var emailMsg EmailMessage
-		if err := json.Unmarshal(msg.Body, &emailMsg); err != nil {
-			log.Printf("Failed to unmarshal message: %v", err)
-			msg.Nack(false, false) // Reject message
-			continue
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+			log.Println("Cancelling purge queue consumer...")
+			if err := s.channel.Cancel(purgeConsumerTag, false); err != nil {
+				log.Printf("Failed to cancel purge queue consumer: %v", err)
+			}
+		case <-stopped:
 		}
+	}()
+
+	log.Printf("Starting purge queue consumer with %d workers...", s.config.Workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range msgs {
+				if ctx.Err() != nil {
+					msg.Nack(false, true) // Requeue for the next consumer
+					continue
+				}
+
+				var purgeMsg PurgeMessage
+				if err := json.Unmarshal(msg.Body, &purgeMsg); err != nil {
+					log.Printf("Failed to unmarshal purge message: %v", err)
+					msg.Nack(false, false) // Reject message
+					continue
+				}
+				runPurgeHandler(handler, &purgeMsg)
+				msg.Ack(false)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
 
-		log.Printf("Processing email message: %s", emailMsg.To)
+// ConsumeEmails consumes email messages from the queue with a pool of workers,
+// returning once the delivery channel closes and every worker has finished.
+// Cancelling ctx stops pulling messages: messages being handled are finished,
+// and prefetched ones not yet started are requeued.
+func (s *rabbitMQService) ConsumeEmails(ctx context.Context, handler func(*EmailMessage) error) error {
+	if s.channel == nil {
+		return fmt.Errorf("RabbitMQ channel not initialized")
+	}
 
-		// Handle the message
-		if err := handler(&emailMsg); err != nil {
-			log.Printf("Failed to handle email message: %v", err)
+	// Let the broker hand out enough messages to keep every worker busy
+	err := s.channel.Qos(
+		s.config.Prefetch, // prefetch count
+		0,                 // prefetch size
+		false,             // global
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set QoS: %w", err)
+	}
 
-			// Increment retry count
-			emailMsg.Retry++
+	msgs, err := s.channel.Consume(
+		"email_queue",    // queue
+		emailConsumerTag, // consumer
+		false,            // auto-ack (we'll manually ack)
+		false,            // exclusive
+		false,            // no-local
+		false,            // no-wait
+		nil,              // args
+	)
+	if err != nil {
+		return fmt.Errorf("failed to register consumer: %w", err)
+	}
 
-			// If max retries reached, reject the message
-			if emailMsg.Retry >= emailMsg.MaxRetries {
-				log.Printf("Max retries reached for email to %s, rejecting message", emailMsg.To)
-				msg.Nack(false, false) // Reject without requeue
-				continue
+	// Cancelling the consumer stops new deliveries; msgs closes once the
+	// prefetched ones have been handed out
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+			log.Println("Cancelling email queue consumer...")
+			if err := s.channel.Cancel(emailConsumerTag, false); err != nil {
+				log.Printf("Failed to cancel email queue consumer: %v", err)
 			}
-
-			// Publish to delayed queue for retry
-			delay := time.Duration(emailMsg.Retry*30) * time.Second // Exponential backoff
-			if err := s.PublishDelayedEmail(&emailMsg, delay); err != nil {
-				log.Printf("Failed to publish retry message: %v", err)
-			} else {
-				log.Printf("Scheduled retry %d/%d for email to %s (delay: %v)",
-					emailMsg.Retry, emailMsg.MaxRetries, emailMsg.To, delay)
+		case <-stopped:
+		}
+	}()
+
+	log.Printf("Starting email queue consumer with %d workers (prefetch %d)...", s.config.Workers, s.config.Prefetch)
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range msgs {
+				if ctx.Err() != nil {
+					msg.Nack(false, true) // Requeue for the next consumer
+					continue
+				}
+				s.handleDelivery(msg, handler)
 			}
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
 
-			msg.Ack(false) // Acknowledge original message
+// handleDelivery processes one queued email, scheduling a delayed retry when
+// the handler fails and acknowledging the original message
+func (s *rabbitMQService) handleDelivery(msg amqp.Delivery, handler func(*EmailMessage) error) {
+	var emailMsg EmailMessage
+	if err := json.Unmarshal(msg.Body, &emailMsg); err != nil {
+		log.Printf("Failed to unmarshal message: %v", err)
+		msg.Nack(false, false) // Reject message
+		return
+	}
+
+	log.Printf("Processing email message: %s", emailMsg.To)
+
+	// Handle the message
+	if err := runEmailHandler(handler, &emailMsg); err != nil {
+		log.Printf("Failed to handle email message: %v", err)
+
+		// Increment retry count
+		emailMsg.Retry++
+
+		// If max retries reached, reject the message
+		if emailMsg.Retry >= emailMsg.MaxRetries {
+			log.Printf("Max retries reached for email to %s, rejecting message", emailMsg.To)
+			msg.Nack(false, false) // Reject without requeue
+			return
+		}
+
+		// Publish to delayed queue for retry
+		delay := time.Duration(emailMsg.Retry*30) * time.Second // Exponential backoff
+		if err := s.PublishDelayedEmail(&emailMsg, delay); err != nil {
+			log.Printf("Failed to publish retry message: %v", err)
 		} else {
-			log.Printf("Email message processed successfully: %s", emailMsg.To)
-			msg.Ack(false) // Acknowledge successful processing
+			log.Printf("Scheduled retry %d/%d for email to %s (delay: %v)",
+				emailMsg.Retry, emailMsg.MaxRetries, emailMsg.To, delay)
 		}
+
+		msg.Ack(false) // Acknowledge original message
+	} else {
+		log.Printf("Email message processed successfully: %s", emailMsg.To)
+		msg.Ack(false) // Acknowledge successful processing
 	}
+}
 
-	return nil
+// runEmailHandler calls handler, turning a panic into an error so one bad
+// message is retried like any other failure instead of killing its worker
+func runEmailHandler(handler func(*EmailMessage) error, message *EmailMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("email handler panicked: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return handler(message)
+}
+
+// runPurgeHandler calls handler, logging a panic instead of killing its worker.
+// The deletion can be resumed from the admin API.
+func runPurgeHandler(handler func(*PurgeMessage), message *PurgeMessage) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Purge handler panicked on account deletion %d: %v\n%s", message.DeletionID, r, debug.Stack())
+		}
+	}()
+	handler(message)
 }
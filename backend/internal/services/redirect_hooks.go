@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/hpower2/url-shortener/internal/models"
+)
+
+// RedirectVisit describes a visitor being redirected through a short link
+type RedirectVisit struct {
+	URL       *models.URL
+	ClientIP  string
+	UserAgent string
+	Referer   string
+	Request   *http.Request // Incoming request, for hooks that inspect cookies or headers
+}
+
+// RedirectHook lets a deployment plug custom logic into link resolution (for example an
+// internal SSO gate or a legal interstitial) without changing the service. Hooks are
+// registered in main and run in registration order.
+type RedirectHook interface {
+	// BeforeRedirect runs after the link's own access rules. Returning a non-empty URL
+	// sends the visitor there instead of the destination; returning an error rejects the
+	// visit. Either result stops later hooks and skips click recording.
+	BeforeRedirect(ctx context.Context, visit *RedirectVisit) (string, error)
+
+	// AfterClick runs once a click has been recorded. Errors are logged, never surfaced.
+	AfterClick(ctx context.Context, url *models.URL, click *models.ClickEvent) error
+}
+
+// RedirectHookFuncs adapts plain functions to RedirectHook; either may be nil
+type RedirectHookFuncs struct {
+	Before func(ctx context.Context, visit *RedirectVisit) (string, error)
+	After  func(ctx context.Context, url *models.URL, click *models.ClickEvent) error
+}
+
+// BeforeRedirect implements RedirectHook
+func (f RedirectHookFuncs) BeforeRedirect(ctx context.Context, visit *RedirectVisit) (string, error) {
+	if f.Before == nil {
+		return "", nil
+	}
+	return f.Before(ctx, visit)
+}
+
+// AfterClick implements RedirectHook
+func (f RedirectHookFuncs) AfterClick(ctx context.Context, url *models.URL, click *models.ClickEvent) error {
+	if f.After == nil {
+		return nil
+	}
+	return f.After(ctx, url, click)
+}
+
+// RegisterHook appends a hook to the redirect pipeline. Hooks must be registered
+// before the server starts handling requests.
+func (s *urlService) RegisterHook(hook RedirectHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+// HasRedirectHooks returns true if any hooks are registered, in which case every
+// redirect must reach the server and browsers must not cache it
+func (s *urlService) HasRedirectHooks() bool {
+	return len(s.hooks) > 0
+}
+
+// RunBeforeRedirectHooks runs the registered hooks in order until one redirects or rejects the visit
+func (s *urlService) RunBeforeRedirectHooks(ctx context.Context, visit *RedirectVisit) (string, error) {
+	for _, hook := range s.hooks {
+		redirectTo, err := hook.BeforeRedirect(ctx, visit)
+		if err != nil || redirectTo != "" {
+			return redirectTo, err
+		}
+	}
+	return "", nil
+}
+
+// runAfterClickHooks runs every registered hook for a recorded click
+func (s *urlService) runAfterClickHooks(ctx context.Context, url *models.URL, click *models.ClickEvent) {
+	for _, hook := range s.hooks {
+		if err := hook.AfterClick(ctx, url, click); err != nil {
+			log.Printf("Redirect hook failed after click on %s: %v", url.ShortCode, err)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// DefaultReservedPrefixes are top-level paths kept free for routes that don't exist yet,
+// so adding them later never takes over a user's link
+var DefaultReservedPrefixes = []string{
+	"about", "account", "admin", "api", "app", "assets", "auth", "billing", "dashboard",
+	"docs", "favicon.ico", "health", "help", "login", "logout", "metrics", "privacy",
+	"register", "robots.txt", "settings", "signup", "static", "status", "terms", "unlock",
+}
+
+// codeMigrationLease is how long one instance holds the reserved code
+// migration of a region, keeping the others from migrating it concurrently
+const codeMigrationLease = time.Hour
+
+// maxCodeMigrationAttempts bounds the search for a free replacement code
+const maxCodeMigrationAttempts = 100
+
+// reservedCodeRefreshInterval is how often codes reserved through the admin
+// API are reloaded, picking up changes made on other instances
+const reservedCodeRefreshInterval = time.Minute
+
+// ReservedRouteService tracks the top-level path segments served by the application.
+// Because short links are served from the catch-all /:shortCode route, these
+// segments can't be used as short codes. It also blocks codes reserved by the
+// operator, in the configuration or through the admin API, including honeytokens.
+type ReservedRouteService interface {
+	Reserve(path string)
+	IsReserved(shortCode string) bool
+	IsHoneytoken(shortCode string) bool
+	Prefixes() []string
+	MigrateConflictingCodes(ctx context.Context) (int, error)
+	Start(ctx context.Context)
+	GetReservedCodes(ctx context.Context) ([]models.ReservedCode, error)
+	ReserveCode(ctx context.Context, req *models.ReserveCodeRequest) (*models.ReservedCode, error)
+	ReleaseCode(ctx context.Context, code string) error
+}
+
+// reservedRouteService implements ReservedRouteService interface
+type reservedRouteService struct {
+	urlRepo      repository.URLRepository
+	userRepo     repository.UserRepository
+	cacheRepo    repository.CacheRepository
+	emailService EmailService
+	orgService   OrganizationService
+	webhooks     WebhookService
+	regions      *repository.RegionRouter
+	baseURL      string
+
+	// prefixes are blocked for new links; routes are the prefixes actually
+	// served, the only ones existing links are moved out of the way of
+	mu       sync.RWMutex
+	prefixes map[string]bool
+	routes   map[string]bool
+
+	// Codes reserved in the configuration, and through the admin API
+	codeRepo    repository.ReservedCodeRepository
+	configCodes map[string]bool
+	adminCodes  map[string]bool
+	honeytokens map[string]bool
+}
+
+// NewReservedRouteService creates a reserved route registry seeded with prefixes
+// and the codes reserved in the configuration
+func NewReservedRouteService(urlRepo repository.URLRepository, userRepo repository.UserRepository, cacheRepo repository.CacheRepository, codeRepo repository.ReservedCodeRepository, emailService EmailService, orgService OrganizationService, webhooks WebhookService, regions *repository.RegionRouter, baseURL string, prefixes []string, configCodes []string) ReservedRouteService {
+	s := &reservedRouteService{
+		urlRepo:      urlRepo,
+		userRepo:     userRepo,
+		cacheRepo:    cacheRepo,
+		emailService: emailService,
+		orgService:   orgService,
+		webhooks:     webhooks,
+		regions:      regions,
+		baseURL:      baseURL,
+		prefixes:     make(map[string]bool),
+		routes:       make(map[string]bool),
+		codeRepo:     codeRepo,
+		configCodes:  make(map[string]bool),
+		adminCodes:   make(map[string]bool),
+		honeytokens:  make(map[string]bool),
+	}
+	for _, prefix := range prefixes {
+		if segment := routeSegment(prefix); segment != "" {
+			s.prefixes[segment] = true
+		}
+	}
+	for _, code := range configCodes {
+		normalized, err := models.NormalizeReservedCode(code)
+		if err != nil {
+			log.Printf("Ignoring reserved code %q: %v", code, err)
+			continue
+		}
+		s.configCodes[normalized] = true
+	}
+	return s
+}
+
+// Reserve reserves the first segment of a served route path, and moves
+// existing links out of its way on the next migration. Parameter and
+// wildcard segments (such as the short link route itself) are ignored.
+func (s *reservedRouteService) Reserve(path string) {
+	segment := routeSegment(path)
+	if segment == "" {
+		return
+	}
+
+	s.mu.Lock()
+	s.prefixes[segment] = true
+	s.routes[segment] = true
+	s.mu.Unlock()
+}
+
+// routeSegment returns the lowercased first segment of a path, or "" for
+// parameter and wildcard segments
+func routeSegment(path string) string {
+	segment := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+	if segment == "" || strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+		return ""
+	}
+	return strings.ToLower(segment)
+}
+
+// IsReserved reports whether a short code collides with a reserved path or
+// code, ignoring case
+func (s *reservedRouteService) IsReserved(shortCode string) bool {
+	code := strings.ToLower(shortCode)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.prefixes[code] || s.configCodes[code] || s.adminCodes[code]
+}
+
+// IsHoneytoken reports whether a short code is a honeytoken. Only the exact
+// code is one, since routes are matched case-sensitively and no link can
+// take another casing of it.
+func (s *reservedRouteService) IsHoneytoken(shortCode string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.honeytokens[shortCode]
+}
+
+// Prefixes returns the reserved path segments in alphabetical order
+func (s *reservedRouteService) Prefixes() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	prefixes := make([]string, 0, len(s.prefixes))
+	for prefix := range s.prefixes {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// Start loads the codes reserved through the admin API, and keeps reloading
+// them in the background until ctx is cancelled
+func (s *reservedRouteService) Start(ctx context.Context) {
+	if err := s.loadAdminCodes(ctx); err != nil {
+		log.Printf("Failed to load reserved codes: %v", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(reservedCodeRefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.loadAdminCodes(ctx); err != nil {
+					log.Printf("Failed to reload reserved codes: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// loadAdminCodes replaces the codes reserved through the admin API with the stored ones
+func (s *reservedRouteService) loadAdminCodes(ctx context.Context) error {
+	codes, err := s.codeRepo.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	adminCodes := make(map[string]bool, len(codes))
+	honeytokens := make(map[string]bool)
+	for _, code := range codes {
+		adminCodes[code.Code] = true
+		if code.Honeytoken {
+			honeytokens[code.Code] = true
+		}
+	}
+
+	s.mu.Lock()
+	s.adminCodes = adminCodes
+	s.honeytokens = honeytokens
+	s.mu.Unlock()
+	return nil
+}
+
+// GetReservedCodes lists every reserved code: route prefixes, configured codes
+// and codes reserved through the admin API
+func (s *reservedRouteService) GetReservedCodes(ctx context.Context) ([]models.ReservedCode, error) {
+	adminCodes, err := s.codeRepo.GetAll(ctx)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get reserved codes", err)
+	}
+
+	codes := make([]models.ReservedCode, 0, len(adminCodes))
+	for _, prefix := range s.Prefixes() {
+		codes = append(codes, models.ReservedCode{Code: prefix, Source: models.ReservedCodeSourceRoute})
+	}
+
+	s.mu.RLock()
+	configCodes := make([]string, 0, len(s.configCodes))
+	for code := range s.configCodes {
+		configCodes = append(configCodes, code)
+	}
+	s.mu.RUnlock()
+	sort.Strings(configCodes)
+	for _, code := range configCodes {
+		codes = append(codes, models.ReservedCode{Code: code, Source: models.ReservedCodeSourceConfig})
+	}
+
+	return append(codes, adminCodes...), nil
+}
+
+// ReserveCode stops new links from taking a code. Existing links with the code
+// keep working, so a honeytoken must be a code no link uses.
+func (s *reservedRouteService) ReserveCode(ctx context.Context, req *models.ReserveCodeRequest) (*models.ReservedCode, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid reserved code", err)
+	}
+	if s.IsReserved(req.Code) {
+		return nil, errors.NewAlreadyExistsError(fmt.Sprintf("Code %q is already reserved", req.Code), nil)
+	}
+	if req.Honeytoken {
+		exists, err := s.urlRepo.ExistsByShortCode(ctx, req.Code)
+		if err != nil {
+			return nil, errors.NewDatabaseError("Failed to check short code", err)
+		}
+		if exists {
+			return nil, errors.NewConflictError(fmt.Sprintf("Code %q is used by a link and can't be a honeytoken", req.Code), nil)
+		}
+	}
+
+	code := &models.ReservedCode{Code: req.Code, Source: models.ReservedCodeSourceAdmin, Reason: req.Reason, Honeytoken: req.Honeytoken}
+	created, err := s.codeRepo.Create(ctx, code)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to reserve code", err)
+	}
+	if !created {
+		return nil, errors.NewAlreadyExistsError(fmt.Sprintf("Code %q is already reserved", req.Code), nil)
+	}
+
+	s.mu.Lock()
+	s.adminCodes[code.Code] = true
+	if code.Honeytoken {
+		s.honeytokens[code.Code] = true
+	}
+	s.mu.Unlock()
+	return code, nil
+}
+
+// ReleaseCode makes a code reserved through the admin API available again
+func (s *reservedRouteService) ReleaseCode(ctx context.Context, code string) error {
+	code = strings.ToLower(code)
+
+	s.mu.RLock()
+	fixed := s.prefixes[code] || s.configCodes[code]
+	s.mu.RUnlock()
+	if fixed {
+		return errors.NewValidationError(fmt.Sprintf("Code %q is reserved by a route or the configuration and can't be released", code), nil)
+	}
+
+	if err := s.codeRepo.Delete(ctx, code); err != nil {
+		if repository.IsNotFound(err) {
+			return errors.NewNotFoundError("Reserved code not found", err)
+		}
+		return errors.NewDatabaseError("Failed to release reserved code", err)
+	}
+
+	s.mu.Lock()
+	delete(s.adminCodes, code)
+	delete(s.honeytokens, code)
+	s.mu.Unlock()
+	return nil
+}
+
+// MigrateConflictingCodes moves links whose code is shadowed by a served route
+// to "<code>-<n>" and tells their owners. Prefixes reserved for future routes
+// only block new links. Only exact matches are moved, since routes are matched
+// case-sensitively and other casings still redirect. Each region is migrated
+// by a single instance, under a lease.
+func (s *reservedRouteService) MigrateConflictingCodes(ctx context.Context) (int, error) {
+	s.mu.RLock()
+	routes := make([]string, 0, len(s.routes))
+	for route := range s.routes {
+		routes = append(routes, route)
+	}
+	s.mu.RUnlock()
+	if len(routes) == 0 {
+		return 0, nil
+	}
+	sort.Strings(routes)
+
+	migrated := 0
+	for _, region := range s.regions.Regions() {
+		regionCtx := repository.WithRegion(ctx, region)
+
+		acquired, err := s.cacheRepo.AcquireLease(regionCtx, "reserved-code-migration", codeMigrationLease)
+		if err != nil {
+			return migrated, fmt.Errorf("failed to acquire reserved code migration lease in %s: %w", region, err)
+		}
+		if !acquired {
+			continue
+		}
+
+		n, err := s.migrateRegion(regionCtx, routes)
+		migrated += n
+		if err != nil {
+			return migrated, err
+		}
+	}
+	return migrated, nil
+}
+
+// migrateRegion moves the links of the context's region shadowed by routes
+func (s *reservedRouteService) migrateRegion(ctx context.Context, routes []string) (int, error) {
+	urls, err := s.urlRepo.GetByShortCodes(ctx, routes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find conflicting short codes: %w", err)
+	}
+
+	migrated := 0
+	for i := range urls {
+		url := &urls[i]
+		oldCode := url.ShortCode
+
+		newCode, err := s.freeCode(ctx, oldCode)
+		if err != nil {
+			log.Printf("Failed to migrate reserved short code %s: %v", oldCode, err)
+			continue
+		}
+		if err := s.urlRepo.UpdateShortCode(ctx, url.ID, newCode); err != nil {
+			log.Printf("Failed to migrate reserved short code %s: %v", oldCode, err)
+			continue
+		}
+		url.ShortCode = newCode
+		url.UpdatedAt = time.Now()
+		migrated++
+
+		if err := s.cacheRepo.DeleteURL(ctx, oldCode); err != nil {
+			// Log error but don't fail the migration
+			log.Printf("Failed to delete URL from cache: %v", err)
+		}
+
+		s.webhooks.Dispatch(ctx, url, models.WebhookEventLinkUpdated, url)
+		s.notifyOwner(ctx, url, oldCode)
+	}
+
+	return migrated, nil
+}
+
+// freeCode finds the first unused, unreserved "<code>-<n>"
+func (s *reservedRouteService) freeCode(ctx context.Context, shortCode string) (string, error) {
+	for n := 1; n <= maxCodeMigrationAttempts; n++ {
+		candidate := fmt.Sprintf("%s-%d", shortCode, n)
+		if s.IsReserved(candidate) {
+			continue
+		}
+
+		exists, err := s.urlRepo.ExistsByShortCode(ctx, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no free short code after %d attempts", maxCodeMigrationAttempts)
+}
+
+// notifyOwner emails the owner of a migrated link its new short URL
+func (s *reservedRouteService) notifyOwner(ctx context.Context, url *models.URL, oldCode string) {
+	owner, err := s.userRepo.GetByID(ctx, url.UserID)
+	if err != nil {
+		log.Printf("Failed to get owner of migrated short code %s: %v", oldCode, err)
+		return
+	}
+
+	branding := s.orgService.GetBrandingForRecipient(ctx, owner.Email)
+	newShortURL := url.ShortURL(s.baseURL)
+	if err := s.emailService.SendShortCodeChangedEmail(owner.Email, oldCode, newShortURL, branding); err != nil {
+		log.Printf("Failed to notify owner of migrated short code %s: %v", oldCode, err)
+	}
+}
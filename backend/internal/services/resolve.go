@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// ResolveURL describes where a short link goes for the requesting client and
+// whether it is safe, without following it: no click is recorded and no
+// redirect is claimed. Links the redirect cache may hold are resolved from the
+// cache, alongside their redirect entry.
+func (s *urlService) ResolveURL(ctx context.Context, shortCode, clientIP, userAgent string) (*models.ResolvedLink, error) {
+	if !s.isKilled(shortCode) {
+		resolved, err := s.cacheRepo.GetResolvedLink(ctx, shortCode)
+		if err == nil {
+			return resolved, nil
+		}
+		if !repository.IsCacheMiss(err) {
+			log.Printf("Failed to get resolved link %s from cache: %v", shortCode, err)
+		}
+	}
+
+	url, err := s.urlRepo.GetByShortCode(ctx, shortCode)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("URL not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get URL", err)
+	}
+
+	resolved := &models.ResolvedLink{
+		ShortCode:         url.ShortCode,
+		ShortURL:          url.ShortURL(s.baseURL),
+		PasswordProtected: url.IsPasswordProtected(),
+		ClickLimited:      url.HasClickLimit(),
+		Safety:            models.SafetyClean,
+		ThreatType:        url.ThreatType,
+	}
+
+	switch {
+	case url.IsExpired():
+		resolved.Status = models.ResolveStatusExpired
+	case !url.IsActive || s.isKilled(shortCode):
+		resolved.Status = models.ResolveStatusInactive
+	case url.IsScheduled():
+		resolved.Status = models.ResolveStatusScheduled
+	default:
+		resolved.Status = models.ResolveStatusActive
+	}
+
+	// Threats deactivate links for review, unless they only warrant a warning
+	switch {
+	case url.NeedsReview && url.ThreatType != "" && !warningThreats[url.ThreatType]:
+		resolved.Safety = models.SafetyMalicious
+	case url.NeedsReview:
+		resolved.Safety = models.SafetyPendingReview
+	case s.ShouldWarn(ctx, url):
+		resolved.Safety = models.SafetySuspicious
+	}
+
+	// Click-limited links would give away a use of the link without claiming
+	// it. Referrer rules and redirect hooks gate visits by who is asking, which
+	// resolving doesn't check, so like passwords they keep the destination hidden.
+	gated := resolved.PasswordProtected || url.ReferrerMode != "" || s.HasRedirectHooks()
+	if resolved.Status == models.ResolveStatusActive && !gated && !resolved.ClickLimited && resolved.Safety != models.SafetyMalicious {
+		resolved.Destination, resolved.PerVisitor = s.resolveDestination(ctx, url, clientIP, userAgent)
+	}
+
+	// Cacheable links are active, clean, ungated and go to the same destination for everyone
+	if resolved.Status == models.ResolveStatusActive && url.Cacheable() && !gated && resolved.Safety == models.SafetyClean {
+		if err := s.cacheRepo.SetResolvedLink(ctx, resolved, s.urlCacheTTL(url)); err != nil {
+			log.Printf("Failed to cache resolved link: %v", err)
+		}
+	}
+	return resolved, nil
+}
+
+// resolveDestination returns where a visit from the client would be sent, like
+// RotateDestination but without counting the visit, and whether the destination
+// depends on the visitor. Rotations resolve to the link's primary URL, since
+// picking a destination advances them.
+func (s *urlService) resolveDestination(ctx context.Context, url *models.URL, clientIP, userAgent string) (string, bool) {
+	if destination, ok := url.DeviceDestination(models.ParseUserAgent(userAgent)); ok {
+		return destination, true
+	}
+	if url.IsCanaryRollingOut() {
+		if url.PickCanary(time.Now()) {
+			return url.UTM.Tag(url.CanaryURL), true
+		}
+		return url.TaggedURL(), true
+	}
+	if url.IsSplitTest() {
+		variants, err := s.getCachedVariants(ctx, url.ID)
+		if err != nil {
+			log.Printf("Failed to get variants of %s: %v", url.ShortCode, err)
+			return url.TaggedURL(), true
+		}
+		if variant := models.PickVariant(variants, url.SplitMode, url.ShortCode, clientIP); variant != nil {
+			return url.UTM.Tag(models.TagVariant(variant.URL, variant.Name)), true
+		}
+		return url.TaggedURL(), true
+	}
+	return url.TaggedURL(), url.HasDeviceRules()
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Scheduler runs periodic maintenance and reporting jobs
+type Scheduler struct {
+	urlService       URLService
+	reportService    UsageReportService
+	authService      AuthService
+	otpService       OTPService
+	orgService       OrganizationService
+	userEmailService UserEmailService
+	domainService    DomainService
+	suspects         SuspectList
+	interval         time.Duration
+}
+
+// NewScheduler creates a scheduler that runs every interval
+func NewScheduler(urlService URLService, reportService UsageReportService, authService AuthService, otpService OTPService, orgService OrganizationService, userEmailService UserEmailService, domainService DomainService, suspects SuspectList, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		urlService:       urlService,
+		reportService:    reportService,
+		authService:      authService,
+		otpService:       otpService,
+		orgService:       orgService,
+		userEmailService: userEmailService,
+		domainService:    domainService,
+		suspects:         suspects,
+		interval:         interval,
+	}
+}
+
+// Start runs the scheduled jobs in the background until ctx is cancelled.
+// Metadata refreshes wait on slow destinations, so they run on their own and
+// never hold up the other jobs.
+func (s *Scheduler) Start(ctx context.Context) {
+	go s.every(ctx, s.runOnce)
+	go s.every(ctx, s.refreshMetadata)
+}
+
+// every runs a job every interval until ctx is cancelled. Ticks that come
+// while the job still runs are dropped.
+func (s *Scheduler) every(ctx context.Context, job func(ctx context.Context)) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job(ctx)
+		}
+	}
+}
+
+// refreshMetadata refreshes the stale metadata of links a single time
+func (s *Scheduler) refreshMetadata(ctx context.Context) {
+	refreshed, err := s.urlService.RefreshStaleMetadata(ctx)
+	if err != nil {
+		log.Printf("Error refreshing link metadata: %v", err)
+	} else if refreshed > 0 {
+		log.Printf("Refreshed the metadata of %d links", refreshed)
+	}
+}
+
+// runOnce runs every job a single time
+func (s *Scheduler) runOnce(ctx context.Context) {
+	expired, err := s.urlService.ExpireInactiveURLs(ctx)
+	if err != nil {
+		log.Printf("Error expiring inactive URLs: %v", err)
+	} else if expired > 0 {
+		log.Printf("Expired %d inactive URLs", expired)
+	}
+
+	// Runs after inactivity expiration, so links it just expired are deactivated too
+	deactivated, err := s.urlService.DeactivateExpiredURLs(ctx)
+	if err != nil {
+		log.Printf("Error deactivating expired URLs: %v", err)
+	} else if deactivated > 0 {
+		log.Printf("Deactivated %d expired URLs", deactivated)
+	}
+
+	clicks, err := s.urlService.DeleteOldClickEvents(ctx)
+	if err != nil {
+		log.Printf("Error deleting old click events: %v", err)
+	} else if clicks > 0 {
+		log.Printf("Deleted %d click events past the retention window", clicks)
+	}
+
+	flagged, err := s.urlService.RescanDestinations(ctx)
+	if err != nil {
+		log.Printf("Error rescanning link destinations: %v", err)
+	} else if flagged > 0 {
+		log.Printf("Flagged %d links with threat-listed destinations", flagged)
+	}
+
+	if _, err := s.urlService.RefreshKeyspace(ctx); err != nil {
+		log.Printf("Error refreshing short code keyspace: %v", err)
+	}
+
+	// Reports cover the previous month and are only generated once per organization
+	reports, err := s.reportService.GenerateMonthlyReports(ctx, time.Now())
+	if err != nil {
+		log.Printf("Error generating usage reports: %v", err)
+	} else if reports > 0 {
+		log.Printf("Generated %d organization usage reports", reports)
+	}
+
+	deleted, err := s.authService.CleanupExpiredRefreshTokens(ctx)
+	if err != nil {
+		log.Printf("Error deleting expired refresh tokens: %v", err)
+	} else if deleted > 0 {
+		log.Printf("Deleted %d expired refresh tokens", deleted)
+	}
+
+	if err := s.otpService.CleanupExpiredOTPs(ctx); err != nil {
+		log.Printf("Error deleting expired OTPs: %v", err)
+	}
+
+	invitations, err := s.orgService.DeleteExpiredInvitations(ctx)
+	if err != nil {
+		log.Printf("Error deleting expired organization invitations: %v", err)
+	} else if invitations > 0 {
+		log.Printf("Deleted %d expired organization invitations", invitations)
+	}
+
+	emails, err := s.userEmailService.DeleteUnverifiedEmails(ctx)
+	if err != nil {
+		log.Printf("Error deleting unverified secondary emails: %v", err)
+	} else if emails > 0 {
+		log.Printf("Deleted %d unverified secondary emails", emails)
+	}
+
+	domains, err := s.domainService.DeleteUnverifiedDomains(ctx)
+	if err != nil {
+		log.Printf("Error deleting unverified custom domains: %v", err)
+	} else if domains > 0 {
+		log.Printf("Deleted %d unverified custom domains", domains)
+	}
+
+	hits, err := s.suspects.DeleteOldHits(ctx)
+	if err != nil {
+		log.Printf("Error deleting old honeytoken hits: %v", err)
+	} else if hits > 0 {
+		log.Printf("Deleted %d honeytoken hits past the retention window", hits)
+	}
+}
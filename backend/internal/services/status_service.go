@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/config"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// maxLatencySamples bounds the redirect latencies kept for percentiles
+const maxLatencySamples = 10000
+
+// DependencyCheck probes one dependency of the service. The service is down
+// while a critical dependency is.
+type DependencyCheck struct {
+	Name     string
+	Critical bool
+	Check    func(ctx context.Context) error
+}
+
+// StatusService tracks redirect latency and dependency health for the public status page
+type StatusService interface {
+	RecordRedirect(latency time.Duration, status int)
+	Start(ctx context.Context)
+	GetStatus() *models.ServiceStatus
+}
+
+// latencySample is one timed redirect
+type latencySample struct {
+	at      time.Time
+	latency time.Duration
+	failed  bool
+}
+
+// uptimeSample is the outcome of one round of dependency checks
+type uptimeSample struct {
+	at time.Time
+	up bool
+}
+
+// statusService implements StatusService interface
+type statusService struct {
+	checks        []DependencyCheck
+	cacheRepo     repository.CacheRepository
+	interval      time.Duration
+	uptimeWindow  time.Duration
+	latencyWindow time.Duration
+	startedAt     time.Time
+
+	mu           sync.Mutex
+	latencies    []latencySample // Ring buffer
+	nextLatency  int
+	uptime       []uptimeSample // Ring buffer
+	nextUptime   int
+	dependencies []models.DependencyStatus
+}
+
+// NewStatusService creates a status tracker probing checks every configured interval
+func NewStatusService(checks []DependencyCheck, cacheRepo repository.CacheRepository, config *config.AppConfig) StatusService {
+	dependencies := make([]models.DependencyStatus, len(checks))
+	for i, check := range checks {
+		dependencies[i] = models.DependencyStatus{
+			Name:     check.Name,
+			Status:   models.DependencyStatusUnknown,
+			Critical: check.Critical,
+		}
+	}
+
+	return &statusService{
+		checks:        checks,
+		cacheRepo:     cacheRepo,
+		interval:      config.StatusCheckInterval,
+		uptimeWindow:  config.StatusUptimeWindow,
+		latencyWindow: config.StatusLatencyWindow,
+		startedAt:     time.Now(),
+		latencies:     make([]latencySample, 0, maxLatencySamples),
+		uptime:        make([]uptimeSample, 0, int(config.StatusUptimeWindow/config.StatusCheckInterval)+1),
+		dependencies:  dependencies,
+	}
+}
+
+// RecordRedirect records the latency of a served redirect
+func (s *statusService) RecordRedirect(latency time.Duration, status int) {
+	sample := latencySample{at: time.Now(), latency: latency, failed: status >= http.StatusInternalServerError}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.latencies) < cap(s.latencies) {
+		s.latencies = append(s.latencies, sample)
+		return
+	}
+	s.latencies[s.nextLatency] = sample
+	s.nextLatency = (s.nextLatency + 1) % len(s.latencies)
+}
+
+// Start probes the dependencies now and then every interval until ctx is cancelled
+func (s *statusService) Start(ctx context.Context) {
+	go func() {
+		s.probe(ctx)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.probe(ctx)
+			}
+		}
+	}()
+}
+
+// probe runs every dependency check once and records the outcome
+func (s *statusService) probe(ctx context.Context) {
+	results := make([]models.DependencyStatus, len(s.checks))
+	up := true
+	for i, check := range s.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, s.interval/2)
+		start := time.Now()
+		err := check.Check(checkCtx)
+		cancel()
+		checkedAt := time.Now()
+
+		results[i] = models.DependencyStatus{
+			Name:      check.Name,
+			Status:    models.DependencyStatusUp,
+			Critical:  check.Critical,
+			LatencyMs: milliseconds(checkedAt.Sub(start)),
+			CheckedAt: &checkedAt,
+		}
+		if err != nil {
+			log.Printf("Status check %s failed: %v", check.Name, err)
+			results[i].Status = models.DependencyStatusDown
+			if check.Critical {
+				up = false
+			}
+		}
+	}
+
+	sample := uptimeSample{at: time.Now(), up: up}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dependencies = results
+	if len(s.uptime) < cap(s.uptime) {
+		s.uptime = append(s.uptime, sample)
+		return
+	}
+	s.uptime[s.nextUptime] = sample
+	s.nextUptime = (s.nextUptime + 1) % len(s.uptime)
+}
+
+// GetStatus summarizes the recorded samples
+func (s *statusService) GetStatus() *models.ServiceStatus {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := &models.ServiceStatus{
+		Status:          models.ServiceStatusOperational,
+		StartedAt:       s.startedAt,
+		Uptime:          s.uptimeStats(now),
+		RedirectLatency: s.latencyStats(now),
+		RedirectCache:   s.cacheRepo.URLStats(),
+		Dependencies:    append([]models.DependencyStatus(nil), s.dependencies...),
+		GeneratedAt:     now,
+	}
+	status.RedirectCache.HitRate = roundTo(status.RedirectCache.HitRate, 4)
+	for _, dependency := range status.Dependencies {
+		if dependency.Status != models.DependencyStatusDown {
+			continue
+		}
+		if dependency.Critical {
+			status.Status = models.ServiceStatusOutage
+			break
+		}
+		status.Status = models.ServiceStatusDegraded
+	}
+	return status
+}
+
+// uptimeStats returns the share of checks in the uptime window that passed
+func (s *statusService) uptimeStats(now time.Time) models.UptimeStats {
+	stats := models.UptimeStats{Window: s.uptimeWindow.String(), Percent: 100}
+
+	up := 0
+	for _, sample := range s.uptime {
+		if now.Sub(sample.at) > s.uptimeWindow {
+			continue
+		}
+		stats.Checks++
+		if sample.up {
+			up++
+		}
+	}
+	if stats.Checks > 0 {
+		stats.Percent = roundTo(float64(up)*100/float64(stats.Checks), 3)
+	}
+	return stats
+}
+
+// latencyStats returns redirect latency percentiles over the latency window
+func (s *statusService) latencyStats(now time.Time) models.LatencyStats {
+	stats := models.LatencyStats{Window: s.latencyWindow.String()}
+
+	latencies := make([]time.Duration, 0, len(s.latencies))
+	failed := 0
+	for _, sample := range s.latencies {
+		if now.Sub(sample.at) > s.latencyWindow {
+			continue
+		}
+		latencies = append(latencies, sample.latency)
+		if sample.failed {
+			failed++
+		}
+	}
+	if len(latencies) == 0 {
+		return stats
+	}
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	stats.Samples = len(latencies)
+	stats.P50Ms = milliseconds(percentile(latencies, 50))
+	stats.P95Ms = milliseconds(percentile(latencies, 95))
+	stats.P99Ms = milliseconds(percentile(latencies, 99))
+	stats.ErrorRate = roundTo(float64(failed)/float64(len(latencies)), 4)
+	return stats
+}
+
+// percentile returns the nearest-rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// milliseconds converts a duration to milliseconds with microsecond precision
+func milliseconds(d time.Duration) float64 {
+	return roundTo(float64(d)/float64(time.Millisecond), 3)
+}
+
+// roundTo rounds v to the given number of decimal places
+func roundTo(v float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(v*scale) / scale
+}
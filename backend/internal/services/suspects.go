@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// SuspectList tracks the clients caught visiting honeytoken short codes. A
+// client's IP is suspected from its first visit, and its ASN once visits come
+// from enough distinct IPs in it, so scanners rotating addresses within one
+// network are caught too. Suspicion expires on its own, and recorded visits
+// are deleted after a retention window. The list lives in the home
+// region's Redis so every instance and region consults the same one.
+type SuspectList interface {
+	Flag(ctx context.Context, hit *models.HoneytokenHit)
+	IsSuspect(ctx context.Context, clientIP, asn string) bool
+	Pardon(ctx context.Context, clientIP, asn string) error
+	GetHits(ctx context.Context, filter *models.HoneytokenHitFilter) ([]models.HoneytokenHit, error)
+	DeleteOldHits(ctx context.Context) (int64, error)
+}
+
+// suspectList implements SuspectList interface
+type suspectList struct {
+	hitRepo      repository.HoneytokenRepository
+	cacheRepo    repository.CacheRepository
+	regions      *repository.RegionRouter
+	ttl          time.Duration
+	asnThreshold int
+	retention    time.Duration
+}
+
+// NewSuspectList creates a suspicion list whose entries last ttl. An ASN is
+// suspected once visits within ttl come from asnThreshold distinct IPs in it;
+// 0 never suspects an ASN. Visits are kept for retention; 0 keeps them forever.
+func NewSuspectList(hitRepo repository.HoneytokenRepository, cacheRepo repository.CacheRepository, regions *repository.RegionRouter, ttl time.Duration, asnThreshold int, retention time.Duration) SuspectList {
+	return &suspectList{
+		hitRepo:      hitRepo,
+		cacheRepo:    cacheRepo,
+		regions:      regions,
+		ttl:          ttl,
+		asnThreshold: asnThreshold,
+		retention:    retention,
+	}
+}
+
+// Flag records a honeytoken visit and suspects its client. Failures are
+// logged, since the visitor gets the same not found page either way.
+func (s *suspectList) Flag(ctx context.Context, hit *models.HoneytokenHit) {
+	if err := s.hitRepo.RecordHit(ctx, hit); err != nil {
+		log.Printf("Failed to record honeytoken hit on %s: %v", hit.Code, err)
+	}
+
+	ctx = repository.WithRegion(ctx, s.regions.Home())
+	if err := s.cacheRepo.Set(ctx, suspectKey("ip", hit.IPAddress), hit.Code, s.ttl); err != nil {
+		log.Printf("Failed to suspect IP %s: %v", hit.IPAddress, err)
+	}
+
+	if hit.ASN == "" || s.asnThreshold == 0 {
+		return
+	}
+	// Distinct IPs are counted, so one client revisiting can't get its whole
+	// network suspected
+	clients, err := s.cacheRepo.AddUniqueWithExpiry(ctx, asnClientsKey(hit.ASN), hit.IPAddress, s.ttl)
+	if err != nil {
+		log.Printf("Failed to count honeytoken visitors from AS%s: %v", hit.ASN, err)
+		return
+	}
+	if clients >= int64(s.asnThreshold) {
+		if err := s.cacheRepo.Set(ctx, suspectKey("asn", hit.ASN), hit.Code, s.ttl); err != nil {
+			log.Printf("Failed to suspect AS%s: %v", hit.ASN, err)
+		}
+	}
+}
+
+// IsSuspect reports whether a client's IP or ASN is suspected. Clients aren't
+// suspected while Redis is unavailable.
+func (s *suspectList) IsSuspect(ctx context.Context, clientIP, asn string) bool {
+	keys := []string{suspectKey("ip", clientIP)}
+	if asn != "" {
+		keys = append(keys, suspectKey("asn", asn))
+	}
+
+	suspected, err := s.cacheRepo.ExistsAny(repository.WithRegion(ctx, s.regions.Home()), keys...)
+	return err == nil && suspected
+}
+
+// Pardon takes an IP, an ASN or both off the suspicion list
+func (s *suspectList) Pardon(ctx context.Context, clientIP, asn string) error {
+	if clientIP == "" && asn == "" {
+		return errors.NewValidationError("An IP address or ASN is required", nil)
+	}
+
+	ctx = repository.WithRegion(ctx, s.regions.Home())
+	if clientIP != "" {
+		if err := s.cacheRepo.Delete(ctx, suspectKey("ip", clientIP)); err != nil {
+			return errors.NewInternalError("Failed to pardon IP address", err)
+		}
+	}
+	if asn != "" {
+		location := models.NewGeoLocation("", "", asn)
+		if location.ASN == "" {
+			return errors.NewValidationError("Invalid ASN", nil)
+		}
+		if err := s.cacheRepo.Delete(ctx, suspectKey("asn", location.ASN)); err != nil {
+			return errors.NewInternalError("Failed to pardon ASN", err)
+		}
+		if err := s.cacheRepo.Delete(ctx, asnClientsKey(location.ASN)); err != nil {
+			return errors.NewInternalError("Failed to pardon ASN", err)
+		}
+	}
+	return nil
+}
+
+// GetHits lists visits to honeytokens, newest first
+func (s *suspectList) GetHits(ctx context.Context, filter *models.HoneytokenHitFilter) ([]models.HoneytokenHit, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid honeytoken hit filter", err)
+	}
+
+	hits, err := s.hitRepo.GetHits(ctx, filter)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get honeytoken hits", err)
+	}
+	return hits, nil
+}
+
+// DeleteOldHits deletes recorded honeytoken visits older than the retention
+// window. Suspicion doesn't depend on them, so nobody is pardoned.
+func (s *suspectList) DeleteOldHits(ctx context.Context) (int64, error) {
+	if s.retention == 0 {
+		return 0, nil
+	}
+
+	var deleted int64
+	before := time.Now().Add(-s.retention)
+	for batch := 0; batch < cleanupMaxBatches; batch++ {
+		n, err := s.hitRepo.DeleteHitsBefore(ctx, before, cleanupBatchSize)
+		deleted += n
+		if err != nil {
+			return deleted, errors.NewDatabaseError("Failed to delete old honeytoken hits", err)
+		}
+		if n < cleanupBatchSize {
+			break
+		}
+	}
+	return deleted, nil
+}
+
+// asnClientsKey returns the cache key counting the distinct IPs in an ASN that
+// visited honeytokens
+func asnClientsKey(asn string) string {
+	return fmt.Sprintf("honeytoken_asn_clients:%s", asn)
+}
+
+// suspectKey returns the cache key suspecting a client IP or ASN
+func suspectKey(kind, value string) string {
+	return fmt.Sprintf("suspect:%s:%s", kind, value)
+}
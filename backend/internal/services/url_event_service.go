@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// Unpublished events are left to the request that appended them for this
+// long before the relay publishes them
+const urlEventPublishDelay = time.Minute
+
+// urlEventBatchSize bounds the events the relay publishes or appends per region per run
+const urlEventBatchSize = 500
+
+// urlEventExpiryLookback is how far back the relay looks for expirations
+// without an event, so expirations missed while it was down are still recorded
+const urlEventExpiryLookback = 7 * 24 * time.Hour
+
+// URLEventPublisher delivers link events to the message bus
+type URLEventPublisher interface {
+	PublishURLEvent(event *models.URLEvent) error
+}
+
+// URLEventService interface defines the contract for the link lifecycle event log
+type URLEventService interface {
+	Record(ctx context.Context, url *models.URL, eventType string, data interface{})
+	RecordBatch(ctx context.Context, urls []models.URL, eventType string, data interface{})
+	GetEvents(ctx context.Context, url *models.URL, opts *models.URLEventListOptions) ([]models.URLEvent, error)
+	Start(ctx context.Context)
+}
+
+// urlEventService implements URLEventService interface
+type urlEventService struct {
+	eventRepo repository.URLEventRepository
+	cacheRepo repository.CacheRepository
+	publisher URLEventPublisher
+	regions   *repository.RegionRouter
+	interval  time.Duration
+}
+
+// NewURLEventService creates a link event log publishing to the message bus.
+// Its relay runs every interval.
+func NewURLEventService(eventRepo repository.URLEventRepository, cacheRepo repository.CacheRepository, publisher URLEventPublisher, regions *repository.RegionRouter, interval time.Duration) URLEventService {
+	return &urlEventService{
+		eventRepo: eventRepo,
+		cacheRepo: cacheRepo,
+		publisher: publisher,
+		regions:   regions,
+		interval:  interval,
+	}
+}
+
+// Record appends a lifecycle event of a link to its log and publishes it in
+// the background. Failures are logged and never fail the transition; events
+// that were appended but not published are picked up by the relay.
+func (s *urlEventService) Record(ctx context.Context, url *models.URL, eventType string, data interface{}) {
+	// Sandbox requests change nothing, so there is nothing to record
+	if models.IsSandbox(ctx) {
+		return
+	}
+
+	event, err := models.NewURLEvent(url, eventType, data)
+	if err != nil {
+		log.Printf("Failed to record %s event of %s: %v", eventType, url.ShortCode, err)
+		return
+	}
+	if _, err := s.eventRepo.Append(ctx, event); err != nil {
+		log.Printf("Failed to record %s event of %s: %v", eventType, url.ShortCode, err)
+		return
+	}
+
+	go s.publish(context.WithoutCancel(ctx), []models.URLEvent{*event})
+}
+
+// RecordBatch appends the same lifecycle event of many links to their logs at
+// once, for background jobs. The relay publishes them; without it, they are
+// published before returning.
+func (s *urlEventService) RecordBatch(ctx context.Context, urls []models.URL, eventType string, data interface{}) {
+	if len(urls) == 0 {
+		return
+	}
+
+	var encoded []byte
+	if data != nil {
+		var err error
+		if encoded, err = json.Marshal(data); err != nil {
+			log.Printf("Failed to record %d %s events: %v", len(urls), eventType, err)
+			return
+		}
+	}
+
+	events, err := s.eventRepo.AppendBatch(ctx, urls, eventType, encoded, time.Now())
+	if err != nil {
+		log.Printf("Failed to record %d %s events: %v", len(urls), eventType, err)
+		return
+	}
+	if s.interval <= 0 {
+		s.publish(ctx, events)
+	}
+}
+
+// GetEvents returns a page of a link's events, newest first
+func (s *urlEventService) GetEvents(ctx context.Context, url *models.URL, opts *models.URLEventListOptions) ([]models.URLEvent, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid event list options", err)
+	}
+
+	events, err := s.eventRepo.GetByURL(ctx, url.ID, opts)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get link events", err)
+	}
+	return events, nil
+}
+
+// Start runs the relay in the background until ctx is cancelled. It appends
+// the expirations of links, which happen without a request, and publishes
+// events whose publication failed.
+func (s *urlEventService) Start(ctx context.Context) {
+	if s.interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.runOnce(ctx)
+			}
+		}
+	}()
+}
+
+// runOnce runs the relay a single time in every region. Each region's relay
+// runs on one instance per interval, under a lease.
+func (s *urlEventService) runOnce(ctx context.Context) {
+	for _, region := range s.regions.Regions() {
+		regionCtx := repository.WithRegion(ctx, region)
+
+		acquired, err := s.cacheRepo.AcquireLease(regionCtx, "url-event-relay", s.interval)
+		if err != nil {
+			log.Printf("Error acquiring the link event relay lease in %s: %v", region, err)
+			continue
+		}
+		if !acquired {
+			continue
+		}
+		now := time.Now()
+
+		expirations, err := s.eventRepo.AppendExpirations(regionCtx, now.Add(-urlEventExpiryLookback), now, urlEventBatchSize)
+		if err != nil {
+			log.Printf("Error recording link expirations in %s: %v", region, err)
+		} else if len(expirations) > 0 {
+			log.Printf("Recorded %d link expirations in %s", len(expirations), region)
+		}
+
+		// Expirations were just appended unpublished, so this picks them up too,
+		// once their publication delay has passed
+		unpublished, err := s.eventRepo.GetUnpublished(regionCtx, now.Add(-urlEventPublishDelay), urlEventBatchSize)
+		if err != nil {
+			log.Printf("Error getting unpublished link events in %s: %v", region, err)
+			continue
+		}
+		s.publish(regionCtx, unpublished)
+	}
+}
+
+// publish sends events to the message bus, in order, and marks the ones that
+// got there published. It stops at the first failure, leaving the rest to the
+// relay.
+func (s *urlEventService) publish(ctx context.Context, events []models.URLEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	ids := make([]int64, 0, len(events))
+	for i := range events {
+		if err := s.publisher.PublishURLEvent(&events[i]); err != nil {
+			log.Printf("Failed to publish %s event of %s: %v", events[i].Type, events[i].ShortCode, err)
+			break
+		}
+		ids = append(ids, events[i].ID)
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	if err := s.eventRepo.MarkPublished(ctx, ids, time.Now()); err != nil {
+		log.Printf("Failed to mark link events published: %v", err)
+	}
+}
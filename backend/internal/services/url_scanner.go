@@ -0,0 +1,142 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/config"
+	"github.com/hpower2/url-shortener/internal/fetcher"
+)
+
+// safeBrowsingEndpoint is the Google Safe Browsing v4 Lookup API
+const safeBrowsingEndpoint = "https://safebrowsing.googleapis.com/v4/threatMatches:find"
+
+// safeBrowsingBatchSize is the most URLs the Lookup API accepts per request
+const safeBrowsingBatchSize = 500
+
+// safeBrowsingTimeout bounds a single Lookup API request
+const safeBrowsingTimeout = 5 * time.Second
+
+// warningThreats are threat types serious enough to warn visitors about but not
+// to take a link down: rescans mark such links suspicious instead of deactivating them
+var warningThreats = map[string]bool{
+	"UNWANTED_SOFTWARE":               true,
+	"POTENTIALLY_HARMFUL_APPLICATION": true,
+}
+
+// URLScanner checks link destinations against a list of known phishing and
+// malware URLs. Deployments can plug in their own list behind this interface.
+type URLScanner interface {
+	// Scan returns the threat type of each listed URL; clean URLs are omitted
+	Scan(ctx context.Context, urls []string) (map[string]string, error)
+}
+
+// NewURLScanner returns the configured scanner, or nil when scanning is off
+func NewURLScanner(cfg *config.Config, fetcher *fetcher.Fetcher) URLScanner {
+	switch cfg.Abuse.URLScanner {
+	case config.URLScannerSafeBrowsing:
+		return &safeBrowsingScanner{
+			apiKey:        cfg.Abuse.SafeBrowsingAPIKey,
+			clientVersion: cfg.App.Version,
+			endpoint:      safeBrowsingEndpoint,
+			fetcher:       fetcher,
+		}
+	default:
+		return nil
+	}
+}
+
+// safeBrowsingScanner implements URLScanner with the Google Safe Browsing Lookup API
+type safeBrowsingScanner struct {
+	apiKey        string
+	clientVersion string
+	endpoint      string
+	fetcher       *fetcher.Fetcher
+}
+
+// safeBrowsingRequest is the body of a threatMatches:find request
+type safeBrowsingRequest struct {
+	Client struct {
+		ClientID      string `json:"clientId"`
+		ClientVersion string `json:"clientVersion"`
+	} `json:"client"`
+	ThreatInfo struct {
+		ThreatTypes      []string            `json:"threatTypes"`
+		PlatformTypes    []string            `json:"platformTypes"`
+		ThreatEntryTypes []string            `json:"threatEntryTypes"`
+		ThreatEntries    []map[string]string `json:"threatEntries"`
+	} `json:"threatInfo"`
+}
+
+// safeBrowsingResponse is the body of a threatMatches:find response
+type safeBrowsingResponse struct {
+	Matches []struct {
+		ThreatType string `json:"threatType"`
+		Threat     struct {
+			URL string `json:"url"`
+		} `json:"threat"`
+	} `json:"matches"`
+}
+
+// Scan looks the URLs up in batches the API accepts
+func (s *safeBrowsingScanner) Scan(ctx context.Context, urls []string) (map[string]string, error) {
+	threats := make(map[string]string)
+	for start := 0; start < len(urls); start += safeBrowsingBatchSize {
+		end := start + safeBrowsingBatchSize
+		if end > len(urls) {
+			end = len(urls)
+		}
+		if err := s.lookup(ctx, urls[start:end], threats); err != nil {
+			return nil, err
+		}
+	}
+	return threats, nil
+}
+
+// lookup checks one batch of URLs, adding matches to threats
+func (s *safeBrowsingScanner) lookup(ctx context.Context, urls []string, threats map[string]string) error {
+	var body safeBrowsingRequest
+	body.Client.ClientID = "url-shortener"
+	body.Client.ClientVersion = s.clientVersion
+	body.ThreatInfo.ThreatTypes = []string{"MALWARE", "SOCIAL_ENGINEERING", "UNWANTED_SOFTWARE", "POTENTIALLY_HARMFUL_APPLICATION"}
+	body.ThreatInfo.PlatformTypes = []string{"ANY_PLATFORM"}
+	body.ThreatInfo.ThreatEntryTypes = []string{"URL"}
+	for _, url := range urls {
+		body.ThreatInfo.ThreatEntries = append(body.ThreatInfo.ThreatEntries, map[string]string{"url": url})
+	}
+
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode Safe Browsing request: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, safeBrowsingTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"?key="+s.apiKey, bytes.NewReader(encoded))
+	if err != nil {
+		return fmt.Errorf("failed to create Safe Browsing request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.fetcher.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query Safe Browsing: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Safe Browsing responded with status %d", resp.StatusCode)
+	}
+
+	var result safeBrowsingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode Safe Browsing response: %w", err)
+	}
+	for _, match := range result.Matches {
+		threats[match.Threat.URL] = match.ThreatType
+	}
+	return nil
+}
@@ -2,12 +2,17 @@ package services
 
 import (
 	"context"
-	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
-	"reflect"
+	"log"
+	neturl "net/url"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/hpower2/url-shortener/internal/config"
 	"github.com/hpower2/url-shortener/internal/errors"
 	"github.com/hpower2/url-shortener/internal/models"
 	"github.com/hpower2/url-shortener/internal/repository"
@@ -17,12 +22,64 @@ import (
 type URLService interface {
 	CreateURL(ctx context.Context, req *models.CreateURLRequest, userID int, clientIP, userAgent string) (*models.CreateURLResponse, error)
 	GetURL(ctx context.Context, shortCode string) (*models.URL, error)
+	GetURLForRedirect(ctx context.Context, shortCode string) (*models.URL, error)
+	TripHoneytoken(ctx context.Context, shortCode, clientIP, userAgent, referer string) bool
 	GetURLStats(ctx context.Context, shortCode string, userID int) (*models.URLStatsResponse, error)
-	GetAllURLs(ctx context.Context, userID int, limit, offset int) ([]models.URL, int, error)
+	GetAllURLs(ctx context.Context, userID int, opts *models.URLListOptions) ([]models.URL, int, error)
+	GetURLPage(ctx context.Context, userID int, opts *models.URLListOptions) (*models.URLPage, error)
+	SearchURLs(ctx context.Context, userID int, opts *models.URLSearchOptions) ([]models.URL, int, error)
+	GetRecentActivity(ctx context.Context, userID int, within time.Duration, limit int) ([]models.URL, error)
+	GetCampaignStats(ctx context.Context, userID int) ([]models.CampaignStats, error)
+	GetDashboard(ctx context.Context, userID int) (*models.Dashboard, error)
 	DeleteURL(ctx context.Context, shortCode string, userID int) error
+	PreviewDeleteLabeledLinks(ctx context.Context, userID int, req *models.DeleteLabeledLinksRequest) (*models.LabeledLinksImpact, error)
+	DeleteLabeledLinks(ctx context.Context, userID int, req *models.DeleteLabeledLinksRequest, expected int) (int, error)
+	KillURL(ctx context.Context, shortCode string, userID int) (*models.KillSwitchResult, error)
+	ListenForKills(ctx context.Context)
 	UpdateURL(ctx context.Context, shortCode string, req *models.UpdateURLRequest, userID int) (*models.URL, error)
-	RecordClick(ctx context.Context, shortCode, clientIP, userAgent, referer string) error
-	GetAnalytics(ctx context.Context, shortCode string, userID int, days int) (*models.URLAnalytics, error)
+	ExtendExpiration(ctx context.Context, shortCode string, req *models.ExtendExpirationRequest, userID int) (*models.URL, *models.ExpirationExtension, error)
+	GetExpirationExtensions(ctx context.Context, shortCode string, userID int) ([]models.ExpirationExtension, error)
+	GetDestinationChanges(ctx context.Context, shortCode string, userID int) ([]models.DestinationChange, error)
+	GetURLEvents(ctx context.Context, shortCode string, userID int, opts *models.URLEventListOptions) ([]models.URLEvent, error)
+	RecordClick(ctx context.Context, shortCode, clientIP, userAgent, referer, channel string) error
+	CheckReferrer(ctx context.Context, url *models.URL, clientIP, userAgent, referer string) error
+	CheckClickRate(ctx context.Context, url *models.URL, clientIP, userAgent, referer string) error
+	ResolveFrequencyCap(ctx context.Context, url *models.URL, clientIP, userAgent, referer string) (string, bool)
+	RotateDestination(ctx context.Context, url *models.URL, clientIP, userAgent string) string
+	RecordConversion(ctx context.Context, shortCode, variant string, userID int) (*models.URLVariant, error)
+	ClaimRedirect(ctx context.Context, url *models.URL) error
+	UnlockURL(ctx context.Context, shortCode string, req *models.UnlockURLRequest, clientIP, userAgent string) (*models.URL, error)
+	OpenWithShareToken(ctx context.Context, url *models.URL, token string) bool
+	ShouldWarn(ctx context.Context, url *models.URL) bool
+	RotatePassword(ctx context.Context, shortCode string, req *models.RotateLinkPasswordRequest, userID int) (*models.RotateLinkPasswordResponse, error)
+	CreateShareToken(ctx context.Context, shortCode string, req *models.CreateShareTokenRequest, userID int) (*models.CreateShareTokenResponse, error)
+	GetShareTokens(ctx context.Context, shortCode string, userID int) ([]models.ShareToken, error)
+	RevokeShareToken(ctx context.Context, shortCode string, id int, userID int) error
+	CreateGuestEditLink(ctx context.Context, shortCode string, req *models.CreateGuestEditLinkRequest, userID int) (*models.CreateGuestEditLinkResponse, error)
+	GetGuestEditLinks(ctx context.Context, shortCode string, userID int) ([]models.GuestEditLink, error)
+	RevokeGuestEditLink(ctx context.Context, shortCode string, id int, userID int) error
+	GetGuestEdit(ctx context.Context, token string) (*models.GuestEditView, error)
+	GuestEdit(ctx context.Context, token string, req *models.GuestEditRequest) (*models.GuestEditView, error)
+	GetAnalytics(ctx context.Context, shortCode string, userID int, days int, timezone string) (*models.URLAnalytics, error)
+	GetClickTimeSeries(ctx context.Context, shortCode string, userID int, opts *models.ClickTimeSeriesOptions) (*models.ClickTimeSeries, error)
+	StreamClicks(ctx context.Context, shortCode string, userID int, opts *models.ClickStreamOptions) (*models.ClickStreamPage, error)
+	GetClicks(ctx context.Context, shortCode string, userID int, opts *models.ClickListOptions) (*models.ClickPage, error)
+	ExportURLs(ctx context.Context, userID int, each func(url *models.URL) error) error
+	ExportClicks(ctx context.Context, shortCode string, userID int, each func(event *models.ClickEvent) error) error
+	ExpireInactiveURLs(ctx context.Context) (int, error)
+	DeactivateExpiredURLs(ctx context.Context) (int, error)
+	DeleteOldClickEvents(ctx context.Context) (int64, error)
+	RescanDestinations(ctx context.Context) (int, error)
+	RefreshMetadata(ctx context.Context, shortCode string, userID int) (*models.LinkMetadata, error)
+	RefreshStaleMetadata(ctx context.Context) (int, error)
+	RefreshKeyspace(ctx context.Context) (*models.ShortCodeKeyspace, error)
+	GetKeyspace() *models.ShortCodeKeyspace
+	SetSuspicious(ctx context.Context, shortCode string, suspicious bool) (*models.URL, error)
+	ResolveURL(ctx context.Context, shortCode, clientIP, userAgent string) (*models.ResolvedLink, error)
+	RegisterHook(hook RedirectHook)
+	RegisterEnricher(enricher Enricher)
+	HasRedirectHooks() bool
+	RunBeforeRedirectHooks(ctx context.Context, visit *RedirectVisit) (string, error)
 }
 
 // urlService implements URLService interface
@@ -30,56 +87,169 @@ type urlService struct {
 	urlRepo   repository.URLRepository
 	userRepo  repository.UserRepository
 	cacheRepo repository.CacheRepository
-	baseURL   string
+	prefsRepo repository.PreferencesRepository
+	webhooks  WebhookService
+	events    URLEventService
+	routes    ReservedRouteService
+	orgs      OrganizationService
+	domains   DomainService
+	suspects  SuspectList
+	regions   *repository.RegionRouter
+	scanner   URLScanner
+	metadata  *LinkMetadataFetcher
+	config    *config.Config
+
+	// Split test variant clicks, written to the database in batches
+	variantClicks *VariantClickCounter
+	baseURL       string
+	hooks         []RedirectHook
+	enrichers     []Enricher
+
+	// Destination domains owners proved they control
+	verifiedDomainRepo repository.VerifiedDomainRepository
+
+	// Generated short codes grow longer as the keyspace fills up
+	keyspaceMu sync.RWMutex
+	keyspace   models.ShortCodeKeyspace
+
+	// Short codes killed recently, on this instance or any other
+	killedMu sync.Mutex
+	killed   map[string]time.Time
 }
 
 // NewURLService creates a new URL service
-func NewURLService(urlRepo repository.URLRepository, userRepo repository.UserRepository, cacheRepo repository.CacheRepository, baseURL string) URLService {
+func NewURLService(urlRepo repository.URLRepository, userRepo repository.UserRepository, cacheRepo repository.CacheRepository, prefsRepo repository.PreferencesRepository, verifiedDomainRepo repository.VerifiedDomainRepository, webhooks WebhookService, events URLEventService, routes ReservedRouteService, orgs OrganizationService, domains DomainService, suspects SuspectList, regions *repository.RegionRouter, scanner URLScanner, metadata *LinkMetadataFetcher, variantClicks *VariantClickCounter, config *config.Config) URLService {
 	return &urlService{
-		urlRepo:   urlRepo,
-		userRepo:  userRepo,
-		cacheRepo: cacheRepo,
-		baseURL:   baseURL,
+		urlRepo:            urlRepo,
+		userRepo:           userRepo,
+		cacheRepo:          cacheRepo,
+		prefsRepo:          prefsRepo,
+		verifiedDomainRepo: verifiedDomainRepo,
+		webhooks:           webhooks,
+		events:             events,
+		routes:             routes,
+		orgs:               orgs,
+		domains:            domains,
+		suspects:           suspects,
+		regions:            regions,
+		scanner:            scanner,
+		metadata:           metadata,
+		variantClicks:      variantClicks,
+		config:             config,
+		baseURL:            config.App.BaseURL,
+		keyspace: models.ShortCodeKeyspace{
+			Length:         config.App.ShortCodeLength,
+			MinLength:      config.App.ShortCodeLength,
+			Keyspace:       models.ShortCodeKeyspaceSize(config.App.ShortCodeLength),
+			MaxUtilization: config.App.ShortCodeMaxUtilization,
+		},
+		killed: make(map[string]time.Time),
 	}
 }
 
 // CreateURL creates a new short URL with user association
 func (s *urlService) CreateURL(ctx context.Context, req *models.CreateURLRequest, userID int, clientIP, userAgent string) (*models.CreateURLResponse, error) {
-	fmt.Println("Creating URL", req)
-	fmt.Println("Client IP", clientIP)
-	fmt.Println("User Agent", userAgent)
-	fmt.Println("User ID", userID)
-	fmt.Println("req.URL", req.URL)
-	fmt.Println("req.CustomCode", req.CustomCode)
-	fmt.Println("req.ExpiresAt", req.ExpiresAt)
-
 	// Validate request
 	if err := req.Validate(); err != nil {
-		fmt.Println("Error", err)
-		fmt.Println("Error type", reflect.TypeOf(err))
-
 		return nil, errors.NewValidationError("Invalid request", err)
 	}
 
+	// Double submissions of the same link return the first one
+	return s.coalesceCreate(ctx, userID, req, func() (*models.CreateURLResponse, error) {
+		return s.createURL(ctx, req, userID, clientIP, userAgent)
+	})
+}
+
+// createURL creates a link from a validated request
+func (s *urlService) createURL(ctx context.Context, req *models.CreateURLRequest, userID int, clientIP, userAgent string) (*models.CreateURLResponse, error) {
 	// Check if user can create more links
 	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
 		return nil, errors.NewDatabaseError("Failed to get user", err)
 	}
 
-	if !user.CanCreateLink() {
+	// Sandbox links are never saved, so they don't use up the link limit
+	if !user.CanCreateLink() && !models.IsSandbox(ctx) {
 		return nil, errors.NewValidationError(fmt.Sprintf("Link limit exceeded. You can create maximum %d links", user.LinkLimit), nil)
 	}
 
+	// Fill in the user's presets, then the server defaults, unless the link opts out
+	if !req.SkipDefaults {
+		preferences, err := s.prefsRepo.Get(ctx, userID)
+		if err != nil {
+			return nil, errors.NewDatabaseError("Failed to get preferences", err)
+		}
+		if !req.SkipUTMDefaults {
+			taggedURL, err := preferences.UTMDefaults.ApplyTo(req.URL)
+			if err != nil {
+				return nil, errors.NewValidationError("Invalid request", err)
+			}
+			req.URL = taggedURL
+		}
+		if err := preferences.ApplyTo(req, time.Now()); err != nil {
+			return nil, errors.NewValidationError("Invalid request", err)
+		}
+		if req.ExpiresAt.Time == nil && s.config.App.DefaultExpiration > 0 {
+			expiresAt := req.LiveFrom(time.Now()).Add(s.config.App.DefaultExpiration)
+			req.ExpiresAt.Time = &expiresAt
+		}
+	}
+
+	// Links can only be shared on the user's own verified custom domains
+	if req.Domain != "" {
+		if err := s.domains.CheckLinkDomain(ctx, userID, req.Domain); err != nil {
+			return nil, err
+		}
+	}
+
+	// Screen the destination and throttle mass creation of links to a single domain
+	needsReview, err := s.checkDestination(ctx, user, req.URL)
+	if err != nil {
+		return nil, err
+	}
+	if req.Rotation != nil {
+		rotationNeedsReview, err := s.checkDestinations(ctx, user, req.Rotation.Destinations)
+		if err != nil {
+			return nil, err
+		}
+		needsReview = needsReview || rotationNeedsReview
+	}
+	if req.SplitTest != nil {
+		if req.Rotation != nil && req.Rotation.Mode != "" && req.SplitTest.Mode != "" {
+			return nil, errors.NewValidationError("Rotator links can't be split tested", nil)
+		}
+		splitNeedsReview, err := s.checkDestinations(ctx, user, req.SplitTest.Destinations())
+		if err != nil {
+			return nil, err
+		}
+		needsReview = needsReview || splitNeedsReview
+	}
+	deviceNeedsReview, err := s.checkDestinations(ctx, user, req.DeviceRules.WebDestinations())
+	if err != nil {
+		return nil, err
+	}
+	needsReview = needsReview || deviceNeedsReview
+
 	// Generate or use custom short code
 	shortCode := req.CustomCode
-	if shortCode == "" {
+	generated := shortCode == ""
+	if generated {
 		var err error
-		shortCode, err = s.generateUniqueShortCode(ctx)
+		shortCode, err = s.generateShortCode(ctx)
 		if err != nil {
 			return nil, errors.NewInternalError("Failed to generate short code", err)
 		}
 	} else {
+		// Codes shadowed by application routes would never redirect
+		if s.routes.IsReserved(shortCode) {
+			return nil, errors.NewValidationError(fmt.Sprintf("Custom short code %q is reserved", shortCode), nil)
+		}
+
+		// Organizations keep the codes starting with their prefix for their members
+		if err := s.orgs.CheckCustomCode(ctx, user, shortCode); err != nil {
+			return nil, err
+		}
+
 		// Check if custom code already exists
 		exists, err := s.urlRepo.ExistsByShortCode(ctx, shortCode)
 		if err != nil {
@@ -95,39 +265,105 @@ func (s *urlService) CreateURL(ctx context.Context, req *models.CreateURLRequest
 		ShortCode:   shortCode,
 		OriginalURL: req.URL,
 		UserID:      userID,
-		IsActive:    true,
+		IsActive:    !needsReview,
+		NeedsReview: needsReview,
 		ExpiresAt:   req.ExpiresAt.Time,
+		ActivatesAt: req.ActivatesAt.Time,
 		IPAddress:   clientIP,
 		UserAgent:   userAgent,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
+
+		InactivityExpiryDays: req.InactivityExpiryDays,
+		MaxClicksPerMinute:   req.MaxClicksPerMinute,
+		MaxClicks:            req.MaxClicks,
+		RedirectType:         req.RedirectType,
+		UTM:                  req.UTM(),
+		Title:                req.Title,
+		Notes:                req.Notes,
+		Labels:               req.Labels,
+		DeviceRules:          req.DeviceRules,
+		Domain:               req.Domain,
+	}
+	if req.ReferrerRules != nil {
+		req.ReferrerRules.Apply(url)
+	}
+	if req.FrequencyCap != nil {
+		req.FrequencyCap.Apply(url)
+	}
+	if req.ShadowTraffic != nil {
+		req.ShadowTraffic.Apply(url, time.Now())
+	}
+	if req.Rotation != nil {
+		req.Rotation.Apply(url)
+	}
+	if req.SplitTest != nil {
+		req.SplitTest.Apply(url)
+	}
+	if err := url.SetPassword(req.Password); err != nil {
+		return nil, errors.NewInternalError("Failed to set link password", err)
 	}
 
-	// Save to database
+	// Sandbox requests get the link they would have created, without saving it
+	if models.IsSandbox(ctx) {
+		return s.newCreateURLResponse(url), nil
+	}
+
+	// Save to database. Generated codes never collide with each other, but may
+	// with a custom code or one generated at random before the sequence existed.
 	createdURL, err := s.urlRepo.Create(ctx, url)
+	for attempt := 1; generated && repository.IsDuplicate(err) && attempt < maxShortCodeAttempts; attempt++ {
+		if url.ShortCode, err = s.generateShortCode(ctx); err != nil {
+			return nil, errors.NewInternalError("Failed to generate short code", err)
+		}
+		createdURL, err = s.urlRepo.Create(ctx, url)
+	}
 	if err != nil {
 		return nil, errors.NewDatabaseError("Failed to create URL", err)
 	}
-
-	// Cache the URL
-	if err := s.cacheRepo.SetURL(ctx, shortCode, req.URL, 24*time.Hour); err != nil {
-		// Log error but don't fail the request
-		fmt.Printf("Failed to cache URL: %v\n", err)
+	if createdURL.IsRotator() {
+		if err := s.urlRepo.SetDestinations(ctx, createdURL.ID, req.Rotation.Destinations); err != nil {
+			return nil, errors.NewDatabaseError("Failed to store rotation destinations", err)
+		}
+	}
+	if createdURL.IsSplitTest() {
+		if err := s.setVariants(ctx, createdURL.ID, req.SplitTest.Variants); err != nil {
+			return nil, errors.NewDatabaseError("Failed to store split test variants", err)
+		}
 	}
 
-	// Create response
-	response := &models.CreateURLResponse{
-		ID:          createdURL.ID,
-		ShortCode:   createdURL.ShortCode,
+	s.events.Record(ctx, createdURL, models.URLEventCreated, models.URLEventCreation{
 		OriginalURL: createdURL.OriginalURL,
-		ShortURL:    fmt.Sprintf("%s/%s", s.baseURL, createdURL.ShortCode),
-		IsActive:    createdURL.IsActive,
-		CreatedAt:   createdURL.CreatedAt,
 		ExpiresAt:   createdURL.ExpiresAt,
-		QRCode:      fmt.Sprintf("%s/api/v1/urls/%s/qr", s.baseURL, createdURL.ShortCode),
+		ActivatesAt: createdURL.ActivatesAt,
+		NeedsReview: createdURL.NeedsReview,
+	})
+
+	// Cache the URL (links held for review are not redirectable yet)
+	if createdURL.Cacheable() {
+		if err := s.cacheRepo.SetURL(ctx, createdURL.ShortCode, createdURL.TaggedURL(), s.urlCacheTTL(createdURL)); err != nil {
+			// Log error but don't fail the request
+			log.Printf("Failed to cache URL: %v", err)
+		}
 	}
 
-	return response, nil
+	return s.newCreateURLResponse(createdURL), nil
+}
+
+// newCreateURLResponse describes a newly created link to its owner
+func (s *urlService) newCreateURLResponse(url *models.URL) *models.CreateURLResponse {
+	return &models.CreateURLResponse{
+		ID:          url.ID,
+		ShortCode:   url.ShortCode,
+		OriginalURL: url.OriginalURL,
+		ShortURL:    url.ShortURL(s.baseURL),
+		IsActive:    url.IsActive,
+		CreatedAt:   url.CreatedAt,
+		ExpiresAt:   url.ExpiresAt,
+		ActivatesAt: url.ActivatesAt,
+		QRCode:      fmt.Sprintf("%s/api/v1/urls/%s/qr", s.baseURL, url.ShortCode),
+		NeedsReview: url.NeedsReview,
+	}
 }
 
 // GetURL retrieves a URL by short code
@@ -139,7 +375,7 @@ func (s *urlService) GetURL(ctx context.Context, shortCode string) (*models.URL,
 	// Always get from database first to ensure we have the latest status
 	url, err := s.urlRepo.GetByShortCode(ctx, shortCode)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
+		if repository.IsNotFound(err) {
 			return nil, errors.NewNotFoundError("URL not found", err)
 		}
 		return nil, errors.NewDatabaseError("Failed to get URL", err)
@@ -159,62 +395,276 @@ func (s *urlService) GetURL(ctx context.Context, shortCode string) (*models.URL,
 		return nil, errors.NewInactiveError("URL is not active", nil)
 	}
 
-	// Only cache if URL is active and not expired
-	if err := s.cacheRepo.SetURL(ctx, shortCode, url.OriginalURL, 24*time.Hour); err != nil {
-		// Log error but don't fail the request
-		fmt.Printf("Failed to cache URL: %v\n", err)
+	// Only cache if URL is active and not expired. The link may have been
+	// killed since it was read, so never replace a cached tombstone.
+	if url.Cacheable() {
+		if err := s.cacheRepo.SetURLIfAbsent(ctx, shortCode, url.TaggedURL(), s.urlCacheTTL(url)); err != nil {
+			// Log error but don't fail the request
+			log.Printf("Failed to cache URL: %v", err)
+		}
 	}
 
 	return url, nil
 }
 
-// GetAllURLs retrieves all URLs with pagination
-func (s *urlService) GetAllURLs(ctx context.Context, userID int, limit, offset int) ([]models.URL, int, error) {
-	if limit <= 0 {
-		limit = 10
+// GetURLForRedirect resolves a short code for the redirect route, serving from
+// cache when possible. Only active, unexpired links are cached, and the cache
+// entry is cleared whenever a link is deleted, deactivated or expires.
+func (s *urlService) GetURLForRedirect(ctx context.Context, shortCode string) (*models.URL, error) {
+	if shortCode == "" {
+		return nil, errors.NewValidationError("Short code is required", nil)
+	}
+
+	// Killed links stop here on every instance, whatever the cache holds
+	if s.isKilled(shortCode) {
+		return nil, errors.NewInactiveError("URL is not active", nil)
+	}
+
+	originalURL, err := s.cacheRepo.GetURL(ctx, shortCode)
+	if err == nil && originalURL == models.KillSwitchTombstone {
+		return nil, errors.NewInactiveError("URL is not active", nil)
+	}
+	if err == nil && originalURL != "" {
+		return &models.URL{
+			ShortCode:   shortCode,
+			OriginalURL: originalURL,
+			IsActive:    true,
+		}, nil
+	}
+	if err != nil && !repository.IsCacheMiss(err) {
+		// The cache is unavailable; the database can still serve the redirect
+		log.Printf("Failed to get URL %s from cache: %v", shortCode, err)
+	}
+
+	// Cache miss: fall back to the database, which also repopulates the cache
+	url, err := s.GetURL(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	// Scheduled links don't redirect before their activation time. They are
+	// never cached, but their owners can still see and manage them.
+	if url.IsScheduled() {
+		return nil, errors.NewNotYetActiveError("URL is not active yet", nil).WithDetails(url.ActivatesAt.UTC().Format(time.RFC3339))
 	}
-	if offset < 0 {
-		offset = 0
+	return url, nil
+}
+
+// GetAllURLs retrieves all URLs with pagination, sorting and filtering
+func (s *urlService) GetAllURLs(ctx context.Context, userID int, opts *models.URLListOptions) ([]models.URL, int, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, 0, errors.NewValidationError("Invalid list options", err)
 	}
 
-	urls, total, err := s.urlRepo.GetAllByUser(ctx, userID, limit, offset)
+	urls, total, err := s.urlRepo.GetAllByUser(ctx, userID, opts)
 	if err != nil {
 		return nil, 0, errors.NewDatabaseError("Failed to get URLs", err)
 	}
 
+	setLinkStatuses(urls)
+	return urls, total, nil
+}
+
+// setLinkStatuses sets the status of listed links, so owners can tell
+// scheduled and expired links apart without comparing times
+func setLinkStatuses(urls []models.URL) {
+	for i := range urls {
+		urls[i].Status = urls[i].LinkStatus()
+	}
+}
+
+// GetURLPage retrieves a page of the user's URLs by cursor. Unlike offsets,
+// cursors stay cheap deep into large accounts, and links created while paging
+// don't shift later pages.
+func (s *urlService) GetURLPage(ctx context.Context, userID int, opts *models.URLListOptions) (*models.URLPage, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid list options", err)
+	}
+
+	// Read one link past the page to know whether another follows
+	limit := opts.Limit
+	opts.Limit++
+	urls, err := s.urlRepo.GetPageByUser(ctx, userID, opts)
+	opts.Limit = limit
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get URLs", err)
+	}
+
+	setLinkStatuses(urls)
+	page := &models.URLPage{URLs: urls, Limit: limit}
+	if len(urls) > limit {
+		page.URLs = urls[:limit]
+		page.HasMore = true
+		page.NextCursor = models.NewURLCursor(&page.URLs[limit-1], opts.SortBy, opts.Ascending).Encode()
+	}
+	return page, nil
+}
+
+// SearchURLs finds the user's links matching a search query
+func (s *urlService) SearchURLs(ctx context.Context, userID int, opts *models.URLSearchOptions) ([]models.URL, int, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, 0, errors.NewValidationError("Invalid search", err)
+	}
+
+	urls, total, err := s.urlRepo.Search(ctx, userID, opts)
+	if err != nil {
+		return nil, 0, errors.NewDatabaseError("Failed to search URLs", err)
+	}
+
+	setLinkStatuses(urls)
 	return urls, total, nil
 }
 
+// GetCampaignStats groups the user's links and clicks by the UTM campaign stored with them
+func (s *urlService) GetCampaignStats(ctx context.Context, userID int) ([]models.CampaignStats, error) {
+	stats, err := s.urlRepo.GetCampaignStats(ctx, userID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get campaign stats", err)
+	}
+	return stats, nil
+}
+
+// GetDashboard summarizes the user's account: link and click totals, clicks over
+// the last 7 and 30 days, the most clicked links and the recently clicked ones.
+// Each figure comes from a single grouped query over all the user's links.
+func (s *urlService) GetDashboard(ctx context.Context, userID int) (*models.Dashboard, error) {
+	var dashboard *models.Dashboard
+	var err error
+	if s.aggregateOnly() {
+		dashboard, err = s.urlRepo.GetAggregateDashboardTotals(ctx, userID, time.Now())
+	} else {
+		dashboard, err = s.urlRepo.GetDashboardTotals(ctx, userID, time.Now())
+	}
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get dashboard", err)
+	}
+
+	topLinks, _, err := s.urlRepo.GetAllByUser(ctx, userID, &models.URLListOptions{
+		Limit:  models.DashboardTopLinks,
+		SortBy: models.URLSortClickCount,
+	})
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get top links", err)
+	}
+	if topLinks != nil {
+		dashboard.TopLinks = topLinks
+	}
+
+	recentActivity, err := s.GetRecentActivity(ctx, userID, 7*24*time.Hour, models.DashboardRecentLinks)
+	if err != nil {
+		return nil, err
+	}
+	if recentActivity != nil {
+		dashboard.RecentActivity = recentActivity
+	}
+
+	return dashboard, nil
+}
+
+// GetRecentActivity retrieves the user's links clicked within the given window, most recently clicked first
+func (s *urlService) GetRecentActivity(ctx context.Context, userID int, within time.Duration, limit int) ([]models.URL, error) {
+	if within <= 0 {
+		return nil, errors.NewValidationError("Activity window must be positive", nil)
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 10
+	}
+
+	since := time.Now().Add(-within)
+	urls, _, err := s.urlRepo.GetAllByUser(ctx, userID, &models.URLListOptions{
+		Limit:        limit,
+		SortBy:       models.URLSortLastClickedAt,
+		ClickedSince: &since,
+	})
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get recent activity", err)
+	}
+
+	return urls, nil
+}
+
 // DeleteURL deletes a URL by short code
 func (s *urlService) DeleteURL(ctx context.Context, shortCode string, userID int) error {
 	if shortCode == "" {
 		return errors.NewValidationError("Short code is required", nil)
 	}
 
-	// Check ownership first
-	owned, err := s.urlRepo.CheckOwnership(ctx, shortCode, userID)
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
 	if err != nil {
-		return errors.NewDatabaseError("Failed to check URL ownership", err)
+		return err
 	}
-	if !owned {
-		return errors.NewForbiddenError("URL not found or access denied", nil)
+	if models.IsSandbox(ctx) {
+		return nil
 	}
 
 	// Delete from cache first
 	if err := s.cacheRepo.DeleteURL(ctx, shortCode); err != nil {
 		// Log error but don't fail the request
-		fmt.Printf("Failed to delete URL from cache: %v\n", err)
+		log.Printf("Failed to delete URL from cache: %v", err)
 	}
 
 	// Delete from database
 	err = s.urlRepo.DeleteByUser(ctx, shortCode, userID)
 	if err != nil {
+		if repository.IsNotFound(err) {
+			return errors.NewNotFoundError("URL not found", err)
+		}
 		return errors.NewDatabaseError("Failed to delete URL", err)
 	}
 
+	s.events.Record(ctx, url, models.URLEventDeleted, nil)
+
 	return nil
 }
 
+// labeledLinksSample is how many short codes a preview of deleting labeled links lists
+const labeledLinksSample = 20
+
+// PreviewDeleteLabeledLinks describes which of the user's links carry a label value
+func (s *urlService) PreviewDeleteLabeledLinks(ctx context.Context, userID int, req *models.DeleteLabeledLinksRequest) (*models.LabeledLinksImpact, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid label", err)
+	}
+
+	impact, err := s.urlRepo.GetLabeledLinksImpact(ctx, userID, req.Label, req.Value, labeledLinksSample)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to count labeled links", err)
+	}
+	return impact, nil
+}
+
+// DeleteLabeledLinks deletes all of the user's links carrying a label value
+// and returns how many were deleted. Nothing is deleted unless the number of
+// links carrying it is still the expected one, as previewed.
+func (s *urlService) DeleteLabeledLinks(ctx context.Context, userID int, req *models.DeleteLabeledLinksRequest, expected int) (int, error) {
+	if err := req.Validate(); err != nil {
+		return 0, errors.NewValidationError("Invalid label", err)
+	}
+
+	urls, err := s.urlRepo.DeleteByLabel(ctx, userID, req.Label, req.Value, expected)
+	if err != nil {
+		if repository.IsChanged(err) {
+			return 0, errors.NewConflictError("The labeled links changed since the preview, preview the action again", err)
+		}
+		return 0, errors.NewDatabaseError("Failed to delete labeled links", err)
+	}
+
+	if len(urls) > 0 {
+		shortCodes := make([]string, len(urls))
+		for i := range urls {
+			shortCodes[i] = urls[i].ShortCode
+		}
+		if _, err := s.cacheRepo.PurgeURLs(ctx, shortCodes); err != nil {
+			// Log error but don't fail the request
+			log.Printf("Failed to delete URLs from cache: %v", err)
+		}
+	}
+	for i := range urls {
+		s.events.Record(ctx, &urls[i], models.URLEventDeleted, nil)
+	}
+	return len(urls), nil
+}
+
 // UpdateURL updates a URL
 func (s *urlService) UpdateURL(ctx context.Context, shortCode string, req *models.UpdateURLRequest, userID int) (*models.URL, error) {
 	if shortCode == "" {
@@ -241,14 +691,29 @@ func (s *urlService) UpdateURL(ctx context.Context, shortCode string, req *model
 		return nil, errors.NewDatabaseError("Failed to get URL", err)
 	}
 
+	wasActive := url.IsActive
+
 	// Track if status-related fields are being changed
 	statusChanged := false
 	
 	// Update fields
-	if req.OriginalURL != "" {
+	var destinationChange *models.DestinationChange
+	if req.OriginalURL != "" && req.OriginalURL != url.OriginalURL {
+		destinationChange = &models.DestinationChange{
+			URLID:       url.ID,
+			UserID:      &userID,
+			PreviousURL: url.OriginalURL,
+			NewURL:      req.OriginalURL,
+			CreatedAt:   time.Now(),
+		}
 		url.OriginalURL = req.OriginalURL
+		// The previous destination's metadata no longer applies
+		url.LinkMetadata = models.LinkMetadata{}
 	}
 	if req.IsActive != nil {
+		if *req.IsActive && url.NeedsReview {
+			return nil, errors.NewForbiddenError("URL is pending review and cannot be activated", nil)
+		}
 		if url.IsActive != *req.IsActive {
 			statusChanged = true
 		}
@@ -261,99 +726,1185 @@ func (s *urlService) UpdateURL(ctx context.Context, shortCode string, req *model
 		}
 		url.ExpiresAt = req.ExpiresAt.Time
 	}
+	if req.ActivatesAt.Time != nil {
+		if url.ActivatesAt == nil || !url.ActivatesAt.Equal(*req.ActivatesAt.Time) {
+			statusChanged = true
+		}
+		url.ActivatesAt = req.ActivatesAt.Time
+	}
+	if url.ExpiryDeactivated && !url.IsExpired() {
+		// The cleanup job deactivated the link on expiry; a new expiration brings
+		// it back unless the owner says otherwise
+		if req.IsActive == nil {
+			url.IsActive = true
+			statusChanged = true
+		}
+		url.ExpiryDeactivated = false
+	} else if req.IsActive != nil {
+		url.ExpiryDeactivated = false
+	}
+	if err := url.ValidateActivationWindow(); err != nil {
+		return nil, errors.NewValidationError("Invalid request", err)
+	}
+	if req.ReferrerRules != nil {
+		req.ReferrerRules.Apply(url)
+	}
+	if req.Password != nil {
+		if err := url.SetPassword(*req.Password); err != nil {
+			return nil, errors.NewInternalError("Failed to set link password", err)
+		}
+	}
+	if req.InactivityExpiryDays != nil {
+		url.InactivityExpiryDays = *req.InactivityExpiryDays
+	}
+	if req.MaxClicksPerMinute != nil {
+		url.MaxClicksPerMinute = *req.MaxClicksPerMinute
+	}
+	if req.MaxClicks != nil {
+		url.MaxClicks = *req.MaxClicks
+	}
+	if url.ClickLimitDeactivated && (url.MaxClicks == 0 || url.RedirectCount < url.MaxClicks) {
+		// The link used up its redirects; raising or removing the limit brings
+		// it back unless the owner says otherwise
+		if req.IsActive == nil {
+			url.IsActive = true
+			statusChanged = true
+		}
+		url.ClickLimitDeactivated = false
+	} else if req.IsActive != nil {
+		url.ClickLimitDeactivated = false
+	}
+	if req.RedirectType != nil {
+		url.RedirectType = *req.RedirectType
+	}
+	if req.FrequencyCap != nil {
+		req.FrequencyCap.Apply(url)
+	}
+	if req.ShadowTraffic != nil {
+		req.ShadowTraffic.Apply(url, time.Now())
+	}
+	if req.Rotation != nil {
+		req.Rotation.Apply(url)
+	}
+	if req.Canary != nil {
+		req.Canary.Apply(url, time.Now())
+	}
+	if req.SplitTest != nil {
+		req.SplitTest.Apply(url)
+	}
+	if url.IsRotator() && url.IsCanaryRollingOut() {
+		return nil, errors.NewValidationError("Rotator links can't have a canary rollout", nil)
+	}
+	if url.IsSplitTest() && (url.IsRotator() || url.IsCanaryRollingOut()) {
+		return nil, errors.NewValidationError("Split test links can't rotate destinations or have a canary rollout", nil)
+	}
+	if req.Title != nil {
+		url.Title = *req.Title
+	}
+	if req.Notes != nil {
+		url.Notes = *req.Notes
+	}
+	if req.Labels != nil {
+		url.Labels = *req.Labels
+	}
+	if req.DeviceRules != nil {
+		url.DeviceRules = *req.DeviceRules
+	}
+	if req.Domain != nil && *req.Domain != url.Domain {
+		if *req.Domain != "" {
+			if err := s.domains.CheckLinkDomain(ctx, userID, *req.Domain); err != nil {
+				return nil, err
+			}
+		}
+		url.Domain = *req.Domain
+	}
+
+	// A new destination gets the same screening as a new link, so a clean
+	// link can't later be pointed somewhere it would have been refused
+	canaryStarted := req.Canary != nil && req.Canary.URL != ""
+	splitTestStarted := req.SplitTest != nil && len(req.SplitTest.Variants) > 0
+	deviceRulesSet := req.DeviceRules != nil && len(req.DeviceRules.WebDestinations()) > 0
+	if destinationChange != nil || (req.Rotation != nil && len(req.Rotation.Destinations) > 0) || canaryStarted || splitTestStarted || deviceRulesSet {
+		user, err := s.userRepo.GetByID(ctx, userID)
+		if err != nil {
+			return nil, errors.NewDatabaseError("Failed to get user", err)
+		}
+		needsReview := false
+		if destinationChange != nil {
+			if needsReview, err = s.checkDestination(ctx, user, url.OriginalURL); err != nil {
+				return nil, err
+			}
+		}
+		if req.Rotation != nil {
+			rotationNeedsReview, err := s.checkDestinations(ctx, user, req.Rotation.Destinations)
+			if err != nil {
+				return nil, err
+			}
+			needsReview = needsReview || rotationNeedsReview
+		}
+		if splitTestStarted {
+			splitNeedsReview, err := s.checkDestinations(ctx, user, req.SplitTest.Destinations())
+			if err != nil {
+				return nil, err
+			}
+			needsReview = needsReview || splitNeedsReview
+		}
+		if deviceRulesSet {
+			deviceNeedsReview, err := s.checkDestinations(ctx, user, req.DeviceRules.WebDestinations())
+			if err != nil {
+				return nil, err
+			}
+			needsReview = needsReview || deviceNeedsReview
+		}
+		if canaryStarted {
+			canaryNeedsReview, err := s.checkDestination(ctx, user, req.Canary.URL)
+			if err != nil {
+				return nil, err
+			}
+			needsReview = needsReview || canaryNeedsReview
+		}
+		if needsReview {
+			if url.IsActive {
+				statusChanged = true
+			}
+			url.Deactivate()
+			url.NeedsReview = true
+			if destinationChange != nil {
+				destinationChange.HeldForReview = true
+			}
+		}
+	}
 	url.UpdatedAt = time.Now()
 
+	// Sandbox requests get the link as it would have been updated, without saving it
+	if models.IsSandbox(ctx) {
+		return url, nil
+	}
+
 	// Update in database
 	updatedURL, err := s.urlRepo.Update(ctx, url)
 	if err != nil {
 		return nil, errors.NewDatabaseError("Failed to update URL", err)
 	}
+	if req.Rotation != nil {
+		if err := s.urlRepo.SetDestinations(ctx, updatedURL.ID, req.Rotation.Destinations); err != nil {
+			return nil, errors.NewDatabaseError("Failed to store rotation destinations", err)
+		}
+	}
+	if req.SplitTest != nil {
+		if err := s.setVariants(ctx, updatedURL.ID, req.SplitTest.Variants); err != nil {
+			return nil, errors.NewDatabaseError("Failed to store split test variants", err)
+		}
+	}
 
-	// Clear cache if status changed or URL is inactive/expired
-	if statusChanged || !updatedURL.IsActive || updatedURL.IsExpired() {
+	// Clear cache if status changed, URL is inactive/expired, or it now has access rules
+	if statusChanged || !updatedURL.IsActive || updatedURL.IsExpired() || !updatedURL.Cacheable() {
 		if err := s.cacheRepo.DeleteURL(ctx, shortCode); err != nil {
 			// Log error but don't fail the request
-			fmt.Printf("Failed to delete URL from cache: %v\n", err)
+			log.Printf("Failed to delete URL from cache: %v", err)
 		}
 	} else {
 		// Update cache only if URL is still active and not expired
-		if err := s.cacheRepo.SetURL(ctx, shortCode, updatedURL.OriginalURL, 24*time.Hour); err != nil {
+		if err := s.cacheRepo.SetURL(ctx, shortCode, updatedURL.TaggedURL(), s.urlCacheTTL(updatedURL)); err != nil {
+			// Log error but don't fail the request
+			log.Printf("Failed to update URL in cache: %v", err)
+		}
+	}
+
+	s.webhooks.Dispatch(ctx, updatedURL, models.WebhookEventLinkUpdated, updatedURL)
+
+	if destinationChange != nil {
+		if _, err := s.urlRepo.CreateDestinationChange(ctx, destinationChange); err != nil {
 			// Log error but don't fail the request
-			fmt.Printf("Failed to update URL in cache: %v\n", err)
+			log.Printf("Failed to record destination change: %v", err)
+		}
+		s.webhooks.Dispatch(ctx, updatedURL, models.WebhookEventLinkDestinationChanged, destinationChange)
+		s.events.Record(ctx, updatedURL, models.URLEventDestinationChanged, destinationChange)
+	}
+	if updatedURL.IsActive != wasActive {
+		reason := models.URLEventReasonOwner
+		if updatedURL.NeedsReview {
+			reason = models.URLEventReasonReview
 		}
+		s.recordStatusChange(ctx, updatedURL, reason)
 	}
 
 	return updatedURL, nil
 }
 
-// RecordClick records a click event
-func (s *urlService) RecordClick(ctx context.Context, shortCode, clientIP, userAgent, referer string) error {
-	// Get URL
-	url, err := s.GetURL(ctx, shortCode)
+// ExtendExpiration pushes a link's expiration date forward and records who extended it.
+// Already-expired links are extended from now, which reactivates them, unless
+// they were deactivated for another reason than expiring.
+func (s *urlService) ExtendExpiration(ctx context.Context, shortCode string, req *models.ExtendExpirationRequest, userID int) (*models.URL, *models.ExpirationExtension, error) {
+	duration, err := req.ParseDuration()
 	if err != nil {
-		return err
+		return nil, nil, errors.NewValidationError("Invalid extension", err)
 	}
 
-	// Create click event
-	clickEvent := &models.ClickEvent{
-		URLId:     url.ID,
-		IPAddress: clientIP,
-		UserAgent: userAgent,
-		Referer:   referer,
-		ClickedAt: time.Now(),
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if url.ExpiresAt == nil {
+		return nil, nil, errors.NewBadRequestError("URL does not expire", nil)
 	}
 
-	// Save click event
-	if err := s.urlRepo.CreateClickEvent(ctx, clickEvent); err != nil {
-		return errors.NewDatabaseError("Failed to record click", err)
+	now := time.Now()
+	from := *url.ExpiresAt
+	if from.Before(now) {
+		from = now
 	}
 
-	// Increment click count
-	if err := s.urlRepo.IncrementClickCount(ctx, shortCode); err != nil {
-		return errors.NewDatabaseError("Failed to increment click count", err)
+	extension, err := s.urlRepo.ExtendExpiration(ctx, &models.ExpirationExtension{
+		URLID:             url.ID,
+		UserID:            &userID,
+		PreviousExpiresAt: *url.ExpiresAt,
+		NewExpiresAt:      from.Add(duration),
+		DurationSeconds:   int64(duration / time.Second),
+		CreatedAt:         now,
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "changed concurrently") {
+			return nil, nil, errors.NewConflictError("URL expiration was changed by another request", err)
+		}
+		return nil, nil, errors.NewDatabaseError("Failed to extend URL expiration", err)
 	}
 
-	// Increment click count in cache
-	if err := s.cacheRepo.IncrementClickCount(ctx, shortCode); err != nil {
+	url.ExpiresAt = &extension.NewExpiresAt
+	url.IsActive = url.IsActive || url.ExpiryDeactivated
+	url.ExpiryDeactivated = false
+	url.UpdatedAt = now
+
+	// The cached entry's TTL was bounded by the old expiration
+	if err := s.cacheRepo.DeleteURL(ctx, shortCode); err != nil {
 		// Log error but don't fail the request
-		fmt.Printf("Failed to increment click count in cache: %v\n", err)
+		log.Printf("Failed to delete URL from cache: %v", err)
 	}
 
-	return nil
+	s.webhooks.Dispatch(ctx, url, models.WebhookEventLinkExtended, extension)
+	if extension.PreviousExpiresAt.Before(now) && url.IsActive {
+		s.events.Record(ctx, url, models.URLEventActivated, models.URLEventStatusChange{Reason: models.URLEventReasonExtended})
+	}
+
+	return url, extension, nil
 }
 
-// GetURLStats retrieves URL statistics
-func (s *urlService) GetURLStats(ctx context.Context, shortCode string, userID int) (*models.URLStatsResponse, error) {
-	// Check ownership first
-	owned, err := s.urlRepo.CheckOwnership(ctx, shortCode, userID)
+// GetExpirationExtensions returns the extension history of a user's link
+func (s *urlService) GetExpirationExtensions(ctx context.Context, shortCode string, userID int) ([]models.ExpirationExtension, error) {
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
 	if err != nil {
-		return nil, errors.NewDatabaseError("Failed to check URL ownership", err)
-	}
-	if !owned {
-		return nil, errors.NewForbiddenError("URL not found or access denied", nil)
+		return nil, err
 	}
 
-	// Get URL
-	url, err := s.GetURL(ctx, shortCode)
+	extensions, err := s.urlRepo.GetExpirationExtensions(ctx, url.ID)
 	if err != nil {
-		return nil, err
+		return nil, errors.NewDatabaseError("Failed to get expiration extensions", err)
 	}
+	return extensions, nil
+}
 
-	// Get analytics
-	analytics, err := s.GetAnalytics(ctx, shortCode, userID, 30) // Get 30 days analytics
+// GetDestinationChanges returns the destination change history of a user's link
+func (s *urlService) GetDestinationChanges(ctx context.Context, shortCode string, userID int) ([]models.DestinationChange, error) {
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get recent clicks
-	recentClicks, err := s.urlRepo.GetClickEvents(ctx, url.ID, 10)
+	changes, err := s.urlRepo.GetDestinationChanges(ctx, url.ID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get destination changes", err)
+	}
+	return changes, nil
+}
+
+// GetURLEvents returns a page of a user's link's lifecycle events, newest first
+func (s *urlService) GetURLEvents(ctx context.Context, shortCode string, userID int, opts *models.URLEventListOptions) ([]models.URLEvent, error) {
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.events.GetEvents(ctx, url, opts)
+}
+
+// recordStatusChange records that a link was activated or deactivated, and why
+func (s *urlService) recordStatusChange(ctx context.Context, url *models.URL, reason string) {
+	eventType := models.URLEventDeactivated
+	if url.IsActive {
+		eventType = models.URLEventActivated
+	}
+	s.events.Record(ctx, url, eventType, models.URLEventStatusChange{Reason: reason})
+}
+
+// getOwnedURL loads one of the user's links, including inactive ones
+func (s *urlService) getOwnedURL(ctx context.Context, shortCode string, userID int) (*models.URL, error) {
+	owned, err := s.urlRepo.CheckOwnership(ctx, shortCode, userID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to check URL ownership", err)
+	}
+	if !owned {
+		return nil, errors.NewForbiddenError("URL not found or access denied", nil)
+	}
+
+	url, err := s.urlRepo.GetByShortCode(ctx, shortCode)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("URL not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get URL", err)
+	}
+	return url, nil
+}
+
+// RecordClick records a click event that arrived through the given channel
+func (s *urlService) RecordClick(ctx context.Context, shortCode, clientIP, userAgent, referer, channel string) error {
+	// The visit was already allowed, so don't re-check the link's status: the
+	// last redirect of a click-limited link has deactivated it by now
+	url, err := s.urlRepo.GetByShortCode(ctx, shortCode)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return errors.NewNotFoundError("URL not found", err)
+		}
+		return errors.NewDatabaseError("Failed to get URL", err)
+	}
+
+	// Create click event
+	clickEvent := &models.ClickEvent{
+		URLId:     url.ID,
+		IPAddress: clientIP,
+		UserAgent: userAgent,
+		Referer:   referer,
+		ClickedAt: time.Now(),
+		Channel:   channel,
+	}
+	s.enrichClick(ctx, url, clickEvent)
+
+	if s.aggregateOnly() {
+		// Count the click without keeping who made it
+		s.recordUniqueVisitor(ctx, shortCode, clientIP, userAgent)
+		client := models.ClientInfo{Browser: clickEvent.Browser, Device: clickEvent.Device, OS: clickEvent.OS}
+		aggregate := models.NewClickAggregate(url.ID, clickEvent.ClickedAt, clickEvent.Country, referer, client, clickEvent.Channel)
+		if err := s.urlRepo.RecordClickAggregate(ctx, aggregate); err != nil {
+			return errors.NewDatabaseError("Failed to record click", err)
+		}
+		clickEvent.IPAddress = ""
+		clickEvent.UserAgent = ""
+		clickEvent.Referer = aggregate.Referrer
+		clickEvent.Attributes = nil
+	} else {
+		clickEvent.SampleWeight = s.sampleClick(ctx, shortCode)
+		if clickEvent.SampleWeight > 0 {
+			if err := s.urlRepo.CreateClickEvent(ctx, clickEvent); err != nil {
+				return errors.NewDatabaseError("Failed to record click", err)
+			}
+		}
+	}
+
+	// Increment click count
+	if err := s.urlRepo.IncrementClickCount(ctx, shortCode); err != nil {
+		return errors.NewDatabaseError("Failed to increment click count", err)
+	}
+
+	// Increment click count in cache
+	clicks, err := s.cacheRepo.IncrementClickCount(ctx, shortCode)
+	if err != nil {
+		// Log error but don't fail the request
+		log.Printf("Failed to increment click count in cache: %v", err)
+	} else if clicks == 1 && url.ClickCount > 0 {
+		// The cached counter was lost; resync it from the database
+		clicks = int64(url.ClickCount) + 1
+		if err := s.cacheRepo.SetClickCount(ctx, shortCode, clicks); err != nil {
+			log.Printf("Failed to resync click count in cache: %v", err)
+		}
+	}
+
+	s.webhooks.Dispatch(ctx, url, models.WebhookEventLinkClicked, clickEvent)
+	if url.IsMirroring(time.Now()) {
+		s.webhooks.Mirror(ctx, url, models.NewShadowClick(shortCode, clickEvent))
+	}
+	if err == nil {
+		s.webhooks.EvaluateClickTriggers(ctx, url, clicks)
+	}
+	s.runAfterClickHooks(ctx, url, clickEvent)
+
+	return nil
+}
+
+// sampleClick counts a link's clicks this minute and returns how many clicks
+// the click's event stands for, or 0 when sampling leaves it out. Click counts
+// are kept exactly either way.
+func (s *urlService) sampleClick(ctx context.Context, shortCode string) int {
+	threshold := s.config.App.AnalyticsSamplingThreshold
+	if threshold == 0 {
+		return 1
+	}
+
+	key := fmt.Sprintf("link_sample_rate:%s:%d", shortCode, time.Now().Unix()/60)
+	clicks, err := s.cacheRepo.IncrementWithExpiry(ctx, key, 2*time.Minute)
+	if err != nil {
+		// Store every event rather than lose clicks while Redis is down
+		log.Printf("Failed to count link clicks for sampling: %v", err)
+		return 1
+	}
+	return models.ClickSampleWeight(clicks, int64(threshold), s.config.App.AnalyticsSampleRate)
+}
+
+// ExpireInactiveURLs expires links that have gone unclicked for longer than their
+// inactivity policy allows and evicts them from the redirect cache
+func (s *urlService) ExpireInactiveURLs(ctx context.Context) (int, error) {
+	expired := 0
+	for _, region := range s.regions.Regions() {
+		regionCtx := repository.WithRegion(ctx, region)
+		shortCodes, err := s.urlRepo.ExpireInactive(regionCtx, time.Now())
+		if err != nil {
+			return expired, errors.NewDatabaseError("Failed to expire inactive URLs", err)
+		}
+
+		for _, shortCode := range shortCodes {
+			if err := s.cacheRepo.DeleteURL(regionCtx, shortCode); err != nil {
+				// Log error but keep expiring the rest
+				log.Printf("Failed to delete URL from cache: %v", err)
+			}
+		}
+		expired += len(shortCodes)
+	}
+
+	return expired, nil
+}
+
+// cleanupBatchSize is how many rows the cleanup jobs change per query
+const cleanupBatchSize = 1000
+
+// cleanupMaxBatches caps the batches a cleanup job runs per region per run, so
+// a backlog is worked through over several runs
+const cleanupMaxBatches = 50
+
+// DeactivateExpiredURLs deactivates active links whose expiration has passed and
+// evicts them from the redirect cache. Expired links already stop redirecting;
+// this keeps is_active truthful for everything reading it directly.
+func (s *urlService) DeactivateExpiredURLs(ctx context.Context) (int, error) {
+	deactivated := 0
+	for _, region := range s.regions.Regions() {
+		regionCtx := repository.WithRegion(ctx, region)
+		for batch := 0; batch < cleanupMaxBatches; batch++ {
+			urls, err := s.urlRepo.DeactivateExpired(regionCtx, time.Now(), cleanupBatchSize)
+			if err != nil {
+				return deactivated, errors.NewDatabaseError("Failed to deactivate expired URLs", err)
+			}
+			if len(urls) == 0 {
+				break
+			}
+
+			shortCodes := make([]string, len(urls))
+			for i := range urls {
+				shortCodes[i] = urls[i].ShortCode
+			}
+			if _, err := s.cacheRepo.PurgeURLs(regionCtx, shortCodes); err != nil {
+				// Log error but keep deactivating the rest
+				log.Printf("Failed to delete URLs from cache: %v", err)
+			}
+			s.events.RecordBatch(regionCtx, urls, models.URLEventDeactivated, models.URLEventStatusChange{Reason: models.URLEventReasonExpired})
+			deactivated += len(urls)
+
+			if len(urls) < cleanupBatchSize {
+				break
+			}
+		}
+	}
+	return deactivated, nil
+}
+
+// DeleteOldClickEvents deletes raw click events older than the click event
+// retention window. Links' click counts are kept.
+func (s *urlService) DeleteOldClickEvents(ctx context.Context) (int64, error) {
+	retention := s.config.App.ClickEventRetention
+	if retention == 0 {
+		return 0, nil
+	}
+
+	var deleted int64
+	for _, region := range s.regions.Regions() {
+		regionCtx := repository.WithRegion(ctx, region)
+		before := time.Now().Add(-retention)
+		for batch := 0; batch < cleanupMaxBatches; batch++ {
+			n, err := s.urlRepo.DeleteClickEventsBefore(regionCtx, before, cleanupBatchSize)
+			deleted += n
+			if err != nil {
+				return deleted, errors.NewDatabaseError("Failed to delete old click events", err)
+			}
+			if n < cleanupBatchSize {
+				break
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// rescanBatchSize is how many links RescanDestinations scans per query
+const rescanBatchSize = 500
+
+// rescanMaxBatches caps the links rescanned per region per run, so a backlog
+// after enabling the scanner is worked through over several runs
+const rescanMaxBatches = 20
+
+// RescanDestinations rescans the destinations of links not scanned within the
+// rescan interval, flagging any that are now on a threat list
+func (s *urlService) RescanDestinations(ctx context.Context) (int, error) {
+	interval := s.config.Abuse.URLRescanInterval
+	if s.scanner == nil || interval == 0 {
+		return 0, nil
+	}
+
+	flagged := 0
+	for _, region := range s.regions.Regions() {
+		regionCtx := repository.WithRegion(ctx, region)
+		for batch := 0; batch < rescanMaxBatches; batch++ {
+			now := time.Now()
+			targets, err := s.urlRepo.GetLinksToScan(regionCtx, now.Add(-interval), rescanBatchSize)
+			if err != nil {
+				return flagged, errors.NewDatabaseError("Failed to get links to scan", err)
+			}
+			if len(targets) == 0 {
+				break
+			}
+
+			var destinations []string
+			ids := make([]int, 0, len(targets))
+			for _, target := range targets {
+				destinations = append(destinations, target.Destinations...)
+				ids = append(ids, target.ID)
+			}
+			threats, err := s.scanner.Scan(regionCtx, destinations)
+			if err != nil {
+				return flagged, errors.NewExternalServiceError("Failed to scan link destinations", err)
+			}
+
+			for _, target := range targets {
+				for _, destination := range target.Destinations {
+					threatType, ok := threats[destination]
+					if !ok {
+						continue
+					}
+					if s.flagThreat(regionCtx, target, threatType) {
+						flagged++
+					}
+					break
+				}
+			}
+
+			if err := s.urlRepo.MarkScanned(regionCtx, ids, now); err != nil {
+				return flagged, errors.NewDatabaseError("Failed to mark links scanned", err)
+			}
+			if len(targets) < rescanBatchSize {
+				break
+			}
+		}
+	}
+
+	return flagged, nil
+}
+
+// flagThreat acts on a link whose destination was flagged: lower-severity
+// threats mark it suspicious, anything else deactivates it. It reports whether
+// the link changed.
+func (s *urlService) flagThreat(ctx context.Context, target models.LinkScanTarget, threatType string) bool {
+	warnOnly := warningThreats[threatType]
+	var flagged bool
+	var err error
+	if warnOnly {
+		flagged, err = s.urlRepo.WarnThreat(ctx, target.ID, threatType)
+	} else {
+		flagged, err = s.urlRepo.FlagThreat(ctx, target.ID, threatType)
+	}
+	if err != nil {
+		log.Printf("Failed to flag URL %s: %v", target.ShortCode, err)
+		return false
+	}
+	if !flagged {
+		return false
+	}
+
+	if warnOnly {
+		log.Printf("Marked URL %s suspicious: destination flagged as %s", target.ShortCode, threatType)
+	} else {
+		log.Printf("Deactivated URL %s: destination flagged as %s", target.ShortCode, threatType)
+	}
+	if err := s.cacheRepo.DeleteURL(ctx, target.ShortCode); err != nil {
+		// Log error but don't fail the rescan
+		log.Printf("Failed to delete URL from cache: %v", err)
+	}
+	if url, err := s.urlRepo.GetByShortCode(ctx, target.ShortCode); err == nil {
+		s.webhooks.Dispatch(ctx, url, models.WebhookEventLinkUpdated, url)
+		if !warnOnly {
+			s.recordStatusChange(ctx, url, models.URLEventReasonThreat)
+		}
+	}
+	return true
+}
+
+// SetSuspicious sets or clears a link's suspicious flag, which puts a warning
+// page in front of its redirect
+func (s *urlService) SetSuspicious(ctx context.Context, shortCode string, suspicious bool) (*models.URL, error) {
+	url, err := s.urlRepo.GetByShortCode(ctx, shortCode)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("URL not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get URL", err)
+	}
+
+	if err := s.urlRepo.SetSuspicious(ctx, url.ID, suspicious); err != nil {
+		return nil, errors.NewDatabaseError("Failed to update URL", err)
+	}
+	url.Suspicious = suspicious
+	url.UpdatedAt = time.Now()
+
+	// Cached redirects skip the warning page
+	if err := s.cacheRepo.DeleteURL(ctx, shortCode); err != nil {
+		// Log error but don't fail the request
+		log.Printf("Failed to delete URL from cache: %v", err)
+	}
+
+	s.webhooks.Dispatch(ctx, url, models.WebhookEventLinkUpdated, url)
+
+	return url, nil
+}
+
+// CheckReferrer enforces a link's referrer rules, recording rejected attempts for analytics
+func (s *urlService) CheckReferrer(ctx context.Context, url *models.URL, clientIP, userAgent, referer string) error {
+	if url.ReferrerAllowed(referer) {
+		return nil
+	}
+
+	s.recordBlockedClick(ctx, url, models.BlockReasonReferrer, clientIP, userAgent, referer)
+
+	return errors.NewReferrerBlockedError("Referrer not allowed for this link", nil)
+}
+
+// CheckClickRate enforces a link's redirect rate cap using a per-minute counter,
+// recording throttled attempts for analytics
+func (s *urlService) CheckClickRate(ctx context.Context, url *models.URL, clientIP, userAgent, referer string) error {
+	if !url.IsThrottled() {
+		return nil
+	}
+
+	key := fmt.Sprintf("link_click_rate:%s:%d", url.ShortCode, time.Now().Unix()/60)
+	clicks, err := s.cacheRepo.IncrementWithExpiry(ctx, key, 2*time.Minute)
+	if err != nil {
+		// Fail open: Redis being down should not make throttled links unreachable
+		log.Printf("Failed to count link clicks: %v", err)
+		return nil
+	}
+	if clicks <= int64(url.MaxClicksPerMinute) {
+		return nil
+	}
+
+	s.recordBlockedClick(ctx, url, models.BlockReasonThrottled, clientIP, userAgent, referer)
+
+	return errors.NewLinkThrottledError("This link is receiving too much traffic. Please try again shortly", nil)
+}
+
+// ResolveFrequencyCap counts the visitor's redirects to a frequency-capped link today.
+// Once the cap is reached it returns the link's alternate URL and true.
+func (s *urlService) ResolveFrequencyCap(ctx context.Context, url *models.URL, clientIP, userAgent, referer string) (string, bool) {
+	if !url.IsFrequencyCapped() {
+		return "", false
+	}
+
+	visitor, err := s.visitorHash(ctx, clientIP, userAgent)
+	if err != nil {
+		// Fail open: Redis being down should not reroute every visitor
+		log.Printf("Failed to identify visitor: %v", err)
+		return "", false
+	}
+
+	key := fmt.Sprintf("link_frequency:%s:%s", url.ShortCode, visitor)
+	visits, err := s.cacheRepo.IncrementWithExpiry(ctx, key, 24*time.Hour)
+	if err != nil {
+		log.Printf("Failed to count visitor redirects: %v", err)
+		return "", false
+	}
+	if visits <= int64(url.FrequencyCap) {
+		return "", false
+	}
+
+	s.recordBlockedClick(ctx, url, models.BlockReasonFrequencyCapped, clientIP, userAgent, referer)
+
+	return url.FrequencyCapURL, true
+}
+
+// RotateDestination returns where a visit to the link goes: the destination of
+// the first device rule matching the visitor's user agent, the next (or a random)
+// destination of a rotator link, counting the click against it, a split test
+// variant drawn by weight (the same one for each visitor IP of sticky tests),
+// counted likewise and tagged with its name, the new destination for the
+// canary rollout's current share of visits, and the link's own URL otherwise,
+// tagged with the link's UTM parameters. Failures fall back to the link's own URL.
+// QR payload landing pages of gated links come with a grant to open them once.
+func (s *urlService) RotateDestination(ctx context.Context, url *models.URL, clientIP, userAgent string) string {
+	return s.grantQRLanding(ctx, url, s.rotateDestination(ctx, url, clientIP, userAgent))
+}
+
+// rotateDestination picks the destination of a visit for RotateDestination
+func (s *urlService) rotateDestination(ctx context.Context, url *models.URL, clientIP, userAgent string) string {
+	if destination, ok := url.DeviceDestination(models.ParseUserAgent(userAgent)); ok {
+		return destination
+	}
+	if url.IsCanaryRollingOut() && url.PickCanary(time.Now()) {
+		return url.UTM.Tag(url.CanaryURL)
+	}
+	if url.IsSplitTest() {
+		return s.splitDestination(ctx, url, clientIP)
+	}
+	if !url.IsRotator() {
+		return url.TaggedURL()
+	}
+
+	destinations, err := s.urlRepo.GetDestinations(ctx, url.ID)
+	if err != nil {
+		log.Printf("Failed to get destinations of %s: %v", url.ShortCode, err)
+		return url.TaggedURL()
+	}
+
+	var sequence int64
+	if url.RotationMode == models.RotationModeSequential {
+		// The shared counter keeps the rotation fair across instances; if Redis
+		// is down the pick falls back to random
+		key := fmt.Sprintf("link_rotation:%s", url.ShortCode)
+		if sequence, err = s.cacheRepo.IncrementWithExpiry(ctx, key, 30*24*time.Hour); err != nil {
+			log.Printf("Failed to advance rotation of %s: %v", url.ShortCode, err)
+		}
+	}
+
+	destination := models.PickDestination(destinations, url.RotationMode, sequence)
+	if destination == nil {
+		return url.TaggedURL()
+	}
+	if err := s.urlRepo.RecordDestinationClick(ctx, destination.ID); err != nil {
+		log.Printf("Failed to record destination click: %v", err)
+	}
+	return url.UTM.Tag(destination.URL)
+}
+
+// grantQRLanding lets a visit of a password-protected or click-limited link,
+// which passed the link's checks, into the QR payload landing page it goes to,
+// once. Its landing URL alone doesn't open the page.
+func (s *urlService) grantQRLanding(ctx context.Context, url *models.URL, destination string) string {
+	if !url.IsPasswordProtected() && !url.HasClickLimit() {
+		return destination
+	}
+	rest, ok := strings.CutPrefix(destination, s.baseURL+models.QRLandingPath)
+	if !ok {
+		return destination
+	}
+	token, _, _ := strings.Cut(rest, "?")
+
+	parsed, err := neturl.Parse(destination)
+	if err != nil {
+		return destination
+	}
+	grant, err := randomHex(16)
+	if err != nil {
+		log.Printf("Failed to generate QR landing grant: %v", err)
+		return destination
+	}
+	if err := s.cacheRepo.Set(ctx, qrLandingGrantKey(grant), token, models.QRLandingGrantTTL); err != nil {
+		log.Printf("Failed to store QR landing grant: %v", err)
+		return destination
+	}
+
+	query := parsed.Query()
+	query.Set(models.QRLandingGrantParam, grant)
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+// splitDestination draws the variant of a visit to a split test link
+func (s *urlService) splitDestination(ctx context.Context, url *models.URL, clientIP string) string {
+	variants, err := s.getCachedVariants(ctx, url.ID)
+	if err != nil {
+		log.Printf("Failed to get variants of %s: %v", url.ShortCode, err)
+		return url.TaggedURL()
+	}
+
+	variant := models.PickVariant(variants, url.SplitMode, url.ShortCode, clientIP)
+	if variant == nil {
+		return url.TaggedURL()
+	}
+	s.variantClicks.Add(ctx, variant.ID)
+	return url.UTM.Tag(models.TagVariant(variant.URL, variant.Name))
+}
+
+// variantCacheTTL is how long a split test's variants are cached for its
+// visits. Storing new variants invalidates the cache.
+const variantCacheTTL = 10 * time.Minute
+
+// getCachedVariants returns the variants of a split test link for picking one,
+// caching them. Their click and conversion counts may be stale. The database
+// is used while Redis is unavailable.
+func (s *urlService) getCachedVariants(ctx context.Context, urlID int) ([]models.URLVariant, error) {
+	key := variantsKey(urlID)
+	if value, err := s.cacheRepo.Get(ctx, key); err == nil {
+		var variants []models.URLVariant
+		if err := json.Unmarshal([]byte(value), &variants); err == nil {
+			return variants, nil
+		}
+	} else if !repository.IsCacheMiss(err) {
+		log.Printf("Failed to get cached variants: %v", err)
+	}
+
+	variants, err := s.urlRepo.GetVariants(ctx, urlID)
+	if err != nil {
+		return nil, err
+	}
+	if value, err := json.Marshal(variants); err == nil {
+		if err := s.cacheRepo.Set(ctx, key, string(value), variantCacheTTL); err != nil {
+			log.Printf("Failed to cache variants: %v", err)
+		}
+	}
+	return variants, nil
+}
+
+// setVariants stores a split test link's variants and drops the cached ones
+func (s *urlService) setVariants(ctx context.Context, urlID int, variants []models.SplitVariant) error {
+	if err := s.urlRepo.SetVariants(ctx, urlID, variants); err != nil {
+		return err
+	}
+	if err := s.cacheRepo.Delete(ctx, variantsKey(urlID)); err != nil {
+		log.Printf("Failed to invalidate cached variants: %v", err)
+	}
+	return nil
+}
+
+// variantsKey returns the cache key of a split test link's variants
+func variantsKey(urlID int) string {
+	return fmt.Sprintf("split_variants:%d", urlID)
+}
+
+// RecordConversion counts a conversion of a variant of a user's split test link,
+// reported by the owner's site for visitors it got with the variant's name
+func (s *urlService) RecordConversion(ctx context.Context, shortCode, variant string, userID int) (*models.URLVariant, error) {
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !url.IsSplitTest() {
+		return nil, errors.NewValidationError("URL is not split tested", nil)
+	}
+
+	recorded, err := s.urlRepo.RecordVariantConversion(ctx, url.ID, variant)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("Variant not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to record conversion", err)
+	}
+	return recorded, nil
+}
+
+// ClaimRedirect takes one of a click-limited link's remaining redirects before
+// the visitor is sent on. Once they are used up the link deactivates and further
+// visits get an expired error. Failures refuse the visit rather than risk
+// serving a one-time link twice.
+func (s *urlService) ClaimRedirect(ctx context.Context, url *models.URL) error {
+	if !url.HasClickLimit() {
+		return nil
+	}
+
+	claimed, deactivated, err := s.urlRepo.ClaimRedirect(ctx, url.ID)
+	if err != nil {
+		return errors.NewDatabaseError("Failed to claim redirect", err)
+	}
+	if !claimed {
+		return errors.NewExpiredError("URL has reached its click limit", nil)
+	}
+	if deactivated {
+		// Click-limited links aren't cached, but clear any entry from before the limit was set
+		if err := s.cacheRepo.DeleteURL(ctx, url.ShortCode); err != nil {
+			log.Printf("Failed to delete URL from cache: %v", err)
+		}
+		s.events.Record(ctx, url, models.URLEventDeactivated, models.URLEventStatusChange{Reason: models.URLEventReasonClickLimit})
+	}
+	return nil
+}
+
+// visitorHash identifies a visitor for the current UTC day without storing their IP
+// or fingerprinting the browser. The salt is random per day and shared through Redis,
+// so hashes cannot be linked across days once it expires.
+func (s *urlService) visitorHash(ctx context.Context, clientIP, userAgent string) (string, error) {
+	saltKey := "visitor_salt:" + time.Now().UTC().Format("2006-01-02")
+
+	salt, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	if _, err := s.cacheRepo.SetIfNotExists(ctx, saltKey, salt, 48*time.Hour); err != nil {
+		return "", err
+	}
+	salt, err = s.cacheRepo.Get(ctx, saltKey)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(salt + "|" + clientIP + "|" + userAgent))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// recordBlockedClick stores a rejected redirect attempt for the owner's analytics
+func (s *urlService) recordBlockedClick(ctx context.Context, url *models.URL, reason, clientIP, userAgent, referer string) {
+	if s.aggregateOnly() {
+		clientIP, userAgent, referer = "", "", models.ReferrerDomain(referer)
+	}
+
+	blockedClick := &models.BlockedClick{
+		URLId:     url.ID,
+		Reason:    reason,
+		IPAddress: clientIP,
+		UserAgent: userAgent,
+		Referer:   referer,
+		BlockedAt: time.Now(),
+	}
+	if err := s.urlRepo.CreateBlockedClick(ctx, blockedClick); err != nil {
+		// Log error but still reject the attempt
+		log.Printf("Failed to record blocked click: %v", err)
+	}
+}
+
+// UnlockURL checks the password or share token of a protected link. Failed attempts are
+// counted per link and client IP; too many within the window lock the client out for a while.
+func (s *urlService) UnlockURL(ctx context.Context, shortCode string, req *models.UnlockURLRequest, clientIP, userAgent string) (*models.URL, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid unlock request", err)
+	}
+
+	url, err := s.GetURLForRedirect(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	// Open links have nothing to unlock, and must not hand out their destination
+	// past the checks of the redirect route
+	if !url.IsPasswordProtected() {
+		return nil, errors.NewBadRequestError("URL is not password protected", nil)
+	}
+
+	lockoutKey := fmt.Sprintf("link_password_lockout:%s:%s", shortCode, clientIP)
+	attemptsKey := fmt.Sprintf("link_password_attempts:%s:%s", shortCode, clientIP)
+
+	locked, err := s.cacheRepo.Exists(ctx, lockoutKey)
+	if err != nil {
+		// Fail open: Redis being down should not make protected links unreachable
+		log.Printf("Failed to check link password lockout: %v", err)
+	}
+	if locked {
+		s.recordBlockedClick(ctx, url, models.BlockReasonPasswordLocked, clientIP, userAgent, "")
+		return nil, errors.NewRateLimitError("Too many incorrect attempts. Please try again later", nil)
+	}
+
+	unlocked, reason := false, models.BlockReasonPassword
+	if req.ShareToken != "" {
+		unlocked, reason = s.OpenWithShareToken(ctx, url, req.ShareToken), models.BlockReasonShareToken
+	}
+	if !unlocked && req.Password != "" {
+		unlocked, reason = url.CheckPassword(req.Password), models.BlockReasonPassword
+	}
+	if unlocked {
+		if err := s.cacheRepo.Delete(ctx, attemptsKey); err != nil {
+			log.Printf("Failed to reset link password attempts: %v", err)
+		}
+		return url, nil
+	}
+
+	s.recordBlockedClick(ctx, url, reason, clientIP, userAgent, "")
+
+	attempts, err := s.cacheRepo.IncrementWithExpiry(ctx, attemptsKey, s.config.Security.LinkPasswordWindow)
+	if err != nil {
+		log.Printf("Failed to count link password attempts: %v", err)
+	} else if attempts >= int64(s.config.Security.LinkPasswordMaxAttempts) {
+		if err := s.cacheRepo.Set(ctx, lockoutKey, attempts, s.config.Security.LinkPasswordLockout); err != nil {
+			log.Printf("Failed to lock out client: %v", err)
+		}
+		if err := s.cacheRepo.Delete(ctx, attemptsKey); err != nil {
+			log.Printf("Failed to reset link password attempts: %v", err)
+		}
+	}
+
+	return nil, errors.NewUnauthorizedError("Incorrect password", nil)
+}
+
+// OpenWithShareToken reports whether token is a live share token of a protected
+// link, counting the visit against it
+func (s *urlService) OpenWithShareToken(ctx context.Context, url *models.URL, token string) bool {
+	if token == "" {
+		return false
+	}
+
+	ok, err := s.urlRepo.UseShareToken(ctx, url.ID, hashShareToken(token))
+	if err != nil {
+		// Fail closed: the visitor can still use the password
+		log.Printf("Failed to check share token: %v", err)
+		return false
+	}
+	return ok
+}
+
+// RotatePassword replaces the password of a protected link, generating one when none
+// is given, and optionally revokes its share tokens
+func (s *urlService) RotatePassword(ctx context.Context, shortCode string, req *models.RotateLinkPasswordRequest, userID int) (*models.RotateLinkPasswordResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid password", err)
+	}
+
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !url.IsPasswordProtected() {
+		return nil, errors.NewBadRequestError("URL is not password protected", nil)
+	}
+
+	password := req.Password
+	if password == "" {
+		if password, err = randomHex(8); err != nil {
+			return nil, errors.NewInternalError("Failed to generate link password", err)
+		}
+	}
+	if err := url.SetPassword(password); err != nil {
+		return nil, errors.NewInternalError("Failed to set link password", err)
+	}
+	url.UpdatedAt = time.Now()
+
+	updatedURL, err := s.urlRepo.Update(ctx, url)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to update URL", err)
+	}
+
+	// A link that just gained a password can no longer be served from the cache
+	if err := s.cacheRepo.DeleteURL(ctx, shortCode); err != nil {
+		log.Printf("Failed to delete URL from cache: %v", err)
+	}
+
+	revoked := 0
+	if req.RevokeShareTokens {
+		if revoked, err = s.urlRepo.RevokeShareTokens(ctx, url.ID); err != nil {
+			return nil, errors.NewDatabaseError("Failed to revoke share tokens", err)
+		}
+	}
+
+	s.webhooks.Dispatch(ctx, updatedURL, models.WebhookEventLinkUpdated, updatedURL)
+
+	return &models.RotateLinkPasswordResponse{
+		ShortCode:          shortCode,
+		Password:           password,
+		RevokedShareTokens: revoked,
+	}, nil
+}
+
+// CreateShareToken mints a named share token for a protected link and returns it once
+func (s *urlService) CreateShareToken(ctx context.Context, shortCode string, req *models.CreateShareTokenRequest, userID int) (*models.CreateShareTokenResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid share token request", err)
+	}
+
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !url.IsPasswordProtected() {
+		return nil, errors.NewBadRequestError("URL is not password protected", nil)
+	}
+
+	token, err := randomHex(24)
+	if err != nil {
+		return nil, errors.NewInternalError("Failed to generate share token", err)
+	}
+
+	shareToken, err := s.urlRepo.CreateShareToken(ctx, &models.ShareToken{
+		URLID:       url.ID,
+		Name:        req.Name,
+		TokenHash:   hashShareToken(token),
+		TokenPrefix: token[:8],
+		CreatedAt:   time.Now(),
+	})
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to create share token", err)
+	}
+
+	return &models.CreateShareTokenResponse{
+		ShareToken: *shareToken,
+		Token:      token,
+		ShareURL:   fmt.Sprintf("%s?share=%s", url.ShortURL(s.baseURL), token),
+	}, nil
+}
+
+// GetShareTokens lists the share tokens of a user's link with their access counts
+func (s *urlService) GetShareTokens(ctx context.Context, shortCode string, userID int) ([]models.ShareToken, error) {
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.urlRepo.GetShareTokens(ctx, url.ID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get share tokens", err)
+	}
+	return tokens, nil
+}
+
+// RevokeShareToken stops a share token from opening a user's link
+func (s *urlService) RevokeShareToken(ctx context.Context, shortCode string, id int, userID int) error {
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.urlRepo.RevokeShareToken(ctx, url.ID, id); err != nil {
+		if repository.IsNotFound(err) {
+			return errors.NewNotFoundError("Share token not found", err)
+		}
+		return errors.NewDatabaseError("Failed to revoke share token", err)
+	}
+	return nil
+}
+
+// hashShareToken returns the stored form of a share token
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GetURLStats retrieves URL statistics
+func (s *urlService) GetURLStats(ctx context.Context, shortCode string, userID int) (*models.URLStatsResponse, error) {
+	// Owners can see the stats of links that no longer redirect
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get analytics
+	analytics, err := s.GetAnalytics(ctx, shortCode, userID, 30, "") // Get 30 days analytics
+	if err != nil {
+		return nil, err
+	}
+
+	// Get recent clicks
+	recentClicks, err := s.urlRepo.GetClickEvents(ctx, url.ID, 10)
 	if err != nil {
 		return nil, errors.NewDatabaseError("Failed to get recent clicks", err)
 	}
 
+	// Get daily clicks over the same window, in the analytics time zone
+	series, err := s.GetClickTimeSeries(ctx, shortCode, userID, &models.ClickTimeSeriesOptions{Interval: models.TimeSeriesDay, Days: 30})
+	if err != nil {
+		return nil, err
+	}
+	clicksByDate := make(map[string]int, len(series.Points))
+	for _, point := range series.Points {
+		clicksByDate[point.Time.Format("2006-01-02")] = point.Clicks
+	}
+
 	response := &models.URLStatsResponse{
 		URL:          *url,
 		TotalClicks:  analytics.TotalClicks,
+		ClicksByDate: clicksByDate,
 		RecentClicks: recentClicks,
 		Analytics:    *analytics,
 	}
@@ -361,69 +1912,638 @@ func (s *urlService) GetURLStats(ctx context.Context, shortCode string, userID i
 	return response, nil
 }
 
-// GetAnalytics retrieves URL analytics
-func (s *urlService) GetAnalytics(ctx context.Context, shortCode string, userID int, days int) (*models.URLAnalytics, error) {
-	// Check ownership first
-	owned, err := s.urlRepo.CheckOwnership(ctx, shortCode, userID)
+// clickStreamSettle holds back the newest click events from the stream. IDs are
+// assigned before inserts commit, so a just-committed event can have a lower
+// ID than one already streamed; waiting for them to settle keeps the stream
+// append-only.
+const clickStreamSettle = 5 * time.Second
+
+// StreamClicks returns the click events of a URL recorded after the cursor, in
+// a stable order, so integrations can sync them incrementally. Events older
+// than the user's plan allows are left out.
+func (s *urlService) StreamClicks(ctx context.Context, shortCode string, userID int, opts *models.ClickStreamOptions) (*models.ClickStreamPage, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid click stream request", err)
+	}
+
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
 	if err != nil {
-		return nil, errors.NewDatabaseError("Failed to check URL ownership", err)
+		return nil, err
 	}
-	if !owned {
-		return nil, errors.NewForbiddenError("URL not found or access denied", nil)
+	if s.aggregateOnly() {
+		return nil, errors.NewBadRequestError("Click events are not stored in aggregate analytics mode", nil)
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get user", err)
 	}
 
-	// Get URL
-	url, err := s.GetURL(ctx, shortCode)
+	now := time.Now()
+	since := now.AddDate(0, 0, -s.analyticsMaxDays(user.Plan))
+	events, err := s.urlRepo.GetClickEventsAfter(ctx, url.ID, opts.AfterID, since, now.Add(-clickStreamSettle), opts.Limit+1)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get click events", err)
+	}
+
+	page := &models.ClickStreamPage{Events: events, NextCursor: models.EncodeClickCursor(opts.AfterID)}
+	if len(events) > opts.Limit {
+		page.Events = events[:opts.Limit]
+		page.HasMore = true
+	}
+	if len(page.Events) > 0 {
+		page.NextCursor = models.EncodeClickCursor(page.Events[len(page.Events)-1].ID)
+	}
+	return page, nil
+}
+
+// GetClicks returns a page of the click events of a URL, newest first. Events
+// older than the user's plan allows are left out.
+func (s *urlService) GetClicks(ctx context.Context, shortCode string, userID int, opts *models.ClickListOptions) (*models.ClickPage, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid click list request", err)
+	}
+
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+	if s.aggregateOnly() {
+		return nil, errors.NewBadRequestError("Click events are not stored in aggregate analytics mode", nil)
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get user", err)
+	}
+
+	since := time.Now().AddDate(0, 0, -s.analyticsMaxDays(user.Plan))
+	events, err := s.urlRepo.GetClickEventsBefore(ctx, url.ID, opts.BeforeID, since, opts.Limit+1)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get click events", err)
+	}
+
+	page := &models.ClickPage{Events: events, Limit: opts.Limit}
+	if len(events) > opts.Limit {
+		page.Events = events[:opts.Limit]
+		page.HasMore = true
+		page.NextCursor = models.EncodeClickCursor(page.Events[opts.Limit-1].ID)
+	}
+	return page, nil
+}
+
+// ExportURLs calls each with every one of the user's links in ID order,
+// reading them in batches so exports of large accounts stay in bounded memory.
+// It stops at the first error returned by each.
+func (s *urlService) ExportURLs(ctx context.Context, userID int, each func(url *models.URL) error) error {
+	afterID := 0
+	for {
+		urls, err := s.urlRepo.GetAllByUserAfter(ctx, userID, afterID, models.ExportBatchSize)
+		if err != nil {
+			return errors.NewDatabaseError("Failed to get URLs", err)
+		}
+		for i := range urls {
+			if err := each(&urls[i]); err != nil {
+				return err
+			}
+		}
+		if len(urls) < models.ExportBatchSize {
+			return nil
+		}
+		afterID = urls[len(urls)-1].ID
+	}
+}
+
+// ExportClicks calls each with every click event of a URL within the user's
+// plan's analytics window, oldest first, reading them in batches. It stops at
+// the first error returned by each.
+func (s *urlService) ExportClicks(ctx context.Context, shortCode string, userID int, each func(event *models.ClickEvent) error) error {
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return err
+	}
+	if s.aggregateOnly() {
+		return errors.NewBadRequestError("Click events are not stored in aggregate analytics mode", nil)
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return errors.NewDatabaseError("Failed to get user", err)
+	}
+
+	// Clicks recorded while the export runs are left for the next one
+	now := time.Now()
+	since := now.AddDate(0, 0, -s.analyticsMaxDays(user.Plan))
+	afterID := 0
+	for {
+		events, err := s.urlRepo.GetClickEventsAfter(ctx, url.ID, afterID, since, now, models.ExportBatchSize)
+		if err != nil {
+			return errors.NewDatabaseError("Failed to get click events", err)
+		}
+		for i := range events {
+			if err := each(&events[i]); err != nil {
+				return err
+			}
+		}
+		if len(events) < models.ExportBatchSize {
+			return nil
+		}
+		afterID = events[len(events)-1].ID
+	}
+}
+
+// GetClickTimeSeries returns a URL's clicks bucketed by hour or day, for charts.
+// Buckets use the given IANA time zone, falling back to the user's profile time
+// zone when it is empty. The window is capped at the user's plan's analytics window.
+func (s *urlService) GetClickTimeSeries(ctx context.Context, shortCode string, userID int, opts *models.ClickTimeSeriesOptions) (*models.ClickTimeSeries, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid time series request", err)
+	}
+
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get user", err)
+	}
+
+	loc, err := analyticsLocation(user, opts.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	days := opts.Days
+	maxDays := s.analyticsMaxDays(user.Plan)
+	if days > maxDays {
+		days = maxDays
+	}
+
+	now := time.Now()
+	since := models.TimeSeriesStart(now, days, loc)
+	var hourly []models.ClickCount
+	if s.aggregateOnly() {
+		hourly, err = s.urlRepo.GetAggregateHourlyClicks(ctx, url.ID, since)
+	} else {
+		hourly, err = s.urlRepo.GetHourlyClicks(ctx, url.ID, since)
+	}
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get clicks", err)
+	}
+
+	series := models.NewClickTimeSeries(opts.Interval, days, loc, now, hourly)
+	if opts.Days > days {
+		series.UpgradeRequired = &models.UpgradeHint{
+			Plan:          user.Plan,
+			RequestedDays: opts.Days,
+			MaxDays:       days,
+			Message:       fmt.Sprintf("On the %s plan history is limited to %d days. Upgrade your plan to see more.", user.Plan, days),
+		}
+	}
+
+	return series, nil
+}
+
+// GetAnalytics retrieves URL analytics. Day buckets use the given IANA time zone,
+// falling back to the user's profile time zone when it is empty.
+func (s *urlService) GetAnalytics(ctx context.Context, shortCode string, userID int, days int, timezone string) (*models.URLAnalytics, error) {
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Get analytics data
-	analytics, err := s.urlRepo.GetAnalyticsByUser(ctx, url.ID, userID, days)
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get user", err)
+	}
+
+	loc, err := analyticsLocation(user, timezone)
 	if err != nil {
-		return nil, errors.NewDatabaseError("Failed to get analytics", err)
+		return nil, err
+	}
+
+	// The caller's plan bounds how far back they can look
+	requestedDays := days
+	maxDays := s.analyticsMaxDays(user.Plan)
+	if days > maxDays {
+		days = maxDays
+	}
+
+	var analytics *models.URLAnalytics
+	if s.aggregateOnly() {
+		// Aggregate-only installs never stored raw clicks, so serve from the counters
+		analytics, err = s.urlRepo.GetAggregateAnalytics(ctx, url.ID, days, loc)
+		if err != nil {
+			return nil, errors.NewDatabaseError("Failed to get analytics", err)
+		}
+		analytics.UniqueClicks = s.countUniqueVisitors(ctx, shortCode)
+	} else {
+		analytics, err = s.urlRepo.GetAnalyticsByUser(ctx, url.ID, userID, days, loc)
+		if err != nil {
+			if repository.IsNotFound(err) {
+				return nil, errors.NewNotFoundError("URL not found", err)
+			}
+			return nil, errors.NewDatabaseError("Failed to get analytics", err)
+		}
+	}
+
+	if url.IsPasswordProtected() {
+		analytics.ShareTokens, err = s.urlRepo.GetShareTokens(ctx, url.ID)
+		if err != nil {
+			return nil, errors.NewDatabaseError("Failed to get share tokens", err)
+		}
+	}
+	if url.IsRotator() {
+		analytics.Destinations, err = s.urlRepo.GetDestinations(ctx, url.ID)
+		if err != nil {
+			return nil, errors.NewDatabaseError("Failed to get rotation destinations", err)
+		}
+	}
+	if url.IsSplitTest() {
+		variants, err := s.urlRepo.GetVariants(ctx, url.ID)
+		if err != nil {
+			return nil, errors.NewDatabaseError("Failed to get split test variants", err)
+		}
+		analytics.Variants = models.CompareVariants(variants)
 	}
 
+	s.applyAnalyticsPlan(analytics, user.Plan, requestedDays, days)
+
 	return analytics, nil
 }
 
-// generateUniqueShortCode generates a unique short code
-func (s *urlService) generateUniqueShortCode(ctx context.Context) (string, error) {
-	maxAttempts := 10
+// analyticsMaxDays returns the longest analytics window a plan may request
+func (s *urlService) analyticsMaxDays(plan string) int {
+	if days, ok := s.config.App.AnalyticsPlanMaxDays[plan]; ok {
+		return days
+	}
+	return s.config.App.AnalyticsMaxDays
+}
 
-	for i := 0; i < maxAttempts; i++ {
-		shortCode := s.generateShortCode()
+// applyAnalyticsPlan strips the breakdowns the plan doesn't include and tells
+// the caller what upgrading would unlock
+func (s *urlService) applyAnalyticsPlan(analytics *models.URLAnalytics, plan string, requestedDays, days int) {
+	hint := &models.UpgradeHint{Plan: plan}
+	var limits []string
 
-		// Check if code already exists
-		exists, err := s.urlRepo.ExistsByShortCode(ctx, shortCode)
+	if requestedDays > days {
+		hint.RequestedDays = requestedDays
+		hint.MaxDays = days
+		limits = append(limits, fmt.Sprintf("history is limited to %d days", days))
+	}
+
+	breakdowns := false
+	for _, breakdownPlan := range s.config.App.AnalyticsBreakdownPlans {
+		if breakdownPlan == plan {
+			breakdowns = true
+			break
+		}
+	}
+	if !breakdowns {
+		analytics.TopCountries = []models.CountryStats{}
+		hint.HiddenFields = []string{"top_countries"}
+		limits = append(limits, "geographic breakdowns are not included")
+	}
+
+	if len(limits) > 0 {
+		hint.Message = fmt.Sprintf("On the %s plan %s. Upgrade your plan to see more.", plan, strings.Join(limits, " and "))
+		analytics.UpgradeRequired = hint
+	}
+}
+
+// aggregateOnly returns true if the deployment must not store raw click events
+func (s *urlService) aggregateOnly() bool {
+	return s.config.App.AnalyticsMode == config.AnalyticsModeAggregate
+}
+
+// recordUniqueVisitor adds a visitor to the link's HyperLogLog sketch, which
+// estimates unique visitors without keeping their identities
+func (s *urlService) recordUniqueVisitor(ctx context.Context, shortCode, clientIP, userAgent string) {
+	sum := sha256.Sum256([]byte(clientIP + "|" + userAgent))
+	if err := s.cacheRepo.AddUnique(ctx, "link_visitors:"+shortCode, hex.EncodeToString(sum[:])); err != nil {
+		// Log error but don't fail the request
+		log.Printf("Failed to count unique visitor: %v", err)
+	}
+}
+
+// countUniqueVisitors estimates the link's unique visitors from its sketch
+func (s *urlService) countUniqueVisitors(ctx context.Context, shortCode string) int {
+	count, err := s.cacheRepo.CountUnique(ctx, "link_visitors:"+shortCode)
+	if err != nil {
+		log.Printf("Failed to count unique visitors: %v", err)
+		return 0
+	}
+	return int(count)
+}
+
+// analyticsLocation resolves the time zone used for analytics day buckets
+func analyticsLocation(user *models.User, timezone string) (*time.Location, error) {
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, errors.NewValidationError("Invalid timezone", err)
+		}
+		return loc, nil
+	}
+	return user.Location(), nil
+}
+
+// checkDestinations screens each destination of a rotator link, reporting
+// whether any of them holds the link for review
+func (s *urlService) checkDestinations(ctx context.Context, user *models.User, destinations []string) (bool, error) {
+	needsReview := false
+	for _, destination := range destinations {
+		destinationNeedsReview, err := s.checkDestination(ctx, user, destination)
+		if err != nil {
+			return false, err
+		}
+		needsReview = needsReview || destinationNeedsReview
+	}
+	return needsReview, nil
+}
+
+// checkDestination screens a link destination before it is saved. Blocked
+// domains are rejected; the result reports whether the link must be held for review.
+func (s *urlService) checkDestination(ctx context.Context, user *models.User, destination string) (bool, error) {
+	domain := destinationDomain(destination)
+	for _, blocked := range s.config.Abuse.BlockedDestinations {
+		blocked = strings.ToLower(blocked)
+		if domain == blocked || strings.HasSuffix(domain, "."+blocked) {
+			return false, errors.NewForbiddenError(fmt.Sprintf("Links to %s are not allowed", domain), nil)
+		}
+	}
+
+	if err := s.scanDestination(ctx, destination); err != nil {
+		return false, err
+	}
+
+	return s.checkDomainThrottle(ctx, user, destination)
+}
+
+// scanDestination rejects a destination the URL scanner flags as phishing or
+// malware. Scanner outages fail open; the periodic rescan catches up later.
+func (s *urlService) scanDestination(ctx context.Context, destination string) error {
+	if s.scanner == nil {
+		return nil
+	}
+
+	threats, err := s.scanner.Scan(ctx, []string{destination})
+	if err != nil {
+		log.Printf("Failed to scan destination: %v", err)
+		return nil
+	}
+	if threatType, ok := threats[destination]; ok {
+		return errors.NewForbiddenError(fmt.Sprintf("Links to %s are flagged as %s", destinationDomain(destination), strings.ToLower(threatType)), nil)
+	}
+	return nil
+}
+
+// checkDomainThrottle counts link creations per destination domain per hour.
+// Once the (per-plan) limit is exceeded the request is either rejected or,
+// when the action is "review", reported back so the link is held for review.
+// The count is taken when the destination is screened, so requests rejected
+// afterwards, by the throttle itself or a later check, count too: a client
+// retrying a throttled domain stays throttled until the hour is over.
+func (s *urlService) checkDomainThrottle(ctx context.Context, user *models.User, destination string) (bool, error) {
+	abuse := s.config.Abuse
+	if !abuse.DomainThrottleEnabled || models.IsSandbox(ctx) {
+		// Sandbox links are never created, so they count toward no limit
+		return false, nil
+	}
+
+	domain := destinationDomain(destination)
+	if domain == "" {
+		return false, nil
+	}
+
+	limit := abuse.DomainThrottleLimit
+	if planLimit, ok := abuse.DomainThrottlePlanLimits[user.Plan]; ok {
+		limit = planLimit
+	}
+	// Owners linking to their own verified domains get a higher limit
+	if s.isVerifiedDomain(ctx, user.ID, domain) {
+		limit = abuse.DomainThrottleVerifiedLimit
+	}
+	if limit <= 0 {
+		return false, nil
+	}
+
+	key := fmt.Sprintf("domain_creations:%s:%s", domain, time.Now().UTC().Format("2006010215"))
+	count, err := s.cacheRepo.IncrementWithExpiry(ctx, key, time.Hour)
+	if err != nil {
+		// Fail open so a Redis outage doesn't block link creation
+		log.Printf("Failed to increment domain creation counter: %v", err)
+		return false, nil
+	}
+
+	if count <= int64(limit) {
+		return false, nil
+	}
+
+	if abuse.DomainThrottleAction == "review" {
+		return true, nil
+	}
+
+	return false, errors.NewRateLimitError(fmt.Sprintf("Too many links created for %s in the last hour, please try again later", domain), nil)
+}
+
+// ShouldWarn reports whether visitors must see the suspicious-link warning before
+// being redirected. Links to a domain their owner verified skip it.
+func (s *urlService) ShouldWarn(ctx context.Context, url *models.URL) bool {
+	if !url.Suspicious {
+		return false
+	}
+	return !s.isVerifiedDomain(ctx, url.UserID, destinationDomain(url.OriginalURL))
+}
+
+// isVerifiedDomain reports whether the user proved ownership of a destination
+// domain. Lookup failures count as unverified.
+func (s *urlService) isVerifiedDomain(ctx context.Context, userID int, domain string) bool {
+	if domain == "" {
+		return false
+	}
+	verified, err := s.verifiedDomainRepo.IsVerifiedForUser(ctx, userID, domain)
+	if err != nil {
+		log.Printf("Failed to check verified domain %s: %v", domain, err)
+		return false
+	}
+	return verified
+}
+
+// destinationDomain returns the normalized host of a destination URL
+func destinationDomain(destination string) string {
+	parsedURL, err := neturl.Parse(destination)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.ToLower(parsedURL.Hostname()), "www.")
+}
+
+// urlCacheTTL returns how long a URL may stay cached without outliving its expiration
+func (s *urlService) urlCacheTTL(url *models.URL) time.Duration {
+	ttl := s.config.Redis.URLCacheTTL
+	if url.ExpiresAt != nil {
+		if untilExpiry := time.Until(*url.ExpiresAt); untilExpiry < ttl {
+			ttl = untilExpiry
+		}
+	}
+	// A zero TTL would cache the URL forever
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	return ttl
+}
+
+// maxShortCodeAttempts bounds the sequence numbers tried for one generated short code
+const maxShortCodeAttempts = 10
+
+// generateShortCode turns the next number of the short code sequence into a
+// code of the current length. Codes that are reserved or spell a profanity are
+// skipped. Single-region installs leave collisions with custom codes to the
+// unique constraint; with several regions, a code may be taken in another
+// region, so it is looked up first.
+func (s *urlService) generateShortCode(ctx context.Context) (string, error) {
+	s.keyspaceMu.RLock()
+	length := s.keyspace.Length
+	s.keyspaceMu.RUnlock()
+
+	for i := 0; i < maxShortCodeAttempts; i++ {
+		seq, err := s.urlRepo.NextShortCodeSequence(ctx)
 		if err != nil {
 			return "", err
 		}
 
-		if !exists {
-			return shortCode, nil
+		shortCode := models.ShortCodeFromSequence(seq, length, []byte(s.config.App.ShortCodeSecret))
+		if s.routes.IsReserved(shortCode) || models.ContainsBlockedWord(shortCode) {
+			continue
+		}
+		if s.regions.MultiRegion() {
+			exists, err := s.urlRepo.ExistsByShortCode(ctx, shortCode)
+			if err != nil {
+				return "", err
+			}
+			if exists {
+				continue
+			}
+		}
+		return shortCode, nil
+	}
+
+	return "", fmt.Errorf("failed to generate short code after %d attempts", maxShortCodeAttempts)
+}
+
+// keyspaceRefreshInterval is how often one instance recounts the short codes;
+// the others load its count from Redis
+const keyspaceRefreshInterval = time.Hour
+
+// keyspaceCacheKey holds the last short code count, shared by every instance
+const keyspaceCacheKey = "short_code_keyspace"
+
+// RefreshKeyspace picks the length of new generated codes. Counting the codes
+// scans every region's links, so it's done by one instance per
+// keyspaceRefreshInterval, under a lease, and shared through Redis; the
+// others load the shared count. Instances without one, such as the first
+// after a deploy that changes SHORT_CODE_LENGTH, count themselves.
+func (s *urlService) RefreshKeyspace(ctx context.Context) (*models.ShortCodeKeyspace, error) {
+	s.keyspaceMu.RLock()
+	keyspace := s.keyspace
+	s.keyspaceMu.RUnlock()
+
+	acquired, err := s.cacheRepo.AcquireLease(ctx, "short-code-keyspace", keyspaceRefreshInterval)
+	if err != nil {
+		log.Printf("Error acquiring the short code keyspace lease: %v", err)
+	}
+	if !acquired {
+		if shared, ok := s.loadKeyspace(ctx, keyspace); ok {
+			s.setKeyspace(shared)
+			return &shared, nil
+		}
+	}
+
+	keyspace, err = s.countKeyspace(ctx, keyspace)
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(keyspace); err == nil {
+		if err := s.cacheRepo.Set(ctx, keyspaceCacheKey, data, 0); err != nil {
+			log.Printf("Failed to share short code keyspace: %v", err)
 		}
 	}
+	s.setKeyspace(keyspace)
+
+	return &keyspace, nil
+}
 
-	return "", fmt.Errorf("failed to generate unique short code after %d attempts", maxAttempts)
+// countKeyspace counts the existing codes of each length from the configured
+// one up and picks the shortest length whose keyspace is below the utilization
+// threshold for generating new codes. Deleting links can make it shorter again.
+func (s *urlService) countKeyspace(ctx context.Context, keyspace models.ShortCodeKeyspace) (models.ShortCodeKeyspace, error) {
+	for length := keyspace.MinLength; ; length++ {
+		codes, err := s.countGeneratedShortCodes(ctx, length)
+		if err != nil {
+			return keyspace, errors.NewDatabaseError("Failed to count short codes", err)
+		}
+
+		keyspace.Length = length
+		keyspace.Codes = codes
+		keyspace.Keyspace = models.ShortCodeKeyspaceSize(length)
+		keyspace.Utilization = float64(codes) / keyspace.Keyspace
+		if keyspace.Utilization < keyspace.MaxUtilization || length == models.MaxShortCodeLength {
+			break
+		}
+	}
+	keyspace.CheckedAt = time.Now()
+	return keyspace, nil
 }
 
-// generateShortCode generates a random short code
-func (s *urlService) generateShortCode() string {
-	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	const length = 8
+// loadKeyspace returns the count shared by another instance, unless it was
+// made with a different configured length or utilization threshold
+func (s *urlService) loadKeyspace(ctx context.Context, current models.ShortCodeKeyspace) (models.ShortCodeKeyspace, bool) {
+	value, err := s.cacheRepo.Get(ctx, keyspaceCacheKey)
+	if err != nil {
+		if !repository.IsCacheMiss(err) {
+			log.Printf("Failed to load shared short code keyspace: %v", err)
+		}
+		return current, false
+	}
+
+	var shared models.ShortCodeKeyspace
+	if err := json.Unmarshal([]byte(value), &shared); err != nil {
+		return current, false
+	}
+	if shared.MinLength != current.MinLength || shared.MaxUtilization != current.MaxUtilization {
+		return current, false
+	}
+	return shared, true
+}
 
-	bytes := make([]byte, length)
-	for i := range bytes {
-		bytes[i] = charset[s.randomInt(len(charset))]
+// setKeyspace switches new generated codes to the keyspace's length
+func (s *urlService) setKeyspace(keyspace models.ShortCodeKeyspace) {
+	s.keyspaceMu.Lock()
+	defer s.keyspaceMu.Unlock()
+	if keyspace.Length != s.keyspace.Length {
+		log.Printf("Generated short codes now have %d characters (keyspace %.2f%% used)", keyspace.Length, keyspace.Utilization*100)
 	}
+	s.keyspace = keyspace
+}
 
-	return string(bytes)
+// GetKeyspace returns the short code keyspace as of the last refresh
+func (s *urlService) GetKeyspace() *models.ShortCodeKeyspace {
+	s.keyspaceMu.RLock()
+	defer s.keyspaceMu.RUnlock()
+	keyspace := s.keyspace
+	return &keyspace
 }
 
-// randomInt generates a random integer
-func (s *urlService) randomInt(max int) int {
-	bytes := make([]byte, 1)
-	rand.Read(bytes)
-	return int(bytes[0]) % max
+// countGeneratedShortCodes counts the codes of a length across every region,
+// since short codes are shared by every region
+func (s *urlService) countGeneratedShortCodes(ctx context.Context, length int) (int64, error) {
+	var total int64
+	for _, region := range s.regions.Regions() {
+		regionCtx := repository.WithRegion(ctx, region)
+		count, err := s.urlRepo.CountGeneratedShortCodes(regionCtx, length)
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
 }
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// apiCallCounterTTL keeps monthly API call counters until the month's report is generated
+const apiCallCounterTTL = 70 * 24 * time.Hour
+
+// UsageReportService interface defines the contract for organization usage reporting
+type UsageReportService interface {
+	RecordAPICall(ctx context.Context, userID int)
+	GenerateMonthlyReports(ctx context.Context, now time.Time) (int, error)
+	GetReports(ctx context.Context, orgID int, userID int) ([]models.UsageReport, error)
+}
+
+// usageReportService implements UsageReportService interface
+type usageReportService struct {
+	reportRepo   repository.UsageReportRepository
+	orgRepo      repository.OrganizationRepository
+	userRepo     repository.UserRepository
+	cacheRepo    repository.CacheRepository
+	emailService EmailService
+	emailReports bool
+}
+
+// NewUsageReportService creates a new usage report service. When emailReports is set,
+// each generated report is also emailed to the organization owner.
+func NewUsageReportService(
+	reportRepo repository.UsageReportRepository,
+	orgRepo repository.OrganizationRepository,
+	userRepo repository.UserRepository,
+	cacheRepo repository.CacheRepository,
+	emailService EmailService,
+	emailReports bool,
+) UsageReportService {
+	return &usageReportService{
+		reportRepo:   reportRepo,
+		orgRepo:      orgRepo,
+		userRepo:     userRepo,
+		cacheRepo:    cacheRepo,
+		emailService: emailService,
+		emailReports: emailReports,
+	}
+}
+
+// RecordAPICall counts an authenticated API call towards the user's monthly usage
+func (s *usageReportService) RecordAPICall(ctx context.Context, userID int) {
+	if _, err := s.cacheRepo.IncrementWithExpiry(ctx, apiCallCounterKey(userID, time.Now()), apiCallCounterTTL); err != nil {
+		// Log error but don't fail the request
+		log.Printf("Failed to count API call: %v", err)
+	}
+}
+
+// GenerateMonthlyReports creates the previous month's report for every organization
+// that does not have one yet, and returns how many were created
+func (s *usageReportService) GenerateMonthlyReports(ctx context.Context, now time.Time) (int, error) {
+	currentStart, _ := models.UsagePeriod(now)
+	from, to := models.UsagePeriod(currentStart.AddDate(0, -1, 0))
+
+	orgIDs, err := s.reportRepo.GetOrganizationsWithoutReport(ctx, from)
+	if err != nil {
+		return 0, errors.NewDatabaseError("Failed to find organizations to report on", err)
+	}
+
+	generated := 0
+	for _, orgID := range orgIDs {
+		report, err := s.generateReport(ctx, orgID, from, to)
+		if err != nil {
+			log.Printf("Failed to generate usage report for organization %d: %v", orgID, err)
+			continue
+		}
+		if report == nil {
+			continue
+		}
+		generated++
+
+		if s.emailReports {
+			s.emailReport(ctx, report)
+		}
+	}
+
+	return generated, nil
+}
+
+// GetReports returns an organization's usage reports to its owner
+func (s *usageReportService) GetReports(ctx context.Context, orgID int, userID int) ([]models.UsageReport, error) {
+	org, err := s.orgRepo.GetByID(ctx, orgID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("Organization not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get organization", err)
+	}
+	if !org.IsOwner(userID) {
+		return nil, errors.NewForbiddenError("Only the organization owner can view usage reports", nil)
+	}
+
+	reports, err := s.reportRepo.GetByOrganization(ctx, orgID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get usage reports", err)
+	}
+	return reports, nil
+}
+
+// generateReport computes and stores one organization's report. It returns nil if a
+// report for the period was created concurrently.
+func (s *usageReportService) generateReport(ctx context.Context, orgID int, from, to time.Time) (*models.UsageReport, error) {
+	members, err := s.orgRepo.GetMembers(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	memberIDs := make([]int, 0, len(members))
+	for _, member := range members {
+		memberIDs = append(memberIDs, member.UserID)
+	}
+
+	org, err := s.orgRepo.GetByID(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	// Link and click data live in the organization's region, everything else at home
+	usageCtx := ctx
+	if org.DataRegion != "" {
+		usageCtx = repository.WithRegion(ctx, org.DataRegion)
+	}
+
+	report, err := s.reportRepo.ComputeUsage(usageCtx, orgID, from, to, memberIDs)
+	if err != nil {
+		return nil, err
+	}
+	report.APICalls = s.countAPICalls(ctx, memberIDs, from)
+	report.GeneratedAt = time.Now()
+
+	report, err = s.reportRepo.Create(ctx, report)
+	if err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return report, nil
+}
+
+// countAPICalls sums the members' API call counters for the month starting at from
+func (s *usageReportService) countAPICalls(ctx context.Context, memberIDs []int, from time.Time) int64 {
+	var total int64
+	for _, userID := range memberIDs {
+		value, err := s.cacheRepo.Get(ctx, apiCallCounterKey(userID, from))
+		if err != nil {
+			// Missing counters mean no calls were made
+			if !repository.IsCacheMiss(err) {
+				log.Printf("Failed to get API call counter for user %d: %v", userID, err)
+			}
+			continue
+		}
+		var calls int64
+		if _, err := fmt.Sscan(value, &calls); err == nil {
+			total += calls
+		}
+	}
+	return total
+}
+
+// emailReport sends a report to the organization owner with the organization's branding
+func (s *usageReportService) emailReport(ctx context.Context, report *models.UsageReport) {
+	org, err := s.orgRepo.GetByID(ctx, report.OrganizationID)
+	if err != nil {
+		log.Printf("Failed to load organization %d for usage report: %v", report.OrganizationID, err)
+		return
+	}
+	owner, err := s.userRepo.GetByID(ctx, org.OwnerID)
+	if err != nil {
+		log.Printf("Failed to load owner of organization %d for usage report: %v", org.ID, err)
+		return
+	}
+	branding, err := s.orgRepo.GetEmailBranding(ctx, org.ID)
+	if err != nil {
+		branding = nil
+	}
+
+	if err := s.emailService.SendUsageReportEmail(owner.Email, org.Name, report, branding); err != nil {
+		log.Printf("Failed to email usage report %d: %v", report.ID, err)
+		return
+	}
+	if err := s.reportRepo.MarkEmailed(ctx, report.ID); err != nil {
+		log.Printf("Failed to mark usage report %d emailed: %v", report.ID, err)
+	}
+}
+
+// apiCallCounterKey returns the Redis key counting a user's API calls in t's month
+func apiCallCounterKey(userID int, t time.Time) string {
+	return fmt.Sprintf("api_calls:%d:%s", userID, t.UTC().Format("2006-01"))
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// UserEmailService interface defines the contract for secondary email operations
+type UserEmailService interface {
+	GetEmails(ctx context.Context, userID int) (*models.UserEmailsResponse, error)
+	AddEmail(ctx context.Context, userID int, req *models.AddUserEmailRequest) (*models.UserEmail, *models.OTPResponse, error)
+	ResendVerification(ctx context.Context, userID, id int) (*models.UserEmail, *models.OTPResponse, error)
+	VerifyEmail(ctx context.Context, userID, id int, req *models.VerifyUserEmailRequest) (*models.UserEmail, error)
+	MakePrimary(ctx context.Context, userID, id int) (*models.UserEmailsResponse, error)
+	DeleteEmail(ctx context.Context, userID, id int) error
+	DeleteUnverifiedEmails(ctx context.Context) (int64, error)
+}
+
+// userEmailService implements UserEmailService interface
+type userEmailService struct {
+	userEmailRepo repository.UserEmailRepository
+	userRepo      repository.UserRepository
+	otpService    OTPService
+}
+
+// NewUserEmailService creates a new secondary email service
+func NewUserEmailService(userEmailRepo repository.UserEmailRepository, userRepo repository.UserRepository, otpService OTPService) UserEmailService {
+	return &userEmailService{
+		userEmailRepo: userEmailRepo,
+		userRepo:      userRepo,
+		otpService:    otpService,
+	}
+}
+
+// GetEmails lists the user's primary and secondary emails
+func (s *userEmailService) GetEmails(ctx context.Context, userID int) (*models.UserEmailsResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.NewNotFoundError("User not found", err)
+	}
+
+	emails, err := s.userEmailRepo.GetAllByUser(ctx, userID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get emails", err)
+	}
+
+	return &models.UserEmailsResponse{Primary: user.Email, Emails: emails}, nil
+}
+
+// AddEmail adds an unverified secondary email and issues the code verifying it.
+// The caller sends the code to the new address.
+func (s *userEmailService) AddEmail(ctx context.Context, userID int, req *models.AddUserEmailRequest) (*models.UserEmail, *models.OTPResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, nil, errors.NewValidationError("Invalid email", err)
+	}
+
+	count, err := s.userEmailRepo.CountByUser(ctx, userID)
+	if err != nil {
+		return nil, nil, errors.NewDatabaseError("Failed to count emails", err)
+	}
+	if count >= models.MaxUserEmails {
+		return nil, nil, errors.NewValidationError(fmt.Sprintf("You can add at most %d emails", models.MaxUserEmails), nil)
+	}
+
+	taken, err := s.userRepo.ExistsByEmail(ctx, req.Email)
+	if err != nil {
+		return nil, nil, errors.NewDatabaseError("Failed to check email", err)
+	}
+	if taken {
+		return nil, nil, errors.NewAlreadyExistsError("Email already in use", nil)
+	}
+
+	email, err := s.userEmailRepo.Create(ctx, &models.UserEmail{
+		UserID:    userID,
+		Email:     req.Email,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		if repository.IsDuplicate(err) {
+			return nil, nil, errors.NewAlreadyExistsError("Email already added", err)
+		}
+		return nil, nil, errors.NewDatabaseError("Failed to add email", err)
+	}
+
+	otp, err := s.otpService.GenerateOTP(ctx, userID, email.Email, models.OTPPurposeEmailAlias)
+	if err != nil {
+		return nil, nil, err
+	}
+	return email, otp, nil
+}
+
+// ResendVerification issues a new code for an unverified secondary email.
+// Codes replace each other, so only the latest one sent works.
+func (s *userEmailService) ResendVerification(ctx context.Context, userID, id int) (*models.UserEmail, *models.OTPResponse, error) {
+	email, err := s.getEmail(ctx, userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if email.IsVerified() {
+		return nil, nil, errors.NewBadRequestError("Email is already verified", nil)
+	}
+
+	otp, err := s.otpService.GenerateOTP(ctx, userID, email.Email, models.OTPPurposeEmailAlias)
+	if err != nil {
+		return nil, nil, err
+	}
+	return email, otp, nil
+}
+
+// VerifyEmail verifies a secondary email with the code sent to it
+func (s *userEmailService) VerifyEmail(ctx context.Context, userID, id int, req *models.VerifyUserEmailRequest) (*models.UserEmail, error) {
+	email, err := s.getEmail(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if email.IsVerified() {
+		return email, nil
+	}
+
+	result, err := s.otpService.VerifyOTP(ctx, &models.OTPVerifyRequest{
+		Email:   email.Email,
+		OTPCode: req.OTPCode,
+		Purpose: models.OTPPurposeEmailAlias,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !result.IsVerified {
+		return nil, errors.NewValidationError(result.Message, nil)
+	}
+
+	// Unverified addresses don't block others, so someone may have registered
+	// or verified this one since it was added
+	taken, err := s.userRepo.ExistsByEmail(ctx, email.Email)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to check email", err)
+	}
+	if taken {
+		return nil, errors.NewAlreadyExistsError("Email already in use", nil)
+	}
+
+	now := time.Now()
+	if err := s.userEmailRepo.MarkVerified(ctx, email.ID, now); err != nil {
+		if repository.IsDuplicate(err) {
+			return nil, errors.NewAlreadyExistsError("Email already in use", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to verify email", err)
+	}
+	email.VerifiedAt = &now
+
+	return email, nil
+}
+
+// MakePrimary makes a verified secondary email the user's primary email, where
+// notifications are sent. The previous primary email becomes a secondary one.
+func (s *userEmailService) MakePrimary(ctx context.Context, userID, id int) (*models.UserEmailsResponse, error) {
+	email, err := s.getEmail(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if !email.IsVerified() {
+		return nil, errors.NewBadRequestError("Verify the email before making it primary", nil)
+	}
+
+	if err := s.userEmailRepo.MakePrimary(ctx, id, userID); err != nil {
+		return nil, errors.NewDatabaseError("Failed to change primary email", err)
+	}
+
+	return s.GetEmails(ctx, userID)
+}
+
+// DeleteEmail removes a secondary email from the user's account
+func (s *userEmailService) DeleteEmail(ctx context.Context, userID, id int) error {
+	if err := s.userEmailRepo.Delete(ctx, id, userID); err != nil {
+		if repository.IsNotFound(err) {
+			return errors.NewNotFoundError("Email not found", err)
+		}
+		return errors.NewDatabaseError("Failed to delete email", err)
+	}
+	return nil
+}
+
+// DeleteUnverifiedEmails removes the secondary emails that weren't verified in time
+func (s *userEmailService) DeleteUnverifiedEmails(ctx context.Context) (int64, error) {
+	deleted, err := s.userEmailRepo.DeleteUnverifiedBefore(ctx, time.Now().Add(-models.UnverifiedUserEmailTTL))
+	if err != nil {
+		return 0, errors.NewDatabaseError("Failed to delete unverified emails", err)
+	}
+	return deleted, nil
+}
+
+// getEmail returns one of the user's secondary emails
+func (s *userEmailService) getEmail(ctx context.Context, userID, id int) (*models.UserEmail, error) {
+	email, err := s.userEmailRepo.GetByID(ctx, id, userID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("Email not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get email", err)
+	}
+	return email, nil
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// variantClickFlushInterval is how often counted split test variant clicks are
+// written to the database
+const variantClickFlushInterval = 10 * time.Second
+
+// VariantClickCounter counts the clicks of split test variants in memory and
+// writes them to the database in batches, so visits to a split test link don't
+// each update its variant's row. Counts not yet written are lost if the
+// instance dies without being stopped.
+type VariantClickCounter struct {
+	urlRepo repository.URLRepository
+
+	mu      sync.Mutex
+	pending map[string]map[int]int64 // Click counts by region and variant ID
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewVariantClickCounter creates a variant click counter
+func NewVariantClickCounter(urlRepo repository.URLRepository) *VariantClickCounter {
+	return &VariantClickCounter{
+		urlRepo: urlRepo,
+		pending: make(map[string]map[int]int64),
+	}
+}
+
+// Add counts a click of a variant in the data region of ctx
+func (c *VariantClickCounter) Add(ctx context.Context, variantID int) {
+	region := repository.RegionFromContext(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending[region] == nil {
+		c.pending[region] = make(map[int]int64)
+	}
+	c.pending[region][variantID]++
+}
+
+// Start writes the counted clicks every flush interval until stopped
+func (c *VariantClickCounter) Start(ctx context.Context) {
+	flushCtx, cancel := context.WithCancel(ctx)
+	c.cancel = cancel
+	c.done = make(chan struct{})
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(variantClickFlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-flushCtx.Done():
+				return
+			case <-ticker.C:
+				c.flush(flushCtx)
+			}
+		}
+	}()
+}
+
+// Stop stops the flush loop and writes the clicks still counted, so call it
+// once requests have drained
+func (c *VariantClickCounter) Stop(ctx context.Context) {
+	if c.cancel != nil {
+		c.cancel()
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+		}
+	}
+	c.flush(ctx)
+}
+
+// flush writes the counted clicks of every region. Clicks that fail to be
+// written are counted again for the next flush.
+func (c *VariantClickCounter) flush(ctx context.Context) {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = make(map[string]map[int]int64)
+	c.mu.Unlock()
+
+	now := time.Now()
+	for region, clicks := range pending {
+		if err := c.urlRepo.RecordVariantClicks(repository.WithRegion(ctx, region), clicks, now); err != nil {
+			log.Printf("Failed to record variant clicks: %v", err)
+			c.restore(region, clicks)
+		}
+	}
+}
+
+// restore counts clicks again that failed to be written
+func (c *VariantClickCounter) restore(region string, clicks map[int]int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending[region] == nil {
+		c.pending[region] = make(map[int]int64)
+	}
+	for id, n := range clicks {
+		c.pending[region][id] += n
+	}
+}
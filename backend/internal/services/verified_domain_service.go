@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// VerifiedDomainService interface defines the contract for destination domain ownership claims
+type VerifiedDomainService interface {
+	AddDomain(ctx context.Context, userID int, req *models.CreateVerifiedDomainRequest) (*models.VerifiedDomain, error)
+	GetDomains(ctx context.Context, userID int) ([]models.VerifiedDomain, error)
+	VerifyDomain(ctx context.Context, id int, userID int) (*models.VerifiedDomain, error)
+	DeleteDomain(ctx context.Context, id int, userID int) error
+}
+
+// verifiedDomainService implements VerifiedDomainService interface
+type verifiedDomainService struct {
+	verifiedDomainRepo repository.VerifiedDomainRepository
+	resolver           *net.Resolver
+}
+
+// NewVerifiedDomainService creates a new destination domain ownership service
+func NewVerifiedDomainService(verifiedDomainRepo repository.VerifiedDomainRepository) VerifiedDomainService {
+	return &verifiedDomainService{
+		verifiedDomainRepo: verifiedDomainRepo,
+		resolver:           net.DefaultResolver,
+	}
+}
+
+// AddDomain claims a destination domain pending DNS verification
+func (s *verifiedDomainService) AddDomain(ctx context.Context, userID int, req *models.CreateVerifiedDomainRequest) (*models.VerifiedDomain, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid domain request", err)
+	}
+
+	domains, err := s.verifiedDomainRepo.GetAllByUser(ctx, userID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to check domain", err)
+	}
+	for _, domain := range domains {
+		if domain.Domain == req.Domain {
+			return nil, errors.NewAlreadyExistsError("Domain is already claimed", nil)
+		}
+	}
+
+	token, err := randomHex(16)
+	if err != nil {
+		return nil, errors.NewInternalError("Failed to generate verification token", err)
+	}
+
+	domain := &models.VerifiedDomain{
+		UserID:            userID,
+		Domain:            req.Domain,
+		VerificationToken: token,
+		CreatedAt:         time.Now(),
+	}
+
+	createdDomain, err := s.verifiedDomainRepo.Create(ctx, domain)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to create domain", err)
+	}
+
+	return createdDomain, nil
+}
+
+// GetDomains lists the destination domains the user claimed
+func (s *verifiedDomainService) GetDomains(ctx context.Context, userID int) ([]models.VerifiedDomain, error) {
+	domains, err := s.verifiedDomainRepo.GetAllByUser(ctx, userID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get domains", err)
+	}
+	return domains, nil
+}
+
+// VerifyDomain checks the domain's TXT record for its verification token
+func (s *verifiedDomainService) VerifyDomain(ctx context.Context, id int, userID int) (*models.VerifiedDomain, error) {
+	domain, err := s.verifiedDomainRepo.GetByID(ctx, id, userID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("Domain not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get domain", err)
+	}
+
+	if domain.IsVerified() {
+		return domain, nil
+	}
+
+	records, err := s.resolver.LookupTXT(ctx, domain.VerificationRecord())
+	if err != nil {
+		return nil, errors.NewValidationError(fmt.Sprintf("No TXT record found at %s", domain.VerificationRecord()), err)
+	}
+
+	verified := false
+	for _, record := range records {
+		if strings.TrimSpace(record) == domain.VerificationToken {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.NewValidationError(fmt.Sprintf("TXT record at %s does not match the verification token", domain.VerificationRecord()), nil)
+	}
+
+	now := time.Now()
+	domain.VerifiedAt = &now
+
+	if err := s.verifiedDomainRepo.MarkVerified(ctx, domain); err != nil {
+		return nil, errors.NewDatabaseError("Failed to verify domain", err)
+	}
+
+	return domain, nil
+}
+
+// DeleteDomain drops one of the user's destination domain claims
+func (s *verifiedDomainService) DeleteDomain(ctx context.Context, id int, userID int) error {
+	if err := s.verifiedDomainRepo.Delete(ctx, id, userID); err != nil {
+		if repository.IsNotFound(err) {
+			return errors.NewNotFoundError("Domain not found", err)
+		}
+		return errors.NewDatabaseError("Failed to delete domain", err)
+	}
+	return nil
+}
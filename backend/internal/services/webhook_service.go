@@ -0,0 +1,576 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/hpower2/url-shortener/internal/errors"
+	"github.com/hpower2/url-shortener/internal/fetcher"
+	"github.com/hpower2/url-shortener/internal/models"
+	"github.com/hpower2/url-shortener/internal/repository"
+)
+
+// Headers sent with every webhook delivery
+const (
+	HeaderWebhookEvent     = "X-Webhook-Event"
+	HeaderWebhookSignature = "X-Webhook-Signature"
+	HeaderWebhookDelivery  = "X-Webhook-Delivery"
+)
+
+// webhookTimeout bounds a single webhook delivery
+const webhookTimeout = 10 * time.Second
+
+// Mirrored clicks are sent by mirrorWorkers workers from a queue of
+// mirrorQueueSize clicks; clicks mirrored while it's full are dropped
+const (
+	mirrorWorkers   = 8
+	mirrorQueueSize = 1000
+)
+
+// clickTriggerCacheTTL is how long a link's click triggers are cached for its
+// clicks. Changing the triggers invalidates the cache.
+const clickTriggerCacheTTL = 10 * time.Minute
+
+// WebhookService interface defines the contract for per-link webhook operations
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, shortCode string, userID int, req *models.CreateWebhookRequest) (*models.CreateWebhookResponse, error)
+	GetWebhooks(ctx context.Context, shortCode string, userID int) ([]models.Webhook, error)
+	UpdateWebhook(ctx context.Context, shortCode string, id int, userID int, req *models.UpdateWebhookRequest) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, shortCode string, id int, userID int) error
+	GetDeliveries(ctx context.Context, shortCode string, id int, userID int, limit, offset int) ([]models.WebhookDelivery, int, error)
+	TestWebhook(ctx context.Context, shortCode string, id int, userID int, req *models.TestWebhookRequest) (*models.WebhookDelivery, error)
+	Dispatch(ctx context.Context, url *models.URL, event string, data interface{})
+	Mirror(ctx context.Context, url *models.URL, click *models.ShadowClick)
+	CreateClickTrigger(ctx context.Context, shortCode string, userID int, req *models.CreateClickTriggerRequest) (*models.ClickTrigger, error)
+	GetClickTriggers(ctx context.Context, shortCode string, userID int) ([]models.ClickTrigger, error)
+	DeleteClickTrigger(ctx context.Context, shortCode string, id int, userID int) error
+	EvaluateClickTriggers(ctx context.Context, url *models.URL, clicks int64)
+}
+
+// webhookService implements WebhookService interface
+type webhookService struct {
+	webhookRepo repository.WebhookRepository
+	urlRepo     repository.URLRepository
+	cacheRepo   repository.CacheRepository
+	fetcher     *fetcher.Fetcher
+	mirrors     chan shadowMirror
+}
+
+// shadowMirror is a click queued to be mirrored to a link's shadow endpoint
+type shadowMirror struct {
+	shortCode string
+	target    string
+	payload   []byte
+}
+
+// NewWebhookService creates a new webhook service and starts its click mirroring workers
+func NewWebhookService(webhookRepo repository.WebhookRepository, urlRepo repository.URLRepository, cacheRepo repository.CacheRepository, fetcher *fetcher.Fetcher) WebhookService {
+	s := &webhookService{
+		webhookRepo: webhookRepo,
+		urlRepo:     urlRepo,
+		cacheRepo:   cacheRepo,
+		fetcher:     fetcher,
+		mirrors:     make(chan shadowMirror, mirrorQueueSize),
+	}
+	for i := 0; i < mirrorWorkers; i++ {
+		go s.mirrorWorker()
+	}
+	return s
+}
+
+// CreateWebhook subscribes a webhook to one of the user's links and returns its secret once
+func (s *webhookService) CreateWebhook(ctx context.Context, shortCode string, userID int, req *models.CreateWebhookRequest) (*models.CreateWebhookResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid webhook request", err)
+	}
+
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, errors.NewInternalError("Failed to generate webhook secret", err)
+	}
+
+	webhook := &models.Webhook{
+		UserID:    userID,
+		URLID:     url.ID,
+		TargetURL: req.TargetURL,
+		Secret:    "whsec_" + secret,
+		Events:    req.Events,
+		IsActive:  true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	createdWebhook, err := s.webhookRepo.Create(ctx, webhook)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to create webhook", err)
+	}
+
+	return &models.CreateWebhookResponse{
+		Webhook: *createdWebhook,
+		Secret:  createdWebhook.Secret,
+	}, nil
+}
+
+// GetWebhooks lists the webhooks subscribed to a link
+func (s *webhookService) GetWebhooks(ctx context.Context, shortCode string, userID int) ([]models.Webhook, error) {
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks, err := s.webhookRepo.GetAllByURL(ctx, url.ID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get webhooks", err)
+	}
+	return webhooks, nil
+}
+
+// UpdateWebhook updates a link's webhook
+func (s *webhookService) UpdateWebhook(ctx context.Context, shortCode string, id int, userID int, req *models.UpdateWebhookRequest) (*models.Webhook, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid webhook request", err)
+	}
+
+	webhook, err := s.getWebhook(ctx, shortCode, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.TargetURL != "" {
+		webhook.TargetURL = req.TargetURL
+	}
+	if req.Events != nil {
+		webhook.Events = req.Events
+	}
+	if req.IsActive != nil {
+		webhook.IsActive = *req.IsActive
+	}
+
+	updatedWebhook, err := s.webhookRepo.Update(ctx, webhook)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("Webhook not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to update webhook", err)
+	}
+
+	return updatedWebhook, nil
+}
+
+// DeleteWebhook removes a link's webhook
+func (s *webhookService) DeleteWebhook(ctx context.Context, shortCode string, id int, userID int) error {
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.webhookRepo.Delete(ctx, id, url.ID); err != nil {
+		if repository.IsNotFound(err) {
+			return errors.NewNotFoundError("Webhook not found", err)
+		}
+		return errors.NewDatabaseError("Failed to delete webhook", err)
+	}
+	return nil
+}
+
+// GetDeliveries returns a webhook's delivery history
+func (s *webhookService) GetDeliveries(ctx context.Context, shortCode string, id int, userID int, limit, offset int) ([]models.WebhookDelivery, int, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	webhook, err := s.getWebhook(ctx, shortCode, id, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	deliveries, total, err := s.webhookRepo.GetDeliveries(ctx, webhook.ID, limit, offset)
+	if err != nil {
+		return nil, 0, errors.NewDatabaseError("Failed to get webhook deliveries", err)
+	}
+	return deliveries, total, nil
+}
+
+// TestWebhook sends a signed sample payload to a webhook and returns the
+// recorded delivery, so integrators can check their endpoint before real
+// events flow. Inactive webhooks and events they don't subscribe to can be tested.
+func (s *webhookService) TestWebhook(ctx context.Context, shortCode string, id int, userID int, req *models.TestWebhookRequest) (*models.WebhookDelivery, error) {
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+	webhook, err := s.webhookRepo.GetByID(ctx, id, url.ID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("Webhook not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get webhook", err)
+	}
+
+	event := req.Event
+	if event == "" && len(webhook.Events) > 0 {
+		event = webhook.Events[0]
+	}
+	now := time.Now().UTC()
+	data, err := models.SampleWebhookData(event, url, now)
+	if err != nil {
+		return nil, errors.NewValidationError("Invalid test event", err)
+	}
+
+	payload, err := json.Marshal(models.WebhookPayload{
+		Event:     event,
+		ShortCode: url.ShortCode,
+		Timestamp: now,
+		Test:      true,
+		Data:      data,
+	})
+	if err != nil {
+		return nil, errors.NewInternalError("Failed to encode webhook payload", err)
+	}
+
+	return s.deliver(ctx, *webhook, event, payload), nil
+}
+
+// Dispatch delivers an event to the link's subscribed webhooks in the background
+func (s *webhookService) Dispatch(ctx context.Context, url *models.URL, event string, data interface{}) {
+	webhooks, err := s.webhookRepo.GetActiveByURLAndEvent(ctx, url.ID, event)
+	if err != nil {
+		log.Printf("Failed to load webhooks for %s: %v", url.ShortCode, err)
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(models.WebhookPayload{
+		Event:     event,
+		ShortCode: url.ShortCode,
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		log.Printf("Failed to encode webhook payload for %s: %v", url.ShortCode, err)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		go s.deliver(context.WithoutCancel(ctx), webhook, event, payload)
+	}
+}
+
+// Mirror queues a copy of a click to be sent to the link's shadow endpoint in
+// the background. Mirroring is best effort: failures are logged and never
+// retried, and clicks are dropped while the queue is full, so a slow shadow
+// endpoint can't pile up goroutines.
+func (s *webhookService) Mirror(ctx context.Context, url *models.URL, click *models.ShadowClick) {
+	payload, err := json.Marshal(click)
+	if err != nil {
+		log.Printf("Failed to encode shadow click for %s: %v", url.ShortCode, err)
+		return
+	}
+
+	select {
+	case s.mirrors <- shadowMirror{shortCode: url.ShortCode, target: url.ShadowURL, payload: payload}:
+	default:
+		// Queue full: drop the click rather than stall or pile up
+	}
+}
+
+// mirrorWorker sends queued clicks to their shadow endpoints, one at a time
+func (s *webhookService) mirrorWorker() {
+	for mirror := range s.mirrors {
+		s.sendMirror(mirror)
+	}
+}
+
+// sendMirror POSTs a mirrored click to its shadow endpoint
+func (s *webhookService) sendMirror(mirror shadowMirror) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, mirror.target, bytes.NewReader(mirror.payload))
+	if err != nil {
+		log.Printf("Failed to mirror click for %s: %v", mirror.shortCode, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookEvent, models.ShadowEventClick)
+
+	resp, err := s.fetcher.Do(req)
+	if err != nil {
+		log.Printf("Failed to mirror click for %s: %v", mirror.shortCode, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Printf("Shadow endpoint for %s responded with status %d", mirror.shortCode, resp.StatusCode)
+	}
+}
+
+// deliver POSTs a payload to a webhook and records the attempt in the
+// data region of parent, returning the recorded attempt
+func (s *webhookService) deliver(parent context.Context, webhook models.Webhook, event string, payload []byte) *models.WebhookDelivery {
+	ctx, cancel := context.WithTimeout(parent, 2*webhookTimeout)
+	defer cancel()
+
+	delivery := &models.WebhookDelivery{
+		WebhookID: webhook.ID,
+		Event:     event,
+		Payload:   string(payload),
+		CreatedAt: time.Now(),
+	}
+
+	start := time.Now()
+	statusCode, err := s.post(ctx, webhook, event, payload)
+	delivery.DurationMs = int(time.Since(start).Milliseconds())
+
+	if statusCode != 0 {
+		delivery.StatusCode = &statusCode
+	}
+	if err != nil {
+		message := err.Error()
+		delivery.Error = &message
+	} else {
+		delivery.Success = true
+	}
+
+	if err := s.webhookRepo.CreateDelivery(ctx, delivery); err != nil {
+		log.Printf("Failed to record webhook delivery %d: %v", webhook.ID, err)
+	}
+	return delivery
+}
+
+// post sends a signed webhook request and returns the response status
+func (s *webhookService) post(ctx context.Context, webhook models.Webhook, event string, payload []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.TargetURL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	deliveryID, err := randomHex(12)
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate delivery ID: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookEvent, event)
+	req.Header.Set(HeaderWebhookDelivery, deliveryID)
+	req.Header.Set(HeaderWebhookSignature, "sha256="+signWebhookPayload(webhook.Secret, payload))
+
+	resp, err := s.fetcher.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// CreateClickTrigger adds a click threshold trigger to one of the user's links
+func (s *webhookService) CreateClickTrigger(ctx context.Context, shortCode string, userID int, req *models.CreateClickTriggerRequest) (*models.ClickTrigger, error) {
+	if err := req.Validate(); err != nil {
+		return nil, errors.NewValidationError("Invalid click trigger request", err)
+	}
+
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.webhookRepo.GetTriggersByURL(ctx, url.ID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get click triggers", err)
+	}
+	if len(existing) >= models.MaxClickTriggersPerURL {
+		return nil, errors.NewValidationError(fmt.Sprintf("A link can have at most %d click triggers", models.MaxClickTriggersPerURL), nil)
+	}
+
+	trigger := &models.ClickTrigger{
+		UserID:    userID,
+		URLID:     url.ID,
+		Kind:      req.Kind,
+		Threshold: req.Threshold,
+		CreatedAt: time.Now(),
+	}
+
+	createdTrigger, err := s.webhookRepo.CreateTrigger(ctx, trigger)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to create click trigger", err)
+	}
+	s.invalidateClickTriggers(ctx, url.ID)
+	return createdTrigger, nil
+}
+
+// GetClickTriggers lists a link's click triggers
+func (s *webhookService) GetClickTriggers(ctx context.Context, shortCode string, userID int) ([]models.ClickTrigger, error) {
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	triggers, err := s.webhookRepo.GetTriggersByURL(ctx, url.ID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to get click triggers", err)
+	}
+	return triggers, nil
+}
+
+// DeleteClickTrigger removes a link's click trigger
+func (s *webhookService) DeleteClickTrigger(ctx context.Context, shortCode string, id int, userID int) error {
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.webhookRepo.DeleteTrigger(ctx, id, url.ID); err != nil {
+		if repository.IsNotFound(err) {
+			return errors.NewNotFoundError("Click trigger not found", err)
+		}
+		return errors.NewDatabaseError("Failed to delete click trigger", err)
+	}
+	s.invalidateClickTriggers(ctx, url.ID)
+	return nil
+}
+
+// EvaluateClickTriggers fires the link's triggers matched by its new click count as
+// link.click_threshold webhook events. The count comes from an atomic Redis counter,
+// so each "every" multiple is seen by exactly one click. Triggers are read from the
+// cache, so most clicks don't query the database.
+func (s *webhookService) EvaluateClickTriggers(ctx context.Context, url *models.URL, clicks int64) {
+	triggers, err := s.getCachedClickTriggers(ctx, url.ID)
+	if err != nil {
+		log.Printf("Failed to load click triggers for %s: %v", url.ShortCode, err)
+		return
+	}
+
+	for i := range triggers {
+		trigger := &triggers[i]
+		if !trigger.Matches(clicks) {
+			continue
+		}
+
+		fired, err := s.webhookRepo.MarkTriggerFired(ctx, trigger, time.Now())
+		if err != nil {
+			log.Printf("Failed to fire click trigger %d: %v", trigger.ID, err)
+			continue
+		}
+		if !fired {
+			continue
+		}
+		if trigger.Kind == models.ClickTriggerReach {
+			// The cached copy would still match every later click
+			s.invalidateClickTriggers(ctx, url.ID)
+		}
+
+		s.Dispatch(ctx, url, models.WebhookEventLinkClickThreshold, models.ClickThresholdEvent{
+			TriggerID: trigger.ID,
+			Kind:      trigger.Kind,
+			Threshold: trigger.Threshold,
+			Clicks:    clicks,
+		})
+	}
+}
+
+// getCachedClickTriggers returns a link's click triggers, caching them,
+// including the lack of any. The database is used while Redis is unavailable.
+func (s *webhookService) getCachedClickTriggers(ctx context.Context, urlID int) ([]models.ClickTrigger, error) {
+	key := clickTriggersKey(urlID)
+	if value, err := s.cacheRepo.Get(ctx, key); err == nil {
+		var triggers []models.ClickTrigger
+		if err := json.Unmarshal([]byte(value), &triggers); err == nil {
+			return triggers, nil
+		}
+	} else if !repository.IsCacheMiss(err) {
+		log.Printf("Failed to get cached click triggers: %v", err)
+	}
+
+	triggers, err := s.webhookRepo.GetTriggersByURL(ctx, urlID)
+	if err != nil {
+		return nil, err
+	}
+	if value, err := json.Marshal(triggers); err == nil {
+		if err := s.cacheRepo.Set(ctx, key, string(value), clickTriggerCacheTTL); err != nil {
+			log.Printf("Failed to cache click triggers: %v", err)
+		}
+	}
+	return triggers, nil
+}
+
+// invalidateClickTriggers drops a link's cached click triggers after they change
+func (s *webhookService) invalidateClickTriggers(ctx context.Context, urlID int) {
+	if err := s.cacheRepo.Delete(ctx, clickTriggersKey(urlID)); err != nil {
+		log.Printf("Failed to invalidate cached click triggers: %v", err)
+	}
+}
+
+// clickTriggersKey returns the cache key of a link's click triggers
+func clickTriggersKey(urlID int) string {
+	return fmt.Sprintf("click_triggers:%d", urlID)
+}
+
+// getOwnedURL loads one of the user's links, including inactive ones
+func (s *webhookService) getOwnedURL(ctx context.Context, shortCode string, userID int) (*models.URL, error) {
+	owned, err := s.urlRepo.CheckOwnership(ctx, shortCode, userID)
+	if err != nil {
+		return nil, errors.NewDatabaseError("Failed to check URL ownership", err)
+	}
+	if !owned {
+		return nil, errors.NewForbiddenError("URL not found or access denied", nil)
+	}
+
+	url, err := s.urlRepo.GetByShortCode(ctx, shortCode)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("URL not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get URL", err)
+	}
+	return url, nil
+}
+
+// getWebhook loads a webhook subscribed to one of the user's links
+func (s *webhookService) getWebhook(ctx context.Context, shortCode string, id int, userID int) (*models.Webhook, error) {
+	url, err := s.getOwnedURL(ctx, shortCode, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.webhookRepo.GetByID(ctx, id, url.ID)
+	if err != nil {
+		if repository.IsNotFound(err) {
+			return nil, errors.NewNotFoundError("Webhook not found", err)
+		}
+		return nil, errors.NewDatabaseError("Failed to get webhook", err)
+	}
+	return webhook, nil
+}
+
+// signWebhookPayload computes hex(HMAC-SHA256(secret, payload))
+func signWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
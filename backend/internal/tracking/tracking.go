@@ -0,0 +1,80 @@
+package tracking
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"github.com/hpower2/url-shortener/internal/config"
+)
+
+// Init configures the error tracker. Reporting stays disabled (and every
+// capture call is a no-op) when no DSN is configured.
+func Init(cfg *config.SentryConfig) error {
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:         cfg.DSN,
+		Environment: cfg.Environment,
+		Release:     cfg.Release,
+		SampleRate:  cfg.SampleRate,
+		Debug:       cfg.Debug,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize error tracking: %w", err)
+	}
+
+	return nil
+}
+
+// Flush waits up to timeout for queued events to be delivered
+func Flush(timeout time.Duration) {
+	sentry.Flush(timeout)
+}
+
+// CaptureRequestError reports an error tagged with the request's context
+func CaptureRequestError(c *gin.Context, err error) {
+	withRequestScope(c, func(hub *sentry.Hub) {
+		hub.CaptureException(err)
+	})
+}
+
+// CapturePanic reports a recovered panic tagged with the request's context
+func CapturePanic(c *gin.Context, recovered interface{}) {
+	withRequestScope(c, func(hub *sentry.Hub) {
+		hub.Recover(recovered)
+	})
+}
+
+// withRequestScope runs capture on a hub scoped to the request
+func withRequestScope(c *gin.Context, capture func(hub *sentry.Hub)) {
+	if sentry.CurrentHub().Client() == nil {
+		return
+	}
+
+	hub := sentry.CurrentHub().Clone()
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetRequest(c.Request)
+		scope.SetTag("method", c.Request.Method)
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		scope.SetTag("route", route)
+
+		if requestID, exists := c.Get("request_id"); exists {
+			scope.SetTag("request_id", fmt.Sprintf("%v", requestID))
+		}
+		if userID, exists := c.Get("user_id"); exists {
+			id := fmt.Sprintf("%v", userID)
+			scope.SetTag("user_id", id)
+			scope.SetUser(sentry.User{ID: id})
+		}
+
+		capture(hub)
+	})
+}
@@ -0,0 +1,11 @@
+-- Migration 004: Add user plans and link review status
+
+-- Add plan to users table (used for per-plan limits)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS plan VARCHAR(50) NOT NULL DEFAULT 'free';
+
+-- Links created beyond the destination-domain threshold are held for review
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS needs_review BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Create indexes for new columns
+CREATE INDEX IF NOT EXISTS idx_users_plan ON users(plan);
+CREATE INDEX IF NOT EXISTS idx_urls_needs_review ON urls(needs_review) WHERE needs_review = TRUE;
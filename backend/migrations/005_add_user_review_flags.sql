@@ -0,0 +1,11 @@
+-- Migration 005: Flag suspicious signups for review
+
+-- Accounts that tripped an anti-abuse check in "flag" mode
+ALTER TABLE users ADD COLUMN IF NOT EXISTS needs_review BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS review_reason TEXT NOT NULL DEFAULT '';
+
+-- Track the IP address used at signup
+ALTER TABLE users ADD COLUMN IF NOT EXISTS signup_ip INET NULL;
+
+-- Create indexes for new user columns
+CREATE INDEX IF NOT EXISTS idx_users_needs_review ON users(needs_review) WHERE needs_review = TRUE;
@@ -0,0 +1,20 @@
+-- Migration 008: Add referrer-based access rules and blocked click tracking
+
+-- Links can require (allow) or forbid (deny) a list of referrer domains
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS referrer_mode VARCHAR(10) NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS referrer_domains TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS referrer_fallback_url TEXT NOT NULL DEFAULT '';
+
+-- Redirect attempts rejected by a link's access rules
+CREATE TABLE IF NOT EXISTS blocked_clicks (
+    id SERIAL PRIMARY KEY,
+    url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+    reason VARCHAR(50) NOT NULL,
+    ip_address INET,
+    user_agent TEXT,
+    referer TEXT,
+    blocked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for blocked clicks table
+CREATE INDEX IF NOT EXISTS idx_blocked_clicks_url_id ON blocked_clicks(url_id, blocked_at DESC);
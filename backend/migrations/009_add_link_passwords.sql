@@ -0,0 +1,5 @@
+-- Migration 009: Add password-protected links
+
+-- bcrypt hash of the link password (empty means the link is not protected).
+-- Failed and locked-out unlock attempts are recorded in blocked_clicks.
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS password_hash VARCHAR(255) NOT NULL DEFAULT '';
@@ -0,0 +1,17 @@
+-- Migration 011: Add custom domains with branded error pages
+
+CREATE TABLE IF NOT EXISTS custom_domains (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    hostname VARCHAR(253) UNIQUE NOT NULL,
+    verification_token VARCHAR(64) NOT NULL,
+    verified_at TIMESTAMP NULL,
+    not_found_url TEXT NOT NULL DEFAULT '',
+    expired_url TEXT NOT NULL DEFAULT '',
+    error_html TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for custom domains table
+CREATE INDEX IF NOT EXISTS idx_custom_domains_user_id ON custom_domains(user_id);
@@ -0,0 +1,7 @@
+-- Migration 012: Add inactivity-based expiration for URLs
+
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS last_clicked_at TIMESTAMP NULL;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS inactivity_expiry_days INTEGER NOT NULL DEFAULT 0;
+
+-- Only links with an inactivity policy are scanned by the scheduler
+CREATE INDEX IF NOT EXISTS idx_urls_inactivity_expiry ON urls(inactivity_expiry_days) WHERE inactivity_expiry_days > 0;
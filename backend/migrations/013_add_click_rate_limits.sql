@@ -0,0 +1,3 @@
+-- Migration 013: Add per-link click rate limits
+
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS max_clicks_per_minute INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+-- Migration 014: Index last click time for recent-activity queries
+
+CREATE INDEX IF NOT EXISTS idx_urls_user_last_clicked ON urls(user_id, last_clicked_at DESC);
@@ -0,0 +1,77 @@
+-- Migration 015: Store timestamps as UTC-aware TIMESTAMPTZ and add profile timezones
+--
+-- Existing values are interpreted as UTC. Deployments whose application servers
+-- did not run in UTC should replace 'UTC' below with the server's time zone.
+
+-- url_stats depends on urls/click_events columns and must be recreated
+DROP VIEW IF EXISTS url_stats;
+
+ALTER TABLE urls
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC',
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ USING expires_at AT TIME ZONE 'UTC',
+    ALTER COLUMN last_clicked_at TYPE TIMESTAMPTZ USING last_clicked_at AT TIME ZONE 'UTC';
+
+ALTER TABLE click_events
+    ALTER COLUMN clicked_at TYPE TIMESTAMPTZ USING clicked_at AT TIME ZONE 'UTC';
+
+ALTER TABLE url_analytics
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+
+ALTER TABLE users
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC',
+    ALTER COLUMN email_verified_at TYPE TIMESTAMPTZ USING email_verified_at AT TIME ZONE 'UTC';
+
+ALTER TABLE otp_verifications
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ USING expires_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN verified_at TYPE TIMESTAMPTZ USING verified_at AT TIME ZONE 'UTC';
+
+ALTER TABLE api_keys
+    ALTER COLUMN last_used_at TYPE TIMESTAMPTZ USING last_used_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN revoked_at TYPE TIMESTAMPTZ USING revoked_at AT TIME ZONE 'UTC';
+
+ALTER TABLE webhooks
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+
+ALTER TABLE webhook_deliveries
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC';
+
+ALTER TABLE blocked_clicks
+    ALTER COLUMN blocked_at TYPE TIMESTAMPTZ USING blocked_at AT TIME ZONE 'UTC';
+
+ALTER TABLE user_preferences
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+
+ALTER TABLE custom_domains
+    ALTER COLUMN verified_at TYPE TIMESTAMPTZ USING verified_at AT TIME ZONE 'UTC',
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ USING created_at AT TIME ZONE 'UTC',
+    ALTER COLUMN updated_at TYPE TIMESTAMPTZ USING updated_at AT TIME ZONE 'UTC';
+
+-- Recreate view for URL statistics (day buckets are UTC)
+CREATE OR REPLACE VIEW url_stats AS
+SELECT 
+    u.id,
+    u.short_code,
+    u.original_url,
+    u.created_at,
+    u.updated_at,
+    u.click_count,
+    u.is_active,
+    u.expires_at,
+    COUNT(ce.id) as total_events,
+    COUNT(DISTINCT ce.ip_address) as unique_visitors,
+    COUNT(CASE WHEN ce.clicked_at >= CURRENT_DATE THEN 1 END) as clicks_today,
+    COUNT(CASE WHEN ce.clicked_at >= CURRENT_DATE - INTERVAL '7 days' THEN 1 END) as clicks_this_week,
+    COUNT(CASE WHEN ce.clicked_at >= CURRENT_DATE - INTERVAL '30 days' THEN 1 END) as clicks_this_month
+FROM urls u
+LEFT JOIN click_events ce ON u.id = ce.url_id
+GROUP BY u.id, u.short_code, u.original_url, u.created_at, u.updated_at, u.click_count, u.is_active, u.expires_at;
+
+-- Profile time zone used for analytics day boundaries (IANA name)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
@@ -0,0 +1,4 @@
+-- Migration 017: Add per-visitor frequency caps for URLs
+
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS frequency_cap INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS frequency_cap_url TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,16 @@
+-- Migration 018: Add bulk QR code batches rendered in the background
+
+CREATE TABLE IF NOT EXISTS qr_batches (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    short_codes TEXT[] NOT NULL,
+    size INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    archive BYTEA NULL,
+    error TEXT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMPTZ NULL
+);
+
+-- Create indexes for QR batches table
+CREATE INDEX IF NOT EXISTS idx_qr_batches_user_id ON qr_batches(user_id);
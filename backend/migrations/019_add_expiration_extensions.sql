@@ -0,0 +1,14 @@
+-- Migration 019: Add link expiration extension history
+
+CREATE TABLE IF NOT EXISTS url_expiration_extensions (
+    id SERIAL PRIMARY KEY,
+    url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+    user_id INTEGER NULL REFERENCES users(id) ON DELETE SET NULL,
+    previous_expires_at TIMESTAMPTZ NOT NULL,
+    new_expires_at TIMESTAMPTZ NOT NULL,
+    duration_seconds BIGINT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for expiration extensions table
+CREATE INDEX IF NOT EXISTS idx_url_expiration_extensions_url_id ON url_expiration_extensions(url_id, created_at DESC);
@@ -0,0 +1,14 @@
+-- Migration 020: Add click threshold triggers delivered through link webhooks
+
+CREATE TABLE IF NOT EXISTS click_triggers (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+    kind VARCHAR(10) NOT NULL,
+    threshold BIGINT NOT NULL,
+    last_fired_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for click triggers table
+CREATE INDEX IF NOT EXISTS idx_click_triggers_url_id ON click_triggers(url_id);
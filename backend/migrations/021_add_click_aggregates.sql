@@ -0,0 +1,19 @@
+-- Migration 021: Add aggregate-only click analytics
+
+-- Clicks per link per hour, so day buckets can follow any time zone
+CREATE TABLE IF NOT EXISTS click_aggregates (
+    url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+    bucket TIMESTAMPTZ NOT NULL,
+    clicks BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (url_id, bucket)
+);
+
+-- Clicks per link per UTC day by country and referrer domain
+CREATE TABLE IF NOT EXISTS click_dimension_aggregates (
+    url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    dimension VARCHAR(20) NOT NULL,
+    value VARCHAR(255) NOT NULL,
+    clicks BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (url_id, day, dimension, value)
+);
@@ -0,0 +1,14 @@
+-- Migration 022: Add monthly organization usage reports
+
+CREATE TABLE IF NOT EXISTS organization_usage_reports (
+    id SERIAL PRIMARY KEY,
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    period_start DATE NOT NULL,
+    links_created BIGINT NOT NULL DEFAULT 0,
+    clicks_served BIGINT NOT NULL DEFAULT 0,
+    api_calls BIGINT NOT NULL DEFAULT 0,
+    storage_bytes BIGINT NOT NULL DEFAULT 0,
+    generated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    emailed_at TIMESTAMPTZ NULL,
+    UNIQUE (organization_id, period_start)
+);
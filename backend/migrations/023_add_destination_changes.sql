@@ -0,0 +1,15 @@
+-- Migration 023: Add link destination change history
+
+-- Every change of a link's destination is re-checked and kept for audit
+CREATE TABLE IF NOT EXISTS url_destination_changes (
+    id SERIAL PRIMARY KEY,
+    url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+    user_id INTEGER NULL REFERENCES users(id) ON DELETE SET NULL,
+    previous_url TEXT NOT NULL,
+    new_url TEXT NOT NULL,
+    held_for_review BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for destination changes table
+CREATE INDEX IF NOT EXISTS idx_url_destination_changes_url_id ON url_destination_changes(url_id, created_at DESC);
@@ -0,0 +1,28 @@
+-- Migration 024: Add per-organization data regions
+
+-- Region holding the organization's link and click data ('' is the home region)
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS data_region VARCHAR(50) NOT NULL DEFAULT '';
+
+-- Directory of links stored outside the home region, used to route public visits.
+-- Kept in the home region only; links missing from it live in the home region.
+CREATE TABLE IF NOT EXISTS link_regions (
+    short_code VARCHAR(20) PRIMARY KEY,
+    region VARCHAR(50) NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_link_regions_user_id ON link_regions(user_id);
+
+-- Regional links count towards their owner's link limit in the home region
+DROP TRIGGER IF EXISTS link_regions_insert_trigger ON link_regions;
+CREATE TRIGGER link_regions_insert_trigger
+    AFTER INSERT ON link_regions
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_increment_link_count();
+
+DROP TRIGGER IF EXISTS link_regions_delete_trigger ON link_regions;
+CREATE TRIGGER link_regions_delete_trigger
+    AFTER DELETE ON link_regions
+    FOR EACH ROW
+    EXECUTE FUNCTION trigger_decrement_link_count();
@@ -0,0 +1,7 @@
+-- Migration 025: Track undeliverable user email addresses
+
+-- Set from provider bounce and complaint notifications; sends are paused while
+-- the status is not 'deliverable'
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_status VARCHAR(20) NOT NULL DEFAULT 'deliverable';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_status_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_status_at TIMESTAMPTZ;
@@ -0,0 +1,17 @@
+-- Migration 026: Add share tokens for password-protected links
+
+-- Named codes that open a protected link without its password. Only a SHA-256
+-- hash of the token is stored; revoked tokens are kept for their access counts.
+CREATE TABLE IF NOT EXISTS link_share_tokens (
+    id SERIAL PRIMARY KEY,
+    url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    token_prefix VARCHAR(8) NOT NULL,
+    access_count BIGINT NOT NULL DEFAULT 0,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_link_share_tokens_url_id ON link_share_tokens(url_id);
@@ -0,0 +1,5 @@
+-- Migration 027: Add click mirroring (shadow traffic) for debugging integrations
+
+-- Endpoint receiving a copy of each click's metadata until shadow_until (empty disables it)
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS shadow_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS shadow_until TIMESTAMPTZ;
@@ -0,0 +1,18 @@
+-- Migration 028: Add rotator links that cycle through several destinations
+
+-- Empty for regular links, otherwise "sequential" or "random"
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS rotation_mode VARCHAR(20) NOT NULL DEFAULT '';
+
+-- Destinations of a rotator link in rotation order, with their own click counts
+CREATE TABLE IF NOT EXISTS link_destinations (
+    id SERIAL PRIMARY KEY,
+    url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    destination_url TEXT NOT NULL,
+    click_count BIGINT NOT NULL DEFAULT 0,
+    last_clicked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (url_id, destination_url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_link_destinations_url_id ON link_destinations(url_id, position);
@@ -0,0 +1,7 @@
+-- Migration 029: Add click-count expiration (e.g. one-time links)
+
+-- Redirects allowed before the link deactivates itself (0 means unlimited), and
+-- how many have been claimed. The claim counter is updated atomically on the
+-- redirect path, separately from the asynchronously recorded click_count.
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS max_clicks INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS redirect_count INTEGER NOT NULL DEFAULT 0;
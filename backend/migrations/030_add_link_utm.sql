@@ -0,0 +1,6 @@
+-- Migration 030: Store UTM parameters with links
+
+-- Added to the destination on redirect rather than baked into original_url
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS utm_params JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx_urls_utm_campaign ON urls(user_id, (utm_params->>'utm_campaign'));
@@ -0,0 +1,16 @@
+-- Migration 031: Full-text and substring search over a user's links
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Punctuation is split out of URLs so path segments and query values match as words
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS search_vector TSVECTOR
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', short_code), 'A') ||
+        setweight(to_tsvector('simple', regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_urls_search_vector ON urls USING GIN (search_vector);
+
+-- Trigram indexes serve partial matches that aren't whole words
+CREATE INDEX IF NOT EXISTS idx_urls_original_url_trgm ON urls USING GIN (original_url gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_urls_short_code_trgm ON urls USING GIN (short_code gin_trgm_ops);
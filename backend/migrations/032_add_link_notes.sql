@@ -0,0 +1,17 @@
+-- Migration 032: Internal notes and labels on links
+
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS notes TEXT NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}';
+
+-- Rebuild the search document to include notes and label keys and values
+DROP INDEX IF EXISTS idx_urls_search_vector;
+ALTER TABLE urls DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE urls ADD COLUMN search_vector TSVECTOR
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', short_code), 'A') ||
+        setweight(to_tsvector('simple', regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g')), 'B') ||
+        setweight(jsonb_to_tsvector('simple', labels, '["key", "string"]'), 'B') ||
+        setweight(to_tsvector('simple', notes), 'C')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_urls_search_vector ON urls USING GIN (search_vector);
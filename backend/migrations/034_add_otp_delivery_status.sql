@@ -0,0 +1,9 @@
+-- Migration 034: Track delivery of OTP emails
+
+-- queued -> sent | failed, and sent -> bounced when the provider reports a bounce
+ALTER TABLE otp_verifications ADD COLUMN IF NOT EXISTS delivery_status VARCHAR(20) NOT NULL DEFAULT 'queued';
+ALTER TABLE otp_verifications ADD COLUMN IF NOT EXISTS delivery_error TEXT NOT NULL DEFAULT '';
+ALTER TABLE otp_verifications ADD COLUMN IF NOT EXISTS delivery_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE otp_verifications ADD COLUMN IF NOT EXISTS delivery_updated_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_otp_verifications_delivery_status ON otp_verifications(delivery_status, created_at DESC);
@@ -0,0 +1,3 @@
+-- Migration 035: Index click events for streaming in insertion order
+
+CREATE INDEX IF NOT EXISTS idx_click_events_url_id_id ON click_events(url_id, id);
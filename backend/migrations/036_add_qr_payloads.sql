@@ -0,0 +1,16 @@
+-- Migration 036: Add QR payloads (contact cards, Wi-Fi networks, calendar events)
+
+-- Each payload is served through its own short link, so scans are counted as
+-- the link's clicks. Payloads live with their link, in its data region.
+CREATE TABLE IF NOT EXISTS qr_payloads (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url_id INTEGER NOT NULL UNIQUE REFERENCES urls(id) ON DELETE CASCADE,
+    token VARCHAR(64) NOT NULL UNIQUE,
+    type VARCHAR(20) NOT NULL,
+    data JSONB NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_qr_payloads_user_id ON qr_payloads(user_id);
@@ -0,0 +1,9 @@
+-- Migration 037: Track threat-list scans of link destinations
+
+-- threat_type is set (e.g. MALWARE, SOCIAL_ENGINEERING) when a rescan finds
+-- the destination on a threat list and the link is deactivated for review.
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS threat_type VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS scanned_at TIMESTAMPTZ NULL;
+
+-- Active links are rescanned least recently scanned first
+CREATE INDEX IF NOT EXISTS idx_urls_scanned_at ON urls(scanned_at NULLS FIRST) WHERE is_active = TRUE;
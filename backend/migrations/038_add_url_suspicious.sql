@@ -0,0 +1,5 @@
+-- Migration 038: Warn visitors before redirecting to suspicious links
+
+-- Suspicious links show an interstitial warning page instead of redirecting
+-- straight away. Set by operators or by a rescan finding a lower-severity threat.
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS suspicious BOOLEAN NOT NULL DEFAULT FALSE;
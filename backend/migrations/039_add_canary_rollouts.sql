@@ -0,0 +1,11 @@
+-- Migration 039: Canary rollouts gradually shifting a link's clicks to a new destination
+
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS canary_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS canary_status VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS canary_start_percent INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS canary_started_at TIMESTAMPTZ NULL;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS canary_ends_at TIMESTAMPTZ NULL;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS canary_failures INTEGER NOT NULL DEFAULT 0;
+
+-- The canary monitor health-checks every rollout in progress
+CREATE INDEX IF NOT EXISTS idx_urls_canary_rolling_out ON urls(canary_ends_at) WHERE canary_status = 'rolling_out';
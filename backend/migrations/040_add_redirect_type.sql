@@ -0,0 +1,5 @@
+-- Migration 040: Per-link redirect type
+
+-- Empty keeps the default (301, or 302 for links re-evaluated on every visit);
+-- otherwise 301, 302, 307 or meta (an HTML page redirecting with meta refresh)
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS redirect_type VARCHAR(10) NOT NULL DEFAULT '';
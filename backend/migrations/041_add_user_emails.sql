@@ -0,0 +1,14 @@
+-- Migration 041: Secondary login emails
+
+-- Addresses a user added besides users.email (their primary address). Once
+-- verified they can be used to log in and can be made the primary address.
+-- An address belongs to at most one account, as either kind.
+CREATE TABLE IF NOT EXISTS user_emails (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    verified_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_emails_user_id ON user_emails(user_id);
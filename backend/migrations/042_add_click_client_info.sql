@@ -0,0 +1,6 @@
+-- Migration 042: Browser, device type and OS of each click
+
+-- Parsed from the user agent when the click is recorded; empty for older clicks
+ALTER TABLE click_events ADD COLUMN IF NOT EXISTS browser VARCHAR(50) NOT NULL DEFAULT '';
+ALTER TABLE click_events ADD COLUMN IF NOT EXISTS device VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE click_events ADD COLUMN IF NOT EXISTS os VARCHAR(50) NOT NULL DEFAULT '';
@@ -0,0 +1,12 @@
+-- Migration 043: Destination domains whose ownership users proved over DNS
+
+CREATE TABLE IF NOT EXISTS verified_domains (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    domain VARCHAR(253) NOT NULL,
+    verification_token VARCHAR(64) NOT NULL,
+    verified_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    -- Also serves the lookups made when links are created and redirected
+    UNIQUE (user_id, domain)
+);
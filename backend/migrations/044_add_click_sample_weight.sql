@@ -0,0 +1,5 @@
+-- Migration 044: Sampled click events
+
+-- How many clicks each stored event stands for: 1 normally, more for the
+-- events kept while a hot link's clicks were being sampled
+ALTER TABLE click_events ADD COLUMN IF NOT EXISTS sample_weight INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+-- Migration 045: Sandbox API keys
+
+-- Requests signed with a sandbox key validate writes without persisting them
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS sandbox BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,8 @@
+-- Migration 046: Per-user presets for new links
+
+-- Applied to each new link that doesn't set its own value; 0 days and an empty
+-- redirect type fall back to the server defaults
+ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS default_expiry_days INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS default_redirect_type VARCHAR(10) NOT NULL DEFAULT '';
+ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS default_labels JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS qr_style JSONB NOT NULL DEFAULT '{}';
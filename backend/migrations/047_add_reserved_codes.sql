@@ -0,0 +1,8 @@
+-- Migration 047: Short codes reserved by operators
+
+-- Codes are stored in lower case and block every casing of the code
+CREATE TABLE IF NOT EXISTS reserved_codes (
+    code VARCHAR(20) PRIMARY KEY,
+    reason VARCHAR(200) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,5 @@
+-- Migration 048: Sequence of generated short codes
+
+-- Each generated code encodes the next number, shuffled by SHORT_CODE_SECRET,
+-- so generated codes never collide with each other
+CREATE SEQUENCE IF NOT EXISTS short_code_seq;
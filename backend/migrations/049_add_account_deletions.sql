@@ -0,0 +1,28 @@
+-- Migration 049: Add account deletions purged in the background
+
+-- Kept after the user is deleted, so there is no foreign key to users
+CREATE TABLE IF NOT EXISTS account_deletions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    requested_by VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    stage VARCHAR(20) NOT NULL,
+    region VARCHAR(50) NOT NULL DEFAULT '',
+    chunks INTEGER NOT NULL DEFAULT 0,
+    caches_purged BIGINT NOT NULL DEFAULT 0,
+    click_events_deleted BIGINT NOT NULL DEFAULT 0,
+    aggregates_deleted BIGINT NOT NULL DEFAULT 0,
+    urls_deleted BIGINT NOT NULL DEFAULT 0,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    completed_at TIMESTAMPTZ NULL
+);
+
+-- An account has at most one deletion in progress
+CREATE UNIQUE INDEX IF NOT EXISTS idx_account_deletions_active_user
+    ON account_deletions(user_id) WHERE status IN ('pending', 'running');
+
+CREATE INDEX IF NOT EXISTS idx_account_deletions_created_at ON account_deletions(created_at DESC);
@@ -0,0 +1,17 @@
+-- Migration 050: Titles on links
+
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS title VARCHAR(200) NOT NULL DEFAULT '';
+
+-- Rebuild the search document so titles rank with short codes
+DROP INDEX IF EXISTS idx_urls_search_vector;
+ALTER TABLE urls DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE urls ADD COLUMN search_vector TSVECTOR
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('simple', short_code), 'A') ||
+        setweight(to_tsvector('simple', title), 'A') ||
+        setweight(to_tsvector('simple', regexp_replace(original_url, '[^[:alnum:]]+', ' ', 'g')), 'B') ||
+        setweight(jsonb_to_tsvector('simple', labels, '["key", "string"]'), 'B') ||
+        setweight(to_tsvector('simple', notes), 'C')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_urls_search_vector ON urls USING GIN (search_vector);
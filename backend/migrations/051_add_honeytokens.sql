@@ -0,0 +1,19 @@
+-- Migration 051: Honeytoken short codes
+
+-- Honeytokens are reserved codes that are never published, so any visit to
+-- one comes from someone enumerating short codes
+ALTER TABLE reserved_codes ADD COLUMN IF NOT EXISTS honeytoken BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Visits to honeytokens, kept after their visitors' suspicion expires
+CREATE TABLE IF NOT EXISTS honeytoken_hits (
+    id SERIAL PRIMARY KEY,
+    code VARCHAR(20) NOT NULL,
+    ip_address VARCHAR(45) NOT NULL,
+    asn VARCHAR(20) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    referer TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_honeytoken_hits_created_at ON honeytoken_hits(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_honeytoken_hits_ip_address ON honeytoken_hits(ip_address, created_at DESC);
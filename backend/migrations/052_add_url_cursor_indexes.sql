@@ -0,0 +1,5 @@
+-- Migration 052: Index link lists in the order they are paged through by cursor
+
+CREATE INDEX IF NOT EXISTS idx_urls_user_created_at_id ON urls(user_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_urls_user_click_count_id ON urls(user_id, click_count DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_urls_user_last_clicked_id ON urls(user_id, last_clicked_at DESC NULLS LAST, id DESC);
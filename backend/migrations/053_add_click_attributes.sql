@@ -0,0 +1,3 @@
+-- Migration 053: Store the attributes click enrichers add to clicks
+
+ALTER TABLE click_events ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,3 @@
+-- Migration 054: Index link lists sorted by expiration
+
+CREATE INDEX IF NOT EXISTS idx_urls_user_expires_at_id ON urls(user_id, expires_at DESC NULLS LAST, id DESC);
@@ -0,0 +1,3 @@
+-- Migration 055: Record whether a click came from a QR code scan
+
+ALTER TABLE click_events ADD COLUMN IF NOT EXISTS channel VARCHAR(10) NOT NULL DEFAULT 'click';
@@ -0,0 +1,21 @@
+-- Migration 056: Add guest edit links
+
+-- Single-use codes that let someone without an account change a link's
+-- destination before they expire. Only a SHA-256 hash of the token is stored.
+CREATE TABLE IF NOT EXISTS guest_edit_links (
+    id SERIAL PRIMARY KEY,
+    url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    token_prefix VARCHAR(8) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_guest_edit_links_url_id ON guest_edit_links(url_id);
+
+-- Destination changes made through a guest edit link name it
+ALTER TABLE url_destination_changes
+    ADD COLUMN IF NOT EXISTS guest_edit_link_id INTEGER NULL REFERENCES guest_edit_links(id) ON DELETE SET NULL;
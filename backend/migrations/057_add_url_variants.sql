@@ -0,0 +1,22 @@
+-- Migration 057: Add A/B split tests sending visits to weighted destination variants
+
+-- Empty for regular links, otherwise "weighted" or "sticky"
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS split_mode VARCHAR(20) NOT NULL DEFAULT '';
+
+-- Variants of a split test link in listed order, the first being the control,
+-- with their own click and conversion counts
+CREATE TABLE IF NOT EXISTS url_variants (
+    id SERIAL PRIMARY KEY,
+    url_id INTEGER NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    name VARCHAR(50) NOT NULL,
+    destination_url TEXT NOT NULL,
+    weight INTEGER NOT NULL DEFAULT 1,
+    click_count BIGINT NOT NULL DEFAULT 0,
+    conversion_count BIGINT NOT NULL DEFAULT 0,
+    last_clicked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (url_id, name)
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_variants_url_id ON url_variants(url_id, position);
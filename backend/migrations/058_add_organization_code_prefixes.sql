@@ -0,0 +1,13 @@
+-- Migration 058: Add short code prefixes reserved for organizations
+
+-- Custom codes starting with the prefix and a hyphen (e.g. "acme-") are only
+-- for the organization's members; empty for organizations without one
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS code_prefix VARCHAR(20) NOT NULL DEFAULT '';
+
+-- Members' custom codes must use the prefix
+ALTER TABLE organizations ADD COLUMN IF NOT EXISTS require_code_prefix BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_organizations_code_prefix ON organizations(code_prefix) WHERE code_prefix <> '';
+
+-- Lists and rollups of the links in an organization's namespace
+CREATE INDEX IF NOT EXISTS idx_urls_short_code_pattern ON urls(LOWER(short_code) text_pattern_ops);
@@ -0,0 +1,4 @@
+-- Migration 059: Add device rules routing visits by the visitor's device
+
+-- Ordered [{"device": "ios", "url": "https://apps.apple.com/..."}, ...]; empty for regular links
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS device_rules JSONB NOT NULL DEFAULT '[]';
@@ -0,0 +1,12 @@
+-- Migration 060: Add the title, description and favicon fetched from link destinations
+
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS page_title VARCHAR(300) NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS page_description VARCHAR(1000) NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS favicon_url TEXT NOT NULL DEFAULT '';
+
+-- When the metadata was last fetched successfully, and when a fetch was last
+-- attempted, so unreachable destinations aren't retried on every run
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS metadata_fetched_at TIMESTAMPTZ;
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS metadata_checked_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_urls_metadata_checked_at ON urls(metadata_checked_at NULLS FIRST, id) WHERE is_active = TRUE;
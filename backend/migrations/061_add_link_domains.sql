@@ -0,0 +1,8 @@
+-- Migration 061: Add the custom domain links are shared on, and a default domain for new links
+
+-- Hostname of one of the owner's verified custom domains, or empty for the default domain
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS domain VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_urls_user_domain ON urls(user_id, domain) WHERE domain <> '';
+
+ALTER TABLE user_preferences ADD COLUMN IF NOT EXISTS default_domain VARCHAR(255) NOT NULL DEFAULT '';
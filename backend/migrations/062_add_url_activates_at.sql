@@ -0,0 +1,4 @@
+-- Migration 062: Add scheduled activation of links
+
+-- Links don't redirect before this time (NULL redirects right away)
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS activates_at TIMESTAMPTZ NULL;
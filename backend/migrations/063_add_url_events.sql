@@ -0,0 +1,18 @@
+-- Migration 063: Add the append-only lifecycle event log of links
+
+-- Events outlive their link, so url_id doesn't reference urls
+CREATE TABLE IF NOT EXISTS url_events (
+    id BIGSERIAL PRIMARY KEY,
+    url_id INTEGER NOT NULL,
+    short_code VARCHAR(50) NOT NULL,
+    user_id INTEGER NOT NULL,
+    type VARCHAR(30) NOT NULL,
+    data JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Set once the event reached the message bus; NULL events are published again
+    published_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_events_url ON url_events(url_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_url_events_user ON url_events(user_id);
+CREATE INDEX IF NOT EXISTS idx_url_events_unpublished ON url_events(created_at) WHERE published_at IS NULL;
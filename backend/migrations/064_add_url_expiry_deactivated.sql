@@ -0,0 +1,11 @@
+-- Migration 064: Deactivate expired links in the background
+
+-- Set when the cleanup job deactivated a link because it expired, so giving it
+-- a new expiration reactivates it
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS expiry_deactivated BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Active links past their expiration, for the cleanup job
+CREATE INDEX IF NOT EXISTS idx_urls_active_expires_at ON urls(expires_at) WHERE is_active AND expires_at IS NOT NULL;
+
+-- Raw events past the click event retention window, for the cleanup job
+CREATE INDEX IF NOT EXISTS idx_blocked_clicks_blocked_at ON blocked_clicks(blocked_at);
@@ -0,0 +1,8 @@
+-- Migration 066: Reactivate click-limited links when their limit is raised
+
+-- Set when a link deactivated because its last allowed redirect was taken, so
+-- raising or removing max_clicks reactivates it
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS click_limit_deactivated BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE urls SET click_limit_deactivated = TRUE
+WHERE NOT is_active AND max_clicks > 0 AND redirect_count >= max_clicks;
@@ -0,0 +1,12 @@
+-- Migration 067: Only verified secondary emails claim an address
+
+-- An unverified address proves nothing, so it mustn't keep its owner from
+-- registering or adding it. Several accounts may claim it until one verifies
+-- it; unverified claims are removed by the cleanup job.
+ALTER TABLE user_emails DROP CONSTRAINT IF EXISTS user_emails_email_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_emails_verified_email ON user_emails(email) WHERE verified_at IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_emails_user_email ON user_emails(user_id, email);
+
+-- Unverified claims, for the cleanup job
+CREATE INDEX IF NOT EXISTS idx_user_emails_unverified_created_at ON user_emails(created_at) WHERE verified_at IS NULL;
@@ -0,0 +1,9 @@
+-- Migration 068: Record each link event once
+
+-- Replicas running the event relay concurrently may have appended the same
+-- expiration twice; keep the first
+DELETE FROM url_events a
+USING url_events b
+WHERE a.url_id = b.url_id AND a.type = b.type AND a.created_at = b.created_at AND a.id > b.id;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_url_events_url_type_created_at ON url_events(url_id, type, created_at);
@@ -0,0 +1,13 @@
+-- Migration 069: Render QR batches from the table, and expire their archives
+
+-- Instances claim pending batches before rendering them, so a batch whose
+-- instance died is picked up by another once its claim goes stale
+ALTER TABLE qr_batches ADD COLUMN IF NOT EXISTS claimed_at TIMESTAMPTZ NULL;
+ALTER TABLE qr_batches ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
+
+-- Batches queued before claims existed were rendered by their creating
+-- instance only; queue them again
+UPDATE qr_batches SET claimed_at = NULL WHERE status = 'pending';
+
+CREATE INDEX IF NOT EXISTS idx_qr_batches_pending ON qr_batches(claimed_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_qr_batches_completed_at ON qr_batches(completed_at) WHERE status = 'completed';
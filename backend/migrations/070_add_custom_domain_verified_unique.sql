@@ -0,0 +1,12 @@
+-- Migration 070: Only verified custom domains claim a hostname
+
+-- An unverified domain proves nothing, so it mustn't keep the hostname's owner
+-- from adding it. Several accounts may add it until one verifies it;
+-- unverified domains are removed by the cleanup job.
+ALTER TABLE custom_domains DROP CONSTRAINT IF EXISTS custom_domains_hostname_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_custom_domains_verified_hostname ON custom_domains(hostname) WHERE verified_at IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_custom_domains_user_hostname ON custom_domains(user_id, hostname);
+
+-- Unverified domains, for the cleanup job
+CREATE INDEX IF NOT EXISTS idx_custom_domains_unverified_created_at ON custom_domains(created_at) WHERE verified_at IS NULL;
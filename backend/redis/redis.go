@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-redis/redis/v8"
 	"github.com/hpower2/url-shortener/config"
+	"github.com/hpower2/url-shortener/internal/chaos"
 )
 
 type Client struct {
@@ -20,6 +21,7 @@ func NewRedisClient(cfg *config.RedisConfig) (*Client, error) {
 		Password: cfg.Password,
 		DB:       cfg.DB,
 	})
+	chaos.InstrumentRedis(rdb)
 
 	// Test the connection
 	ctx := context.Background()
@@ -13,6 +13,7 @@ import ErrorExpired from './pages/ErrorExpired'
 import ErrorInactive from './pages/ErrorInactive'
 import ErrorNotFound from './pages/ErrorNotFound'
 import ErrorServer from './pages/ErrorServer'
+import GuestEdit from './pages/GuestEdit'
 import PublicRoute from './components/PublicRoute'
 import PrivateRoute from './components/PrivateRoute'
 
@@ -60,6 +61,9 @@ function AppRoutes() {
                 <Route path="/error/not-found" element={<ErrorNotFound />} />
                 <Route path="/error/server-error" element={<ErrorServer />} />
 
+                {/* Guest edit links, opened without an account */}
+                <Route path="/guest-edit/:token" element={<GuestEdit />} />
+
                 {/* 404 page for all unmatched routes */}
                 <Route path="*" element={<NotFound />} />
             </Routes>
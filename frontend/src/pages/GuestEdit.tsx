@@ -0,0 +1,122 @@
+import React, { useEffect, useState } from 'react'
+import { useParams } from 'react-router-dom'
+import { LinkIcon } from '@heroicons/react/24/outline'
+import { guestEditAPI, GuestEditView } from '../services/api'
+
+export default function GuestEdit() {
+    const { token } = useParams<{ token: string }>()
+    const [link, setLink] = useState<GuestEditView | null>(null)
+    const [destination, setDestination] = useState('')
+    const [loading, setLoading] = useState(true)
+    const [saving, setSaving] = useState(false)
+    const [saved, setSaved] = useState(false)
+    const [error, setError] = useState('')
+
+    useEffect(() => {
+        if (!token) return
+
+        guestEditAPI.get(token)
+            .then((response) => {
+                setLink(response.data)
+                setDestination(response.data.original_url)
+            })
+            .catch((error: any) => {
+                setError(error.response?.data?.error?.message || 'This edit link is not valid')
+            })
+            .finally(() => setLoading(false))
+    }, [token])
+
+    const handleSubmit = async (e: React.FormEvent) => {
+        e.preventDefault()
+        if (!token) return
+        setSaving(true)
+        setError('')
+
+        try {
+            const response = await guestEditAPI.update(token, destination)
+            setLink(response.data)
+            setSaved(true)
+        } catch (error: any) {
+            setError(error.response?.data?.error?.message || 'Failed to change the destination')
+        } finally {
+            setSaving(false)
+        }
+    }
+
+    return (
+        <div className="min-h-screen flex items-center justify-center bg-gray-50 py-12 px-4 sm:px-6 lg:px-8">
+            <div className="max-w-md w-full space-y-8">
+                <div>
+                    <div className="flex justify-center">
+                        <LinkIcon className="h-12 w-12 text-blue-600" />
+                    </div>
+                    <h2 className="mt-6 text-center text-3xl font-extrabold text-gray-900">
+                        Change link destination
+                    </h2>
+                    {link && (
+                        <p className="mt-2 text-center text-sm text-gray-600">
+                            {link.short_url} · shared with {link.name}
+                        </p>
+                    )}
+                </div>
+
+                {loading && (
+                    <p className="text-center text-sm text-gray-600">Loading...</p>
+                )}
+
+                {saved && link && (
+                    <div className="rounded-md bg-green-50 p-4">
+                        <div className="text-sm text-green-700">
+                            {link.short_url} now goes to {link.original_url}. This edit link has been used up.
+                        </div>
+                    </div>
+                )}
+
+                {!loading && !saved && link && (
+                    <form className="mt-8 space-y-6" onSubmit={handleSubmit}>
+                        <div>
+                            <label htmlFor="destination" className="block text-sm font-medium text-gray-700">
+                                New destination
+                            </label>
+                            <input
+                                id="destination"
+                                name="destination"
+                                type="url"
+                                required
+                                className="mt-1 appearance-none relative block w-full px-3 py-2 border border-gray-300 placeholder-gray-500 text-gray-900 rounded-md focus:outline-none focus:ring-blue-500 focus:border-blue-500 sm:text-sm"
+                                placeholder="https://example.com/landing"
+                                value={destination}
+                                onChange={(e) => setDestination(e.target.value)}
+                            />
+                            <p className="mt-2 text-xs text-gray-500">
+                                You can change the destination once, until {new Date(link.expires_at).toLocaleString()}.
+                            </p>
+                        </div>
+
+                        {error && (
+                            <div className="rounded-md bg-red-50 p-4">
+                                <div className="text-sm text-red-700">{error}</div>
+                            </div>
+                        )}
+
+                        <div>
+                            <button
+                                type="submit"
+                                disabled={saving}
+                                className="group relative w-full flex justify-center py-2 px-4 border border-transparent text-sm font-medium rounded-md text-white bg-blue-600 hover:bg-blue-700 focus:outline-none focus:ring-2 focus:ring-offset-2 focus:ring-blue-500 disabled:opacity-50 disabled:cursor-not-allowed"
+                            >
+                                {saving ? 'Saving...' : 'Change destination'}
+                            </button>
+                        </div>
+                    </form>
+                )}
+
+                {!loading && !link && error && (
+                    <div className="rounded-md bg-red-50 p-4">
+                        <div className="text-sm text-red-700">{error}</div>
+                    </div>
+                )}
+            </div>
+        </div>
+    )
+}
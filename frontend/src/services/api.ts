@@ -79,6 +79,14 @@ export interface LoginRequest {
     password: string
 }
 
+export interface GuestEditView {
+    short_code: string
+    short_url: string
+    original_url: string
+    name: string
+    expires_at: string
+}
+
 export interface RegisterRequest {
     email: string
     password: string
@@ -111,4 +119,11 @@ export const urlsAPI = {
     getQRCode: (shortCode: string) => api.get(`/api/v1/urls/${shortCode}/qr`, { responseType: 'blob' }),
 }
 
+// Guest edit API, used without an account
+export const guestEditAPI = {
+    get: (token: string) => api.get(`/api/v1/guest-edit/${token}`),
+    update: (token: string, originalURL: string) =>
+        api.put(`/api/v1/guest-edit/${token}`, { original_url: originalURL }),
+}
+
 export default api 
\ No newline at end of file